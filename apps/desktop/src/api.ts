@@ -100,6 +100,18 @@ export async function clientsApply(client: string, config: any, path?: string):
   if (!r.ok) throw new Error("clients apply failed");
 }
 
+export type ClientConfigDiff = { status: string; path: string; changed: boolean; diff: string };
+
+export async function clientsDiff(client: string, config: any, path?: string): Promise<ClientConfigDiff> {
+  const r = await fetch(`${BASE}/v1/clients/diff`, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ client, config, path }),
+  });
+  if (!r.ok) throw new Error("clients diff failed");
+  return r.json();
+}
+
 export async function clientPreview(client: string): Promise<any> {
   const r = await fetch(`${BASE}/v1/clients/preview?client=${encodeURIComponent(client)}`);
   if (!r.ok) throw new Error("preview failed");
@@ -565,17 +565,18 @@ export function Clients() {
             })),
         };
       } else if (currentConfig?.mcpServers || clientName.includes("Cursor")) {
+        // The manager merges mcpServers by name, so unassigned tools are sent as null to remove them
         newConfig = {
           mcpServers: Object.fromEntries(
-            availableTools
-              .filter(tool => assignments[clientName]?.[tool])
-              .map(tool => [
-                tool,
-                {
-                  command: `/path/to/${tool}`,
-                  args: [],
-                },
-              ])
+            availableTools.map(tool => [
+              tool,
+              assignments[clientName]?.[tool]
+                ? {
+                    command: `/path/to/${tool}`,
+                    args: [],
+                  }
+                : null,
+            ])
           ),
         };
       } else {
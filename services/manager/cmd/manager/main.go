@@ -2,23 +2,52 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"mcp/manager/internal/buildinfo"
 	"mcp/manager/internal/health"
 	api "mcp/manager/internal/httpapi"
+	"mcp/manager/internal/install"
 	"mcp/manager/internal/logs"
 	"mcp/manager/internal/paths"
+	"mcp/manager/internal/providers"
 	"mcp/manager/internal/registry"
+	"mcp/manager/internal/settings"
 	"mcp/manager/internal/supervisor"
 )
 
 func main() {
 	log.SetPrefix("mcp-manager: ")
+	configDir := flag.String("config-dir", "", "directory holding the registry, settings, servers and logs (default $MCP_HOME or ~/.mcp)")
+	umask := flag.String("umask", "", "octal umask applied to files and directories the manager creates (default $MCP_UMASK or 027)")
+	flag.Parse()
+	if *configDir != "" {
+		if err := paths.SetRoot(*configDir); err != nil {
+			log.Fatalf("fatal: invalid config dir: %v", err)
+		}
+	}
+	if *umask != "" {
+		mask, err := paths.ParseUmask(*umask)
+		if err == nil {
+			err = paths.SetUmask(mask)
+		}
+		if err != nil {
+			log.Fatalf("fatal: %v", err)
+		}
+	}
+
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
@@ -28,12 +57,45 @@ func main() {
 }
 
 func run(ctx context.Context) error {
-	log.Println("starting manager daemon")
+	log.Printf("starting manager daemon: %s", buildinfo.Get())
 
 	// Ensure all required directories exist
 	if err := paths.EnsureAllDirectories(); err != nil {
 		return fmt.Errorf("failed to create required directories: %w", err)
 	}
+	if root, err := paths.Root(); err == nil {
+		log.Printf("using config dir %s", root)
+	}
+
+	// Warn about built-in providers whose examples contradict their validation
+	for _, err := range providers.SelfCheck() {
+		log.Printf("Warning: provider self-check: %v", err)
+	}
+
+	// Add the user's own providers, skipping any that no longer validate
+	if path, err := providers.CustomProvidersFile(); err == nil {
+		for _, err := range providers.LoadCustomProviders(path) {
+			log.Printf("Warning: custom provider not loaded: %v", err)
+		}
+	}
+
+	// Load settings; a broken settings file should not keep the daemon down
+	appSettings, err := settings.LoadDefault()
+	if err != nil {
+		log.Printf("Warning: failed to load settings, using defaults: %v", err)
+		appSettings = settings.NewDefault()
+	}
+
+	// Trust a private CA for health checks and installer subprocesses
+	var rootCAs *x509.CertPool
+	if path := appSettings.TLS.CABundle; path != "" {
+		if rootCAs, err = health.LoadCABundle(path); err != nil {
+			log.Printf("Warning: ignoring custom CA bundle: %v", err)
+		} else {
+			install.SetCABundle(path)
+			log.Printf("Using custom CA bundle %s", path)
+		}
+	}
 
 	// Load registry from default location, creating new if doesn't exist
 	reg, err := registry.LoadDefault()
@@ -41,28 +103,84 @@ func run(ctx context.Context) error {
 		return fmt.Errorf("failed to load registry: %w", err)
 	}
 
+	// Move any legacy inline external credentials into the vault
+	cm, err := api.NewCredentialManager()
+	if err != nil {
+		log.Printf("Credential manager unavailable: %v", err)
+	} else if changed, err := cm.MigrateRegistry(reg); err != nil {
+		log.Printf("Failed to migrate legacy credentials: %v", err)
+	} else if changed {
+		if err := registry.SaveDefault(reg); err != nil {
+			log.Printf("Failed to save registry after credential migration: %v", err)
+		}
+	}
+
 	// Get logs directory for streaming and monitoring
 	logsDir, err := paths.LogsDir()
 	if err != nil {
 		return fmt.Errorf("failed to get logs directory: %w", err)
 	}
 
-	// Initialize enhanced supervisor with caps (128MB per server, 1GB global)
-	sup := supervisor.New(reg, 128*1024*1024, 1024*1024*1024)
+	// Memory caps are off unless configured
+	sup := supervisor.New(reg, int64(appSettings.Manager.MemoryCapMB)<<20, int64(appSettings.Manager.GlobalMemoryCapMB)<<20)
 
 	// Initialize health monitor
 	healthMonitor := health.NewHealthMonitor(30 * time.Second)
+	healthMonitor.SetCheckConcurrency(appSettings.Health.CheckConcurrency)
+	healthMonitor.SetThresholds(healthThresholds(appSettings.Health))
+	healthMonitor.SetHysteresis(healthHysteresis(appSettings.Health))
+	sup.SetHealthRestartGrace(time.Duration(appSettings.Health.RestartGraceSec) * time.Second)
+	sup.SetCommandAllowlist(appSettings.Manager.CommandAllowlist)
+	healthMonitor.SetCommandCheck(sup.CommandAllowed)
+	// The monitor is the only health check; the supervisor reports its results
+	healthMonitor.SetProcessSource(sup)
+	sup.SetHealthSource(healthMonitor)
+	if rootCAs != nil {
+		healthMonitor.SetRootCAs(rootCAs)
+	}
+	// Health status changes go out to the configured webhooks
+	notifier := health.NewWebhookNotifier(0)
+	notifier.SetWebhooks(webhooks(appSettings.Notifications))
+	if cm != nil {
+		notifier.SetSecretSource(cm)
+		healthMonitor.SetCredentialResolver(cm)
+		healthMonitor.SetCredentialRefresher(cm)
+		healthMonitor.SetSecretSource(cm)
+		sup.SetSecretSource(cm)
+		cm.SetValidationRateLimit(
+			appSettings.Credentials.ValidationMaxAttempts,
+			time.Duration(appSettings.Credentials.ValidationWindowSec)*time.Second,
+			appSettings.Credentials.ValidationPerClient,
+		)
+	}
 
-	// Set up health monitor callbacks for automatic process management
+	// Set up health monitor callbacks for automatic process management. They
+	// only fire once monitoring starts, after the API server below exists.
+	var srv *api.Server
 	healthMonitor.SetCallbacks(
 		func(processName string, oldStatus, newStatus health.Status) {
 			log.Printf("Health status changed for %s: %s -> %s", processName, oldStatus, newStatus)
+			ev := health.WebhookEvent{Process: processName, OldStatus: oldStatus, NewStatus: newStatus}
+			if ph, ok := healthMonitor.GetProcessHealth(processName); ok {
+				ev.ConsecutiveFails = ph.ConsecutiveFails
+			}
+			notifier.Notify(ev)
 		},
 		func(processName string, reason string) {
+			if exit := sup.LastExit(processName); exit != "" {
+				reason += "; last exit: " + exit
+			}
 			log.Printf("Process %s failed: %s", processName, reason)
-			// Attempt automatic restart for failed processes
-			if err := sup.Restart(processName); err != nil {
+			if srv.InMaintenance() {
+				log.Printf("Not restarting %s during maintenance", processName)
+				return
+			}
+			// Restart after the grace period unless the process recovers first
+			restarted, err := sup.RestartForHealth(processName, reason)
+			if err != nil {
 				log.Printf("Failed to restart process %s: %v", processName, err)
+			} else if !restarted {
+				log.Printf("Not restarting %s: it recovered, was stopped or already has a restart pending", processName)
 			}
 		},
 	)
@@ -94,10 +212,36 @@ func run(ctx context.Context) error {
 
 	// Initialize log streamer
 	logStreamer := logs.NewLogStreamer(logsDir)
+	applyStreamSettings(logStreamer, appSettings.Logs)
+	for _, s := range reg.Servers {
+		if pattern := s.Logs.RecordPattern(); pattern != "" {
+			if err := logStreamer.SetRecordStart(s.Slug, pattern); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
 
 	// Create HTTP API server with all components
-	cm, _ := api.NewCredentialManager()
-	srv := api.NewServer(reg).WithSupervisor(sup).WithHealthMonitor(healthMonitor).WithLogStreamer(logStreamer).WithCredentialManager(cm)
+	srv = api.NewServer(reg).WithSupervisor(sup).WithHealthMonitor(healthMonitor).WithLogStreamer(logStreamer).WithCredentialManager(cm).WithRootCAs(rootCAs)
+
+	// SIGHUP rereads the configuration. It reaches the supervisor's signal
+	// handler; the shutdown context above only listens for SIGINT and SIGTERM.
+	sup.SetReloadHandler(func() { reloadConfig(srv, sup, healthMonitor, notifier, logStreamer) })
+
+	// Optional gRPC control interface on its own loopback port
+	if appSettings.Control.Enabled {
+		addr := fmt.Sprintf("127.0.0.1:%d", appSettings.Control.Port)
+		if ln, err := net.Listen("tcp", addr); err != nil {
+			log.Printf("Warning: control interface disabled: %v", err)
+		} else {
+			log.Printf("Control interface listening on %s", addr)
+			go func() {
+				if err := srv.ServeControl(ctx, ln); err != nil {
+					log.Printf("Control interface error: %v", err)
+				}
+			}()
+		}
+	}
 
 	httpServer := &http.Server{
 		Addr:         "127.0.0.1:7099",
@@ -116,6 +260,7 @@ func run(ctx context.Context) error {
 	}()
 
 	// Start health monitoring
+	notifier.Start()
 	healthMonitor.Start()
 	log.Println("Health monitoring started")
 
@@ -123,34 +268,30 @@ func run(ctx context.Context) error {
 	logStreamer.Start()
 	log.Println("Log streaming started")
 
-	// Start autostart servers and add them to health monitoring
-	log.Println("Starting autostart servers...")
+	// Flag installed servers whose files have gone missing since install
 	for _, s := range reg.Servers {
-		if s.Auto != nil && s.Auto.Enabled {
-			if s.IsExternal() {
-				// External servers don't need to be "started" by supervisor
-				// but should be added to health monitoring
-				log.Printf("Registering external autostart server for monitoring: %s", s.Name)
-				ext := s.GetExternalConfig()
-				healthMonitor.AddExternalProcess(s.Slug, ext.Provider, ext.APIEndpoint, ext.AuthType)
-			} else {
-				// Local servers need to be started and monitored
-				log.Printf("Starting autostart server: %s", s.Name)
-				if err := sup.Start(s.Slug); err != nil {
-					log.Printf("Failed to start autostart server %s: %v", s.Name, err)
-				} else {
-					// Add to health monitoring
-					httpURL := ""
-					if s.Entry.Transport == "http" {
-						httpURL = deriveHTTPURL(s.Entry.Args, s.Entry.Env)
-					}
-					logPath := fmt.Sprintf("%s/%s.log", logsDir, s.Slug)
-					healthMonitor.AddProcess(s.Slug, s.Entry.Transport, httpURL, logPath)
-				}
+		if report := install.VerifyServer(s); report.NeedsRepair {
+			log.Printf("Warning: server %s needs repair: %s", s.Slug, strings.Join(report.Issues, "; "))
+		}
+	}
+
+	// Servers adopted from the previous daemon are already running; autostart
+	// ones are registered for monitoring again below
+	for _, slug := range sup.Adopted() {
+		for _, s := range reg.Servers {
+			if s.Slug == slug && (s.Auto == nil || !s.Auto.Enabled) {
+				logPath := fmt.Sprintf("%s/%s.log", logsDir, s.Slug)
+				healthMonitor.AddProcess(s.Slug, s.Entry.Transport, registry.DeriveHTTPURL(s.Entry.Args, s.Entry.Env), logPath)
+				healthMonitor.SetProbe(s.Slug, s.Health)
 			}
 		}
 	}
 
+	// Start autostart servers and add them to health monitoring. Local servers
+	// come up in parallel; readiness only waits for the sweep to be dispatched.
+	log.Println("Starting autostart servers...")
+	go startAutostartServers(ctx, srv, sup, healthMonitor, cm, append([]registry.Server(nil), reg.Servers...), logsDir)
+
 	// Initialize all external servers in registry for health monitoring
 	// even if they don't have autostart enabled
 	log.Println("Registering external servers for health monitoring...")
@@ -158,42 +299,31 @@ func run(ctx context.Context) error {
 		if s.IsExternal() && (s.Auto == nil || !s.Auto.Enabled) {
 			// Add external servers that aren't autostart enabled
 			log.Printf("Registering external server for monitoring: %s", s.Name)
-			ext := s.GetExternalConfig()
-			healthMonitor.AddExternalProcess(s.Slug, ext.Provider, ext.APIEndpoint, ext.AuthType)
+			api.MonitorExternal(healthMonitor, cm, &s)
 		}
 	}
 
 	// Start log rotation janitor
-	go func() {
-		ticker := time.NewTicker(2 * time.Minute)
-		defer ticker.Stop()
-		log.Println("Log rotation janitor started")
-
-		for {
-			select {
-			case <-ctx.Done():
-				log.Println("Log rotation janitor shutting down")
-				return
-			case <-ticker.C:
-				files, sizes, err := logs.ListLogFiles(logsDir)
-				if err != nil {
-					log.Printf("Failed to list log files: %v", err)
-					continue
-				}
+	go runLogJanitor(ctx, logsDir)
 
-				trim := logs.PlanRotation(sizes, 128*1024*1024, 1024*1024*1024)
-				if err := logs.ApplyRotation(files, trim); err != nil {
-					log.Printf("Failed to apply log rotation: %v", err)
-				}
-			}
-		}
-	}()
+	// SIGUSR2 exits for an upgrade, leaving HTTP servers running for the
+	// next daemon to adopt
+	upgradeCh := make(chan os.Signal, 1)
+	signal.Notify(upgradeCh, syscall.SIGUSR2)
+	defer signal.Stop(upgradeCh)
 
+	srv.MarkReady()
 	log.Println("Manager daemon fully started and ready")
 
 	// Wait for shutdown signal
-	<-ctx.Done()
-	log.Println("Shutdown signal received, beginning graceful shutdown...")
+	upgrade := false
+	select {
+	case <-ctx.Done():
+		log.Println("Shutdown signal received, beginning graceful shutdown...")
+	case <-upgradeCh:
+		upgrade = true
+		log.Println("Upgrade signal received, handing servers over to the next daemon...")
+	}
 
 	// Create shutdown context with generous timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -202,15 +332,23 @@ func run(ctx context.Context) error {
 	// Stop health monitoring
 	log.Println("Stopping health monitoring...")
 	healthMonitor.Stop()
+	notifier.Stop()
 
 	// Stop log streaming
 	log.Println("Stopping log streaming...")
 	logStreamer.Stop()
 
-	// Shutdown supervisor (stops all processes)
-	log.Println("Shutting down supervisor and all processes...")
-	if err := sup.Shutdown(20 * time.Second); err != nil {
-		log.Printf("Warning: supervisor shutdown error: %v", err)
+	// Shutdown supervisor (stops all processes, or only stdio ones on upgrade)
+	if upgrade {
+		log.Println("Detaching supervisor from running processes...")
+		if err := sup.Detach(20 * time.Second); err != nil {
+			log.Printf("Warning: failed to save process state, servers will not be adopted: %v", err)
+		}
+	} else {
+		log.Println("Shutting down supervisor and all processes...")
+		if err := sup.Shutdown(20 * time.Second); err != nil {
+			log.Printf("Warning: supervisor shutdown error: %v", err)
+		}
 	}
 
 	// Save registry before shutdown
@@ -229,31 +367,226 @@ func run(ctx context.Context) error {
 	return nil
 }
 
-// deriveHTTPURL tries to construct a local HTTP URL from args or env.
-// Priority: env[HEALTH_HTTP_URL], then --port=NNNN or -p NNNN in args → http://127.0.0.1:NNNN
-func deriveHTTPURL(args []string, env map[string]string) string {
-	if env != nil {
-		if u, ok := env["HEALTH_HTTP_URL"]; ok && u != "" {
-			return u
+// runLogJanitor trims logs in logsDir and prunes old install transcripts
+// until ctx is done. Caps, retention, the enabled flag and the interval are
+// re-read from settings on every pass, so changes made through /v1/settings
+// apply from the next tick.
+func runLogJanitor(ctx context.Context, logsDir string) {
+	log.Println("Log rotation janitor started")
+	for {
+		timer := time.NewTimer(janitorSettings().JanitorInterval())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Println("Log rotation janitor shutting down")
+			return
+		case <-timer.C:
+		}
+
+		cfg := janitorSettings()
+		if n, err := install.PruneTranscripts(cfg.InstallTranscriptRetention()); err != nil {
+			log.Printf("Failed to prune install transcripts: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d install transcripts", n)
+		}
+		if !cfg.RotationEnabled {
+			continue
+		}
+
+		files, sizes, err := logs.ListLogFiles(logsDir)
+		if err != nil {
+			log.Printf("Failed to list log files: %v", err)
+			continue
+		}
+
+		trim := logs.PlanRotation(sizes, cfg.MaxSizePerFile, cfg.MaxTotalSize)
+		if err := logs.ApplyRotation(files, trim); err != nil {
+			log.Printf("Failed to apply log rotation: %v", err)
 		}
 	}
+}
+
+// autostartConcurrency bounds how many autostart servers are launched at once.
+const autostartConcurrency = 4
+
+// startAutostartServers starts every autostart-enabled server and adds it to
+// health monitoring. External servers are only registered for monitoring.
+// Local servers are started by a bounded worker pool; a failure is logged and
+// does not hold up the others. Starts wait while srv is in maintenance mode.
+func startAutostartServers(ctx context.Context, srv *api.Server, sup *supervisor.Supervisor, healthMonitor *health.HealthMonitor, cm *api.CredentialManager, servers []registry.Server, logsDir string) {
+	// Dependencies go first so they hold the early slots; Start itself still
+	// waits for each to be ready before starting a dependent
+	if sorted, err := registry.SortByDependencies(servers); err != nil {
+		log.Printf("Warning: autostart ignores dependency order: %v", err)
+	} else {
+		servers = sorted
+	}
+
+	sem := make(chan struct{}, autostartConcurrency)
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+	local := 0
 
-	var port string
-	for i := 0; i < len(args); i++ {
-		a := args[i]
-		if len(a) > 7 && a[:7] == "--port=" {
-			port = a[7:]
-			break
+	for _, s := range servers {
+		if s.Auto == nil || !s.Auto.Enabled {
+			continue
 		}
-		if a == "-p" && i+1 < len(args) {
-			port = args[i+1]
-			break
+		if s.IsExternal() {
+			// External servers don't need to be "started" by supervisor
+			// but should be added to health monitoring
+			log.Printf("Registering external autostart server for monitoring: %s", s.Name)
+			api.MonitorExternal(healthMonitor, cm, &s)
+			continue
 		}
+
+		local++
+		wg.Add(1)
+		go func(s registry.Server) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := srv.WaitMaintenance(ctx); err != nil {
+				failed.Add(1)
+				return
+			}
+
+			log.Printf("Starting autostart server: %s", s.Name)
+			if err := sup.Start(s.Slug); err != nil {
+				failed.Add(1)
+				log.Printf("Failed to start autostart server %s: %v", s.Name, err)
+				return
+			}
+			httpURL := ""
+			if s.Entry.Transport == "http" {
+				httpURL = registry.DeriveHTTPURL(s.Entry.Args, s.Entry.Env)
+			}
+			logPath := fmt.Sprintf("%s/%s.log", logsDir, s.Slug)
+			healthMonitor.AddProcess(s.Slug, s.Entry.Transport, httpURL, logPath)
+			healthMonitor.SetProbe(s.Slug, s.Health)
+		}(s)
 	}
 
-	if port != "" {
-		return "http://127.0.0.1:" + port
+	wg.Wait()
+	log.Printf("Autostart finished: %d of %d local servers started", local-int(failed.Load()), local)
+}
+
+// healthHysteresis overlays the configured check counts on the defaults.
+func healthHysteresis(cfg settings.HealthSettings) health.Hysteresis {
+	hy := health.DefaultHysteresis()
+	if cfg.DegradeAfterChecks > 0 {
+		hy.DegradeAfter = cfg.DegradeAfterChecks
+	}
+	if cfg.RecoverAfterChecks > 0 {
+		hy.RecoverAfter = cfg.RecoverAfterChecks
 	}
+	return hy
+}
 
-	return ""
+// healthThresholds overlays the configured health thresholds on the defaults.
+func healthThresholds(cfg settings.HealthSettings) health.Thresholds {
+	th := health.DefaultThresholds()
+	if cfg.DegradedMissedPings > 0 {
+		th.DegradedMissedPings = cfg.DegradedMissedPings
+	}
+	if cfg.DownMissedPings > 0 {
+		th.DownMissedPings = cfg.DownMissedPings
+	}
+	if cfg.MaxPingMs > 0 {
+		th.MaxPingMs = cfg.MaxPingMs
+	}
+	if cfg.MaxRestarts10m > 0 {
+		th.MaxRestarts10m = cfg.MaxRestarts10m
+	}
+	// A raised degraded threshold must not leave the default down threshold below it
+	if th.DownMissedPings < th.DegradedMissedPings {
+		th.DownMissedPings = th.DegradedMissedPings
+	}
+	return th
+}
+
+// liveSettings are the settings a reload applies to the running daemon,
+// matched by dotted path or section prefix; the rest wait for a restart.
+var liveSettings = []string{
+	"health.degradedMissedPings", "health.downMissedPings", "health.maxPingMs", "health.maxRestarts10m", "health.restartGraceSec",
+	"health.degradeAfterChecks", "health.recoverAfterChecks",
+	"manager.autoRestartOnConfigChange", "manager.commandAllowlist", "manager.maxConcurrentInstalls",
+	"manager.memoryCapMB", "manager.globalMemoryCapMB",
+	"logs.", "notifications.",
+}
+
+// reloadConfig rereads the settings and the registry, as on SIGHUP, and
+// applies what can change while running. Settings go first, so a new
+// command allowlist or autoRestartOnConfigChange already covers the registry.
+func reloadConfig(srv *api.Server, sup *supervisor.Supervisor, healthMonitor *health.HealthMonitor, notifier *health.WebhookNotifier, logStreamer *logs.LogStreamer) {
+	log.Println("Reloading configuration")
+
+	previous, current, err := settings.Reload()
+	if err != nil {
+		log.Printf("Warning: keeping the current settings: %v", err)
+	} else if changed := settings.Changed(previous, current); len(changed) == 0 {
+		log.Println("Settings unchanged")
+	} else {
+		healthMonitor.SetThresholds(healthThresholds(current.Health))
+		healthMonitor.SetHysteresis(healthHysteresis(current.Health))
+		sup.SetHealthRestartGrace(time.Duration(current.Health.RestartGraceSec) * time.Second)
+		sup.SetCommandAllowlist(current.Manager.CommandAllowlist)
+		sup.SetMemoryCaps(int64(current.Manager.MemoryCapMB)<<20, int64(current.Manager.GlobalMemoryCapMB)<<20)
+		applyStreamSettings(logStreamer, current.Logs)
+		notifier.SetWebhooks(webhooks(current.Notifications))
+		log.Printf("Settings changed: %s", strings.Join(changed, ", "))
+
+		var later []string
+		for _, path := range changed {
+			live := false
+			for _, prefix := range liveSettings {
+				if path == prefix || (strings.HasSuffix(prefix, ".") && strings.HasPrefix(path, prefix)) {
+					live = true
+					break
+				}
+			}
+			if !live {
+				later = append(later, path)
+			}
+		}
+		if len(later) > 0 {
+			log.Printf("Settings applied on the next daemon start: %s", strings.Join(later, ", "))
+		}
+	}
+
+	restarted, err := srv.ReloadRegistry()
+	if err != nil {
+		log.Printf("Warning: keeping the current registry: %v", err)
+		return
+	}
+	if len(restarted) > 0 {
+		log.Printf("Restarted after a launch configuration change: %s", strings.Join(restarted, ", "))
+	}
+}
+
+// applyStreamSettings sets the buffer and overflow policy of log streams
+// opened from now on.
+func applyStreamSettings(logStreamer *logs.LogStreamer, cfg settings.LogSettings) {
+	if err := logStreamer.SetOverflow(cfg.StreamBuffer, logs.OverflowPolicy(cfg.StreamOverflow), time.Duration(cfg.StreamBlockMs)*time.Millisecond); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// webhooks returns the webhooks the notification settings list.
+func webhooks(cfg settings.NotificationSettings) []health.Webhook {
+	hooks := make([]health.Webhook, 0, len(cfg.Webhooks))
+	for _, w := range cfg.Webhooks {
+		hooks = append(hooks, health.Webhook{URL: w.URL, Secret: w.Secret})
+	}
+	return hooks
+}
+
+// janitorSettings returns the current log settings, falling back to defaults
+// if the settings file can't be read.
+func janitorSettings() settings.LogSettings {
+	s, err := settings.GetCached()
+	if err != nil {
+		log.Printf("Failed to load log settings, using defaults: %v", err)
+		return settings.NewDefault().Logs
+	}
+	return s.Logs
 }
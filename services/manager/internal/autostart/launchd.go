@@ -5,6 +5,8 @@ import (
     "os"
     "path/filepath"
     "os/exec"
+
+    "mcp/manager/internal/paths"
 )
 
 // PlistPath returns the user LaunchAgents plist path.
@@ -34,8 +36,8 @@ func PlistContents(execPath string) string {
 }
 
 func logPath(name string) string {
-    home, _ := os.UserHomeDir()
-    return filepath.Join(home, ".mcp", "logs", "manager-"+name+".log")
+    base, _ := paths.Root()
+    return filepath.Join(base, "logs", "manager-"+name+".log")
 }
 
 // Install writes the plist and attempts to load it via launchctl.
@@ -0,0 +1,96 @@
+// Package buildinfo reports the version of the running binary.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X mcp/manager/internal/buildinfo.version=1.2.0 \
+//	    -X mcp/manager/internal/buildinfo.commit=$(git rev-parse HEAD) \
+//	    -X mcp/manager/internal/buildinfo.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// Info describes a build of the daemon.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"goVersion"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+var get = sync.OnceValue(func() Info {
+	info := Info{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	// Without ldflags, use what the go tool stamped into the binary
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		var dirty bool
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				dirty = s.Value == "true"
+			}
+		}
+		if dirty && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+})
+
+// Get returns the build metadata of the running binary.
+func Get() Info { return get() }
+
+// Version returns the daemon's version, "dev" for an unstamped build.
+func Version() string { return get().Version }
+
+// String formats the version line logged at startup.
+func (i Info) String() string {
+	s := "mcp-manager " + i.Version
+	if i.Commit != "" {
+		c, dirty := strings.CutSuffix(i.Commit, "-dirty")
+		if len(c) > 12 {
+			c = c[:12]
+		}
+		if dirty {
+			c += "-dirty"
+		}
+		s += " (" + c
+		if i.Date != "" {
+			s += ", built " + i.Date
+		}
+		s += ")"
+	}
+	return s + fmt.Sprintf(" %s %s/%s", i.GoVersion, i.OS, i.Arch)
+}
@@ -0,0 +1,24 @@
+package buildinfo
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version == "" || info.GoVersion == "" || info.OS == "" || info.Arch == "" {
+		t.Fatalf("incomplete build info: %+v", info)
+	}
+	if Version() != info.Version {
+		t.Fatalf("Version() = %q, want %q", Version(), info.Version)
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	info := Info{Version: "1.2.0", Commit: "0123456789abcdef", Date: "2026-01-02T03:04:05Z", GoVersion: "go1.22.5", OS: "linux", Arch: "amd64"}
+	want := "mcp-manager 1.2.0 (0123456789ab, built 2026-01-02T03:04:05Z) go1.22.5 linux/amd64"
+	if got := info.String(); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	if got := (Info{Version: "dev", GoVersion: "go1.22.5", OS: "darwin", Arch: "arm64"}).String(); got != "mcp-manager dev go1.22.5 darwin/arm64" {
+		t.Fatalf("unstamped String() = %q", got)
+	}
+}
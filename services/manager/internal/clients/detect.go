@@ -26,6 +26,8 @@ func DetectKnown(p Paths) []Detection {
     list := []struct{ name, cmd, cfg string }{
         {"Claude Desktop", "", p.ClaudeDesktop},
         {"Cursor (Global)", "cursor", p.CursorGlobal},
+        {"VS Code", "code", p.VSCode},
+        {"Windsurf", "windsurf", p.Windsurf},
         {"Continue", "continue", ""},
         {"Cursor CLI", "cursor", ""},
         {"Claude Code", "claude", ""},
@@ -72,8 +74,13 @@ func scanMCPServers(configPath string, clientName string) []MCPServer {
     }
     
     // Handle different config formats
-    // Claude Desktop format: mcpServers object
-    if mcpServers, ok := config["mcpServers"].(map[string]interface{}); ok {
+    // Claude Desktop, Cursor and Windsurf: mcpServers object; VS Code: servers
+    // object in mcp.json
+    mcpServers, ok := config["mcpServers"].(map[string]interface{})
+    if !ok {
+        mcpServers, ok = config["servers"].(map[string]interface{})
+    }
+    if ok {
         for name, serverData := range mcpServers {
             if server, ok := serverData.(map[string]interface{}); ok {
                 mcp := MCPServer{
@@ -0,0 +1,98 @@
+package clients
+
+import (
+    "fmt"
+    "strings"
+)
+
+// diffContext is how many unchanged lines surround each hunk.
+const diffContext = 3
+
+type diffLine struct {
+    kind byte // ' ', '-' or '+'
+    text string
+}
+
+// unifiedDiff returns the changes from a to b as a unified diff labelled with
+// name, or "" when they are the same. A missing final newline is ignored.
+func unifiedDiff(name, a, b string) string {
+    lines := diffLines(splitLines(a), splitLines(b))
+
+    // Where each line falls in a and b, for the hunk headers
+    aPos, bPos := make([]int, len(lines)+1), make([]int, len(lines)+1)
+    for i, l := range lines {
+        aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+        if l.kind != '+' { aPos[i+1]++ }
+        if l.kind != '-' { bPos[i+1]++ }
+    }
+
+    var out strings.Builder
+    for i := 0; i < len(lines); {
+        if lines[i].kind == ' ' {
+            i++
+            continue
+        }
+        // Grow the hunk while the next change is close enough to share context
+        end := i + 1
+        for j := end; j < len(lines) && j-end < 2*diffContext; j++ {
+            if lines[j].kind != ' ' { end = j + 1 }
+        }
+        start, stop := max(i-diffContext, 0), min(end+diffContext, len(lines))
+
+        if out.Len() == 0 { fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name) }
+        fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aPos[start], aPos[stop]), hunkRange(bPos[start], bPos[stop]))
+        for _, l := range lines[start:stop] {
+            out.WriteByte(l.kind)
+            out.WriteString(l.text)
+            out.WriteByte('\n')
+        }
+        i = stop
+    }
+    return out.String()
+}
+
+// hunkRange formats the lines from (exclusive) to to (inclusive) as
+// "start,count"; an empty range names the line before it.
+func hunkRange(from, to int) string {
+    if to == from { return fmt.Sprintf("%d,0", from) }
+    return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+func splitLines(s string) []string {
+    s = strings.TrimSuffix(s, "\n")
+    if s == "" { return nil }
+    return strings.Split(s, "\n")
+}
+
+// diffLines lines a and b up along their longest common subsequence. Client
+// configs are small, so the quadratic table is fine.
+func diffLines(a, b []string) []diffLine {
+    lcs := make([][]int, len(a)+1)
+    for i := range lcs { lcs[i] = make([]int, len(b)+1) }
+    for i := len(a) - 1; i >= 0; i-- {
+        for j := len(b) - 1; j >= 0; j-- {
+            if a[i] == b[j] {
+                lcs[i][j] = lcs[i+1][j+1] + 1
+            } else {
+                lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+            }
+        }
+    }
+
+    var out []diffLine
+    i, j := 0, 0
+    for i < len(a) || j < len(b) {
+        switch {
+        case i < len(a) && j < len(b) && a[i] == b[j]:
+            out = append(out, diffLine{' ', a[i]})
+            i, j = i+1, j+1
+        case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+            out = append(out, diffLine{'-', a[i]})
+            i++
+        default:
+            out = append(out, diffLine{'+', b[j]})
+            j++
+        }
+    }
+    return out
+}
@@ -3,21 +3,31 @@ package clients
 import (
     "os"
     "path/filepath"
+
+    "mcp/manager/internal/paths"
 )
 
 type Paths struct {
     ClaudeDesktop string
     CursorGlobal  string
+    VSCode        string // user profile's mcp.json
+    Windsurf      string
     Store         string // ~/.mcp/clients/config.json
 }
 
 func DefaultPaths() (Paths, error) {
     home, err := os.UserHomeDir()
     if err != nil { return Paths{}, err }
+    config, err := os.UserConfigDir()
+    if err != nil { return Paths{}, err }
+    base, err := paths.Root()
+    if err != nil { return Paths{}, err }
     return Paths{
         ClaudeDesktop: filepath.Join(home, "Library", "Application Support", "Claude", "claude_desktop_config.json"),
         CursorGlobal:  filepath.Join(home, ".cursor", "mcp.json"),
-        Store:         filepath.Join(home, ".mcp", "clients", "config.json"),
+        VSCode:        filepath.Join(config, "Code", "User", "mcp.json"),
+        Windsurf:      filepath.Join(home, ".codeium", "windsurf", "mcp_config.json"),
+        Store:         filepath.Join(base, "clients", "config.json"),
     }, nil
 }
 
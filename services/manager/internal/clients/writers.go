@@ -1,22 +1,280 @@
 package clients
 
 import (
+    "bytes"
     "encoding/json"
+    "errors"
+    "fmt"
     "os"
     "path/filepath"
+    "sort"
 )
 
+// ManagedKey marks the mcpServers entries this manager wrote, with the value
+// ManagedBy. Entries without it belong to the user or another tool.
+const (
+    ManagedKey = "_managedBy"
+    ManagedBy  = "mcp-manager"
+)
+
+// Plan is what applying a config to a client file would change. Diff is a
+// unified diff from the current contents to the merged ones, empty when
+// nothing changes. Skipped names the servers left alone because the manager
+// doesn't own their entries.
+type Plan struct {
+    Path    string   `json:"path"`
+    Changed bool     `json:"changed"`
+    Diff    string   `json:"diff"`
+    Skipped []string `json:"skipped,omitempty"`
+
+    contents []byte
+}
+
+// format is how a client lays out its MCP config. The config handed to the
+// writers is always in the Claude Desktop shape, {"mcpServers": {name: entry}};
+// serversAt is where the client keeps that map, and entry, when set, rewrites
+// each entry into the client's spelling.
+type format struct {
+    serversAt []string
+    entry     func(fields map[string]any)
+}
+
+var (
+    mcpServersFormat = format{serversAt: []string{"mcpServers"}}
+    // VS Code's mcp.json files, in a workspace's .vscode directory or the user profile
+    vscodeFormat   = format{serversAt: []string{"servers"}, entry: vscodeEntry}
+    windsurfFormat = format{serversAt: []string{"mcpServers"}, entry: windsurfEntry}
+)
+
+// vscodeEntry adds the transport type VS Code requires on every server.
+func vscodeEntry(fields map[string]any) {
+    if _, ok := fields["type"]; ok { return }
+    if _, ok := fields["url"]; ok {
+        fields["type"] = "http"
+    } else {
+        fields["type"] = "stdio"
+    }
+}
+
+// windsurfEntry renames url to the serverUrl Windsurf reads remote servers from.
+func windsurfEntry(fields map[string]any) {
+    if url, ok := fields["url"]; ok {
+        if _, set := fields["serverUrl"]; !set { fields["serverUrl"] = url }
+        delete(fields, "url")
+    }
+}
+
 // WriteClaudeDesktop merges and writes configuration for Claude Desktop.
 func WriteClaudeDesktop(path string, servers any) error {
-    return writeJSON(path, servers)
+    return writeJSON(path, servers, mcpServersFormat)
 }
 
-// WriteCursorGlobal writes global Cursor MCP config.
-func WriteCursorGlobal(path string, servers any) error { return writeJSON(path, servers) }
+// WriteCursorGlobal merges and writes global Cursor MCP config.
+func WriteCursorGlobal(path string, servers any) error { return writeJSON(path, servers, mcpServersFormat) }
+
+// WriteVSCode merges and writes a VS Code mcp.json, the user profile's or a
+// workspace's .vscode/mcp.json.
+func WriteVSCode(path string, servers any) error { return writeJSON(path, servers, vscodeFormat) }
+
+// WriteWindsurf merges and writes Windsurf's mcp_config.json.
+func WriteWindsurf(path string, servers any) error { return writeJSON(path, servers, windsurfFormat) }
+
+// PlanClaudeDesktop returns what WriteClaudeDesktop would change, without
+// touching disk.
+func PlanClaudeDesktop(path string, servers any) (*Plan, error) { return planJSON(path, servers, mcpServersFormat) }
+
+// PlanCursorGlobal returns what WriteCursorGlobal would change, without
+// touching disk.
+func PlanCursorGlobal(path string, servers any) (*Plan, error) { return planJSON(path, servers, mcpServersFormat) }
+
+// PlanVSCode returns what WriteVSCode would change, without touching disk.
+func PlanVSCode(path string, servers any) (*Plan, error) { return planJSON(path, servers, vscodeFormat) }
 
-func writeJSON(path string, v any) error {
+// PlanWindsurf returns what WriteWindsurf would change, without touching disk.
+func PlanWindsurf(path string, servers any) (*Plan, error) { return planJSON(path, servers, windsurfFormat) }
+
+func writeJSON(path string, v any, f format) error {
+    plan, err := planJSON(path, v, f)
+    if err != nil { return err }
+    if !plan.Changed { return nil }
     if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return err }
-    b, _ := json.MarshalIndent(v, "", "  ")
-    return os.WriteFile(path, b, 0o644)
+    return os.WriteFile(path, plan.contents, 0o644)
+}
+
+// planJSON merges v into the config at path, see mergeConfig, and diffs the
+// result against the file. Keys keep the order the file has them in, so the
+// diff shows only what the merge changed.
+func planJSON(path string, v any, f format) (*Plan, error) {
+    current, err := os.ReadFile(path)
+    if err != nil && !errors.Is(err, os.ErrNotExist) { return nil, err }
+
+    existing := map[string]any{}
+    if len(bytes.TrimSpace(current)) > 0 {
+        if err := json.Unmarshal(current, &existing); err != nil {
+            return nil, fmt.Errorf("%s is not a JSON object, not overwriting it: %w", path, err)
+        }
+    }
+    b, err := json.Marshal(v)
+    if err != nil { return nil, err }
+    update := map[string]any{}
+    if err := json.Unmarshal(b, &update); err != nil {
+        return nil, fmt.Errorf("config must be a JSON object: %w", err)
+    }
+
+    config, skipped := mergeConfig(existing, update, f)
+    var buf bytes.Buffer
+    writeOrdered(&buf, config, current, "")
+    buf.WriteByte('\n')
+    merged := buf.Bytes()
+    diff := unifiedDiff(path, string(current), string(merged))
+    return &Plan{Path: path, Changed: diff != "", Diff: diff, Skipped: skipped, contents: merged}, nil
+}
+
+// writeOrdered writes v indented like json.MarshalIndent, except that the
+// keys of each object come in the order orig, the JSON v was decoded from,
+// has them, followed by the keys orig lacks, sorted.
+func writeOrdered(buf *bytes.Buffer, v any, orig []byte, indent string) {
+    inner := indent + "  "
+    switch v := v.(type) {
+    case map[string]any:
+        if len(v) == 0 { buf.WriteString("{}"); return }
+        keys, raws := objectFields(orig)
+        var added []string
+        for key := range v {
+            if _, found := raws[key]; !found { added = append(added, key) }
+        }
+        sort.Strings(added)
+        buf.WriteByte('{')
+        n := 0
+        for _, key := range append(keys, added...) {
+            value, ok := v[key]
+            if !ok { continue }
+            if n > 0 { buf.WriteByte(',') }
+            n++
+            name, _ := json.Marshal(key)
+            buf.WriteString("\n" + inner)
+            buf.Write(name)
+            buf.WriteString(": ")
+            writeOrdered(buf, value, raws[key], inner)
+        }
+        buf.WriteString("\n" + indent + "}")
+    case []any:
+        if len(v) == 0 { buf.WriteString("[]"); return }
+        var raws []json.RawMessage
+        _ = json.Unmarshal(orig, &raws)
+        buf.WriteByte('[')
+        for i, value := range v {
+            if i > 0 { buf.WriteByte(',') }
+            var raw []byte
+            if i < len(raws) { raw = raws[i] }
+            buf.WriteString("\n" + inner)
+            writeOrdered(buf, value, raw, inner)
+        }
+        buf.WriteString("\n" + indent + "]")
+    default:
+        b, _ := json.Marshal(v)
+        buf.Write(b)
+    }
+}
+
+// objectFields returns the keys of the JSON object data in the order they
+// appear, each once, and their raw values. Anything but an object has none.
+func objectFields(data []byte) ([]string, map[string]json.RawMessage) {
+    raws := map[string]json.RawMessage{}
+    dec := json.NewDecoder(bytes.NewReader(data))
+    if tok, err := dec.Token(); err != nil || tok != json.Delim('{') { return nil, raws }
+    var keys []string
+    for dec.More() {
+        tok, err := dec.Token()
+        if err != nil { break }
+        key, _ := tok.(string)
+        var raw json.RawMessage
+        if err := dec.Decode(&raw); err != nil { break }
+        if _, seen := raws[key]; !seen { keys = append(keys, key) }
+        raws[key] = raw
+    }
+    return keys, raws
 }
 
+// mergeConfig applies update to existing. Top-level keys of update replace
+// those of existing, except mcpServers, which is merged by server name into
+// the map at f.serversAt: each entry replaces the one of that name and is
+// marked with ManagedKey, and a null entry removes it. Entries the manager
+// doesn't own are never touched; their names are returned instead. An
+// unmarked entry that runs the same command and arguments, or the same URL,
+// as its update is taken over, since that is what the manager wrote before
+// it marked its entries.
+func mergeConfig(existing, update map[string]any, f format) (map[string]any, []string) {
+    var skipped []string
+    for key, value := range update {
+        servers, isObject := value.(map[string]any)
+        if key != "mcpServers" || !isObject {
+            existing[key] = value
+            continue
+        }
+        old := objectAt(existing, f.serversAt)
+        for name, entry := range servers {
+            fields, ok := entry.(map[string]any)
+            if ok && f.entry != nil { f.entry(fields) }
+            if current, found := old[name]; found && !isManaged(current) && !sameTarget(current, fields) {
+                skipped = append(skipped, name)
+                continue
+            }
+            if entry == nil {
+                delete(old, name)
+                continue
+            }
+            if ok { fields[ManagedKey] = ManagedBy }
+            old[name] = entry
+        }
+    }
+    sort.Strings(skipped)
+    return existing, skipped
+}
+
+// objectAt returns the object at path in config, creating it and the objects
+// on the way as needed.
+func objectAt(config map[string]any, path []string) map[string]any {
+    for _, key := range path {
+        next, ok := config[key].(map[string]any)
+        if !ok {
+            next = map[string]any{}
+            config[key] = next
+        }
+        config = next
+    }
+    return config
+}
+
+// isManaged reports whether an mcpServers entry was written by the manager.
+func isManaged(entry any) bool {
+    fields, ok := entry.(map[string]any)
+    return ok && fields[ManagedKey] == ManagedBy
+}
+
+// sameTarget reports whether entry runs the same command with the same
+// arguments, or reaches the same URL, as the fields the manager is about to
+// write for it. A command alone says little: many servers run through npx
+// or docker.
+func sameTarget(entry any, fields map[string]any) bool {
+    current, ok := entry.(map[string]any)
+    if !ok || fields == nil { return false }
+    if want, ok := fields["command"].(string); ok && want != "" {
+        return current["command"] == want && sameArgs(current["args"], fields["args"])
+    }
+    for _, key := range []string{"url", "serverUrl"} {
+        if want, ok := fields[key].(string); ok && want != "" && current[key] == want { return true }
+    }
+    return false
+}
+
+// sameArgs compares two decoded args lists, a missing list counting as empty.
+func sameArgs(a, b any) bool {
+    x, _ := a.([]any)
+    y, _ := b.([]any)
+    if len(x) != len(y) { return false }
+    for i := range x {
+        if x[i] != y[i] { return false }
+    }
+    return true
+}
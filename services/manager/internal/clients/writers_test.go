@@ -0,0 +1,238 @@
+package clients
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestPlanMergesServers(t *testing.T) {
+    p := filepath.Join(t.TempDir(), "claude_desktop_config.json")
+    original := `{
+  "globalShortcut": "Ctrl+Space",
+  "mcpServers": {
+    "mine": {"command": "/usr/local/bin/mine"},
+    "old": {"command": "old", "_managedBy": "mcp-manager"}
+  }
+}
+`
+    if err := os.WriteFile(p, []byte(original), 0o644); err != nil { t.Fatal(err) }
+
+    update := map[string]any{"mcpServers": map[string]any{
+        "fs":  map[string]any{"command": "/home/me/.mcp/servers/fs/bin/fs"},
+        "old": nil,
+    }}
+    plan, err := PlanClaudeDesktop(p, update)
+    if err != nil { t.Fatal(err) }
+    if !plan.Changed || !strings.HasPrefix(plan.Diff, "--- "+p+"\n+++ "+p+"\n@@ ") {
+        t.Fatalf("plan = %+v", plan)
+    }
+    for _, want := range []string{`+    "fs": {`, `-    "old": {`} {
+        if !strings.Contains(plan.Diff, want) { t.Errorf("diff lacks %q:\n%s", want, plan.Diff) }
+    }
+    if b, _ := os.ReadFile(p); string(b) != original { t.Fatal("planning touched the file") }
+
+    if err := WriteClaudeDesktop(p, update); err != nil { t.Fatal(err) }
+    b, _ := os.ReadFile(p)
+    if !strings.Contains(string(b), `"_managedBy": "mcp-manager"`) { t.Fatalf("fs not marked as managed: %s", b) }
+    var written struct {
+        GlobalShortcut string                    `json:"globalShortcut"`
+        MCPServers     map[string]map[string]any `json:"mcpServers"`
+    }
+    if err := json.Unmarshal(b, &written); err != nil { t.Fatal(err) }
+    if written.GlobalShortcut != "Ctrl+Space" || written.MCPServers["mine"] == nil || written.MCPServers["fs"] == nil || written.MCPServers["old"] != nil {
+        t.Fatalf("written = %s", b)
+    }
+
+    // Applying the same config again changes nothing
+    if plan, err := PlanClaudeDesktop(p, update); err != nil || plan.Changed || plan.Diff != "" {
+        t.Fatalf("second plan = %+v, %v", plan, err)
+    }
+}
+
+func TestPlanKeepsKeyOrder(t *testing.T) {
+    p := filepath.Join(t.TempDir(), "claude_desktop_config.json")
+    original := `{
+  "mcpServers": {
+    "zeta": {
+      "command": "zeta",
+      "args": [
+        "--port",
+        "1"
+      ]
+    },
+    "alpha": {
+      "url": "https://alpha.example"
+    }
+  },
+  "globalShortcut": "Ctrl+Space"
+}
+`
+    if err := os.WriteFile(p, []byte(original), 0o644); err != nil { t.Fatal(err) }
+
+    plan, err := PlanClaudeDesktop(p, map[string]any{"mcpServers": map[string]any{"fs": map[string]any{"command": "fs"}}})
+    if err != nil { t.Fatal(err) }
+    var removed, added []string
+    for _, line := range strings.Split(plan.Diff, "\n")[2:] {
+        if strings.HasPrefix(line, "-") { removed = append(removed, line) }
+        if strings.HasPrefix(line, "+") { added = append(added, line) }
+    }
+    if len(removed) != 0 || len(added) != 4 || added[1] != `+    "fs": {` {
+        t.Fatalf("diff touches more than the new server:\n%s", plan.Diff)
+    }
+}
+
+func TestPlanRefusesInvalidFile(t *testing.T) {
+    p := filepath.Join(t.TempDir(), "mcp.json")
+    if err := os.WriteFile(p, []byte(`{"mcpServers": {`), 0o644); err != nil { t.Fatal(err) }
+    if err := WriteCursorGlobal(p, map[string]any{"mcpServers": map[string]any{}}); err == nil {
+        t.Fatal("overwrote a file that isn't valid JSON")
+    }
+}
+
+func TestUnifiedDiff(t *testing.T) {
+    a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+    b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n"
+    want := `--- f
++++ f
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -14,3 +14,4 @@
+ 14
+ 15
+ 16
++17
+`
+    if got := unifiedDiff("f", a, b); got != want { t.Fatalf("got\n%s\nwant\n%s", got, want) }
+    if got := unifiedDiff("f", "", "x\n"); got != "--- f\n+++ f\n@@ -0,0 +1,1 @@\n+x\n" { t.Fatalf("from empty: %q", got) }
+    if got := unifiedDiff("f", a, strings.TrimSuffix(a, "\n")); got != "" { t.Fatalf("final newline only: %q", got) }
+}
+
+func TestApplyKeepsThirdPartyServers(t *testing.T) {
+    p := filepath.Join(t.TempDir(), "mcp.json")
+    original := `{"mcpServers": {"github": {"command": "docker", "args": ["run", "ghcr.io/github/github-mcp-server"]}}, "theme": "dark"}`
+    if err := os.WriteFile(p, []byte(original), 0o644); err != nil { t.Fatal(err) }
+
+    // Neither a same-named entry nor a removal touches a server the manager didn't write
+    for _, github := range []any{map[string]any{"command": "/home/me/.mcp/servers/github/bin/github"}, nil} {
+        update := map[string]any{"mcpServers": map[string]any{"github": github, "fs": map[string]any{"command": "fs"}}}
+        plan, err := PlanCursorGlobal(p, update)
+        if err != nil { t.Fatal(err) }
+        if len(plan.Skipped) != 1 || plan.Skipped[0] != "github" { t.Fatalf("skipped = %v", plan.Skipped) }
+        if err := WriteCursorGlobal(p, update); err != nil { t.Fatal(err) }
+    }
+
+    b, _ := os.ReadFile(p)
+    var written map[string]any
+    if err := json.Unmarshal(b, &written); err != nil { t.Fatal(err) }
+    servers := written["mcpServers"].(map[string]any)
+    github, _ := servers["github"].(map[string]any)
+    if github["command"] != "docker" || isManaged(github) || !isManaged(servers["fs"]) || written["theme"] != "dark" {
+        t.Fatalf("written = %s", b)
+    }
+}
+
+func TestApplyAdoptsUnmarkedManagerEntries(t *testing.T) {
+    p := filepath.Join(t.TempDir(), "mcp_config.json")
+    // Written before the manager marked its entries
+    original := `{"mcpServers": {"fs": {"command": "/home/me/.mcp/servers/fs/bin/fs"}, "remote": {"serverUrl": "https://mcp.example.com/sse"}}}`
+    if err := os.WriteFile(p, []byte(original), 0o644); err != nil { t.Fatal(err) }
+
+    update := map[string]any{"mcpServers": map[string]any{
+        "fs":     map[string]any{"command": "/home/me/.mcp/servers/fs/bin/fs", "env": map[string]any{"ROOT": "/tmp"}},
+        "remote": map[string]any{"url": "https://mcp.example.com/sse"},
+    }}
+    plan, err := PlanWindsurf(p, update)
+    if err != nil { t.Fatal(err) }
+    if len(plan.Skipped) != 0 { t.Fatalf("skipped = %v", plan.Skipped) }
+    if err := WriteWindsurf(p, update); err != nil { t.Fatal(err) }
+
+    b, _ := os.ReadFile(p)
+    var written map[string]any
+    if err := json.Unmarshal(b, &written); err != nil { t.Fatal(err) }
+    servers := written["mcpServers"].(map[string]any)
+    fs, _ := servers["fs"].(map[string]any)
+    if !isManaged(fs) || fs["env"] == nil || !isManaged(servers["remote"]) {
+        t.Fatalf("written = %s", b)
+    }
+}
+
+func TestClientFormats(t *testing.T) {
+    dir := t.TempDir()
+    update := map[string]any{"mcpServers": map[string]any{
+        "fs":     map[string]any{"command": "fs", "args": []any{"--root", "/tmp"}},
+        "remote": map[string]any{"url": "https://mcp.example.com/sse"},
+    }}
+    read := func(p string) map[string]any {
+        t.Helper()
+        b, err := os.ReadFile(p)
+        if err != nil { t.Fatal(err) }
+        var out map[string]any
+        if err := json.Unmarshal(b, &out); err != nil { t.Fatal(err) }
+        return out
+    }
+
+    // VS Code's mcp.json keeps servers at the top level, next to its inputs
+    profile := filepath.Join(dir, "Code", "User", "mcp.json")
+    os.MkdirAll(filepath.Dir(profile), 0o755)
+    if err := os.WriteFile(profile, []byte(`{"inputs": [], "servers": {"mine": {"type": "stdio", "command": "mine"}}}`), 0o644); err != nil { t.Fatal(err) }
+    if err := WriteVSCode(profile, update); err != nil { t.Fatal(err) }
+    got := read(profile)
+    servers := got["servers"].(map[string]any)
+    if got["inputs"] == nil || servers["mine"] == nil || got["mcpServers"] != nil {
+        t.Fatalf("mcp.json = %v", got)
+    }
+    if fs := servers["fs"].(map[string]any); fs["type"] != "stdio" || !isManaged(fs) {
+        t.Fatalf("fs = %v", fs)
+    }
+    if remote := servers["remote"].(map[string]any); remote["type"] != "http" || remote["url"] == nil {
+        t.Fatalf("remote = %v", remote)
+    }
+
+    // So does a workspace's .vscode/mcp.json
+    workspace := filepath.Join(dir, "project", ".vscode", "mcp.json")
+    if err := WriteVSCode(workspace, update); err != nil { t.Fatal(err) }
+    if servers, ok := read(workspace)["servers"].(map[string]any); !ok || servers["fs"] == nil {
+        t.Fatalf("workspace mcp.json = %v", read(workspace))
+    }
+
+    // Windsurf reads remote servers from serverUrl
+    windsurf := filepath.Join(dir, ".codeium", "windsurf", "mcp_config.json")
+    if err := WriteWindsurf(windsurf, update); err != nil { t.Fatal(err) }
+    remote := read(windsurf)["mcpServers"].(map[string]any)["remote"].(map[string]any)
+    if remote["serverUrl"] != "https://mcp.example.com/sse" || remote["url"] != nil {
+        t.Fatalf("windsurf remote = %v", remote)
+    }
+
+    // Detection finds the servers in each layout
+    for p, client := range map[string]string{profile: "VS Code", workspace: "VS Code", windsurf: "Windsurf"} {
+        names := map[string]bool{}
+        for _, mcp := range scanMCPServers(p, client) { names[mcp.Name] = true }
+        if !names["fs"] || !names["remote"] { t.Errorf("%s: found %v", p, names) }
+    }
+}
+
+func TestApplySkipsUnmarkedEntriesWithOtherArgs(t *testing.T) {
+    p := filepath.Join(t.TempDir(), "mcp_config.json")
+    // The user's own server that also happens to run through npx
+    original := `{"mcpServers": {"fs": {"command": "npx", "args": ["-y", "my-fs-server"]}}}`
+    if err := os.WriteFile(p, []byte(original), 0o644); err != nil { t.Fatal(err) }
+
+    update := map[string]any{"mcpServers": map[string]any{
+        "fs": map[string]any{"command": "npx", "args": []any{"-y", "@modelcontextprotocol/server-filesystem"}},
+    }}
+    plan, err := PlanWindsurf(p, update)
+    if err != nil { t.Fatal(err) }
+    if len(plan.Skipped) != 1 || plan.Skipped[0] != "fs" { t.Fatalf("skipped = %v", plan.Skipped) }
+    if err := WriteWindsurf(p, update); err != nil { t.Fatal(err) }
+    b, _ := os.ReadFile(p)
+    if !strings.Contains(string(b), "my-fs-server") || strings.Contains(string(b), ManagedKey) { t.Fatalf("written = %s", b) }
+}
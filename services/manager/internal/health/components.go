@@ -0,0 +1,196 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthComponent is one part of a provider probed on its own endpoint,
+// such as a single API route behind the provider's main health endpoint.
+type HealthComponent struct {
+	Name      string
+	Endpoint  string
+	SlowAfter time.Duration // responses slower than this degrade the component; 0 never does
+}
+
+// ComponentProbe lists what an external check looks at besides the main
+// endpoint: sub-endpoints and a Statuspage-style status page.
+type ComponentProbe struct {
+	Components []HealthComponent
+	StatusPage string   // summary.json URL of a Statuspage-compatible page
+	Follow     []string // status page components that matter; empty follows all
+}
+
+func (p ComponentProbe) empty() bool {
+	return len(p.Components) == 0 && p.StatusPage == ""
+}
+
+// ComponentResult is the outcome of probing one component.
+type ComponentResult struct {
+	Name         string `json:"name"`
+	Source       string `json:"source"` // "endpoint" or "statusPage"
+	Status       Status `json:"status"`
+	Detail       string `json:"detail,omitempty"` // e.g. "slow", "partial outage"
+	StatusCode   int    `json:"statusCode,omitempty"`
+	ResponseTime int64  `json:"responseTime,omitempty"` // milliseconds
+}
+
+// maxStatusPageRead bounds how much of a status page summary is read.
+const maxStatusPageRead = 1 << 20
+
+// probeComponents checks every component of p concurrently. Sub-endpoints
+// get the same credentials and extras as the main check.
+func (e *ExternalHealthChecker) probeComponents(ctx context.Context, p ComponentProbe, credentials map[string]string, extra HealthRequest) []ComponentResult {
+	results := make([]ComponentResult, len(p.Components))
+	var page []ComponentResult
+	var wg sync.WaitGroup
+	for i, c := range p.Components {
+		wg.Add(1)
+		go func(i int, c HealthComponent) {
+			defer wg.Done()
+			results[i] = e.probeEndpoint(ctx, c, credentials, extra)
+		}(i, c)
+	}
+	if p.StatusPage != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page = e.readStatusPage(ctx, p.StatusPage, p.Follow)
+		}()
+	}
+	wg.Wait()
+	return append(results, page...)
+}
+
+func (e *ExternalHealthChecker) probeEndpoint(ctx context.Context, c HealthComponent, credentials map[string]string, extra HealthRequest) ComponentResult {
+	result := ComponentResult{Name: c.Name, Source: "endpoint"}
+	h, err := e.CheckHealthRequest(ctx, c.Endpoint, credentials, extra, nil)
+	if err != nil {
+		result.Status, result.Detail = Down, err.Error()
+		return result
+	}
+	result.StatusCode = h.StatusCode
+	result.ResponseTime = h.ResponseTime
+	switch h.Status {
+	case "healthy":
+		result.Status = Ready
+		if c.SlowAfter > 0 && time.Duration(h.ResponseTime)*time.Millisecond > c.SlowAfter {
+			result.Status, result.Detail = Degraded, "slow"
+		}
+	case "warning":
+		result.Status, result.Detail = Degraded, h.Error
+	default:
+		result.Status, result.Detail = Down, h.Error
+	}
+	return result
+}
+
+// statusPageSummary is the part of a Statuspage /api/v2/summary.json we read.
+type statusPageSummary struct {
+	Components []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+		Group  bool   `json:"group"`
+	} `json:"components"`
+}
+
+// readStatusPage returns the followed components of the status page at url.
+// A page that can't be read yields a single result with an empty status, so
+// an outage of the status page itself never degrades the provider.
+func (e *ExternalHealthChecker) readStatusPage(ctx context.Context, url string, follow []string) []ComponentResult {
+	unavailable := func(detail string) []ComponentResult {
+		return []ComponentResult{{Name: "status page", Source: "statusPage", Detail: detail}}
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return unavailable(err.Error())
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return unavailable(fmt.Sprintf("request failed: %v", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return unavailable(fmt.Sprintf("status page returned %d", resp.StatusCode))
+	}
+	var summary statusPageSummary
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxStatusPageRead)).Decode(&summary); err != nil {
+		return unavailable(fmt.Sprintf("unreadable status page: %v", err))
+	}
+
+	var results []ComponentResult
+	for _, c := range summary.Components {
+		if c.Group || !following(follow, c.Name) {
+			continue
+		}
+		result := ComponentResult{Name: c.Name, Source: "statusPage", Status: Ready}
+		switch c.Status {
+		case "operational":
+		case "degraded_performance":
+			result.Status, result.Detail = Degraded, "slow"
+		case "partial_outage":
+			result.Status, result.Detail = Degraded, "partial outage"
+		case "under_maintenance":
+			result.Status, result.Detail = Degraded, "maintenance"
+		case "major_outage":
+			result.Status, result.Detail = Down, "major outage"
+		default:
+			result.Detail = c.Status
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func following(follow []string, name string) bool {
+	if len(follow) == 0 {
+		return true
+	}
+	for _, f := range follow {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compositeStatus folds the main check's status and its components into one.
+// The main endpoint decides whether the provider is reachable at all; an
+// impaired component only degrades it. The summary names the impaired
+// components, e.g. "degraded: completions slow, embeddings down".
+func compositeStatus(main Status, components []ComponentResult) (Status, string) {
+	var impaired []string
+	for _, c := range components {
+		switch c.Status {
+		case Degraded:
+			impaired = append(impaired, componentSummary(c, "degraded"))
+		case Down:
+			impaired = append(impaired, componentSummary(c, "down"))
+		}
+	}
+	if len(impaired) == 0 {
+		return main, ""
+	}
+	sort.Strings(impaired)
+	status := main
+	if status == Ready {
+		status = Degraded
+	}
+	return status, fmt.Sprintf("%s: %s", status, strings.Join(impaired, ", "))
+}
+
+func componentSummary(c ComponentResult, fallback string) string {
+	detail := c.Detail
+	if detail == "" || c.Source == "endpoint" && c.Status == Down {
+		detail = fallback
+	}
+	return c.Name + " " + detail
+}
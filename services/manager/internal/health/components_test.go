@@ -0,0 +1,77 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExternalCheckAggregatesComponents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/completions":
+			time.Sleep(30 * time.Millisecond)
+		case "/embeddings":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/summary.json":
+			w.Write([]byte(`{"components":[
+				{"name":"API","status":"operational"},
+				{"name":"ChatGPT","status":"major_outage"},
+				{"name":"Files","status":"degraded_performance"},
+				{"name":"Everything","status":"major_outage","group":true}
+			]}`))
+		}
+	}))
+	defer srv.Close()
+
+	h := NewHealthMonitor(0)
+	h.AddExternalProcess("ai", "custom", srv.URL+"/models", "api_key", nil)
+	ph := h.externalProcesses["ai"]
+	lastCheck := func() HealthCheck { return ph.CheckHistory[len(ph.CheckHistory)-1] }
+
+	// Only the main endpoint is checked until components are declared
+	h.performExternalHealthCheck(ph)
+	if c := lastCheck(); c.Status != Ready || c.Error != "" {
+		t.Fatalf("plain check = %+v", c)
+	}
+
+	h.SetHealthComponents("ai", ComponentProbe{
+		Components: []HealthComponent{
+			{Name: "completions", Endpoint: srv.URL + "/completions", SlowAfter: time.Millisecond},
+			{Name: "embeddings", Endpoint: srv.URL + "/embeddings"},
+		},
+		StatusPage: srv.URL + "/summary.json",
+		Follow:     []string{"api", "files"},
+	})
+	h.performExternalHealthCheck(ph)
+	want := "degraded: Files slow, completions slow, embeddings down"
+	if c := lastCheck(); c.Status != Degraded || c.Error != want {
+		t.Fatalf("composite check = %+v, want %q", c, want)
+	}
+
+	got, _ := h.GetExternalProcessHealth("ai")
+	if got.ServiceMetrics["summary"] != want {
+		t.Fatalf("summary = %v", got.ServiceMetrics["summary"])
+	}
+	components, _ := got.ServiceMetrics["components"].([]ComponentResult)
+	byName := map[string]ComponentResult{}
+	for _, c := range components {
+		byName[c.Name] = c
+	}
+	if len(byName) != 4 || byName["API"].Status != Ready || byName["embeddings"].StatusCode != 503 || byName["Files"].Source != "statusPage" {
+		t.Fatalf("components = %+v", components)
+	}
+}
+
+func TestCompositeStatus(t *testing.T) {
+	down := []ComponentResult{{Name: "completions", Source: "endpoint", Status: Down, Detail: "server error: 500"}}
+	if s, msg := compositeStatus(Down, down); s != Down || msg != "down: completions down" {
+		t.Fatalf("main down = %s %q", s, msg)
+	}
+	// A status page that can't be read has no status and changes nothing
+	unread := []ComponentResult{{Name: "status page", Source: "statusPage", Detail: "request failed"}}
+	if s, msg := compositeStatus(Ready, unread); s != Ready || msg != "" {
+		t.Fatalf("unreadable status page = %s %q", s, msg)
+	}
+}
@@ -2,23 +2,40 @@ package health
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
+
+	"mcp/manager/internal/providers"
 )
 
+const externalCheckTimeout = 10 * time.Second
+
 // ExternalHealthChecker monitors external/cloud MCP services
 type ExternalHealthChecker struct {
-	client *http.Client
+	client         *http.Client
+	insecureClient *http.Client
 }
 
 // NewExternalHealthChecker creates a new external health checker
 func NewExternalHealthChecker() *ExternalHealthChecker {
-	return &ExternalHealthChecker{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
+	e := &ExternalHealthChecker{}
+	e.SetRootCAs(nil)
+	return e
+}
+
+// SetRootCAs makes the checker trust rootCAs instead of only the system roots
+func (e *ExternalHealthChecker) SetRootCAs(rootCAs *x509.CertPool) {
+	e.client = NewHTTPClient(externalCheckTimeout, rootCAs, false)
+	e.insecureClient = NewHTTPClient(externalCheckTimeout, rootCAs, true)
+}
+
+// Insecure returns a checker that skips TLS certificate verification
+func (e *ExternalHealthChecker) Insecure() *ExternalHealthChecker {
+	return &ExternalHealthChecker{client: e.insecureClient, insecureClient: e.insecureClient}
 }
 
 // CheckHealth performs a health check on an external MCP service
@@ -28,83 +45,113 @@ func (e *ExternalHealthChecker) CheckHealth(ctx context.Context, endpoint string
 
 // CheckHealthWithCredentials performs a health check with flexible credential support
 func (e *ExternalHealthChecker) CheckHealthWithCredentials(ctx context.Context, endpoint string, credentials map[string]string) (*ExternalHealth, error) {
-	start := time.Now()
+	return e.CheckHealthExpecting(ctx, endpoint, credentials, nil)
+}
+
+// CheckHealthExpecting is CheckHealthWithCredentials for a custom health
+// endpoint: when expect is set, a response meeting it is healthy and a
+// successful one that misses it is unhealthy.
+func (e *ExternalHealthChecker) CheckHealthExpecting(ctx context.Context, endpoint string, credentials map[string]string, expect *HealthExpectation) (*ExternalHealth, error) {
+	return e.CheckHealthRequest(ctx, endpoint, credentials, HealthRequest{}, expect)
+}
+
+// CheckHealthRequest is CheckHealthExpecting sending extra's headers and
+// query parameters along with the credentials.
+func (e *ExternalHealthChecker) CheckHealthRequest(ctx context.Context, endpoint string, credentials map[string]string, extra HealthRequest, expect *HealthExpectation) (*ExternalHealth, error) {
+	probe := HTTPProbe{Client: e.client, Extra: extra, Header: credentialHeader(credentials), KeepBody: true}
+	if expect != nil {
+		probe.Expect = *expect
+	}
+	res := probe.Check(ctx, endpoint)
 	
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
-	if err != nil {
+	switch {
+	case res.Attempts == 0:
 		return &ExternalHealth{
 			Status:    "error",
-			Error:     fmt.Sprintf("failed to create request: %v", err),
+			Error:     fmt.Sprintf("failed to create request: %v", res.Err),
 			Timestamp: time.Now(),
 		}, nil
-	}
-	
-	// Add credentials based on provider requirements
-	if apiKey, exists := credentials["api_key"]; exists && apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+apiKey)
-	}
-	
-	if token, exists := credentials["oauth_token"]; exists && token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
-	
-	if username, exists := credentials["username"]; exists {
-		if password, exists := credentials["password"]; exists {
-			req.SetBasicAuth(username, password)
-		}
-	}
-	
-	resp, err := e.client.Do(req)
-	if err != nil {
+	case res.StatusCode == 0:
 		return &ExternalHealth{
 			Status:       "error",
-			Error:        fmt.Sprintf("request failed: %v", err),
+			Error:        fmt.Sprintf("request failed: %v", res.Err),
 			Timestamp:    time.Now(),
-			ResponseTime: time.Since(start).Milliseconds(),
+			ResponseTime: res.ResponseTime.Milliseconds(),
 		}, nil
 	}
-	defer resp.Body.Close()
 	
 	health := &ExternalHealth{
-		StatusCode:   resp.StatusCode,
-		ResponseTime: time.Since(start).Milliseconds(),
+		StatusCode:   res.StatusCode,
+		ResponseTime: res.ResponseTime.Milliseconds(),
 		Timestamp:    time.Now(),
+		body:         res.Body,
 	}
 	
 	// Enhanced status determination with rate limiting detection
 	switch {
-	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+	case res.StatusCode >= 200 && res.StatusCode < 300:
 		health.Status = "healthy"
-	case resp.StatusCode == 401:
+	case res.StatusCode == 401:
 		health.Status = "error"
 		health.Error = "unauthorized - credential may be expired or invalid"
-	case resp.StatusCode == 403:
+		health.CredentialExpired = true
+	case res.StatusCode == 403:
 		health.Status = "error"
 		health.Error = "forbidden - insufficient permissions or quota exceeded"
-	case resp.StatusCode == 429:
+	case res.StatusCode == 429:
 		health.Status = "warning"
 		health.Error = "rate limited"
 		// Extract rate limit information if available
-		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if retryAfter := res.Header.Get("Retry-After"); retryAfter != "" {
 			health.Error += fmt.Sprintf(" (retry after %s)", retryAfter)
 		}
-		if resetTime := resp.Header.Get("X-RateLimit-Reset"); resetTime != "" {
+		if resetTime := res.Header.Get("X-RateLimit-Reset"); resetTime != "" {
 			health.Error += fmt.Sprintf(" (reset at %s)", resetTime)
 		}
-	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+	case res.StatusCode >= 400 && res.StatusCode < 500:
 		health.Status = "error"
-		health.Error = fmt.Sprintf("client error: %d", resp.StatusCode)
-	case resp.StatusCode >= 500:
+		health.Error = fmt.Sprintf("client error: %d", res.StatusCode)
+	case res.StatusCode >= 500:
 		health.Status = "unhealthy"
-		health.Error = fmt.Sprintf("server error: %d", resp.StatusCode)
+		health.Error = fmt.Sprintf("server error: %d", res.StatusCode)
 	default:
 		health.Status = "warning"
-		health.Error = fmt.Sprintf("unexpected status: %d", resp.StatusCode)
+		health.Error = fmt.Sprintf("unexpected status: %d", res.StatusCode)
+	}
+	
+	// A custom endpoint's expectation overrides the status classes, except
+	// that a failure the classes already explain keeps its explanation
+	if expect != nil {
+		if res.Healthy() {
+			health.Status = "healthy"
+			health.Error = ""
+		} else if health.Status == "healthy" || res.StatusCode == expect.Status {
+			health.Status = "unhealthy"
+			health.Error = res.Err.Error()
+		}
 	}
 	
 	return health, nil
 }
 
+// credentialHeader returns the Authorization header for credentials: a
+// bearer API key or OAuth token, or basic auth, in rising precedence.
+func credentialHeader(credentials map[string]string) http.Header {
+	header := http.Header{}
+	if apiKey := credentials["api_key"]; apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if token := credentials["oauth_token"]; token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	username, hasUser := credentials["username"]
+	password, hasPassword := credentials["password"]
+	if hasUser && hasPassword {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+	return header
+}
+
 // ExternalHealth represents the health status of an external MCP service
 type ExternalHealth struct {
 	Status       string    `json:"status"` // "healthy", "unhealthy", "warning", "error"
@@ -112,6 +159,69 @@ type ExternalHealth struct {
 	ResponseTime int64     `json:"responseTime"` // milliseconds
 	Error        string    `json:"error,omitempty"`
 	Timestamp    time.Time `json:"timestamp"`
+	// CredentialExpired is set when the provider rejected the credentials
+	// in a way renewing them may fix: a 401, or a provider's own answer
+	// recognised by DetectExpiredCredential.
+	CredentialExpired bool `json:"credentialExpired,omitempty"`
+
+	body []byte
+}
+
+// expiryDetectors recognise the answers providers give to an expired or
+// revoked token other than a 401, returning the provider's reason.
+var expiryDetectors = map[string]func(statusCode int, body []byte) (string, bool){
+	"slack":  slackTokenExpired,
+	"google": googleTokenExpired,
+}
+
+// DetectExpiredCredential marks health as a failed check with an expired
+// credential when the response is the provider's way of saying so.
+func DetectExpiredCredential(provider string, health *ExternalHealth) {
+	detect, ok := expiryDetectors[provider]
+	if !ok || health == nil || health.CredentialExpired {
+		return
+	}
+	if reason, expired := detect(health.StatusCode, health.body); expired {
+		health.Status = "error"
+		health.Error = fmt.Sprintf("credential expired or revoked: %s", reason)
+		health.CredentialExpired = true
+	}
+}
+
+// slackTokenExpired reads Slack Web API errors, which arrive with a 200, as
+// in {"ok": false, "error": "token_expired"}.
+func slackTokenExpired(_ int, body []byte) (string, bool) {
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &resp) != nil || resp.OK {
+		return "", false
+	}
+	switch resp.Error {
+	case "token_expired", "token_revoked", "invalid_auth", "not_authed", "account_inactive":
+		return resp.Error, true
+	}
+	return "", false
+}
+
+// googleTokenExpired reads the tokeninfo endpoint's answer to a token it no
+// longer accepts: a 400 with {"error": "invalid_token"}.
+func googleTokenExpired(statusCode int, body []byte) (string, bool) {
+	if statusCode != http.StatusBadRequest {
+		return "", false
+	}
+	var resp struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &resp) != nil || resp.Error != "invalid_token" {
+		return "", false
+	}
+	if resp.Description != "" {
+		return resp.Error + " (" + resp.Description + ")", true
+	}
+	return resp.Error, true
 }
 
 // ProviderHealthEndpoints defines health check endpoints for known providers
@@ -125,10 +235,16 @@ var ProviderHealthEndpoints = map[string]string{
 	"anthropic":  "https://api.anthropic.com/v1/messages",
 }
 
-// GetProviderEndpoint returns the health check endpoint for a known provider
+// GetProviderEndpoint returns the health check endpoint for a known provider:
+// a built-in one, or a custom provider added with a health endpoint
 func GetProviderEndpoint(provider string) (string, bool) {
-	endpoint, ok := ProviderHealthEndpoints[provider]
-	return endpoint, ok
+	if endpoint, ok := ProviderHealthEndpoints[provider]; ok {
+		return endpoint, true
+	}
+	if p, err := providers.GetProvider(provider); err == nil && p.Custom && p.HealthEndpoint != "" {
+		return p.HealthEndpoint, true
+	}
+	return "", false
 }
 
 // CheckProviderHealth performs provider-specific health checks with enhanced error detection
@@ -0,0 +1,283 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/providers"
+)
+
+func TestCheckHealthExpecting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ready":
+			w.WriteHeader(http.StatusNoContent)
+		case "/status":
+			w.Write([]byte(`{"status":"ok"}`))
+		case "/auth":
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	checker := NewExternalHealthChecker()
+	cases := []struct {
+		path   string
+		expect *HealthExpectation
+		want   string
+	}{
+		{"/status", nil, "healthy"},
+		{"/status", &HealthExpectation{Body: `"status":"ok"`}, "healthy"},
+		{"/status", &HealthExpectation{Body: "degraded"}, "unhealthy"},
+		{"/ready", &HealthExpectation{Status: 204}, "healthy"},
+		{"/status", &HealthExpectation{Status: 204}, "unhealthy"},
+		{"/auth", &HealthExpectation{Status: 200}, "error"}, // credential errors keep their status
+		{"/auth", &HealthExpectation{Status: 401}, "healthy"},
+	}
+	for _, c := range cases {
+		h, err := checker.CheckHealthExpecting(context.Background(), srv.URL+c.path, nil, c.expect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Status != c.want {
+			t.Errorf("%s with %+v: got %q (%s), want %q", c.path, c.expect, h.Status, h.Error, c.want)
+		}
+	}
+}
+
+func TestCheckHealthRequestSendsExtras(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer srv.Close()
+
+	extra := HealthRequest{
+		Headers: map[string]string{"Notion-Version": "2022-06-28", "Authorization": "Bearer config"},
+		Query:   map[string]string{"tenant": "acme"},
+	}
+	h, err := NewExternalHealthChecker().CheckHealthRequest(context.Background(), srv.URL+"/status?page=1", map[string]string{"api_key": "vault"}, extra, nil)
+	if err != nil || h.Status != "healthy" {
+		t.Fatalf("check = %+v, %v", h, err)
+	}
+	if v := got.Header.Get("Notion-Version"); v != "2022-06-28" {
+		t.Errorf("Notion-Version = %q", v)
+	}
+	if v := got.Header.Get("Authorization"); v != "Bearer vault" {
+		t.Errorf("Authorization = %q, want the vault credential", v)
+	}
+	if q := got.URL.Query(); q.Get("tenant") != "acme" || q.Get("page") != "1" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+// fakeVault holds one set of google credentials and refreshes them to token,
+// stored under key, access_token by default.
+type fakeVault struct {
+	creds     map[string]string
+	token     string
+	key       string
+	err       error
+	refreshes int
+}
+
+func (v *fakeVault) Resolve(ref, provider string) (map[string]string, string, error) {
+	return v.creds, provider, nil
+}
+
+func (v *fakeVault) RefreshCredentials(ctx context.Context, ref, provider string) (map[string]string, error) {
+	v.refreshes++
+	if v.err != nil {
+		return nil, v.err
+	}
+	key := v.key
+	if key == "" {
+		key = "access_token"
+	}
+	v.creds = map[string]string{key: v.token, "refresh_token": v.creds["refresh_token"], "expires_at": time.Now().Add(time.Hour).Format(time.RFC3339)}
+	return v.creds, nil
+}
+
+func TestExternalCheckRefreshesExpiredToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	h := NewHealthMonitor(0)
+	h.AddExternalProcess("drive", "google", srv.URL, "oauth2", nil)
+	ph := h.externalProcesses["drive"]
+	lastCheck := func() HealthCheck { return ph.CheckHistory[len(ph.CheckHistory)-1] }
+
+	v := &fakeVault{creds: map[string]string{"access_token": "stale", "refresh_token": "1//r"}, token: "fresh"}
+	h.SetCredentialResolver(v)
+	h.SetCredentialRefresher(v)
+	h.performExternalHealthCheck(ph)
+	if c := lastCheck(); c.Status != Ready || v.refreshes != 1 {
+		t.Fatalf("check = %+v after %d refreshes", c, v.refreshes)
+	}
+	if ph.CredentialExpiry == nil || !ph.CredentialWarning {
+		t.Fatalf("expiry %v, warning %v after refresh", ph.CredentialExpiry, ph.CredentialWarning)
+	}
+	h.performExternalHealthCheck(ph)
+	if v.refreshes != 1 {
+		t.Fatalf("refreshed a working token: %d refreshes", v.refreshes)
+	}
+
+	// A failed refresh leaves the server down and says why
+	v.creds["access_token"], v.err = "stale", errors.New("invalid_grant")
+	h.performExternalHealthCheck(ph)
+	if c := lastCheck(); c.Status != Down || !strings.Contains(c.Error, "token refresh failed: invalid_grant") {
+		t.Fatalf("check after failed refresh = %+v", c)
+	}
+
+	// Without a refresh token there is nothing to try
+	v.creds, v.refreshes = map[string]string{"access_token": "stale"}, 0
+	h.performExternalHealthCheck(ph)
+	if c := lastCheck(); c.Status != Down || v.refreshes != 0 {
+		t.Fatalf("check without refresh token = %+v after %d refreshes", c, v.refreshes)
+	}
+}
+
+func TestExternalCheckDetectsProviderExpiry(t *testing.T) {
+	for _, tc := range []struct {
+		provider, key string
+		status        int
+		rejected, ok  string
+	}{
+		// Slack's auth.test answers every call with a 200
+		{"slack", "oauth_token", http.StatusOK, `{"ok":false,"error":"token_expired"}`, `{"ok":true,"url":"https://acme.slack.com/","team":"Acme","user":"bot","team_id":"T0","user_id":"U0"}`},
+		{"slack", "oauth_token", http.StatusOK, `{"ok":false,"error":"invalid_auth"}`, `{"ok":true,"team":"Acme","user":"bot"}`},
+		// Google's tokeninfo answers a token it no longer accepts with a 400
+		{"google", "access_token", http.StatusBadRequest, `{"error":"invalid_token","error_description":"Invalid Value"}`, `{"azp":"1.apps.googleusercontent.com","scope":"openid","expires_in":3599,"access_type":"offline"}`},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.Header.Get("Authorization") != "Bearer fresh" {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.rejected)
+				return
+			}
+			fmt.Fprint(w, tc.ok)
+		}))
+
+		h := NewHealthMonitor(0)
+		h.AddExternalProcess("svc", tc.provider, srv.URL, "oauth2", nil)
+		ph := h.externalProcesses["svc"]
+		lastCheck := func() HealthCheck { return ph.CheckHistory[len(ph.CheckHistory)-1] }
+
+		v := &fakeVault{key: tc.key, creds: map[string]string{tc.key: "stale", "refresh_token": "r"}, token: "fresh"}
+		h.SetCredentialResolver(v)
+		h.SetCredentialRefresher(v)
+		h.performExternalHealthCheck(ph)
+		if c := lastCheck(); c.Status != Ready || v.refreshes != 1 {
+			t.Errorf("%s %s: check = %+v after %d refreshes", tc.provider, tc.rejected, c, v.refreshes)
+		}
+
+		// Without a refresh token the expired credential is reported
+		v.creds, v.refreshes = map[string]string{tc.key: "stale"}, 0
+		h.performExternalHealthCheck(ph)
+		if c := lastCheck(); c.Status != Down || !strings.Contains(c.Error, "credential expired or revoked") {
+			t.Errorf("%s %s: check without refresh token = %+v", tc.provider, tc.rejected, c)
+		}
+		srv.Close()
+	}
+
+	// Other errors are not taken for an expired token
+	for _, tc := range []struct {
+		provider string
+		health   ExternalHealth
+	}{
+		{"slack", ExternalHealth{Status: "healthy", StatusCode: 200, body: []byte(`{"ok":false,"error":"ratelimited"}`)}},
+		{"google", ExternalHealth{Status: "error", StatusCode: 400, body: []byte(`{"error":"invalid_request"}`)}},
+		{"github", ExternalHealth{Status: "healthy", StatusCode: 200, body: []byte(`{"ok":false,"error":"token_expired"}`)}},
+	} {
+		health := tc.health
+		if DetectExpiredCredential(tc.provider, &health); health.CredentialExpired {
+			t.Errorf("%s %s taken for an expired token", tc.provider, tc.health.body)
+		}
+	}
+}
+
+// noCredentials is a vault without the server's credentials.
+type noCredentials struct{}
+
+func (noCredentials) Resolve(ref, provider string) (map[string]string, string, error) {
+	return nil, "", fmt.Errorf("credentials not found for %s or provider default %s", ref, provider)
+}
+
+func TestExternalCheckReportsMissingCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	h := NewHealthMonitor(0)
+	h.AddExternalProcess("notion", "notion", srv.URL, "api_key", nil)
+	h.SetCredentialRef("notion", "ext:notion:notion")
+	h.SetCredentialResolver(noCredentials{})
+	ph := h.externalProcesses["notion"]
+	h.performExternalHealthCheck(ph)
+	if c := ph.CheckHistory[len(ph.CheckHistory)-1]; c.Status != Down || !strings.Contains(c.Error, "no credentials: credentials not found for ext:notion:notion") {
+		t.Fatalf("check = %+v", c)
+	}
+}
+
+func TestCredentialWarning(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	soon := time.Now().Add(24 * time.Hour)
+	h := NewHealthMonitor(0)
+	h.AddExternalProcess("drive", "google", srv.URL, "oauth2", &soon)
+	ph := h.externalProcesses["drive"]
+	if !ph.CredentialWarning {
+		t.Fatal("no warning for a token expiring tomorrow")
+	}
+
+	// A later token recorded in the vault clears the warning
+	later := time.Now().Add(30 * 24 * time.Hour).Format(time.RFC3339)
+	h.SetCredentialResolver(&fakeVault{creds: map[string]string{"access_token": "t", "expires_at": later}})
+	h.performExternalHealthCheck(ph)
+	if ph.CredentialWarning || ph.CredentialExpiry == nil || ph.CredentialExpiry.Format(time.RFC3339) != later {
+		t.Fatalf("expiry %v, warning %v", ph.CredentialExpiry, ph.CredentialWarning)
+	}
+}
+
+func TestExternalCheckUsesCustomProviderEndpoint(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer srv.Close()
+	err := providers.AddCustomProvider(filepath.Join(t.TempDir(), "providers.json"), providers.Provider{
+		Name:           "statushub",
+		DisplayName:    "StatusHub",
+		AuthType:       providers.AuthAPIKey,
+		HealthEndpoint: srv.URL,
+		Credentials:    []providers.Credential{{Key: "api_key", DisplayName: "API Key", Required: true, Secret: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the server nor an override names an endpoint, as after an
+	// update that clears the server's own health endpoint
+	h := NewHealthMonitor(0)
+	h.AddExternalProcess("hub", "statushub", "", "api_key", nil)
+	h.SetHealthEndpoint("hub", "", HealthExpectation{})
+	ph := h.externalProcesses["hub"]
+	h.performExternalHealthCheck(ph)
+	if c := ph.CheckHistory[len(ph.CheckHistory)-1]; c.Status != Ready || hits != 1 {
+		t.Fatalf("check = %+v, %d hits on the provider's endpoint", c, hits)
+	}
+}
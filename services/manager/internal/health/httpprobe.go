@@ -0,0 +1,161 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPProbe is a GET health check: local HTTP servers, external servers and
+// the connection test all run theirs through it. Retries, the success
+// criterion and the headers are set per use; the client is meant to be
+// shared between checks.
+type HTTPProbe struct {
+	Client        *http.Client      // nil uses http.DefaultClient
+	Method        string            // empty sends a GET
+	Timeout       time.Duration     // bounds each attempt; 0 leaves it to the client and ctx
+	Attempts      int               // tries before giving up; below 1 means 1
+	Backoff       time.Duration     // pause between attempts
+	Extra         HealthRequest     // configured headers and query parameters
+	Header        http.Header       // set after Extra, so credentials win over a configured header
+	Expect        HealthExpectation // success criterion; the zero value accepts any 2xx
+	AllowRedirect bool              // also accept a 3xx when Expect names no status or body
+	KeepBody      bool              // return the start of the body in the result
+}
+
+// HTTPProbeResult is the outcome of an HTTPProbe's last attempt.
+type HTTPProbeResult struct {
+	StatusCode   int           // 0 when no response arrived
+	Header       http.Header   // of the response
+	ResponseTime time.Duration // of the last attempt
+	Attempts     int           // 0 when the request could not even be built
+	Err          error         // why the check failed; nil when healthy
+	Body         []byte        // up to maxExpectedBodyRead bytes, when the probe keeps it
+}
+
+// Healthy reports whether the last attempt met the probe's criterion.
+func (r HTTPProbeResult) Healthy() bool {
+	return r.Err == nil
+}
+
+// Check requests url until an attempt is healthy or the attempts run out. It
+// stops early when ctx is done.
+func (p HTTPProbe) Check(ctx context.Context, url string) HTTPProbeResult {
+	attempts := max(p.Attempts, 1)
+	var res HTTPProbeResult
+	for n := 1; n <= attempts; n++ {
+		if n > 1 {
+			select {
+			case <-time.After(p.Backoff):
+			case <-ctx.Done():
+				return res
+			}
+		}
+		res = p.attempt(ctx, url)
+		if res.Attempts == 0 {
+			return res
+		}
+		res.Attempts = n
+		if res.Err == nil {
+			return res
+		}
+	}
+	return res
+}
+
+// attempt makes a single request. Its Attempts is 1, or 0 when the request
+// could not be built and retrying is pointless.
+func (p HTTPProbe) attempt(ctx context.Context, url string) HTTPProbeResult {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	method := p.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return HTTPProbeResult{Err: err}
+	}
+	p.Extra.Apply(req)
+	for k, v := range p.Header {
+		req.Header[k] = v
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res := HTTPProbeResult{Attempts: 1}
+	start := time.Now()
+	resp, err := client.Do(req)
+	res.ResponseTime = time.Since(start)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+	res.StatusCode, res.Header = resp.StatusCode, resp.Header
+
+	var body []byte
+	if p.Expect.Body != "" || p.KeepBody {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, maxExpectedBodyRead))
+	}
+	if p.KeepBody {
+		res.Body = body
+	}
+	res.Err = p.Expect.Check(resp.StatusCode, body)
+	if res.Err != nil && p.AllowRedirect && p.Expect == (HealthExpectation{}) && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		res.Err = nil
+	}
+	return res
+}
+
+// HealthExpectation is the response a custom health endpoint must give to count as healthy
+type HealthExpectation struct {
+	Status int    // required status code; 0 accepts any 2xx
+	Body   string // substring the body must contain; empty accepts any body
+}
+
+// maxExpectedBodyRead bounds how much of a response is searched for Body
+const maxExpectedBodyRead = 64 * 1024
+
+// Check returns why a response does not meet the expectation, or nil if it does
+func (x HealthExpectation) Check(statusCode int, body []byte) error {
+	if x.Status != 0 && statusCode != x.Status {
+		return fmt.Errorf("expected status %d, got %d", x.Status, statusCode)
+	}
+	if x.Status == 0 && (statusCode < 200 || statusCode >= 300) {
+		return fmt.Errorf("expected a 2xx status, got %d", statusCode)
+	}
+	if x.Body != "" && !bytes.Contains(body, []byte(x.Body)) {
+		return fmt.Errorf("response body does not contain %q", x.Body)
+	}
+	return nil
+}
+
+// HealthRequest holds the extra headers and query parameters of a health check
+type HealthRequest struct {
+	Headers map[string]string
+	Query   map[string]string
+}
+
+// Apply adds the extra headers and query parameters to req. Credentials are
+// set afterwards, so they win over a header of the same name.
+func (x HealthRequest) Apply(req *http.Request) {
+	if len(x.Query) > 0 {
+		q := req.URL.Query()
+		for k, v := range x.Query {
+			q.Set(k, v)
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	for k, v := range x.Headers {
+		req.Header.Set(k, v)
+	}
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProbeRetriesUntilHealthy(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	probe := HTTPProbe{Attempts: 3, Backoff: time.Millisecond}
+	if res := probe.Check(context.Background(), srv.URL); !res.Healthy() || res.Attempts != 3 || res.StatusCode != 200 {
+		t.Fatalf("third attempt = %+v", res)
+	}
+
+	calls.Store(-10)
+	res := probe.Check(context.Background(), srv.URL)
+	if res.Healthy() || res.Attempts != 3 || res.StatusCode != 503 || res.Err.Error() != "expected a 2xx status, got 503" {
+		t.Fatalf("exhausted = %+v", res)
+	}
+}
+
+func TestHTTPProbeCriteria(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			w.WriteHeader(http.StatusNotModified)
+		case "/auth":
+			w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	// Redirects count only for local servers, and never against an expectation
+	if res := (HTTPProbe{}).Check(ctx, srv.URL+"/moved"); res.Healthy() {
+		t.Fatal("3xx healthy by default")
+	}
+	if res := (HTTPProbe{AllowRedirect: true}).Check(ctx, srv.URL+"/moved"); !res.Healthy() {
+		t.Fatalf("3xx with AllowRedirect = %+v", res)
+	}
+	if res := (HTTPProbe{AllowRedirect: true, Expect: HealthExpectation{Status: 200}}).Check(ctx, srv.URL+"/moved"); res.Healthy() {
+		t.Fatal("3xx met an expected 200")
+	}
+
+	// Header goes on top of the configured extras
+	probe := HTTPProbe{
+		Extra:  HealthRequest{Headers: map[string]string{"Authorization": "configured"}},
+		Header: http.Header{"Authorization": {"Bearer cred"}},
+		Expect: HealthExpectation{Body: "Bearer cred"},
+	}
+	if res := probe.Check(ctx, srv.URL+"/auth"); !res.Healthy() {
+		t.Fatalf("credential header = %+v", res)
+	}
+}
+
+func TestHTTPProbeFailures(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	probe := HTTPProbe{Attempts: 2, Backoff: time.Millisecond}
+	if res := probe.Check(context.Background(), srv.URL); res.StatusCode != 0 || res.Attempts != 2 || res.Err == nil {
+		t.Fatalf("unreachable = %+v", res)
+	}
+	// A URL that can't make a request is not retried
+	if res := probe.Check(context.Background(), "http://bad host/"); res.Attempts != 0 || res.Err == nil {
+		t.Fatalf("bad URL = %+v", res)
+	}
+}
+
+func TestLocalHTTPCheckStatuses(t *testing.T) {
+	code := http.StatusFound
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/elsewhere")
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	h := NewHealthMonitor(0)
+	h.retryBackoff = time.Millisecond
+	ph := &ProcessHealth{Name: "web", HTTPURL: srv.URL}
+	client := h.httpClient("web")
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	defer func() { client.CheckRedirect = nil }()
+
+	if status, _, err := h.performHTTPCheck(ph); status != Ready || err != nil {
+		t.Fatalf("302 = %s %v", status, err)
+	}
+	code = http.StatusInternalServerError
+	if status, _, err := h.performHTTPCheck(ph); status != Degraded || err == nil || err.Error() != "HTTP check returned status 500" {
+		t.Fatalf("500 = %s %v", status, err)
+	}
+	srv.Close()
+	if status, _, err := h.performHTTPCheck(ph); status != Down || err == nil {
+		t.Fatalf("unreachable = %s %v", status, err)
+	}
+}
@@ -0,0 +1,45 @@
+package health
+
+import (
+    "math"
+    "sort"
+    "time"
+)
+
+// latencyStats holds the mean and percentiles of the response times in a
+// process's check history
+type latencyStats struct {
+    mean, p50, p95, p99 time.Duration
+}
+
+// windowLatency computes latency over the successful checks in history.
+// Failed checks are left out, as their time is a timeout or an error path.
+func windowLatency(history []HealthCheck) latencyStats {
+    samples := make([]time.Duration, 0, len(history))
+    var total time.Duration
+    for _, c := range history {
+        if c.Error == "" && c.ResponseTime > 0 {
+            samples = append(samples, c.ResponseTime)
+            total += c.ResponseTime
+        }
+    }
+    if len(samples) == 0 {
+        return latencyStats{}
+    }
+    sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+    return latencyStats{
+        mean: total / time.Duration(len(samples)),
+        p50:  percentile(samples, 50),
+        p95:  percentile(samples, 95),
+        p99:  percentile(samples, 99),
+    }
+}
+
+// percentile returns the nearest-rank pth percentile of sorted
+func percentile(sorted []time.Duration, p float64) time.Duration {
+    rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+    if rank < 1 {
+        rank = 1
+    }
+    return sorted[rank-1]
+}
@@ -0,0 +1,51 @@
+package health
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestWindowLatency(t *testing.T) {
+    var history []HealthCheck
+    for i := 100; i >= 1; i-- {
+        history = append(history, HealthCheck{ResponseTime: time.Duration(i) * time.Millisecond})
+    }
+    // Failures don't count, however long they took
+    history = append(history, HealthCheck{ResponseTime: time.Minute, Error: "timeout"})
+
+    got := windowLatency(history)
+    want := latencyStats{mean: 50500 * time.Microsecond, p50: 50 * time.Millisecond, p95: 95 * time.Millisecond, p99: 99 * time.Millisecond}
+    if got != want {
+        t.Fatalf("windowLatency = %+v, want %+v", got, want)
+    }
+    if got := windowLatency(history[100:]); got != (latencyStats{}) {
+        t.Fatalf("no successful checks: %+v", got)
+    }
+}
+
+func TestResponseTimeIsWindowMean(t *testing.T) {
+    h := NewHealthMonitor(0)
+    h.AddExternalProcess("ext", "notion", "", "api_key", nil)
+    ph := h.externalProcesses["ext"]
+    ph.maxHistorySize = 3
+
+    // A probe that never succeeded has no minimum yet
+    h.updateExternalProcessHealth(ph, Down, time.Second, errors.New("refused"), "test")
+    if ph.MinResponseTime != 0 {
+        t.Fatalf("min %v before any success, want 0", ph.MinResponseTime)
+    }
+
+    for _, ms := range []int{500, 10, 10, 100} {
+        h.updateExternalProcessHealth(ph, Ready, time.Duration(ms)*time.Millisecond, nil, "test")
+    }
+    h.updateExternalProcessHealth(ph, Down, time.Second, errors.New("refused"), "test")
+
+    // The 500ms check has left the window and the failure is ignored
+    if ph.AvgResponseTime != 55*time.Millisecond || ph.P50ResponseTime != 10*time.Millisecond || ph.P99ResponseTime != 100*time.Millisecond {
+        t.Fatalf("avg %v p50 %v p99 %v", ph.AvgResponseTime, ph.P50ResponseTime, ph.P99ResponseTime)
+    }
+    if ph.MaxResponseTime != 500*time.Millisecond || ph.MinResponseTime != 10*time.Millisecond {
+        t.Fatalf("min %v max %v, want the lifetime extremes", ph.MinResponseTime, ph.MaxResponseTime)
+    }
+}
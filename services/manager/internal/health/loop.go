@@ -10,8 +10,8 @@ type ProbeTarget interface {
     GetProbeInput() ProbeInput
 }
 
-// Run evaluates ProbeInput at interval and sets status accordingly.
-func Run(t Target, interval time.Duration, stop <-chan struct{}) {
+// Run evaluates ProbeInput against th at interval and sets status accordingly.
+func Run(t Target, th Thresholds, interval time.Duration, stop <-chan struct{}) {
     ticker := time.NewTicker(interval)
     defer ticker.Stop()
     for {
@@ -20,7 +20,7 @@ func Run(t Target, interval time.Duration, stop <-chan struct{}) {
             return
         case <-ticker.C:
             in := t.GetProbeInput()
-            st := Evaluate(in)
+            st := Evaluate(in, th)
             t.SetStatus(st)
         }
     }
@@ -0,0 +1,391 @@
+package health
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+
+    "mcp/manager/internal/buildinfo"
+)
+
+// ErrCapabilityUnsupported is returned when a server does not advertise, or
+// rejects, the capability behind a list request.
+var ErrCapabilityUnsupported = errors.New("capability not supported by server")
+
+// rpcResponse is an MCPResponse whose result is kept raw for pass-through.
+type rpcResponse struct {
+    ID     json.RawMessage `json:"id"`
+    Result json.RawMessage `json:"result,omitempty"`
+    Error  *MCPError       `json:"error,omitempty"`
+}
+
+// MCPList initializes a session with the MCP Streamable HTTP endpoint and
+// issues a list method ("tools/list", "resources/list" or "prompts/list"),
+// returning its raw result. The session is closed on return.
+func MCPList(ctx context.Context, endpoint, method string) (json.RawMessage, error) {
+    t := mcpHTTP{client: http.DefaultClient}
+    session, result, err := t.handshake(ctx, endpoint)
+    if err != nil {
+        return nil, err
+    }
+    defer t.closeSession(ctx, endpoint, session)
+    if err := checkCapability(result, method); err != nil {
+        return nil, err
+    }
+    
+    req := MCPInitializeRequest{JSONRPC: "2.0", ID: 2, Method: method, Params: map[string]interface{}{}}
+    resp, _, err := t.post(ctx, endpoint, session, req)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", method, err)
+    }
+    return listResult(method, resp)
+}
+
+// MCPForward relays a single raw JSON-RPC message to the Streamable HTTP
+// endpoint inside a fresh session and returns the server's raw reply, or nil
+// for notifications. An "initialize" request is forwarded as the handshake
+// itself. The session is closed on return.
+func MCPForward(ctx context.Context, endpoint, method string, msg json.RawMessage) (json.RawMessage, error) {
+    t := mcpHTTP{client: http.DefaultClient}
+    session := ""
+    if method != "initialize" {
+        var err error
+        if session, _, err = t.handshake(ctx, endpoint); err != nil {
+            return nil, err
+        }
+    }
+    reply, session, err := t.postRaw(ctx, endpoint, session, msg)
+    t.closeSession(ctx, endpoint, session)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", method, err)
+    }
+    return reply, nil
+}
+
+// StdioList is MCPList over conn, a session on the stdio pipes of a running
+// server such as the supervisor's Attach opens. conn is closed on return, or
+// as soon as ctx is done to unblock the read.
+func StdioList(ctx context.Context, conn io.ReadWriteCloser, method string) (json.RawMessage, error) {
+    c, done := openStdio(ctx, conn)
+    defer done()
+    
+    result, err := c.handshake()
+    if err != nil {
+        return nil, err
+    }
+    if err := checkCapability(result, method); err != nil {
+        return nil, err
+    }
+    req, err := json.Marshal(MCPInitializeRequest{JSONRPC: "2.0", ID: 2, Method: method, Params: map[string]interface{}{}})
+    if err != nil {
+        return nil, err
+    }
+    raw, err := c.call(json.RawMessage("2"), req)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", method, err)
+    }
+    var resp rpcResponse
+    if err := json.Unmarshal(raw, &resp); err != nil {
+        return nil, fmt.Errorf("%s: invalid response: %w", method, err)
+    }
+    return listResult(method, &resp)
+}
+
+// StdioForward is MCPForward over conn, a session on the stdio pipes of a
+// running server. The server's stdio broker answers an "initialize" request
+// itself, so one is forwarded as is. conn is closed on return, or as soon as
+// ctx is done to unblock the read.
+func StdioForward(ctx context.Context, conn io.ReadWriteCloser, method string, msg json.RawMessage) (json.RawMessage, error) {
+    c, done := openStdio(ctx, conn)
+    defer done()
+    
+    if method != "initialize" {
+        if _, err := c.handshake(); err != nil {
+            return nil, err
+        }
+    }
+    var req struct {
+        ID json.RawMessage `json:"id"`
+    }
+    if err := json.Unmarshal(msg, &req); err != nil {
+        return nil, fmt.Errorf("%s: %w", method, err)
+    }
+    if len(req.ID) == 0 {
+        // A notification gets no reply
+        if err := c.send(msg); err != nil {
+            return nil, fmt.Errorf("%s: %w", method, err)
+        }
+        return nil, nil
+    }
+    reply, err := c.call(req.ID, msg)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", method, err)
+    }
+    return reply, nil
+}
+
+// checkCapability fails with ErrCapabilityUnsupported unless the server
+// advertised the capability behind a list method.
+func checkCapability(result *initializeResult, method string) error {
+    capability := strings.TrimSuffix(method, "/list")
+    if _, ok := result.Capabilities[capability]; !ok {
+        return fmt.Errorf("%w: %s", ErrCapabilityUnsupported, capability)
+    }
+    return nil
+}
+
+// listResult returns the result of the response to a list method.
+func listResult(method string, resp *rpcResponse) (json.RawMessage, error) {
+    if resp == nil {
+        return nil, fmt.Errorf("%s: empty response", method)
+    }
+    if resp.Error != nil {
+        if resp.Error.Code == -32601 {
+            return nil, fmt.Errorf("%w: %s", ErrCapabilityUnsupported, strings.TrimSuffix(method, "/list"))
+        }
+        return nil, fmt.Errorf("%s: %s", method, resp.Error.Message)
+    }
+    return resp.Result, nil
+}
+
+// initializeResult is the part of an initialize result the manager reads.
+type initializeResult struct {
+    ProtocolVersion string                 `json:"protocolVersion"`
+    Capabilities    map[string]interface{} `json:"capabilities"`
+}
+
+// initializeRequest is the initialize request the manager sends as a client.
+func initializeRequest() MCPInitializeRequest {
+    return MCPInitializeRequest{
+        JSONRPC: "2.0",
+        ID:      1,
+        Method:  "initialize",
+        Params: MCPInitializeParams{
+            ProtocolVersion: "2025-03-26",
+            Capabilities:    map[string]interface{}{},
+            ClientInfo:      ClientInfo{Name: "mcp-manager", Version: buildinfo.Version()},
+        },
+    }
+}
+
+// decodeInitializeResult reads the result of an initialize response.
+func decodeInitializeResult(resp *rpcResponse) (*initializeResult, error) {
+    if resp == nil {
+        return nil, fmt.Errorf("initialize: empty response")
+    }
+    if resp.Error != nil {
+        return nil, fmt.Errorf("initialize: %s", resp.Error.Message)
+    }
+    var result initializeResult
+    if err := json.Unmarshal(resp.Result, &result); err != nil {
+        return nil, fmt.Errorf("initialize: invalid result: %w", err)
+    }
+    return &result, nil
+}
+
+// mcpHTTP sends MCP messages over Streamable HTTP with client, adding header
+// to every request, such as the auth of a server's health check.
+type mcpHTTP struct {
+    client *http.Client
+    header http.Header
+}
+
+// handshake runs initialize and notifications/initialized, returning the
+// session ID (if the server issued one) and the initialize result.
+func (t mcpHTTP) handshake(ctx context.Context, endpoint string) (string, *initializeResult, error) {
+    resp, session, err := t.post(ctx, endpoint, "", initializeRequest())
+    if err != nil {
+        return "", nil, fmt.Errorf("initialize: %w", err)
+    }
+    result, err := decodeInitializeResult(resp)
+    if err != nil {
+        return "", nil, err
+    }
+    
+    notify := map[string]string{"jsonrpc": "2.0", "method": "notifications/initialized"}
+    if _, _, err := t.post(ctx, endpoint, session, notify); err != nil {
+        return "", nil, fmt.Errorf("initialized notification: %w", err)
+    }
+    return session, result, nil
+}
+
+// closeSession ends a session the server issued, as the Streamable HTTP
+// transport asks of clients that are done with one. Servers may refuse with
+// 405, which is as good as closed, so the outcome is ignored.
+func (t mcpHTTP) closeSession(ctx context.Context, endpoint, session string) {
+    if session == "" {
+        return
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+    if err != nil {
+        return
+    }
+    t.setHeaders(req, session)
+    if resp, err := t.client.Do(req); err == nil {
+        resp.Body.Close()
+    }
+}
+
+// post sends one JSON-RPC message and decodes the reply. Notifications get
+// no reply and return a nil response.
+func (t mcpHTTP) post(ctx context.Context, endpoint, session string, msg interface{}) (*rpcResponse, string, error) {
+    body, err := json.Marshal(msg)
+    if err != nil {
+        return nil, "", err
+    }
+    raw, session, err := t.postRaw(ctx, endpoint, session, body)
+    if err != nil || raw == nil {
+        return nil, session, err
+    }
+    var out rpcResponse
+    if err := json.Unmarshal(raw, &out); err != nil {
+        return nil, session, fmt.Errorf("invalid response: %w", err)
+    }
+    return &out, session, nil
+}
+
+// postRaw sends an encoded JSON-RPC message and returns the raw reply, which
+// may arrive as plain JSON or inside an SSE stream.
+func (t mcpHTTP) postRaw(ctx context.Context, endpoint, session string, body []byte) (json.RawMessage, string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+    if err != nil {
+        return nil, "", err
+    }
+    t.setHeaders(req, session)
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("Accept", "application/json, text/event-stream")
+    
+    resp, err := t.client.Do(req)
+    if err != nil {
+        return nil, "", err
+    }
+    defer resp.Body.Close()
+    
+    if s := resp.Header.Get("Mcp-Session-Id"); s != "" {
+        session = s
+    }
+    if resp.StatusCode == http.StatusAccepted {
+        return nil, session, nil
+    }
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        return nil, session, fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+    }
+    
+    if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+        scanner := bufio.NewScanner(resp.Body)
+        scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+        for scanner.Scan() {
+            data, ok := strings.CutPrefix(scanner.Text(), "data:")
+            if !ok {
+                continue
+            }
+            // Skip server-initiated requests and notifications interleaved in the stream
+            raw := []byte(strings.TrimSpace(data))
+            var probe rpcResponse
+            if err := json.Unmarshal(raw, &probe); err == nil && (probe.Result != nil || probe.Error != nil) {
+                return raw, session, nil
+            }
+        }
+        if err := scanner.Err(); err != nil {
+            return nil, session, err
+        }
+        return nil, session, fmt.Errorf("event stream ended without a response")
+    }
+    
+    var raw json.RawMessage
+    if err := json.NewDecoder(resp.Body).Decode(&raw); err == io.EOF {
+        return nil, session, nil
+    } else if err != nil {
+        return nil, session, fmt.Errorf("invalid response: %w", err)
+    }
+    return raw, session, nil
+}
+
+func (t mcpHTTP) setHeaders(req *http.Request, session string) {
+    for k, v := range t.header {
+        req.Header[k] = v
+    }
+    if session != "" {
+        req.Header.Set("Mcp-Session-Id", session)
+    }
+}
+
+// stdioConn exchanges newline-delimited JSON-RPC messages over a session on
+// a stdio server's pipes.
+type stdioConn struct {
+    conn io.ReadWriteCloser
+    r    *bufio.Reader
+}
+
+// openStdio wraps conn and arranges for it to be closed when ctx is done.
+// The returned func closes it for good.
+func openStdio(ctx context.Context, conn io.ReadWriteCloser) (*stdioConn, func()) {
+    stop := context.AfterFunc(ctx, func() { conn.Close() })
+    return &stdioConn{conn: conn, r: bufio.NewReader(conn)}, func() {
+        stop()
+        conn.Close()
+    }
+}
+
+func (c *stdioConn) send(msg []byte) error {
+    _, err := c.conn.Write(append(bytes.TrimSpace(msg), '\n'))
+    return err
+}
+
+// call sends msg and returns the response carrying id, skipping notifications,
+// server requests and other responses in between.
+func (c *stdioConn) call(id json.RawMessage, msg []byte) (json.RawMessage, error) {
+    if err := c.send(msg); err != nil {
+        return nil, err
+    }
+    want := compactJSON(id)
+    for {
+        line, err := c.r.ReadBytes('\n')
+        if err != nil {
+            return nil, fmt.Errorf("no response: %w", err)
+        }
+        var resp rpcResponse
+        if json.Unmarshal(line, &resp) != nil || (resp.Result == nil && resp.Error == nil) || compactJSON(resp.ID) != want {
+            continue
+        }
+        return json.RawMessage(bytes.TrimSpace(line)), nil
+    }
+}
+
+// handshake runs initialize and notifications/initialized.
+func (c *stdioConn) handshake() (*initializeResult, error) {
+    req, err := json.Marshal(initializeRequest())
+    if err != nil {
+        return nil, err
+    }
+    raw, err := c.call(json.RawMessage("1"), req)
+    if err != nil {
+        return nil, fmt.Errorf("initialize: %w", err)
+    }
+    var resp rpcResponse
+    if err := json.Unmarshal(raw, &resp); err != nil {
+        return nil, fmt.Errorf("initialize: invalid response: %w", err)
+    }
+    result, err := decodeInitializeResult(&resp)
+    if err != nil {
+        return nil, err
+    }
+    if err := c.send([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); err != nil {
+        return nil, fmt.Errorf("initialized notification: %w", err)
+    }
+    return result, nil
+}
+
+func compactJSON(raw json.RawMessage) string {
+    var b bytes.Buffer
+    if json.Compact(&b, raw) != nil {
+        return string(raw)
+    }
+    return b.String()
+}
@@ -0,0 +1,93 @@
+package health
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+)
+
+func TestMCPList(t *testing.T) {
+    var closed []string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method == http.MethodDelete {
+            closed = append(closed, r.Header.Get("Mcp-Session-Id"))
+            return
+        }
+        var msg struct {
+            ID     int    `json:"id"`
+            Method string `json:"method"`
+        }
+        _ = json.NewDecoder(r.Body).Decode(&msg)
+        switch msg.Method {
+        case "initialize":
+            w.Header().Set("Mcp-Session-Id", "sess-1")
+            fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":{"capabilities":{"tools":{}}}}`, msg.ID)
+        case "notifications/initialized":
+            w.WriteHeader(http.StatusAccepted)
+        case "tools/list":
+            if r.Header.Get("Mcp-Session-Id") != "sess-1" {
+                w.WriteHeader(http.StatusBadRequest)
+                return
+            }
+            w.Header().Set("Content-Type", "text/event-stream")
+            fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%d,\"result\":{\"tools\":[{\"name\":\"echo\"}]}}\n\n", msg.ID)
+        }
+    }))
+    defer srv.Close()
+    
+    result, err := MCPList(context.Background(), srv.URL, "tools/list")
+    if err != nil { t.Fatal(err) }
+    var tools struct{ Tools []struct{ Name string } }
+    if err := json.Unmarshal(result, &tools); err != nil { t.Fatal(err) }
+    if len(tools.Tools) != 1 || tools.Tools[0].Name != "echo" { t.Fatalf("unexpected result %s", result) }
+    
+    if _, err := MCPList(context.Background(), srv.URL, "resources/list"); !errors.Is(err, ErrCapabilityUnsupported) {
+        t.Fatalf("want ErrCapabilityUnsupported, got %v", err)
+    }
+    if len(closed) != 2 || closed[0] != "sess-1" { t.Fatalf("sessions closed: %v", closed) }
+}
+
+// fakeStdio answers like a stdio broker session: initialize, then echoes each
+// request's method back after a notification, which callers must skip.
+func fakeStdio() net.Conn {
+    client, server := net.Pipe()
+    go func() {
+        defer server.Close()
+        sc := bufio.NewScanner(server)
+        for sc.Scan() {
+            var msg struct {
+                ID     json.RawMessage `json:"id"`
+                Method string          `json:"method"`
+            }
+            if json.Unmarshal(sc.Bytes(), &msg) != nil || msg.ID == nil { continue }
+            if msg.Method == "initialize" {
+                fmt.Fprintf(server, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-03-26","capabilities":{"tools":{}}}}`+"\n", msg.ID)
+                continue
+            }
+            fmt.Fprintf(server, `{"jsonrpc":"2.0","method":"notifications/message","params":{}}`+"\n")
+            fmt.Fprintf(server, `{"jsonrpc":"2.0","id":%s,"result":{"method":%q}}`+"\n", msg.ID, msg.Method)
+        }
+    }()
+    return client
+}
+
+func TestStdioCalls(t *testing.T) {
+    ctx := context.Background()
+    result, err := StdioList(ctx, fakeStdio(), "tools/list")
+    if err != nil || string(result) != `{"method":"tools/list"}` { t.Fatalf("StdioList = %s, %v", result, err) }
+    if _, err := StdioList(ctx, fakeStdio(), "prompts/list"); !errors.Is(err, ErrCapabilityUnsupported) {
+        t.Fatalf("want ErrCapabilityUnsupported, got %v", err)
+    }
+    
+    reply, err := StdioForward(ctx, fakeStdio(), "tools/call", json.RawMessage(`{"jsonrpc":"2.0","id": "a1","method":"tools/call"}`))
+    if err != nil || string(reply) != `{"jsonrpc":"2.0","id":"a1","result":{"method":"tools/call"}}` { t.Fatalf("StdioForward = %s, %v", reply, err) }
+    if reply, err := StdioForward(ctx, fakeStdio(), "notifications/cancelled", json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/cancelled"}`)); err != nil || reply != nil {
+        t.Fatalf("notification = %s, %v", reply, err)
+    }
+}
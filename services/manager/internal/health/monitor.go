@@ -2,10 +2,14 @@ package health
 
 import (
     "context"
+    "crypto/x509"
+    "errors"
     "fmt"
     "io"
+    "log"
     "net/http"
     "os"
+    "sort"
     "strings"
     "sync"
     "time"
@@ -60,13 +64,51 @@ type HealthMonitor struct {
     mcpTimeout            time.Duration
     retryAttempts         int
     retryBackoff          time.Duration
+    hysteresis            Hysteresis
+    thresholds            Thresholds
+    
+    // Supervisor state of local processes
+    source ProcessSource
+    
+    // Bounded worker pool shared by local and external checks
+    pool             *checkPool
+    checkConcurrency int
     
     // External health checking
     externalChecker *ExternalHealthChecker
     
+    // TLS trust for HTTP checks, and the clients built on it and shared by
+    // every check
+    rootCAs        *x509.CertPool
+    insecure       map[string]bool
+    client         *http.Client
+    insecureClient *http.Client
+    
     // Registry integration
     registryUpdater func(slug string, status registry.ExternalStatus)
     
+    // Credentials for external checks, resolved per server ref then provider default
+    credentials    CredentialResolver
+    credentialRefs map[string]string
+    refresher      CredentialRefresher
+    
+    // Vault lookups for vault:// health header values
+    secrets SecretSource
+    
+    // Per-server health endpoint overrides for external checks
+    healthOverrides map[string]externalHealthOverride
+    healthRequests  map[string]HealthRequest
+    
+    // Per-server sub-endpoints and status pages probed alongside external checks
+    healthComponents map[string]ComponentProbe
+    
+    // Per-process health configs with a tcp, exec or http probe replacing the
+    // transport's check, or headers for the http check
+    probes map[string]registry.Health
+    
+    // Command allowlist applied to exec probes
+    commandCheck func(name, command string) error
+    
     // Callbacks
     onHealthChange func(processName string, oldStatus, newStatus Status)
     onFailure      func(processName string, reason string)
@@ -93,9 +135,12 @@ type ProcessHealth struct {
     TotalFailures  int64
     
     // Timing metrics
-    MinResponseTime time.Duration
+    MinResponseTime time.Duration // zero until a check succeeds
     MaxResponseTime time.Duration
-    AvgResponseTime time.Duration
+    AvgResponseTime time.Duration // mean of the successful checks in CheckHistory
+    P50ResponseTime time.Duration
+    P95ResponseTime time.Duration
+    P99ResponseTime time.Duration
     
     // MCP specific
     MCPHandshakeComplete bool
@@ -105,6 +150,8 @@ type ProcessHealth struct {
     // History
     CheckHistory   []HealthCheck
     maxHistorySize int
+    filter         statusFilter
+    removed        bool // set under h.mu once dropped; in-flight results are discarded
 }
 
 // HealthCheck represents a single health check result
@@ -113,7 +160,7 @@ type HealthCheck struct {
     Status       Status
     ResponseTime time.Duration
     Error        string
-    CheckType    string // "http", "mcp", "log"
+    CheckType    string // "http", "mcp", "log", "tcp", "exec"
 }
 
 // ExternalProcessHealth tracks health information for an external server
@@ -132,9 +179,12 @@ type ExternalProcessHealth struct {
     TotalFailures  int64
     
     // Timing metrics
-    MinResponseTime time.Duration
+    MinResponseTime time.Duration // zero until a check succeeds
     MaxResponseTime time.Duration
-    AvgResponseTime time.Duration
+    AvgResponseTime time.Duration // mean of the successful checks in CheckHistory
+    P50ResponseTime time.Duration
+    P95ResponseTime time.Duration
+    P99ResponseTime time.Duration
     
     // External specific
     CredentialExpiry   *time.Time
@@ -146,6 +196,8 @@ type ExternalProcessHealth struct {
     // History
     CheckHistory   []HealthCheck
     maxHistorySize int
+    filter         statusFilter
+    removed        bool // set under h.mu once dropped; in-flight results are discarded
     
     // Provider-specific metrics
     ServiceMetrics map[string]interface{}
@@ -155,7 +207,7 @@ type ExternalProcessHealth struct {
 func NewHealthMonitor(checkInterval time.Duration) *HealthMonitor {
     ctx, cancel := context.WithCancel(context.Background())
     
-    return &HealthMonitor{
+    h := &HealthMonitor{
         processes:             make(map[string]*ProcessHealth),
         externalProcesses:     make(map[string]*ExternalProcessHealth),
         checkInterval:         checkInterval,
@@ -164,10 +216,23 @@ func NewHealthMonitor(checkInterval time.Duration) *HealthMonitor {
         mcpTimeout:            10 * time.Second,
         retryAttempts:         3,
         retryBackoff:          time.Second,
+        hysteresis:            DefaultHysteresis(),
+        thresholds:            DefaultThresholds(),
+        pool:                  newCheckPool(),
+        checkConcurrency:      DefaultCheckConcurrency,
         externalChecker:       NewExternalHealthChecker(),
+        insecure:              make(map[string]bool),
+        credentialRefs:        make(map[string]string),
+        healthOverrides:       make(map[string]externalHealthOverride),
+        healthRequests:        make(map[string]HealthRequest),
+        healthComponents:      make(map[string]ComponentProbe),
+        probes:                make(map[string]registry.Health),
         ctx:                   ctx,
         cancel:                cancel,
     }
+    h.client = NewHTTPClient(h.httpTimeout, nil, false)
+    h.insecureClient = NewHTTPClient(h.httpTimeout, nil, true)
+    return h
 }
 
 // SetCallbacks sets callback functions for health events
@@ -182,6 +247,237 @@ func (h *HealthMonitor) SetCallbacks(
     h.onFailure = onFailure
 }
 
+// SetRootCAs makes HTTP and external checks trust rootCAs, e.g. a private CA
+// loaded with LoadCABundle.
+func (h *HealthMonitor) SetRootCAs(rootCAs *x509.CertPool) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.rootCAs = rootCAs
+    h.client = NewHTTPClient(h.httpTimeout, rootCAs, false)
+    h.insecureClient = NewHTTPClient(h.httpTimeout, rootCAs, true)
+    h.externalChecker.SetRootCAs(rootCAs)
+}
+
+// CredentialResolver looks up an external server's credentials, preferring the
+// entry under its own ref and falling back to the provider default.
+type CredentialResolver interface {
+    Resolve(ref, provider string) (map[string]string, string, error)
+}
+
+// SetCredentialResolver makes external checks authenticate with credentials from r.
+func (h *HealthMonitor) SetCredentialResolver(r CredentialResolver) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.credentials = r
+}
+
+// CredentialRefresher renews an external server's OAuth access token and
+// saves the result where Resolve found the old credentials.
+type CredentialRefresher interface {
+    RefreshCredentials(ctx context.Context, ref, provider string) (map[string]string, error)
+}
+
+// SetCredentialRefresher lets an external check refused with 401 refresh its
+// server's token through r and try once more.
+func (h *HealthMonitor) SetCredentialRefresher(r CredentialRefresher) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.refresher = r
+}
+
+// SecretSource looks up the vault items that vault:// header values refer to.
+type SecretSource interface {
+    Retrieve(ref string) (map[string]string, error)
+}
+
+// SetSecretSource makes vault:// health header values resolve against src.
+func (h *HealthMonitor) SetSecretSource(src SecretSource) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.secrets = src
+}
+
+// SetCredentialRef records the registry CredentialRef of an external server.
+func (h *HealthMonitor) SetCredentialRef(name, ref string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.credentialRefs[name] = ref
+}
+
+type externalHealthOverride struct {
+    endpoint string
+    expect   HealthExpectation
+}
+
+// SetHealthEndpoint makes external checks of name hit endpoint and judge the
+// response by expect instead of using the provider's health endpoint. An
+// empty endpoint removes the override.
+func (h *HealthMonitor) SetHealthEndpoint(name, endpoint string, expect HealthExpectation) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if endpoint == "" {
+        delete(h.healthOverrides, name)
+        return
+    }
+    h.healthOverrides[name] = externalHealthOverride{endpoint: endpoint, expect: expect}
+}
+
+// SetHealthRequest sets the extra headers and query parameters sent with
+// name's external checks, whichever endpoint they hit.
+func (h *HealthMonitor) SetHealthRequest(name string, extra HealthRequest) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if len(extra.Headers) == 0 && len(extra.Query) == 0 {
+        delete(h.healthRequests, name)
+        return
+    }
+    h.healthRequests[name] = extra
+}
+
+// SetHealthComponents sets the sub-endpoints and status page probed with
+// name's external checks. Their results are kept in ServiceMetrics and an
+// impaired component degrades the server; an empty probe checks only the
+// main endpoint.
+func (h *HealthMonitor) SetHealthComponents(name string, probe ComponentProbe) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if probe.empty() {
+        delete(h.healthComponents, name)
+        return
+    }
+    h.healthComponents[name] = probe
+}
+
+// SetInsecureSkipVerify disables TLS certificate verification for one process.
+// This is a development escape hatch and is logged loudly whenever it is enabled.
+func (h *HealthMonitor) SetInsecureSkipVerify(name string, skip bool) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if skip {
+        log.Printf("WARNING: TLS certificate verification is DISABLED for health checks of %s; do not use this outside development", name)
+        h.insecure[name] = true
+    } else {
+        delete(h.insecure, name)
+    }
+}
+
+// httpClient returns the client used for a process's HTTP checks
+func (h *HealthMonitor) httpClient(name string) *http.Client {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    
+    if h.insecure[name] {
+        return h.insecureClient
+    }
+    return h.client
+}
+
+// SetHysteresis sets how many consecutive checks must agree before a status
+// transition is committed. Values below 1 are treated as 1.
+func (h *HealthMonitor) SetHysteresis(hy Hysteresis) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if hy.DegradeAfter < 1 {
+        hy.DegradeAfter = 1
+    }
+    if hy.RecoverAfter < 1 {
+        hy.RecoverAfter = 1
+    }
+    h.hysteresis = hy
+}
+
+// ProcessSource reports what the supervisor knows about a local process:
+// whether it is running and how often it restarted recently. The monitor
+// fills in the ping fields from its own checks.
+type ProcessSource interface {
+    ProbeInput(name string) (ProbeInput, bool)
+}
+
+// StdioAttacher is implemented by a ProcessSource that can open a session on
+// the stdin and stdout of a running stdio server, as the supervisor does. The
+// MCP probe of stdio servers goes through it.
+type StdioAttacher interface {
+    Attach(name string) (io.ReadWriteCloser, error)
+}
+
+// SetProcessSource makes the monitor judge local processes together with the
+// supervisor's state. A process the source knows but isn't running is
+// reported Down without being probed, and the source's restart count is
+// weighed against the thresholds set by SetThresholds.
+func (h *HealthMonitor) SetProcessSource(src ProcessSource) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.source = src
+}
+
+// SetCommandCheck makes exec probes run only the commands check accepts for
+// the process they probe, as the supervisor's command allowlist does. A
+// refused probe reports Down without running anything.
+func (h *HealthMonitor) SetCommandCheck(check func(name, command string) error) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.commandCheck = check
+}
+
+// SetThresholds sets the limits each local check is evaluated against in
+// addition to its own result; the worse of the two is observed.
+func (h *HealthMonitor) SetThresholds(th Thresholds) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    h.thresholds = th
+}
+
+// probeInput asks the process source about name. The source may take its own
+// locks, so this must not be called with h.mu held.
+func (h *HealthMonitor) probeInput(name string) (ProbeInput, bool) {
+    h.mu.RLock()
+    src := h.source
+    h.mu.RUnlock()
+    if src == nil {
+        return ProbeInput{}, false
+    }
+    return src.ProbeInput(name)
+}
+
+// notRunning reports whether the process source knows name and it isn't running.
+func (h *HealthMonitor) notRunning(name string) bool {
+    in, ok := h.probeInput(name)
+    return ok && !in.ProcessRunning
+}
+
+// SetCheckConcurrency sets how many health checks may run at once. It takes
+// effect when Start is called; values below 1 select the default.
+func (h *HealthMonitor) SetCheckConcurrency(n int) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if n < 1 {
+        n = DefaultCheckConcurrency
+    }
+    h.checkConcurrency = n
+}
+
+// CheckConcurrency returns the number of health checks that may run at once.
+func (h *HealthMonitor) CheckConcurrency() int {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    
+    return h.checkConcurrency
+}
+
 // SetRegistryUpdater sets the callback function for updating external server status in registry
 func (h *HealthMonitor) SetRegistryUpdater(updater func(string, registry.ExternalStatus)) {
     h.mu.Lock()
@@ -203,46 +499,66 @@ func (h *HealthMonitor) AddProcess(name, transport, httpURL, logPath string) {
         Status:         Down,
         CheckHistory:   make([]HealthCheck, 0),
         maxHistorySize: 100,
-        MinResponseTime: time.Hour, // Initialize to a large value
     }
 }
 
-// AddExternalProcess adds an external server to be monitored
-func (h *HealthMonitor) AddExternalProcess(name, provider, apiEndpoint, authType string) {
+// AddExternalProcess adds an external server to be monitored.
+// credentialExpiry is when its access token expires, nil when unknown or
+// for credentials that don't expire; checks update it from the vault.
+func (h *HealthMonitor) AddExternalProcess(name, provider, apiEndpoint, authType string, credentialExpiry *time.Time) {
     h.mu.Lock()
     defer h.mu.Unlock()
     
     h.externalProcesses[name] = &ExternalProcessHealth{
-        Name:            name,
-        Provider:        provider,
-        APIEndpoint:     apiEndpoint,
-        AuthType:        authType,
-        Status:          Down,
-        CheckHistory:    make([]HealthCheck, 0),
-        maxHistorySize:  100,
-        MinResponseTime: time.Hour, // Initialize to a large value
-        ServiceMetrics:  make(map[string]interface{}),
+        Name:              name,
+        Provider:          provider,
+        APIEndpoint:       apiEndpoint,
+        AuthType:          authType,
+        CredentialExpiry:  credentialExpiry,
+        CredentialWarning: credentialExpiring(credentialExpiry),
+        Status:            Down,
+        CheckHistory:      make([]HealthCheck, 0),
+        maxHistorySize:    100,
+        ServiceMetrics:    make(map[string]interface{}),
     }
 }
 
-// RemoveProcess removes a process from monitoring
+// RemoveProcess removes a process from monitoring. A check already running
+// for it completes, but its result is dropped and fires no callbacks.
 func (h *HealthMonitor) RemoveProcess(name string) {
     h.mu.Lock()
     defer h.mu.Unlock()
     
+    if ph, ok := h.processes[name]; ok {
+        ph.removed = true
+        ph.CheckHistory = nil
+    }
     delete(h.processes, name)
+    delete(h.insecure, name)
+    delete(h.probes, name)
 }
 
-// RemoveExternalProcess removes an external server from monitoring
+// RemoveExternalProcess removes an external server from monitoring, dropping
+// the result of any check still in flight like RemoveProcess.
 func (h *HealthMonitor) RemoveExternalProcess(name string) {
     h.mu.Lock()
     defer h.mu.Unlock()
     
+    if ph, ok := h.externalProcesses[name]; ok {
+        ph.removed = true
+        ph.CheckHistory = nil
+    }
     delete(h.externalProcesses, name)
+    delete(h.insecure, name)
+    delete(h.credentialRefs, name)
+    delete(h.healthOverrides, name)
+    delete(h.healthRequests, name)
+    delete(h.healthComponents, name)
 }
 
 // Start begins health monitoring
 func (h *HealthMonitor) Start() {
+    h.pool.start(h.ctx, h.CheckConcurrency(), &h.wg)
     h.wg.Add(2)
     go h.monitorLoop()
     go h.externalMonitorLoop()
@@ -257,15 +573,20 @@ func (h *HealthMonitor) Stop() {
 // GetProcessHealth returns the health information for a process
 func (h *HealthMonitor) GetProcessHealth(name string) (*ProcessHealth, bool) {
     h.mu.RLock()
-    defer h.mu.RUnlock()
-    
     ph, exists := h.processes[name]
     if !exists {
+        h.mu.RUnlock()
         return nil, false
     }
     
     // Return a copy to avoid race conditions
     copy := *ph
+    h.mu.RUnlock()
+    
+    // Don't wait for the next check to notice a process has stopped
+    if h.notRunning(name) {
+        copy.Status = Down
+    }
     return &copy, true
 }
 
@@ -287,14 +608,19 @@ func (h *HealthMonitor) GetExternalProcessHealth(name string) (*ExternalProcessH
 // GetAllHealth returns health information for all monitored processes
 func (h *HealthMonitor) GetAllHealth() map[string]*ProcessHealth {
     h.mu.RLock()
-    defer h.mu.RUnlock()
     
     result := make(map[string]*ProcessHealth)
     for name, ph := range h.processes {
         copy := *ph
         result[name] = &copy
     }
+    h.mu.RUnlock()
     
+    for name, ph := range result {
+        if h.notRunning(name) {
+            ph.Status = Down
+        }
+    }
     return result
 }
 
@@ -338,21 +664,20 @@ func (h *HealthMonitor) performAllHealthChecks() {
     }
     h.mu.RUnlock()
     
-    // Perform checks concurrently
-    var wg sync.WaitGroup
+    // Checks still running from an earlier tick are not queued again
     for _, ph := range processes {
-        wg.Add(1)
-        go func(ph *ProcessHealth) {
-            defer wg.Done()
-            h.performHealthCheck(ph)
-        }(ph)
+        ph := ph
+        h.pool.submit("local:"+ph.Name, func() { h.performHealthCheck(ph) })
     }
-    
-    wg.Wait()
 }
 
 // performHealthCheck performs a health check on a single process
 func (h *HealthMonitor) performHealthCheck(ph *ProcessHealth) {
+    if h.notRunning(ph.Name) {
+        h.markStopped(ph)
+        return
+    }
+    
     checkStart := time.Now()
     
     var status Status
@@ -360,27 +685,42 @@ func (h *HealthMonitor) performHealthCheck(ph *ProcessHealth) {
     var responseTime time.Duration
     var checkType string
     
-    // Determine check type based on transport
-    switch ph.Transport {
-    case "http":
+    // A configured probe takes precedence, otherwise the transport decides
+    probe, hasProbe := h.probeFor(ph.Name)
+    switch {
+    case hasProbe && probe.Probe == registry.ProbeTCP:
+        status, responseTime, err = h.performTCPCheck(probe)
+        checkType = "tcp"
+    case hasProbe && probe.Probe == registry.ProbeExec:
+        status, responseTime, err = h.performExecCheck(ph.Name, probe)
+        checkType = "exec"
+    case hasProbe && probe.Probe == registry.ProbeHTTP:
+        status, responseTime, err = h.performProbeHTTPCheck(ph, probe)
+        checkType = "http"
+    case ph.Transport == "http":
         if ph.HTTPURL != "" {
             status, responseTime, err = h.performHTTPCheck(ph)
             checkType = "http"
+            // A supervised server is only Ready once it has answered initialize
+            if _, known := h.probeInput(ph.Name); known && status == Ready && !ph.MCPHandshakeComplete {
+                status, responseTime, err = h.performMCPProbe(ph)
+                checkType = "mcp-handshake"
+            }
         } else {
             status = Down
             err = fmt.Errorf("HTTP transport but no URL configured")
             checkType = "config"
         }
-    case "stdio":
-        // For stdio transport, check MCP handshake and log activity
+    case ph.Transport == "stdio":
+        // For stdio transport, complete the MCP handshake, then watch log activity
         if !ph.MCPHandshakeComplete {
-            status, err = h.checkMCPHandshake(ph)
+            status, responseTime, err = h.performMCPProbe(ph)
             checkType = "mcp-handshake"
         } else {
             status, err = h.checkLogActivity(ph)
             checkType = "log"
+            responseTime = time.Since(checkStart)
         }
-        responseTime = time.Since(checkStart)
     default:
         status = Down
         err = fmt.Errorf("unsupported transport: %s", ph.Transport)
@@ -391,39 +731,101 @@ func (h *HealthMonitor) performHealthCheck(ph *ProcessHealth) {
     h.updateProcessHealth(ph, status, responseTime, err, checkType)
 }
 
-// performHTTPCheck performs an HTTP health check
+// performHTTPCheck performs an HTTP health check. A 2xx or 3xx is healthy;
+// after the retries a server that answers otherwise is degraded and one that
+// doesn't answer is down.
 func (h *HealthMonitor) performHTTPCheck(ph *ProcessHealth) (Status, time.Duration, error) {
-    client := &http.Client{Timeout: h.httpTimeout}
+    headers, err := h.checkHeaders(ph.Name)
+    if err != nil {
+        return Down, 0, err
+    }
     
-    for attempt := 0; attempt < h.retryAttempts; attempt++ {
-        if attempt > 0 {
-            time.Sleep(h.retryBackoff)
-        }
-        
-        start := time.Now()
-        resp, err := client.Get(ph.HTTPURL)
-        responseTime := time.Since(start)
-        
-        if err != nil {
-            if attempt == h.retryAttempts-1 {
-                return Down, responseTime, fmt.Errorf("HTTP check failed after %d attempts: %w", h.retryAttempts, err)
-            }
-            continue
-        }
-        
-        resp.Body.Close()
-        
-        // Consider 2xx and 3xx as healthy
-        if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-            return Ready, responseTime, nil
+    res := HTTPProbe{
+        Client:        h.httpClient(ph.Name),
+        Attempts:      h.retryAttempts,
+        Backoff:       h.retryBackoff,
+        Header:        headers,
+        AllowRedirect: true,
+    }.Check(h.ctx, ph.HTTPURL)
+    switch {
+    case res.Healthy():
+        return Ready, res.ResponseTime, nil
+    case res.Attempts == 0:
+        return Down, 0, fmt.Errorf("HTTP check: %w", res.Err)
+    case res.StatusCode == 0:
+        return Down, res.ResponseTime, fmt.Errorf("HTTP check failed after %d attempts: %w", res.Attempts, res.Err)
+    default:
+        return Degraded, res.ResponseTime, fmt.Errorf("HTTP check returned status %d", res.StatusCode)
+    }
+}
+
+// performMCPProbe runs the MCP handshake against the process: over its stdin
+// and stdout for stdio, or as a POST to its URL for http. A result carrying a
+// protocolVersion and capabilities completes the handshake and is recorded on
+// ph. Both exchanges are bounded by h.mcpTimeout. A stdio process that can't
+// be attached to, such as one the supervisor didn't start, falls back to
+// looking for the handshake in its log.
+func (h *HealthMonitor) performMCPProbe(ph *ProcessHealth) (Status, time.Duration, error) {
+    ctx, cancel := context.WithTimeout(h.ctx, h.mcpTimeout)
+    defer cancel()
+    start := time.Now()
+    
+    var result *initializeResult
+    var err error
+    if ph.Transport == "stdio" {
+        conn, attachErr := h.attachStdio(ph.Name)
+        if attachErr != nil {
+            status, err := h.checkMCPHandshake(ph)
+            return status, time.Since(start), err
         }
-        
-        if attempt == h.retryAttempts-1 {
-            return Degraded, responseTime, fmt.Errorf("HTTP check returned status %d", resp.StatusCode)
+        result, err = stdioHandshake(ctx, conn)
+    } else {
+        headers, headerErr := h.checkHeaders(ph.Name)
+        if headerErr != nil {
+            return Down, 0, headerErr
         }
+        t := mcpHTTP{client: h.httpClient(ph.Name), header: headers}
+        var session string
+        session, result, err = t.handshake(ctx, ph.HTTPURL)
+        t.closeSession(ctx, ph.HTTPURL, session)
+    }
+    elapsed := time.Since(start)
+    if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+        return Degraded, elapsed, fmt.Errorf("MCP handshake timed out after %s", h.mcpTimeout)
+    }
+    if err != nil {
+        return Degraded, elapsed, fmt.Errorf("MCP handshake: %w", err)
+    }
+    if result.ProtocolVersion == "" || result.Capabilities == nil {
+        return Degraded, elapsed, fmt.Errorf("MCP handshake: initialize result has no protocolVersion or capabilities")
     }
     
-    return Down, 0, fmt.Errorf("HTTP check exhausted retries")
+    h.mu.Lock()
+    ph.MCPHandshakeComplete = true
+    ph.MCPProtocolVersion = result.ProtocolVersion
+    ph.MCPCapabilities = result.Capabilities
+    h.mu.Unlock()
+    return Ready, elapsed, nil
+}
+
+// attachStdio opens a session on the stdio pipes of the named process.
+func (h *HealthMonitor) attachStdio(name string) (io.ReadWriteCloser, error) {
+    h.mu.RLock()
+    att, ok := h.source.(StdioAttacher)
+    h.mu.RUnlock()
+    if !ok {
+        return nil, fmt.Errorf("process source cannot attach to stdio")
+    }
+    return att.Attach(name)
+}
+
+// stdioHandshake sends initialize over conn, waits for its result and sends
+// notifications/initialized. Other messages from the server are skipped. conn
+// is closed on return, or as soon as ctx is done to unblock the read.
+func stdioHandshake(ctx context.Context, conn io.ReadWriteCloser) (*initializeResult, error) {
+    c, done := openStdio(ctx, conn)
+    defer done()
+    return c.handshake()
 }
 
 // checkMCPHandshake checks if MCP handshake is complete by looking for initialization messages in logs
@@ -517,9 +919,15 @@ func (h *HealthMonitor) logContainsAny(logPath string, patterns []string) (bool,
 
 // updateProcessHealth updates the health status of a process
 func (h *HealthMonitor) updateProcessHealth(ph *ProcessHealth, status Status, responseTime time.Duration, err error, checkType string) {
+    in, known := h.probeInput(ph.Name)
+    
     h.mu.Lock()
     defer h.mu.Unlock()
     
+    if ph.removed {
+        return
+    }
+    
     oldStatus := ph.Status
     ph.LastCheck = time.Now()
     ph.TotalChecks++
@@ -542,19 +950,12 @@ func (h *HealthMonitor) updateProcessHealth(ph *ProcessHealth, status Status, re
         
         // Update response time metrics
         if responseTime > 0 {
-            if responseTime < ph.MinResponseTime {
+            if ph.MinResponseTime == 0 || responseTime < ph.MinResponseTime {
                 ph.MinResponseTime = responseTime
             }
             if responseTime > ph.MaxResponseTime {
                 ph.MaxResponseTime = responseTime
             }
-            
-            // Simple moving average for now
-            if ph.AvgResponseTime == 0 {
-                ph.AvgResponseTime = responseTime
-            } else {
-                ph.AvgResponseTime = (ph.AvgResponseTime + responseTime) / 2
-            }
         }
     }
     
@@ -563,13 +964,26 @@ func (h *HealthMonitor) updateProcessHealth(ph *ProcessHealth, status Status, re
     if len(ph.CheckHistory) > ph.maxHistorySize {
         ph.CheckHistory = ph.CheckHistory[1:] // Remove oldest entry
     }
+    lat := windowLatency(ph.CheckHistory)
+    ph.AvgResponseTime, ph.P50ResponseTime, ph.P95ResponseTime, ph.P99ResponseTime = lat.mean, lat.p50, lat.p95, lat.p99
+    
+    // Missed pings, slow responses and recent restarts can make a check that
+    // passed on its own count as degraded or down
+    if known {
+        in.MissedPings = ph.ConsecutiveFails
+        in.LastPingMs = int(responseTime.Milliseconds())
+        if evaluated := Evaluate(in, h.thresholds); severity(evaluated) > severity(status) {
+            status = evaluated
+        }
+    }
     
-    ph.Status = status
+    // Commit the observed status only once it has persisted long enough
+    ph.Status = ph.filter.observe(oldStatus, status, h.hysteresis)
     
     // Trigger callbacks if status changed
-    if oldStatus != status {
+    if oldStatus != ph.Status {
         if h.onHealthChange != nil {
-            go h.onHealthChange(ph.Name, oldStatus, status)
+            go h.onHealthChange(ph.Name, oldStatus, ph.Status)
         }
     }
     
@@ -583,38 +997,107 @@ func (h *HealthMonitor) updateProcessHealth(ph *ProcessHealth, status Status, re
     }
 }
 
+// markStopped reports a process the supervisor isn't running as Down. This
+// is not a failed check: it skips hysteresis and never fires the failure
+// callback, which would restart a server that was stopped on purpose.
+func (h *HealthMonitor) markStopped(ph *ProcessHealth) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if ph.removed {
+        return
+    }
+    
+    oldStatus := ph.Status
+    ph.Status = Down
+    ph.ConsecutiveFails = 0
+    ph.MCPHandshakeComplete = false // the next run has to complete it again
+    ph.filter = statusFilter{}
+    
+    if oldStatus != Down && h.onHealthChange != nil {
+        go h.onHealthChange(ph.Name, oldStatus, Down)
+    }
+}
+
+// stoppedProcesses returns the local processes the source reports as not running.
+func (h *HealthMonitor) stoppedProcesses() map[string]bool {
+    h.mu.RLock()
+    names := make([]string, 0, len(h.processes))
+    for name := range h.processes {
+        names = append(names, name)
+    }
+    h.mu.RUnlock()
+    
+    stopped := make(map[string]bool)
+    for _, name := range names {
+        if h.notRunning(name) {
+            stopped[name] = true
+        }
+    }
+    return stopped
+}
+
 // GetHealthSummary returns a summary of health status for all processes
 func (h *HealthMonitor) GetHealthSummary() map[string]interface{} {
+    return h.HealthSummaryPage(0, 0)
+}
+
+// HealthSummaryPage returns the summary with its process rows limited to a
+// page. Rows run local processes first, then external ones, each sorted by
+// name; the page starts at offset and holds at most limit rows, or all the
+// rest when limit is 0. The counts always cover every process, and only the
+// rows on the page are built.
+func (h *HealthMonitor) HealthSummaryPage(offset, limit int) map[string]interface{} {
+    stopped := h.stoppedProcesses()
+    
     h.mu.RLock()
     defer h.mu.RUnlock()
     
     totalProcesses := len(h.processes) + len(h.externalProcesses)
-    summary := map[string]interface{}{
-        "totalProcesses": totalProcesses,
-        "healthy":        0,
-        "degraded":       0,
-        "down":           0,
-        "processes":      make([]map[string]interface{}, 0, totalProcesses),
-        "external": map[string]interface{}{
-            "totalExternal": len(h.externalProcesses),
-            "processes":     make([]map[string]interface{}, 0, len(h.externalProcesses)),
-        },
-    }
-    
-    // Count local processes
-    for _, ph := range h.processes {
-        switch ph.Status {
+    healthy, degraded, down := 0, 0, 0
+    count := func(status Status) {
+        switch status {
         case Ready:
-            summary["healthy"] = summary["healthy"].(int) + 1
+            healthy++
         case Degraded:
-            summary["degraded"] = summary["degraded"].(int) + 1
+            degraded++
         case Down:
-            summary["down"] = summary["down"].(int) + 1
+            down++
         }
-        
-        processInfo := map[string]interface{}{
+    }
+    
+    localNames := make([]string, 0, len(h.processes))
+    for name, ph := range h.processes {
+        status := ph.Status
+        if stopped[name] {
+            status = Down
+        }
+        count(status)
+        localNames = append(localNames, name)
+    }
+    externalNames := make([]string, 0, len(h.externalProcesses))
+    for name, ph := range h.externalProcesses {
+        count(ph.Status)
+        externalNames = append(externalNames, name)
+    }
+    sort.Strings(localNames)
+    sort.Strings(externalNames)
+    
+    // The page is a window over localNames followed by externalNames
+    from, to := pageBounds(totalProcesses, offset, limit)
+    localFrom, localTo := min(from, len(localNames)), min(to, len(localNames))
+    externalFrom, externalTo := max(from-len(localNames), 0), max(to-len(localNames), 0)
+    
+    processes := make([]map[string]interface{}, 0, localTo-localFrom)
+    for _, name := range localNames[localFrom:localTo] {
+        ph := h.processes[name]
+        status := ph.Status
+        if stopped[name] {
+            status = Down
+        }
+        processes = append(processes, map[string]interface{}{
             "name":              ph.Name,
-            "status":            string(ph.Status),
+            "status":            string(status),
             "transport":         ph.Transport,
             "lastCheck":         ph.LastCheck,
             "lastSuccess":       ph.LastSuccess,
@@ -622,24 +1105,17 @@ func (h *HealthMonitor) GetHealthSummary() map[string]interface{} {
             "totalChecks":       ph.TotalChecks,
             "totalFailures":     ph.TotalFailures,
             "avgResponseTime":   ph.AvgResponseTime.Milliseconds(),
+            "p50ResponseTime":   ph.P50ResponseTime.Milliseconds(),
+            "p95ResponseTime":   ph.P95ResponseTime.Milliseconds(),
+            "p99ResponseTime":   ph.P99ResponseTime.Milliseconds(),
             "mcpHandshakeComplete": ph.MCPHandshakeComplete,
-        }
-        
-        summary["processes"] = append(summary["processes"].([]map[string]interface{}), processInfo)
+        })
     }
     
-    // Count external processes
-    for _, ph := range h.externalProcesses {
-        switch ph.Status {
-        case Ready:
-            summary["healthy"] = summary["healthy"].(int) + 1
-        case Degraded:
-            summary["degraded"] = summary["degraded"].(int) + 1
-        case Down:
-            summary["down"] = summary["down"].(int) + 1
-        }
-        
-        processInfo := map[string]interface{}{
+    external := make([]map[string]interface{}, 0, externalTo-externalFrom)
+    for _, name := range externalNames[externalFrom:externalTo] {
+        ph := h.externalProcesses[name]
+        external = append(external, map[string]interface{}{
             "name":               ph.Name,
             "status":             string(ph.Status),
             "provider":           ph.Provider,
@@ -650,18 +1126,58 @@ func (h *HealthMonitor) GetHealthSummary() map[string]interface{} {
             "totalChecks":        ph.TotalChecks,
             "totalFailures":      ph.TotalFailures,
             "avgResponseTime":    ph.AvgResponseTime.Milliseconds(),
+            "p50ResponseTime":    ph.P50ResponseTime.Milliseconds(),
+            "p95ResponseTime":    ph.P95ResponseTime.Milliseconds(),
+            "p99ResponseTime":    ph.P99ResponseTime.Milliseconds(),
             "credentialWarning":  ph.CredentialWarning,
             "rateLimited":        ph.RateLimited,
             "lastErrorCode":      ph.LastErrorCode,
-        }
-        
-        summary["external"].(map[string]interface{})["processes"] = append(
-            summary["external"].(map[string]interface{})["processes"].([]map[string]interface{}), 
-            processInfo,
-        )
+        })
     }
     
-    return summary
+    return map[string]interface{}{
+        "totalProcesses": totalProcesses,
+        "healthy":        healthy,
+        "degraded":       degraded,
+        "down":           down,
+        "checkConcurrency": h.checkConcurrency,
+        "processes":      processes,
+        "external": map[string]interface{}{
+            "totalExternal": len(h.externalProcesses),
+            "processes":     external,
+        },
+    }
+}
+
+// HealthStatuses returns the current status of every local and external
+// process by name, without copying their health records.
+func (h *HealthMonitor) HealthStatuses() map[string]Status {
+    stopped := h.stoppedProcesses()
+    
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    
+    statuses := make(map[string]Status, len(h.processes)+len(h.externalProcesses))
+    for name, ph := range h.processes {
+        statuses[name] = ph.Status
+        if stopped[name] {
+            statuses[name] = Down
+        }
+    }
+    for name, ph := range h.externalProcesses {
+        statuses[name] = ph.Status
+    }
+    return statuses
+}
+
+// pageBounds returns the slice bounds of the page of total rows starting at
+// offset with at most limit rows, 0 meaning no limit.
+func pageBounds(total, offset, limit int) (int, int) {
+    from := min(max(offset, 0), total)
+    if limit <= 0 || limit > total-from {
+        return from, total
+    }
+    return from, from + limit
 }
 
 // externalMonitorLoop is the monitoring loop for external servers
@@ -690,17 +1206,10 @@ func (h *HealthMonitor) performAllExternalHealthChecks() {
     }
     h.mu.RUnlock()
     
-    // Perform checks concurrently
-    var wg sync.WaitGroup
     for _, ph := range processes {
-        wg.Add(1)
-        go func(ph *ExternalProcessHealth) {
-            defer wg.Done()
-            h.performExternalHealthCheck(ph)
-        }(ph)
+        ph := ph
+        h.pool.submit("external:"+ph.Name, func() { h.performExternalHealthCheck(ph) })
     }
-    
-    wg.Wait()
 }
 
 // performExternalHealthCheck performs a health check on a single external server
@@ -710,8 +1219,19 @@ func (h *HealthMonitor) performExternalHealthCheck(ph *ExternalProcessHealth) {
     ctx, cancel := context.WithTimeout(h.ctx, h.httpTimeout)
     defer cancel()
     
-    // Get API key from provider endpoint mapping or use configured endpoint
+    // Prefer the server's own health endpoint, then the configured API
+    // endpoint, then the provider's known health endpoint
+    h.mu.RLock()
+    override, hasOverride := h.healthOverrides[ph.Name]
+    extra := h.healthRequests[ph.Name]
+    probe, hasComponents := h.healthComponents[ph.Name]
+    h.mu.RUnlock()
     endpoint := ph.APIEndpoint
+    var expect *HealthExpectation
+    if hasOverride {
+        endpoint = override.endpoint
+        expect = &override.expect
+    }
     if endpoint == "" {
         if providerEndpoint, exists := GetProviderEndpoint(ph.Provider); exists {
             endpoint = providerEndpoint
@@ -722,8 +1242,52 @@ func (h *HealthMonitor) performExternalHealthCheck(ph *ExternalProcessHealth) {
     }
     
     // Perform health check using the external checker
-    // For now, we'll use empty API key - this should be retrieved from credential store
-    health, err := h.externalChecker.CheckHealth(ctx, endpoint, "")
+    h.mu.RLock()
+    checker := h.externalChecker
+    if h.insecure[ph.Name] {
+        checker = checker.Insecure()
+    }
+    resolver := h.credentials
+    refresher := h.refresher
+    ref := h.credentialRefs[ph.Name]
+    h.mu.RUnlock()
+    
+    credentials := map[string]string{}
+    canRefresh := false
+    var credErr error
+    if resolver != nil {
+        creds, _, err := resolver.Resolve(ref, ph.Provider)
+        if err == nil {
+            credentials = checker.normalizeCredentials(ph.Provider, creds)
+            canRefresh = refresher != nil && creds["refresh_token"] != ""
+            h.noteCredentialExpiry(ph, creds)
+        }
+        credErr = err
+    }
+    var components []ComponentResult
+    var componentsDone chan struct{}
+    if hasComponents {
+        componentsDone = make(chan struct{})
+        go func() {
+            defer close(componentsDone)
+            components = checker.probeComponents(ctx, probe, credentials, extra)
+        }()
+    }
+    health, err := checker.CheckHealthRequest(ctx, endpoint, credentials, extra, expect)
+    DetectExpiredCredential(ph.Provider, health)
+    var refreshErr error
+    if err == nil && health.CredentialExpired && canRefresh {
+        // The access token has likely expired; renew it and check once more
+        creds, rerr := refresher.RefreshCredentials(ctx, ref, ph.Provider)
+        if rerr != nil {
+            refreshErr = rerr
+        } else {
+            credentials = checker.normalizeCredentials(ph.Provider, creds)
+            h.noteCredentialExpiry(ph, creds)
+            health, err = checker.CheckHealthRequest(ctx, endpoint, credentials, extra, expect)
+            DetectExpiredCredential(ph.Provider, health)
+        }
+    }
     
     var status Status
     var responseTime time.Duration = time.Since(checkStart)
@@ -760,17 +1324,65 @@ func (h *HealthMonitor) performExternalHealthCheck(ph *ExternalProcessHealth) {
         if health.Error != "" {
             checkErr = fmt.Errorf(health.Error)
         }
+        if refreshErr != nil {
+            checkErr = fmt.Errorf("%s (token refresh failed: %v)", health.Error, refreshErr)
+        }
+        // The check went out unauthenticated; say why it was refused
+        if credErr != nil && (health.StatusCode == http.StatusUnauthorized || health.StatusCode == http.StatusForbidden) {
+            checkErr = fmt.Errorf("%s (no credentials: %v)", health.Error, credErr)
+        }
+    }
+    
+    if hasComponents {
+        <-componentsDone
+        var summary string
+        status, summary = compositeStatus(status, components)
+        if summary != "" && checkErr == nil {
+            checkErr = fmt.Errorf("%s", summary)
+        }
+        h.setServiceMetrics(ph, map[string]interface{}{
+            "components": components,
+            "summary":    summary,
+        })
     }
     
     // Update process health
     h.updateExternalProcessHealth(ph, status, responseTime, checkErr, "external")
 }
 
+// noteCredentialExpiry takes ph's credential expiry from creds when they
+// record one, keeping the value it was added with otherwise.
+func (h *HealthMonitor) noteCredentialExpiry(ph *ExternalProcessHealth, creds map[string]string) {
+    expiry, ok := recordedExpiry(creds)
+    if !ok {
+        return
+    }
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    ph.CredentialExpiry = expiry
+}
+
+// setServiceMetrics replaces ph's provider-specific metrics. The map is
+// never modified in place, so copies handed out earlier stay consistent.
+func (h *HealthMonitor) setServiceMetrics(ph *ExternalProcessHealth, metrics map[string]interface{}) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    
+    if !ph.removed {
+        ph.ServiceMetrics = metrics
+    }
+}
+
 // updateExternalProcessHealth updates the health status of an external server
 func (h *HealthMonitor) updateExternalProcessHealth(ph *ExternalProcessHealth, status Status, responseTime time.Duration, err error, checkType string) {
     h.mu.Lock()
     defer h.mu.Unlock()
     
+    if ph.removed {
+        return
+    }
+    
     oldStatus := ph.Status
     ph.LastCheck = time.Now()
     ph.TotalChecks++
@@ -793,19 +1405,12 @@ func (h *HealthMonitor) updateExternalProcessHealth(ph *ExternalProcessHealth, s
         
         // Update response time metrics
         if responseTime > 0 {
-            if responseTime < ph.MinResponseTime {
+            if ph.MinResponseTime == 0 || responseTime < ph.MinResponseTime {
                 ph.MinResponseTime = responseTime
             }
             if responseTime > ph.MaxResponseTime {
                 ph.MaxResponseTime = responseTime
             }
-            
-            // Simple moving average for now
-            if ph.AvgResponseTime == 0 {
-                ph.AvgResponseTime = responseTime
-            } else {
-                ph.AvgResponseTime = (ph.AvgResponseTime + responseTime) / 2
-            }
         }
     }
     
@@ -814,18 +1419,19 @@ func (h *HealthMonitor) updateExternalProcessHealth(ph *ExternalProcessHealth, s
     if len(ph.CheckHistory) > ph.maxHistorySize {
         ph.CheckHistory = ph.CheckHistory[1:] // Remove oldest entry
     }
+    lat := windowLatency(ph.CheckHistory)
+    ph.AvgResponseTime, ph.P50ResponseTime, ph.P95ResponseTime, ph.P99ResponseTime = lat.mean, lat.p50, lat.p95, lat.p99
     
-    ph.Status = status
+    // Commit the observed status only once it has persisted long enough
+    ph.Status = ph.filter.observe(oldStatus, status, h.hysteresis)
     
     // Check for credential expiry warnings
-    if ph.CredentialExpiry != nil && time.Until(*ph.CredentialExpiry) < 7*24*time.Hour {
-        ph.CredentialWarning = true
-    }
+    ph.CredentialWarning = credentialExpiring(ph.CredentialExpiry)
     
     // Trigger callbacks if status changed
-    if oldStatus != status {
+    if oldStatus != ph.Status {
         if h.onHealthChange != nil {
-            go h.onHealthChange(ph.Name, oldStatus, status)
+            go h.onHealthChange(ph.Name, oldStatus, ph.Status)
         }
     }
     
@@ -841,7 +1447,7 @@ func (h *HealthMonitor) updateExternalProcessHealth(ph *ExternalProcessHealth, s
     // Update registry with external server status
     if h.registryUpdater != nil {
         registryStatus := registry.ExternalStatus{
-            State:        h.convertStatusToRegistryState(status),
+            State:        h.convertStatusToRegistryState(ph.Status),
             Message:      h.createStatusMessage(ph, err),
             LastChecked:  &ph.LastCheck,
             ResponseTime: func() *int64 { rt := responseTime.Milliseconds(); return &rt }(),
@@ -850,6 +1456,25 @@ func (h *HealthMonitor) updateExternalProcessHealth(ph *ExternalProcessHealth, s
     }
 }
 
+// credentialExpiring reports whether a credential expires within a week.
+func credentialExpiring(expiry *time.Time) bool {
+    return expiry != nil && time.Until(*expiry) < 7*24*time.Hour
+}
+
+// recordedExpiry reads the expires_at time kept with OAuth2 credentials in
+// the vault. ok is false when the credentials have no such key, and expiry is
+// nil when it is empty, i.e. the current token's expiry is unknown.
+func recordedExpiry(creds map[string]string) (expiry *time.Time, ok bool) {
+    v, ok := creds["expires_at"]
+    if !ok {
+        return nil, false
+    }
+    if t, err := time.Parse(time.RFC3339, v); err == nil {
+        return &t, true
+    }
+    return nil, true
+}
+
 // convertStatusToRegistryState converts health Status to registry state
 func (h *HealthMonitor) convertStatusToRegistryState(status Status) string {
     switch status {
@@ -0,0 +1,227 @@
+package health
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestUpdateProcessHealthHysteresis(t *testing.T) {
+    h := NewHealthMonitor(0)
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var changes []Status
+    h.SetCallbacks(func(_ string, _, newStatus Status) {
+        defer wg.Done()
+        mu.Lock()
+        changes = append(changes, newStatus)
+        mu.Unlock()
+    }, nil)
+    h.AddProcess("srv", "stdio", "", "")
+    ph := h.processes["srv"]
+    
+    observe := func(s Status, wantStatus Status, wantChange bool) {
+        t.Helper()
+        if wantChange { wg.Add(1) }
+        h.updateProcessHealth(ph, s, 0, nil, "test")
+        wg.Wait()
+        if ph.Status != wantStatus { t.Fatalf("after %s: status %s, want %s", s, ph.Status, wantStatus) }
+    }
+    
+    // Recovery needs two agreeing checks, so a single Ready blip is ignored
+    observe(Ready, Down, false)
+    observe(Down, Down, false)
+    observe(Ready, Down, false)
+    observe(Ready, Ready, true)
+    
+    // Degradation commits immediately by default
+    observe(Degraded, Degraded, true)
+    
+    // With a stricter degrade threshold a single bad sample does not fire
+    h.SetHysteresis(Hysteresis{DegradeAfter: 2, RecoverAfter: 2})
+    observe(Ready, Degraded, false)
+    observe(Ready, Ready, true)
+    observe(Down, Ready, false)
+    observe(Ready, Ready, false)
+    
+    mu.Lock()
+    defer mu.Unlock()
+    want := []Status{Ready, Degraded, Ready}
+    if len(changes) != len(want) { t.Fatalf("changes %v, want %v", changes, want) }
+    for i := range want {
+        if changes[i] != want[i] { t.Fatalf("changes %v, want %v", changes, want) }
+    }
+}
+
+func TestRemoveProcessDropsInFlightCheck(t *testing.T) {
+    entered := make(chan struct{})
+    release := make(chan struct{})
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        close(entered)
+        <-release
+        w.WriteHeader(http.StatusInternalServerError)
+    }))
+    defer srv.Close()
+    
+    h := NewHealthMonitor(0)
+    h.retryAttempts = 1
+    fired := make(chan string, 2)
+    h.SetCallbacks(
+        func(name string, _, _ Status) { fired <- "change:" + name },
+        func(name, _ string) { fired <- "failure:" + name },
+    )
+    h.AddProcess("srv", "http", srv.URL, "")
+    ph := h.processes["srv"]
+    // Either callback would fire for a failed check on a live process
+    ph.Status = Ready
+    ph.ConsecutiveFails = 5
+    
+    done := make(chan struct{})
+    go func() {
+        h.performHealthCheck(ph)
+        close(done)
+    }()
+    <-entered
+    h.RemoveProcess("srv")
+    close(release)
+    <-done
+    
+    select {
+    case cb := <-fired:
+        t.Fatalf("callback %s fired for a removed process", cb)
+    case <-time.After(100 * time.Millisecond):
+    }
+    if ph.TotalChecks != 0 || len(ph.CheckHistory) != 0 || ph.Status != Ready {
+        t.Fatalf("removed process was updated: %+v", ph)
+    }
+    if _, ok := h.GetProcessHealth("srv"); ok {
+        t.Fatal("removed process is still monitored")
+    }
+}
+
+// pipeSource is a ProcessSource whose processes are running stdio servers
+// played by serve over an in-memory pipe.
+type pipeSource struct {
+    serve func(conn net.Conn)
+}
+
+func (p pipeSource) ProbeInput(string) (ProbeInput, bool) {
+    return ProbeInput{ProcessRunning: true}, true
+}
+
+func (p pipeSource) Attach(string) (io.ReadWriteCloser, error) {
+    client, server := net.Pipe()
+    go func() {
+        defer server.Close()
+        p.serve(server)
+    }()
+    return client, nil
+}
+
+// runningSource knows every process as running but can't attach to it.
+type runningSource struct{}
+
+func (runningSource) ProbeInput(string) (ProbeInput, bool) {
+    return ProbeInput{ProcessRunning: true}, true
+}
+
+func TestMCPProbeStdio(t *testing.T) {
+    logPath := filepath.Join(t.TempDir(), "srv.log")
+    // The words the log scraper looks for, without a handshake behind them
+    if err := os.WriteFile(logPath, []byte("waiting until initialized\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    
+    notified := make(chan string, 1)
+    h := NewHealthMonitor(0)
+    h.mcpTimeout = 100 * time.Millisecond
+    h.SetProcessSource(pipeSource{serve: func(conn net.Conn) {
+        r := bufio.NewReader(conn)
+        line, err := r.ReadString('\n')
+        if err != nil || !strings.Contains(line, `"method":"initialize"`) {
+            return
+        }
+        fmt.Fprintln(conn, `{"jsonrpc":"2.0","method":"notifications/message","params":{}}`)
+        fmt.Fprintln(conn, `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26","capabilities":{"tools":{}}}}`)
+        line, _ = r.ReadString('\n')
+        notified <- line
+    }})
+    h.AddProcess("srv", "stdio", "", logPath)
+    ph := h.processes["srv"]
+    
+    status, _, err := h.performMCPProbe(ph)
+    if status != Ready || err != nil {
+        t.Fatalf("probe = %s, %v", status, err)
+    }
+    if !ph.MCPHandshakeComplete || ph.MCPProtocolVersion != "2025-03-26" || ph.MCPCapabilities["tools"] == nil {
+        t.Fatalf("handshake not recorded: %v %q %v", ph.MCPHandshakeComplete, ph.MCPProtocolVersion, ph.MCPCapabilities)
+    }
+    if line := <-notified; !strings.Contains(line, "notifications/initialized") {
+        t.Fatalf("sent %q after the result, want notifications/initialized", line)
+    }
+    
+    // A server that never answers is not ready, whatever its log says
+    ph.MCPHandshakeComplete = false
+    h.SetProcessSource(pipeSource{serve: func(conn net.Conn) { _, _ = io.Copy(io.Discard, conn) }})
+    status, _, err = h.performMCPProbe(ph)
+    if status != Degraded || err == nil || !strings.Contains(err.Error(), "timed out") {
+        t.Fatalf("silent server = %s, %v", status, err)
+    }
+    
+    // Without a pipe to the process only the log is left
+    h.SetProcessSource(runningSource{})
+    if status, _, err := h.performMCPProbe(ph); status != Ready || err != nil {
+        t.Fatalf("log fallback = %s, %v", status, err)
+    }
+}
+
+func TestMCPProbeHTTP(t *testing.T) {
+    version := "2025-03-26"
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            return
+        }
+        var msg struct{ Method string }
+        _ = json.NewDecoder(r.Body).Decode(&msg)
+        if msg.Method != "initialize" {
+            w.WriteHeader(http.StatusAccepted)
+            return
+        }
+        w.Header().Set("Content-Type", "text/event-stream")
+        fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{\"protocolVersion\":%q,\"capabilities\":{}}}\n\n", version)
+    }))
+    defer srv.Close()
+    
+    h := NewHealthMonitor(0)
+    h.SetProcessSource(runningSource{})
+    h.AddProcess("web", "http", srv.URL, "")
+    ph := h.processes["web"]
+    
+    version = ""
+    h.performHealthCheck(ph)
+    if last := ph.CheckHistory[len(ph.CheckHistory)-1]; last.CheckType != "mcp-handshake" || last.Status != Degraded {
+        t.Fatalf("result without protocolVersion: %+v", last)
+    }
+    
+    version = "2025-03-26"
+    h.performHealthCheck(ph)
+    if last := ph.CheckHistory[len(ph.CheckHistory)-1]; last.Status != Ready || ph.MCPProtocolVersion != version {
+        t.Fatalf("handshake: %+v, version %q", last, ph.MCPProtocolVersion)
+    }
+    
+    // Once complete, the plain HTTP check takes over
+    h.performHealthCheck(ph)
+    if last := ph.CheckHistory[len(ph.CheckHistory)-1]; last.CheckType != "http" {
+        t.Fatalf("check type %q after the handshake, want http", last.CheckType)
+    }
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+    "context"
+    "sync"
+)
+
+// DefaultCheckConcurrency is how many health checks run at once unless
+// SetCheckConcurrency says otherwise.
+const DefaultCheckConcurrency = 16
+
+// checkPool runs health checks on a fixed number of workers. A key whose
+// previous check is still queued or running is skipped, so a server that
+// hangs until its timeout holds at most one worker and never delays the
+// scheduling of the others.
+type checkPool struct {
+    mu      sync.Mutex
+    queue   []poolTask
+    pending map[string]bool
+    wake    chan struct{}
+}
+
+type poolTask struct {
+    key string
+    run func()
+}
+
+func newCheckPool() *checkPool {
+    return &checkPool{
+        pending: make(map[string]bool),
+        wake:    make(chan struct{}, 1),
+    }
+}
+
+// start launches n workers that run until ctx is cancelled.
+func (p *checkPool) start(ctx context.Context, n int, wg *sync.WaitGroup) {
+    if n < 1 {
+        n = 1
+    }
+    wg.Add(n)
+    for i := 0; i < n; i++ {
+        go func() {
+            defer wg.Done()
+            p.work(ctx)
+        }()
+    }
+}
+
+// submit queues run under key and reports whether it was queued; false means
+// a check for key is already queued or running.
+func (p *checkPool) submit(key string, run func()) bool {
+    p.mu.Lock()
+    if p.pending[key] {
+        p.mu.Unlock()
+        return false
+    }
+    p.pending[key] = true
+    p.queue = append(p.queue, poolTask{key: key, run: run})
+    p.mu.Unlock()
+
+    p.signal()
+    return true
+}
+
+func (p *checkPool) signal() {
+    select {
+    case p.wake <- struct{}{}:
+    default:
+    }
+}
+
+func (p *checkPool) work(ctx context.Context) {
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-p.wake:
+        }
+
+        for {
+            task, ok := p.next()
+            if !ok {
+                break
+            }
+            // Pass the wakeup on so idle workers pick up the rest of the queue
+            p.signal()
+            task.run()
+
+            p.mu.Lock()
+            delete(p.pending, task.key)
+            p.mu.Unlock()
+
+            if ctx.Err() != nil {
+                return
+            }
+        }
+    }
+}
+
+func (p *checkPool) next() (poolTask, bool) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if len(p.queue) == 0 {
+        return poolTask{}, false
+    }
+    task := p.queue[0]
+    p.queue[0] = poolTask{}
+    p.queue = p.queue[1:]
+    return task, true
+}
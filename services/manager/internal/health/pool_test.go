@@ -0,0 +1,74 @@
+package health
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestCheckPoolBoundsConcurrency(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    var wg sync.WaitGroup
+    p := newCheckPool()
+    p.start(ctx, 3, &wg)
+    defer func() { cancel(); wg.Wait() }()
+
+    var running, peak atomic.Int32
+    var done sync.WaitGroup
+    for i := 0; i < 20; i++ {
+        done.Add(1)
+        p.submit(fmt.Sprint(i), func() {
+            defer done.Done()
+            n := running.Add(1)
+            for {
+                old := peak.Load()
+                if n <= old || peak.CompareAndSwap(old, n) { break }
+            }
+            time.Sleep(5 * time.Millisecond)
+            running.Add(-1)
+        })
+    }
+    done.Wait()
+    if got := peak.Load(); got > 3 { t.Fatalf("peak concurrency %d, want at most 3", got) }
+}
+
+func TestCheckPoolSkipsBusyKey(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    var wg sync.WaitGroup
+    p := newCheckPool()
+    p.start(ctx, 2, &wg)
+
+    release := make(chan struct{})
+    started := make(chan struct{})
+    if !p.submit("slow", func() { close(started); <-release }) { t.Fatal("first submit refused") }
+    <-started
+    if p.submit("slow", func() { t.Error("duplicate check ran") }) { t.Fatal("busy key queued again") }
+
+    // The hung check holds one worker; the other keeps serving the rest
+    for i := 0; i < 5; i++ {
+        ran := make(chan struct{})
+        p.submit("fast", func() { close(ran) })
+        select {
+        case <-ran:
+        case <-time.After(2 * time.Second):
+            t.Fatal("check starved behind a slow one")
+        }
+        // pending is cleared just after run returns
+        for deadline := time.Now().Add(time.Second); ; {
+            p.mu.Lock()
+            busy := p.pending["fast"]
+            p.mu.Unlock()
+            if !busy { break }
+            if time.Now().After(deadline) { t.Fatal("fast key never released") }
+            time.Sleep(time.Millisecond)
+        }
+    }
+
+    close(release)
+    cancel()
+    wg.Wait()
+    if !p.submit("slow", func() {}) { t.Fatal("key not released after its check finished") }
+}
@@ -0,0 +1,247 @@
+package health
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "mcp/manager/internal/registry"
+)
+
+// maxProbeOutput bounds how much exec probe output ends up in a failure reason.
+const maxProbeOutput = 512
+
+// SetProbe applies name's health config: a tcp, exec or http probe replaces
+// the transport's default check, and Headers and BearerToken go with the http
+// check. A config with neither removes the override.
+func (h *HealthMonitor) SetProbe(name string, hc registry.Health) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    switch {
+    case hc.Probe == registry.ProbeTCP, hc.Probe == registry.ProbeExec, hc.Probe == registry.ProbeHTTP,
+        len(hc.Headers) > 0, hc.BearerToken != "":
+        hc.Args = append([]string(nil), hc.Args...)
+        h.probes[name] = hc
+    default:
+        delete(h.probes, name)
+    }
+}
+
+// checkHeaders returns the headers of name's http check, with vault://
+// references resolved now so rotated secrets are picked up. Errors name the
+// header but never carry its value.
+func (h *HealthMonitor) checkHeaders(name string) (http.Header, error) {
+    h.mu.RLock()
+    hc := h.probes[name]
+    secrets := h.secrets
+    h.mu.RUnlock()
+
+    if len(hc.Headers) == 0 && hc.BearerToken == "" {
+        return nil, nil
+    }
+    resolve := func(value string) (string, error) {
+        ref, key, ok := registry.ParseVaultRef(value)
+        if !ok {
+            return value, nil
+        }
+        if secrets == nil {
+            return "", fmt.Errorf("the vault is not available")
+        }
+        item, err := secrets.Retrieve(ref)
+        if err != nil {
+            return "", err
+        }
+        v, found := item[key]
+        if !found {
+            return "", fmt.Errorf("vault item %s has no key %s", ref, key)
+        }
+        return v, nil
+    }
+
+    headers := http.Header{}
+    for k, v := range hc.Headers {
+        resolved, err := resolve(v)
+        if err != nil {
+            return nil, fmt.Errorf("health header %s: %w", k, err)
+        }
+        headers.Set(k, resolved)
+    }
+    if hc.BearerToken != "" {
+        token, err := resolve(hc.BearerToken)
+        if err != nil {
+            return nil, fmt.Errorf("health bearer token: %w", err)
+        }
+        headers.Set("Authorization", "Bearer "+token)
+    }
+    return headers, nil
+}
+
+// probeFor returns the configured probe of name, if any.
+func (h *HealthMonitor) probeFor(name string) (registry.Health, bool) {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+
+    hc, ok := h.probes[name]
+    return hc, ok
+}
+
+// probeTimeout is the probe's own timeout, or the HTTP check timeout when unset.
+func (h *HealthMonitor) probeTimeout(hc registry.Health) time.Duration {
+    if hc.TimeoutSec > 0 {
+        return time.Duration(hc.TimeoutSec) * time.Second
+    }
+    return h.httpTimeout
+}
+
+// performTCPCheck reports Ready when a connection to the probe's port opens.
+func (h *HealthMonitor) performTCPCheck(hc registry.Health) (Status, time.Duration, error) {
+    host := hc.Host
+    if host == "" {
+        host = "127.0.0.1"
+    }
+    addr := net.JoinHostPort(host, strconv.Itoa(hc.Port))
+
+    start := time.Now()
+    conn, err := net.DialTimeout("tcp", addr, h.probeTimeout(hc))
+    elapsed := time.Since(start)
+    if err != nil {
+        return Down, elapsed, fmt.Errorf("TCP check of %s failed: %w", addr, err)
+    }
+    conn.Close()
+    return Ready, elapsed, nil
+}
+
+// performProbeHTTPCheck sends the probe's method to its path and reports
+// Ready on the expected status, or any 2xx when none is set. Like the
+// transport's http check it is retried, and a server that answers wrongly
+// is degraded rather than down.
+func (h *HealthMonitor) performProbeHTTPCheck(ph *ProcessHealth, hc registry.Health) (Status, time.Duration, error) {
+    target, err := probeURL(ph.HTTPURL, hc)
+    if err != nil {
+        return Down, 0, err
+    }
+    headers, err := h.checkHeaders(ph.Name)
+    if err != nil {
+        return Down, 0, err
+    }
+
+    res := HTTPProbe{
+        Client:   h.httpClient(ph.Name),
+        Method:   hc.ProbeMethod(),
+        Timeout:  h.probeTimeout(hc),
+        Attempts: h.retryAttempts,
+        Backoff:  h.retryBackoff,
+        Header:   headers,
+        Expect:   HealthExpectation{Status: hc.ExpectedStatus},
+    }.Check(h.ctx, target)
+    switch {
+    case res.Healthy():
+        return Ready, res.ResponseTime, nil
+    case res.Attempts == 0:
+        return Down, 0, fmt.Errorf("HTTP probe: %w", res.Err)
+    case res.StatusCode == 0:
+        return Down, res.ResponseTime, fmt.Errorf("HTTP probe of %s %s failed after %d attempts: %w", hc.ProbeMethod(), target, res.Attempts, res.Err)
+    default:
+        return Degraded, res.ResponseTime, fmt.Errorf("HTTP probe of %s %s: %w", hc.ProbeMethod(), target, res.Err)
+    }
+}
+
+// probeURL is where an http probe is sent: Path on Host:Port when a port is
+// set, otherwise Path on the server's own URL.
+func probeURL(serverURL string, hc registry.Health) (string, error) {
+    if hc.Port > 0 {
+        host := hc.Host
+        if host == "" {
+            host = "127.0.0.1"
+        }
+        return "http://" + net.JoinHostPort(host, strconv.Itoa(hc.Port)) + hc.Path, nil
+    }
+    if serverURL == "" {
+        return "", fmt.Errorf("http probe has no port and the server has no URL")
+    }
+    u, err := url.Parse(serverURL)
+    if err != nil {
+        return "", fmt.Errorf("http probe: %w", err)
+    }
+    if hc.Path != "" {
+        u.Path, u.RawPath = hc.Path, ""
+    }
+    return u.String(), nil
+}
+
+// performExecCheck runs the probe command of process name and reports Ready
+// when it exits with the expected code. The command is killed at the probe
+// timeout, and the tail of its stderr, or of its stdout when stderr is empty,
+// is kept in the error.
+func (h *HealthMonitor) performExecCheck(name string, hc registry.Health) (Status, time.Duration, error) {
+    h.mu.RLock()
+    check := h.commandCheck
+    h.mu.RUnlock()
+    if check != nil {
+        // Checked as exec will resolve it, from the manager's directory
+        command := hc.Command
+        if strings.ContainsRune(command, filepath.Separator) && !filepath.IsAbs(command) {
+            command, _ = filepath.Abs(command)
+        }
+        if err := check(name, command); err != nil {
+            return Down, 0, fmt.Errorf("probe command refused: %w", err)
+        }
+    }
+
+    timeout := h.probeTimeout(hc)
+    ctx, cancel := context.WithTimeout(h.ctx, timeout)
+    defer cancel()
+
+    var stdout, stderr bytes.Buffer
+    cmd := exec.CommandContext(ctx, hc.Command, hc.Args...)
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+    // Don't let a grandchild holding the pipes outlive the timeout
+    cmd.WaitDelay = time.Second
+
+    start := time.Now()
+    err := cmd.Run()
+    elapsed := time.Since(start)
+
+    out := stderr.Bytes()
+    if len(bytes.TrimSpace(out)) == 0 {
+        out = stdout.Bytes()
+    }
+    code := 0
+    var exitErr *exec.ExitError
+    switch {
+    case ctx.Err() == context.DeadlineExceeded:
+        return Down, elapsed, fmt.Errorf("probe command timed out after %s%s", timeout, probeOutput(out))
+    case errors.As(err, &exitErr):
+        code = exitErr.ExitCode()
+    case err != nil:
+        return Down, elapsed, fmt.Errorf("probe command failed: %w", err)
+    }
+
+    if code != hc.ExpectedExit {
+        return Down, elapsed, fmt.Errorf("probe command exited with %d, want %d%s", code, hc.ExpectedExit, probeOutput(out))
+    }
+    return Ready, elapsed, nil
+}
+
+// probeOutput formats the last maxProbeOutput bytes of output for an error.
+func probeOutput(output []byte) string {
+    s := strings.TrimSpace(string(output))
+    if s == "" {
+        return ""
+    }
+    if len(s) > maxProbeOutput {
+        s = "..." + s[len(s)-maxProbeOutput:]
+    }
+    return ": " + s
+}
@@ -0,0 +1,213 @@
+package health
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "mcp/manager/internal/registry"
+)
+
+func TestTCPProbe(t *testing.T) {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    port := ln.Addr().(*net.TCPAddr).Port
+
+    h := NewHealthMonitor(0)
+    hc := registry.Health{Probe: registry.ProbeTCP, Port: port, TimeoutSec: 1}
+    if status, _, err := h.performTCPCheck(hc); status != Ready || err != nil {
+        t.Fatalf("open port: %s, %v", status, err)
+    }
+
+    ln.Close()
+    if status, _, err := h.performTCPCheck(hc); status != Down || err == nil {
+        t.Fatalf("closed port: %s, %v", status, err)
+    }
+}
+
+func TestExecProbe(t *testing.T) {
+    h := NewHealthMonitor(0)
+    cases := []struct {
+        script   string
+        expected int
+        want     Status
+        errPart  string
+    }{
+        {"exit 0", 0, Ready, ""},
+        {"echo degraded >&2; exit 2", 0, Down, "exited with 2, want 0: degraded"},
+        {"exit 3", 3, Ready, ""},
+        {"echo started; sleep 5", 0, Down, "timed out after 1s: started"},
+        {"echo noise; echo broken >&2; exit 1", 0, Down, "exited with 1, want 0: broken"},
+    }
+    for _, c := range cases {
+        hc := registry.Health{Probe: registry.ProbeExec, Command: "sh", Args: []string{"-c", c.script}, ExpectedExit: c.expected, TimeoutSec: 1}
+        status, _, err := h.performExecCheck("srv", hc)
+        if status != c.want {
+            t.Errorf("%q: status %s, want %s (%v)", c.script, status, c.want, err)
+        }
+        if c.errPart == "" && err != nil || c.errPart != "" && (err == nil || !strings.Contains(err.Error(), c.errPart)) {
+            t.Errorf("%q: error %v, want %q", c.script, err, c.errPart)
+        }
+    }
+}
+
+func TestExecProbeCommandCheck(t *testing.T) {
+    h := NewHealthMonitor(0)
+    h.SetCommandCheck(func(name, command string) error {
+        if name == "srv" && command == "true" {
+            return nil
+        }
+        return errors.New("command not in allowlist")
+    })
+    if status, _, err := h.performExecCheck("srv", registry.Health{Command: "true", TimeoutSec: 1}); status != Ready {
+        t.Fatalf("allowed probe: %s, %v", status, err)
+    }
+    status, _, err := h.performExecCheck("srv", registry.Health{Command: "sh", Args: []string{"-c", "exit 0"}, TimeoutSec: 1})
+    if status != Down || err == nil || !strings.Contains(err.Error(), "probe command refused") {
+        t.Fatalf("refused probe: %s, %v", status, err)
+    }
+}
+
+func TestProbeOverridesTransportCheck(t *testing.T) {
+    h := NewHealthMonitor(0)
+    h.AddProcess("srv", "stdio", "", "")
+    h.SetProbe("srv", registry.Health{Probe: registry.ProbeExec, Command: "sh", Args: []string{"-c", "exit 1"}, TimeoutSec: 1})
+
+    h.performHealthCheck(h.processes["srv"])
+    ph, _ := h.GetProcessHealth("srv")
+    if last := ph.CheckHistory[len(ph.CheckHistory)-1]; last.CheckType != "exec" {
+        t.Fatalf("check type %q, want exec", last.CheckType)
+    }
+
+    // Probes without parameters leave the transport's check in place
+    h.SetProbe("srv", registry.Health{Probe: "command"})
+    h.performHealthCheck(h.processes["srv"])
+    ph, _ = h.GetProcessHealth("srv")
+    if last := ph.CheckHistory[len(ph.CheckHistory)-1]; last.CheckType != "mcp-handshake" {
+        t.Fatalf("check type %q, want mcp-handshake", last.CheckType)
+    }
+}
+
+func TestHTTPProbe(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Method == http.MethodHead && r.URL.Path == "/healthz" {
+            w.WriteHeader(http.StatusNoContent)
+            return
+        }
+        w.WriteHeader(http.StatusNotFound)
+    }))
+    defer srv.Close()
+
+    h := NewHealthMonitor(0)
+    h.retryAttempts = 1
+    h.AddProcess("srv", "http", srv.URL+"/mcp", "")
+    check := func(hc registry.Health) (Status, error) {
+        t.Helper()
+        hc.Probe, hc.TimeoutSec = registry.ProbeHTTP, 1
+        h.SetProbe("srv", hc)
+        h.performHealthCheck(h.processes["srv"])
+        ph, _ := h.GetProcessHealth("srv")
+        last := ph.CheckHistory[len(ph.CheckHistory)-1]
+        if last.CheckType != "http" {
+            t.Fatalf("check type %q, want http", last.CheckType)
+        }
+        status, _, err := h.performProbeHTTPCheck(h.processes["srv"], hc)
+        return status, err
+    }
+
+    if status, err := check(registry.Health{Method: "HEAD", Path: "/healthz", ExpectedStatus: 204}); status != Ready {
+        t.Fatalf("HEAD /healthz: %s %v", status, err)
+    }
+    if status, err := check(registry.Health{Path: "/healthz"}); status != Degraded || !strings.Contains(err.Error(), "GET "+srv.URL+"/healthz: expected a 2xx status, got 404") {
+        t.Fatalf("GET /healthz: %s %v", status, err)
+    }
+    if status, err := check(registry.Health{Method: "HEAD", Path: "/healthz", ExpectedStatus: 200}); status != Degraded || !strings.Contains(err.Error(), "expected status 200, got 204") {
+        t.Fatalf("wrong status: %s %v", status, err)
+    }
+
+    // A port sends the probe to the host instead of the server's URL
+    port := srv.Listener.Addr().(*net.TCPAddr).Port
+    if status, err := check(registry.Health{Method: "HEAD", Path: "/healthz", Port: port}); status != Ready {
+        t.Fatalf("by port: %s %v", status, err)
+    }
+}
+
+type fakeSecrets map[string]map[string]string
+
+func (f fakeSecrets) Retrieve(ref string) (map[string]string, error) {
+    return f[ref], nil
+}
+
+func TestHTTPCheckHeaders(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Tenant") != "acme" {
+            w.WriteHeader(http.StatusUnauthorized)
+        }
+    }))
+    defer srv.Close()
+
+    h := NewHealthMonitor(0)
+    h.retryAttempts = 1
+    h.AddProcess("srv", "http", srv.URL, "")
+    check := func() (Status, error) {
+        t.Helper()
+        status, _, err := h.performHTTPCheck(h.processes["srv"])
+        return status, err
+    }
+
+    // No headers configured, as before
+    if status, _ := check(); status == Ready {
+        t.Fatal("unauthenticated check passed")
+    }
+
+    h.SetProbe("srv", registry.Health{Headers: map[string]string{"X-Tenant": "acme"}, BearerToken: "vault://env:srv/TOKEN"})
+    status, err := check()
+    if status != Down || err == nil || !strings.Contains(err.Error(), "vault is not available") {
+        t.Fatalf("without a vault: %s %v", status, err)
+    }
+
+    h.SetSecretSource(fakeSecrets{"env:srv": {"TOKEN": "s3cret"}})
+    if status, err := check(); status != Ready {
+        t.Fatalf("with vault token: %s %v", status, err)
+    }
+
+    h.SetSecretSource(fakeSecrets{"env:srv": {}})
+    if _, err := check(); err == nil || strings.Contains(err.Error(), "s3cret") || !strings.Contains(err.Error(), "no key TOKEN") {
+        t.Fatalf("missing key: %v", err)
+    }
+}
+
+func TestMCPHandshakeHeaders(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.Header.Get("Authorization") != "Bearer s3cret" {
+            w.WriteHeader(http.StatusUnauthorized)
+            return
+        }
+        var msg struct{ Method string }
+        _ = json.NewDecoder(r.Body).Decode(&msg)
+        if msg.Method != "initialize" {
+            w.WriteHeader(http.StatusAccepted)
+            return
+        }
+        fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26","capabilities":{}}}`)
+    }))
+    defer srv.Close()
+
+    h := NewHealthMonitor(0)
+    h.AddProcess("srv", "http", srv.URL, "")
+    if status, _, _ := h.performMCPProbe(h.processes["srv"]); status == Ready {
+        t.Fatal("unauthenticated handshake passed")
+    }
+    h.SetProbe("srv", registry.Health{BearerToken: "vault://env:srv/TOKEN"})
+    h.SetSecretSource(fakeSecrets{"env:srv": {"TOKEN": "s3cret"}})
+    if status, _, err := h.performMCPProbe(h.processes["srv"]); status != Ready {
+        t.Fatalf("with the check's bearer token: %s %v", status, err)
+    }
+}
@@ -15,24 +15,109 @@ type ProbeInput struct {
     RestartsLast10m  int
 }
 
-// Evaluate returns Ready, Degraded, or Down based on inputs and v0 policy.
-func Evaluate(in ProbeInput) Status {
+// Thresholds are the limits Evaluate checks a probe against.
+type Thresholds struct {
+    DegradedMissedPings int // consecutive missed pings that make a process Degraded
+    DownMissedPings     int // consecutive missed pings that make it Down; 0 disables
+    MaxPingMs           int // ping latency above which a process is Degraded
+    MaxRestarts10m      int // restarts in the last 10 minutes that make it Degraded
+}
+
+// DefaultThresholds returns the v0 policy: one missed ping, a ping slower
+// than a second, or any recent restart degrades a process, and three missed
+// pings in a row take it down.
+func DefaultThresholds() Thresholds {
+    return Thresholds{
+        DegradedMissedPings: 1,
+        DownMissedPings:     3,
+        MaxPingMs:           1000,
+        MaxRestarts10m:      1,
+    }
+}
+
+// Evaluate maps a probe to a status. The rules are checked in order:
+//
+//   - process not running                   -> Down
+//   - MissedPings >= th.DownMissedPings     -> Down (when DownMissedPings > 0)
+//   - MissedPings >= th.DegradedMissedPings,
+//     LastPingMs > th.MaxPingMs, or
+//     RestartsLast10m >= th.MaxRestarts10m  -> Degraded
+//   - otherwise                             -> Ready
+//
+// A process therefore moves Ready -> Degraded -> Down as pings keep failing
+// and returns straight to Ready once a ping succeeds and no restart remains
+// in the window. Callers that want to debounce these moves use Hysteresis.
+func Evaluate(in ProbeInput, th Thresholds) Status {
     if !in.ProcessRunning {
         return Down
     }
-    degraded := false
-    if in.MissedPings >= 1 && in.MissedPings <= 2 {
-        degraded = true
+    if th.DownMissedPings > 0 && in.MissedPings >= th.DownMissedPings {
+        return Down
     }
-    if in.LastPingMs > 1000 {
-        degraded = true
+    if th.DegradedMissedPings > 0 && in.MissedPings >= th.DegradedMissedPings {
+        return Degraded
     }
-    if in.RestartsLast10m >= 1 {
-        degraded = true
+    if th.MaxPingMs > 0 && in.LastPingMs > th.MaxPingMs {
+        return Degraded
     }
-    if degraded {
+    if th.MaxRestarts10m > 0 && in.RestartsLast10m >= th.MaxRestarts10m {
         return Degraded
     }
     return Ready
 }
 
+
+// Hysteresis debounces status transitions so a process hovering around a
+// threshold does not flap. A worse status is committed after DegradeAfter
+// consecutive observations, a better one after RecoverAfter.
+type Hysteresis struct {
+    DegradeAfter int
+    RecoverAfter int
+}
+
+// DefaultHysteresis fails fast and recovers cautiously.
+func DefaultHysteresis() Hysteresis {
+    return Hysteresis{DegradeAfter: 1, RecoverAfter: 2}
+}
+
+// severity ranks statuses from healthiest to least healthy.
+func severity(s Status) int {
+    switch s {
+    case Ready:
+        return 0
+    case Degraded:
+        return 1
+    default:
+        return 2
+    }
+}
+
+// statusFilter tracks the candidate status a process is drifting towards and
+// how many consecutive checks have reported it.
+type statusFilter struct {
+    candidate Status
+    run       int
+}
+
+// observe records one check result and returns the status to commit, which
+// stays current until the candidate's run length reaches the threshold.
+func (f *statusFilter) observe(current, observed Status, h Hysteresis) Status {
+    if observed == current {
+        f.candidate, f.run = "", 0
+        return current
+    }
+    if observed != f.candidate {
+        f.candidate, f.run = observed, 0
+    }
+    f.run++
+    
+    need := h.RecoverAfter
+    if severity(observed) > severity(current) {
+        need = h.DegradeAfter
+    }
+    if f.run < need {
+        return current
+    }
+    f.candidate, f.run = "", 0
+    return observed
+}
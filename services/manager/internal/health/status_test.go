@@ -14,9 +14,38 @@ func TestEvaluate(t *testing.T) {
         {ProbeInput{ProcessRunning: true, RestartsLast10m: 1}, Degraded},
     }
     for i, c := range cases {
-        if got := Evaluate(c.in); got != c.want {
+        if got := Evaluate(c.in, DefaultThresholds()); got != c.want {
             t.Fatalf("case %d: got %s want %s", i, got, c.want)
         }
     }
 }
 
+
+func TestEvaluateThresholds(t *testing.T) {
+    strict := Thresholds{DegradedMissedPings: 1, DownMissedPings: 2, MaxPingMs: 200, MaxRestarts10m: 3}
+    lenient := Thresholds{DegradedMissedPings: 3, MaxPingMs: 5000, MaxRestarts10m: 5}
+    cases := []struct {
+        name string
+        th   Thresholds
+        in   ProbeInput
+        want Status
+    }{
+        {"default ready", DefaultThresholds(), ProbeInput{ProcessRunning: true, LastPingMs: 1000}, Ready},
+        {"default missed pings degrade", DefaultThresholds(), ProbeInput{ProcessRunning: true, MissedPings: 2}, Degraded},
+        {"default missed pings down", DefaultThresholds(), ProbeInput{ProcessRunning: true, MissedPings: 3}, Down},
+        {"default recovers after ping", DefaultThresholds(), ProbeInput{ProcessRunning: true, MissedPings: 0, LastPingMs: 50}, Ready},
+        {"default not running", DefaultThresholds(), ProbeInput{ProcessRunning: false, LastPingMs: 10}, Down},
+        {"strict slow ping", strict, ProbeInput{ProcessRunning: true, LastPingMs: 201}, Degraded},
+        {"strict missed pings down", strict, ProbeInput{ProcessRunning: true, MissedPings: 2}, Down},
+        {"strict restarts below ceiling", strict, ProbeInput{ProcessRunning: true, RestartsLast10m: 2}, Ready},
+        {"strict restarts at ceiling", strict, ProbeInput{ProcessRunning: true, RestartsLast10m: 3}, Degraded},
+        {"lenient tolerates misses", lenient, ProbeInput{ProcessRunning: true, MissedPings: 2, LastPingMs: 4000}, Ready},
+        {"lenient never down on misses", lenient, ProbeInput{ProcessRunning: true, MissedPings: 50}, Degraded},
+        {"lenient restart", lenient, ProbeInput{ProcessRunning: true, RestartsLast10m: 1}, Ready},
+    }
+    for _, c := range cases {
+        if got := Evaluate(c.in, c.th); got != c.want {
+            t.Errorf("%s: got %s want %s", c.name, got, c.want)
+        }
+    }
+}
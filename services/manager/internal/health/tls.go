@@ -0,0 +1,44 @@
+package health
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "net/http"
+    "os"
+    "time"
+)
+
+// LoadCABundle returns the system root pool extended with the PEM certificates
+// in path, for endpoints signed by a private CA.
+func LoadCABundle(path string) (*x509.CertPool, error) {
+    pem, err := os.ReadFile(path)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+    }
+    pool, err := x509.SystemCertPool()
+    if err != nil || pool == nil {
+        pool = x509.NewCertPool()
+    }
+    if !pool.AppendCertsFromPEM(pem) {
+        return nil, fmt.Errorf("no PEM certificates found in %s", path)
+    }
+    return pool, nil
+}
+
+// NewHTTPClient builds a client that trusts rootCAs (system roots when nil).
+// insecure disables certificate verification entirely and is meant for dev setups only.
+// Any other client than the default gets a transport, and connection pool, of
+// its own, so callers build one per configuration and keep it rather than one
+// per request.
+func NewHTTPClient(timeout time.Duration, rootCAs *x509.CertPool, insecure bool) *http.Client {
+    if rootCAs == nil && !insecure {
+        return &http.Client{Timeout: timeout}
+    }
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.TLSClientConfig = &tls.Config{
+        RootCAs:            rootCAs,
+        InsecureSkipVerify: insecure,
+    }
+    return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCustomCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewExternalHealthChecker()
+	if h, _ := checker.CheckHealth(context.Background(), srv.URL, ""); h.Status != "error" {
+		t.Fatalf("expected TLS failure without the CA, got %q", h.Status)
+	}
+	if h, _ := checker.Insecure().CheckHealth(context.Background(), srv.URL, ""); h.Status != "healthy" {
+		t.Fatalf("insecure check: %q %s", h.Status, h.Error)
+	}
+
+	pool, err := LoadCABundle(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker.SetRootCAs(pool)
+	if h, _ := checker.CheckHealth(context.Background(), srv.URL, ""); h.Status != "healthy" {
+		t.Fatalf("check with CA bundle: %q %s", h.Status, h.Error)
+	}
+
+	hm := NewHealthMonitor(0)
+	hm.retryAttempts = 1
+	ph := &ProcessHealth{Name: "internal", HTTPURL: srv.URL}
+	if status, _, _ := hm.performHTTPCheck(ph); status != Down {
+		t.Fatalf("expected Down without the CA, got %s", status)
+	}
+	hm.SetRootCAs(pool)
+	if status, _, err := hm.performHTTPCheck(ph); status != Ready {
+		t.Fatalf("expected Ready with the CA, got %s: %v", status, err)
+	}
+
+	if _, err := LoadCABundle(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Fatal("expected error for missing bundle")
+	}
+}
@@ -0,0 +1,250 @@
+package health
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "sync"
+    "time"
+
+    "mcp/manager/internal/registry"
+)
+
+const (
+    // defaultWebhookQueue is how many events wait for delivery before new
+    // ones are dropped.
+    defaultWebhookQueue = 256
+
+    // A failed delivery is retried with doubling pauses, starting at
+    // webhookBackoff; a 4xx answer other than 429 is not retried.
+    webhookAttempts = 3
+    webhookBackoff  = time.Second
+    webhookTimeout  = 10 * time.Second
+
+    // WebhookSignatureHeader carries "sha256=<hex HMAC of the body>" when the
+    // webhook has a secret.
+    WebhookSignatureHeader = "X-MCP-Signature"
+)
+
+// Webhook is a URL health status changes are POSTed to. Secret, when set, is
+// a vault://<ref>/<key> reference to the HMAC-SHA256 key the body is signed
+// with.
+type Webhook struct {
+    URL    string
+    Secret string
+}
+
+// WebhookEvent is the JSON body of a webhook delivery.
+type WebhookEvent struct {
+    Process          string    `json:"process"`
+    OldStatus        Status    `json:"oldStatus"`
+    NewStatus        Status    `json:"newStatus"`
+    Timestamp        time.Time `json:"timestamp"`
+    ConsecutiveFails int       `json:"consecutiveFails"`
+}
+
+// WebhookNotifier delivers WebhookEvents to every registered webhook from a
+// bounded queue, so a slow or unreachable endpoint never holds up health
+// checks: Notify only enqueues, and drops the event when the queue is full.
+type WebhookNotifier struct {
+    mu      sync.RWMutex
+    hooks   []Webhook
+    secrets SecretSource
+    dropped int64
+
+    client  *http.Client
+    backoff time.Duration
+    queue   chan WebhookEvent
+
+    ctx    context.Context
+    cancel context.CancelFunc
+    wg     sync.WaitGroup
+}
+
+// NewWebhookNotifier creates a notifier whose queue holds queueSize events,
+// or defaultWebhookQueue when queueSize is not positive. Call Start to begin
+// delivering.
+func NewWebhookNotifier(queueSize int) *WebhookNotifier {
+    if queueSize <= 0 {
+        queueSize = defaultWebhookQueue
+    }
+    ctx, cancel := context.WithCancel(context.Background())
+    return &WebhookNotifier{
+        client:  &http.Client{Timeout: webhookTimeout},
+        backoff: webhookBackoff,
+        queue:   make(chan WebhookEvent, queueSize),
+        ctx:     ctx,
+        cancel:  cancel,
+    }
+}
+
+// SetWebhooks replaces the registered webhooks.
+func (n *WebhookNotifier) SetWebhooks(hooks []Webhook) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.hooks = append([]Webhook(nil), hooks...)
+}
+
+// AddWebhook registers one more webhook.
+func (n *WebhookNotifier) AddWebhook(hook Webhook) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.hooks = append(n.hooks, hook)
+}
+
+// Webhooks returns the registered webhooks.
+func (n *WebhookNotifier) Webhooks() []Webhook {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return append([]Webhook(nil), n.hooks...)
+}
+
+// SetSecretSource makes vault:// webhook secrets resolve against src.
+func (n *WebhookNotifier) SetSecretSource(src SecretSource) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+    n.secrets = src
+}
+
+// Dropped returns how many events were dropped because the queue was full.
+func (n *WebhookNotifier) Dropped() int64 {
+    n.mu.RLock()
+    defer n.mu.RUnlock()
+    return n.dropped
+}
+
+// Start begins delivering queued events.
+func (n *WebhookNotifier) Start() {
+    n.wg.Add(1)
+    go n.run()
+}
+
+// Stop stops delivery and waits for the event being delivered, if any, to
+// give up. Events still queued are discarded.
+func (n *WebhookNotifier) Stop() {
+    n.cancel()
+    n.wg.Wait()
+}
+
+// Notify queues ev for every webhook. It never blocks: with no webhooks the
+// event is ignored, and with a full queue it is dropped and false returned.
+func (n *WebhookNotifier) Notify(ev WebhookEvent) bool {
+    if len(n.Webhooks()) == 0 {
+        return true
+    }
+    if ev.Timestamp.IsZero() {
+        ev.Timestamp = time.Now()
+    }
+    select {
+    case n.queue <- ev:
+        return true
+    default:
+        n.mu.Lock()
+        n.dropped++
+        n.mu.Unlock()
+        log.Printf("Webhook queue full, dropped %s -> %s for %s", ev.OldStatus, ev.NewStatus, ev.Process)
+        return false
+    }
+}
+
+func (n *WebhookNotifier) run() {
+    defer n.wg.Done()
+    for {
+        select {
+        case <-n.ctx.Done():
+            return
+        case ev := <-n.queue:
+            body, err := json.Marshal(ev)
+            if err != nil {
+                log.Printf("Webhook event for %s: %v", ev.Process, err)
+                continue
+            }
+            for _, hook := range n.Webhooks() {
+                if err := n.deliver(hook, body); err != nil {
+                    log.Printf("Webhook %s for %s failed: %v", hook.URL, ev.Process, err)
+                }
+            }
+        }
+    }
+}
+
+// deliver POSTs body to hook, retrying network errors, 429s and 5xx answers.
+func (n *WebhookNotifier) deliver(hook Webhook, body []byte) error {
+    signature, err := n.sign(hook, body)
+    if err != nil {
+        return err
+    }
+
+    backoff := n.backoff
+    for attempt := 1; ; attempt++ {
+        retry, err := n.post(hook.URL, body, signature)
+        if err == nil || !retry || attempt == webhookAttempts {
+            return err
+        }
+        select {
+        case <-time.After(backoff):
+        case <-n.ctx.Done():
+            return err
+        }
+        backoff *= 2
+    }
+}
+
+// post makes one delivery and reports whether a failure is worth retrying.
+func (n *WebhookNotifier) post(url string, body []byte, signature string) (bool, error) {
+    req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return false, err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    if signature != "" {
+        req.Header.Set(WebhookSignatureHeader, signature)
+    }
+
+    resp, err := n.client.Do(req)
+    if err != nil {
+        return true, err
+    }
+    resp.Body.Close()
+    if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+        return false, nil
+    }
+    retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+    return retry, fmt.Errorf("answered %d", resp.StatusCode)
+}
+
+// sign returns the signature header value for body, or "" when hook has no
+// secret. The secret is looked up at each delivery so a rotation applies.
+func (n *WebhookNotifier) sign(hook Webhook, body []byte) (string, error) {
+    if hook.Secret == "" {
+        return "", nil
+    }
+    ref, key, ok := registry.ParseVaultRef(hook.Secret)
+    if !ok {
+        return "", fmt.Errorf("secret must be a vault://<ref>/<key> reference")
+    }
+    n.mu.RLock()
+    secrets := n.secrets
+    n.mu.RUnlock()
+    if secrets == nil {
+        return "", fmt.Errorf("the vault is not available")
+    }
+    item, err := secrets.Retrieve(ref)
+    if err != nil {
+        return "", fmt.Errorf("secret: %w", err)
+    }
+    secret, found := item[key]
+    if !found {
+        return "", fmt.Errorf("vault item %s has no key %s", ref, key)
+    }
+
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write(body)
+    return "sha256=" + hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,82 @@
+package health
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestWebhookNotifierDelivers(t *testing.T) {
+    var calls atomic.Int32
+    got := make(chan *http.Request, 1)
+    bodies := make(chan []byte, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // The first attempt fails and is retried
+        if calls.Add(1) == 1 {
+            w.WriteHeader(http.StatusServiceUnavailable)
+            return
+        }
+        body, _ := io.ReadAll(r.Body)
+        got <- r
+        bodies <- body
+    }))
+    defer srv.Close()
+
+    n := NewWebhookNotifier(0)
+    n.backoff = 10 * time.Millisecond
+    n.SetSecretSource(fakeSecrets{"settings:webhook": {"KEY": "k3y"}})
+    n.AddWebhook(Webhook{URL: srv.URL, Secret: "vault://settings:webhook/KEY"})
+    n.Start()
+    defer n.Stop()
+
+    at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+    if !n.Notify(WebhookEvent{Process: "srv", OldStatus: Ready, NewStatus: Down, Timestamp: at, ConsecutiveFails: 3}) {
+        t.Fatal("event not queued")
+    }
+
+    var req *http.Request
+    var body []byte
+    select {
+    case req = <-got:
+        body = <-bodies
+    case <-time.After(5 * time.Second):
+        t.Fatal("webhook not delivered")
+    }
+    var ev WebhookEvent
+    if err := json.Unmarshal(body, &ev); err != nil { t.Fatal(err) }
+    if ev.Process != "srv" || ev.OldStatus != Ready || ev.NewStatus != Down || !ev.Timestamp.Equal(at) || ev.ConsecutiveFails != 3 {
+        t.Fatalf("event = %+v", ev)
+    }
+    mac := hmac.New(sha256.New, []byte("k3y"))
+    mac.Write(body)
+    if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.Header.Get(WebhookSignatureHeader) != want {
+        t.Fatalf("signature %q, want %q", req.Header.Get(WebhookSignatureHeader), want)
+    }
+    if calls.Load() != 2 {
+        t.Fatalf("%d attempts, want 2", calls.Load())
+    }
+}
+
+func TestWebhookNotifierDropsWhenFull(t *testing.T) {
+    n := NewWebhookNotifier(1)
+    n.SetWebhooks([]Webhook{{URL: "http://127.0.0.1:1"}, {URL: "http://127.0.0.1:2"}})
+    if len(n.Webhooks()) != 2 {
+        t.Fatalf("webhooks = %v", n.Webhooks())
+    }
+
+    // Not started, so nothing drains the queue
+    ev := WebhookEvent{Process: "srv", OldStatus: Ready, NewStatus: Degraded}
+    if !n.Notify(ev) { t.Fatal("first event dropped") }
+    if n.Notify(ev) { t.Fatal("second event queued past the bound") }
+    if n.Dropped() != 1 { t.Fatalf("dropped %d, want 1", n.Dropped()) }
+
+    n.SetWebhooks(nil)
+    if !n.Notify(ev) || n.Dropped() != 1 { t.Fatal("event without webhooks counted as dropped") }
+}
@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// attachProtocol is the Upgrade token for /v1/servers/{slug}/attach.
+const attachProtocol = "mcp-stdio"
+
+// handleServerAttach handles GET /v1/servers/{slug}/attach. A request carrying
+// "Connection: Upgrade" and "Upgrade: mcp-stdio" is answered with 101 Switching
+// Protocols, after which the connection carries newline-delimited JSON-RPC
+// messages to and from the server's stdio pipes until either side closes it.
+// Several clients may be attached to the same server at once.
+func (s *Server) handleServerAttach(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sv := s.findServer(slug)
+	if sv == nil {
+		http.Error(w, fmt.Sprintf("unknown server: %s", slug), http.StatusNotFound)
+		return
+	}
+	if sv.IsExternal() || sv.Entry.Transport == "http" {
+		http.Error(w, fmt.Sprintf("server %s does not use stdio transport", slug), http.StatusBadRequest)
+		return
+	}
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", attachProtocol) {
+		w.Header().Set("Upgrade", attachProtocol)
+		http.Error(w, "attach requires Upgrade: "+attachProtocol, http.StatusUpgradeRequired)
+		return
+	}
+	if s.sup == nil {
+		http.Error(w, "supervisor not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	session, err := s.sup.Attach(slug)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		session.Close()
+		http.Error(w, "connection does not support upgrades", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		session.Close()
+		log.Printf("attach %s: hijack failed: %v", slug, err)
+		return
+	}
+	// The server's read and write timeouts don't apply to a long-lived session
+	_ = conn.SetDeadline(time.Time{})
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", attachProtocol)
+	if err := rw.Flush(); err != nil {
+		session.Close()
+		conn.Close()
+		return
+	}
+
+	log.Printf("[AUDIT] Client %s attached to stdio server %s", r.RemoteAddr, slug)
+	var once sync.Once
+	done := make(chan struct{})
+	closeBoth := func() {
+		once.Do(func() {
+			session.Close()
+			conn.Close()
+			close(done)
+		})
+	}
+	go func() {
+		// rw.Reader holds anything the client sent after the request headers
+		_, _ = io.Copy(session, rw.Reader)
+		closeBoth()
+	}()
+	go func() {
+		_, _ = io.Copy(conn, session)
+		closeBoth()
+	}()
+	<-done
+	log.Printf("[AUDIT] Client %s detached from stdio server %s", r.RemoteAddr, slug)
+}
+
+// headerHasToken reports whether the comma-separated header name contains
+// token, ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/registry"
+)
+
+// pipeSupervisor attaches clients to an in-process echo of their messages.
+type pipeSupervisor struct {
+	stubSupervisor
+	detached chan struct{}
+}
+
+func (p pipeSupervisor) Attach(string) (io.ReadWriteCloser, error) {
+	client, server := net.Pipe()
+	go func() {
+		_, _ = io.Copy(server, server)
+		close(p.detached)
+	}()
+	return client, nil
+}
+
+func TestServerAttach(t *testing.T) {
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+		{Slug: "fs", Entry: registry.Entry{Transport: "stdio", Command: "node"}},
+		{Slug: "web", Entry: registry.Entry{Transport: "http", Command: "node"}},
+	}}
+	sup := pipeSupervisor{detached: make(chan struct{})}
+	ts := httptest.NewServer(NewServer(reg).WithSupervisor(sup).Router())
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		path    string
+		upgrade bool
+		want    int
+	}{
+		{"/v1/servers/fs/attach", false, http.StatusUpgradeRequired},
+		{"/v1/servers/web/attach", true, http.StatusBadRequest},
+		{"/v1/servers/nope/attach", true, http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+tt.path, nil)
+		if tt.upgrade {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", attachProtocol)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s (upgrade=%v) = %d, want %d", tt.path, tt.upgrade, resp.StatusCode, tt.want)
+		}
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// The first message follows the headers directly
+	msg := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+	if _, err := io.WriteString(conn, "GET /v1/servers/fs/attach HTTP/1.1\r\nHost: manager\r\nConnection: Upgrade\r\nUpgrade: mcp-stdio\r\n\r\n"+msg+"\n"); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != attachProtocol {
+		t.Fatalf("handshake: %d %v", resp.StatusCode, resp.Header)
+	}
+	line, err := br.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != msg {
+		t.Fatalf("echo = %q, %v", line, err)
+	}
+
+	conn.Close()
+	select {
+	case <-sup.detached:
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not closed after the client disconnected")
+	}
+}
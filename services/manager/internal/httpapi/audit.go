@@ -0,0 +1,214 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp/manager/internal/paths"
+)
+
+// maxAuditResults bounds a query's response; the newest events are kept.
+const maxAuditResults = 1000
+
+// maxClientIDLen bounds the X-Client-ID value kept in an event.
+const maxClientIDLen = 128
+
+// CredentialAuditEvent records one credential operation. It never carries
+// credential values.
+type CredentialAuditEvent struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"` // store, update, delete, switch-profile, migrate, refresh, validate, validate-stored or maintenance
+	Provider string    `json:"provider"` // empty for maintenance
+	Slug     string    `json:"slug,omitempty"`
+	Result   string    `json:"result"`
+	Reason   string    `json:"reason,omitempty"` // given when entering maintenance
+	ClientID string    `json:"clientId,omitempty"` // from the X-Client-ID header
+	Remote   string    `json:"remote,omitempty"`   // both empty for the manager's own changes
+}
+
+// credentialAudit appends events to a JSONL file and reads them back.
+type credentialAudit struct {
+	mu   sync.Mutex
+	path string // empty means audit/credentials.jsonl under the config dir
+}
+
+func newCredentialAudit() *credentialAudit {
+	return &credentialAudit{}
+}
+
+func (a *credentialAudit) file() (string, error) {
+	if a.path != "" {
+		return a.path, nil
+	}
+	base, err := paths.HomeMCP()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "audit")
+	if err := paths.MkdirAll(dir); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "credentials.jsonl"), nil
+}
+
+// record logs ev and appends it to the audit file. A nil audit only logs.
+func (a *credentialAudit) record(ev CredentialAuditEvent) {
+	client := ev.ClientID
+	if client == "" {
+		client = ev.Remote
+	}
+	if client == "" {
+		client = "the manager"
+	}
+	if ev.Provider == "" {
+		log.Printf("[AUDIT] %s by %s: %s %s", ev.Action, client, ev.Result, ev.Reason)
+	} else {
+		log.Printf("[AUDIT] Credential %s for provider %s by %s: %s", ev.Action, ev.Provider, client, ev.Result)
+	}
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	path, err := a.file()
+	if err != nil {
+		log.Printf("Failed to resolve credential audit log: %v", err)
+		return
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	f, err := paths.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("Failed to open credential audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write credential audit log: %v", err)
+	}
+}
+
+// query returns the events for provider (any when empty) at or after since,
+// oldest first, keeping only the newest maxAuditResults.
+func (a *credentialAudit) query(provider string, since time.Time) ([]CredentialAuditEvent, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	events := []CredentialAuditEvent{}
+	path, err := a.file()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev CredentialAuditEvent
+		// A line cut short by a crash is skipped rather than failing the query
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		if (provider != "" && ev.Provider != provider) || ev.Time.Before(since) {
+			continue
+		}
+		events = append(events, ev)
+		if len(events) > maxAuditResults {
+			events = events[1:]
+		}
+	}
+	return events, scanner.Err()
+}
+
+// auditCredential records a credential operation made by r. Every vault
+// write and delete goes through it.
+func (s *Server) auditCredential(r *http.Request, action, provider, slug, result string) {
+	s.credentialManager.auditCredential(r, action, provider, slug, result)
+}
+
+// auditCredential records a credential operation made by r, or by the
+// manager itself when r is nil. A nil manager only logs it.
+func (cm *CredentialManager) auditCredential(r *http.Request, action, provider, slug, result string) {
+	cm.recordAudit(auditEvent(r, CredentialAuditEvent{Action: action, Provider: provider, Slug: slug, Result: result}))
+}
+
+// auditEvent stamps ev with the time and with who made r, if anyone.
+func auditEvent(r *http.Request, ev CredentialAuditEvent) CredentialAuditEvent {
+	ev.Time = time.Now().UTC()
+	if r != nil {
+		ev.ClientID = strings.TrimSpace(r.Header.Get("X-Client-ID"))
+		if len(ev.ClientID) > maxClientIDLen {
+			ev.ClientID = ev.ClientID[:maxClientIDLen]
+		}
+		ev.Remote = clientAddr(r)
+	}
+	return ev
+}
+
+// recordAudit appends ev to the manager's audit log. A nil manager only logs it.
+func (cm *CredentialManager) recordAudit(ev CredentialAuditEvent) {
+	var audit *credentialAudit
+	if cm != nil {
+		audit = cm.audit
+	}
+	audit.record(ev)
+}
+
+// handleCredentialsAudit handles GET /v1/credentials/audit?provider=&since=
+// with since as an RFC 3339 time.
+func (s *Server) handleCredentialsAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid since %q: want an RFC 3339 time", v), http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+
+	if s.credentialManager == nil {
+		cm, err := NewCredentialManager()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.credentialManager = cm
+	}
+	if s.credentialManager.audit == nil {
+		writeJSON(w, []CredentialAuditEvent{})
+		return
+	}
+
+	events, err := s.credentialManager.audit.query(r.URL.Query().Get("provider"), since)
+	if err != nil {
+		log.Printf("Error reading credential audit log: %v", err)
+		http.Error(w, "failed to read audit log", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, events)
+}
@@ -0,0 +1,119 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/vault"
+)
+
+func TestCredentialAuditTrail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.jsonl")
+	server := &Server{credentialManager: &CredentialManager{
+		rateLimiter: newRateLimiter(),
+		audit:       &credentialAudit{path: path},
+	}}
+
+	validate := func(provider, clientID string) {
+		t.Helper()
+		body, _ := json.Marshal(ValidateCredentialsRequest{Provider: provider, Credentials: map[string]string{"wrong_field": "s3cret-value"}})
+		req := httptest.NewRequest(http.MethodPost, "/v1/credentials/validate", bytes.NewReader(body))
+		req.Header.Set("X-Client-ID", clientID)
+		server.handleCredentialsValidate(httptest.NewRecorder(), req)
+	}
+	query := func(q string) (int, []CredentialAuditEvent) {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		server.handleCredentialsAudit(rr, httptest.NewRequest(http.MethodGet, "/v1/credentials/audit"+q, nil))
+		var events []CredentialAuditEvent
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&events); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rr.Code, events
+	}
+
+	if code, events := query(""); code != http.StatusOK || len(events) != 0 {
+		t.Fatalf("empty log: %d %v", code, events)
+	}
+
+	validate("notion", "desktop")
+	validate("slack", "cli")
+
+	_, events := query("?provider=slack")
+	if len(events) != 1 {
+		t.Fatalf("slack events = %+v", events)
+	}
+	ev := events[0]
+	if ev.Action != "validate" || ev.Result != "invalid_format" || ev.ClientID != "cli" || ev.Remote == "" {
+		t.Fatalf("event = %+v", ev)
+	}
+
+	since := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	if _, events := query("?since=" + since); len(events) != 0 {
+		t.Fatalf("events after %s: %+v", since, events)
+	}
+	if code, _ := query("?since=yesterday"); code != http.StatusBadRequest {
+		t.Fatalf("bad since: status %d", code)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(data), "\n") != 2 || strings.Contains(string(data), "s3cret-value") {
+		t.Fatalf("audit log contents:\n%s", data)
+	}
+}
+
+func TestServerCredentialChangesAudited(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", root)
+	v, err := vault.NewKeychainVault("mcp-manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{credentialManager: &CredentialManager{vault: v, audit: newCredentialAudit()}}
+	req := httptest.NewRequest(http.MethodPost, "/v1/external/servers", nil)
+	req.Header.Set("X-Client-ID", "desktop")
+
+	undo, err := server.storeServerCredentials(req, "gh", "github:gh", "github", map[string]string{"token": "s3cret-value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	undo()
+	if _, err := server.storeServerCredentials(req, "gh", "github:gh", "github", map[string]string{"token": "s3cret-value"}); err != nil {
+		t.Fatal(err)
+	}
+	server.deleteServerCredentials(req, "gh", "github:gh", "github")
+	server.deleteServerCredentials(req, "gh", "github:gh", "github") // nothing left to delete
+
+	events, err := server.credentialManager.audit.query("", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ev := range events {
+		if ev.Slug != "gh" || ev.Provider != "github" || ev.ClientID != "desktop" {
+			t.Fatalf("event = %+v", ev)
+		}
+		got = append(got, ev.Action+":"+ev.Result)
+	}
+	if want := "store:success delete:rolled_back store:success delete:success"; strings.Join(got, " ") != want {
+		t.Fatalf("events = %v, want %s", got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "audit", "credentials.jsonl"))
+	if err != nil || strings.Contains(string(data), "s3cret-value") {
+		t.Fatalf("audit log: %v\n%s", err, data)
+	}
+}
@@ -0,0 +1,176 @@
+package httpapi
+
+import (
+    "archive/tar"
+    "compress/gzip"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "net/http"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+// maxBundleFileBytes caps how much of any single log goes into a download bundle.
+// Larger files contribute only their tail, which is where the interesting part is.
+const maxBundleFileBytes = 32 << 20
+
+const redacted = "[REDACTED]"
+
+// secretKeyPattern matches env/config keys whose values must not leave the machine.
+var secretKeyPattern = regexp.MustCompile(`(?i)(key|token|secret|passw(or)?d|credential|auth)`)
+
+func (s *Server) handleLogsDownload(w http.ResponseWriter, r *http.Request, slug string) {
+    // GET /v1/servers/{slug}/logs/download
+    if r.Method != http.MethodGet { w.WriteHeader(http.StatusMethodNotAllowed); return }
+    sv := s.findServer(slug)
+    if sv == nil { http.Error(w, "server not found", http.StatusNotFound); return }
+
+    logsDir, err := paths.LogsDir()
+    if err != nil { w.WriteHeader(http.StatusInternalServerError); return }
+    logFiles := serverLogFiles(logsDir, slug)
+
+    name := fmt.Sprintf("%s-logs-%s", slug, time.Now().UTC().Format("20060102-150405"))
+    w.Header().Set("Content-Type", "application/gzip")
+    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".tar.gz"))
+
+    // From here on the status is committed; failures can only truncate the archive.
+    gz := gzip.NewWriter(w)
+    tw := tar.NewWriter(gz)
+    defer func() {
+        if err := tw.Close(); err != nil { log.Printf("logs bundle %s: %v", slug, err) }
+        if err := gz.Close(); err != nil { log.Printf("logs bundle %s: %v", slug, err) }
+    }()
+
+    entry, _ := json.MarshalIndent(scrubServer(*sv), "", "  ")
+    if err := addBundleBytes(tw, name+"/registry-entry.json", entry); err != nil { return }
+
+    if serversDir, err := paths.ServersDir(); err == nil {
+        if b, err := os.ReadFile(filepath.Join(serversDir, slug, "manifest.json")); err == nil {
+            if err := addBundleBytes(tw, name+"/install-result.json", scrubManifest(b)); err != nil { return }
+        }
+    }
+
+    for _, p := range logFiles {
+        if err := addBundleFile(tw, name+"/logs/"+filepath.Base(p), p); err != nil {
+            log.Printf("logs bundle %s: %v", slug, err)
+            return
+        }
+    }
+}
+
+// serverLogFiles returns the main and stderr logs for slug plus any rotated copies
+// (<slug>.log.1, <slug>.err.log.gz, ...), sorted by name.
+func serverLogFiles(dir, slug string) []string {
+    entries, err := os.ReadDir(dir)
+    if err != nil { return nil }
+    var out []string
+    for _, e := range entries {
+        if e.IsDir() { continue }
+        n := e.Name()
+        for _, base := range []string{slug + ".log", slug + ".err.log"} {
+            if n == base || strings.HasPrefix(n, base+".") {
+                out = append(out, filepath.Join(dir, n))
+                break
+            }
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+func addBundleBytes(tw *tar.Writer, name string, b []byte) error {
+    hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), ModTime: time.Now()}
+    if err := tw.WriteHeader(hdr); err != nil { return err }
+    _, err := tw.Write(b)
+    return err
+}
+
+// addBundleFile streams the last maxBundleFileBytes of path into the archive. The
+// size is fixed from a stat up front, so a log that keeps growing while we copy
+// is cut at that point rather than breaking the tar framing.
+func addBundleFile(tw *tar.Writer, name, path string) error {
+    f, err := os.Open(path)
+    if err != nil { return nil } // rotated away between listing and opening
+    defer f.Close()
+    fi, err := f.Stat()
+    if err != nil { return nil }
+    size := fi.Size()
+    if size > maxBundleFileBytes {
+        if _, err := f.Seek(size-maxBundleFileBytes, io.SeekStart); err != nil { return err }
+        size = maxBundleFileBytes
+    }
+    hdr := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: fi.ModTime()}
+    if err := tw.WriteHeader(hdr); err != nil { return err }
+    n, err := io.CopyN(tw, f, size)
+    if err == io.EOF {
+        // Truncated underneath us; pad so the header stays truthful.
+        _, err = io.CopyN(tw, zeroReader{}, size-n)
+    }
+    return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+    for i := range p { p[i] = 0 }
+    return len(p), nil
+}
+
+// scrubServer returns a copy of sv that is safe to attach to a bug report: inline
+// credentials are dropped and secret-looking env values are redacted. vault://
+// references are kept verbatim and never resolved.
+func scrubServer(sv registry.Server) registry.Server {
+    sv.Entry.Env = scrubEnv(sv.Entry.Env)
+    if sv.External != nil {
+        ext := *sv.External
+        if ext.APIKey != "" { ext.APIKey = redacted }
+        if len(ext.Credentials) > 0 {
+            creds := make(map[string]string, len(ext.Credentials))
+            for k := range ext.Credentials { creds[k] = redacted }
+            ext.Credentials = creds
+        }
+        sv.External = &ext
+    }
+    return sv
+}
+
+func scrubEnv(env map[string]string) map[string]string {
+    if env == nil { return nil }
+    out := make(map[string]string, len(env))
+    for k, v := range env {
+        if secretKeyPattern.MatchString(k) && !strings.HasPrefix(v, "vault://") {
+            v = redacted
+        }
+        out[k] = v
+    }
+    return out
+}
+
+// scrubManifest redacts the entry environment of a persisted install manifest.
+// Unparseable manifests are replaced by a note rather than passed through raw.
+func scrubManifest(b []byte) []byte {
+    var m map[string]any
+    if err := json.Unmarshal(b, &m); err != nil {
+        return []byte(fmt.Sprintf("{\"error\": %q}\n", "manifest.json could not be parsed: "+err.Error()))
+    }
+    if entry, ok := m["entry"].(map[string]any); ok {
+        if env, ok := entry["environment"].(map[string]any); ok {
+            for k, v := range env {
+                if s, _ := v.(string); secretKeyPattern.MatchString(k) && !strings.HasPrefix(s, "vault://") {
+                    env[k] = redacted
+                }
+            }
+        }
+    }
+    out, _ := json.MarshalIndent(m, "", "  ")
+    return out
+}
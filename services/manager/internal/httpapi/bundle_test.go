@@ -0,0 +1,112 @@
+package httpapi
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestLogsDownload(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", root)
+	logsDir := filepath.Join(root, "logs")
+	serverDir := filepath.Join(root, "servers", "fs")
+	for _, d := range []string{logsDir, serverDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(logsDir, "fs.log"):          "current\n",
+		filepath.Join(logsDir, "fs.log.1"):        "rotated\n",
+		filepath.Join(logsDir, "fs.err.log"):      "stderr\n",
+		filepath.Join(logsDir, "fs-other.log"):    "not ours\n",
+		filepath.Join(serverDir, "manifest.json"): `{"slug":"fs","entry":{"environment":{"API_TOKEN":"abc123","MODE":"prod"}}}`,
+	}
+	for p, c := range files {
+		if err := os.WriteFile(p, []byte(c), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+		Name: "fs",
+		Slug: "fs",
+		Entry: registry.Entry{Env: map[string]string{
+			"GITHUB_TOKEN": "ghp_secret",
+			"DB_PASSWORD":  "vault://fs/db",
+			"LOG_LEVEL":    "debug",
+		}},
+		External: &registry.ExternalInfo{APIKey: "sk-live", Credentials: map[string]string{"token": "t0ken"}},
+	}}}
+	s := NewServer(reg)
+
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/servers/fs/logs/download", nil))
+	if rr.Code != 200 {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment; filename=\"fs-logs-") || !strings.HasSuffix(cd, ".tar.gz\"") {
+		t.Fatalf("unexpected Content-Disposition %q", cd)
+	}
+
+	gz, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	got := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(tr)
+		got[hdr.Name[strings.Index(hdr.Name, "/")+1:]] = string(b)
+	}
+
+	for name, want := range map[string]string{"logs/fs.log": "current\n", "logs/fs.log.1": "rotated\n", "logs/fs.err.log": "stderr\n"} {
+		if got[name] != want {
+			t.Errorf("%s = %q, want %q", name, got[name], want)
+		}
+	}
+	if _, ok := got["logs/fs-other.log"]; ok {
+		t.Errorf("bundle includes another server's log")
+	}
+
+	entry := got["registry-entry.json"]
+	for _, secret := range []string{"ghp_secret", "sk-live", "t0ken"} {
+		if strings.Contains(entry, secret) {
+			t.Errorf("registry entry leaks %q: %s", secret, entry)
+		}
+	}
+	if !strings.Contains(entry, "vault://fs/db") || !strings.Contains(entry, "debug") {
+		t.Errorf("registry entry lost non-secret values: %s", entry)
+	}
+
+	manifest := got["install-result.json"]
+	if strings.Contains(manifest, "abc123") || !strings.Contains(manifest, "prod") {
+		t.Errorf("manifest not scrubbed correctly: %s", manifest)
+	}
+}
+
+func TestLogsDownloadUnknownServer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s := NewServer(&registry.Registry{Version: "1.0"})
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/servers/../logs/download", nil))
+	if rr.Code == 200 {
+		t.Fatalf("expected failure for unknown server, got 200")
+	}
+}
@@ -0,0 +1,96 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestClientsApplyDryRun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MCP_HOME", "")
+	cursor := filepath.Join(home, ".cursor", "mcp.json")
+	if err := os.MkdirAll(filepath.Dir(cursor), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	original := `{"mcpServers":{"mine":{"command":"mine"}}}`
+	if err := os.WriteFile(cursor, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewServer(&registry.Registry{Version: "1.0"}).Router()
+	post := func(path, body string) (int, map[string]any) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		var out map[string]any
+		json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	apply := `{"client":"Cursor (Global)","config":{"mcpServers":{"fs":{"command":"fs"}}}%s}`
+	for _, req := range []struct{ path, body string }{
+		{"/v1/clients/diff", strings.Replace(apply, "%s", "", 1)},
+		{"/v1/clients/apply", strings.Replace(apply, "%s", `,"dryRun":true`, 1)},
+	} {
+		code, out := post(req.path, req.body)
+		diff, _ := out["diff"].(string)
+		if code != http.StatusOK || out["status"] != "dry-run" || out["changed"] != true || !strings.Contains(diff, `+    "fs": {`) || !strings.Contains(diff, ` "mine": {`) {
+			t.Fatalf("%s: %d %v", req.path, code, out)
+		}
+		if b, _ := os.ReadFile(cursor); string(b) != original {
+			t.Fatalf("%s wrote %s", req.path, b)
+		}
+	}
+
+	if code, out := post("/v1/clients/apply", strings.Replace(apply, "%s", "", 1)); code != http.StatusOK || out["status"] != "ok" {
+		t.Fatalf("apply: %d %v", code, out)
+	}
+	b, _ := os.ReadFile(cursor)
+	if !strings.Contains(string(b), `"mine"`) || !strings.Contains(string(b), `"fs"`) {
+		t.Fatalf("apply replaced the config: %s", b)
+	}
+
+	if err := os.WriteFile(cursor, []byte(`{"mcpServers":`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := post("/v1/clients/diff", strings.Replace(apply, "%s", "", 1)); code != http.StatusUnprocessableEntity {
+		t.Fatalf("diff of a broken file: %d", code)
+	}
+}
+
+func TestClientsApplyNewClients(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MCP_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	h := NewServer(&registry.Registry{Version: "1.0"}).Router()
+
+	for client, path := range map[string]string{
+		"VS Code":  filepath.Join(home, ".config", "Code", "User", "mcp.json"),
+		"Windsurf": filepath.Join(home, ".codeium", "windsurf", "mcp_config.json"),
+	} {
+		rr := httptest.NewRecorder()
+		body := `{"client":"` + client + `","config":{"mcpServers":{"fs":{"command":"fs"}}}}`
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/v1/clients/apply", strings.NewReader(body)))
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"ok"`) {
+			t.Fatalf("%s apply: %d %s", client, rr.Code, rr.Body)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s: %v", client, err)
+		}
+
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/clients/current?client="+url.QueryEscape(client), nil))
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"fs"`) {
+			t.Fatalf("%s current: %d %s", client, rr.Code, rr.Body)
+		}
+	}
+}
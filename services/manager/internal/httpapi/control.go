@@ -0,0 +1,224 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"mcp/manager/internal/health"
+	"mcp/manager/internal/install"
+	"mcp/manager/internal/logs"
+	"mcp/manager/internal/registry"
+	pb "mcp/manager/proto"
+)
+
+// The control interface is the gRPC service in proto/control.proto, for
+// tooling that prefers it to REST, streams especially. Every call maps onto
+// the same Server helpers the HTTP handlers use.
+
+// healthEventInterval is how often health streams look for status changes.
+var healthEventInterval = time.Second
+
+// ServeControl serves the control interface on ln until ctx is done.
+func (s *Server) ServeControl(ctx context.Context, ln net.Listener) error {
+	gs := grpc.NewServer()
+	pb.RegisterControlServer(gs, &controlServer{s: s})
+	go func() {
+		<-ctx.Done()
+		gs.Stop()
+	}()
+	return gs.Serve(ln)
+}
+
+// controlServer implements pb.ControlServer on top of a Server.
+type controlServer struct {
+	pb.UnimplementedControlServer
+	s *Server
+}
+
+func (c *controlServer) ListServers(ctx context.Context, req *pb.ListServersRequest) (*pb.ListServersResponse, error) {
+	rows, total := c.s.serverList(int(req.GetOffset()), int(req.GetLimit()))
+	resp := &pb.ListServersResponse{Total: int32(total)}
+	for _, row := range rows {
+		resp.Servers = append(resp.Servers, serverMessage(row))
+	}
+	return resp, nil
+}
+
+// serverMessage converts a summary row, whose keys are capitalized when no
+// supervisor built it.
+func serverMessage(row map[string]any) *pb.Server {
+	field := func(key string) any {
+		if v, ok := row[key]; ok {
+			return v
+		}
+		return row[strings.ToUpper(key[:1])+key[1:]]
+	}
+	str := func(key string) string {
+		v, _ := field(key).(string)
+		return v
+	}
+	num := func(key string) int {
+		v, _ := field(key).(int)
+		return v
+	}
+	return &pb.Server{
+		Slug:      str("slug"),
+		Name:      str("name"),
+		Status:    str("status"),
+		State:     str("state"),
+		UptimeSec: int64(num("uptime")),
+		Restarts:  int32(num("restarts")),
+		Pid:       int32(num("pid")),
+	}
+}
+
+func (c *controlServer) StartServer(ctx context.Context, req *pb.ServerRequest) (*pb.ServerActionResponse, error) {
+	return c.serverAction(req, "start")
+}
+
+func (c *controlServer) StopServer(ctx context.Context, req *pb.ServerRequest) (*pb.ServerActionResponse, error) {
+	return c.serverAction(req, "stop")
+}
+
+func (c *controlServer) RestartServer(ctx context.Context, req *pb.ServerRequest) (*pb.ServerActionResponse, error) {
+	return c.serverAction(req, "restart")
+}
+
+func (c *controlServer) serverAction(req *pb.ServerRequest, action string) (*pb.ServerActionResponse, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+	if req.GetSlug() == "" {
+		return nil, status.Error(codes.InvalidArgument, "slug is required")
+	}
+	if c.s.sup == nil {
+		return nil, status.Error(codes.Unavailable, "supervisor not available")
+	}
+	if err := c.s.serverAction(req.GetSlug(), action); err != nil {
+		return nil, err
+	}
+	return &pb.ServerActionResponse{Status: "ok"}, nil
+}
+
+func (c *controlServer) Install(ctx context.Context, req *pb.InstallRequest) (*pb.InstallResponse, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+	if req.GetType() == "" || req.GetSlug() == "" {
+		return nil, status.Error(codes.InvalidArgument, "type and slug are required")
+	}
+	r := AdvancedInstallRequest{Type: install.SourceType(req.GetType()), URI: req.GetUri(), Slug: req.GetSlug()}
+	if req.Options != nil {
+		options, err := protojson.Marshal(req.Options)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid options: %v", err)
+		}
+		r.Options = json.RawMessage(options)
+	}
+	for _, env := range req.GetRequiredEnv() {
+		r.RequiredEnv = append(r.RequiredEnv, registry.RequiredEnv{Key: env.GetKey(), Description: env.GetDescription(), Secret: env.GetSecret()})
+	}
+	// The job outlives the call, as it does a REST request
+	jobID, err := c.s.startAdvancedInstall(context.Background(), r)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.InstallResponse{JobId: jobID}, nil
+}
+
+func (c *controlServer) StreamLogs(req *pb.StreamLogsRequest, stream pb.Control_StreamLogsServer) error {
+	if req.GetSlug() == "" {
+		return status.Error(codes.InvalidArgument, "slug is required")
+	}
+	if c.s.logStreamer == nil {
+		return status.Error(codes.Unavailable, "log streaming not available")
+	}
+	fromLine := int64(-1)
+	if req.FromLine != nil {
+		fromLine = req.GetFromLine()
+	}
+	clientID := fmt.Sprintf("control-%d", time.Now().UnixNano())
+	client, err := c.s.logStreamer.StreamLogs(clientID, req.GetSlug(), fromLine, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start log stream: %v", err)
+	}
+	defer c.s.logStreamer.StopStream(clientID)
+
+	for {
+		select {
+		case entry, ok := <-client.Ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(logEntryMessage(entry)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func logEntryMessage(entry logs.LogEntry) *pb.LogEntry {
+	return &pb.LogEntry{
+		Timestamp: timestamppb.New(entry.Timestamp),
+		Process:   entry.Process,
+		Level:     entry.Level,
+		Message:   entry.Message,
+		Line:      entry.Line,
+		Event:     entry.Event,
+		Count:     entry.Count,
+	}
+}
+
+func (c *controlServer) StreamHealth(req *pb.StreamHealthRequest, stream pb.Control_StreamHealthServer) error {
+	if c.s.healthMonitor == nil {
+		return status.Error(codes.Unavailable, "health monitoring not available")
+	}
+	ticker := time.NewTicker(healthEventInterval)
+	defer ticker.Stop()
+	last := map[string]health.Status{}
+	for {
+		current := c.s.healthMonitor.HealthStatuses()
+		for name, st := range current {
+			if prev, ok := last[name]; !ok || prev != st {
+				if err := stream.Send(&pb.HealthEvent{Name: name, Status: string(st)}); err != nil {
+					return err
+				}
+			}
+		}
+		for name := range last {
+			if _, ok := current[name]; !ok {
+				if err := stream.Send(&pb.HealthEvent{Name: name, Status: "removed"}); err != nil {
+					return err
+				}
+			}
+		}
+		last = current
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// writable refuses changes while the daemon is in maintenance mode, as the
+// REST API does.
+func (c *controlServer) writable() error {
+	if c.s.InMaintenance() {
+		return status.Error(codes.FailedPrecondition, "maintenance mode: the daemon is read-only until maintenance ends")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"mcp/manager/internal/logs"
+	"mcp/manager/internal/registry"
+	pb "mcp/manager/proto"
+)
+
+func TestControlInterface(t *testing.T) {
+	logsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(logsDir, "fs.log"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	streamer := logs.NewLogStreamer(logsDir)
+	streamer.Start()
+	defer streamer.Stop()
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{Name: "fs", Slug: "fs"}}}
+	s := NewServer(reg).WithSupervisor(stubSupervisor{}).WithLogStreamer(streamer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go s.ServeControl(ctx, ln)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewControlClient(conn)
+
+	if _, err := client.RestartServer(ctx, &pb.ServerRequest{Slug: "fs"}); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	if _, err := client.StopServer(ctx, &pb.ServerRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("missing slug: %v", err)
+	}
+	if _, err := client.Install(ctx, &pb.InstallRequest{Uri: "x"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("install without type or slug: %v", err)
+	}
+
+	from := int64(0)
+	stream, err := client.StreamLogs(ctx, &pb.StreamLogsRequest{Slug: "fs", FromLine: &from})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.GetMessage() != "hello" || entry.GetLine() != 1 || entry.GetProcess() != "fs" {
+		t.Fatalf("expected the existing line, got %v", entry)
+	}
+}
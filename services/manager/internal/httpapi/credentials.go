@@ -2,16 +2,20 @@ package httpapi
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"mcp/manager/internal/health"
 	"mcp/manager/internal/providers"
+	"mcp/manager/internal/registry"
 	"mcp/manager/internal/vault"
 )
 
@@ -20,6 +24,8 @@ type CredentialManager struct {
 	vault         *vault.KeychainVault
 	healthChecker *health.ExternalHealthChecker
 	rateLimiter   *rateLimiter
+	audit         *credentialAudit
+	refreshMu     sync.Mutex // one token refresh at a time, as providers may rotate the refresh token
 }
 
 // NewCredentialManager creates a new credential manager
@@ -33,64 +39,217 @@ func NewCredentialManager() (*CredentialManager, error) {
 		vault:         keychainVault,
 		healthChecker: health.NewExternalHealthChecker(),
 		rateLimiter:   newRateLimiter(),
+		audit:         newCredentialAudit(),
 	}, nil
 }
 
+// SetRootCAs makes credential validation checks trust rootCAs
+func (cm *CredentialManager) SetRootCAs(rootCAs *x509.CertPool) {
+	cm.healthChecker.SetRootCAs(rootCAs)
+}
+
+// MigrateRegistry moves legacy inline external credentials from reg into the
+// vault and moves servers that referenced a provider-scoped entry onto their
+// own ref, leaving that entry as the provider default. It reports whether reg
+// changed and needs saving.
+func (cm *CredentialManager) MigrateRegistry(reg *registry.Registry) (bool, error) {
+	rescoped := registry.MigrateProviderCredentialRefs(reg)
+	for _, slug := range rescoped {
+		log.Printf("[AUDIT] External server %s now uses its provider's credentials as a default", slug)
+	}
+	migrated, err := registry.MigrateLegacyCredentials(reg, cm.vault)
+	for _, slug := range migrated {
+		provider := ""
+		for _, sv := range reg.Servers {
+			if sv.Slug == slug && sv.External != nil {
+				provider = sv.External.Provider
+			}
+		}
+		cm.auditCredential(nil, "migrate", provider, slug, "success")
+	}
+	return len(rescoped)+len(migrated) > 0, err
+}
+
+// Resolve looks up an external server's credentials: ref first, then the
+// provider default.
+func (cm *CredentialManager) Resolve(ref, provider string) (map[string]string, string, error) {
+	return cm.vault.Resolve(ref, provider)
+}
+
+// RefreshCredentials renews the OAuth access token of the credentials Resolve
+// finds for ref and provider, and stores the result back under the same key.
+func (cm *CredentialManager) RefreshCredentials(ctx context.Context, ref, provider string) (map[string]string, error) {
+	cm.refreshMu.Lock()
+	defer cm.refreshMu.Unlock()
+
+	creds, key, err := cm.vault.Resolve(ref, provider)
+	if err != nil {
+		return nil, err
+	}
+	refreshed, err := providers.RefreshToken(ctx, provider, creds)
+	if err != nil {
+		log.Printf("Token refresh failed for %s credentials: %v", key, err)
+		cm.auditCredential(nil, "refresh", provider, "", "refresh_failed")
+		return nil, err
+	}
+	if err := cm.vault.Update(key, refreshed); err != nil {
+		cm.auditCredential(nil, "refresh", provider, "", "error")
+		return nil, fmt.Errorf("failed to store refreshed credentials: %w", err)
+	}
+	cm.auditCredential(nil, "refresh", provider, "", "success")
+	return refreshed, nil
+}
+
+// CredentialExpiry returns when the access token Resolve finds for ref and
+// provider expires, or nil if it doesn't or that is unknown.
+func (cm *CredentialManager) CredentialExpiry(ref, provider string) *time.Time {
+	creds, _, err := cm.vault.Resolve(ref, provider)
+	if err != nil {
+		return nil
+	}
+	return providers.CredentialExpiry(provider, creds)
+}
+
+// Retrieve returns the vault item stored under ref, such as a server's secret
+// env values.
+func (cm *CredentialManager) Retrieve(ref string) (map[string]string, error) {
+	return cm.vault.Retrieve(ref)
+}
+
+// SetValidationRateLimit allows max validation attempts per provider within
+// window. With perClient set, each client address gets its own allowance per
+// provider. Values below 1 keep the defaults.
+func (cm *CredentialManager) SetValidationRateLimit(max int, window time.Duration, perClient bool) {
+	cm.rateLimiter.configure(max, window, perClient)
+}
+
+// Default credential validation rate limit
+const (
+	DefaultValidationMaxAttempts = 5
+	DefaultValidationWindow      = time.Minute
+)
+
 // Rate limiter for credential validation attempts
 type rateLimiter struct {
-	attempts map[string][]time.Time
-	mu       sync.RWMutex
+	attempts  map[string][]time.Time
+	max       int
+	window    time.Duration
+	perClient bool
+	lastPurge time.Time
+	mu        sync.RWMutex
 }
 
 func newRateLimiter() *rateLimiter {
 	return &rateLimiter{
 		attempts: make(map[string][]time.Time),
+		max:      DefaultValidationMaxAttempts,
+		window:   DefaultValidationWindow,
+	}
+}
+
+func (rl *rateLimiter) configure(max int, window time.Duration, perClient bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.max = DefaultValidationMaxAttempts
+	if max > 0 {
+		rl.max = max
 	}
+	rl.window = DefaultValidationWindow
+	if window > 0 {
+		rl.window = window
+	}
+	rl.perClient = perClient
 }
 
-// isRateLimited checks if validation attempts are rate limited (max 5 per minute)
-func (rl *rateLimiter) isRateLimited(provider string) bool {
+// key returns the bucket for a provider and client address.
+func (rl *rateLimiter) key(provider, client string) string {
 	rl.mu.RLock()
-	attempts := rl.attempts[provider]
-	rl.mu.RUnlock()
+	defer rl.mu.RUnlock()
 
-	now := time.Now()
-	cutoff := now.Add(-1 * time.Minute)
+	if rl.perClient && client != "" {
+		return provider + "|" + client
+	}
+	return provider
+}
+
+// rateLimitStatus describes a bucket's allowance.
+type rateLimitStatus struct {
+	Limit      int
+	Remaining  int
+	Window     time.Duration
+	RetryAfter time.Duration // until the oldest attempt leaves the window
+}
 
-	// Count attempts in the last minute
-	validAttempts := 0
-	for _, attempt := range attempts {
+// status reports the allowance of key at now. Callers hold rl.mu.
+func (rl *rateLimiter) status(key string, now time.Time) rateLimitStatus {
+	cutoff := now.Add(-rl.window)
+	st := rateLimitStatus{Limit: rl.max, Window: rl.window}
+
+	var oldest time.Time
+	count := 0
+	for _, attempt := range rl.attempts[key] {
 		if attempt.After(cutoff) {
-			validAttempts++
+			if count == 0 {
+				oldest = attempt
+			}
+			count++
 		}
 	}
-
-	return validAttempts >= 5
+	st.Remaining = rl.max - count
+	if st.Remaining < 0 {
+		st.Remaining = 0
+	}
+	if st.Remaining == 0 && !oldest.IsZero() {
+		st.RetryAfter = oldest.Add(rl.window).Sub(now)
+	}
+	return st
 }
 
-// recordAttempt records a validation attempt
-func (rl *rateLimiter) recordAttempt(provider string) {
+// allow records an attempt for key unless its allowance is used up, and
+// reports the allowance after the attempt.
+func (rl *rateLimiter) allow(key string) (rateLimitStatus, bool) {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	now := time.Now()
-	attempts := rl.attempts[provider]
+	if st := rl.status(key, now); st.Remaining == 0 {
+		return st, false
+	}
+	rl.record(key, now)
+	return rl.status(key, now), true
+}
+
+// record adds an attempt and drops the ones outside the window. Once per
+// window it also forgets buckets with no recent attempts, so providers and
+// clients that stop validating don't accumulate. Callers hold rl.mu.
+func (rl *rateLimiter) record(key string, now time.Time) {
+	cutoff := now.Add(-rl.window)
+	if now.Sub(rl.lastPurge) >= rl.window {
+		for k, attempts := range rl.attempts {
+			if len(attempts) == 0 || !attempts[len(attempts)-1].After(cutoff) {
+				delete(rl.attempts, k)
+			}
+		}
+		rl.lastPurge = now
+	}
 
-	// Clean old attempts
-	cutoff := now.Add(-1 * time.Minute)
 	var validAttempts []time.Time
-	for _, attempt := range attempts {
+	for _, attempt := range rl.attempts[key] {
 		if attempt.After(cutoff) {
 			validAttempts = append(validAttempts, attempt)
 		}
 	}
-
-	// Add new attempt
-	validAttempts = append(validAttempts, now)
-	rl.attempts[provider] = validAttempts
+	rl.attempts[key] = append(validAttempts, now)
 }
 
-// API request/response structures
+// size returns the number of tracked buckets.
+func (rl *rateLimiter) size() int {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	return len(rl.attempts)
+}
 
 type StoreCredentialsRequest struct {
 	Provider    string            `json:"provider"`
@@ -133,6 +292,15 @@ type ValidateCredentialsResponse struct {
 	Status      string                 `json:"status"`
 	Message     string                 `json:"message"`
 	HealthCheck *health.ExternalHealth `json:"healthCheck,omitempty"`
+	RateLimit   *RateLimitInfo         `json:"rateLimit,omitempty"`
+}
+
+// RateLimitInfo tells a rate limited client how long to back off.
+type RateLimitInfo struct {
+	Limit         int `json:"limit"`
+	Remaining     int `json:"remaining"`
+	WindowSec     int `json:"windowSec"`
+	RetryAfterSec int `json:"retryAfterSec"`
 }
 
 type CredentialStatusResponse struct {
@@ -214,8 +382,10 @@ func (s *Server) handleCredentialsStore(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Store credentials securely
+	providers.RecordExpiry(req.Provider, req.Credentials)
 	if err := s.credentialManager.vault.Store(req.Provider, req.Credentials); err != nil {
 		log.Printf("Error storing credentials for provider %s: %v", req.Provider, err)
+		s.auditCredential(r, "store", req.Provider, "", "error")
 		w.WriteHeader(http.StatusInternalServerError)
 		writeJSON(w, StoreCredentialsResponse{
 			Success: false,
@@ -224,7 +394,7 @@ func (s *Server) handleCredentialsStore(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Printf("[AUDIT] Credentials stored successfully for provider: %s", req.Provider)
+	s.auditCredential(r, "store", req.Provider, "", "success")
 	writeJSON(w, StoreCredentialsResponse{
 		Success: true,
 		Message: "Credentials stored successfully",
@@ -345,8 +515,10 @@ func (s *Server) handleCredentialsUpdate(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Update credentials
+	providers.RecordExpiry(provider, req.Credentials)
 	if err := s.credentialManager.vault.Update(provider, req.Credentials); err != nil {
 		if strings.Contains(err.Error(), "not found") {
+			s.auditCredential(r, "update", provider, "", "not_found")
 			w.WriteHeader(http.StatusNotFound)
 			writeJSON(w, UpdateCredentialsResponse{
 				Success: false,
@@ -356,6 +528,7 @@ func (s *Server) handleCredentialsUpdate(w http.ResponseWriter, r *http.Request)
 		}
 
 		log.Printf("Error updating credentials for provider %s: %v", provider, err)
+		s.auditCredential(r, "update", provider, "", "error")
 		w.WriteHeader(http.StatusInternalServerError)
 		writeJSON(w, UpdateCredentialsResponse{
 			Success: false,
@@ -364,7 +537,7 @@ func (s *Server) handleCredentialsUpdate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Printf("[AUDIT] Credentials updated successfully for provider: %s", provider)
+	s.auditCredential(r, "update", provider, "", "success")
 	writeJSON(w, UpdateCredentialsResponse{
 		Success: true,
 		Message: "Credentials updated successfully",
@@ -412,6 +585,7 @@ func (s *Server) handleCredentialsDelete(w http.ResponseWriter, r *http.Request)
 	// Delete credentials
 	if err := s.credentialManager.vault.Delete(provider); err != nil {
 		log.Printf("Error deleting credentials for provider %s: %v", provider, err)
+		s.auditCredential(r, "delete", provider, "", "error")
 		w.WriteHeader(http.StatusInternalServerError)
 		writeJSON(w, DeleteCredentialsResponse{
 			Success: false,
@@ -420,7 +594,7 @@ func (s *Server) handleCredentialsDelete(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	log.Printf("[AUDIT] Credentials deleted successfully for provider: %s", provider)
+	s.auditCredential(r, "delete", provider, "", "success")
 	writeJSON(w, DeleteCredentialsResponse{
 		Success: true,
 		Message: "Credentials deleted successfully",
@@ -472,20 +646,31 @@ func (s *Server) handleCredentialsValidate(w http.ResponseWriter, r *http.Reques
 		s.credentialManager = cm
 	}
 
-	// Check rate limiting
-	if s.credentialManager.rateLimiter.isRateLimited(req.Provider) {
+	// Check rate limiting and record this attempt
+	limiter := s.credentialManager.rateLimiter
+	st, ok := limiter.allow(limiter.key(req.Provider, clientAddr(r)))
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(st.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(st.Remaining))
+	if !ok {
+		// Round up so a client that waits this long is let through
+		retryAfter := int((st.RetryAfter + time.Second - 1) / time.Second)
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		s.auditCredential(r, "validate", req.Provider, "", "rate_limited")
 		w.WriteHeader(http.StatusTooManyRequests)
 		writeJSON(w, ValidateCredentialsResponse{
 			Valid:   false,
 			Status:  "rate_limited",
 			Message: "Too many validation attempts. Please wait before trying again.",
+			RateLimit: &RateLimitInfo{
+				Limit:         st.Limit,
+				Remaining:     st.Remaining,
+				WindowSec:     int(st.Window / time.Second),
+				RetryAfterSec: retryAfter,
+			},
 		})
 		return
 	}
 
-	// Record this attempt
-	s.credentialManager.rateLimiter.recordAttempt(req.Provider)
-
 	// Validate provider exists
 	if !providers.IsProviderSupported(req.Provider) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -499,6 +684,7 @@ func (s *Server) handleCredentialsValidate(w http.ResponseWriter, r *http.Reques
 
 	// Validate credential format first
 	if err := providers.ValidateProviderConfig(req.Provider, req.Credentials); err != nil {
+		s.auditCredential(r, "validate", req.Provider, "", "invalid_format")
 		writeJSON(w, ValidateCredentialsResponse{
 			Valid:   false,
 			Status:  "invalid_format",
@@ -536,6 +722,7 @@ func (s *Server) handleCredentialsValidate(w http.ResponseWriter, r *http.Reques
 	healthCheck, err := s.credentialManager.healthChecker.CheckHealth(ctx, providerInfo.HealthEndpoint, apiKey)
 	if err != nil {
 		log.Printf("Error during health check for provider %s: %v", req.Provider, err)
+		s.auditCredential(r, "validate", req.Provider, "", "health_check_failed")
 		writeJSON(w, ValidateCredentialsResponse{
 			Valid:       false,
 			Status:      "health_check_failed",
@@ -560,7 +747,7 @@ func (s *Server) handleCredentialsValidate(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	log.Printf("[AUDIT] Credential validation performed for provider: %s, result: %s", req.Provider, status)
+	s.auditCredential(r, "validate", req.Provider, "", status)
 
 	writeJSON(w, ValidateCredentialsResponse{
 		Valid:       valid,
@@ -604,7 +791,9 @@ func (s *Server) handleCredentialsStatus(w http.ResponseWriter, r *http.Request)
 }
 
 // handleCredentialsValidateStored handles POST /v1/credentials/validate-stored
-// Body: { "provider": "name" }
+// Body: { "provider": "name", "slug": "optional external server" }
+// With a slug the server's own credentials are checked, falling back to the
+// provider default the same way its health checks do.
 func (s *Server) handleCredentialsValidateStored(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -613,6 +802,7 @@ func (s *Server) handleCredentialsValidateStored(w http.ResponseWriter, r *http.
 
 	var body struct {
 		Provider string `json:"provider"`
+		Slug     string `json:"slug"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Provider == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -629,8 +819,15 @@ func (s *Server) handleCredentialsValidateStored(w http.ResponseWriter, r *http.
 		s.credentialManager = cm
 	}
 
-	creds, err := s.credentialManager.vault.Retrieve(body.Provider)
+	ref := ""
+	if body.Slug != "" {
+		if sv := s.findServer(body.Slug); sv != nil && sv.IsExternal() {
+			ref = sv.External.CredentialRef
+		}
+	}
+	creds, _, err := s.credentialManager.Resolve(ref, body.Provider)
 	if err != nil {
+		s.auditCredential(r, "validate-stored", body.Provider, body.Slug, "not_found")
 		w.WriteHeader(http.StatusNotFound)
 		writeJSON(w, map[string]string{"error": "stored credentials not found"})
 		return
@@ -641,6 +838,7 @@ func (s *Server) handleCredentialsValidateStored(w http.ResponseWriter, r *http.
 
 	hc, err := s.credentialManager.healthChecker.CheckProviderHealth(ctx, body.Provider, creds)
 	if err != nil {
+		s.auditCredential(r, "validate-stored", body.Provider, body.Slug, "health_check_failed")
 		writeJSON(w, ValidateCredentialsResponse{Valid: false, Status: "health_check_failed", Message: "Failed during provider health check", HealthCheck: hc})
 		return
 	}
@@ -657,6 +855,7 @@ func (s *Server) handleCredentialsValidateStored(w http.ResponseWriter, r *http.
 			msg = "Service unavailable or insufficient scopes"
 		}
 	}
+	s.auditCredential(r, "validate-stored", body.Provider, body.Slug, status)
 	writeJSON(w, ValidateCredentialsResponse{Valid: valid, Status: status, Message: msg, HealthCheck: hc})
 }
 
@@ -691,3 +890,11 @@ func (s *Server) addCredentialRoutes(mux *http.ServeMux) {
 	})
 	mux.HandleFunc("/v1/credentials/validate", s.handleCredentialsValidate)
 }
+
+// clientAddr returns the host part of the request's remote address.
+func clientAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
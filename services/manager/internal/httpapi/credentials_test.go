@@ -56,27 +56,86 @@ func TestRateLimiter(t *testing.T) {
 	rl := newRateLimiter()
 	provider := "test-provider"
 
-	// Should not be rate limited initially
-	if rl.isRateLimited(provider) {
-		t.Error("Should not be rate limited initially")
-	}
-
-	// Add 5 attempts (the limit)
+	// The first 5 attempts (the limit) are allowed
 	for i := 0; i < 5; i++ {
-		rl.recordAttempt(provider)
+		if _, ok := rl.allow(provider); !ok {
+			t.Fatalf("attempt %d was rate limited", i+1)
+		}
 	}
 
 	// Should now be rate limited
-	if !rl.isRateLimited(provider) {
-		t.Error("Should be rate limited after 5 attempts")
+	if st, ok := rl.allow(provider); ok || st.Remaining != 0 {
+		t.Errorf("Should be rate limited after 5 attempts, got %+v", st)
 	}
 
 	// Test that different providers are independent
-	if rl.isRateLimited("other-provider") {
+	if _, ok := rl.allow("other-provider"); !ok {
 		t.Error("Different provider should not be rate limited")
 	}
 }
 
+func TestRateLimiterConfigured(t *testing.T) {
+	rl := newRateLimiter()
+	rl.configure(2, 50*time.Millisecond, true)
+
+	// Each client gets its own allowance per provider
+	a, b := rl.key("notion", "10.0.0.1"), rl.key("notion", "10.0.0.2")
+	if a == b {
+		t.Fatalf("per-client keys collide: %q", a)
+	}
+	for i, want := range []int{1, 0} {
+		st, ok := rl.allow(a)
+		if !ok || st.Remaining != want || st.Limit != 2 {
+			t.Fatalf("attempt %d: %+v, %v", i+1, st, ok)
+		}
+	}
+	st, ok := rl.allow(a)
+	if ok || st.RetryAfter <= 0 || st.RetryAfter > 50*time.Millisecond {
+		t.Fatalf("third attempt: %+v, %v", st, ok)
+	}
+	if _, ok := rl.allow(b); !ok {
+		t.Fatal("other client was rate limited")
+	}
+
+	// Buckets without recent attempts are purged
+	time.Sleep(60 * time.Millisecond)
+	rl.allow(rl.key("github", ""))
+	if n := rl.size(); n != 1 {
+		t.Fatalf("%d buckets after purge, want 1", n)
+	}
+}
+
+func TestCredentialsValidateRateLimited(t *testing.T) {
+	rl := newRateLimiter()
+	rl.configure(1, time.Minute, false)
+	server := &Server{credentialManager: &CredentialManager{rateLimiter: rl}}
+
+	validate := func() *httptest.ResponseRecorder {
+		body := bytes.NewReader([]byte(`{"provider":"unknown-provider","credentials":{}}`))
+		rr := httptest.NewRecorder()
+		server.handleCredentialsValidate(rr, httptest.NewRequest(http.MethodPost, "/v1/credentials/validate", body))
+		return rr
+	}
+
+	if rr := validate(); rr.Code == http.StatusTooManyRequests || rr.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("first attempt: %d, remaining %q", rr.Code, rr.Header().Get("X-RateLimit-Remaining"))
+	}
+	rr := validate()
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After = %q", got)
+	}
+	var resp ValidateCredentialsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RateLimit == nil || resp.RateLimit.Limit != 1 || resp.RateLimit.Remaining != 0 || resp.RateLimit.WindowSec != 60 {
+		t.Fatalf("rateLimit = %+v", resp.RateLimit)
+	}
+}
+
 func TestCredentialsStoreAPI(t *testing.T) {
 	// Create a test server
 	server := &Server{
@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"mcp/manager/internal/install"
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
+)
+
+// Doctor check results, from best to worst.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// managerAddr is where the daemon serves the HTTP API.
+const managerAddr = "127.0.0.1:7099"
+
+// Free space thresholds for the servers partition.
+const (
+	doctorDiskFail = 500 << 20 // installers refuse to run below this
+	doctorDiskWarn = 2 << 30
+)
+
+// doctorTimeout bounds the tool probes, which shell out to each tool.
+const doctorTimeout = 20 * time.Second
+
+// DoctorCheck is one diagnostic result.
+type DoctorCheck struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"` // pass, warn or fail
+	Detail      string `json:"detail"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// DoctorReport is the response of GET /v1/doctor. Status is the worst check status.
+type DoctorReport struct {
+	Status string        `json:"status"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+func (d *DoctorReport) add(c DoctorCheck) {
+	d.Checks = append(d.Checks, c)
+	if doctorSeverity(c.Status) > doctorSeverity(d.Status) {
+		d.Status = c.Status
+	}
+}
+
+func doctorSeverity(status string) int {
+	switch status {
+	case checkFail:
+		return 2
+	case checkWarn:
+		return 1
+	}
+	return 0
+}
+
+// handleDoctor handles GET /v1/doctor. It runs the setup checks a user would
+// otherwise do by hand: installer and build tools, the vault, disk space, the registry
+// file, the API port and every server's on-disk install.
+func (s *Server) handleDoctor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), doctorTimeout)
+	defer cancel()
+
+	report := &DoctorReport{Status: checkPass, Checks: []DoctorCheck{}}
+	for _, tool := range install.DetectTools(ctx, install.ExecRunner{}) {
+		report.add(toolCheck(tool))
+	}
+	for _, tool := range install.DetectBuildTools(ctx, install.ExecRunner{}) {
+		report.add(buildToolCheck(tool))
+	}
+	report.add(s.vaultCheck())
+	report.add(diskCheck())
+	report.add(registryCheck())
+	report.add(portCheck(r))
+	for _, sv := range s.reg.Servers {
+		report.add(verifyCheck(sv))
+	}
+
+	writeJSON(w, report)
+}
+
+// handleInstallCapabilities handles GET /v1/install/capabilities, telling a
+// client which source types it can offer before the user picks one.
+func (s *Server) handleInstallCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), doctorTimeout)
+	defer cancel()
+	writeJSON(w, install.DetectCapabilities(ctx, install.ExecRunner{}))
+}
+
+func toolCheck(tool install.ToolStatus) DoctorCheck {
+	c := DoctorCheck{Name: "tool:" + tool.Name}
+	switch {
+	case tool.Found:
+		c.Status = checkPass
+		c.Detail = strings.TrimSpace(tool.Command + " " + tool.Version)
+	case tool.Required:
+		c.Status = checkFail
+		c.Detail = tool.Name + " not found"
+		c.Remediation = fmt.Sprintf("Install %s and make sure it is on the manager's PATH", tool.Name)
+	default:
+		c.Status = checkWarn
+		c.Detail = tool.Name + " not found (optional)"
+		c.Remediation = fmt.Sprintf("Install %s only if a server needs it", tool.Name)
+	}
+	return c
+}
+
+// buildToolCheck only warns: build tools matter for servers with native code.
+func buildToolCheck(tool install.ToolStatus) DoctorCheck {
+	c := DoctorCheck{Name: "build:" + tool.Name}
+	if tool.Found {
+		c.Status = checkPass
+		c.Detail = strings.TrimSpace(tool.Command + " " + tool.Version)
+		return c
+	}
+	c.Status = checkWarn
+	c.Detail = tool.Name + " not found; servers with native code will fail to install"
+	c.Remediation = "To build native code, " + install.BuildToolsHint()
+	return c
+}
+
+func (s *Server) vaultCheck() DoctorCheck {
+	c := DoctorCheck{Name: "vault"}
+	err := s.ensureCredentialManager()
+	if err == nil {
+		_, err = s.credentialManager.vault.List()
+	}
+	if err != nil {
+		dir, _ := paths.SecretsDir()
+		c.Status = checkFail
+		c.Detail = err.Error()
+		c.Remediation = fmt.Sprintf("Check that %s exists and is writable only by you (0700)", dir)
+		return c
+	}
+	c.Status = checkPass
+	c.Detail = "credential vault is readable"
+	return c
+}
+
+func diskCheck() DoctorCheck {
+	c := DoctorCheck{Name: "disk"}
+	dir, err := paths.ServersDir()
+	var free uint64
+	if err == nil {
+		free, err = install.FreeDiskSpace(dir)
+	}
+	if err != nil {
+		c.Status = checkWarn
+		c.Detail = fmt.Sprintf("could not determine free space: %v", err)
+		return c
+	}
+	c.Detail = fmt.Sprintf("%d MB free on %s", free>>20, dir)
+	switch {
+	case free < doctorDiskFail:
+		c.Status = checkFail
+		c.Remediation = "Free up disk space; installs need at least 500 MB"
+	case free < doctorDiskWarn:
+		c.Status = checkWarn
+		c.Remediation = "Free up disk space before installing more servers"
+	default:
+		c.Status = checkPass
+	}
+	return c
+}
+
+func registryCheck() DoctorCheck {
+	c := DoctorCheck{Name: "registry"}
+	path, _ := registry.DefaultPath()
+	reg, err := registry.LoadDefault()
+	if errors.Is(err, os.ErrNotExist) {
+		c.Status = checkPass
+		c.Detail = fmt.Sprintf("%s not created yet; it is written on the first install", path)
+		return c
+	}
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = err.Error()
+		c.Remediation = fmt.Sprintf("Fix or restore %s", path)
+		return c
+	}
+	c.Status = checkPass
+	c.Detail = fmt.Sprintf("%s parsed, %d servers", path, len(reg.Servers))
+	return c
+}
+
+// portCheck reports on the API port. When this request arrived on it the
+// manager itself holds the port; otherwise a test bind shows whether it is free.
+func portCheck(r *http.Request) DoctorCheck {
+	c := DoctorCheck{Name: "port"}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok && addr.String() == managerAddr {
+		c.Status = checkPass
+		c.Detail = "manager is listening on " + managerAddr
+		return c
+	}
+	ln, err := net.Listen("tcp", managerAddr)
+	if err != nil {
+		c.Status = checkFail
+		c.Detail = fmt.Sprintf("cannot bind %s: %v", managerAddr, err)
+		if errors.Is(err, syscall.EADDRINUSE) {
+			c.Remediation = "Another process holds port 7099; stop it or any second manager instance"
+		}
+		return c
+	}
+	ln.Close()
+	c.Status = checkPass
+	c.Detail = managerAddr + " is free"
+	return c
+}
+
+func verifyCheck(sv registry.Server) DoctorCheck {
+	c := DoctorCheck{Name: "server:" + sv.Slug}
+	report := install.VerifyServer(sv)
+	if report.NeedsRepair {
+		c.Status = checkFail
+		c.Detail = strings.Join(report.Issues, "; ")
+		c.Remediation = fmt.Sprintf("Reinstall %s", sv.Slug)
+		return c
+	}
+	c.Status = checkPass
+	c.Detail = "installed files verified"
+	return c
+}
@@ -0,0 +1,57 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestDoctor(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+		{Name: "fs", Slug: "fs", Entry: registry.Entry{Command: "./bin/missing"}},
+	}}
+	rr := httptest.NewRecorder()
+	NewServer(reg).Router().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/doctor", nil))
+	if rr.Code != 200 {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var report DoctorReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]DoctorCheck{}
+	for _, c := range report.Checks {
+		if c.Status != checkPass && c.Status != checkWarn && c.Status != checkFail {
+			t.Fatalf("bad status in %+v", c)
+		}
+		checks[c.Name] = c
+	}
+	for _, name := range []string{"tool:git", "tool:python", "build:C compiler", "vault", "disk", "registry", "port"} {
+		if _, ok := checks[name]; !ok {
+			t.Fatalf("missing check %s in %+v", name, report.Checks)
+		}
+	}
+	// a fresh config dir has no registry file, which is not a problem
+	if checks["registry"].Status != checkPass {
+		t.Fatalf("registry: %+v", checks["registry"])
+	}
+	fs := checks["server:fs"]
+	if fs.Status != checkFail || fs.Remediation == "" {
+		t.Fatalf("server:fs should fail verification: %+v", fs)
+	}
+	if report.Status != checkFail {
+		t.Fatalf("overall status should be worst check, got %s", report.Status)
+	}
+
+	rr = httptest.NewRecorder()
+	NewServer(reg).Router().ServeHTTP(rr, httptest.NewRequest("POST", "/v1/doctor", nil))
+	if rr.Code != 405 {
+		t.Fatalf("POST: expected 405, got %d", rr.Code)
+	}
+}
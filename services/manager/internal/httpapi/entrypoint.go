@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"mcp/manager/internal/install"
+	"mcp/manager/internal/registry"
+)
+
+// handleServerEntryPoint handles PUT /v1/servers/{slug}/entrypoint. It
+// switches the server to another entry point its installer recorded and
+// regenerates the bin script, so picking a different executable the package
+// provides doesn't need a reinstall. A running process keeps its old command
+// until it is restarted.
+func (s *Server) handleServerEntryPoint(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	sv := s.findServer(slug)
+	if sv == nil {
+		http.Error(w, fmt.Sprintf("unknown server: %s", slug), http.StatusNotFound)
+		return
+	}
+
+	// Work on a copy so a failure leaves the registry as it was
+	entry := sv.Entry
+	if err := entry.SelectEntryPoint(body.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := install.WriteBinScript(slug, entry); err != nil {
+		http.Error(w, fmt.Sprintf("failed to write bin script: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sv.Entry = entry
+	if err := registry.SaveDefault(s.reg); err != nil {
+		http.Error(w, "failed to save registry", http.StatusInternalServerError)
+		return
+	}
+	restarted := s.syncSupervisor()
+	log.Printf("[AUDIT] Server %s now runs entry point %s", slug, body.Name)
+
+	writeJSON(w, map[string]interface{}{
+		"status":     "ok",
+		"entryPoint": entry.EntryPoint,
+		"command":    entry.Command,
+		"args":       entry.Args,
+		"restarted":  restarted,
+	})
+}
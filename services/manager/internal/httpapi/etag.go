@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// writeJSONCached writes v like writeJSON, tagged with an ETag over the
+// encoded body. A client that sends the same tag in If-None-Match gets a 304.
+func writeJSONCached(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n') // match json.Encoder output
+	sum := sha256.Sum256(body)
+	writeWithETag(w, r, fmt.Sprintf(`"%x"`, sum[:16]), body)
+}
+
+// fileETag derives an ETag from a file's modification time and size, so an
+// unchanged file can be answered without reading it.
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// writeWithETag sets the ETag header and writes body as JSON, or only a 304
+// when If-None-Match already names etag. The 304 still carries the ETag.
+func writeWithETag(w http.ResponseWriter, r *http.Request, etag string, body []byte) {
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// etagMatches applies the weak comparison If-None-Match calls for.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
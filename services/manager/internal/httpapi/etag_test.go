@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestConditionalGET(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MCP_HOME", "")
+	cursor := filepath.Join(home, ".cursor", "mcp.json")
+	if err := os.MkdirAll(filepath.Dir(cursor), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cursor, []byte(`{"mcpServers":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{Name: "fs", Slug: "fs"}}}
+	h := NewServer(reg).Router()
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	current := "/v1/clients/current?client=" + url.QueryEscape("Cursor (Global)")
+	for _, path := range []string{"/v1/servers", "/v1/external/providers", "/v1/settings", current} {
+		first := get(path, "")
+		etag := first.Header().Get("ETag")
+		if first.Code != 200 || etag == "" {
+			t.Fatalf("%s: status %d etag %q", path, first.Code, etag)
+		}
+		if again := get(path, ""); again.Header().Get("ETag") != etag {
+			t.Fatalf("%s: ETag not stable: %q vs %q", path, etag, again.Header().Get("ETag"))
+		}
+		cached := get(path, `"other", `+etag)
+		if cached.Code != 304 || cached.Header().Get("ETag") != etag || cached.Body.Len() != 0 {
+			t.Fatalf("%s: expected bare 304 with ETag, got %d %q %q", path, cached.Code, cached.Header().Get("ETag"), cached.Body.String())
+		}
+		if stale := get(path, `"stale"`); stale.Code != 200 {
+			t.Fatalf("%s: stale ETag should get 200, got %d", path, stale.Code)
+		}
+	}
+
+	etag := get(current, "").Header().Get("ETag")
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(cursor, []byte(`{"mcpServers":{"fs":{}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(cursor, later, later)
+	if rr := get(current, etag); rr.Code != 200 || rr.Header().Get("ETag") == etag {
+		t.Fatalf("changed file should invalidate ETag, got %d", rr.Code)
+	}
+}
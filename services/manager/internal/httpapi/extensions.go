@@ -52,18 +52,15 @@ func (s *Server) handleServerEnv(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    // Update environment variables
-    if s.reg.Servers[serverIndex].Entry.Env == nil {
-        s.reg.Servers[serverIndex].Entry.Env = make(map[string]string)
+    // Update environment variables; empty values remove a key and values
+    // for secret required env go to the vault
+    var secrets registry.SecretStore
+    if err := s.ensureCredentialManager(); err == nil {
+        secrets = s.credentialManager.vault
     }
-    
-    for key, value := range body.EnvVars {
-        if value == "" {
-            // Remove if empty
-            delete(s.reg.Servers[serverIndex].Entry.Env, key)
-        } else {
-            s.reg.Servers[serverIndex].Entry.Env[key] = value
-        }
+    if err := registry.ApplyEnv(&s.reg.Servers[serverIndex], body.EnvVars, secrets); err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
     }
     
     // Save registry
@@ -72,12 +69,14 @@ func (s *Server) handleServerEnv(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    // Update supervisor if available
-    if s.sup != nil {
-        s.sup.UpdateRegistry(s.reg)
-    }
+    // Update supervisor if available, restarting the server if configured to
+    restarted := s.syncSupervisor()
     
-    writeJSON(w, map[string]string{"status": "ok"})
+    writeJSON(w, map[string]interface{}{
+        "status":     "ok",
+        "missingEnv": s.reg.Servers[serverIndex].Entry.MissingEnv(),
+        "restarted":  restarted,
+    })
 }
 
 // handleStorageClear handles clearing of logs, cache, or all storage
@@ -2,13 +2,16 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"mcp/manager/internal/health"
 	"mcp/manager/internal/providers"
 	"mcp/manager/internal/registry"
 )
@@ -22,6 +25,96 @@ type ExternalServerRequest struct {
 	Credentials map[string]string      `json:"credentials"`
 	Config      map[string]interface{} `json:"config,omitempty"`
 	AutoStart   bool                   `json:"autoStart,omitempty"`
+	// InsecureSkipVerify disables TLS verification for this server's health checks (dev only)
+	InsecureSkipVerify *bool `json:"insecureSkipVerify,omitempty"`
+	// Health check override; on update, a nil field is left unchanged and "" or 0 clears it
+	HealthEndpoint *string `json:"healthEndpoint,omitempty"`
+	ExpectedStatus *int    `json:"expectedStatus,omitempty"`
+	ExpectedBody   *string `json:"expectedBody,omitempty"`
+	// Extra health check headers and query parameters; on update, an absent
+	// field is left unchanged and {} clears it
+	HealthHeaders map[string]string `json:"healthHeaders,omitempty"`
+	HealthQuery   map[string]string `json:"healthQuery,omitempty"`
+}
+
+// applyHealthOverride copies the request's health check fields onto ext
+func (req *ExternalServerRequest) applyHealthOverride(ext *registry.ExternalInfo) {
+	if req.HealthEndpoint != nil {
+		ext.HealthEndpoint = *req.HealthEndpoint
+	}
+	if req.ExpectedStatus != nil {
+		ext.ExpectedStatus = *req.ExpectedStatus
+	}
+	if req.ExpectedBody != nil {
+		ext.ExpectedBody = *req.ExpectedBody
+	}
+	if req.HealthHeaders != nil {
+		ext.HealthHeaders = req.HealthHeaders
+	}
+	if req.HealthQuery != nil {
+		ext.HealthQuery = req.HealthQuery
+	}
+}
+
+// healthRequest returns the extra headers and query parameters of ext's
+// health checks, with the provider template's headers as defaults.
+func healthRequest(ext *registry.ExternalInfo) health.HealthRequest {
+	provider, _ := providers.GetProvider(ext.Provider)
+	return health.HealthRequest{Headers: ext.HealthRequestHeaders(provider.HealthHeaders), Query: ext.HealthQuery}
+}
+
+// testClient returns the client external server tests use, built once per
+// root CA pool so its connections are reused between tests.
+func (s *Server) testClient(insecure bool) *http.Client {
+	s.testClientsMu.Lock()
+	defer s.testClientsMu.Unlock()
+	i := 0
+	if insecure {
+		i = 1
+	}
+	if s.testClients[i] == nil {
+		s.testClients[i] = health.NewHTTPClient(10*time.Second, s.rootCAs, insecure)
+	}
+	return s.testClients[i]
+}
+
+// MonitorExternal registers the external server sv with hm the way its
+// settings ask: the health endpoint and what it must answer, the request's
+// headers and query, the provider's component checks, the credential ref and
+// expiry, and TLS verification. cm may be nil, leaving the expiry unknown.
+func MonitorExternal(hm HealthMonitor, cm *CredentialManager, sv *registry.Server) {
+	ext := sv.GetExternalConfig()
+	var expiry *time.Time
+	if cm != nil {
+		expiry = cm.CredentialExpiry(ext.CredentialRef, ext.Provider)
+	}
+	hm.AddExternalProcess(sv.Slug, ext.Provider, ext.APIEndpoint, ext.AuthType, expiry)
+	hm.SetCredentialRef(sv.Slug, ext.CredentialRef)
+	hm.SetHealthEndpoint(sv.Slug, ext.HealthEndpoint, health.HealthExpectation{Status: ext.ExpectedStatus, Body: ext.ExpectedBody})
+	hm.SetHealthRequest(sv.Slug, healthRequest(ext))
+	hm.SetHealthComponents(sv.Slug, ComponentProbe(ext))
+	if ext.InsecureSkipVerify {
+		hm.SetInsecureSkipVerify(sv.Slug, true)
+	}
+}
+
+// ComponentProbe returns the component checks of ext's provider template.
+// A server with its own health endpoint is usually not the provider's API,
+// so the provider's components and status page are left out for it.
+func ComponentProbe(ext *registry.ExternalInfo) health.ComponentProbe {
+	provider, err := providers.GetProvider(ext.Provider)
+	if err != nil || ext.HealthEndpoint != "" {
+		return health.ComponentProbe{}
+	}
+	probe := health.ComponentProbe{StatusPage: provider.StatusPage, Follow: provider.StatusComponents}
+	for _, c := range provider.HealthComponents {
+		probe.Components = append(probe.Components, health.HealthComponent{
+			Name:      c.Name,
+			Endpoint:  c.Endpoint,
+			SlowAfter: time.Duration(c.SlowMs) * time.Millisecond,
+		})
+	}
+	return probe
 }
 
 // ExternalServerResponse represents the response for external server operations
@@ -36,6 +129,38 @@ type ExternalServerResponse struct {
 	LastSync    *time.Time             `json:"lastSync,omitempty"`
 	APIEndpoint string                 `json:"apiEndpoint"`
 	AuthType    string                 `json:"authType"`
+
+	HealthEndpoint string `json:"healthEndpoint,omitempty"`
+	ExpectedStatus int    `json:"expectedStatus,omitempty"`
+	ExpectedBody   string `json:"expectedBody,omitempty"`
+
+	// Credential profile names only; their secrets stay in the vault
+	Profiles      []string `json:"profiles,omitempty"`
+	ActiveProfile string   `json:"activeProfile,omitempty"`
+}
+
+// externalServerResponse describes an external server entry
+func externalServerResponse(server *registry.Server) ExternalServerResponse {
+	ext := server.External
+	return ExternalServerResponse{
+		Name:        server.Name,
+		Slug:        server.Slug,
+		Provider:    ext.Provider,
+		DisplayName: ext.GetDisplayName(),
+		Status:      ext.Status,
+		Config:      ext.Config,
+		AutoStart:   server.Auto != nil && server.Auto.Enabled,
+		LastSync:    ext.LastSync,
+		APIEndpoint: ext.APIEndpoint,
+		AuthType:    ext.AuthType,
+
+		HealthEndpoint: ext.HealthEndpoint,
+		ExpectedStatus: ext.ExpectedStatus,
+		ExpectedBody:   ext.ExpectedBody,
+
+		Profiles:      ext.Profiles,
+		ActiveProfile: ext.ActiveProfile,
+	}
 }
 
 // ExternalServerTestResponse represents the response for connection testing
@@ -56,6 +181,7 @@ type ExternalProviderResponse struct {
 	Credentials    []providers.Credential    `json:"credentials"`
 	ConfigSchema   map[string]interface{}    `json:"configSchema,omitempty"`
 	Tags           []string                  `json:"tags,omitempty"`
+	Custom         bool                      `json:"custom,omitempty"`
 }
 
 // handleExternalMCPs handles requests to /v1/external/servers
@@ -70,7 +196,7 @@ func (s *Server) handleExternalMCPs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// handleExternalMCPActions handles requests to /v1/external/servers/{slug} and /v1/external/servers/{slug}/test
+// handleExternalMCPActions handles requests to /v1/external/servers/{slug}, /v1/external/servers/{slug}/test and /v1/external/servers/{slug}/profile
 func (s *Server) handleExternalMCPActions(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/external/servers/"), "/")
 	if len(parts) < 1 || parts[0] == "" {
@@ -90,6 +216,16 @@ func (s *Server) handleExternalMCPActions(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Handle /v1/external/servers/{slug}/profile
+	if len(parts) == 2 && parts[1] == "profile" {
+		if r.Method == http.MethodPut {
+			s.handleSwitchExternalProfile(w, r, slug)
+		} else {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
 	// Handle /v1/external/servers/{slug}
 	if len(parts) == 1 {
 		switch r.Method {
@@ -112,22 +248,9 @@ func (s *Server) handleExternalMCPActions(w http.ResponseWriter, r *http.Request
 func (s *Server) handleListExternalServers(w http.ResponseWriter, r *http.Request) {
 	var externalServers []ExternalServerResponse
 
-	for _, server := range s.reg.Servers {
-		if server.IsExternal() {
-			ext := server.GetExternalConfig()
-			response := ExternalServerResponse{
-				Name:        server.Name,
-				Slug:        server.Slug,
-				Provider:    ext.Provider,
-				DisplayName: ext.GetDisplayName(),
-				Status:      ext.Status,
-				Config:      ext.Config,
-				AutoStart:   server.Auto != nil && server.Auto.Enabled,
-				LastSync:    ext.LastSync,
-				APIEndpoint: ext.APIEndpoint,
-				AuthType:    ext.AuthType,
-			}
-			externalServers = append(externalServers, response)
+	for i := range s.reg.Servers {
+		if server := &s.reg.Servers[i]; server.IsExternal() {
+			externalServers = append(externalServers, externalServerResponse(server))
 		}
 	}
 
@@ -149,32 +272,69 @@ func (s *Server) handleGetExternalServer(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	ext := server.GetExternalConfig()
-	response := ExternalServerResponse{
-		Name:        server.Name,
-		Slug:        server.Slug,
-		Provider:    ext.Provider,
-		DisplayName: ext.GetDisplayName(),
-		Status:      ext.Status,
-		Config:      ext.Config,
-		AutoStart:   server.Auto != nil && server.Auto.Enabled,
-		LastSync:    ext.LastSync,
-		APIEndpoint: ext.APIEndpoint,
-		AuthType:    ext.AuthType,
-	}
-
-	writeJSON(w, response)
+	writeJSON(w, externalServerResponse(server))
 }
 
-// handleCreateExternalServer handles POST /v1/external/servers
+// ensureCredentialManager creates the credential manager on first use
 func (s *Server) ensureCredentialManager() error {
 	if s.credentialManager != nil { return nil }
 	cm, err := NewCredentialManager()
 	if err != nil { return err }
+	if s.rootCAs != nil { cm.SetRootCAs(s.rootCAs) }
 	s.credentialManager = cm
 	return nil
 }
 
+// hasProviderDefault reports whether /v1/credentials has stored credentials for provider
+func (s *Server) hasProviderDefault(provider string) bool {
+	return s.ensureCredentialManager() == nil && s.credentialManager.vault.HasCredentials(provider)
+}
+
+// storeServerCredentials writes provider's creds for slug under ref, with
+// their expiry recorded, and returns a function that puts back whatever ref
+// held before, to undo the write if a later step fails. Both are audited as
+// made by r.
+func (s *Server) storeServerCredentials(r *http.Request, slug, ref, provider string, creds map[string]string) (undo func(), err error) {
+	if err := s.ensureCredentialManager(); err != nil {
+		return nil, err
+	}
+	providers.RecordExpiry(provider, creds)
+	v := s.credentialManager.vault
+	prev, prevErr := v.Retrieve(ref)
+	action := "store"
+	if prevErr == nil {
+		action = "update"
+	}
+	if err := v.Store(ref, creds); err != nil {
+		s.auditCredential(r, action, provider, slug, "error")
+		return nil, err
+	}
+	s.auditCredential(r, action, provider, slug, "success")
+	return func() {
+		if prevErr == nil {
+			_ = v.Store(ref, prev)
+			s.auditCredential(r, "update", provider, slug, "rolled_back")
+		} else {
+			_ = v.Delete(ref)
+			s.auditCredential(r, "delete", provider, slug, "rolled_back")
+		}
+	}, nil
+}
+
+// deleteServerCredentials removes what the vault holds under ref for slug,
+// if anything, auditing it as made by r.
+func (s *Server) deleteServerCredentials(r *http.Request, slug, ref, provider string) {
+	v := s.credentialManager.vault
+	if !v.HasCredentials(ref) {
+		return
+	}
+	result := "success"
+	if err := v.Delete(ref); err != nil {
+		result = "error"
+	}
+	s.auditCredential(r, "delete", provider, slug, result)
+}
+
 // handleCreateExternalServer handles POST /v1/external/servers
 func (s *Server) handleCreateExternalServer(w http.ResponseWriter, r *http.Request) {
 	var req ExternalServerRequest
@@ -206,11 +366,14 @@ func (s *Server) handleCreateExternalServer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Validate credentials
-	if err := providers.ValidateProviderConfig(req.Provider, req.Credentials); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		writeJSON(w, map[string]string{"error": fmt.Sprintf("Invalid credentials: %v", err)})
-		return
+	// Validate credentials. Without any, the server uses the provider default
+	// stored through /v1/credentials, so one has to exist.
+	if len(req.Credentials) > 0 || !s.hasProviderDefault(req.Provider) {
+		if err := providers.ValidateProviderConfig(req.Provider, req.Credentials); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": fmt.Sprintf("Invalid credentials: %v", err)})
+			return
+		}
 	}
 
 	// Create external info
@@ -224,25 +387,21 @@ func (s *Server) handleCreateExternalServer(w http.ResponseWriter, r *http.Reque
 		DisplayName:   displayName,
 		APIEndpoint:   provider.BaseURL,
 		AuthType:      string(provider.AuthType),
-		CredentialRef: "",
+		CredentialRef: registry.ServerCredentialRef(req.Provider, req.Slug),
 		Config:        req.Config,
 		Status: registry.ExternalStatus{
 			State:   "inactive",
 			Message: "Created but not tested",
 		},
 	}
-
-	// Persist credentials securely and set credential reference
-	if len(req.Credentials) > 0 {
-		if err := s.ensureCredentialManager(); err == nil {
-			credRef := fmt.Sprintf("ext:%s:%s", req.Provider, req.Slug)
-			_ = s.credentialManager.vault.Store(credRef, req.Credentials)
-			externalInfo.CredentialRef = credRef
-		}
-		// do not persist raw credentials in registry (legacy fields)
-		externalInfo.Credentials = nil
-		externalInfo.APIKey = ""
+	if req.InsecureSkipVerify != nil {
+		externalInfo.InsecureSkipVerify = *req.InsecureSkipVerify
 	}
+	req.applyHealthOverride(externalInfo)
+
+	// do not persist raw credentials in registry (legacy fields)
+	externalInfo.Credentials = nil
+	externalInfo.APIKey = ""
 
 	// Create the server entry
 	server := registry.Server{
@@ -285,13 +444,27 @@ func (s *Server) handleCreateExternalServer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Persist credentials as a server-scoped override; without one the ref
+	// resolves to the provider default
+	undoCredentials := func() {}
+	if len(req.Credentials) > 0 {
+		undo, err := s.storeServerCredentials(r, req.Slug, externalInfo.CredentialRef, externalInfo.Provider, req.Credentials)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to store credentials: %v", err)})
+			return
+		}
+		undoCredentials = undo
+	}
+
 	// Add to registry
 	s.reg.Servers = append(s.reg.Servers, server)
 
 	// Save registry
 	if err := s.saveRegistry(); err != nil {
-		// Remove the server we just added
+		// Remove the server we just added and the secret it would have used
 		s.reg.Servers = s.reg.Servers[:len(s.reg.Servers)-1]
+		undoCredentials()
 		w.WriteHeader(http.StatusInternalServerError)
 		writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to save registry: %v", err)})
 		return
@@ -304,25 +477,12 @@ func (s *Server) handleCreateExternalServer(w http.ResponseWriter, r *http.Reque
 
 	// Add to health monitoring if available
 	if s.healthMonitor != nil {
-		s.healthMonitor.AddProcess(req.Slug, "http", provider.HealthEndpoint, "")
+		MonitorExternal(s.healthMonitor, s.credentialManager, &server)
 	}
 
 	// Return the created server
-	response := ExternalServerResponse{
-		Name:        server.Name,
-		Slug:        server.Slug,
-		Provider:    externalInfo.Provider,
-		DisplayName: externalInfo.GetDisplayName(),
-		Status:      externalInfo.Status,
-		Config:      externalInfo.Config,
-		AutoStart:   req.AutoStart,
-		LastSync:    externalInfo.LastSync,
-		APIEndpoint: externalInfo.APIEndpoint,
-		AuthType:    externalInfo.AuthType,
-	}
-
 	w.WriteHeader(http.StatusCreated)
-	writeJSON(w, response)
+	writeJSON(w, externalServerResponse(&server))
 }
 
 // handleUpdateExternalServer handles PUT /v1/external/servers/{slug}
@@ -347,6 +507,28 @@ func (s *Server) handleUpdateExternalServer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Keep the entry as it was so a failed update leaves nothing behind
+	before, beforeExt := *server, *server.External
+	var beforeAuto *registry.Autostart
+	if server.Auto != nil {
+		auto := *server.Auto
+		beforeAuto = &auto
+	}
+	rollback := func() {
+		*server.External = beforeExt
+		*server = before
+		server.Auto = beforeAuto
+	}
+
+	// Check the health check override before anything is changed
+	candidate := *server.External
+	req.applyHealthOverride(&candidate)
+	if err := candidate.ValidateHealthCheck(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
 	// Validate provider if changed
 	if req.Provider != "" && req.Provider != server.External.Provider {
 		provider, err := providers.GetProvider(req.Provider)
@@ -384,19 +566,13 @@ func (s *Server) handleUpdateExternalServer(w http.ResponseWriter, r *http.Reque
 		server.External.DisplayName = req.DisplayName
 	}
 	if len(req.Credentials) > 0 {
-		// Store updated credentials in vault and reference them
-		credRef := server.External.CredentialRef
-		if credRef == "" {
-			credRef = fmt.Sprintf("ext:%s:%s", server.External.Provider, server.Slug)
-		}
-		if err := s.ensureCredentialManager(); err == nil {
-			_ = s.credentialManager.vault.Store(credRef, req.Credentials)
-			server.External.CredentialRef = credRef
+		// Reference the vault entry the credentials are stored under once the update validates
+		if server.External.CredentialRef == "" {
+			server.External.CredentialRef = registry.ServerCredentialRef(server.External.Provider, server.Slug)
 		}
 		// Do not persist raw credentials in registry
 		server.External.Credentials = nil
 		server.External.APIKey = ""
-		server.External.Credentials = req.Credentials
 		// Reset status since credentials changed
 		server.External.Status = registry.ExternalStatus{
 			State:   "inactive",
@@ -406,6 +582,13 @@ func (s *Server) handleUpdateExternalServer(w http.ResponseWriter, r *http.Reque
 	if req.Config != nil {
 		server.External.Config = req.Config
 	}
+	if req.InsecureSkipVerify != nil {
+		server.External.InsecureSkipVerify = *req.InsecureSkipVerify
+	}
+	healthChanged := req.HealthEndpoint != nil || req.ExpectedStatus != nil || req.ExpectedBody != nil || req.HealthHeaders != nil || req.HealthQuery != nil
+	if healthChanged {
+		req.applyHealthOverride(server.External)
+	}
 
 	// Update autostart configuration
 	if req.AutoStart && server.Auto == nil {
@@ -419,38 +602,53 @@ func (s *Server) handleUpdateExternalServer(w http.ResponseWriter, r *http.Reque
 
 	// Validate the updated server
 	if err := server.ValidateExternalSetup(); err != nil {
+		rollback()
 		w.WriteHeader(http.StatusBadRequest)
 		writeJSON(w, map[string]string{"error": fmt.Sprintf("Server validation failed: %v", err)})
 		return
 	}
 
+	undoCredentials := func() {}
+	if len(req.Credentials) > 0 {
+		undo, err := s.storeServerCredentials(r, server.Slug, server.External.CredentialRef, server.External.Provider, req.Credentials)
+		if err != nil {
+			rollback()
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to store credentials: %v", err)})
+			return
+		}
+		undoCredentials = undo
+	}
+
 	// Save registry
 	if err := s.saveRegistry(); err != nil {
+		undoCredentials()
+		rollback()
 		w.WriteHeader(http.StatusInternalServerError)
 		writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to save registry: %v", err)})
 		return
 	}
 
+	// The monitor only follows settings that were saved
+	if s.healthMonitor != nil {
+		ext := server.External
+		if req.InsecureSkipVerify != nil {
+			s.healthMonitor.SetInsecureSkipVerify(slug, ext.InsecureSkipVerify)
+		}
+		if healthChanged {
+			s.healthMonitor.SetHealthEndpoint(slug, ext.HealthEndpoint, health.HealthExpectation{Status: ext.ExpectedStatus, Body: ext.ExpectedBody})
+			s.healthMonitor.SetHealthRequest(slug, healthRequest(ext))
+			s.healthMonitor.SetHealthComponents(slug, ComponentProbe(ext))
+		}
+	}
+
 	// Update supervisor with new registry if available
 	if s.sup != nil {
 		s.sup.UpdateRegistry(s.reg)
 	}
 
 	// Return the updated server
-	response := ExternalServerResponse{
-		Name:        server.Name,
-		Slug:        server.Slug,
-		Provider:    server.External.Provider,
-		DisplayName: server.External.GetDisplayName(),
-		Status:      server.External.Status,
-		Config:      server.External.Config,
-		AutoStart:   server.Auto != nil && server.Auto.Enabled,
-		LastSync:    server.External.LastSync,
-		APIEndpoint: server.External.APIEndpoint,
-		AuthType:    server.External.AuthType,
-	}
-
-	writeJSON(w, response)
+	writeJSON(w, externalServerResponse(server))
 }
 
 // handleDeleteExternalServer handles DELETE /v1/external/servers/{slug}
@@ -475,17 +673,37 @@ func (s *Server) handleDeleteExternalServer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Refuse to leave clients pointing at a deleted server unless forced
+	if force, _ := strconv.ParseBool(r.URL.Query().Get("force")); !force {
+		if refs := serverReferences(detectClients(), s.reg.Servers[serverIndex]); len(refs) > 0 {
+			w.WriteHeader(http.StatusConflict)
+			writeJSON(w, map[string]any{
+				"error":      "Server is still referenced by client configs; retry with ?force=true to delete anyway",
+				"references": refs,
+			})
+			return
+		}
+	}
+
 	// Remove from health monitoring first
 	if s.healthMonitor != nil {
-		s.healthMonitor.RemoveProcess(slug)
+		s.healthMonitor.RemoveExternalProcess(slug)
+	}
+	if s.logStreamer != nil {
+		s.logStreamer.CloseProcess(slug)
 	}
 
-	// Delete stored credentials if any (best effort)
-	if s.reg.Servers[serverIndex].External != nil {
-		credRef := s.reg.Servers[serverIndex].External.CredentialRef
-		if credRef != "" {
-			_ = s.ensureCredentialManager()
-			if s.credentialManager != nil { _ = s.credentialManager.vault.Delete(credRef) }
+	// Delete the server's own credentials if any (best effort); the provider
+	// default is shared and stays
+	if ext := s.reg.Servers[serverIndex].External; ext != nil {
+		credRef := ext.CredentialRef
+		if credRef != "" && credRef != ext.Provider && s.ensureCredentialManager() == nil {
+			s.deleteServerCredentials(r, slug, credRef, ext.Provider)
+		}
+		if len(ext.Profiles) > 0 && s.ensureCredentialManager() == nil {
+			for _, profile := range append([]string{""}, ext.Profiles...) {
+				s.deleteServerCredentials(r, slug, registry.ProfileCredentialRef(ext.Provider, slug, profile), ext.Provider)
+			}
 		}
 	}
 
@@ -507,6 +725,100 @@ func (s *Server) handleDeleteExternalServer(w http.ResponseWriter, r *http.Reque
 	writeJSON(w, map[string]string{"status": "deleted"})
 }
 
+// ExternalProfileRequest is the body of PUT /v1/external/servers/{slug}/profile
+type ExternalProfileRequest struct {
+	Profile     string            `json:"profile"`               // "" switches back to the server's own credentials
+	Credentials map[string]string `json:"credentials,omitempty"` // stored under the profile, creating it if new
+}
+
+// handleSwitchExternalProfile handles PUT /v1/external/servers/{slug}/profile,
+// making a named credential profile the one health checks and connection
+// tests use. Credentials in the body are saved to the profile first.
+func (s *Server) handleSwitchExternalProfile(w http.ResponseWriter, r *http.Request, slug string) {
+	server := s.findServer(slug)
+	if server == nil {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"error": "Server not found"})
+		return
+	}
+	if !server.IsExternal() {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Server is not an external server"})
+		return
+	}
+
+	var req ExternalProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Invalid request body"})
+		return
+	}
+	if req.Profile != "" {
+		if err := registry.ValidateProfileName(req.Profile); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	ext := server.External
+	if len(req.Credentials) == 0 && !ext.HasProfile(req.Profile) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("Unknown profile %q; send credentials to create it", req.Profile)})
+		return
+	}
+	if len(req.Credentials) > 0 {
+		if err := providers.ValidateProviderConfig(ext.Provider, req.Credentials); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": fmt.Sprintf("Invalid credentials: %v", err)})
+			return
+		}
+	}
+
+	before := *ext
+	ref := registry.ProfileCredentialRef(ext.Provider, slug, req.Profile)
+	undoCredentials := func() {}
+	if len(req.Credentials) > 0 {
+		undo, err := s.storeServerCredentials(r, slug, ref, ext.Provider, req.Credentials)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to store credentials: %v", err)})
+			return
+		}
+		undoCredentials = undo
+		if !ext.HasProfile(req.Profile) {
+			ext.Profiles = append(append([]string(nil), ext.Profiles...), req.Profile)
+		}
+	}
+
+	ext.ActiveProfile = req.Profile
+	ext.CredentialRef = ref
+	if req.Profile == "" {
+		ext.Status = registry.ExternalStatus{State: "inactive", Message: "Switched to the server's own credentials, needs testing"}
+	} else {
+		ext.Status = registry.ExternalStatus{State: "inactive", Message: fmt.Sprintf("Switched to credential profile %s, needs testing", req.Profile)}
+	}
+
+	if err := s.saveRegistry(); err != nil {
+		undoCredentials()
+		*ext = before
+		s.auditCredential(r, "switch-profile", ext.Provider, slug, "error")
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to save registry: %v", err)})
+		return
+	}
+	s.auditCredential(r, "switch-profile", ext.Provider, slug, "success")
+
+	if s.healthMonitor != nil {
+		s.healthMonitor.SetCredentialRef(slug, ref)
+	}
+	if s.sup != nil {
+		s.sup.UpdateRegistry(s.reg)
+	}
+
+	writeJSON(w, externalServerResponse(server))
+}
+
 // handleTestExternalServer handles POST /v1/external/servers/{slug}/test
 func (s *Server) handleTestExternalServer(w http.ResponseWriter, r *http.Request, slug string) {
 	server := s.findServer(slug)
@@ -533,69 +845,76 @@ func (s *Server) handleTestExternalServer(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Perform health check
-	start := time.Now()
-	
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	client := s.testClient(ext.InsecureSkipVerify)
+	if ext.InsecureSkipVerify {
+		log.Printf("WARNING: testing %s with TLS certificate verification disabled", slug)
 	}
 
-	// Create request to health endpoint
-	req, err := http.NewRequest("GET", provider.HealthEndpoint, nil)
-	if err != nil {
-		responseTime := time.Since(start).Milliseconds()
-		writeJSON(w, ExternalServerTestResponse{
-			Success:      false,
-			Message:      fmt.Sprintf("Failed to create request: %v", err),
-			ResponseTime: &responseTime,
-		})
-		return
+	// Add authentication headers based on provider type, using the server's
+	// credentials or else the provider default
+	creds := ext.Credentials
+	if ext.CredentialRef != "" && s.ensureCredentialManager() == nil {
+		if resolved, _, err := s.credentialManager.Resolve(ext.CredentialRef, ext.Provider); err == nil {
+			creds = resolved
+		}
 	}
-
-	// Add authentication headers based on provider type
-	if ext.Credentials != nil {
+	auth := http.Header{}
+	if creds != nil {
 		switch provider.AuthType {
 		case providers.AuthAPIKey:
-			if apiKey, ok := ext.Credentials["api_key"]; ok {
-				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+			if apiKey, ok := creds["api_key"]; ok {
+				auth.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
 			}
 		case providers.AuthOAuth2:
-			if accessToken, ok := ext.Credentials["access_token"]; ok {
-				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+			if accessToken, ok := creds["access_token"]; ok {
+				auth.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 			}
 		case providers.AuthBasic:
 			// Basic auth would be handled differently
 		}
 	}
 
-	// Perform the request
-	resp, err := client.Do(req)
-	responseTime := time.Since(start).Milliseconds()
+	// Check the health endpoint, the server's own if it has one, which then
+	// also sets what counts as healthy
+	endpoint := ext.HealthCheckURL(provider.HealthEndpoint)
+	probe := health.HTTPProbe{Client: client, Extra: healthRequest(ext), Header: auth}
+	if ext.HealthEndpoint != "" {
+		probe.Expect = health.HealthExpectation{Status: ext.ExpectedStatus, Body: ext.ExpectedBody}
+	}
+	res := probe.Check(r.Context(), endpoint)
+	responseTime := res.ResponseTime.Milliseconds()
 
-	if err != nil {
+	if res.Attempts == 0 {
+		writeJSON(w, ExternalServerTestResponse{
+			Success:      false,
+			Message:      fmt.Sprintf("Failed to create request: %v", res.Err),
+			ResponseTime: &responseTime,
+		})
+		return
+	}
+	if res.StatusCode == 0 {
 		// Update server status
-		ext.UpdateStatus("error", fmt.Sprintf("Connection failed: %v", err), nil)
+		ext.UpdateStatus("error", fmt.Sprintf("Connection failed: %v", res.Err), nil)
 		s.saveRegistry() // Best effort save
 
 		writeJSON(w, ExternalServerTestResponse{
 			Success:      false,
-			Message:      fmt.Sprintf("Connection failed: %v", err),
+			Message:      fmt.Sprintf("Connection failed: %v", res.Err),
 			ResponseTime: &responseTime,
 		})
 		return
 	}
-	defer resp.Body.Close()
 
-	success := resp.StatusCode >= 200 && resp.StatusCode < 300
-	var message string
-	var status string
-
-	if success {
-		message = fmt.Sprintf("Connection successful (HTTP %d)", resp.StatusCode)
-		status = "active"
-	} else {
-		message = fmt.Sprintf("Connection failed with HTTP %d", resp.StatusCode)
+	success := res.Healthy()
+	message := fmt.Sprintf("Connection successful (HTTP %d)", res.StatusCode)
+	status := "active"
+	switch {
+	case success:
+	case ext.HealthEndpoint != "":
+		message = fmt.Sprintf("Health check failed: %v", res.Err)
+		status = "error"
+	default:
+		message = fmt.Sprintf("Connection failed with HTTP %d", res.StatusCode)
 		status = "error"
 	}
 
@@ -606,9 +925,9 @@ func (s *Server) handleTestExternalServer(w http.ResponseWriter, r *http.Request
 	// Update health monitoring if available
 	if s.healthMonitor != nil {
 		if success {
-			s.healthMonitor.AddProcess(slug, "http", provider.HealthEndpoint, "")
+			MonitorExternal(s.healthMonitor, s.credentialManager, server)
 		} else {
-			s.healthMonitor.RemoveProcess(slug)
+			s.healthMonitor.RemoveExternalProcess(slug)
 		}
 	}
 
@@ -635,11 +954,14 @@ func (s *Server) handleListProviders(w http.ResponseWriter, r *http.Request) {
 			Credentials:    provider.Credentials,
 			ConfigSchema:   provider.ConfigSchema,
 			Tags:           provider.Tags,
+			Custom:         provider.Custom,
 		}
 		providerList = append(providerList, response)
 	}
+	// Map iteration order is random; sort so the ETag is stable
+	sort.Slice(providerList, func(i, j int) bool { return providerList[i].Name < providerList[j].Name })
 
-	writeJSON(w, providerList)
+	writeJSONCached(w, r, providerList)
 }
 
 // handleGetProvider handles GET /v1/external/providers/{name}
@@ -668,16 +990,57 @@ func (s *Server) handleGetProvider(w http.ResponseWriter, r *http.Request) {
 		Credentials:    provider.Credentials,
 		ConfigSchema:   provider.ConfigSchema,
 		Tags:           provider.Tags,
+		Custom:         provider.Custom,
 	}
 
 	writeJSON(w, response)
 }
 
-// saveRegistry is a helper function to save the registry to disk
-func (s *Server) saveRegistry() error {
-	registryPath := os.Getenv("MCP_REGISTRY_PATH")
-	if registryPath == "" {
-		registryPath = filepath.Join(os.Getenv("HOME"), ".mcp", "registry.json")
+// handleCreateProvider handles POST /v1/external/providers, adding a
+// user-defined provider template that is kept across restarts
+func (s *Server) handleCreateProvider(w http.ResponseWriter, r *http.Request) {
+	var provider providers.Provider
+	if err := json.NewDecoder(r.Body).Decode(&provider); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Invalid request body"})
+		return
 	}
-	return s.reg.Save(registryPath)
+	path, err := providers.CustomProvidersFile()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := providers.AddCustomProvider(path, provider); err != nil {
+		switch {
+		case errors.Is(err, providers.ErrProviderExists):
+			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, providers.ErrCustomSave):
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("[AUDIT] Added custom external provider: %s", provider.Name)
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, ExternalProviderResponse{
+		Name:           provider.Name,
+		DisplayName:    provider.DisplayName,
+		Description:    provider.Description,
+		AuthType:       string(provider.AuthType),
+		BaseURL:        provider.BaseURL,
+		HealthEndpoint: provider.HealthEndpoint,
+		Credentials:    provider.Credentials,
+		ConfigSchema:   provider.ConfigSchema,
+		Tags:           provider.Tags,
+		Custom:         true,
+	})
+}
+
+// saveRegistry saves the registry to the same file the daemon loaded it from
+func (s *Server) saveRegistry() error {
+	return registry.SaveDefault(s.reg)
 }
@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/health"
+	"mcp/manager/internal/registry"
+)
+
+func TestExternalHealthEndpointOverride(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	var gotAuth string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte("gateway ok"))
+	}))
+	defer gateway.Close()
+
+	h := NewServer(&registry.Registry{Version: "1.0"}).Router()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	create := `{"name":"Gateway","slug":"gw","provider":"openai","credentials":{"api_key":"sk-abcdefghijklmnopqrstuvwxyz"},"healthEndpoint":%s}`
+	if rr := do("POST", "/v1/external/servers", strings.Replace(create, "%s", `"ftp://gateway/healthz"`, 1)); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid health endpoint: expected 400, got %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", "/v1/external/servers", strings.Replace(create, "%s", `"`+gateway.URL+`/healthz","expectedBody":"gateway ok"`, 1)); rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var res ExternalServerTestResponse
+	rr := do("POST", "/v1/external/servers/gw/test", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil || !res.Success {
+		t.Fatalf("test against override: %d %s", rr.Code, rr.Body.String())
+	}
+	if gotAuth != "Bearer sk-abcdefghijklmnopqrstuvwxyz" {
+		t.Fatalf("stored credentials not sent, Authorization %q", gotAuth)
+	}
+
+	if rr := do("PUT", "/v1/external/servers/gw", `{"expectedStatus":700}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("invalid expected status: expected 400, got %d", rr.Code)
+	}
+	if rr := do("PUT", "/v1/external/servers/gw", `{"expectedBody":"something else"}`); rr.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = do("POST", "/v1/external/servers/gw/test", "")
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil || res.Success {
+		t.Fatalf("expected body mismatch to fail: %s", rr.Body.String())
+	}
+}
+
+func TestExternalServerFailedSaveLeavesNoCredentials(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MCP_HOME", home)
+
+	reg := &registry.Registry{Version: "1.0"}
+	s := NewServer(reg)
+	h := s.Router()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	// A directory where the registry file goes makes every save fail
+	regPath := filepath.Join(home, "registry.json")
+	breakRegistry := func() {
+		os.Remove(regPath)
+		if err := os.MkdirAll(filepath.Join(regPath, "blocked"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.ensureCredentialManager(); err != nil {
+		t.Fatal(err)
+	}
+	vault := s.credentialManager.vault
+	ref := registry.ServerCredentialRef("openai", "gw")
+
+	breakRegistry()
+	create := `{"name":"Gateway","slug":"gw","provider":"openai","credentials":{"api_key":"sk-abcdefghijklmnopqrstuvwxyz"}}`
+	if rr := do("POST", "/v1/external/servers", create); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("create with a broken registry: %d %s", rr.Code, rr.Body.String())
+	}
+	if len(reg.Servers) != 0 || vault.HasCredentials(ref) {
+		t.Fatalf("failed create left servers %v, vault entry %v", reg.Servers, vault.HasCredentials(ref))
+	}
+
+	os.RemoveAll(regPath)
+	if rr := do("POST", "/v1/external/servers", create); rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// A failed update puts the previous credentials and entry back
+	breakRegistry()
+	update := `{"name":"Renamed","credentials":{"api_key":"sk-zyxwvutsrqponmlkjihgfedcba"}}`
+	if rr := do("PUT", "/v1/external/servers/gw", update); rr.Code != http.StatusInternalServerError {
+		t.Fatalf("update with a broken registry: %d %s", rr.Code, rr.Body.String())
+	}
+	creds, err := vault.Retrieve(ref)
+	if err != nil || creds["api_key"] != "sk-abcdefghijklmnopqrstuvwxyz" {
+		t.Fatalf("credentials after failed update: %v, %v", creds, err)
+	}
+	if srv := s.findServer("gw"); srv == nil || srv.Name != "Gateway" || srv.External.Status.State != "inactive" || srv.External.Status.Message != "Created but not tested" {
+		t.Fatalf("entry after failed update: %+v", srv)
+	}
+}
+
+func TestExternalCredentialProfiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	var gotAuth string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer gateway.Close()
+
+	s := NewServer(&registry.Registry{Version: "1.0"})
+	h := s.Router()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	testAuth := func() string {
+		gotAuth = ""
+		if rr := do("POST", "/v1/external/servers/gw/test", ""); rr.Code != http.StatusOK {
+			t.Fatalf("test: %d %s", rr.Code, rr.Body.String())
+		}
+		return gotAuth
+	}
+
+	prod, staging := "sk-prodprodprodprodprodprodprod", "sk-stagingstagingstagingstaging"
+	if rr := do("POST", "/v1/external/servers", `{"name":"Gateway","slug":"gw","provider":"openai","credentials":{"api_key":"`+prod+`"},"healthEndpoint":"`+gateway.URL+`"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("PUT", "/v1/external/servers/gw/profile", `{"profile":"staging"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown profile: expected 404, got %d", rr.Code)
+	}
+	if rr := do("PUT", "/v1/external/servers/gw/profile", `{"profile":"a b","credentials":{"api_key":"`+staging+`"}}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad profile name: expected 400, got %d", rr.Code)
+	}
+
+	rr := do("PUT", "/v1/external/servers/gw/profile", `{"profile":"staging","credentials":{"api_key":"`+staging+`"}}`)
+	var got ExternalServerResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || rr.Code != http.StatusOK || got.ActiveProfile != "staging" {
+		t.Fatalf("create staging: %d %s", rr.Code, rr.Body.String())
+	}
+	if auth := testAuth(); auth != "Bearer "+staging {
+		t.Fatalf("staging profile sent %q", auth)
+	}
+
+	// Listing names the profiles and never the keys
+	rr = do("GET", "/v1/external/servers", "")
+	if !strings.Contains(rr.Body.String(), `"profiles":["staging"]`) || strings.Contains(rr.Body.String(), "sk-") {
+		t.Fatalf("list: %s", rr.Body.String())
+	}
+
+	if rr := do("PUT", "/v1/external/servers/gw/profile", `{"profile":""}`); rr.Code != http.StatusOK {
+		t.Fatalf("switch back: %d %s", rr.Code, rr.Body.String())
+	}
+	if auth := testAuth(); auth != "Bearer "+prod {
+		t.Fatalf("own credentials sent %q", auth)
+	}
+	if rr := do("PUT", "/v1/external/servers/gw/profile", `{"profile":"staging"}`); rr.Code != http.StatusOK {
+		t.Fatalf("switch to existing profile: %d %s", rr.Code, rr.Body.String())
+	}
+	if auth := testAuth(); auth != "Bearer "+staging {
+		t.Fatalf("staging profile sent %q after switching back", auth)
+	}
+
+	// The profile's credentials and every switch are audited
+	events, err := s.credentialManager.audit.query("openai", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, ev := range events {
+		actions = append(actions, ev.Action+":"+ev.Result)
+	}
+	if want := "store:success store:success switch-profile:success switch-profile:success switch-profile:success"; strings.Join(actions, " ") != want {
+		t.Fatalf("audit events = %v, want %s", actions, want)
+	}
+}
+
+func TestExternalServersMonitoredAsExternal(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	mon := health.NewHealthMonitor(0)
+	h := NewServer(&registry.Registry{Version: "1.0"}).WithHealthMonitor(mon).Router()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := do("POST", "/v1/external/servers", `{"name":"Gateway","slug":"gw","provider":"openai","credentials":{"api_key":"sk-abcdefghijklmnopqrstuvwxyz"}}`); rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := mon.GetProcessHealth("gw"); ok {
+		t.Fatal("external server monitored as a local process")
+	}
+	ph, ok := mon.GetExternalProcessHealth("gw")
+	if !ok || ph.Provider != "openai" {
+		t.Fatalf("external health = %+v, %v", ph, ok)
+	}
+
+	if rr := do("DELETE", "/v1/external/servers/gw", ""); rr.Code != http.StatusOK && rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := mon.GetExternalProcessHealth("gw"); ok {
+		t.Fatal("deleted server still monitored")
+	}
+}
+
+func TestExternalTestClientReused(t *testing.T) {
+	s := NewServer(&registry.Registry{Version: "1.0"})
+	c := s.testClient(false)
+	if s.testClient(false) != c || s.testClient(true) == c {
+		t.Fatal("test clients not cached per TLS setting")
+	}
+	s.WithRootCAs(x509.NewCertPool())
+	if s.testClient(false) == c {
+		t.Fatal("client kept after the root CAs changed")
+	}
+}
@@ -4,31 +4,27 @@ import (
     "context"
     "encoding/json"
     "fmt"
+    "io"
+    "log"
     "net/http"
-    "sync"
+    "strconv"
+    "strings"
     "time"
 
     "mcp/manager/internal/install"
     "mcp/manager/internal/registry"
+    "mcp/manager/internal/settings"
 )
 
-// Legacy job structure for backward compatibility
-type job struct {
-    id   string
-    logs []string
-    ok   bool
-    done bool
-    msg  string
-    mu   sync.Mutex
-    cancel context.CancelFunc
-}
-
 // AdvancedInstallRequest represents a request for advanced installation
 type AdvancedInstallRequest struct {
     Type    install.SourceType `json:"type"`
     URI     string              `json:"uri"`
     Slug    string              `json:"slug"`
     Options json.RawMessage     `json:"options,omitempty"`
+    // RequiredEnv declares env the server needs that the installer can't
+    // supply; values are given when finalizing or later via /env
+    RequiredEnv []registry.RequiredEnv `json:"requiredEnv,omitempty"`
 }
 
 // InstallJobResponse represents the response for installation operations
@@ -38,33 +34,52 @@ type InstallJobResponse struct {
     Message string `json:"message,omitempty"`
 }
 
+// handleInstallStart starts an installation job and answers with its ID at
+// once; /v1/install/logs and its stream follow the job's stages and progress.
 func (s *Server) handleInstallStart(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost { w.WriteHeader(http.StatusMethodNotAllowed); return }
     
-    // Check if it's an advanced installation request
-    var advancedReq AdvancedInstallRequest
-    if err := json.NewDecoder(r.Body).Decode(&advancedReq); err == nil && advancedReq.Type != "" && advancedReq.Slug != "" {
-        s.handleAdvancedInstallStart(w, r, advancedReq)
+    var req AdvancedInstallRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        w.WriteHeader(http.StatusBadRequest)
+        writeJSON(w, InstallJobResponse{Status: "error", Message: "invalid request: " + err.Error()})
         return
     }
-    
-    // Fall back to legacy installation for backward compatibility
-    s.handleLegacyInstallStart(w, r)
+    if req.Type == "" || req.Slug == "" {
+        w.WriteHeader(http.StatusBadRequest)
+        writeJSON(w, InstallJobResponse{Status: "error", Message: "type and slug are required"})
+        return
+    }
+    s.handleAdvancedInstallStart(w, r, req)
 }
 
 func (s *Server) handleAdvancedInstallStart(w http.ResponseWriter, r *http.Request, req AdvancedInstallRequest) {
-    ctx := context.Background()
-    
-    // Get or create the advanced installation service
-    installService, err := s.getInstallationService()
+    jobID, err := s.startAdvancedInstall(context.Background(), req)
     if err != nil {
         writeJSON(w, InstallJobResponse{
             Status:  "error",
-            Message: "Failed to initialize installation service: " + err.Error(),
+            Message: err.Error(),
         })
         return
     }
     
+    writeJSON(w, InstallJobResponse{
+        JobID:  jobID,
+        Status: "started",
+    })
+}
+
+// startAdvancedInstall starts an installation job for req and returns its ID.
+func (s *Server) startAdvancedInstall(ctx context.Context, req AdvancedInstallRequest) (string, error) {
+    // Get or create the advanced installation service
+    installService, err := s.getInstallationService()
+    if err != nil {
+        return "", fmt.Errorf("Failed to initialize installation service: %v", err)
+    }
+    if err := registry.ValidateRequiredEnv(req.RequiredEnv); err != nil {
+        return "", fmt.Errorf("Invalid required env: %v", err)
+    }
+    
     var jobID string
     
     switch req.Type {
@@ -72,11 +87,7 @@ func (s *Server) handleAdvancedInstallStart(w http.ResponseWriter, r *http.Reque
         var options install.GitInstallOptions
         if req.Options != nil {
             if err := json.Unmarshal(req.Options, &options); err != nil {
-                writeJSON(w, InstallJobResponse{
-                    Status:  "error",
-                    Message: "Invalid git installation options: " + err.Error(),
-                })
-                return
+                return "", fmt.Errorf("Invalid git installation options: %v", err)
             }
         }
         
@@ -86,11 +97,7 @@ func (s *Server) handleAdvancedInstallStart(w http.ResponseWriter, r *http.Reque
         var options install.NPMInstallOptions
         if req.Options != nil {
             if err := json.Unmarshal(req.Options, &options); err != nil {
-                writeJSON(w, InstallJobResponse{
-                    Status:  "error",
-                    Message: "Invalid npm installation options: " + err.Error(),
-                })
-                return
+                return "", fmt.Errorf("Invalid npm installation options: %v", err)
             }
         }
         
@@ -100,111 +107,238 @@ func (s *Server) handleAdvancedInstallStart(w http.ResponseWriter, r *http.Reque
         var options install.PipInstallOptions
         if req.Options != nil {
             if err := json.Unmarshal(req.Options, &options); err != nil {
-                writeJSON(w, InstallJobResponse{
-                    Status:  "error",
-                    Message: "Invalid pip installation options: " + err.Error(),
-                })
-                return
+                return "", fmt.Errorf("Invalid pip installation options: %v", err)
             }
         }
         
         jobID, err = installService.InstallFromPip(ctx, req.Slug, req.URI, options)
         
+    case install.SrcURL:
+        var options install.URLInstallOptions
+        if req.Options != nil {
+            if err := json.Unmarshal(req.Options, &options); err != nil {
+                return "", fmt.Errorf("Invalid url installation options: %v", err)
+            }
+        }
+        
+        jobID, err = installService.InstallFromURL(ctx, req.Slug, req.URI, options)
+        
+    case install.SrcDocker:
+        var options install.DockerInstallOptions
+        if req.Options != nil {
+            if err := json.Unmarshal(req.Options, &options); err != nil {
+                return "", fmt.Errorf("Invalid docker installation options: %v", err)
+            }
+        }
+        
+        jobID, err = installService.InstallFromDocker(ctx, req.Slug, req.URI, options)
+        
     default:
-        writeJSON(w, InstallJobResponse{
-            Status:  "error",
-            Message: "Unsupported installation type: " + string(req.Type),
-        })
-        return
+        return "", fmt.Errorf("Unsupported installation type: %s", req.Type)
     }
     
     if err != nil {
-        writeJSON(w, InstallJobResponse{
-            Status:  "error",
-            Message: "Failed to start installation: " + err.Error(),
-        })
-        return
+        return "", fmt.Errorf("Failed to start installation: %v", err)
     }
-    
-    writeJSON(w, InstallJobResponse{
-        JobID:  jobID,
-        Status: "started",
-    })
-}
-
-func (s *Server) handleLegacyInstallStart(w http.ResponseWriter, r *http.Request) {
-    var in install.PerformInput
-    if err := json.NewDecoder(r.Body).Decode(&in); err != nil { 
-        w.WriteHeader(http.StatusBadRequest)
-        return 
+    if len(req.RequiredEnv) > 0 {
+        if err := installService.SetRequiredEnv(jobID, req.RequiredEnv); err != nil {
+            return "", fmt.Errorf("Failed to record required env: %v", err)
+        }
     }
-    
-    id := time.Now().Format("20060102T150405.000")
-    ctx, cancel := context.WithCancel(context.Background())
-    j := &job{id: id, cancel: cancel}
-    s.jobsMu.Lock()
-    s.jobs[id] = j
-    s.jobsMu.Unlock()
-    
-    go func() {
-        logger := jobLogger{j: j}
-        res, _ := install.PerformStream(ctx, in, install.ExecRunner{}, logger)
-        j.mu.Lock()
-        j.ok = res.OK
-        j.done = true
-        j.msg = res.Message
-        j.mu.Unlock()
-    }()
-    
-    writeJSON(w, map[string]string{"id": id})
+    return jobID, nil
 }
 
-type jobLogger struct{ j *job }
-func (l jobLogger) Log(line string) { 
-    l.j.mu.Lock()
-    l.j.logs = append(l.j.logs, line)
-    l.j.mu.Unlock()
-}
+// installHeartbeatInterval is how often a followed install job reports its
+// stage and progress, whether or not it logged anything.
+var installHeartbeatInterval = 5 * time.Second
 
+// handleInstallLogs returns an installation job with its stage, progress and
+// logs. With ?since=<RFC 3339 time> only the log entries after it are
+// included, so a poller can pass the timestamp of the last entry it has.
+// ?follow=true streams the job instead, as /v1/install/logs/stream does.
 func (s *Server) handleInstallLogs(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet { w.WriteHeader(http.StatusMethodNotAllowed); return }
+    if follow, _ := strconv.ParseBool(r.URL.Query().Get("follow")); follow {
+        s.handleInstallLogStream(w, r)
+        return
+    }
     id := r.URL.Query().Get("id")
+    if id == "" { id = r.URL.Query().Get("jobId") }
+    var since time.Time
+    if v := r.URL.Query().Get("since"); v != "" {
+        t, err := time.Parse(time.RFC3339Nano, v)
+        if err != nil {
+            w.WriteHeader(http.StatusBadRequest)
+            writeJSON(w, map[string]string{"error": fmt.Sprintf("invalid since %q: want an RFC 3339 time", v)})
+            return
+        }
+        since = t
+    }
     
-    // Try advanced installation service first
     installService, err := s.getInstallationService()
     if err == nil {
-        if job, err := installService.GetJobStatus(id); err == nil {
+        if live, err := installService.GetJob(id); err == nil {
+            job := live.GetSnapshot()
+            if !since.IsZero() {
+                job.Logs = append([]install.LogEntry{}, live.GetLogsSince(since)...)
+            }
             writeJSON(w, job)
             return
         }
     }
     
-    // Fall back to legacy job system
-    s.jobsMu.Lock()
-    j := s.jobs[id]
-    s.jobsMu.Unlock()
+    // Jobs the manager has forgotten are read back from their transcript
+    if data, err := install.ReadTranscript(id); err == nil {
+        writeJSON(w, json.RawMessage(data))
+        return
+    }
+    w.WriteHeader(http.StatusNotFound)
+}
+
+// handleInstallLogStream streams an installation job's logs as Server-Sent
+// Events, or as WebSocket text frames to a client that asks to upgrade: the
+// entries collected so far, then live ones until the job ends. A "progress"
+// event follows each change of stage or progress, and a "heartbeat" carrying
+// both comes every installHeartbeatInterval, so a progress bar keeps moving
+// through long silent steps. Entries a slow client missed are reported by a
+// "dropped" event in their place. Any number of clients may follow the same
+// job.
+// GET /v1/install/logs/stream?jobId=X
+func (s *Server) handleInstallLogStream(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet { w.WriteHeader(http.StatusMethodNotAllowed); return }
+    id := r.URL.Query().Get("jobId")
+    if id == "" { id = r.URL.Query().Get("id") }
     
-    if j == nil { 
-        w.WriteHeader(http.StatusNotFound)
-        return 
+    installService, err := s.getInstallationService()
+    if err != nil {
+        http.Error(w, fmt.Sprintf("installation service not available: %v", err), http.StatusServiceUnavailable)
+        return
+    }
+    job, err := installService.GetJob(id)
+    if err != nil {
+        if data, terr := install.ReadTranscript(id); terr == nil {
+            replayTranscript(w, r, data)
+            return
+        }
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
     }
     
-    j.mu.Lock()
-    defer j.mu.Unlock()
-    writeJSON(w, map[string]any{
-        "id": j.id, 
-        "logs": j.logs, 
-        "done": j.done, 
-        "ok": j.ok, 
-        "message": j.msg,
-    })
+    send, done, closeStream, ok := openInstallStream(w, r)
+    if !ok { return }
+    defer closeStream()
+    
+    backlog, entries, unsubscribe := job.Subscribe()
+    defer unsubscribe()
+    
+    var lastStage install.JobStage
+    lastProgress := -1.0
+    sendProgress := func() error {
+        status, stage, progress := job.State()
+        if stage == lastStage && progress == lastProgress { return nil }
+        lastStage, lastProgress = stage, progress
+        return send("progress", map[string]any{"status": status, "stage": stage, "progress": progress})
+    }
+    sendEntry := func(entry install.LogEntry) error {
+        if entry.Dropped > 0 { return send("dropped", entry) }
+        return send("log", entry)
+    }
+    
+    for _, entry := range backlog {
+        if sendEntry(entry) != nil { return }
+    }
+    if sendProgress() != nil { return }
+    
+    heartbeat := time.NewTicker(installHeartbeatInterval)
+    defer heartbeat.Stop()
+    for {
+        select {
+        case <-heartbeat.C:
+            status, stage, progress := job.State()
+            if send("heartbeat", map[string]any{"status": status, "stage": stage, "progress": progress}) != nil { return }
+        case entry, ok := <-entries:
+            if !ok {
+                snap := job.GetSnapshot()
+                send("done", map[string]any{"status": snap.Status, "stage": snap.CurrentStage, "progress": snap.Progress, "error": snap.Error})
+                return
+            }
+            if sendEntry(entry) != nil || sendProgress() != nil { return }
+        case <-done:
+            return
+        }
+    }
+}
+
+// openInstallStream starts the response for an install log stream. send
+// writes one event, as an SSE "event:" with its JSON data, or over a
+// WebSocket as {"event": ..., "data": ...}; done is closed when the client
+// goes away. ok is false when the stream could not be started, in which case
+// the error has been answered.
+func openInstallStream(w http.ResponseWriter, r *http.Request) (send func(event string, v any) error, done <-chan struct{}, closeStream func(), ok bool) {
+    if isWebSocketUpgrade(r) {
+        ws, err := upgradeWebSocket(w, r)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return nil, nil, nil, false
+        }
+        // The client has nothing to say; reading only notices it leaving
+        closed := make(chan struct{})
+        go func() {
+            defer close(closed)
+            for {
+                if _, err := ws.readMessage(); err != nil { return }
+            }
+        }()
+        send = func(event string, v any) error {
+            data, _ := json.Marshal(map[string]any{"event": event, "data": v})
+            return ws.writeText(data)
+        }
+        return send, closed, func() { ws.close() }, true
+    }
+    
+    flusher, canFlush := w.(http.Flusher)
+    if !canFlush {
+        http.Error(w, "streaming not supported", http.StatusInternalServerError)
+        return nil, nil, nil, false
+    }
+    // The stream outlives the server's WriteTimeout; the heartbeat is what
+    // tells a client it is still alive
+    _ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    send = func(event string, v any) error {
+        data, _ := json.Marshal(v)
+        if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil { return err }
+        flusher.Flush()
+        return nil
+    }
+    return send, r.Context().Done(), func() {}, true
+}
+
+// replayTranscript sends a finished job's saved transcript as the events a
+// live stream would have carried, ending with "done".
+func replayTranscript(w http.ResponseWriter, r *http.Request, data []byte) {
+    var job install.InstallationJob
+    if err := json.Unmarshal(data, &job); err != nil {
+        http.Error(w, fmt.Sprintf("unreadable install transcript: %v", err), http.StatusInternalServerError)
+        return
+    }
+    send, _, closeStream, ok := openInstallStream(w, r)
+    if !ok { return }
+    defer closeStream()
+    for _, entry := range job.Logs {
+        event := "log"
+        if entry.Dropped > 0 { event = "dropped" }
+        if send(event, entry) != nil { return }
+    }
+    send("done", map[string]any{"status": job.Status, "stage": job.CurrentStage, "progress": job.Progress, "error": job.Error})
 }
 
 func (s *Server) handleInstallCancel(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost { w.WriteHeader(http.StatusMethodNotAllowed); return }
     id := r.URL.Query().Get("id")
     
-    // Try advanced installation service first
     installService, err := s.getInstallationService()
     if err == nil {
         if err := installService.CancelJob(id); err == nil {
@@ -212,19 +346,7 @@ func (s *Server) handleInstallCancel(w http.ResponseWriter, r *http.Request) {
             return
         }
     }
-    
-    // Fall back to legacy job system
-    s.jobsMu.Lock()
-    j := s.jobs[id]
-    s.jobsMu.Unlock()
-    
-    if j == nil { 
-        w.WriteHeader(http.StatusNotFound)
-        return 
-    }
-    
-    if j.cancel != nil { j.cancel() }
-    writeJSON(w, map[string]string{"status": "cancelling"})
+    w.WriteHeader(http.StatusNotFound)
 }
 
 func (s *Server) handleInstallFinalize(w http.ResponseWriter, r *http.Request) {
@@ -239,6 +361,17 @@ func (s *Server) handleInstallFinalize(w http.ResponseWriter, r *http.Request) {
         return
     }
     
+    // Optional body: {"env": {...}} with values for the server's required env
+    var body struct {
+        Env map[string]string `json:"env"`
+    }
+    if r.ContentLength != 0 {
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+            http.Error(w, "invalid JSON", http.StatusBadRequest)
+            return
+        }
+    }
+    
     installService, err := s.getInstallationService()
     if err != nil {
         writeJSON(w, map[string]string{
@@ -249,7 +382,7 @@ func (s *Server) handleInstallFinalize(w http.ResponseWriter, r *http.Request) {
     }
     
     ctx := context.Background()
-    if err := installService.FinalizeInstallation(ctx, id); err != nil {
+    if err := installService.FinalizeInstallation(ctx, id, body.Env); err != nil {
         writeJSON(w, map[string]string{
             "status": "error",
             "message": "Failed to finalize installation: " + err.Error(),
@@ -258,7 +391,8 @@ func (s *Server) handleInstallFinalize(w http.ResponseWriter, r *http.Request) {
     }
     
     // After successful finalization, reload the registry to pick up newly registered servers
-    if err := s.reloadRegistry(); err != nil {
+    restarted, err := s.reloadRegistry()
+    if err != nil {
         writeJSON(w, map[string]string{
             "status": "warning", 
             "message": "Installation finalized but failed to reload registry: " + err.Error(),
@@ -266,15 +400,29 @@ func (s *Server) handleInstallFinalize(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    writeJSON(w, map[string]string{"status": "finalized"})
+    resp := map[string]interface{}{"status": "finalized", "restarted": restarted}
+    if job, err := installService.GetJobStatus(id); err == nil && job.Result != nil && len(job.Result.MissingEnv) > 0 {
+        resp["missingEnv"] = job.Result.MissingEnv
+    }
+    writeJSON(w, resp)
 }
 
+// handleInstallList returns a page of install jobs, newest first. ?status=
+// takes a comma-separated list of job statuses, ?sort= one of startTime,
+// endTime or slug, and ?limit=&offset= or ?cursor= page as elsewhere.
 func (s *Server) handleInstallList(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodGet { 
         w.WriteHeader(http.StatusMethodNotAllowed)
         return 
     }
     
+    query, page, err := parseJobQuery(r)
+    if err != nil {
+        w.WriteHeader(http.StatusBadRequest)
+        writeJSON(w, map[string]string{"error": err.Error()})
+        return
+    }
+    
     installService, err := s.getInstallationService()
     if err != nil {
         writeJSON(w, map[string]string{
@@ -283,38 +431,76 @@ func (s *Server) handleInstallList(w http.ResponseWriter, r *http.Request) {
         return
     }
     
-    jobs := installService.ListJobs()
-    writeJSON(w, map[string]interface{}{
-        "jobs": jobs,
-    })
+    jobs, total := installService.QueryJobs(query)
+    resp := map[string]interface{}{
+        "jobs":  jobs,
+        "total": total,
+    }
+    if next := page.nextCursor(total); next != "" {
+        resp["nextCursor"] = next
+    }
+    writeJSON(w, resp)
 }
 
-// getInstallationService returns the installation service, creating it if necessary
+// parseJobQuery reads the filter, sort and paging parameters of an install
+// job listing.
+func parseJobQuery(r *http.Request) (install.JobQuery, listPage, error) {
+    page, err := parsePage(r)
+    if err != nil {
+        return install.JobQuery{}, page, err
+    }
+    query := install.JobQuery{Offset: page.offset, Limit: page.limit}
+    if query.Sort, err = install.ParseJobSort(r.URL.Query().Get("sort")); err != nil {
+        return query, page, err
+    }
+    for _, name := range strings.Split(r.URL.Query().Get("status"), ",") {
+        if name = strings.TrimSpace(name); name == "" {
+            continue
+        }
+        status, err := install.ParseJobStatus(name)
+        if err != nil {
+            return query, page, err
+        }
+        query.Statuses = append(query.Statuses, status)
+    }
+    return query, page, nil
+}
+
+// getInstallationService returns the installation service, creating it if
+// necessary. The concurrency limit follows manager.maxConcurrentInstalls, so
+// a settings change applies to the next installation started.
 func (s *Server) getInstallationService() (*install.AdvancedInstallationService, error) {
     if s.installService == nil {
         var err error
-        s.installService, err = install.NewAdvancedInstallationService(5) // Max 5 concurrent jobs
+        s.installService, err = install.NewAdvancedInstallationService(install.DefaultMaxConcurrentJobs)
         if err != nil {
             return nil, err
         }
+        if s.credentialManager != nil {
+            s.installService.SetSecretStore(s.credentialManager.vault)
+        }
+    }
+    if cfg, err := settings.GetCached(); err == nil {
+        s.installService.SetMaxConcurrent(cfg.Manager.MaxConcurrentInstalls)
     }
     return s.installService, nil
 }
 
-// reloadRegistry reloads the registry from disk and updates the server's registry reference
-func (s *Server) reloadRegistry() error {
+// reloadRegistry reloads the registry from disk and updates the server's
+// registry reference. It returns the servers restarted for a changed launch
+// configuration, see syncSupervisor.
+func (s *Server) reloadRegistry() ([]string, error) {
     newReg, err := registry.LoadDefault()
     if err != nil {
-        return fmt.Errorf("failed to reload registry: %w", err)
+        return nil, fmt.Errorf("failed to reload registry: %w", err)
     }
     
+    if changes := registryChanges(s.reg, newReg); changes != "" {
+        log.Printf("Registry reloaded: %s", changes)
+    }
     s.reg = newReg
     
     // Update the supervisor with the new registry so it can manage newly registered servers
-    if s.sup != nil {
-        s.sup.UpdateRegistry(newReg)
-    }
-    
-    return nil
+    return s.syncSupervisor(), nil
 }
 
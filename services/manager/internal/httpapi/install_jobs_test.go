@@ -0,0 +1,300 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/install"
+	"mcp/manager/internal/registry"
+)
+
+func TestInstallLogsFromTranscript(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	path, err := install.TranscriptPath("demo", "job_1_1_ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	transcript := `{"id":"job_1_1_ab","slug":"demo","status":"failed","currentStage":"failed","logs":[{"timestamp":"2026-01-02T03:04:05Z","level":"error","stage":"failed","message":"Installation failed","details":"npm exited with status 1"}],"error":"npm exited with status 1"}`
+	if err := os.WriteFile(path, []byte(transcript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	router := NewServer(&registry.Registry{Version: "1.0"}).Router()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/install/logs?id=job_1_1_ab", nil))
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), `"error":"npm exited with status 1"`) {
+		t.Fatalf("logs = %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/install/logs/stream?jobId=job_1_1_ab", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, "event: log\ndata: {") || !strings.Contains(body, `event: done`+"\n"+`data: {"error":"npm exited with status 1"`) {
+		t.Fatalf("stream = %s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/install/logs?id=job_unknown", nil))
+	if rr.Code != 404 {
+		t.Fatalf("unknown job = %d", rr.Code)
+	}
+}
+
+func TestInstallLogStreamWebSocket(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	path, err := install.TranscriptPath("demo", "job_1_1_ab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	transcript := `{"id":"job_1_1_ab","slug":"demo","status":"completed","currentStage":"completed","logs":[{"timestamp":"2026-01-02T03:04:05Z","level":"info","stage":"installing","message":"added 3 packages"},{"timestamp":"2026-01-02T03:04:06Z","level":"warning","stage":"installing","message":"2 log entries were dropped","dropped":2}]}`
+	if err := os.WriteFile(path, []byte(transcript), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewServer(&registry.Registry{Version: "1.0"}).Router())
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, _ = io.WriteString(conn, "GET /v1/install/logs/stream?jobId=job_1_1_ab HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake: %v %v", resp, err)
+	}
+
+	var events []string
+	for {
+		opcode, payload, err := readServerFrame(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opcode == wsClose {
+			break
+		}
+		var msg struct {
+			Event string          `json:"event"`
+			Data  json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, msg.Event)
+	}
+	if strings.Join(events, ",") != "log,dropped,done" {
+		t.Fatalf("events = %v", events)
+	}
+}
+
+func TestInstallListQuery(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	router := NewServer(&registry.Registry{Version: "1.0"}).Router()
+
+	for _, q := range []string{"status=running,bogus", "sort=size", "limit=0"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/install/list?"+q, nil))
+		if rr.Code != 400 {
+			t.Errorf("%s = %d %s", q, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/install/list?status=running,failed&limit=50&offset=0&sort=startTime", nil))
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), `"total":0`) {
+		t.Fatalf("list = %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestInstallStartRunsJob(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	router := NewServer(&registry.Registry{Version: "1.0"}).Router()
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/v1/install/start", strings.NewReader(body)))
+		return rr
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		return rr
+	}
+
+	for _, body := range []string{"not json", `{"uri":"demo"}`} {
+		if rr := post(body); rr.Code != 400 || !strings.Contains(rr.Body.String(), `"status":"error"`) {
+			t.Errorf("%s = %d %s", body, rr.Code, rr.Body.String())
+		}
+	}
+
+	// Nothing listens on port 1, so the job fails at download
+	rr := post(`{"type":"url","slug":"demo","uri":"http://127.0.0.1:1/demo.zip"}`)
+	var started InstallJobResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &started); err != nil || started.JobID == "" {
+		t.Fatalf("start = %d %s", rr.Code, rr.Body.String())
+	}
+	var job install.InstallationJob
+	deadline := time.Now().Add(10 * time.Second)
+	for job.Status != install.JobStatusFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("job never failed: %s at %s", job.Status, job.CurrentStage)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if err := json.Unmarshal(get("/v1/install/logs?id="+started.JobID).Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(job.Logs) == 0 {
+		t.Fatal("failed job has no logs")
+	}
+
+	last := job.Logs[len(job.Logs)-1].Timestamp.Format(time.RFC3339Nano)
+	rr = get("/v1/install/logs?id=" + started.JobID + "&since=" + url.QueryEscape(last))
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), `"logs":[]`) {
+		t.Fatalf("logs since the last entry = %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := get("/v1/install/logs?id=" + started.JobID + "&since=yesterday"); rr.Code != 400 {
+		t.Fatalf("bad since = %d", rr.Code)
+	}
+}
+
+func TestInstallLogsFollow(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	defer func(d time.Duration) { installHeartbeatInterval = d }(installHeartbeatInterval)
+	installHeartbeatInterval = 20 * time.Millisecond
+
+	// The download never finishes, so the job stays silent until cancelled
+	release := make(chan struct{})
+	stall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stall.Close()
+	defer close(release)
+
+	api := httptest.NewServer(NewServer(&registry.Registry{Version: "1.0"}).Router())
+	defer api.Close()
+	resp, err := http.Post(api.URL+"/v1/install/start", "application/json", strings.NewReader(`{"type":"url","slug":"demo","uri":"`+stall.URL+`/demo.zip"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var started InstallJobResponse
+	json.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	if started.JobID == "" {
+		t.Fatalf("start = %+v", started)
+	}
+
+	// Two watchers follow the same job
+	var streams []*bufio.Reader
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(api.URL + "/v1/install/logs?jobId=" + started.JobID + "&follow=true")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("content type %q", ct)
+		}
+		streams = append(streams, bufio.NewReader(resp.Body))
+	}
+	waitFor := func(stream *bufio.Reader, event string) string {
+		t.Helper()
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				t.Fatalf("stream ended before %s: %v", event, err)
+			}
+			if line == "event: "+event+"\n" {
+				data, _ := stream.ReadString('\n')
+				return data
+			}
+		}
+	}
+	for _, stream := range streams {
+		if data := waitFor(stream, "heartbeat"); !strings.Contains(data, `"status":"running"`) || !strings.Contains(data, `"progress":`) {
+			t.Fatalf("heartbeat = %s", data)
+		}
+	}
+
+	resp, err = http.Post(api.URL+"/v1/install/cancel?id="+started.JobID, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, stream := range streams {
+		if data := waitFor(stream, "done"); !strings.Contains(data, `"status":"cancelled"`) {
+			t.Fatalf("done = %s", data)
+		}
+	}
+}
+
+func TestInstallLogsFollowOutlivesWriteTimeout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	defer func(d time.Duration) { installHeartbeatInterval = d }(installHeartbeatInterval)
+	installHeartbeatInterval = 20 * time.Millisecond
+
+	release := make(chan struct{})
+	stall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer stall.Close()
+	defer close(release)
+
+	// Served as the daemon serves it, with a write deadline on every response
+	api := httptest.NewUnstartedServer(NewServer(&registry.Registry{Version: "1.0"}).Router())
+	api.Config.WriteTimeout = 200 * time.Millisecond
+	api.Start()
+	defer api.Close()
+
+	resp, err := http.Post(api.URL+"/v1/install/start", "application/json", strings.NewReader(`{"type":"url","slug":"demo","uri":"`+stall.URL+`/demo.zip"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var started InstallJobResponse
+	json.NewDecoder(resp.Body).Decode(&started)
+	resp.Body.Close()
+	defer http.Post(api.URL+"/v1/install/cancel?id="+started.JobID, "", nil)
+
+	resp, err = http.Get(api.URL + "/v1/install/logs?jobId=" + started.JobID + "&follow=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	stream := bufio.NewReader(resp.Body)
+	deadline := time.Now().Add(3 * api.Config.WriteTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := stream.ReadString('\n'); err != nil {
+			t.Fatalf("stream cut off by the write timeout: %v", err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"mcp/manager/internal/health"
+	"mcp/manager/internal/registry"
+)
+
+// introspectTimeout bounds a whole introspection round trip to a server.
+const introspectTimeout = 10 * time.Second
+
+// handleServerIntrospect handles GET /v1/servers/{slug}/{tools,resources,prompts}
+// by proxying the matching MCP list request to the running server, over HTTP
+// or its stdio pipes.
+func (s *Server) handleServerIntrospect(w http.ResponseWriter, r *http.Request, slug, kind string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sv := s.findServer(slug)
+	if sv == nil {
+		http.Error(w, fmt.Sprintf("unknown server: %s", slug), http.StatusNotFound)
+		return
+	}
+	endpoint, status, err := s.runningMCPEndpoint(sv)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("introspection unavailable: %v", err), status)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), introspectTimeout)
+	defer cancel()
+
+	var result json.RawMessage
+	if endpoint == "" {
+		conn, attachErr := s.sup.Attach(slug)
+		if attachErr != nil {
+			http.Error(w, fmt.Sprintf("introspection unavailable: %v", attachErr), http.StatusConflict)
+			return
+		}
+		result, err = health.StdioList(ctx, conn, kind+"/list")
+	} else {
+		result, err = health.MCPList(ctx, endpoint, kind+"/list")
+	}
+	switch {
+	case errors.Is(err, health.ErrCapabilityUnsupported):
+		http.Error(w, fmt.Sprintf("server %s does not expose %s", slug, kind), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("failed to query %s: %v", slug, err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(result)
+}
+
+// runningMCPEndpoint resolves the Streamable HTTP endpoint of a running
+// server. A stdio server has none and is reached through s.sup.Attach; ""
+// is returned for it. On failure it returns the HTTP status that best
+// describes why.
+func (s *Server) runningMCPEndpoint(sv *registry.Server) (string, int, error) {
+	if s.sup == nil {
+		return "", http.StatusServiceUnavailable, errors.New("supervisor not available")
+	}
+
+	info := s.sup.GetProcessInfo(sv.Slug)
+	if state, _ := info["state"].(string); state != "running" {
+		return "", http.StatusConflict, fmt.Errorf("server %s is not running", sv.Slug)
+	}
+	if sv.Entry.Transport == "stdio" {
+		return "", http.StatusOK, nil
+	}
+
+	endpoint, _ := info["httpURL"].(string)
+	if endpoint == "" {
+		endpoint = registry.DeriveHTTPURL(sv.Entry.Args, sv.Entry.Env)
+	}
+	return mcpEndpoint(endpoint), http.StatusOK, nil
+}
+
+// mcpEndpoint returns the Streamable HTTP endpoint for a server base URL,
+// defaulting to the conventional /mcp path when none is given.
+func mcpEndpoint(base string) string {
+	u, err := url.Parse(base)
+	if err != nil || (u.Path != "" && u.Path != "/") {
+		return base
+	}
+	u.Path = "/mcp"
+	return u.String()
+}
@@ -5,16 +5,19 @@ import (
     "bytes"
     "io"
     "net/http"
+    "net/url"
     "os"
     "path/filepath"
     "strconv"
     "strings"
 
+    "mcp/manager/internal/logs"
     "mcp/manager/internal/paths"
 )
 
 func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
-    // GET /v1/logs/{slug}?tail=200
+    // GET /v1/logs/{slug}?tail=200, optionally with the filters of logFilter;
+    // tail then counts matching lines
     if r.Method != http.MethodGet { w.WriteHeader(http.StatusMethodNotAllowed); return }
     parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
     if len(parts) < 3 { w.WriteHeader(http.StatusBadRequest); return }
@@ -23,13 +26,30 @@ func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
     if v := r.URL.Query().Get("tail"); v != "" {
         if n, err := strconv.Atoi(v); err == nil { tailN = n }
     }
+    filter, err := logFilter(r.URL.Query())
+    if err != nil {
+        w.WriteHeader(http.StatusBadRequest)
+        writeJSON(w, map[string]string{"error": err.Error()})
+        return
+    }
     dir, err := paths.LogsDir(); if err != nil { w.WriteHeader(http.StatusInternalServerError); return }
     file := filepath.Join(dir, slug+".log")
-    lines, _ := tailLines(file, tailN)
+    var lines []string
+    if filter != nil {
+        lines, _ = logs.FilterTail(file, tailN, filter)
+    } else {
+        lines, _ = tailLines(file, tailN)
+    }
     w.Header().Set("Content-Type", "text/plain; charset=utf-8")
     for _, l := range lines { _, _ = w.Write([]byte(l+"\n")) }
 }
 
+// logFilter reads the level, contains, regex and since query parameters
+// shared by the log endpoints. It returns nil when none is given.
+func logFilter(q url.Values) (*logs.Filter, error) {
+    return logs.NewFilter(q.Get("level"), q.Get("contains"), q.Get("regex"), q.Get("since"))
+}
+
 func tailLines(path string, n int) ([]string, error) {
     f, err := os.Open(path); if err != nil { return nil, err }
     defer f.Close()
@@ -0,0 +1,155 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maintenance is the daemon's read-only mode. While it is on, requests that
+// change state are refused and automatic starts wait.
+type maintenance struct {
+	mu     sync.Mutex
+	on     bool
+	since  time.Time
+	reason string
+	ended  chan struct{} // closed when maintenance ends
+}
+
+// MaintenanceStatus reports whether the daemon is in maintenance mode.
+type MaintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Since   *time.Time `json:"since,omitempty"`
+	Reason  string     `json:"reason,omitempty"`
+}
+
+// SetMaintenance enters or leaves maintenance mode and reports whether that
+// changed anything. Leaving it releases anything blocked in WaitMaintenance.
+func (s *Server) SetMaintenance(on bool, reason string) bool {
+	m := &s.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if on == m.on {
+		return false
+	}
+	m.on = on
+	if on {
+		m.since = time.Now().UTC()
+		m.reason = reason
+		m.ended = make(chan struct{})
+		return true
+	}
+	log.Printf("Left maintenance mode after %s", time.Since(m.since).Round(time.Second))
+	close(m.ended)
+	m.since, m.reason, m.ended = time.Time{}, "", nil
+	return true
+}
+
+// Maintenance returns the current maintenance state.
+func (s *Server) Maintenance() MaintenanceStatus {
+	m := &s.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.on {
+		return MaintenanceStatus{}
+	}
+	since := m.since
+	return MaintenanceStatus{Enabled: true, Since: &since, Reason: m.reason}
+}
+
+// InMaintenance reports whether state-changing operations are paused.
+func (s *Server) InMaintenance() bool {
+	return s.Maintenance().Enabled
+}
+
+// WaitMaintenance blocks while the daemon is in maintenance mode. It returns
+// ctx's error if ctx ends first.
+func (s *Server) WaitMaintenance(ctx context.Context) error {
+	for {
+		s.maintenance.mu.Lock()
+		ended := s.maintenance.ended
+		s.maintenance.mu.Unlock()
+		if ended == nil {
+			return nil
+		}
+		select {
+		case <-ended:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// readOnlyPOSTs are POST endpoints that only read state and stay available
+// during maintenance.
+var readOnlyPOSTs = map[string]bool{
+	"/v1/install/validate":            true,
+	"/v1/credentials/validate":        true,
+	"/v1/credentials/validate-stored": true,
+	"/v1/system/maintenance":          true,
+}
+
+// mutates reports whether r may change daemon state.
+func mutates(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	if r.Method == http.MethodPost {
+		// MCP traffic goes to the server, not the daemon
+		if readOnlyPOSTs[r.URL.Path] || (strings.HasPrefix(r.URL.Path, "/v1/servers/") && strings.HasSuffix(r.URL.Path, "/rpc")) {
+			return false
+		}
+	}
+	return true
+}
+
+// withMaintenance answers 503 to state-changing requests during maintenance.
+func (s *Server) withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mutates(r) && s.InMaintenance() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"error":       "maintenance mode: the daemon is read-only until maintenance ends",
+				"maintenance": s.Maintenance(),
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleSystemMaintenance handles GET and POST /v1/system/maintenance.
+// Body: { "enabled": true, "reason": "backup" }
+func (s *Server) handleSystemMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var body struct {
+			Enabled *bool  `json:"enabled"`
+			Reason  string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+			http.Error(w, "enabled is required", http.StatusBadRequest)
+			return
+		}
+		if s.SetMaintenance(*body.Enabled, body.Reason) {
+			ev := CredentialAuditEvent{Action: "maintenance", Result: "disabled"}
+			if *body.Enabled {
+				ev.Result, ev.Reason = "enabled", body.Reason
+			}
+			s.credentialManager.recordAudit(auditEvent(r, ev))
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.Maintenance())
+}
@@ -0,0 +1,94 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	audit := &credentialAudit{path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	srv := NewServer(&registry.Registry{Version: "1.0"}).WithCredentialManager(&CredentialManager{audit: audit})
+	h := srv.Router()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := do("POST", "/v1/system/maintenance", `{"enabled":true,"reason":"backup"}`); rr.Code != http.StatusOK {
+		t.Fatalf("enter: %d %s", rr.Code, rr.Body.String())
+	}
+	var st MaintenanceStatus
+	if err := json.Unmarshal(do("GET", "/v1/system/maintenance", "").Body.Bytes(), &st); err != nil || !st.Enabled || st.Reason != "backup" || st.Since == nil {
+		t.Fatalf("status = %+v, %v", st, err)
+	}
+
+	for _, req := range [][2]string{
+		{"PATCH", "/v1/settings"},
+		{"POST", "/v1/servers/demo/actions"},
+		{"POST", "/v1/install/start"},
+		{"POST", "/v1/credentials"},
+		{"DELETE", "/v1/external/servers/demo"},
+	} {
+		if rr := do(req[0], req[1], "{}"); rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "maintenance mode") {
+			t.Errorf("%s %s: %d %s", req[0], req[1], rr.Code, rr.Body.String())
+		}
+	}
+	// Reads and read-only checks carry on
+	if rr := do("GET", "/v1/stats", ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"enabled":true`) {
+		t.Errorf("stats: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do("POST", "/v1/install/validate", "{}"); rr.Code == http.StatusServiceUnavailable {
+		t.Errorf("install validate refused during maintenance")
+	}
+
+	waited := make(chan error, 1)
+	go func() { waited <- srv.WaitMaintenance(context.Background()) }()
+	select {
+	case <-waited:
+		t.Fatal("WaitMaintenance returned during maintenance")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if rr := do("POST", "/v1/system/maintenance", `{"enabled":false}`); rr.Code != http.StatusOK {
+		t.Fatalf("leave: %d", rr.Code)
+	}
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitMaintenance still blocked after maintenance ended")
+	}
+	if rr := do("PATCH", "/v1/settings", "{}"); rr.Code == http.StatusServiceUnavailable {
+		t.Fatalf("settings still refused after maintenance: %s", rr.Body.String())
+	}
+	if rr := do("POST", "/v1/system/maintenance", `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("missing enabled: %d", rr.Code)
+	}
+
+	// Each change is audited once; repeating the current state is not a change
+	if rr := do("POST", "/v1/system/maintenance", `{"enabled":false}`); rr.Code != http.StatusOK {
+		t.Fatalf("leave again: %d", rr.Code)
+	}
+	events, err := audit.query("", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Action != "maintenance" || events[0].Result != "enabled" || events[0].Reason != "backup" ||
+		events[1].Result != "disabled" || events[1].Remote == "" {
+		t.Fatalf("audit events = %+v", events)
+	}
+}
@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// metricsWriter renders metrics in the Prometheus text exposition format,
+// version 0.0.4. Every family is announced with family before its samples,
+// and a family's samples must be written together.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family writes the HELP and TYPE lines of a metric; kind is "counter" or "gauge".
+func (m *metricsWriter) family(name, kind, help string) {
+	m.buf.WriteString("# HELP " + name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help) + "\n")
+	m.buf.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// sample writes one value of name, with labels given as name, value pairs.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.buf.WriteString(name)
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			m.buf.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+		}
+		m.buf.WriteByte('}')
+	}
+	m.buf.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricValue converts a number from a stats map, which holds ints, int64s
+// and float64s. It reports false for anything else, such as the nil CPU and
+// RSS of a platform without process stats.
+func metricValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mcp/manager/internal/health"
+	"mcp/manager/internal/registry"
+)
+
+// metricsSupervisor runs "api" and has never started "idle".
+type metricsSupervisor struct{ stubSupervisor }
+
+func (metricsSupervisor) Stats() map[string]interface{} {
+	return map[string]interface{}{"running": 1, "stopped": 1, "failed": 0, "totalStarts": int64(3), "totalStops": int64(2), "totalRestarts": int64(1)}
+}
+
+func (metricsSupervisor) GetProcessInfo(slug string) map[string]interface{} {
+	if slug != "api" {
+		return map[string]interface{}{"exists": false}
+	}
+	return map[string]interface{}{"exists": true, "state": "running", "cpuPercent": 12.5, "rssBytes": int64(4096), "restarts": 1}
+}
+
+func TestMetrics(t *testing.T) {
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+		{Slug: "api"}, {Slug: "idle"}, {Slug: "gh", External: &registry.ExternalInfo{Provider: "github"}},
+	}}
+	mon := health.NewHealthMonitor(0)
+	mon.AddProcess("api", "stdio", "", "")
+	h := NewServer(reg).WithSupervisor(metricsSupervisor{}).WithHealthMonitor(mon).Router()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/metrics", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/plain; version=0.0.4" {
+		t.Fatalf("status %d, content type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"# TYPE mcp_total_restarts counter\nmcp_total_restarts 1\n",
+		"mcp_total_starts 3\n",
+		`mcp_processes{state="running"} 1`,
+		"# TYPE mcp_process_up gauge\nmcp_process_up{slug=\"api\"} 1\nmcp_process_up{slug=\"idle\"} 0\n",
+		`mcp_process_cpu_percent{slug="api"} 12.5`,
+		"# TYPE mcp_process_rss_bytes gauge\nmcp_process_rss_bytes{slug=\"api\"} 4096\n# HELP",
+		`mcp_process_restarts_total{slug="api"} 1`,
+		`mcp_health_ready{slug="api"} 0`,
+		`mcp_health_checks_total{slug="api"} 0`,
+		`mcp_health_response_seconds{slug="api",quantile="0.99"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `slug="gh"`) {
+		t.Errorf("external server has process metrics:\n%s", body)
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	var m metricsWriter
+	m.sample("x", 1, "path", "a\\b\"c\nd")
+	if got, want := m.buf.String(), `x{path="a\\b\"c\nd"} 1`+"\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
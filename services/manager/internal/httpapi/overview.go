@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"net/http"
+	"time"
+
+	"mcp/manager/internal/health"
+)
+
+// handleOverview handles GET /v1/overview, everything a dashboard shows per
+// refresh in one payload: a row per server, local and external, with its
+// state, health and metrics, plus the counts, supervisor totals and log
+// stream numbers. The supervisor and the health monitor are each read once
+// and every count is taken from the rows themselves, so no server is running
+// in one section and down in another. Rows follow registry order and page
+// like /v1/servers (?limit=, ?offset= or ?cursor=, ?fields=); the counts
+// always cover every server.
+func (s *Server) handleOverview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	local := map[string]map[string]any{}
+	if s.sup != nil {
+		rows, _ := s.sup.SummaryPage(0, 0)
+		for _, row := range rows {
+			if slug, ok := row["slug"].(string); ok {
+				local[slug] = row
+			}
+		}
+	}
+	var external map[string]*health.ExternalProcessHealth
+	if s.healthMonitor != nil {
+		external = s.healthMonitor.GetAllExternalHealth()
+	}
+
+	rows := make([]map[string]any, 0, len(s.reg.Servers))
+	states := map[string]int{}   // local servers by process state
+	statuses := map[string]int{} // every server by health status
+	for _, sv := range s.reg.Servers {
+		var row map[string]any
+		if sv.IsExternal() {
+			row = externalOverviewRow(sv.Name, sv.Slug, external[sv.Slug])
+			row["kind"] = "external"
+		} else {
+			if row = local[sv.Slug]; row == nil {
+				row = map[string]any{"name": sv.Name, "slug": sv.Slug, "state": "stopped", "status": string(health.Down)}
+			}
+			row["kind"] = "local"
+			states[row["state"].(string)]++
+		}
+		statuses[row["status"].(string)]++
+		rows = append(rows, row)
+	}
+
+	total := len(rows)
+	pageRows := rows[min(page.offset, total):]
+	if len(pageRows) > page.limit {
+		pageRows = pageRows[:page.limit]
+	}
+	response := map[string]interface{}{
+		"generatedAt":  time.Now(),
+		"maintenance":  s.Maintenance(),
+		"totalServers": total,
+		"servers":      page.selectFields(pageRows),
+		"states":       states,
+		"health":       statuses,
+	}
+	if next := page.nextCursor(total); next != "" {
+		response["nextCursor"] = next
+	}
+	if s.sup != nil {
+		// Only the lifetime totals: the per-state numbers above match the rows
+		stats := s.sup.Stats()
+		response["stats"] = map[string]interface{}{
+			"totalStarts":   stats["totalStarts"],
+			"totalStops":    stats["totalStops"],
+			"totalRestarts": stats["totalRestarts"],
+		}
+	}
+	if s.logStreamer != nil {
+		streams := s.logStreamer.GetActiveStreams()
+		response["streams"] = map[string]interface{}{
+			"clients":  streams["totalClients"],
+			"watchers": streams["totalWatchers"],
+		}
+	}
+	writeJSON(w, response)
+}
+
+// externalOverviewRow is the overview row of an external server. There is no
+// process, so cpu, ramMB and uptime are null and the latency stands in; ph is
+// nil for a server the monitor does not check yet.
+func externalOverviewRow(name, slug string, ph *health.ExternalProcessHealth) map[string]any {
+	row := map[string]any{
+		"name":       name,
+		"slug":       slug,
+		"state":      "external",
+		"status":     string(health.Down),
+		"cpu":        nil,
+		"ramMB":      nil,
+		"uptime":     nil,
+		"restarts":   0,
+		"lastPingMs": 0,
+	}
+	if ph != nil {
+		row["status"] = string(ph.Status)
+		row["provider"] = ph.Provider
+		row["lastCheck"] = ph.LastCheck
+		row["lastPingMs"] = int(ph.AvgResponseTime.Milliseconds())
+		row["consecutiveFails"] = ph.ConsecutiveFails
+		row["rateLimited"] = ph.RateLimited
+		row["credentialWarning"] = ph.CredentialWarning
+	}
+	return row
+}
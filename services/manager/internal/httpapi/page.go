@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxPageLimit caps the rows of one listing page. A listing fetched without
+// ?limit gets this many too, with a cursor for the rest.
+const maxPageLimit = 500
+
+// listPage is the slice of a listing a request asked for through
+// ?limit=&offset= or ?cursor=, and the row fields it wants (?fields=).
+type listPage struct {
+	offset int
+	limit  int
+	fields []string
+}
+
+// parsePage reads the paging and field selection parameters of r. A cursor
+// is the nextCursor of an earlier page and takes the place of offset.
+func parsePage(r *http.Request) (listPage, error) {
+	q := r.URL.Query()
+	p := listPage{limit: maxPageLimit}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("invalid limit %q: want a positive integer", v)
+		}
+		p.limit = min(n, maxPageLimit)
+	}
+	start, name := q.Get("offset"), "offset"
+	if v := q.Get("cursor"); v != "" {
+		start, name = v, "cursor"
+	}
+	if start != "" {
+		n, err := strconv.Atoi(start)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid %s %q", name, start)
+		}
+		p.offset = n
+	}
+	for _, f := range strings.Split(q.Get("fields"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			p.fields = append(p.fields, f)
+		}
+	}
+	return p, nil
+}
+
+// nextCursor returns the cursor of the page after p in a listing of total
+// rows, or "" when p reaches the end.
+func (p listPage) nextCursor(total int) string {
+	if p.offset+p.limit >= total {
+		return ""
+	}
+	return strconv.Itoa(p.offset + p.limit)
+}
+
+// selectFields trims each row to the requested fields, matched without
+// regard to case. Rows are left whole when no fields were asked for.
+func (p listPage) selectFields(rows []map[string]any) []map[string]any {
+	if len(p.fields) == 0 {
+		return rows
+	}
+	out := make([]map[string]any, 0, len(rows))
+	for _, row := range rows {
+		kept := make(map[string]any, len(p.fields))
+		for key, v := range row {
+			for _, f := range p.fields {
+				if strings.EqualFold(key, f) {
+					kept[key] = v
+					break
+				}
+			}
+		}
+		out = append(out, kept)
+	}
+	return out
+}
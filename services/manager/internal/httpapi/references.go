@@ -0,0 +1,127 @@
+package httpapi
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"mcp/manager/internal/clients"
+	"mcp/manager/internal/registry"
+)
+
+// ClientReference is an entry in a client's config that points at a server.
+type ClientReference struct {
+	Client string `json:"client"`
+	Path   string `json:"path,omitempty"`
+	Name   string `json:"name"` // the entry's name in the client config
+}
+
+// ClientServer is an entry in a client's config, with the registry server it
+// resolves to when there is one.
+type ClientServer struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	Slug    string   `json:"slug,omitempty"`
+}
+
+// clientAliases maps the registry's client keys to detection names.
+var clientAliases = map[string]string{
+	"claudeDesktop": "Claude Desktop",
+	"cursorGlobal":  "Cursor (Global)",
+	"vscode":        "VS Code",
+	"windsurf":      "Windsurf",
+}
+
+// referencesServer reports whether a client config entry is sv: it is named
+// after the server or runs the same command line.
+func referencesServer(mcp clients.MCPServer, sv registry.Server) bool {
+	if mcp.Name == sv.Slug || mcp.Name == sv.Name {
+		return true
+	}
+	return sv.Entry.Command != "" && mcp.Command == sv.Entry.Command && slices.Equal(mcp.Args, sv.Entry.Args)
+}
+
+// detectClients scans the known client configs.
+func detectClients() []clients.Detection {
+	p, err := clients.DefaultPaths()
+	if err != nil {
+		return nil
+	}
+	return clients.DetectKnown(p)
+}
+
+// serverReferences lists the client config entries that point at sv.
+func serverReferences(dets []clients.Detection, sv registry.Server) []ClientReference {
+	refs := []ClientReference{}
+	for _, det := range dets {
+		for _, mcp := range det.ExistingMCPs {
+			if referencesServer(mcp, sv) {
+				refs = append(refs, ClientReference{Client: det.Name, Path: det.Path, Name: mcp.Name})
+			}
+		}
+	}
+	return refs
+}
+
+// handleServerReferences handles GET /v1/servers/{slug}/references
+func (s *Server) handleServerReferences(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	sv := s.findServer(slug)
+	if sv == nil {
+		http.Error(w, "server not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]any{
+		"slug":       slug,
+		"references": serverReferences(detectClients(), *sv),
+	})
+}
+
+// handleClientServers handles GET /v1/clients/{client}/servers, where client
+// is a detection name such as "Claude Desktop" or a registry key such as
+// claudeDesktop.
+func (s *Server) handleClientServers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/clients/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "servers" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := parts[0]
+	if alias, ok := clientAliases[name]; ok {
+		name = alias
+	}
+	for _, det := range detectClients() {
+		if !strings.EqualFold(det.Name, name) {
+			continue
+		}
+		servers := make([]ClientServer, 0, len(det.ExistingMCPs))
+		for _, mcp := range det.ExistingMCPs {
+			cs := ClientServer{Name: mcp.Name, Command: mcp.Command, Args: mcp.Args}
+			for _, sv := range s.reg.Servers {
+				if referencesServer(mcp, sv) {
+					cs.Slug = sv.Slug
+					break
+				}
+			}
+			servers = append(servers, cs)
+		}
+		slices.SortFunc(servers, func(a, b ClientServer) int { return strings.Compare(a.Name, b.Name) })
+		writeJSON(w, map[string]any{
+			"client":   det.Name,
+			"detected": det.Detected,
+			"path":     det.Path,
+			"servers":  servers,
+		})
+		return
+	}
+	http.Error(w, "unknown client", http.StatusNotFound)
+}
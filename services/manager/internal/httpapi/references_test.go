@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestServerReferences(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("MCP_HOME", "")
+
+	cursor := filepath.Join(home, ".cursor", "mcp.json")
+	if err := os.MkdirAll(filepath.Dir(cursor), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"mcpServers": {
+		"gateway": {"command": "mcp-proxy", "args": ["gw"]},
+		"files": {"command": "/srv/files/bin/files"},
+		"unmanaged": {"command": "npx", "args": ["other-server"]}
+	}}`
+	if err := os.WriteFile(cursor, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+		{
+			Name:    "Gateway",
+			Slug:    "gw",
+			Runtime: registry.Runtime{Kind: "external"},
+			Entry:   registry.Entry{Transport: "http", Command: "mcp-proxy", Args: []string{"gw"}},
+			External: &registry.ExternalInfo{
+				Provider: "openai", APIEndpoint: "https://api.openai.com/v1", AuthType: "api_key", CredentialRef: "openai",
+			},
+			Health: registry.Health{IntervalSec: 30, TimeoutSec: 5},
+		},
+		{
+			Name:   "Files",
+			Slug:   "files",
+			Entry:  registry.Entry{Transport: "stdio", Command: "/srv/files/bin/files"},
+			Health: registry.Health{IntervalSec: 30, TimeoutSec: 5},
+		},
+	}}
+	h := NewServer(reg).Router()
+	get := func(method, path string, v any) int {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		if v != nil && rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+		}
+		return rr.Code
+	}
+
+	// Matched by command line, as the config names it differently
+	var refs struct{ References []ClientReference }
+	if code := get("GET", "/v1/servers/gw/references", &refs); code != http.StatusOK {
+		t.Fatalf("references: %d", code)
+	}
+	if len(refs.References) != 1 || refs.References[0].Client != "Cursor (Global)" || refs.References[0].Name != "gateway" {
+		t.Fatalf("references = %+v", refs.References)
+	}
+	if code := get("GET", "/v1/servers/missing/references", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown server: %d", code)
+	}
+
+	var listing struct {
+		Client  string
+		Servers []ClientServer
+	}
+	if code := get("GET", "/v1/clients/cursorGlobal/servers", &listing); code != http.StatusOK {
+		t.Fatalf("client servers: %d", code)
+	}
+	slugs := map[string]string{}
+	for _, cs := range listing.Servers {
+		slugs[cs.Name] = cs.Slug
+	}
+	if listing.Client != "Cursor (Global)" || len(slugs) != 3 || slugs["gateway"] != "gw" || slugs["files"] != "files" || slugs["unmanaged"] != "" {
+		t.Fatalf("listing = %+v", listing)
+	}
+	if code := get("GET", "/v1/clients/"+url.PathEscape("Cursor (Global)")+"/servers", nil); code != http.StatusOK {
+		t.Fatalf("client by detection name: %d", code)
+	}
+	if code := get("GET", "/v1/clients/nobody/servers", nil); code != http.StatusNotFound {
+		t.Fatalf("unknown client: %d", code)
+	}
+
+	// Deleting a referenced server needs force
+	if code := get("DELETE", "/v1/external/servers/gw", nil); code != http.StatusConflict {
+		t.Fatalf("delete referenced server: %d", code)
+	}
+	if code := get("DELETE", "/v1/external/servers/gw?force=true", nil); code != http.StatusOK {
+		t.Fatalf("forced delete: %d", code)
+	}
+	if len(reg.Servers) != 1 {
+		t.Fatalf("servers after delete: %+v", reg.Servers)
+	}
+}
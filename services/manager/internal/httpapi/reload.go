@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"mcp/manager/internal/registry"
+	"mcp/manager/internal/settings"
+)
+
+// syncSupervisor hands the current registry to the supervisor. With
+// manager.autoRestartOnConfigChange set it also restarts the running servers
+// whose launch configuration changed, returning their slugs; other edits,
+// e.g. to health settings, apply without a restart.
+func (s *Server) syncSupervisor() []string {
+	if s.sup == nil {
+		return nil
+	}
+	s.sup.UpdateRegistry(s.reg)
+	cfg, err := settings.GetCached()
+	if err != nil || !cfg.Manager.AutoRestartOnConfigChange {
+		return nil
+	}
+	return s.sup.RestartChanged()
+}
+
+// handleRegistryReload handles POST /v1/system/reload-registry, picking up
+// edits made to the registry file outside the API.
+func (s *Server) handleRegistryReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	restarted, err := s.reloadRegistry()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"status": "reloaded", "servers": len(s.reg.Servers), "restarted": restarted})
+}
+
+// ReloadRegistry rereads the registry file and hands it to the supervisor
+// like POST /v1/system/reload-registry, returning the servers restarted.
+func (s *Server) ReloadRegistry() ([]string, error) {
+	return s.reloadRegistry()
+}
+
+// registryChanges describes which servers next adds, removes or changes
+// relative to prev, e.g. "added fs; changed github", or "" when none. The
+// last observed status of external servers doesn't count as a change.
+func registryChanges(prev, next *registry.Registry) string {
+	fingerprint := func(sv registry.Server) string {
+		if sv.External != nil {
+			ext := *sv.External
+			ext.Status = registry.ExternalStatus{}
+			sv.External = &ext
+		}
+		data, _ := json.Marshal(sv)
+		return string(data)
+	}
+	before := map[string]string{}
+	if prev != nil {
+		for _, sv := range prev.Servers {
+			before[sv.Slug] = fingerprint(sv)
+		}
+	}
+
+	var added, removed, changed []string
+	for _, sv := range next.Servers {
+		old, ok := before[sv.Slug]
+		switch {
+		case !ok:
+			added = append(added, sv.Slug)
+		case old != fingerprint(sv):
+			changed = append(changed, sv.Slug)
+		}
+		delete(before, sv.Slug)
+	}
+	for slug := range before {
+		removed = append(removed, slug)
+	}
+
+	var parts []string
+	for _, group := range []struct {
+		verb  string
+		slugs []string
+	}{{"added", added}, {"removed", removed}, {"changed", changed}} {
+		if len(group.slugs) > 0 {
+			sort.Strings(group.slugs)
+			parts = append(parts, fmt.Sprintf("%s %s", group.verb, strings.Join(group.slugs, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,120 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"mcp/manager/internal/health"
+)
+
+// maxRPCBody bounds a proxied JSON-RPC request.
+const maxRPCBody = 1 << 20
+
+// JSON-RPC error codes used by the proxy itself.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInternalError  = -32603
+)
+
+// handleServerRPC handles POST /v1/servers/{slug}/rpc. It forwards a single
+// JSON-RPC message to the server, over HTTP or its stdio pipes, when the
+// method passes the server's rpcPolicy, and answers with a JSON-RPC error
+// otherwise. Each call runs in its own MCP session, closed when it returns.
+func (s *Server) handleServerRPC(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sv := s.findServer(slug)
+	if sv == nil {
+		http.Error(w, fmt.Sprintf("unknown server: %s", slug), http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRPCBody+1))
+	if err != nil || len(body) > maxRPCBody {
+		writeRPCError(w, http.StatusRequestEntityTooLarge, nil, rpcInvalidRequest, "request too large")
+		return
+	}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		writeRPCError(w, http.StatusBadRequest, nil, rpcInvalidRequest, "batch requests are not supported")
+		return
+	}
+
+	var msg struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		writeRPCError(w, http.StatusBadRequest, nil, rpcParseError, "parse error")
+		return
+	}
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		writeRPCError(w, http.StatusBadRequest, msg.ID, rpcInvalidRequest, "invalid request")
+		return
+	}
+
+	if !sv.RPC.Permits(msg.Method) {
+		log.Printf("[AUDIT] Denied RPC method %s for server %s", msg.Method, slug)
+		writeRPCError(w, http.StatusOK, msg.ID, rpcMethodNotFound, fmt.Sprintf("method %s is not permitted for server %s", msg.Method, slug))
+		return
+	}
+
+	endpoint, status, err := s.runningMCPEndpoint(sv)
+	if err != nil {
+		writeRPCError(w, status, msg.ID, rpcInternalError, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), introspectTimeout)
+	defer cancel()
+
+	var reply json.RawMessage
+	if endpoint == "" {
+		// A stdio server is shared through the supervisor's broker, which
+		// also applies the rpcPolicy to what is sent
+		conn, attachErr := s.sup.Attach(slug)
+		if attachErr != nil {
+			writeRPCError(w, http.StatusConflict, msg.ID, rpcInternalError, attachErr.Error())
+			return
+		}
+		reply, err = health.StdioForward(ctx, conn, msg.Method, body)
+	} else {
+		reply, err = health.MCPForward(ctx, endpoint, msg.Method, body)
+	}
+	if err != nil {
+		writeRPCError(w, http.StatusBadGateway, msg.ID, rpcInternalError, fmt.Sprintf("failed to reach %s: %v", slug, err))
+		return
+	}
+	if reply == nil {
+		// Notifications have no response
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(reply)
+}
+
+// writeRPCError writes a JSON-RPC error response. A nil id is sent as null.
+func writeRPCError(w http.ResponseWriter, status int, id json.RawMessage, code int, message string) {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   map[string]any{"code": code, "message": message},
+	})
+}
@@ -0,0 +1,182 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/registry"
+)
+
+// stubSupervisor reports every server as running at a fixed URL. Attach
+// opens a session with stdio when set.
+type stubSupervisor struct {
+	url   string
+	stdio func() io.ReadWriteCloser
+}
+
+func (s stubSupervisor) Summary() []map[string]any                    { return nil }
+func (s stubSupervisor) SummaryPage(int, int) ([]map[string]any, int) { return nil, 0 }
+func (s stubSupervisor) Start(string) error                           { return nil }
+func (s stubSupervisor) Stop(string, time.Duration) error             { return nil }
+func (s stubSupervisor) Restart(string) error                         { return nil }
+func (s stubSupervisor) Stats() map[string]interface{}                { return nil }
+func (s stubSupervisor) Shutdown(time.Duration) error                 { return nil }
+func (s stubSupervisor) UpdateRegistry(*registry.Registry)            {}
+func (s stubSupervisor) RestartChanged() []string                     { return nil }
+func (s stubSupervisor) Attach(string) (io.ReadWriteCloser, error) {
+	if s.stdio != nil {
+		return s.stdio(), nil
+	}
+	return nil, errors.New("not running")
+}
+func (s stubSupervisor) GetProcessInfo(string) map[string]interface{} {
+	return map[string]interface{}{"state": "running", "httpURL": s.url}
+}
+
+func TestServerRPCPolicy(t *testing.T) {
+	var forwarded int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		switch msg.Method {
+		case "initialize":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"capabilities":{"tools":{}}}}`, msg.ID)
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			atomic.AddInt32(&forwarded, 1)
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"method":%q}}`, msg.ID, msg.Method)
+		}
+	}))
+	defer upstream.Close()
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+		Slug:  "gated",
+		Entry: registry.Entry{Transport: "http"},
+		RPC:   &registry.RPCPolicy{Allow: []string{"tools/*"}, Deny: []string{"tools/call"}},
+	}}}
+	s := NewServer(reg).WithSupervisor(stubSupervisor{url: upstream.URL})
+
+	call := func(body string) (int, map[string]any) {
+		rr := httptest.NewRecorder()
+		s.Router().ServeHTTP(rr, httptest.NewRequest("POST", "/v1/servers/gated/rpc", strings.NewReader(body)))
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	code, out := call(`{"jsonrpc":"2.0","id":"a1","method":"tools/list"}`)
+	if code != 200 || out["id"] != "a1" {
+		t.Fatalf("allowed call: %d %v", code, out)
+	}
+	if result, _ := out["result"].(map[string]any); result["method"] != "tools/list" {
+		t.Fatalf("allowed call not forwarded: %v", out)
+	}
+
+	for _, method := range []string{"tools/call", "resources/read"} {
+		code, out = call(fmt.Sprintf(`{"jsonrpc":"2.0","id":7,"method":%q}`, method))
+		errObj, _ := out["error"].(map[string]any)
+		if code != 200 || errObj["code"] != float64(rpcMethodNotFound) || out["id"] != float64(7) {
+			t.Fatalf("denied %s: %d %v", method, code, out)
+		}
+	}
+	if n := atomic.LoadInt32(&forwarded); n != 1 {
+		t.Fatalf("expected only the allowed call to reach the server, got %d", n)
+	}
+
+	if code, out = call(`not json`); code != 400 {
+		t.Fatalf("parse error: %d %v", code, out)
+	}
+}
+
+// echoStdio serves one stdio session: initialize, then each request's method
+// echoed back as its result. Sessions are counted in opened and closed.
+func echoStdio(opened, closed *int32) func() io.ReadWriteCloser {
+	return func() io.ReadWriteCloser {
+		atomic.AddInt32(opened, 1)
+		client, server := net.Pipe()
+		go func() {
+			defer atomic.AddInt32(closed, 1)
+			defer server.Close()
+			sc := bufio.NewScanner(server)
+			for sc.Scan() {
+				var msg struct {
+					ID     json.RawMessage `json:"id"`
+					Method string          `json:"method"`
+				}
+				if json.Unmarshal(sc.Bytes(), &msg) != nil || msg.ID == nil {
+					continue
+				}
+				result := fmt.Sprintf(`{"method":%q}`, msg.Method)
+				if msg.Method == "initialize" {
+					result = `{"protocolVersion":"2025-03-26","capabilities":{"tools":{}}}`
+				}
+				fmt.Fprintf(server, `{"jsonrpc":"2.0","id":%s,"result":%s}`+"\n", msg.ID, result)
+			}
+		}()
+		return client
+	}
+}
+
+func TestServerStdioCalls(t *testing.T) {
+	var opened, closed int32
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+		Slug:  "local",
+		Entry: registry.Entry{Transport: "stdio", Command: "server"},
+	}}}
+	s := NewServer(reg).WithSupervisor(stubSupervisor{stdio: echoStdio(&opened, &closed)})
+
+	for i := range 2 {
+		rr := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"tools/call"}`, i)
+		s.Router().ServeHTTP(rr, httptest.NewRequest("POST", "/v1/servers/local/rpc", strings.NewReader(body)))
+		want := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{"method":"tools/call"}}`, i)
+		if rr.Code != http.StatusOK || rr.Body.String() != want {
+			t.Fatalf("call %d: %d %s", i, rr.Code, rr.Body)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/servers/local/tools", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"method":"tools/list"}` {
+		t.Fatalf("tools: %d %s", rr.Code, rr.Body)
+	}
+	rr = httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest("GET", "/v1/servers/local/prompts", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("prompts: %d %s", rr.Code, rr.Body)
+	}
+
+	// A session that ends without a reply is a gateway error, not an empty answer
+	mute := NewServer(reg).WithSupervisor(stubSupervisor{stdio: func() io.ReadWriteCloser {
+		client, server := net.Pipe()
+		server.Close()
+		return client
+	}})
+	rr = httptest.NewRecorder()
+	mute.Router().ServeHTTP(rr, httptest.NewRequest("POST", "/v1/servers/local/rpc", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call"}`)))
+	if rr.Code != http.StatusBadGateway {
+		t.Fatalf("mute server: %d %s", rr.Code, rr.Body)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&closed) != 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if o, c := atomic.LoadInt32(&opened), atomic.LoadInt32(&closed); o != 4 || c != 4 {
+		t.Fatalf("sessions opened %d, closed %d", o, c)
+	}
+}
@@ -1,17 +1,22 @@
 package httpapi
 
 import (
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"mcp/manager/internal/buildinfo"
 	"mcp/manager/internal/clients"
 	"mcp/manager/internal/health"
 	"mcp/manager/internal/install"
@@ -24,14 +29,18 @@ type Server struct {
 	sup               Supervisor
 	healthMonitor     HealthMonitor
 	logStreamer       LogStreamer
-	jobs              map[string]*job
-	jobsMu            sync.Mutex
 	installService    *install.AdvancedInstallationService
 	credentialManager *CredentialManager
+	rootCAs           *x509.CertPool
+	testClientsMu     sync.Mutex
+	testClients       [2]*http.Client // for external server tests, verifying TLS and not
+	ready             atomic.Bool
+	maintenance       maintenance
 }
 
 type Supervisor interface {
 	Summary() []map[string]any
+	SummaryPage(offset, limit int) ([]map[string]any, int)
 	Start(slug string) error
 	Stop(slug string, graceful time.Duration) error
 	Restart(slug string) error
@@ -39,32 +48,43 @@ type Supervisor interface {
 	Stats() map[string]interface{}
 	Shutdown(timeout time.Duration) error
 	UpdateRegistry(newReg *registry.Registry)
+	RestartChanged() []string
+	Attach(slug string) (io.ReadWriteCloser, error)
 }
 
 type HealthMonitor interface {
 	AddProcess(name, transport, httpURL, logPath string)
 	RemoveProcess(name string)
-	AddExternalProcess(name, provider, apiEndpoint, authType string)
+	AddExternalProcess(name, provider, apiEndpoint, authType string, credentialExpiry *time.Time)
 	RemoveExternalProcess(name string)
 	GetProcessHealth(name string) (*health.ProcessHealth, bool)
 	GetExternalProcessHealth(name string) (*health.ExternalProcessHealth, bool)
 	GetAllHealth() map[string]*health.ProcessHealth
 	GetAllExternalHealth() map[string]*health.ExternalProcessHealth
 	GetHealthSummary() map[string]interface{}
+	HealthSummaryPage(offset, limit int) map[string]interface{}
+	HealthStatuses() map[string]health.Status
+	SetInsecureSkipVerify(name string, skip bool)
+	SetCredentialRef(name, ref string)
+	SetHealthEndpoint(name, endpoint string, expect health.HealthExpectation)
+	SetHealthRequest(name string, extra health.HealthRequest)
+	SetHealthComponents(name string, probe health.ComponentProbe)
+	SetProbe(name string, hc registry.Health)
 	Start()
 	Stop()
 }
 
 type LogStreamer interface {
-	StreamLogs(clientID, process string, fromLine int64) (*logs.StreamClient, error)
+	StreamLogs(clientID, process string, fromLine int64, filter *logs.Filter) (*logs.StreamClient, error)
 	StopStream(clientID string)
+	CloseProcess(process string)
 	GetActiveStreams() map[string]interface{}
 	Start()
 	Stop()
 }
 
 func NewServer(reg *registry.Registry) *Server {
-	return &Server{reg: reg, jobs: map[string]*job{}}
+	return &Server{reg: reg}
 }
 
 func (s *Server) WithSupervisor(sup Supervisor) *Server {
@@ -72,6 +92,18 @@ func (s *Server) WithSupervisor(sup Supervisor) *Server {
 	return s
 }
 
+// WithRootCAs makes the server's outbound checks trust rootCAs in addition to the system roots
+func (s *Server) WithRootCAs(rootCAs *x509.CertPool) *Server {
+	s.testClientsMu.Lock()
+	s.rootCAs = rootCAs
+	s.testClients = [2]*http.Client{}
+	s.testClientsMu.Unlock()
+	if s.credentialManager != nil {
+		s.credentialManager.SetRootCAs(rootCAs)
+	}
+	return s
+}
+
 func (s *Server) WithHealthMonitor(hm HealthMonitor) *Server {
 	s.healthMonitor = hm
 	return s
@@ -84,19 +116,35 @@ func (s *Server) WithLogStreamer(ls LogStreamer) *Server {
 
 func (s *Server) WithCredentialManager(cm *CredentialManager) *Server {
 	s.credentialManager = cm
+	if cm != nil && s.rootCAs != nil {
+		cm.SetRootCAs(s.rootCAs)
+	}
 	return s
 }
 
+// MarkReady flips /readyz to 200. The daemon calls it once the autostart
+// sweep has been dispatched; individual servers may still be coming up.
+func (s *Server) MarkReady() {
+	s.ready.Store(true)
+}
+
 // Router returns the HTTP handler.
 func (s *Server) Router() http.Handler {
 	mux := http.NewServeMux()
 
 	// Health endpoint
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(200) })
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.ready.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
 
 	// Core server management
 	mux.HandleFunc("/v1/servers", s.handleServers)
-	mux.HandleFunc("/v1/servers/", s.handleServerActions) // /v1/servers/{slug}/actions or /v1/servers/{slug}/info or /v1/servers/{slug}/env
+	mux.HandleFunc("/v1/servers/", s.handleServerActions) // /v1/servers/{slug}/actions, info, env, tools, resources, prompts, rpc, verify, entrypoint, upgrade, references, attach or logs/download
 
 	// Enhanced monitoring endpoints
 	mux.HandleFunc("/v1/health", s.handleHealth)
@@ -104,6 +152,10 @@ func (s *Server) Router() http.Handler {
 	mux.HandleFunc("/v1/health/external", s.handleExternalHealthSummary)
 	mux.HandleFunc("/v1/health/external/", s.handleExternalHealthDetail) // /v1/health/external/{slug}
 	mux.HandleFunc("/v1/stats", s.handleStats)
+	mux.HandleFunc("/v1/metrics", s.handleMetrics) // Prometheus text format
+	mux.HandleFunc("/v1/overview", s.handleOverview)
+	mux.HandleFunc("/v1/version", s.handleVersion)
+	mux.HandleFunc("/v1/doctor", s.handleDoctor)
 
 	// Log streaming endpoints
 	mux.HandleFunc("/v1/logs/stream/", s.handleLogStream) // /v1/logs/stream/{slug}
@@ -114,25 +166,32 @@ func (s *Server) Router() http.Handler {
 	mux.HandleFunc("/v1/install/perform", s.handleInstallPerform)
 	mux.HandleFunc("/v1/install/start", s.handleInstallStart)
 	mux.HandleFunc("/v1/install/logs", s.handleInstallLogs)
+	mux.HandleFunc("/v1/install/logs/stream", s.handleInstallLogStream) // SSE, ?jobId=
 	mux.HandleFunc("/v1/install/cancel", s.handleInstallCancel)
 	mux.HandleFunc("/v1/install/finalize", s.handleInstallFinalize)
 	mux.HandleFunc("/v1/install/list", s.handleInstallList)
+	mux.HandleFunc("/v1/install/capabilities", s.handleInstallCapabilities)
 
 	// Client configuration endpoints
 	mux.HandleFunc("/v1/clients/detect", s.handleClientsDetect)
 	mux.HandleFunc("/v1/clients/apply", s.handleClientsApply)
+	mux.HandleFunc("/v1/clients/diff", s.handleClientsDiff)
 	mux.HandleFunc("/v1/clients/preview", s.handleClientsPreview)
 	mux.HandleFunc("/v1/clients/current", s.handleClientsCurrent)
 	mux.HandleFunc("/v1/clients/paths", s.handleClientsPaths)
 	mux.HandleFunc("/v1/clients/adopt", s.handleClientsAdopt)
+	mux.HandleFunc("/v1/clients/", s.handleClientServers) // /v1/clients/{client}/servers
 
 	// External server management endpoints
 	mux.HandleFunc("/v1/external/servers", s.handleExternalMCPs)
 	mux.HandleFunc("/v1/external/servers/", s.handleExternalMCPActions) // /v1/external/servers/{slug} or /v1/external/servers/{slug}/test
 	mux.HandleFunc("/v1/external/providers", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
+		switch r.Method {
+		case http.MethodGet:
 			s.handleListProviders(w, r)
-		} else {
+		case http.MethodPost:
+			s.handleCreateProvider(w, r)
+		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
 		}
 	})
@@ -181,6 +240,8 @@ func (s *Server) Router() http.Handler {
 	// System endpoints
 	mux.HandleFunc("/v1/system/open", s.handleSystemOpen)
 	mux.HandleFunc("/v1/system/macos/autostart", s.handleMacOSAutostart)
+	mux.HandleFunc("/v1/system/maintenance", s.handleSystemMaintenance)
+	mux.HandleFunc("/v1/system/reload-registry", s.handleRegistryReload)
 
 	// Credential management endpoints
 	mux.HandleFunc("/v1/credentials", s.handleCredentialsStore)
@@ -206,33 +267,60 @@ func (s *Server) Router() http.Handler {
 	mux.HandleFunc("/v1/credentials/validate", s.handleCredentialsValidate)
 	mux.HandleFunc("/v1/credentials/status", s.handleCredentialsStatus)
 	mux.HandleFunc("/v1/credentials/validate-stored", s.handleCredentialsValidateStored)
+	mux.HandleFunc("/v1/credentials/audit", s.handleCredentialsAudit)
 
-	return withCORS(logRequests(mux))
+	return withCORS(logRequests(s.withMaintenance(mux)))
 }
 
 func (s *Server) handleServers(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		if s.sup != nil {
-			writeJSON(w, s.sup.Summary())
+		page, err := parsePage(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": err.Error()})
 			return
 		}
-		type outServer struct{ Name, Slug, Status string }
-		var out []outServer
-		for _, v := range s.reg.Servers {
-			// Only include servers that are not external
-			if !v.IsExternal() {
-				out = append(out, outServer{Name: v.Name, Slug: v.Slug, Status: "down"})
-			}
+		rows, total := s.serverList(page.offset, page.limit)
+		// The body stays a bare array, so paging details travel in headers
+		w.Header().Set("X-Total-Count", strconv.Itoa(total))
+		if next := page.nextCursor(total); next != "" {
+			w.Header().Set("X-Next-Cursor", next)
 		}
-		writeJSON(w, out)
+		writeJSONCached(w, r, page.selectFields(rows))
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
+// serverList returns a page of the supervisor summary, or of every local
+// server as down when no supervisor is attached, and the number of servers
+// in all. A limit of 0 returns every server from offset on.
+func (s *Server) serverList(offset, limit int) ([]map[string]any, int) {
+	if s.sup != nil {
+		return s.sup.SummaryPage(offset, limit)
+	}
+	var out []map[string]any
+	for _, v := range s.reg.Servers {
+		// Only include servers that are not external
+		if !v.IsExternal() {
+			out = append(out, map[string]any{"Name": v.Name, "Slug": v.Slug, "Status": "down"})
+		}
+	}
+	total := len(out)
+	out = out[min(offset, total):]
+	if limit > 0 && limit < len(out) {
+		out = out[:limit]
+	}
+	return out, total
+}
+
 func (s *Server) handleServerActions(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) == 4 && parts[3] != "" {
+		s.handleServerDelete(w, r, parts[3])
+		return
+	}
 	if len(parts) < 5 {
 		w.WriteHeader(http.StatusBadRequest)
 		return
@@ -248,6 +336,26 @@ func (s *Server) handleServerActions(w http.ResponseWriter, r *http.Request) {
 		s.handleServerInfo(w, r, slug)
 	case "env":
 		s.handleServerEnv(w, r)
+	case "tools", "resources", "prompts":
+		s.handleServerIntrospect(w, r, slug, action)
+	case "rpc":
+		s.handleServerRPC(w, r, slug)
+	case "verify":
+		s.handleServerVerify(w, r, slug)
+	case "entrypoint":
+		s.handleServerEntryPoint(w, r, slug)
+	case "upgrade":
+		s.handleServerUpgrade(w, r, slug)
+	case "references":
+		s.handleServerReferences(w, r, slug)
+	case "attach":
+		s.handleServerAttach(w, r, slug)
+	case "logs":
+		if len(parts) < 6 || parts[5] != "download" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.handleLogsDownload(w, r, slug)
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -274,43 +382,151 @@ func (s *Server) handleServerActionsPost(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	switch body.Action {
+	if err := s.serverAction(slug, body.Action); err != nil {
+		if errors.Is(err, errUnknownAction) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]string{"status": "error", "message": err.Error()})
+		return
+	}
+
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// errUnknownAction is returned by serverAction for anything but start, restart or stop.
+var errUnknownAction = errors.New("unknown action")
+
+// serverAction starts, restarts or stops a server through the supervisor and
+// keeps health monitoring in step. The caller must ensure s.sup is set.
+func (s *Server) serverAction(slug, action string) error {
+	switch action {
 	case "start":
 		if err := s.sup.Start(slug); err != nil {
-			writeJSON(w, map[string]string{"status": "error", "message": err.Error()})
-			return
+			return err
 		}
 		// Add to health monitoring if available
 		if s.healthMonitor != nil {
 			if sv := s.findServer(slug); sv != nil {
 				httpURL := ""
 				if sv.Entry.Transport == "http" {
-					httpURL = deriveHTTPURL(sv.Entry.Args, sv.Entry.Env)
+					httpURL = registry.DeriveHTTPURL(sv.Entry.Args, sv.Entry.Env)
 				}
 				logPath := fmt.Sprintf("/var/log/mcp/%s.log", slug) // TODO: Use proper logs dir
 				s.healthMonitor.AddProcess(slug, sv.Entry.Transport, httpURL, logPath)
+				s.healthMonitor.SetProbe(slug, sv.Health)
 			}
 		}
 	case "restart":
-		if err := s.sup.Restart(slug); err != nil {
-			writeJSON(w, map[string]string{"status": "error", "message": err.Error()})
-			return
-		}
+		return s.sup.Restart(slug)
 	case "stop":
 		if err := s.sup.Stop(slug, 10*time.Second); err != nil {
-			writeJSON(w, map[string]string{"status": "error", "message": err.Error()})
-			return
+			return err
 		}
 		// Remove from health monitoring
 		if s.healthMonitor != nil {
 			s.healthMonitor.RemoveProcess(slug)
 		}
+		// End log streams so clients see the stream close instead of stalling
+		if s.logStreamer != nil {
+			s.logStreamer.CloseProcess(slug)
+		}
 	default:
+		return errUnknownAction
+	}
+	return nil
+}
+
+// handleServerDelete handles DELETE /v1/servers/{slug}, uninstalling a local
+// server: its process is stopped and unmonitored, its files are removed and
+// its registry entry is deleted. Like deleting an external server it answers
+// 409 while client configs still reference it, unless ?force=true. A slug
+// missing from the registry only has leftover files cleaned up, so repeating
+// the request succeeds. The response lists the removed paths and packages.
+func (s *Server) handleServerDelete(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !registry.ValidSlug(slug) {
 		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("invalid slug: %q", slug)})
 		return
 	}
 
-	writeJSON(w, map[string]string{"status": "ok"})
+	sv := s.findServer(slug)
+	if sv != nil && sv.IsExternal() {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Server is external; delete it with DELETE /v1/external/servers/" + slug})
+		return
+	}
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	if !force && sv != nil {
+		if refs := serverReferences(detectClients(), *sv); len(refs) > 0 {
+			w.WriteHeader(http.StatusConflict)
+			writeJSON(w, map[string]any{
+				"error":      "Server is still referenced by client configs; retry with ?force=true to delete anyway",
+				"references": refs,
+			})
+			return
+		}
+		// Checked before anything is stopped or removed: the registry
+		// wouldn't save with a dependsOn naming a missing server.
+		if dependents := s.reg.Dependents(slug); len(dependents) > 0 {
+			w.WriteHeader(http.StatusConflict)
+			writeJSON(w, map[string]any{
+				"error":      "Other servers depend on this server; retry with ?force=true to delete it and drop it from their dependsOn",
+				"dependents": dependents,
+			})
+			return
+		}
+	}
+
+	if s.sup != nil {
+		if err := s.sup.Stop(slug, 10*time.Second); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to stop server: %v", err)})
+			return
+		}
+	}
+	if s.healthMonitor != nil {
+		s.healthMonitor.RemoveProcess(slug)
+	}
+	if s.logStreamer != nil {
+		s.logStreamer.CloseProcess(slug)
+	}
+
+	result, err := install.Uninstall(r.Context(), slug)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, map[string]any{"error": err.Error(), "removed": result.Removed})
+		return
+	}
+
+	if sv != nil {
+		for i := range s.reg.Servers {
+			if s.reg.Servers[i].Slug == slug {
+				s.reg.Servers = append(s.reg.Servers[:i], s.reg.Servers[i+1:]...)
+				break
+			}
+		}
+		s.reg.RemoveDependency(slug)
+		if err := s.saveRegistry(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, map[string]string{"error": fmt.Sprintf("Failed to save registry: %v", err)})
+			return
+		}
+		if s.sup != nil {
+			s.sup.UpdateRegistry(s.reg)
+		}
+		log.Printf("[AUDIT] Uninstalled server %s", slug)
+	}
+
+	writeJSON(w, struct {
+		Status     string `json:"status"`
+		Registered bool   `json:"registered"` // whether a registry entry was deleted
+		*install.UninstallResult
+	}{"deleted", sv != nil, result})
 }
 
 // handleServerInfo handles GET requests to /v1/servers/{slug}/info
@@ -339,7 +555,7 @@ func (s *Server) findServer(slug string) *registry.Server {
 	return nil
 }
 
-// handleHealth handles GET requests to /v1/health
+// handleHealth handles GET requests to /v1/health?limit=&offset=&cursor=&fields=
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -351,7 +567,19 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	summary := s.healthMonitor.GetHealthSummary()
+	page, err := parsePage(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	summary := s.healthMonitor.HealthSummaryPage(page.offset, page.limit)
+	summary["processes"] = page.selectFields(summary["processes"].([]map[string]interface{}))
+	external := summary["external"].(map[string]interface{})
+	external["processes"] = page.selectFields(external["processes"].([]map[string]interface{}))
+	if next := page.nextCursor(summary["totalProcesses"].(int)); next != "" {
+		summary["nextCursor"] = next
+	}
 	writeJSON(w, summary)
 }
 
@@ -384,7 +612,9 @@ func (s *Server) handleHealthDetail(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, health)
 }
 
-// handleExternalHealthSummary handles GET requests to /v1/health/external
+// handleExternalHealthSummary handles GET requests to /v1/health/external.
+// The counts cover every external server; the rows are paged and trimmed
+// like those of /v1/health, in name order.
 func (s *Server) handleExternalHealthSummary(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -396,6 +626,13 @@ func (s *Server) handleExternalHealthSummary(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	page, err := parsePage(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
 	externalHealth := s.healthMonitor.GetAllExternalHealth()
 
 	summary := map[string]interface{}{
@@ -403,9 +640,9 @@ func (s *Server) handleExternalHealthSummary(w http.ResponseWriter, r *http.Requ
 		"healthy":       0,
 		"degraded":      0,
 		"down":          0,
-		"servers":       make([]map[string]interface{}, 0, len(externalHealth)),
 	}
 
+	rows := make([]map[string]interface{}, 0, len(externalHealth))
 	for _, ph := range externalHealth {
 		switch ph.Status {
 		case health.Ready:
@@ -426,12 +663,27 @@ func (s *Server) handleExternalHealthSummary(w http.ResponseWriter, r *http.Requ
 			"totalChecks":       ph.TotalChecks,
 			"totalFailures":     ph.TotalFailures,
 			"avgResponseTime":   ph.AvgResponseTime.Milliseconds(),
+			"p50ResponseTime":   ph.P50ResponseTime.Milliseconds(),
+			"p95ResponseTime":   ph.P95ResponseTime.Milliseconds(),
+			"p99ResponseTime":   ph.P99ResponseTime.Milliseconds(),
 			"credentialWarning": ph.CredentialWarning,
+			"expiresAt":         ph.CredentialExpiry,
 			"rateLimited":       ph.RateLimited,
 			"lastErrorCode":     ph.LastErrorCode,
 		}
 
-		summary["servers"] = append(summary["servers"].([]map[string]interface{}), serverInfo)
+		rows = append(rows, serverInfo)
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i]["name"].(string) < rows[j]["name"].(string) })
+	total := len(rows)
+	rows = rows[min(page.offset, total):]
+	if len(rows) > page.limit {
+		rows = rows[:page.limit]
+	}
+	summary["servers"] = page.selectFields(rows)
+	if next := page.nextCursor(total); next != "" {
+		summary["nextCursor"] = next
 	}
 
 	writeJSON(w, summary)
@@ -466,6 +718,15 @@ func (s *Server) handleExternalHealthDetail(w http.ResponseWriter, r *http.Reque
 	writeJSON(w, health)
 }
 
+// handleVersion handles GET requests to /v1/version
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, buildinfo.Get())
+}
+
 // handleStats handles GET requests to /v1/stats
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -473,7 +734,7 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := map[string]interface{}{}
+	response := map[string]interface{}{"maintenance": s.Maintenance()}
 
 	// Add supervisor stats
 	if s.sup != nil {
@@ -493,7 +754,116 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, response)
 }
 
-// handleLogStream handles WebSocket connections for log streaming
+// handleMetrics handles GET /v1/metrics, the supervisor stats, per-process
+// resource usage and health checks in the Prometheus text format for
+// scraping. Processes are labelled by slug in registry order.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var m metricsWriter
+	if s.sup != nil {
+		stats := s.sup.Stats()
+		for _, c := range []struct{ name, key, help string }{
+			{"mcp_total_starts", "totalStarts", "Processes started since the manager started."},
+			{"mcp_total_stops", "totalStops", "Processes stopped since the manager started."},
+			{"mcp_total_restarts", "totalRestarts", "Processes restarted since the manager started."},
+		} {
+			if v, ok := metricValue(stats[c.key]); ok {
+				m.family(c.name, "counter", c.help)
+				m.sample(c.name, v)
+			}
+		}
+		m.family("mcp_processes", "gauge", "Managed processes by state.")
+		for _, state := range []string{"running", "stopped", "failed"} {
+			v, _ := metricValue(stats[state])
+			m.sample("mcp_processes", v, "state", state)
+		}
+
+		var slugs []string
+		infos := map[string]map[string]interface{}{}
+		for _, sv := range s.reg.Servers {
+			if !sv.IsExternal() {
+				slugs = append(slugs, sv.Slug)
+				infos[sv.Slug] = s.sup.GetProcessInfo(sv.Slug)
+			}
+		}
+		m.family("mcp_process_up", "gauge", "Whether the process is running.")
+		for _, slug := range slugs {
+			up := 0.0
+			if infos[slug]["state"] == "running" {
+				up = 1
+			}
+			m.sample("mcp_process_up", up, "slug", slug)
+		}
+		for _, g := range []struct{ name, kind, key, help string }{
+			{"mcp_process_cpu_percent", "gauge", "cpuPercent", "CPU usage of the process in percent of one core."},
+			{"mcp_process_rss_bytes", "gauge", "rssBytes", "Resident memory of the process."},
+			{"mcp_process_restarts_total", "counter", "restarts", "Restarts of the process."},
+		} {
+			m.family(g.name, g.kind, g.help)
+			for _, slug := range slugs {
+				// Stopped processes and unsupported platforms have no sample
+				if v, ok := metricValue(infos[slug][g.key]); ok && infos[slug]["exists"] == true {
+					m.sample(g.name, v, "slug", slug)
+				}
+			}
+		}
+	}
+
+	if s.healthMonitor != nil {
+		summary := s.healthMonitor.GetHealthSummary()
+		m.family("mcp_health_processes", "gauge", "Monitored servers by health status.")
+		for _, status := range []string{"healthy", "degraded", "down"} {
+			v, _ := metricValue(summary[status])
+			m.sample("mcp_health_processes", v, "status", status)
+		}
+
+		rows, _ := summary["processes"].([]map[string]interface{})
+		if external, ok := summary["external"].(map[string]interface{}); ok {
+			externalRows, _ := external["processes"].([]map[string]interface{})
+			rows = append(rows, externalRows...)
+		}
+		m.family("mcp_health_ready", "gauge", "Whether the server's last health check found it ready.")
+		for _, row := range rows {
+			ready := 0.0
+			if row["status"] == string(health.Ready) {
+				ready = 1
+			}
+			m.sample("mcp_health_ready", ready, "slug", fmt.Sprint(row["name"]))
+		}
+		for _, c := range []struct{ name, key, help string }{
+			{"mcp_health_checks_total", "totalChecks", "Health checks run against the server."},
+			{"mcp_health_check_failures_total", "totalFailures", "Health checks the server failed."},
+		} {
+			m.family(c.name, "counter", c.help)
+			for _, row := range rows {
+				if v, ok := metricValue(row[c.key]); ok {
+					m.sample(c.name, v, "slug", fmt.Sprint(row["name"]))
+				}
+			}
+		}
+		m.family("mcp_health_response_seconds", "gauge", "Response time percentiles of the recent successful health checks.")
+		for _, row := range rows {
+			for _, q := range []struct{ quantile, key string }{{"0.5", "p50ResponseTime"}, {"0.95", "p95ResponseTime"}, {"0.99", "p99ResponseTime"}} {
+				if ms, ok := metricValue(row[q.key]); ok {
+					m.sample("mcp_health_response_seconds", ms/1000, "slug", fmt.Sprint(row["name"]), "quantile", q.quantile)
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(m.buf.Bytes())
+}
+
+// handleLogStream handles GET /v1/logs/stream/{slug}. A client that asks to
+// upgrade gets the entries as WebSocket text frames and may pause and resume
+// the stream; any other client gets Server-Sent Events. ?fromLine= replays
+// the log from that line first, and level, contains, regex and since limit
+// both the replay and the live entries to those that match.
 func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) < 5 {
@@ -515,18 +885,26 @@ func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	filter, err := logFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if s.logStreamer == nil {
 		http.Error(w, "log streaming not available", http.StatusServiceUnavailable)
 		return
 	}
 
-	// For now, return JSON streaming instead of WebSocket
-	// TODO: Implement proper WebSocket support
-	client, err := s.logStreamer.StreamLogs(clientID, slug, fromLine)
+	client, err := s.logStreamer.StreamLogs(clientID, slug, fromLine, filter)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("failed to start log stream: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if isWebSocketUpgrade(r) {
+		s.streamLogsWebSocket(w, r, client)
+		return
+	}
 
 	// Set up Server-Sent Events
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -559,6 +937,72 @@ func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// streamLogsWebSocket sends client's entries over a WebSocket as JSON text
+// frames. The client may send {"action":"pause"} and {"action":"resume"};
+// while paused nothing is read from the stream, so the streamer's overflow
+// policy applies as for any slow client. The stream is stopped when the
+// socket closes.
+func (s *Server) streamLogsWebSocket(w http.ResponseWriter, r *http.Request, client *logs.StreamClient) {
+	defer s.logStreamer.StopStream(client.ID)
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer ws.close()
+
+	var paused atomic.Bool
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			data, err := ws.readMessage()
+			if err != nil {
+				return
+			}
+			var msg struct {
+				Action string `json:"action"`
+			}
+			if json.Unmarshal(data, &msg) != nil {
+				continue
+			}
+			switch msg.Action {
+			case "pause":
+				paused.Store(true)
+			case "resume":
+				paused.Store(false)
+			default:
+				continue
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	for {
+		var entries <-chan logs.LogEntry
+		if !paused.Load() {
+			entries = client.Ch
+		}
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(entry)
+			if err := ws.writeText(data); err != nil {
+				return
+			}
+		case <-changed:
+		case <-done:
+			return
+		}
+	}
+}
+
 func (s *Server) handleInstallValidate(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -611,7 +1055,20 @@ func (s *Server) handleClientsDetect(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, out)
 }
 
+// handleClientsApply handles POST /v1/clients/apply, merging config into a
+// client's config file. With "dryRun": true it only reports the diff, like
+// POST /v1/clients/diff.
 func (s *Server) handleClientsApply(w http.ResponseWriter, r *http.Request) {
+	s.applyClientConfig(w, r, false)
+}
+
+// handleClientsDiff handles POST /v1/clients/diff, which takes the body of
+// POST /v1/clients/apply and returns the unified diff it would make.
+func (s *Server) handleClientsDiff(w http.ResponseWriter, r *http.Request) {
+	s.applyClientConfig(w, r, true)
+}
+
+func (s *Server) applyClientConfig(w http.ResponseWriter, r *http.Request, dryRun bool) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -620,37 +1077,62 @@ func (s *Server) handleClientsApply(w http.ResponseWriter, r *http.Request) {
 		Client string         `json:"client"`
 		Config map[string]any `json:"config"`
 		Path   string         `json:"path,omitempty"`
+		DryRun bool           `json:"dryRun,omitempty"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	dryRun = dryRun || body.DryRun
+
 	p, _ := clients.DefaultPaths()
+	var (
+		path  = body.Path
+		plan  func(string, any) (*clients.Plan, error)
+		write func(string, any) error
+	)
 	switch body.Client {
 	case "Claude Desktop":
-		path := body.Path
 		if path == "" {
 			path = p.ClaudeDesktop
 		}
-		if err := clients.WriteClaudeDesktop(path, body.Config); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+		plan, write = clients.PlanClaudeDesktop, clients.WriteClaudeDesktop
 	case "Cursor (Global)":
-		path := body.Path
 		if path == "" {
 			path = p.CursorGlobal
 		}
-		if err := clients.WriteCursorGlobal(path, body.Config); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		plan, write = clients.PlanCursorGlobal, clients.WriteCursorGlobal
+	case "VS Code":
+		if path == "" {
+			path = p.VSCode
+		}
+		plan, write = clients.PlanVSCode, clients.WriteVSCode
+	case "Windsurf":
+		if path == "" {
+			path = p.Windsurf
 		}
+		plan, write = clients.PlanWindsurf, clients.WriteWindsurf
 	default:
 		// For CLI tools, we only provide snippet generation on the UI side in v0
 		writeJSON(w, map[string]string{"status": "snippet-only"})
 		return
 	}
-	writeJSON(w, map[string]string{"status": "ok"})
+
+	changes, err := plan(path, body.Config)
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+	if dryRun {
+		writeJSON(w, map[string]any{"status": "dry-run", "path": changes.Path, "changed": changes.Changed, "diff": changes.Diff, "skipped": changes.Skipped})
+		return
+	}
+	if err := write(path, body.Config); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]any{"status": "ok", "path": changes.Path, "changed": changes.Changed, "diff": changes.Diff, "skipped": changes.Skipped})
 }
 
 func (s *Server) handleClientsPreview(w http.ResponseWriter, r *http.Request) {
@@ -678,17 +1160,31 @@ func (s *Server) handleClientsCurrent(w http.ResponseWriter, r *http.Request) {
 		path = p.ClaudeDesktop
 	case "Cursor (Global)":
 		path = p.CursorGlobal
+	case "VS Code":
+		path = p.VSCode
+	case "Windsurf":
+		path = p.Windsurf
 	default:
-		writeJSON(w, map[string]any{})
+		writeJSONCached(w, r, map[string]any{})
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		writeJSONCached(w, r, map[string]any{})
+		return
+	}
+	etag := fileETag(info)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	b, err := os.ReadFile(path)
 	if err != nil {
-		writeJSON(w, map[string]any{})
+		writeJSONCached(w, r, map[string]any{})
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write(b)
+	writeWithETag(w, r, etag, b)
 }
 
 func (s *Server) handleClientsPaths(w http.ResponseWriter, r *http.Request) {
@@ -707,6 +1203,8 @@ func (s *Server) handleClientsPaths(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]string{
 		"claudeDesktop": p.ClaudeDesktop,
 		"cursorGlobal":  p.CursorGlobal,
+		"vscode":        p.VSCode,
+		"windsurf":      p.Windsurf,
 		"store":         p.Store,
 	})
 }
@@ -737,12 +1235,7 @@ func (s *Server) handleClientsAdopt(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save the updated registry
-	registryPath := os.Getenv("MCP_REGISTRY_PATH")
-	if registryPath == "" {
-		registryPath = filepath.Join(os.Getenv("HOME"), ".mcp", "registry.json")
-	}
-
-	if err := s.reg.Save(registryPath); err != nil {
+	if err := s.saveRegistry(); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		writeJSON(w, map[string]string{"error": "Failed to save registry: " + err.Error()})
 		return
@@ -796,7 +1289,8 @@ func logRequests(next http.Handler) http.Handler {
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Client-ID, If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -805,31 +1299,3 @@ func withCORS(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-// deriveHTTPURL constructs HTTP URL from command args and environment
-func deriveHTTPURL(args []string, env map[string]string) string {
-	if env != nil {
-		if u, ok := env["HEALTH_HTTP_URL"]; ok && u != "" {
-			return u
-		}
-	}
-
-	var port string
-	for i := 0; i < len(args); i++ {
-		a := args[i]
-		if strings.HasPrefix(a, "--port=") {
-			port = strings.TrimPrefix(a, "--port=")
-			break
-		}
-		if a == "-p" && i+1 < len(args) {
-			port = args[i+1]
-			break
-		}
-	}
-
-	if port != "" {
-		return "http://127.0.0.1:" + port
-	}
-
-	return ""
-}
@@ -2,10 +2,20 @@ package httpapi
 
 import (
 	"encoding/json"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"mcp/manager/internal/health"
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/providers"
 	"mcp/manager/internal/registry"
+	"mcp/manager/internal/supervisor"
 )
 
 func TestServersGET(t *testing.T) {
@@ -34,3 +44,465 @@ func TestServersGET(t *testing.T) {
 		t.Fatalf("unexpected body: %s", rr.Body.String())
 	}
 }
+
+func TestServersAndHealthPaging(t *testing.T) {
+	reg := &registry.Registry{Version: "1.0"}
+	mon := health.NewHealthMonitor(0)
+	for _, slug := range []string{"a", "b", "c"} {
+		reg.Servers = append(reg.Servers, registry.Server{Name: strings.ToUpper(slug), Slug: slug})
+		mon.AddProcess(slug, "stdio", "", "")
+	}
+	mon.AddExternalProcess("x", "github", "https://api.github.com", "api_key", nil)
+	h := NewServer(reg).WithHealthMonitor(mon).Router()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/servers?limit=2&fields=slug", nil))
+	var servers []map[string]any
+	_ = json.Unmarshal(rr.Body.Bytes(), &servers)
+	if len(servers) != 2 || len(servers[0]) != 1 || servers[1]["Slug"] != "b" {
+		t.Fatalf("first page = %s", rr.Body.String())
+	}
+	if rr.Header().Get("X-Total-Count") != "3" || rr.Header().Get("X-Next-Cursor") != "2" {
+		t.Fatalf("paging headers = %v", rr.Header())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/servers?cursor=2", nil))
+	servers = nil
+	_ = json.Unmarshal(rr.Body.Bytes(), &servers)
+	if len(servers) != 1 || servers[0]["Slug"] != "c" || rr.Header().Get("X-Next-Cursor") != "" {
+		t.Fatalf("last page = %s", rr.Body.String())
+	}
+
+	// Health pages run over local processes, then external ones
+	var summary struct {
+		TotalProcesses int              `json:"totalProcesses"`
+		Processes      []map[string]any `json:"processes"`
+		External       struct {
+			Processes []map[string]any `json:"processes"`
+		} `json:"external"`
+		NextCursor string `json:"nextCursor"`
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/health?offset=2&limit=2&fields=name,status", nil))
+	_ = json.Unmarshal(rr.Body.Bytes(), &summary)
+	if summary.TotalProcesses != 4 || len(summary.Processes) != 1 || summary.Processes[0]["name"] != "c" ||
+		len(summary.External.Processes) != 1 || len(summary.External.Processes[0]) != 2 || summary.NextCursor != "" {
+		t.Fatalf("health page = %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/health?limit=0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0 status = %d", rr.Code)
+	}
+
+	// The external summary counts every server and pages its rows by name
+	mon.AddExternalProcess("w", "slack", "https://slack.com/api", "oauth2", nil)
+	var external struct {
+		TotalExternal int              `json:"totalExternal"`
+		Servers       []map[string]any `json:"servers"`
+		NextCursor    string           `json:"nextCursor"`
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/health/external?limit=1&fields=name,provider", nil))
+	_ = json.Unmarshal(rr.Body.Bytes(), &external)
+	if external.TotalExternal != 2 || len(external.Servers) != 1 || len(external.Servers[0]) != 2 ||
+		external.Servers[0]["name"] != "w" || external.NextCursor != "1" {
+		t.Fatalf("external page = %s", rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/health/external?cursor=x", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad cursor status = %d", rr.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	s := NewServer(&registry.Registry{Version: "1.0"})
+	h := s.Router()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != 503 {
+		t.Fatalf("expected 503 before MarkReady, got %d", rr.Code)
+	}
+
+	s.MarkReady()
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/readyz", nil))
+	if rr.Code != 200 {
+		t.Fatalf("expected 200 after MarkReady, got %d", rr.Code)
+	}
+}
+
+func TestServerInfoAndHealthAgree(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	srvDir, err := paths.ServersDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(srvDir, "svc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logsDir, _ := paths.LogsDir()
+
+	// Answers initialize, the health monitor's handshake probe
+	script := `while read l; do case "$l" in *'"initialize"'*)
+		id=$(echo "$l" | sed 's/.*"id":\([0-9]*\).*/\1/')
+		echo "{\"jsonrpc\":\"2.0\",\"id\":$id,\"result\":{\"protocolVersion\":\"2025-03-26\",\"capabilities\":{}}}";;
+	esac; done`
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+		Slug:   "svc",
+		Entry:  registry.Entry{Transport: "stdio", Command: "sh", Args: []string{"-c", script}},
+		Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+	}}}
+	sup := supervisor.New(reg, 0, 0)
+	defer sup.Shutdown(time.Second)
+	mon := health.NewHealthMonitor(20 * time.Millisecond)
+	mon.SetProcessSource(sup)
+	sup.SetHealthSource(mon)
+	mon.Start()
+	defer mon.Stop()
+	h := NewServer(reg).WithSupervisor(sup).WithHealthMonitor(mon).Router()
+
+	statuses := func() (state, info, monitor string) {
+		t.Helper()
+		var in struct{ State, Status string }
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/servers/svc/info", nil))
+		_ = json.Unmarshal(rr.Body.Bytes(), &in)
+		var ph struct{ Status string }
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/health/svc", nil))
+		_ = json.Unmarshal(rr.Body.Bytes(), &ph)
+		return in.State, in.Status, ph.Status
+	}
+	waitFor := func(what string, ok func(state, info, monitor string) bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			if ok(statuses()) {
+				return
+			}
+			if time.Now().After(deadline) {
+				state, info, monitor := statuses()
+				t.Fatalf("timed out waiting for %s: state %s, info %s, health %s", what, state, info, monitor)
+			}
+		}
+	}
+	agree := func(when string) {
+		t.Helper()
+		for i := 0; i < 5; i++ {
+			if _, info, monitor := statuses(); info != monitor {
+				t.Fatalf("%s: /info reports %s but /v1/health reports %s", when, info, monitor)
+			}
+		}
+	}
+
+	if err := sup.Start("svc"); err != nil {
+		t.Fatal(err)
+	}
+	mon.AddProcess("svc", "stdio", "", filepath.Join(logsDir, "svc.log"))
+	waitFor("ready", func(state, info, monitor string) bool { return monitor == string(health.Ready) })
+	agree("while running")
+
+	// A crash is reported Down by both before the monitor's next check
+	pid := sup.GetProcessInfo("svc")["pid"].(int)
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the crash", func(state, info, monitor string) bool { return state != "running" })
+	agree("after the crash")
+	if _, info, _ := statuses(); info != string(health.Down) {
+		t.Fatalf("crashed server reported %s", info)
+	}
+	if err := sup.Stop("svc", time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerEntryPoint(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+		Name: "demo", Slug: "demo",
+		Health: registry.Health{IntervalSec: 30, TimeoutSec: 5},
+		Entry:  registry.Entry{Transport: "stdio", Command: "/venv/bin/demo-cli", EntryPoint: "demo-cli", EntryPoints: []registry.EntryPoint{
+			{Name: "demo-cli", Kind: "console_script", Command: "/venv/bin/demo-cli"},
+			{Name: "demo-server", Kind: "console_script", Command: "/venv/bin/demo-server"},
+		}},
+	}}}
+	router := NewServer(reg).Router()
+	put := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/v1/servers/demo/entrypoint", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := put(`{"name":"demo-server"}`); rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	if e := reg.Servers[0].Entry; e.Command != "/venv/bin/demo-server" || e.EntryPoint != "demo-server" {
+		t.Fatalf("entry = %+v", e)
+	}
+	saved, err := registry.LoadDefault()
+	if err != nil || saved.Servers[0].Entry.Command != "/venv/bin/demo-server" {
+		t.Fatalf("registry not saved: %v", err)
+	}
+
+	if rr := put(`{"name":"demo-admin"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown entry point: status %d", rr.Code)
+	}
+	if reg.Servers[0].Entry.Command != "/venv/bin/demo-server" {
+		t.Fatal("failed switch changed the entry")
+	}
+}
+
+func TestServerEnvStoresSecretsOnFirstUse(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+		Name: "demo", Slug: "demo",
+		Entry:  registry.Entry{Transport: "stdio", Command: "demo", RequiredEnv: []registry.RequiredEnv{{Key: "API_KEY", Secret: true}}},
+		Health: registry.Health{IntervalSec: 10, TimeoutSec: 5},
+	}}}
+	s := NewServer(reg)
+	rr := httptest.NewRecorder()
+	s.Router().ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/v1/servers/demo/env", strings.NewReader(`{"envVars":{"API_KEY":"s3cret"}}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := reg.Servers[0].Entry.Env["API_KEY"]; got != registry.VaultRef(registry.ServerEnvRef("demo"), "API_KEY") {
+		t.Fatalf("env value %q, want a vault reference", got)
+	}
+	if stored, err := s.credentialManager.vault.Retrieve(registry.ServerEnvRef("demo")); err != nil || stored["API_KEY"] != "s3cret" {
+		t.Fatalf("vault = %v, %v", stored, err)
+	}
+}
+
+func TestServerDelete(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	srvDir, _ := paths.ServersDir()
+	bin := filepath.Join(srvDir, "demo", "bin", "demo")
+	if err := paths.MkdirAll(filepath.Dir(bin)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	health := registry.Health{IntervalSec: 10, TimeoutSec: 5}
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+		{Name: "demo", Slug: "demo", Entry: registry.Entry{Transport: "stdio", Command: bin}, Health: health},
+		{Name: "GitHub", Slug: "gh", Entry: registry.Entry{Transport: "http"}, Health: health, External: &registry.ExternalInfo{Provider: "github"}},
+		{Name: "app", Slug: "app", Entry: registry.Entry{Transport: "stdio", Command: "node"}, Health: health, DependsOn: []string{"demo"}},
+	}}
+	router := NewServer(reg).Router()
+	del := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, path, nil))
+		return rr
+	}
+
+	if rr := del("/v1/servers/gh"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("external server: status %d", rr.Code)
+	}
+	if rr := del("/v1/servers/Bad_Slug"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad slug: status %d", rr.Code)
+	}
+
+	// app depends on demo: refused before anything is touched
+	rr := del("/v1/servers/demo")
+	var conflict struct{ Dependents []string }
+	if err := json.Unmarshal(rr.Body.Bytes(), &conflict); err != nil || rr.Code != http.StatusConflict || len(conflict.Dependents) != 1 || conflict.Dependents[0] != "app" {
+		t.Fatalf("dependents: status %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := os.Stat(bin); err != nil || len(reg.Servers) != 3 {
+		t.Fatalf("refused delete removed something: %v, %d servers", err, len(reg.Servers))
+	}
+
+	rr = del("/v1/servers/demo?force=true")
+	var out struct {
+		Status     string
+		Registered bool
+		Removed    []string
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rr.Code, rr.Body.String())
+	}
+	if !out.Registered || len(out.Removed) != 2 {
+		t.Fatalf("delete = %+v, want the bin dir and the server dir removed", out)
+	}
+	if len(reg.Servers) != 2 || reg.Servers[0].Slug != "gh" || len(reg.Servers[1].DependsOn) != 0 {
+		t.Fatalf("registry = %+v", reg.Servers)
+	}
+	if saved, err := registry.LoadDefault(); err != nil || len(saved.Servers) != 2 {
+		t.Fatalf("registry not saved: %v", err)
+	}
+
+	// Deleting again finds nothing left and still succeeds
+	rr = del("/v1/servers/demo")
+	out.Removed = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK || out.Registered || len(out.Removed) != 0 {
+		t.Fatalf("repeat: status %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestServerUpgradeRefusals(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	health := registry.Health{IntervalSec: 10, TimeoutSec: 5}
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+		{Name: "demo", Slug: "demo", Entry: registry.Entry{Transport: "stdio", Command: "/bin/demo"}, Health: health},
+		{Name: "GitHub", Slug: "gh", Entry: registry.Entry{Transport: "http"}, Health: health, External: &registry.ExternalInfo{Provider: "github"}},
+	}}
+	router := NewServer(reg).Router()
+
+	for path, want := range map[string]int{
+		"/v1/servers/missing/upgrade": http.StatusNotFound,
+		"/v1/servers/gh/upgrade":      http.StatusBadRequest,
+		"/v1/servers/demo/upgrade":    http.StatusBadRequest, // no install manifest
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"version":"1.2.3"}`)))
+		if rr.Code != want {
+			t.Errorf("%s: status %d, want %d: %s", path, rr.Code, want, rr.Body)
+		}
+	}
+	if reg.Servers[0].Entry.Command != "/bin/demo" {
+		t.Fatal("refused upgrade changed the entry")
+	}
+}
+
+func TestRegistryChanges(t *testing.T) {
+	prev := &registry.Registry{Servers: []registry.Server{
+		{Slug: "fs", Entry: registry.Entry{Command: "node"}},
+		{Slug: "gone"},
+		{Slug: "gh", External: &registry.ExternalInfo{Provider: "github"}},
+	}}
+	next := &registry.Registry{Servers: []registry.Server{
+		{Slug: "fs", Entry: registry.Entry{Command: "bun"}},
+		{Slug: "gh", External: &registry.ExternalInfo{Provider: "github", Status: registry.ExternalStatus{State: "error"}}},
+		{Slug: "new"},
+	}}
+	if got, want := registryChanges(prev, next), "added new; removed gone; changed fs"; got != want {
+		t.Fatalf("changes = %q, want %q", got, want)
+	}
+	if got := registryChanges(next, next); got != "" {
+		t.Fatalf("no-op reload = %q", got)
+	}
+}
+
+func TestOverview(t *testing.T) {
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+		{Name: "A", Slug: "a"},
+		{Name: "GitHub", Slug: "x", External: &registry.ExternalInfo{Provider: "github"}},
+		{Name: "B", Slug: "b"},
+	}}
+	mon := health.NewHealthMonitor(0)
+	mon.AddExternalProcess("x", "github", "https://api.github.com", "api_key", nil)
+	h := NewServer(reg).WithHealthMonitor(mon).Router()
+
+	var overview struct {
+		TotalServers int              `json:"totalServers"`
+		Servers      []map[string]any `json:"servers"`
+		States       map[string]int   `json:"states"`
+		Health       map[string]int   `json:"health"`
+		NextCursor   string           `json:"nextCursor"`
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/overview?limit=2&fields=slug,kind", nil))
+	if err := json.Unmarshal(rr.Body.Bytes(), &overview); err != nil {
+		t.Fatal(err)
+	}
+	if overview.TotalServers != 3 || len(overview.Servers) != 2 || overview.NextCursor != "2" {
+		t.Fatalf("first page = %s", rr.Body.String())
+	}
+	if s := overview.Servers[1]; len(s) != 2 || s["slug"] != "x" || s["kind"] != "external" {
+		t.Fatalf("external row = %v", s)
+	}
+	// Counts cover every server, not just the page
+	if overview.States["stopped"] != 2 {
+		t.Fatalf("states = %v", overview.States)
+	}
+	sum := 0
+	for _, n := range overview.Health {
+		sum += n
+	}
+	if sum != 3 {
+		t.Fatalf("health = %v", overview.Health)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/overview?cursor=2", nil))
+	overview.Servers, overview.NextCursor = nil, ""
+	_ = json.Unmarshal(rr.Body.Bytes(), &overview)
+	if len(overview.Servers) != 1 || overview.Servers[0]["slug"] != "b" || overview.Servers[0]["kind"] != "local" || overview.NextCursor != "" {
+		t.Fatalf("last page = %s", rr.Body.String())
+	}
+}
+
+func TestLogsFilter(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	dir, err := paths.LogsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fs.log"), []byte("info: a\nerror: b\ninfo: c\nerror: d\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := NewServer(&registry.Registry{Version: "1.0"}).Router()
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/logs/fs?level=error&tail=1", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "error: d\n" {
+		t.Fatalf("filtered tail: %d %q", rr.Code, rr.Body.String())
+	}
+
+	// A bad filter is refused before any streaming starts
+	for _, path := range []string{"/v1/logs/fs?regex=(", "/v1/logs/stream/fs?regex=("} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "invalid regex") {
+			t.Errorf("%s: %d %s", path, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestCreateProvider(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	h := NewServer(&registry.Registry{Version: "1.0"}).Router()
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/external/providers", strings.NewReader(body)))
+		return rr
+	}
+
+	body := `{"name":"statusbot","displayName":"Status Bot","authType":"api_key","healthEndpoint":"https://statusbot.test/ping",
+		"credentials":[{"key":"api_key","displayName":"API Key","required":true,"secret":true,"validation":"^sb_[0-9]+$"}]}`
+	if rr := post(strings.Replace(body, `^sb_[0-9]+$`, `^sb_[0-9+$`, 1)); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad regex: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := post(body); rr.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := post(body); rr.Code != http.StatusConflict {
+		t.Fatalf("duplicate: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/external/providers/statusbot", nil))
+	var got ExternalProviderResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || !got.Custom || got.HealthEndpoint != "https://statusbot.test/ping" {
+		t.Fatalf("provider %+v, %v", got, err)
+	}
+	path, _ := providers.CustomProvidersFile()
+	if data, err := os.ReadFile(path); err != nil || !strings.Contains(string(data), `"statusbot"`) {
+		t.Fatalf("providers.json: %s, %v", data, err)
+	}
+}
@@ -2,6 +2,8 @@ package httpapi
 
 import (
     "encoding/json"
+    "errors"
+    "io"
     "net/http"
     "os"
 
@@ -45,23 +47,31 @@ func (s *Server) handleSettingsGet(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    writeJSON(w, settings)
+    writeJSONCached(w, r, settings)
 }
 
-// handleSettingsUpdate updates application settings
+// handleSettingsUpdate replaces the application settings. Keys left out of
+// the body take their defaults.
 func (s *Server) handleSettingsUpdate(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPut && r.Method != http.MethodPost {
         w.WriteHeader(http.StatusMethodNotAllowed)
         return
     }
 
-    var newSettings settings.Settings
-    if err := json.NewDecoder(r.Body).Decode(&newSettings); err != nil {
-        http.Error(w, "invalid JSON", http.StatusBadRequest)
+    newSettings := settings.NewDefault()
+    dec := json.NewDecoder(r.Body)
+    dec.DisallowUnknownFields()
+    if err := dec.Decode(newSettings); err != nil {
+        http.Error(w, "invalid settings: "+err.Error(), http.StatusBadRequest)
         return
     }
 
-    if err := settings.UpdateCached(&newSettings); err != nil {
+    if err := settings.Validate(newSettings); err != nil {
+        writeSettingsError(w, err)
+        return
+    }
+
+    if err := settings.UpdateCached(newSettings); err != nil {
         http.Error(w, "failed to save settings", http.StatusInternalServerError)
         return
     }
@@ -69,7 +79,7 @@ func (s *Server) handleSettingsUpdate(w http.ResponseWriter, r *http.Request) {
     writeJSON(w, newSettings)
 }
 
-// handleSettingsPartial updates specific settings sections
+// handleSettingsPartial deep-merges a partial update into the current settings
 func (s *Server) handleSettingsPartial(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPatch {
         w.WriteHeader(http.StatusMethodNotAllowed)
@@ -82,58 +92,20 @@ func (s *Server) handleSettingsPartial(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // Parse partial update
-    var patch map[string]json.RawMessage
-    if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
-        http.Error(w, "invalid JSON", http.StatusBadRequest)
+    patch, err := io.ReadAll(r.Body)
+    if err != nil {
+        http.Error(w, "failed to read request", http.StatusBadRequest)
         return
     }
 
-    // Apply patches to current settings
-    updated := *currentSettings // copy current settings
-
-    for key, value := range patch {
-        switch key {
-        case "autostart":
-            var autostartSettings settings.AutostartSettings
-            if err := json.Unmarshal(value, &autostartSettings); err != nil {
-                http.Error(w, "invalid autostart settings", http.StatusBadRequest)
-                return
-            }
-            updated.Autostart = autostartSettings
-
-        case "theme":
-            var themeSettings settings.ThemeSettings
-            if err := json.Unmarshal(value, &themeSettings); err != nil {
-                http.Error(w, "invalid theme settings", http.StatusBadRequest)
-                return
-            }
-            updated.Theme = themeSettings
-
-        case "logs":
-            var logSettings settings.LogSettings
-            if err := json.Unmarshal(value, &logSettings); err != nil {
-                http.Error(w, "invalid log settings", http.StatusBadRequest)
-                return
-            }
-            updated.Logs = logSettings
-
-        case "manager":
-            var managerSettings settings.ManagerSettings
-            if err := json.Unmarshal(value, &managerSettings); err != nil {
-                http.Error(w, "invalid manager settings", http.StatusBadRequest)
-                return
-            }
-            updated.Manager = managerSettings
-
-        default:
-            http.Error(w, "unknown settings section: "+key, http.StatusBadRequest)
-            return
-        }
+    updated, err := settings.Patch(currentSettings, patch)
+    if err != nil {
+        writeSettingsError(w, err)
+        return
     }
 
     // Save updated settings
-    if err := settings.UpdateCached(&updated); err != nil {
+    if err := settings.UpdateCached(updated); err != nil {
         http.Error(w, "failed to save settings", http.StatusInternalServerError)
         return
     }
@@ -141,6 +113,18 @@ func (s *Server) handleSettingsPartial(w http.ResponseWriter, r *http.Request) {
     writeJSON(w, updated)
 }
 
+// writeSettingsError answers 400, listing the offending fields when there are any
+func writeSettingsError(w http.ResponseWriter, err error) {
+    var verr *settings.ValidationError
+    if !errors.As(err, &verr) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusBadRequest)
+    writeJSON(w, map[string]any{"error": verr.Error(), "fields": verr.Fields})
+}
+
 // handleSettingsReset resets settings to defaults
 func (s *Server) handleSettingsReset(w http.ResponseWriter, r *http.Request) {
     if r.Method != http.MethodPost {
@@ -0,0 +1,124 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"mcp/manager/internal/install"
+)
+
+// handleServerUpgrade handles POST /v1/servers/{slug}/upgrade with an
+// optional {"version": ...}, starting an install job that moves an npm or pip
+// server to that version, or the latest, in place. It answers with the job's
+// ID; its logs and outcome are read like any other install job's. A running
+// server is stopped for the upgrade and started again afterwards, also when
+// the upgrade was rolled back.
+func (s *Server) handleServerUpgrade(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "Invalid JSON"})
+		return
+	}
+
+	sv := s.findServer(slug)
+	if sv == nil {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"error": fmt.Sprintf("unknown server: %s", slug)})
+		return
+	}
+	if sv.IsExternal() {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "External servers have nothing installed to upgrade"})
+		return
+	}
+	// Refuse here rather than in the job, so nothing is stopped for it
+	if err := install.CheckUpgrade(slug, body.Version); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": err.Error()})
+		return
+	}
+
+	installService, err := s.getInstallationService()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeJSON(w, InstallJobResponse{Status: "error", Message: fmt.Sprintf("Failed to initialize installation service: %v", err)})
+		return
+	}
+	jobID, err := installService.Upgrade(context.Background(), slug, body.Version, install.UpgradeHooks{
+		Stop:  func() (func(), error) { return s.stopForUpgrade(slug) },
+		Apply: func(result *install.UpgradeResult) error { return s.applyUpgrade(slug, result) },
+	})
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, install.ErrUpgradeUnsupported) {
+			code = http.StatusBadRequest
+		}
+		w.WriteHeader(code)
+		writeJSON(w, InstallJobResponse{Status: "error", Message: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, InstallJobResponse{JobID: jobID, Status: "started"})
+}
+
+// stopForUpgrade stops slug if it is running and returns what starts it
+// again.
+func (s *Server) stopForUpgrade(slug string) (func(), error) {
+	if s.sup == nil || s.sup.GetProcessInfo(slug)["state"] != "running" {
+		return nil, nil
+	}
+	if err := s.serverAction(slug, "stop"); err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := s.serverAction(slug, "start"); err != nil {
+			log.Printf("Failed to start %s after upgrade: %v", slug, err)
+		}
+	}, nil
+}
+
+// applyUpgrade points slug's registry entry and bin script at what the
+// upgrade installed.
+func (s *Server) applyUpgrade(slug string, result *install.UpgradeResult) error {
+	if result.RolledBack {
+		log.Printf("[AUDIT] Upgrade of server %s rolled back: %s", slug, result.Error)
+		return nil
+	}
+
+	sv := s.findServer(slug)
+	if sv == nil {
+		return fmt.Errorf("server %s was removed during the upgrade", slug)
+	}
+	// Work on a copy so a failure leaves the registry as it was
+	entry := sv.Entry
+	entry.Command = result.EntryCommand
+	entry.Args = result.EntryArgs
+	entry.EntryPoint = result.EntryPoint
+	entry.EntryPoints = result.EntryPoints
+	if err := install.WriteBinScript(slug, entry); err != nil {
+		return fmt.Errorf("failed to write bin script: %w", err)
+	}
+	sv.Entry = entry
+	if err := s.saveRegistry(); err != nil {
+		return fmt.Errorf("failed to save registry: %w", err)
+	}
+	if s.sup != nil {
+		s.sup.UpdateRegistry(s.reg)
+	}
+	log.Printf("[AUDIT] Upgraded server %s from %s to %s", slug, result.OldVersion, result.NewVersion)
+	return nil
+}
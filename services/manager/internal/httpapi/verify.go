@@ -0,0 +1,26 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"mcp/manager/internal/install"
+)
+
+// handleServerVerify handles GET /v1/servers/{slug}/verify. It reports drift
+// between the registry entry and the installed files so the UI can offer a
+// reinstall when needsRepair is set.
+func (s *Server) handleServerVerify(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	sv := s.findServer(slug)
+	if sv == nil {
+		http.Error(w, fmt.Sprintf("unknown server: %s", slug), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, install.VerifyServer(*sv))
+}
@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/logs"
+	"mcp/manager/internal/registry"
+)
+
+// writeClientFrame sends a masked frame, as a browser would.
+func writeClientFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readServerFrame reads one unmasked frame of up to 64KiB.
+func readServerFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	_, err := io.ReadFull(r, payload)
+	return head[0] & 0x0F, payload, err
+}
+
+func TestLogStreamWebSocket(t *testing.T) {
+	logsDir := t.TempDir()
+	logPath := filepath.Join(logsDir, "fs.log")
+	if err := os.WriteFile(logPath, []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	streamer := logs.NewLogStreamer(logsDir)
+	streamer.Start()
+	defer streamer.Stop()
+
+	reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{Name: "fs", Slug: "fs"}}}
+	srv := httptest.NewServer(NewServer(reg).WithLogStreamer(streamer).Router())
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	_, _ = io.WriteString(conn, "GET /v1/logs/stream/fs?fromLine=0 HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The accept value for this key is the one given in RFC 6455
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	nextEntry := func() logs.LogEntry {
+		t.Helper()
+		opcode, payload, err := readServerFrame(br)
+		if err != nil || opcode != wsText {
+			t.Fatalf("frame %d: %v", opcode, err)
+		}
+		var entry logs.LogEntry
+		if err := json.Unmarshal(payload, &entry); err != nil {
+			t.Fatal(err)
+		}
+		return entry
+	}
+	if entry := nextEntry(); entry.Message != "hello" || entry.Line != 1 {
+		t.Fatalf("replayed entry %+v", entry)
+	}
+
+	// Nothing arrives while paused, even past the watcher's next check
+	writeClientFrame(t, conn, wsText, []byte(`{"action":"pause"}`))
+	time.Sleep(50 * time.Millisecond)
+	f, _ := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0)
+	_, _ = f.WriteString("world\n")
+	f.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := readServerFrame(br); err == nil || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("frame while paused: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	writeClientFrame(t, conn, wsText, []byte(`{"action":"resume"}`))
+	if entry := nextEntry(); entry.Message != "world" {
+		t.Fatalf("entry after resume %+v", entry)
+	}
+
+	writeClientFrame(t, conn, wsClose, binary.BigEndian.AppendUint16(nil, 1000))
+	if opcode, _, err := readServerFrame(br); err != nil || opcode != wsClose {
+		t.Fatalf("close: %d %v", opcode, err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); streamer.GetActiveStreams()["totalClients"] != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stream not stopped: %v", streamer.GetActiveStreams())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package install
+
+import "context"
+
+// Capabilities reports which install sources this host can use, so a client
+// can offer only those, along with every tool probed to decide it.
+type Capabilities struct {
+	Sources []SourceCapability `json:"sources"`
+	Tools   []ToolStatus       `json:"tools"`
+}
+
+// SourceCapability says whether installs of one source type can run here.
+type SourceCapability struct {
+	Type    SourceType `json:"type"`
+	Usable  bool       `json:"usable"`
+	Missing []string   `json:"missing,omitempty"` // tools it needs that were not found
+	Builds  []string   `json:"builds,omitempty"`  // for git, the project runtimes that can be set up
+}
+
+// DetectCapabilities probes the installer tools, plus the extras only some
+// installs use, and derives the usable source types from them.
+func DetectCapabilities(ctx context.Context, runner Runner) Capabilities {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	tools := DetectTools(ctx, runner)
+	found := map[string]bool{}
+	for _, tool := range tools {
+		found[tool.Name] = tool.Found
+	}
+
+	extras := []ToolStatus{
+		probeTool(ctx, runner, "bun", "bun", "--version"),
+		probeTool(ctx, runner, "uv", "uv", "--version"),
+		probeTool(ctx, runner, "pipx", "pipx", "--version"),
+		probeTool(ctx, runner, "go", "go", "version"),
+		probeTool(ctx, runner, "cargo", "cargo", "--version"),
+	}
+	if found["git"] {
+		extras = append(extras, probeTool(ctx, runner, "git-lfs", "git", "lfs", "version"))
+	} else {
+		extras = append(extras, ToolStatus{Name: "git-lfs"})
+	}
+	if found["docker"] {
+		extras = append(extras, probeTool(ctx, runner, "docker-compose", "docker", "compose", "version"))
+	} else {
+		extras = append(extras, ToolStatus{Name: "docker-compose"})
+	}
+	venv, pip := ToolStatus{Name: "python-venv"}, ToolStatus{Name: "pip"}
+	if python, ok := detectPython(ctx, runner); ok {
+		if _, _, err := runner.Run(ctx, python, "-m", "venv", "--help"); err == nil {
+			venv.Found = true
+			venv.Command = python + " -m venv"
+		}
+		pip = probeTool(ctx, runner, "pip", python, "-m", "pip", "--version")
+		if pip.Found {
+			pip.Command = python + " -m pip"
+		}
+	}
+	extras = append(extras, venv, pip)
+	for _, tool := range extras {
+		found[tool.Name] = tool.Found
+	}
+
+	// needs lists a source's prerequisites; a group of names is satisfied
+	// by any one of them
+	needs := func(t SourceType, groups ...[]string) SourceCapability {
+		c := SourceCapability{Type: t, Usable: true}
+		for _, group := range groups {
+			ok := false
+			for _, name := range group {
+				ok = ok || found[name]
+			}
+			if !ok {
+				c.Usable = false
+				c.Missing = append(c.Missing, group[0])
+			}
+		}
+		return c
+	}
+	node := []string{"node"}
+	nodeManager := []string{"npm", "yarn", "pnpm"}
+	python := []string{"python"}
+	pythonEnv := []string{"python-venv", "pipx"}
+
+	git := needs(SrcGit, []string{"git"})
+	for _, build := range []struct {
+		runtime string
+		groups  [][]string
+	}{
+		{"node", [][]string{node, nodeManager}},
+		{"python", [][]string{python, pythonEnv}},
+		{"go", [][]string{{"go"}}},
+		{"rust", [][]string{{"cargo"}}},
+		{"docker", [][]string{{"docker"}}},
+	} {
+		if needs("", build.groups...).Usable {
+			git.Builds = append(git.Builds, build.runtime)
+		}
+	}
+
+	return Capabilities{
+		Sources: []SourceCapability{
+			git,
+			needs(SrcNpm, node, nodeManager),
+			needs(SrcPip, python, pythonEnv),
+			needs(SrcDocker, []string{"docker"}),
+			needs(SrcCompose, []string{"docker-compose"}),
+			needs(SrcURL),
+		},
+		Tools: append(tools, extras...),
+	}
+}
@@ -0,0 +1,330 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mcp/manager/internal/paths"
+)
+
+// ErrDockerUnavailable is returned when the docker CLI is missing or its
+// daemon can't be reached.
+var ErrDockerUnavailable = errors.New("docker is not available")
+
+// envKeyRE matches a variable name the launcher can export.
+var envKeyRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// volumeRE matches a docker run volume mount: a host path or named volume, a
+// container path and optional ro/rw mode.
+var volumeRE = regexp.MustCompile(`^[^:]+:/[^:]*(:(ro|rw))?$`)
+
+// DockerInstaller handles installations that run the MCP server in a
+// container, from a prebuilt image or one built from a Dockerfile.
+type DockerInstaller struct {
+	runner Runner
+	logger Logger
+}
+
+// NewDockerInstaller creates a new docker installer instance
+func NewDockerInstaller(runner Runner, logger Logger) *DockerInstaller {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	return &DockerInstaller{
+		runner: runner,
+		logger: logger,
+	}
+}
+
+// DockerInstallOptions contains configuration for docker-based installations.
+// Exactly one of Image and Context is set.
+type DockerInstallOptions struct {
+	Image       string            `json:"image,omitempty"`       // prebuilt image to pull (e.g., "ghcr.io/org/server:1.0")
+	Context     string            `json:"context,omitempty"`     // build context: a directory or anything docker build accepts, such as a git URL
+	Dockerfile  string            `json:"dockerfile,omitempty"`  // Dockerfile path within the context (default: Dockerfile)
+	BuildArgs   map[string]string `json:"buildArgs,omitempty"`   // --build-arg values
+	Transport   string            `json:"transport,omitempty"`   // stdio (default) or http
+	Port        int               `json:"port,omitempty"`        // container port the server listens on, for http transport
+	HostPort    int               `json:"hostPort,omitempty"`    // loopback port it is published on (default: Port)
+	Volumes     []string          `json:"volumes,omitempty"`     // mounts as "source:/container/path[:ro]"
+	Environment map[string]string `json:"environment,omitempty"` // environment variables passed into the container
+	PassEnv     []string          `json:"passEnv,omitempty"`     // further variables passed in from the server's env when set, e.g. required secrets
+}
+
+// DockerInstallResult contains the result of a docker installation
+type DockerInstallResult struct {
+	Success      bool              `json:"success"`
+	InstallPath  string            `json:"installPath"`
+	BinPath      string            `json:"binPath"`
+	Image        string            `json:"image"`   // the pulled image or build context
+	Tag          string            `json:"tag"`     // mcp-<slug>, the tag the launcher runs
+	ImageID      string            `json:"imageId"` // image ID behind the tag
+	Transport    string            `json:"transport"`
+	HostPort     int               `json:"hostPort,omitempty"`
+	EntryCommand string            `json:"entryCommand"`
+	EntryArgs    []string          `json:"entryArgs"`
+	Environment  map[string]string `json:"environment"`
+	Logs         []string          `json:"logs"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// DockerTag is the image tag a docker install of slug is run from.
+func DockerTag(slug string) string {
+	return "mcp-" + slug
+}
+
+// Install pulls or builds the image, tags it mcp-<slug> and writes a launcher
+// that runs it with docker run. A stdio server keeps stdin attached; an http
+// server has its port published on 127.0.0.1.
+func (d *DockerInstaller) Install(ctx context.Context, slug string, options DockerInstallOptions) (*DockerInstallResult, error) {
+	result := &DockerInstallResult{
+		Tag:         DockerTag(slug),
+		Environment: make(map[string]string),
+	}
+
+	if err := validateDockerOptions(&options); err != nil {
+		return result, err
+	}
+	result.Transport = options.Transport
+	result.HostPort = options.HostPort
+
+	baseServers, err := paths.ServersDir()
+	if err != nil {
+		return result, fmt.Errorf("failed to get servers directory: %w", err)
+	}
+
+	serverDir := filepath.Join(baseServers, slug)
+	installDir := filepath.Join(serverDir, "install")
+	binDir := filepath.Join(serverDir, "bin")
+
+	result.InstallPath = installDir
+	result.BinPath = binDir
+
+	for _, dir := range []string{serverDir, installDir, binDir} {
+		if err := paths.MkdirAll(dir); err != nil {
+			return result, fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	logf(d.logger, "Starting docker installation for %s", slug)
+
+	if err := d.checkDaemon(ctx); err != nil {
+		return result, err
+	}
+
+	if options.Image != "" {
+		result.Image = options.Image
+		if err := d.pullImage(ctx, options.Image, result.Tag); err != nil {
+			result.Error = fmt.Sprintf("Image pull failed: %v", err)
+			logf(d.logger, result.Error)
+			return result, nil
+		}
+	} else {
+		result.Image = options.Context
+		if err := d.buildImage(ctx, options, result.Tag); err != nil {
+			result.Error = fmt.Sprintf("Image build failed: %v", err)
+			logf(d.logger, result.Error)
+			return result, nil
+		}
+	}
+
+	if id, _, err := d.runner.Run(ctx, "docker", "image", "inspect", "--format", "{{.Id}}", result.Tag); err == nil {
+		result.ImageID = strings.TrimSpace(id)
+	}
+
+	for k, v := range options.Environment {
+		result.Environment[k] = v
+	}
+	result.EntryCommand = filepath.Join(binDir, slug)
+	if err := d.createBinScript(result.EntryCommand, result.Tag, slug, options); err != nil {
+		result.Error = fmt.Sprintf("Failed to create bin script: %v", err)
+		logf(d.logger, result.Error)
+		return result, nil
+	}
+
+	result.Success = true
+	logf(d.logger, "Docker installation completed successfully for %s (%s)", slug, result.Tag)
+	return result, nil
+}
+
+// validateDockerOptions checks options and fills in the defaults.
+func validateDockerOptions(options *DockerInstallOptions) error {
+	switch {
+	case options.Image == "" && options.Context == "":
+		return fmt.Errorf("an image to pull or a build context is required")
+	case options.Image != "" && options.Context != "":
+		return fmt.Errorf("image and context are mutually exclusive")
+	case options.Image != "" && (options.Dockerfile != "" || len(options.BuildArgs) > 0):
+		return fmt.Errorf("dockerfile and buildArgs only apply to a build context")
+	}
+
+	switch options.Transport {
+	case "", "stdio":
+		options.Transport = "stdio"
+		if options.Port != 0 || options.HostPort != 0 {
+			return fmt.Errorf("port only applies to http transport")
+		}
+	case "http":
+		if options.Port < 1 || options.Port > 65535 {
+			return fmt.Errorf("http transport needs the container port (1-65535)")
+		}
+		if options.HostPort == 0 {
+			options.HostPort = options.Port
+		}
+		if options.HostPort < 1 || options.HostPort > 65535 {
+			return fmt.Errorf("invalid hostPort %d", options.HostPort)
+		}
+	default:
+		return fmt.Errorf("unsupported transport %q: use stdio or http", options.Transport)
+	}
+
+	for _, v := range options.Volumes {
+		if !volumeRE.MatchString(v) {
+			return fmt.Errorf("invalid volume %q: use source:/container/path[:ro]", v)
+		}
+	}
+	for k := range options.Environment {
+		if !envKeyRE.MatchString(k) {
+			return fmt.Errorf("invalid environment variable name %q", k)
+		}
+	}
+	for _, k := range options.PassEnv {
+		if !envKeyRE.MatchString(k) {
+			return fmt.Errorf("invalid passEnv name %q", k)
+		}
+	}
+	return nil
+}
+
+// checkDaemon makes sure the docker CLI is installed and its daemon answers,
+// so a stopped Docker Desktop fails the install up front with a clear reason.
+func (d *DockerInstaller) checkDaemon(ctx context.Context) error {
+	logf(d.logger, "Checking docker daemon...")
+	version, stderr, err := d.runner.Run(ctx, "docker", "version", "--format", "{{.Server.Version}}")
+	if err == nil {
+		logf(d.logger, "Docker daemon %s", strings.TrimSpace(version))
+		return nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%w: the docker CLI is not installed", ErrDockerUnavailable)
+	}
+	reason := strings.TrimSpace(stderr)
+	if reason == "" {
+		reason = err.Error()
+	}
+	return fmt.Errorf("%w: the docker daemon is not reachable (is Docker running?): %s", ErrDockerUnavailable, reason)
+}
+
+// pullImage pulls image and tags it as tag.
+func (d *DockerInstaller) pullImage(ctx context.Context, image, tag string) error {
+	logf(d.logger, "Pulling %s...", image)
+	if _, stderr, err := d.runner.Run(ctx, "docker", "pull", image); err != nil {
+		return fmt.Errorf("docker pull: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	if _, stderr, err := d.runner.Run(ctx, "docker", "tag", image, tag); err != nil {
+		return fmt.Errorf("docker tag: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// buildImage builds the image from the options' context and tags it as tag.
+func (d *DockerInstaller) buildImage(ctx context.Context, options DockerInstallOptions, tag string) error {
+	logf(d.logger, "Building %s from %s...", tag, options.Context)
+	args := []string{"build", "--progress=plain", "-t", tag}
+	if options.Dockerfile != "" {
+		args = append(args, "-f", options.Dockerfile)
+	}
+	for _, k := range sortedKeys(options.BuildArgs) {
+		args = append(args, "--build-arg", k+"="+options.BuildArgs[k])
+	}
+	args = append(args, options.Context)
+
+	if _, stderr, err := runWithProgress(ctx, d.runner, d.logger, dockerBuildProgress, "docker", args...); err != nil {
+		return fmt.Errorf("docker build: %w: %s", err, lastLines(stderr, 10))
+	}
+	return nil
+}
+
+// dockerRunArgs returns the docker run arguments for the image tag. Env values
+// stay out of the arguments: each variable is passed by name, so values the
+// supervisor sets, vault ones included, reach the container.
+func dockerRunArgs(tag, slug string, options DockerInstallOptions) []string {
+	args := []string{"run", "--rm", "--label", "mcp.slug=" + slug}
+	if options.Transport == "http" {
+		args = append(args, "-p", fmt.Sprintf("127.0.0.1:%d:%d", options.HostPort, options.Port))
+	} else {
+		// stdin stays open for the JSON-RPC stream; no TTY, which would mangle it
+		args = append(args, "-i")
+	}
+	for _, v := range options.Volumes {
+		args = append(args, "-v", v)
+	}
+	for _, k := range sortedKeys(options.Environment) {
+		args = append(args, "-e", k)
+	}
+	for _, k := range options.PassEnv {
+		if _, ok := options.Environment[k]; !ok {
+			args = append(args, "-e", k)
+		}
+	}
+	return append(args, tag)
+}
+
+// createBinScript writes the launcher that runs the container. Arguments to
+// the launcher are passed on to the container's entry point.
+func (d *DockerInstaller) createBinScript(scriptPath, tag, slug string, options DockerInstallOptions) error {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString("# Generated MCP server launcher\n\n")
+
+	for _, k := range sortedKeys(options.Environment) {
+		script.WriteString(fmt.Sprintf("export %s=%s\n", k, shellQuote(options.Environment[k])))
+	}
+
+	script.WriteString("exec docker")
+	for _, arg := range dockerRunArgs(tag, slug, options) {
+		script.WriteString(" " + shellQuote(arg))
+	}
+	script.WriteString(" \"$@\"\n")
+
+	if err := paths.WriteFile(scriptPath, []byte(script.String()), paths.ExecMode()); err != nil {
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sortedKeys returns the keys of m in order, so generated commands are stable.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lastLines returns the last n lines of s, where a failed build explains itself.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dockerHTTPURL is the URL the health monitor checks for an http server.
+func dockerHTTPURL(hostPort int) string {
+	return "http://127.0.0.1:" + strconv.Itoa(hostPort)
+}
@@ -0,0 +1,105 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDockerInstallBuildsAndWritesLauncher(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	var commands []string
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		if len(args) > 1 && args[0] == "image" && args[1] == "inspect" {
+			return "sha256:abc\n", "", nil
+		}
+		return "", "", nil
+	}}
+	d := NewDockerInstaller(runner, testLogger{t})
+
+	result, err := d.Install(context.Background(), "weather", DockerInstallOptions{
+		Context:     "/src/weather",
+		BuildArgs:   map[string]string{"VERSION": "1.2"},
+		Transport:   "http",
+		Port:        8080,
+		HostPort:    18080,
+		Volumes:     []string{"/data:/data:ro"},
+		Environment: map[string]string{"MODE": "it's"},
+		PassEnv:     []string{"API_TOKEN"},
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("install: %v %+v", err, result)
+	}
+	if result.Tag != "mcp-weather" || result.ImageID != "sha256:abc" {
+		t.Fatalf("tag %q, image %q", result.Tag, result.ImageID)
+	}
+	if want := "docker build --progress=plain -t mcp-weather --build-arg VERSION=1.2 /src/weather"; commands[1] != want {
+		t.Fatalf("build command %q, want %q", commands[1], want)
+	}
+
+	script, err := os.ReadFile(result.EntryCommand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`export MODE='it'\''s'`,
+		`exec docker 'run' '--rm' '--label' 'mcp.slug=weather' '-p' '127.0.0.1:18080:8080' '-v' '/data:/data:ro' '-e' 'MODE' '-e' 'API_TOKEN' 'mcp-weather' "$@"`,
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Fatalf("launcher lacks %q:\n%s", want, script)
+		}
+	}
+}
+
+func TestDockerInstallPullsForStdio(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	var commands []string
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "", "", nil
+	}}
+	result, err := NewDockerInstaller(runner, testLogger{t}).Install(context.Background(), "files", DockerInstallOptions{Image: "ghcr.io/org/files:1"})
+	if err != nil || !result.Success {
+		t.Fatalf("install: %v %+v", err, result)
+	}
+	if commands[1] != "docker pull ghcr.io/org/files:1" || commands[2] != "docker tag ghcr.io/org/files:1 mcp-files" {
+		t.Fatalf("commands %q", commands)
+	}
+	script, _ := os.ReadFile(result.EntryCommand)
+	if !strings.Contains(string(script), `'--rm' '--label' 'mcp.slug=files' '-i' 'mcp-files'`) {
+		t.Fatalf("stdio launcher does not keep stdin attached:\n%s", script)
+	}
+}
+
+func TestDockerInstallNeedsDaemon(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		return "", "Cannot connect to the Docker daemon at unix:///var/run/docker.sock.", errors.New("exit status 1")
+	}}
+	_, err := NewDockerInstaller(runner, testLogger{t}).Install(context.Background(), "files", DockerInstallOptions{Image: "files"})
+	if !errors.Is(err, ErrDockerUnavailable) || !strings.Contains(err.Error(), "Cannot connect to the Docker daemon") {
+		t.Fatalf("err = %v", err)
+	}
+
+	for _, bad := range []DockerInstallOptions{
+		{},
+		{Image: "a", Context: "."},
+		{Image: "a", Transport: "http"},
+		{Image: "a", Port: 80},
+		{Image: "a", Volumes: []string{"data"}},
+		{Image: "a", PassEnv: []string{"BAD-NAME"}},
+	} {
+		if err := validateDockerOptions(&bad); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
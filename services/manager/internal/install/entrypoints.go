@@ -0,0 +1,74 @@
+package install
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
+)
+
+// pickEntryPoint returns the candidate called name, or the first candidate
+// when name is empty.
+func pickEntryPoint(candidates []registry.EntryPoint, name string) (registry.EntryPoint, error) {
+	if name == "" {
+		if len(candidates) == 0 {
+			return registry.EntryPoint{}, fmt.Errorf("no entry points found")
+		}
+		return candidates[0], nil
+	}
+	names := make([]string, 0, len(candidates))
+	for _, ep := range candidates {
+		if ep.Name == name {
+			return ep, nil
+		}
+		names = append(names, ep.Name)
+	}
+	return registry.EntryPoint{}, fmt.Errorf("entry point %q not found, available: %s", name, strings.Join(names, ", "))
+}
+
+// addEntryPoint appends ep unless a candidate with its name is already listed.
+func addEntryPoint(candidates []registry.EntryPoint, ep registry.EntryPoint) []registry.EntryPoint {
+	for _, c := range candidates {
+		if c.Name == ep.Name {
+			return candidates
+		}
+	}
+	return append(candidates, ep)
+}
+
+// entryPointName returns the name of the candidate that runs command with
+// args, or "" when the entry came from elsewhere, e.g. an MCP config.
+func entryPointName(candidates []registry.EntryPoint, command string, args []string) string {
+	for _, ep := range candidates {
+		if ep.Command == command && slices.Equal(ep.Args, args) {
+			return ep.Name
+		}
+	}
+	return ""
+}
+
+// WriteBinScript regenerates the launcher in the server's bin directory for
+// entry. Secret and user-provided env are left out: the supervisor injects
+// them at start, and the script must not hold them in plain text.
+func WriteBinScript(slug string, entry registry.Entry) error {
+	baseServers, err := paths.ServersDir()
+	if err != nil {
+		return fmt.Errorf("failed to get servers directory: %w", err)
+	}
+	binDir := filepath.Join(baseServers, slug, "bin")
+	if err := paths.MkdirAll(binDir); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", binDir, err)
+	}
+
+	env := make(map[string]string, len(entry.Env))
+	for k, v := range entry.Env {
+		if _, _, ok := registry.ParseVaultRef(v); ok || slices.ContainsFunc(entry.RequiredEnv, func(r registry.RequiredEnv) bool { return r.Key == k }) {
+			continue
+		}
+		env[k] = v
+	}
+	return (&NPMInstaller{}).createBinScript(binDir, slug, entry.Command, entry.Args, env)
+}
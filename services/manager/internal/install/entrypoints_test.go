@@ -0,0 +1,81 @@
+package install
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestNPMEntryPoints(t *testing.T) {
+	runtimeDir := t.TempDir()
+	binDir := filepath.Join(runtimeDir, "node_modules", ".bin")
+	pkgDir := filepath.Join(runtimeDir, "node_modules", "@acme", "demo")
+	os.MkdirAll(binDir, 0o755)
+	os.MkdirAll(pkgDir, 0o755)
+	for _, name := range []string{"demo-cli", "demo"} {
+		os.WriteFile(filepath.Join(binDir, name), nil, 0o755)
+	}
+	os.WriteFile(filepath.Join(pkgDir, "index.js"), nil, 0o644)
+
+	n := NewNPMInstaller(mockRunner{}, testLogger{t})
+	options := NPMInstallOptions{Package: "@acme/demo"}
+	info := &NPMPackageInfo{Bin: map[string]string{"demo-cli": "cli.js", "demo": "server.js", "gone": "x.js"}}
+	candidates := n.entryPoints(options, info, runtimeDir, "node")
+
+	var names []string
+	for _, ep := range candidates {
+		names = append(names, ep.Name)
+	}
+	// The bin named after the package comes first; missing bins are skipped
+	if got := strings.Join(names, ","); got != "demo,demo-cli,index.js" {
+		t.Fatalf("entry points = %s", got)
+	}
+
+	options.EntryPointName = "demo-cli"
+	cmd, _, _, err := n.determineEntryPoint(options, candidates, runtimeDir, "node")
+	if err != nil || cmd != filepath.Join(binDir, "demo-cli") {
+		t.Fatalf("determineEntryPoint = %q, %v", cmd, err)
+	}
+	if got := entryPointName(candidates, cmd, nil); got != "demo-cli" {
+		t.Fatalf("entryPointName = %q", got)
+	}
+
+	options.EntryPointName = "nope"
+	if _, _, _, err := n.determineEntryPoint(options, candidates, runtimeDir, "node"); err == nil || !strings.Contains(err.Error(), "demo-cli") {
+		t.Fatalf("expected an error listing the entry points, got %v", err)
+	}
+}
+
+func TestWriteBinScriptOmitsSecrets(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", root)
+
+	entry := registry.Entry{
+		Command: "/srv/demo/run",
+		Args:    []string{"--stdio"},
+		Env: map[string]string{
+			"PATH":      "/srv/demo/bin",
+			"API_TOKEN": registry.VaultRef("env:demo", "API_TOKEN"),
+			"API_URL":   "https://api.example.com",
+		},
+		RequiredEnv: []registry.RequiredEnv{{Key: "API_URL"}, {Key: "API_TOKEN", Secret: true}},
+	}
+	if err := WriteBinScript("demo", entry); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "servers", "demo", "bin", "demo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := string(data)
+	if !strings.Contains(script, `exec "/srv/demo/run" "--stdio"`) || !strings.Contains(script, "PATH=") {
+		t.Fatalf("script:\n%s", script)
+	}
+	if strings.Contains(script, "API_") {
+		t.Fatalf("user env written to the script:\n%s", script)
+	}
+}
@@ -46,6 +46,7 @@ type GitInstallOptions struct {
 	PostInstall   []string          `json:"postInstall,omitempty"`   // commands to run after clone
 	Environment   map[string]string `json:"environment,omitempty"`   // environment variables for commands
 	SkipDepsCheck bool              `json:"skipDepsCheck,omitempty"` // skip dependency detection and installation
+	Checksum      *Checksum         `json:"checksum,omitempty"`      // fail unless the checkout is this commit
 }
 
 // GitInstallResult contains the result of a git installation
@@ -86,7 +87,7 @@ func (g *GitInstaller) Install(ctx context.Context, slug string, options GitInst
 
 	// Ensure directories exist
 	for _, dir := range []string{serverDir, installDir, runtimeDir, binDir} {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
+		if err := paths.MkdirAll(dir); err != nil {
 			return result, fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
@@ -108,6 +109,18 @@ func (g *GitInstaller) Install(ctx context.Context, slug string, options GitInst
 		return result, nil
 	}
 
+	// Nothing from the checkout runs before it is verified
+	if options.Checksum != nil {
+		if err := g.verifyCommit(ctx, options, installDir); err != nil {
+			result.Error = fmt.Sprintf("Checksum verification failed: %v", err)
+			logf(g.logger, result.Error)
+			return result, nil
+		}
+	}
+
+	// Dependencies and post-install commands make up the installing stage
+	enterStage(g.logger, StageInstalling)
+
 	// Detect runtime and dependencies
 	if !options.SkipDepsCheck {
 		runtime, manager, err := g.detectRuntime(installDir)
@@ -137,6 +150,7 @@ func (g *GitInstaller) Install(ctx context.Context, slug string, options GitInst
 	}
 
 	// Detect entry point
+	enterStage(g.logger, StageConfiguring)
 	entryCmd, entryArgs, env, err := g.detectEntryPoint(installDir, result.DetectedRuntime)
 	if err != nil {
 		logf(g.logger, "Warning: Entry point detection failed: %v", err)
@@ -196,7 +210,8 @@ func (g *GitInstaller) validateRepository(ctx context.Context, options GitInstal
 func (g *GitInstaller) cloneRepository(ctx context.Context, options GitInstallOptions, installDir string) error {
 	logf(g.logger, "Cloning repository...")
 	
-	args := []string{"clone"}
+	// --progress keeps git printing transfer progress when stderr is not a terminal
+	args := []string{"clone", "--progress"}
 	
 	// Add depth for shallow clone
 	depth := options.Depth
@@ -236,7 +251,7 @@ func (g *GitInstaller) cloneRepository(ctx context.Context, options GitInstallOp
 	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Env = env
 	
-	if _, _, err := g.runCommand(ctx, cmd); err != nil {
+	if _, _, err := runWithProgress(ctx, g.commandRunner(), g.logger, gitCloneProgress, cmd.Path, cmd.Args[1:]...); err != nil {
 		return fmt.Errorf("git clone failed: %w", err)
 	}
 	
@@ -252,6 +267,32 @@ func (g *GitInstaller) cloneRepository(ctx context.Context, options GitInstallOp
 	return nil
 }
 
+// verifyCommit checks that HEAD in installDir is the commit options.Checksum
+// names, or options.Commit when the checksum leaves it out. Abbreviated ids
+// of at least 7 characters match as prefixes.
+func (g *GitInstaller) verifyCommit(ctx context.Context, options GitInstallOptions, installDir string) error {
+	expected := strings.ToLower(strings.TrimSpace(options.Checksum.Value))
+	if expected == "" {
+		expected = strings.ToLower(options.Commit)
+	}
+	if !commitIDRE.MatchString(expected) {
+		return fmt.Errorf("a commit id of at least 7 hex digits is required, got %q", expected)
+	}
+
+	stdout, stderr, err := g.commandRunner().Run(ctx, "git", "-C", installDir, "rev-parse", "HEAD")
+	if err != nil {
+		return fmt.Errorf("git rev-parse failed: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	head := strings.TrimSpace(stdout)
+	if !strings.HasPrefix(head, expected) {
+		return fmt.Errorf("%w: expected commit %s, checked out %s", ErrChecksumMismatch, expected, head)
+	}
+	logf(g.logger, "Verified commit %s", head)
+	return nil
+}
+
+var commitIDRE = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
 // detectRuntime analyzes the repository to determine the runtime environment
 func (g *GitInstaller) detectRuntime(installDir string) (runtime, manager string, err error) {
 	// Check for Node.js
@@ -331,6 +372,10 @@ func (g *GitInstaller) installDependencies(ctx context.Context, installDir, runt
 func (g *GitInstaller) installNodeDependencies(ctx context.Context, installDir, runtimeDir, manager string, options GitInstallOptions) error {
 	logf(g.logger, "Installing Node.js dependencies with %s...", manager)
 	
+	if err := preflightToolchain(ctx, g.runner, installDir, false); err != nil {
+		return err
+	}
+	
 	var cmd *exec.Cmd
 	switch manager {
 	case "npm":
@@ -357,7 +402,10 @@ func (g *GitInstaller) installNodeDependencies(ctx context.Context, installDir,
 	}
 	cmd.Env = env
 	
-	if _, _, err := g.runCommand(ctx, cmd); err != nil {
+	if stdout, stderr, err := g.runCommand(ctx, cmd); err != nil {
+		if terr := toolchainFailure(stdout + stderr); terr != nil {
+			return terr
+		}
 		return fmt.Errorf("failed to install Node.js dependencies: %w", err)
 	}
 	
@@ -368,6 +416,10 @@ func (g *GitInstaller) installNodeDependencies(ctx context.Context, installDir,
 func (g *GitInstaller) installPythonDependencies(ctx context.Context, installDir, runtimeDir, manager string, options GitInstallOptions) error {
 	logf(g.logger, "Installing Python dependencies with %s...", manager)
 	
+	if err := preflightToolchain(ctx, g.runner, installDir, true); err != nil {
+		return err
+	}
+	
 	// Create virtual environment
 	venvDir := filepath.Join(runtimeDir, "venv")
 	cmd := exec.CommandContext(ctx, "python3", "-m", "venv", venvDir)
@@ -420,7 +472,10 @@ func (g *GitInstaller) installPythonDependencies(ctx context.Context, installDir
 	}
 	installCmd.Env = env
 	
-	if _, _, err := g.runCommand(ctx, installCmd); err != nil {
+	if stdout, stderr, err := g.runCommand(ctx, installCmd); err != nil {
+		if terr := toolchainFailure(stdout + stderr); terr != nil {
+			return terr
+		}
 		return fmt.Errorf("failed to install Python dependencies: %w", err)
 	}
 	
@@ -685,7 +740,7 @@ func (g *GitInstaller) createBinScript(binDir, slug, command string, args []stri
 		script.WriteString("exit 1\n")
 	}
 	
-	if err := os.WriteFile(scriptPath, []byte(script.String()), 0o755); err != nil {
+	if err := paths.WriteFile(scriptPath, []byte(script.String()), paths.ExecMode()); err != nil {
 		return fmt.Errorf("failed to write script: %w", err)
 	}
 	
@@ -721,7 +776,7 @@ func (g *GitInstaller) copyFile(src, dst string) error {
 	}
 	defer sourceFile.Close()
 	
-	destFile, err := os.Create(dst)
+	destFile, err := paths.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, paths.FileMode())
 	if err != nil {
 		return err
 	}
@@ -742,10 +797,13 @@ func isExecutable(path string) bool {
 
 // runCommand executes a command and logs output
 func (g *GitInstaller) runCommand(ctx context.Context, cmd *exec.Cmd) (stdout, stderr string, err error) {
+	return g.commandRunner().Run(ctx, cmd.Path, cmd.Args[1:]...)
+}
+
+// commandRunner returns the configured runner, falling back to direct execution
+func (g *GitInstaller) commandRunner() Runner {
 	if g.runner != nil {
-		return g.runner.Run(ctx, cmd.Path, cmd.Args[1:]...)
+		return g.runner
 	}
-	
-	// Fallback to direct execution
-	return ExecRunner{}.Run(ctx, cmd.Path, cmd.Args[1:]...)
+	return ExecRunner{}
 }
\ No newline at end of file
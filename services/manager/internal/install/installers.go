@@ -3,7 +3,10 @@ package install
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
+
+	"mcp/manager/internal/registry"
 )
 
 // ConcreteGitInstaller implements the Installer interface for Git-based installations
@@ -31,6 +34,10 @@ func (cgi *ConcreteGitInstaller) Install(ctx context.Context, job *InstallationJ
 	job.Logf(LogLevelInfo, StageValidation, "Validating git repository: %s", cgi.options.URI)
 	job.UpdateStage(StageValidation, 50)
 	
+	// Clone progress fills the downloading stage
+	job.UpdateStage(StageDownloading, 0)
+	logger.SetStage(StageDownloading)
+	
 	// The GitInstaller will handle the actual installation
 	result, err := cgi.gitInstaller.Install(ctx, job.Slug, cgi.options)
 	if err != nil {
@@ -60,6 +67,137 @@ func (cgi *ConcreteGitInstaller) Install(ctx context.Context, job *InstallationJ
 		},
 	}
 	
+	if installResult.Success {
+		installResult.ServerEntry = NewServerEntry(job.Slug, installResult, job.Type, job.URI)
+	}
+	
+	return installResult, nil
+}
+
+// ConcreteURLInstaller implements the Installer interface for archive URL installations
+type ConcreteURLInstaller struct {
+	urlInstaller *URLInstaller
+	options      URLInstallOptions
+}
+
+// NewConcreteURLInstaller creates a new concrete URL installer
+func NewConcreteURLInstaller(options URLInstallOptions) *ConcreteURLInstaller {
+	return &ConcreteURLInstaller{
+		options: options,
+	}
+}
+
+// Install implements the Installer interface for URL installations
+func (cui *ConcreteURLInstaller) Install(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+	logger := NewInstallationJobLogger(job, LogLevelInfo, StageValidation)
+	cui.urlInstaller = NewURLInstaller(ExecRunner{}, logger)
+	
+	job.UpdateStage(StageValidation, 0)
+	logger.SetStage(StageValidation)
+	
+	job.Logf(LogLevelInfo, StageValidation, "Validating archive URL: %s", cui.options.URI)
+	job.UpdateStage(StageValidation, 50)
+	
+	result, err := cui.urlInstaller.Install(ctx, job.Slug, cui.options)
+	if err != nil {
+		return nil, fmt.Errorf("url installation failed: %w", err)
+	}
+	
+	job.UpdateStage(StageCompleted, 100)
+	
+	// The URL and digest are kept so an upgrade can re-fetch the archive and
+	// tell whether it changed
+	installResult := &InstallationResult{
+		Success:          result.Success,
+		InstallPath:      result.InstallPath,
+		RuntimePath:      result.RuntimePath,
+		BinPath:          result.BinPath,
+		EntryCommand:     result.EntryCommand,
+		EntryArgs:        result.EntryArgs,
+		Environment:      result.Environment,
+		Runtime:          result.DetectedRuntime,
+		PackageManager:   result.DetectedManager,
+		InstalledVersion: "sha256:" + result.SHA256,
+		Metadata: map[string]interface{}{
+			"installTime":   time.Now(),
+			"sourceURL":     result.SourceURL,
+			"sha256":        result.SHA256,
+			"archiveFormat": result.Format,
+			"hasVenv":       false,
+		},
+	}
+	
+	if installResult.Success {
+		installResult.ServerEntry = NewServerEntry(job.Slug, installResult, job.Type, job.URI)
+	}
+	
+	return installResult, nil
+}
+
+// ConcreteDockerInstaller implements the Installer interface for container installations
+type ConcreteDockerInstaller struct {
+	dockerInstaller *DockerInstaller
+	options         DockerInstallOptions
+}
+
+// NewConcreteDockerInstaller creates a new concrete docker installer
+func NewConcreteDockerInstaller(options DockerInstallOptions) *ConcreteDockerInstaller {
+	return &ConcreteDockerInstaller{
+		options: options,
+	}
+}
+
+// Install implements the Installer interface for docker installations
+func (cdi *ConcreteDockerInstaller) Install(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+	logger := NewInstallationJobLogger(job, LogLevelInfo, StageValidation)
+	cdi.dockerInstaller = NewDockerInstaller(ExecRunner{}, logger)
+	
+	job.UpdateStage(StageValidation, 0)
+	logger.SetStage(StageValidation)
+	
+	// Build steps fill the installing stage
+	job.UpdateStage(StageInstalling, 0)
+	logger.SetStage(StageInstalling)
+	
+	result, err := cdi.dockerInstaller.Install(ctx, job.Slug, cdi.options)
+	if err != nil {
+		return nil, fmt.Errorf("docker installation failed: %w", err)
+	}
+	
+	job.UpdateStage(StageCompleted, 100)
+	
+	// The image ID tells an upgrade whether a pull or rebuild changed anything
+	installResult := &InstallationResult{
+		Success:          result.Success,
+		InstallPath:      result.InstallPath,
+		BinPath:          result.BinPath,
+		EntryCommand:     result.EntryCommand,
+		EntryArgs:        result.EntryArgs,
+		Environment:      result.Environment,
+		Runtime:          "docker",
+		PackageManager:   "docker",
+		InstalledVersion: result.ImageID,
+		Metadata: map[string]interface{}{
+			"installTime": time.Now(),
+			"image":       result.Image,
+			"tag":         result.Tag,
+			"imageId":     result.ImageID,
+			"transport":   result.Transport,
+			"hasVenv":     false,
+		},
+	}
+	
+	if installResult.Success {
+		installResult.ServerEntry = NewServerEntry(job.Slug, installResult, job.Type, job.URI)
+		if result.Transport == "http" {
+			installResult.ServerEntry.Entry.Transport = "http"
+			if installResult.ServerEntry.Entry.Env == nil {
+				installResult.ServerEntry.Entry.Env = map[string]string{}
+			}
+			installResult.ServerEntry.Entry.Env["HEALTH_HTTP_URL"] = dockerHTTPURL(result.HostPort)
+		}
+	}
+	
 	return installResult, nil
 }
 
@@ -120,16 +258,23 @@ func (cni *ConcreteNPMInstaller) Install(ctx context.Context, job *InstallationJ
 		Environment:      result.Environment,
 		Runtime:          "node",
 		PackageManager:   result.PackageManager,
+		RuntimeMode:      result.RuntimeMode,
 		InstalledVersion: result.InstalledVersion,
+		EntryPoints:      result.EntryPoints,
 		Metadata: map[string]interface{}{
 			"installTime":    time.Now(),
 			"npmPackage":     cni.options.Package,
 			"packageManager": result.PackageManager,
+			"nodePath":       result.NodePath,
 			"hasVenv":        false,
 			"packageInfo":    result.PackageInfo,
 		},
 	}
 	
+	if installResult.Success {
+		installResult.ServerEntry = NewServerEntry(job.Slug, installResult, job.Type, job.URI)
+	}
+	
 	return installResult, nil
 }
 
@@ -191,17 +336,25 @@ func (cpi *ConcretePipInstaller) Install(ctx context.Context, job *InstallationJ
 		Environment:      result.Environment,
 		Runtime:          "python",
 		PackageManager:   "pip",
+		RuntimeMode:      result.RuntimeMode,
 		InstalledVersion: result.InstalledVersion,
+		EntryPoints:      result.EntryPoints,
 		Metadata: map[string]interface{}{
 			"installTime":    time.Now(),
 			"pipPackage":     cpi.options.Package,
 			"pythonPath":     result.PythonPath,
+			"interpreter":    result.Interpreter,
 			"venvPath":       result.VenvPath,
 			"hasVenv":        result.VenvPath != "",
+			"usePipx":        cpi.options.UsePipx,
 			"packageInfo":    result.PackageInfo,
 		},
 	}
 	
+	if installResult.Success {
+		installResult.ServerEntry = NewServerEntry(job.Slug, installResult, job.Type, job.URI)
+	}
+	
 	return installResult, nil
 }
 
@@ -263,6 +416,60 @@ func (ais *AdvancedInstallationService) InstallFromPip(ctx context.Context, slug
 	return job.ID, nil
 }
 
+// InstallFromURL starts an installation from an archive URL
+func (ais *AdvancedInstallationService) InstallFromURL(ctx context.Context, slug, uri string, options URLInstallOptions) (string, error) {
+	options.URI = uri
+	installer := NewConcreteURLInstaller(options)
+	job := ais.jobManager.CreateJob(slug, SrcURL, uri, installer)
+	
+	if err := ais.jobManager.StartJob(job.ID); err != nil {
+		return "", fmt.Errorf("failed to start url installation job: %w", err)
+	}
+	
+	return job.ID, nil
+}
+
+// InstallFromDocker starts a container installation. uri is the image to pull
+// unless options name an image or a build context.
+func (ais *AdvancedInstallationService) InstallFromDocker(ctx context.Context, slug, uri string, options DockerInstallOptions) (string, error) {
+	if options.Image == "" && options.Context == "" {
+		options.Image = uri
+	}
+	if err := validateDockerOptions(&options); err != nil {
+		return "", err
+	}
+	installer := NewConcreteDockerInstaller(options)
+	job := ais.jobManager.CreateJob(slug, SrcDocker, uri, installer)
+	
+	if err := ais.jobManager.StartJob(job.ID); err != nil {
+		return "", fmt.Errorf("failed to start docker installation job: %w", err)
+	}
+	
+	return job.ID, nil
+}
+
+// Upgrade starts a job moving the installed server slug to version, as
+// Upgrade does. A server Upgrade can't handle is refused before the job
+// starts, with ErrUpgradeUnsupported.
+func (ais *AdvancedInstallationService) Upgrade(ctx context.Context, slug, version string, hooks UpgradeHooks) (string, error) {
+	manifest, err := upgradeTarget(slug, version)
+	if err != nil {
+		return "", err
+	}
+	sourceType, pkg := SrcNpm, manifest.Metadata["npmPackage"]
+	if manifest.Installation.Runtime == "python" {
+		sourceType, pkg = SrcPip, manifest.Metadata["pipPackage"]
+	}
+	installer := &upgradeInstaller{version: version, hooks: hooks, runner: ExecRunner{}}
+	job := ais.jobManager.CreateJob(slug, sourceType, fmt.Sprint(pkg), installer)
+	
+	if err := ais.jobManager.StartJob(job.ID); err != nil {
+		return "", fmt.Errorf("failed to start upgrade job: %w", err)
+	}
+	
+	return job.ID, nil
+}
+
 // GetJobStatus returns the current status of an installation job
 func (ais *AdvancedInstallationService) GetJobStatus(jobID string) (*InstallationJob, error) {
 	job, exists := ais.jobManager.GetJob(jobID)
@@ -273,6 +480,17 @@ func (ais *AdvancedInstallationService) GetJobStatus(jobID string) (*Installatio
 	return job.GetSnapshot(), nil
 }
 
+// GetJob returns the live installation job, for callers that need to follow
+// it rather than read a snapshot
+func (ais *AdvancedInstallationService) GetJob(jobID string) (*InstallationJob, error) {
+	job, exists := ais.jobManager.GetJob(jobID)
+	if !exists {
+		return nil, fmt.Errorf("job %s not found", jobID)
+	}
+	
+	return job, nil
+}
+
 // CancelJob cancels a running installation job
 func (ais *AdvancedInstallationService) CancelJob(jobID string) error {
 	return ais.jobManager.CancelJob(jobID)
@@ -288,8 +506,49 @@ func (ais *AdvancedInstallationService) ListJobs(statusFilter ...JobStatus) []*I
 	return snapshots
 }
 
-// FinalizeInstallation completes the installation by registering the server
-func (ais *AdvancedInstallationService) FinalizeInstallation(ctx context.Context, jobID string) error {
+// QueryJobs returns the page of jobs q asks for and how many match in all
+func (ais *AdvancedInstallationService) QueryJobs(q JobQuery) ([]*InstallationJob, int) {
+	jobs, total := ais.jobManager.QueryJobs(q)
+	snapshots := make([]*InstallationJob, len(jobs))
+	for i, job := range jobs {
+		snapshots[i] = job.GetSnapshot()
+	}
+	return snapshots, total
+}
+
+// SetMaxConcurrent changes how many installations run at once; see
+// JobManager.SetMaxConcurrent.
+func (ais *AdvancedInstallationService) SetMaxConcurrent(n int) {
+	ais.jobManager.SetMaxConcurrent(n)
+}
+
+// SetRequiredEnv records env the server needs at runtime that the installer
+// cannot provide. It is added to the registry entry when the job is finalized.
+func (ais *AdvancedInstallationService) SetRequiredEnv(jobID string, required []registry.RequiredEnv) error {
+	if err := registry.ValidateRequiredEnv(required); err != nil {
+		return err
+	}
+	job, exists := ais.jobManager.GetJob(jobID)
+	if !exists {
+		return fmt.Errorf("job %s not found", jobID)
+	}
+	
+	job.mu.Lock()
+	job.RequiredEnv = append([]registry.RequiredEnv(nil), required...)
+	job.mu.Unlock()
+	return nil
+}
+
+// SetSecretStore sets where secret required env values go when jobs are
+// finalized. Without one, finalizing with a secret value fails.
+func (ais *AdvancedInstallationService) SetSecretStore(store registry.SecretStore) {
+	ais.registryIntegrator.secrets = store
+}
+
+// FinalizeInstallation completes the installation by registering the server.
+// env supplies values for required env; any left unset are reported in the
+// job result's MissingEnv.
+func (ais *AdvancedInstallationService) FinalizeInstallation(ctx context.Context, jobID string, env map[string]string) error {
 	job, exists := ais.jobManager.GetJob(jobID)
 	if !exists {
 		return fmt.Errorf("job %s not found", jobID)
@@ -302,6 +561,9 @@ func (ais *AdvancedInstallationService) FinalizeInstallation(ctx context.Context
 	if !job.Result.Success {
 		return fmt.Errorf("job %s failed, cannot finalize", jobID)
 	}
+	if _, ok := job.installer.(*upgradeInstaller); ok {
+		return fmt.Errorf("job %s upgraded an installed server, there is nothing to finalize", jobID)
+	}
 	
 	job.UpdateStage(StageRegistering, 0)
 	job.Logf(LogLevelInfo, StageRegistering, "Registering server in registry")
@@ -318,34 +580,46 @@ func (ais *AdvancedInstallationService) FinalizeInstallation(ctx context.Context
 		// Don't fail the installation for manifest creation failure
 	}
 	
+	// Env declared with the request adds to what the installer reported
+	job.mu.Lock()
+	job.Result.RequiredEnv = mergeRequiredEnv(job.Result.RequiredEnv, job.RequiredEnv)
+	job.mu.Unlock()
+	
 	// Register server in registry
-	serverEntry, err := ais.registryIntegrator.RegisterServer(ctx, job.Slug, job.Result, job.Type, job.URI)
+	serverEntry, err := ais.registryIntegrator.RegisterServer(ctx, job.Slug, job.Result, job.Type, job.URI, env)
 	if err != nil {
 		job.Logf(LogLevelError, StageRegistering, "Failed to register server: %v", err)
 		return fmt.Errorf("failed to register server: %w", err)
 	}
 	
 	// Update job result with server entry
+	missing := serverEntry.Entry.MissingEnv()
 	job.mu.Lock()
 	job.Result.ServerEntry = serverEntry
+	job.Result.MissingEnv = missing
 	job.mu.Unlock()
 	
+	if len(missing) > 0 {
+		job.Logf(LogLevelWarning, StageRegistering, "Required env not set yet: %s", strings.Join(missing, ", "))
+	}
+	
 	job.UpdateStage(StageRegistering, 100)
 	job.Logf(LogLevelInfo, StageRegistering, "Server successfully registered")
 	
 	return nil
 }
 
-// RemoveServer removes a server installation and unregisters it
-func (ais *AdvancedInstallationService) RemoveServer(ctx context.Context, slug string) error {
-	// TODO: Implement server removal logic
-	// This would involve:
-	// 1. Stopping the server if it's running
-	// 2. Removing installation files
-	// 3. Unregistering from registry
-	// 4. Cleaning up client configurations
-	
-	return fmt.Errorf("server removal not implemented yet")
+// RemoveServer uninstalls a server's files and removes it from the registry
+// file. A running process must be stopped by the caller first.
+func (ais *AdvancedInstallationService) RemoveServer(ctx context.Context, slug string) (*UninstallResult, error) {
+	result, err := Uninstall(ctx, slug)
+	if err != nil {
+		return result, err
+	}
+	if err := ais.registryIntegrator.UnregisterServer(ctx, slug); err != nil {
+		return result, fmt.Errorf("failed to unregister server: %w", err)
+	}
+	return result, nil
 }
 
 // GetRegistry returns the current server registry
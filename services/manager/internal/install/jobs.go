@@ -2,9 +2,14 @@ package install
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"mcp/manager/internal/registry"
@@ -51,6 +56,8 @@ type InstallationJob struct {
 	Logs         []LogEntry         `json:"logs"`
 	Result       *InstallationResult `json:"result,omitempty"`
 	Error        string             `json:"error,omitempty"`
+	RequiredEnv  []registry.RequiredEnv `json:"requiredEnv,omitempty"` // declared with the install request
+	QueuePosition int               `json:"queuePosition,omitempty"` // 1 for the next pending job to start; 0 when not queued
 	
 	// Internal fields
 	ctx         context.Context
@@ -59,6 +66,13 @@ type InstallationJob struct {
 	logChannel  chan LogEntry
 	stageProgress map[JobStage]float64
 	installer   Installer
+	subscribers map[chan LogEntry]int // entries each subscriber missed and has not been told about
+	logsEnded   bool
+	droppedLogs atomic.Int64 // entries Log gave up on, not yet recorded as a gap
+	// logMu guards sends on logChannel against closeLogs. It is separate from mu
+	// because Log is called while mu is held.
+	logMu       sync.RWMutex
+	logClosed   bool
 }
 
 // LogEntry represents a single log entry with metadata
@@ -68,8 +82,13 @@ type LogEntry struct {
 	Stage     JobStage  `json:"stage"`
 	Message   string    `json:"message"`
 	Details   string    `json:"details,omitempty"`
+	Dropped   int       `json:"dropped,omitempty"` // set on a gap marker: the number of entries missing at this point
 }
 
+// logSendTimeout bounds how long Log waits for the collector before giving up
+// on an entry and recording a gap instead.
+const logSendTimeout = time.Second
+
 // LogLevel represents the severity level of a log entry
 type LogLevel string
 
@@ -91,8 +110,12 @@ type InstallationResult struct {
 	Environment     map[string]string      `json:"environment"`
 	Runtime         string                 `json:"runtime"`
 	PackageManager  string                 `json:"packageManager"`
+	RuntimeMode     string                 `json:"runtimeMode,omitempty"` // RuntimeShared or RuntimeIsolated for npm and pip installs
 	InstalledVersion string                `json:"installedVersion"`
+	EntryPoints     []registry.EntryPoint  `json:"entryPoints,omitempty"` // every way to run the package; EntryCommand runs one of them
 	ServerEntry     *registry.Server       `json:"serverEntry,omitempty"`
+	RequiredEnv     []registry.RequiredEnv `json:"requiredEnv,omitempty"`
+	MissingEnv      []string               `json:"missingEnv,omitempty"` // required env still unset after registration
 	Metadata        map[string]interface{} `json:"metadata"`
 }
 
@@ -101,18 +124,24 @@ type Installer interface {
 	Install(ctx context.Context, job *InstallationJob) (*InstallationResult, error)
 }
 
-// JobManager manages multiple installation jobs
+// DefaultMaxConcurrentJobs is how many jobs run at once unless configured.
+const DefaultMaxConcurrentJobs = 5
+
+// JobManager manages multiple installation jobs. At most maxJobs run at once;
+// jobs started beyond that wait, pending, in a FIFO queue.
 type JobManager struct {
 	jobs     map[string]*InstallationJob
 	mu       sync.RWMutex
 	maxJobs  int
+	running  int
+	queue    []*InstallationJob
 	cleanupInterval time.Duration
 }
 
 // NewJobManager creates a new job manager
 func NewJobManager(maxJobs int) *JobManager {
 	if maxJobs <= 0 {
-		maxJobs = 5 // Default maximum concurrent jobs
+		maxJobs = DefaultMaxConcurrentJobs
 	}
 	
 	jm := &JobManager{
@@ -129,9 +158,17 @@ func NewJobManager(maxJobs int) *JobManager {
 
 // CreateJob creates a new installation job
 func (jm *JobManager) CreateJob(slug string, sourceType SourceType, uri string, installer Installer) *InstallationJob {
-	jobID := generateJobID()
 	ctx, cancel := context.WithCancel(context.Background())
 	
+	jm.mu.Lock()
+	jobID := generateJobID()
+	for {
+		if _, exists := jm.jobs[jobID]; !exists {
+			break
+		}
+		jobID = generateJobID()
+	}
+	
 	job := &InstallationJob{
 		ID:           jobID,
 		Slug:         slug,
@@ -149,13 +186,12 @@ func (jm *JobManager) CreateJob(slug string, sourceType SourceType, uri string,
 		installer:    installer,
 	}
 	
-	// Start log collection goroutine
-	go job.logCollector()
-	
-	jm.mu.Lock()
 	jm.jobs[jobID] = job
 	jm.mu.Unlock()
 	
+	// Start log collection goroutine
+	go job.logCollector()
+	
 	return job
 }
 
@@ -190,57 +226,222 @@ func (jm *JobManager) ListJobs(statusFilter ...JobStatus) []*InstallationJob {
 	return jobs
 }
 
-// StartJob starts the execution of a job
+// JobSort orders the jobs QueryJobs returns.
+type JobSort string
+
+const (
+	SortStartTime JobSort = "startTime" // newest first, the default
+	SortEndTime   JobSort = "endTime"   // most recently finished first, unfinished jobs ahead
+	SortSlug      JobSort = "slug"      // by slug, newest first within one
+)
+
+// ParseJobSort returns the sort named by s, SortStartTime when s is empty.
+func ParseJobSort(s string) (JobSort, error) {
+	switch order := JobSort(s); order {
+	case "":
+		return SortStartTime, nil
+	case SortStartTime, SortEndTime, SortSlug:
+		return order, nil
+	default:
+		return "", fmt.Errorf("unknown sort %q: want startTime, endTime or slug", s)
+	}
+}
+
+// ParseJobStatus returns the job status named by s.
+func ParseJobStatus(s string) (JobStatus, error) {
+	switch status := JobStatus(s); status {
+	case JobStatusPending, JobStatusRunning, JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return status, nil
+	default:
+		return "", fmt.Errorf("unknown job status %q", s)
+	}
+}
+
+// JobQuery selects a page of jobs for QueryJobs.
+type JobQuery struct {
+	Statuses []JobStatus // empty matches every status
+	Sort     JobSort     // empty sorts by SortStartTime
+	Offset   int
+	Limit    int // 0 returns every job from Offset on
+}
+
+// QueryJobs returns the page of jobs q asks for and how many jobs match in all.
+func (jm *JobManager) QueryJobs(q JobQuery) ([]*InstallationJob, int) {
+	type entry struct {
+		job   *InstallationJob
+		slug  string
+		start time.Time
+		end   *time.Time
+	}
+	var matched []entry
+	for _, job := range jm.ListJobs(q.Statuses...) {
+		job.mu.RLock()
+		matched = append(matched, entry{job: job, slug: job.Slug, start: job.StartTime, end: job.EndTime})
+		job.mu.RUnlock()
+	}
+	
+	newer := func(a, b entry) int {
+		if c := b.start.Compare(a.start); c != 0 {
+			return c
+		}
+		return strings.Compare(a.job.ID, b.job.ID)
+	}
+	slices.SortFunc(matched, func(a, b entry) int {
+		switch q.Sort {
+		case SortSlug:
+			if c := strings.Compare(a.slug, b.slug); c != 0 {
+				return c
+			}
+		case SortEndTime:
+			switch {
+			case a.end == nil && b.end != nil:
+				return -1
+			case a.end != nil && b.end == nil:
+				return 1
+			case a.end != nil:
+				if c := b.end.Compare(*a.end); c != 0 {
+					return c
+				}
+			}
+		}
+		return newer(a, b)
+	})
+	
+	total := len(matched)
+	matched = matched[min(max(q.Offset, 0), total):]
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+	jobs := make([]*InstallationJob, len(matched))
+	for i, e := range matched {
+		jobs[i] = e.job
+	}
+	return jobs, total
+}
+
+// StartJob runs a pending job, or queues it behind the others when maxJobs
+// are already running. Queued jobs stay pending until a slot frees up.
 func (jm *JobManager) StartJob(jobID string) error {
-	jm.mu.RLock()
+	jm.mu.Lock()
 	job, exists := jm.jobs[jobID]
-	jm.mu.RUnlock()
-	
 	if !exists {
+		jm.mu.Unlock()
 		return fmt.Errorf("job %s not found", jobID)
 	}
-	
-	// Check if we have capacity for new jobs
-	runningJobs := jm.ListJobs(JobStatusRunning)
-	if len(runningJobs) >= jm.maxJobs {
-		return fmt.Errorf("maximum number of concurrent jobs (%d) reached", jm.maxJobs)
+	if status, _, _ := job.State(); status != JobStatusPending || slices.Contains(jm.queue, job) {
+		jm.mu.Unlock()
+		return fmt.Errorf("job %s has already been started", jobID)
 	}
 	
-	// Start the job in a goroutine
-	go jm.executeJob(job)
+	jm.queue = append(jm.queue, job)
+	jm.dispatch()
+	waiting := ""
+	if position := len(jm.queue); position > 0 && jm.queue[position-1] == job {
+		waiting = fmt.Sprintf("Waiting for a free install slot (%d of %d in use), position %d in the queue", jm.running, jm.maxJobs, position)
+	}
+	jm.mu.Unlock()
 	
+	// Log may wait for the collector, so it runs after the lock is released
+	if waiting != "" {
+		job.Log(LogLevelInfo, StageValidation, waiting, "")
+	}
 	return nil
 }
 
-// CancelJob cancels a running job
+// SetMaxConcurrent changes how many jobs run at once, starting queued jobs
+// when the limit goes up. Running jobs are never stopped when it goes down.
+// A limit below 1 restores DefaultMaxConcurrentJobs.
+func (jm *JobManager) SetMaxConcurrent(n int) {
+	if n <= 0 {
+		n = DefaultMaxConcurrentJobs
+	}
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	jm.maxJobs = n
+	jm.dispatch()
+}
+
+// dispatch starts queued jobs while there are free slots and renumbers the
+// rest; jm.mu must be held.
+func (jm *JobManager) dispatch() {
+	for jm.running < jm.maxJobs && len(jm.queue) > 0 {
+		job := jm.queue[0]
+		jm.queue = jm.queue[1:]
+		
+		job.mu.Lock()
+		job.QueuePosition = 0
+		if job.Status != JobStatusPending {
+			job.mu.Unlock()
+			continue
+		}
+		job.Status = JobStatusRunning
+		job.StartTime = time.Now()
+		job.mu.Unlock()
+		
+		jm.running++
+		go jm.executeJob(job)
+	}
+	for i, job := range jm.queue {
+		job.mu.Lock()
+		job.QueuePosition = i + 1
+		job.mu.Unlock()
+	}
+}
+
+// finishJob frees the slot of a job that has stopped running.
+func (jm *JobManager) finishJob() {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	jm.running--
+	jm.dispatch()
+}
+
+// CancelJob cancels a running or queued job
 func (jm *JobManager) CancelJob(jobID string) error {
-	jm.mu.RLock()
+	jm.mu.Lock()
 	job, exists := jm.jobs[jobID]
-	jm.mu.RUnlock()
+	queued := false
+	if exists {
+		if i := slices.Index(jm.queue, job); i >= 0 {
+			jm.queue = slices.Delete(jm.queue, i, i+1)
+			jm.dispatch()
+			queued = true
+		}
+	}
+	jm.mu.Unlock()
 	
 	if !exists {
 		return fmt.Errorf("job %s not found", jobID)
 	}
 	
 	job.mu.Lock()
-	defer job.mu.Unlock()
-	
-	if job.Status == JobStatusRunning {
+	cancelled := job.Status == JobStatusRunning || (queued && job.Status == JobStatusPending)
+	if cancelled {
 		job.cancel()
 		job.Status = JobStatusCancelled
+		job.QueuePosition = 0
 		job.updateEndTime()
-		job.Log(LogLevelInfo, job.CurrentStage, "Job cancelled by user", "")
+	}
+	stage := job.CurrentStage
+	job.mu.Unlock()
+	
+	// A queued job never reaches executeJob, which logs the cancellation of
+	// a running one ahead of the end of its logs
+	if cancelled && queued {
+		job.Log(LogLevelInfo, stage, "Job cancelled by user", "")
+	}
+	if queued {
+		job.endLogs()
 	}
 	
 	return nil
 }
 
-// executeJob executes an installation job
+// executeJob executes an installation job that dispatch has marked running
 func (jm *JobManager) executeJob(job *InstallationJob) {
-	job.mu.Lock()
-	job.Status = JobStatusRunning
-	job.StartTime = time.Now()
-	job.mu.Unlock()
+	defer jm.finishJob()
+	// Runs after the final log entry so subscribers see it before the close
+	defer job.endLogs()
 	
 	job.Log(LogLevelInfo, StageValidation, fmt.Sprintf("Starting installation of %s from %s", job.Slug, job.URI), "")
 	
@@ -248,22 +449,33 @@ func (jm *JobManager) executeJob(job *InstallationJob) {
 	result, err := job.installer.Install(job.ctx, job)
 	
 	job.mu.Lock()
-	defer job.mu.Unlock()
-	
-	if err != nil {
+	status, stage := job.Status, job.CurrentStage
+	switch {
+	case status == JobStatusCancelled:
+		// CancelJob has already recorded how a cancelled job ended
+	case err != nil:
 		job.Status = JobStatusFailed
 		job.CurrentStage = StageFailed
 		job.Error = err.Error()
-		job.Log(LogLevelError, StageFailed, "Installation failed", err.Error())
-	} else {
+		job.updateEndTime()
+	default:
 		job.Status = JobStatusCompleted
 		job.CurrentStage = StageCompleted
 		job.Progress = 100.0
 		job.Result = result
-		job.Log(LogLevelInfo, StageCompleted, "Installation completed successfully", "")
+		job.updateEndTime()
 	}
+	job.mu.Unlock()
 	
-	job.updateEndTime()
+	// Logged after unlocking: Log may wait for the collector, which needs job.mu
+	switch {
+	case status == JobStatusCancelled:
+		job.Log(LogLevelInfo, stage, "Job cancelled by user", "")
+	case err != nil:
+		job.Log(LogLevelError, StageFailed, "Installation failed", err.Error())
+	default:
+		job.Log(LogLevelInfo, StageCompleted, "Installation completed successfully", "")
+	}
 }
 
 // Log adds a log entry to the job
@@ -276,11 +488,26 @@ func (job *InstallationJob) Log(level LogLevel, stage JobStage, message, details
 		Details:   details,
 	}
 	
-	// Send to log channel (non-blocking)
+	job.logMu.RLock()
+	defer job.logMu.RUnlock()
+	if job.logClosed {
+		// The job has been cleaned up; a lingering installer goroutine has nobody left to log to
+		return
+	}
+	
 	select {
 	case job.logChannel <- entry:
+		return
 	default:
-		// Channel is full, skip this log entry
+	}
+	// The collector is behind; wait for it a while, but not forever. Callers
+	// must not hold job.mu, which the collector needs to catch up
+	timer := time.NewTimer(logSendTimeout)
+	defer timer.Stop()
+	select {
+	case job.logChannel <- entry:
+	case <-timer.C:
+		job.droppedLogs.Add(1)
 	}
 }
 
@@ -292,13 +519,12 @@ func (job *InstallationJob) Logf(level LogLevel, stage JobStage, format string,
 // UpdateStage updates the current stage and progress
 func (job *InstallationJob) UpdateStage(stage JobStage, progress float64) {
 	job.mu.Lock()
-	defer job.mu.Unlock()
-	
 	job.CurrentStage = stage
 	job.stageProgress[stage] = progress
 	
 	// Calculate overall progress based on stage completion
 	job.calculateOverallProgress()
+	job.mu.Unlock()
 	
 	job.Log(LogLevelInfo, stage, fmt.Sprintf("Stage %s: %.1f%% complete", stage, progress), "")
 }
@@ -355,6 +581,8 @@ func (job *InstallationJob) GetSnapshot() *InstallationJob {
 		Logs:         make([]LogEntry, len(job.Logs)),
 		Result:       job.Result,
 		Error:        job.Error,
+		RequiredEnv:  job.RequiredEnv,
+		QueuePosition: job.QueuePosition,
 	}
 	
 	copy(snapshot.Logs, job.Logs)
@@ -398,13 +626,132 @@ func (job *InstallationJob) updateEndTime() {
 	job.Duration = now.Sub(job.StartTime)
 }
 
-// logCollector collects log entries from the channel
+// Subscribe returns the logs collected so far together with a channel that
+// receives every later entry, so nothing falls between the two. The channel is
+// closed once the job has finished; call unsubscribe to stop listening early.
+func (job *InstallationJob) Subscribe() ([]LogEntry, <-chan LogEntry, func()) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	
+	backlog := make([]LogEntry, len(job.Logs))
+	copy(backlog, job.Logs)
+	
+	ch := make(chan LogEntry, 100)
+	if job.logsEnded {
+		close(ch)
+		return backlog, ch, func() {}
+	}
+	if job.subscribers == nil {
+		job.subscribers = make(map[chan LogEntry]int)
+	}
+	job.subscribers[ch] = 0
+	
+	unsubscribe := func() {
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		if _, ok := job.subscribers[ch]; ok {
+			delete(job.subscribers, ch)
+			close(ch)
+		}
+	}
+	return backlog, ch, unsubscribe
+}
+
+// State returns the job status, current stage and overall progress.
+func (job *InstallationJob) State() (JobStatus, JobStage, float64) {
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+	return job.Status, job.CurrentStage, job.Progress
+}
+
+// endLogs queues the end-of-logs marker behind any pending entries.
+func (job *InstallationJob) endLogs() {
+	job.logMu.RLock()
+	defer job.logMu.RUnlock()
+	if !job.logClosed {
+		job.logChannel <- LogEntry{}
+	}
+}
+
+// closeLogs closes the log channel, stopping the collector. Log calls made
+// afterwards are dropped instead of sending on the closed channel.
+func (job *InstallationJob) closeLogs() {
+	job.logMu.Lock()
+	defer job.logMu.Unlock()
+	if job.logClosed {
+		return
+	}
+	job.logClosed = true
+	close(job.logChannel)
+}
+
+// closeSubscribers closes every subscriber channel, after a last gap marker
+// where one is owed and fits; job.mu must be held.
+func (job *InstallationJob) closeSubscribers() {
+	for ch, missed := range job.subscribers {
+		if missed > 0 && len(ch) < cap(ch) {
+			ch <- gapEntry(missed, job.CurrentStage)
+		}
+		close(ch)
+	}
+	job.subscribers = nil
+	job.logsEnded = true
+}
+
+// logCollector collects log entries from the channel and fans them out to
+// subscribers. A zero entry marks the end of the job's logs, at which point
+// the finished job is saved as a transcript. Entries Log had to give up on
+// are recorded as a gap ahead of the next one.
 func (job *InstallationJob) logCollector() {
 	for entry := range job.logChannel {
 		job.mu.Lock()
-		job.Logs = append(job.Logs, entry)
+		if n := job.droppedLogs.Swap(0); n > 0 {
+			job.publish(gapEntry(int(n), job.CurrentStage))
+		}
+		if entry.Timestamp.IsZero() {
+			job.closeSubscribers()
+			job.mu.Unlock()
+			job.writeTranscript()
+			continue
+		}
+		job.publish(entry)
 		job.mu.Unlock()
 	}
+	
+	job.mu.Lock()
+	job.closeSubscribers()
+	job.mu.Unlock()
+}
+
+// publish records entry and hands it to every subscriber; job.mu must be
+// held. A subscriber whose buffer is full misses the entry and, once there is
+// room again, gets a gap marker counting what it missed ahead of the next
+// one. The last slot is kept free for that marker.
+func (job *InstallationJob) publish(entry LogEntry) {
+	job.Logs = append(job.Logs, entry)
+	for ch, missed := range job.subscribers {
+		if missed > 0 && len(ch) < cap(ch)-1 {
+			ch <- gapEntry(missed, entry.Stage)
+			missed = 0
+		}
+		if missed == 0 && len(ch) < cap(ch)-1 {
+			ch <- entry
+		} else {
+			missed++
+		}
+		job.subscribers[ch] = missed
+	}
+}
+
+// gapEntry marks n log entries missing at this point.
+func gapEntry(n int, stage JobStage) LogEntry {
+	return LogEntry{
+		Timestamp: time.Now(),
+		Level:     LogLevelWarning,
+		Stage:     stage,
+		Message:   fmt.Sprintf("%d log entries were dropped", n),
+		Dropped:   n,
+	}
 }
 
 // cleanupLoop periodically cleans up old completed jobs
@@ -426,16 +773,21 @@ func (jm *JobManager) cleanupOldJobs() {
 	
 	for jobID, job := range jm.jobs {
 		if job.IsCompleted() && job.EndTime != nil && job.EndTime.Before(cutoff) {
-			// Close the log channel
-			close(job.logChannel)
+			job.closeLogs()
 			delete(jm.jobs, jobID)
 		}
 	}
 }
 
-// generateJobID generates a unique job ID
+// jobSeq makes IDs generated within the same clock tick distinct
+var jobSeq uint64
+
+// generateJobID generates a unique job ID from the time, a process-wide
+// counter and random bytes, so IDs don't collide within or across daemon runs
 func generateJobID() string {
-	return fmt.Sprintf("job_%d", time.Now().UnixNano())
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("job_%d_%d_%s", time.Now().UnixNano(), atomic.AddUint64(&jobSeq, 1), hex.EncodeToString(b[:]))
 }
 
 // MarshalJSON implements custom JSON marshaling for job snapshots
@@ -458,6 +810,7 @@ func (job *InstallationJob) MarshalJSON() ([]byte, error) {
 		Logs         []LogEntry          `json:"logs"`
 		Result       *InstallationResult `json:"result,omitempty"`
 		Error        string              `json:"error,omitempty"`
+		QueuePosition int                `json:"queuePosition,omitempty"`
 	}
 	
 	return json.Marshal(jobJSON{
@@ -474,6 +827,7 @@ func (job *InstallationJob) MarshalJSON() ([]byte, error) {
 		Logs:         job.Logs,
 		Result:       job.Result,
 		Error:        job.Error,
+		QueuePosition: job.QueuePosition,
 	})
 }
 
@@ -503,6 +857,17 @@ func (l *InstallationJobLogger) SetStage(stage JobStage) {
 	l.stage = stage
 }
 
+// EnterStage starts stage at 0% and tags later entries with it
+func (l *InstallationJobLogger) EnterStage(stage JobStage) {
+	l.job.UpdateStage(stage, 0)
+	l.SetStage(stage)
+}
+
+// Progress moves the job's current stage to pct while a long command runs
+func (l *InstallationJobLogger) Progress(pct float64) {
+	l.job.UpdateProgress(pct)
+}
+
 // SetLevel updates the log level for subsequent log entries
 func (l *InstallationJobLogger) SetLevel(level LogLevel) {
 	l.level = level
@@ -0,0 +1,345 @@
+package install
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type funcInstaller func(ctx context.Context, job *InstallationJob) (*InstallationResult, error)
+
+func (f funcInstaller) Install(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+	return f(ctx, job)
+}
+
+// TestJobSubscribe checks that a subscriber joining mid-install sees every entry once and is closed at the end.
+func TestJobSubscribe(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	release := make(chan struct{})
+	jm := NewJobManager(1)
+	job := jm.CreateJob("demo", SrcNpm, "demo", funcInstaller(func(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+		for i := 0; i < 5; i++ {
+			job.Logf(LogLevelInfo, StageInstalling, "before %d", i)
+		}
+		<-release
+		for i := 0; i < 5; i++ {
+			job.Logf(LogLevelInfo, StageInstalling, "after %d", i)
+		}
+		return &InstallationResult{Success: true}, nil
+	}))
+	if err := jm.StartJob(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait until the early entries have been collected
+	deadline := time.Now().Add(2 * time.Second)
+	for len(job.GetSnapshot().Logs) < 6 {
+		if time.Now().After(deadline) {
+			t.Fatal("early logs never collected")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	backlog, ch, unsubscribe := job.Subscribe()
+	defer unsubscribe()
+	close(release)
+
+	var live []LogEntry
+	timeout := time.After(2 * time.Second)
+	for done := false; !done; {
+		select {
+		case entry, ok := <-ch:
+			if !ok {
+				done = true
+				break
+			}
+			live = append(live, entry)
+		case <-timeout:
+			t.Fatal("stream was not closed after the job completed")
+		}
+	}
+
+	all := append(backlog, live...)
+	final := job.GetSnapshot()
+	if len(all) != len(final.Logs) {
+		t.Fatalf("streamed %d entries, job has %d", len(all), len(final.Logs))
+	}
+	for i := range all {
+		if all[i].Message != final.Logs[i].Message {
+			t.Fatalf("entry %d: got %q want %q", i, all[i].Message, final.Logs[i].Message)
+		}
+	}
+	if got := all[len(all)-1].Message; got != "Installation completed successfully" {
+		t.Fatalf("last streamed entry %q", got)
+	}
+
+	// Late subscribers get the full log and a closed channel
+	backlog, ch, _ = job.Subscribe()
+	if _, ok := <-ch; ok || len(backlog) != len(final.Logs) {
+		t.Fatalf("late subscribe: backlog %d, open %v", len(backlog), ok)
+	}
+}
+
+// TestSubscriberGap checks that a subscriber that falls behind is told how many entries it missed.
+func TestSubscriberGap(t *testing.T) {
+	job := &InstallationJob{subscribers: map[chan LogEntry]int{}}
+	ch := make(chan LogEntry, 4)
+	job.subscribers[ch] = 0
+	for i := 0; i < 10; i++ {
+		job.publish(LogEntry{Timestamp: time.Now(), Stage: StageInstalling, Message: "line"})
+	}
+	// Three fit, one slot stays free for the marker
+	for i := 0; i < 3; i++ {
+		<-ch
+	}
+	job.publish(LogEntry{Timestamp: time.Now(), Stage: StageInstalling, Message: "last"})
+	if gap := <-ch; gap.Dropped != 7 {
+		t.Fatalf("gap = %+v", gap)
+	}
+	if last := <-ch; last.Message != "last" {
+		t.Fatalf("entry after the gap = %+v", last)
+	}
+	if len(job.Logs) != 11 {
+		t.Fatalf("job kept %d entries", len(job.Logs))
+	}
+}
+
+// TestLogAfterCleanup logs from a goroutine that outlives its job; run with -race.
+func TestLogAfterCleanup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	jm := &JobManager{jobs: make(map[string]*InstallationJob), maxJobs: 1, cleanupInterval: time.Nanosecond}
+	started := make(chan struct{})
+	stop := make(chan struct{})
+	lingering := make(chan struct{})
+	job := jm.CreateJob("demo", SrcNpm, "demo", funcInstaller(func(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+		go func() {
+			defer close(lingering)
+			close(started)
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				job.Logf(LogLevelInfo, StageInstalling, "late %d", i)
+			}
+		}()
+		<-started
+		return &InstallationResult{Success: true}, nil
+	}))
+	if err := jm.StartJob(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !job.IsCompleted() {
+		if time.Now().After(deadline) {
+			t.Fatal("job never completed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	jm.cleanupOldJobs()
+	if _, ok := jm.GetJob(job.ID); ok {
+		t.Fatal("job was not cleaned up")
+	}
+	jm.cleanupOldJobs()
+
+	// Keep logging past the close; a send on the closed channel would panic here
+	time.Sleep(20 * time.Millisecond)
+	job.Log(LogLevelInfo, StageCompleted, "after cleanup", "")
+	close(stop)
+	<-lingering
+}
+
+// TestCreateJobUniqueIDs creates jobs concurrently and checks none overwrite each other.
+func TestCreateJobUniqueIDs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	jm := &JobManager{jobs: make(map[string]*InstallationJob), maxJobs: 1, cleanupInterval: time.Hour}
+	const n = 500
+	ids := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids <- jm.CreateJob("demo", SrcNpm, "demo", nil).ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool, n)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate job ID %s", id)
+		}
+		seen[id] = true
+		if _, ok := jm.GetJob(id); !ok {
+			t.Fatalf("job %s not retrievable", id)
+		}
+	}
+	if len(jm.ListJobs()) != n {
+		t.Fatalf("expected %d jobs, got %d", n, len(jm.ListJobs()))
+	}
+}
+
+func TestQueryJobs(t *testing.T) {
+	jm := NewJobManager(5)
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, c := range []struct {
+		slug   string
+		status JobStatus
+	}{
+		{"b", JobStatusCompleted},
+		{"a", JobStatusFailed},
+		{"c", JobStatusRunning},
+		{"a", JobStatusRunning},
+	} {
+		job := jm.CreateJob(c.slug, SrcNpm, c.slug, nil)
+		job.mu.Lock()
+		job.StartTime, job.Status = base.Add(time.Duration(i)*time.Minute), c.status
+		if c.status != JobStatusRunning {
+			end := job.StartTime.Add(time.Duration(10-9*i) * time.Minute)
+			job.EndTime = &end
+		}
+		job.mu.Unlock()
+	}
+	order := func(jobs []*InstallationJob) string {
+		var out []string
+		for _, job := range jobs {
+			out = append(out, job.Slug+"@"+job.StartTime.Format("15:04"))
+		}
+		return strings.Join(out, " ")
+	}
+
+	jobs, total := jm.QueryJobs(JobQuery{})
+	if got := order(jobs); total != 4 || got != "a@03:07 c@03:06 a@03:05 b@03:04" {
+		t.Fatalf("default order = %s (%d)", got, total)
+	}
+	jobs, total = jm.QueryJobs(JobQuery{Statuses: []JobStatus{JobStatusRunning, JobStatusFailed}, Offset: 1, Limit: 1})
+	if got := order(jobs); total != 3 || got != "c@03:06" {
+		t.Fatalf("filtered page = %s (%d)", got, total)
+	}
+	jobs, _ = jm.QueryJobs(JobQuery{Sort: SortSlug})
+	if got := order(jobs); got != "a@03:07 a@03:05 b@03:04 c@03:06" {
+		t.Fatalf("by slug = %s", got)
+	}
+	// Running jobs come first, then b, which ended at 03:14, and a at 03:06
+	jobs, _ = jm.QueryJobs(JobQuery{Sort: SortEndTime})
+	if got := order(jobs); got != "a@03:07 c@03:06 b@03:04 a@03:05" {
+		t.Fatalf("by end time = %s", got)
+	}
+	if jobs, total := jm.QueryJobs(JobQuery{Offset: 10}); len(jobs) != 0 || total != 4 {
+		t.Fatalf("past the end = %d jobs of %d", len(jobs), total)
+	}
+	if _, err := ParseJobSort("size"); err == nil {
+		t.Fatal("unknown sort accepted")
+	}
+}
+
+// TestJobQueue starts more jobs than may run at once and checks the rest wait
+// their turn in order, can be cancelled while waiting, and start when the
+// limit is raised.
+func TestJobQueue(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	jm := NewJobManager(1)
+
+	var mu sync.Mutex
+	var order []string
+	release := make(chan struct{})
+	blocking := funcInstaller(func(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+		mu.Lock()
+		order = append(order, job.Slug)
+		mu.Unlock()
+		select {
+		case <-release:
+			return &InstallationResult{Success: true}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	var jobs []*InstallationJob
+	for _, slug := range []string{"a", "b", "c", "d"} {
+		job := jm.CreateJob(slug, SrcNpm, slug, blocking)
+		if err := jm.StartJob(job.ID); err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := jm.StartJob(jobs[1].ID); err == nil {
+		t.Fatal("a queued job was started twice")
+	}
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	for i, want := range []struct {
+		status   JobStatus
+		position int
+	}{{JobStatusRunning, 0}, {JobStatusPending, 1}, {JobStatusPending, 2}, {JobStatusPending, 3}} {
+		if snap := jobs[i].GetSnapshot(); snap.Status != want.status || snap.QueuePosition != want.position {
+			t.Fatalf("job %s: %s at position %d, want %s at %d", snap.Slug, snap.Status, snap.QueuePosition, want.status, want.position)
+		}
+	}
+
+	// Cancelling a queued job ends it without running it and moves the rest up
+	if err := jm.CancelJob(jobs[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	_, ch, _ := jobs[1].Subscribe()
+	waitFor("the cancelled job's logs to end", func() bool {
+		select {
+		case _, ok := <-ch:
+			return !ok
+		default:
+			return false
+		}
+	})
+	if snap := jobs[1].GetSnapshot(); snap.Status != JobStatusCancelled || snap.EndTime == nil {
+		t.Fatalf("cancelled queued job: %s, end %v", snap.Status, snap.EndTime)
+	}
+	if p := jobs[3].GetSnapshot().QueuePosition; p != 2 {
+		t.Fatalf("last job at position %d after a cancel, want 2", p)
+	}
+
+	// Raising the limit starts the next in line
+	jm.SetMaxConcurrent(2)
+	waitFor("c to start", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(order) == 2
+	})
+	if snap := jobs[3].GetSnapshot(); snap.Status != JobStatusPending || snap.QueuePosition != 1 {
+		t.Fatalf("d: %s at position %d", snap.Status, snap.QueuePosition)
+	}
+
+	close(release)
+	waitFor("every job to finish", func() bool {
+		for _, job := range jobs {
+			if !job.IsCompleted() {
+				return false
+			}
+		}
+		return true
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(order, "") != "acd" {
+		t.Fatalf("jobs ran in order %v, want a, c, d", order)
+	}
+}
@@ -2,14 +2,19 @@ package install
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
 )
 
 // NPMInstaller handles npm-based MCP server installations
@@ -31,22 +36,26 @@ func NewNPMInstaller(runner Runner, logger Logger) *NPMInstaller {
 
 // NPMInstallOptions contains configuration for npm-based installations
 type NPMInstallOptions struct {
-	Package       string            `json:"package"`                 // npm package name (e.g., "@anthropic/mcp-cli")
-	Version       string            `json:"version,omitempty"`       // specific version (e.g., "1.0.0", "^1.0.0", "latest")
-	Registry      string            `json:"registry,omitempty"`      // custom npm registry URL
-	Global        bool              `json:"global,omitempty"`        // install globally
-	Token         string            `json:"token,omitempty"`         // npm auth token
-	Username      string            `json:"username,omitempty"`      // npm username for auth
-	Password      string            `json:"password,omitempty"`      // npm password for auth
-	Email         string            `json:"email,omitempty"`         // npm email for auth
-	Scope         string            `json:"scope,omitempty"`         // npm scope for scoped packages
-	PreferManager string            `json:"preferManager,omitempty"` // preferred package manager (npm, yarn, pnpm)
-	Development   bool              `json:"development,omitempty"`   // install dev dependencies
-	Production    bool              `json:"production,omitempty"`    // install only production dependencies
-	Environment   map[string]string `json:"environment,omitempty"`   // environment variables
-	NodeVersion   string            `json:"nodeVersion,omitempty"`   // required Node.js version
-	PostInstall   []string          `json:"postInstall,omitempty"`   // commands to run after install
-	MCPConfig     *NPMMCPConfig     `json:"mcpConfig,omitempty"`     // MCP-specific configuration
+	Package        string            `json:"package"`                  // npm package name (e.g., "@anthropic/mcp-cli")
+	Version        string            `json:"version,omitempty"`        // specific version (e.g., "1.0.0", "^1.0.0", "latest")
+	Registry       string            `json:"registry,omitempty"`       // custom npm registry URL
+	Global         bool              `json:"global,omitempty"`         // install globally
+	Token          string            `json:"token,omitempty"`          // npm auth token
+	Username       string            `json:"username,omitempty"`       // npm username for auth
+	Password       string            `json:"password,omitempty"`       // npm password for auth
+	Email          string            `json:"email,omitempty"`          // npm email for auth
+	Scope          string            `json:"scope,omitempty"`          // npm scope for scoped packages
+	PreferManager  string            `json:"preferManager,omitempty"`  // preferred package manager (npm, yarn, pnpm)
+	Development    bool              `json:"development,omitempty"`    // install dev dependencies
+	Production     bool              `json:"production,omitempty"`     // install only production dependencies
+	Environment    map[string]string `json:"environment,omitempty"`    // environment variables
+	NodeVersion    string            `json:"nodeVersion,omitempty"`    // required Node.js version
+	NodePath       string            `json:"nodePath,omitempty"`       // explicit node binary to pin the install to
+	PostInstall    []string          `json:"postInstall,omitempty"`    // commands to run after install
+	MCPConfig      *NPMMCPConfig     `json:"mcpConfig,omitempty"`      // MCP-specific configuration
+	EntryPointName string            `json:"entryPointName,omitempty"` // bin or script to run; defaults to the first one found
+	SharedRuntime  bool              `json:"sharedRuntime,omitempty"`  // install into the node_modules shared by servers using the same package manager when compatible
+	Checksum       *Checksum         `json:"checksum,omitempty"`       // expected digest of the package tarball
 }
 
 // NPMMCPConfig contains MCP-specific npm configuration
@@ -61,19 +70,22 @@ type NPMMCPConfig struct {
 
 // NPMInstallResult contains the result of an npm installation
 type NPMInstallResult struct {
-	Success          bool              `json:"success"`
-	InstallPath      string            `json:"installPath"`
-	RuntimePath      string            `json:"runtimePath"`
-	BinPath          string            `json:"binPath"`
-	PackageManager   string            `json:"packageManager"`
-	InstalledVersion string            `json:"installedVersion"`
-	EntryCommand     string            `json:"entryCommand"`
-	EntryArgs        []string          `json:"entryArgs"`
-	Environment      map[string]string `json:"environment"`
-	BinExecutables   []string          `json:"binExecutables"`
-	PackageInfo      *NPMPackageInfo   `json:"packageInfo,omitempty"`
-	Logs             []string          `json:"logs"`
-	Error            string            `json:"error,omitempty"`
+	Success          bool                  `json:"success"`
+	InstallPath      string                `json:"installPath"`
+	RuntimePath      string                `json:"runtimePath"`
+	BinPath          string                `json:"binPath"`
+	PackageManager   string                `json:"packageManager"`
+	NodePath         string                `json:"nodePath,omitempty"`
+	RuntimeMode      string                `json:"runtimeMode,omitempty"` // RuntimeShared or RuntimeIsolated
+	InstalledVersion string                `json:"installedVersion"`
+	EntryCommand     string                `json:"entryCommand"`
+	EntryArgs        []string              `json:"entryArgs"`
+	Environment      map[string]string     `json:"environment"`
+	BinExecutables   []string              `json:"binExecutables"`
+	EntryPoints      []registry.EntryPoint `json:"entryPoints,omitempty"`
+	PackageInfo      *NPMPackageInfo       `json:"packageInfo,omitempty"`
+	Logs             []string              `json:"logs"`
+	Error            string                `json:"error,omitempty"`
 }
 
 // NPMPackageInfo contains information about the installed npm package
@@ -123,7 +135,7 @@ func (n *NPMInstaller) Install(ctx context.Context, slug string, options NPMInst
 
 	// Ensure directories exist
 	for _, dir := range []string{serverDir, installDir, runtimeDir, binDir} {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
+		if err := paths.MkdirAll(dir); err != nil {
 			return result, fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
@@ -145,8 +157,19 @@ func (n *NPMInstaller) Install(ctx context.Context, slug string, options NPMInst
 	result.PackageManager = packageManager
 	logf(n.logger, "Using package manager: %s", packageManager)
 
-	// Validate Node.js version if specified
-	if options.NodeVersion != "" {
+	// Resolve a pinned Node.js runtime, or validate the version if only a range is given
+	nodeExec := "node"
+	if options.NodePath != "" || isExactVersion(options.NodeVersion) {
+		nodeExec, err = resolveRuntimePin(ctx, n.runner, n.logger, RuntimePin{
+			Runtime: "node",
+			Path:    options.NodePath,
+			Version: options.NodeVersion,
+		})
+		if err != nil {
+			return result, fmt.Errorf("node.js runtime pin failed: %w", err)
+		}
+		result.NodePath = nodeExec
+	} else if options.NodeVersion != "" {
 		if err := n.validateNodeVersion(ctx, options.NodeVersion); err != nil {
 			return result, fmt.Errorf("node.js version validation failed: %w", err)
 		}
@@ -162,11 +185,39 @@ func (n *NPMInstaller) Install(ctx context.Context, slug string, options NPMInst
 		return result, fmt.Errorf("package validation failed: %w", err)
 	}
 
-	// Install package
-	if err := n.installPackage(ctx, options, runtimeDir, packageManager); err != nil {
-		return result, fmt.Errorf("package installation failed: %w", err)
+	// Install the tarball that was verified rather than fetching it again
+	var tarball string
+	if options.Checksum != nil {
+		file, cleanup, err := n.packVerified(ctx, options)
+		if err != nil {
+			result.Error = fmt.Sprintf("Checksum verification failed: %v", err)
+			logf(n.logger, result.Error)
+			return result, fmt.Errorf("checksum verification failed: %w", err)
+		}
+		defer cleanup()
+		tarball = file
 	}
 
+	// Install package, into the shared node_modules when asked and compatible
+	result.RuntimeMode = RuntimeIsolated
+	if dir, unlock := n.sharedModules(options, packageManager); dir != "" {
+		defer unlock()
+		runtimeDir = dir
+		result.RuntimeMode = RuntimeShared
+	}
+	if err := n.installInto(ctx, options, tarball, nodeExec, runtimeDir, packageManager); err != nil {
+		if result.RuntimeMode != RuntimeShared {
+			return result, fmt.Errorf("package installation failed: %w", err)
+		}
+		// Peer dependency conflicts only show up when resolving
+		logf(n.logger, "Shared install failed, using an isolated runtime: %v", err)
+		runtimeDir, result.RuntimeMode = result.RuntimePath, RuntimeIsolated
+		if err := n.installInto(ctx, options, tarball, nodeExec, runtimeDir, packageManager); err != nil {
+			return result, fmt.Errorf("package installation failed: %w", err)
+		}
+	}
+	result.RuntimePath = runtimeDir
+
 	// Get installed package information
 	packageInfo, installedVersion, err := n.getPackageInfo(ctx, options.Package, runtimeDir, packageManager)
 	if err != nil {
@@ -184,8 +235,16 @@ func (n *NPMInstaller) Install(ctx context.Context, slug string, options NPMInst
 		result.BinExecutables = binExecutables
 	}
 
+	// Record every way to run the package so the entry can be switched later
+	result.EntryPoints = n.entryPoints(options, packageInfo, runtimeDir, nodeExec)
+	if options.EntryPointName != "" {
+		if _, err := pickEntryPoint(result.EntryPoints, options.EntryPointName); err != nil {
+			return result, err
+		}
+	}
+
 	// Determine entry point
-	entryCmd, entryArgs, env, err := n.determineEntryPoint(options, packageInfo, runtimeDir)
+	entryCmd, entryArgs, env, err := n.determineEntryPoint(options, result.EntryPoints, runtimeDir, nodeExec)
 	if err != nil {
 		logf(n.logger, "Warning: Failed to determine entry point: %v", err)
 	} else {
@@ -301,7 +360,7 @@ func (n *NPMInstaller) setupAuthentication(ctx context.Context, options NPMInsta
 	}
 
 	if npmrcContent.Len() > 0 {
-		if err := os.WriteFile(npmrcPath, []byte(npmrcContent.String()), 0o600); err != nil {
+		if err := paths.WriteFile(npmrcPath, []byte(npmrcContent.String()), paths.SecretMode); err != nil {
 			return fmt.Errorf("failed to write .npmrc: %w", err)
 		}
 	}
@@ -343,8 +402,77 @@ func (n *NPMInstaller) validatePackage(ctx context.Context, options NPMInstallOp
 	return nil
 }
 
-// installPackage performs the actual package installation
-func (n *NPMInstaller) installPackage(ctx context.Context, options NPMInstallOptions, runtimeDir, packageManager string) error {
+// packVerified fetches the package tarball with npm pack into a temporary
+// directory and checks it against both the integrity npm reports for it and
+// options.Checksum. It returns the tarball, which installInto installs in
+// place of the package spec, and a function that removes it.
+func (n *NPMInstaller) packVerified(ctx context.Context, options NPMInstallOptions) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "mcp-npm-verify-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	packageSpec := options.Package
+	if options.Version != "" {
+		packageSpec = fmt.Sprintf("%s@%s", options.Package, options.Version)
+	}
+	args := []string{"pack", packageSpec, "--json", "--pack-destination", dir}
+	if options.Registry != "" {
+		args = append(args, "--registry", options.Registry)
+	}
+	stdout, stderr, err := n.runner.Run(ctx, "npm", args...)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("npm pack failed: %w: %s", err, lastLines(stderr, 3))
+	}
+
+	var packed []struct {
+		Filename  string `json:"filename"`
+		Integrity string `json:"integrity"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &packed); err != nil || len(packed) != 1 {
+		cleanup()
+		return "", nil, fmt.Errorf("unexpected npm pack output: %s", lastLines(stdout, 3))
+	}
+	tarball := filepath.Join(dir, filepath.Base(packed[0].Filename))
+
+	// npm reports a subresource integrity string, "sha512-<base64>"
+	if alg, digest, ok := strings.Cut(packed[0].Integrity, "-"); ok {
+		if sum, err := base64.StdEncoding.DecodeString(digest); err == nil {
+			if err := VerifyChecksum(tarball, alg, hex.EncodeToString(sum)); err != nil {
+				cleanup()
+				return "", nil, err
+			}
+		}
+	}
+	if err := VerifyChecksum(tarball, options.Checksum.Algorithm, options.Checksum.Value); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	logf(n.logger, "Verified %s", filepath.Base(tarball))
+	return tarball, cleanup, nil
+}
+
+// installInto installs options' package into runtimeDir with nodeExec, from
+// tarball when one was verified. The tarball is copied into runtimeDir first: the package
+// manager records it as a file: dependency, which has to outlive the
+// temporary copy for later installs in the same directory to resolve.
+func (n *NPMInstaller) installInto(ctx context.Context, options NPMInstallOptions, tarball, nodeExec, runtimeDir, packageManager string) error {
+	if tarball != "" {
+		kept := filepath.Join(runtimeDir, filepath.Base(tarball))
+		if err := copyFile(tarball, kept); err != nil {
+			return fmt.Errorf("failed to keep verified tarball: %w", err)
+		}
+		options.Package, options.Version = kept, ""
+	}
+	return n.installPackage(ctx, options, nodeExec, runtimeDir, packageManager)
+}
+
+// installPackage performs the actual package installation. A pinned nodeExec
+// goes first on PATH, so the package manager and the package's install
+// scripts run on it rather than on whatever node the PATH holds.
+func (n *NPMInstaller) installPackage(ctx context.Context, options NPMInstallOptions, nodeExec, runtimeDir, packageManager string) error {
 	logf(n.logger, "Installing package with %s...", packageManager)
 
 	packageSpec := options.Package
@@ -366,7 +494,7 @@ func (n *NPMInstaller) installPackage(ctx context.Context, options NPMInstallOpt
 		}
 
 		jsonData, _ := json.MarshalIndent(initialPackageJSON, "", "  ")
-		if err := os.WriteFile(packageJSONPath, jsonData, 0o644); err != nil {
+		if err := paths.WriteFile(packageJSONPath, jsonData, paths.FileMode()); err != nil {
 			return fmt.Errorf("failed to create package.json: %w", err)
 		}
 	}
@@ -430,15 +558,19 @@ func (n *NPMInstaller) installPackage(ctx context.Context, options NPMInstallOpt
 		cmd.Dir = runtimeDir
 	}
 
-	env := os.Environ()
-	for k, v := range options.Environment {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	// The runner only takes a command line, so variables are set through env
+	name, runArgs := cmd.Path, cmd.Args[1:]
+	if vars := installEnv(options.Environment, nodeExec); len(vars) > 0 {
+		name, runArgs = "env", append(vars, cmd.Args...)
 	}
-	cmd.Env = env
 
 	// Execute installation
-	stdout, stderr, err := n.runner.Run(ctx, cmd.Path, cmd.Args[1:]...)
+	stdout, stderr, err := runWithProgress(ctx, n.runner, n.logger, npmInstallProgress, name, runArgs...)
 	if err != nil {
+		// node-gyp output is only useful to someone who can fix the toolchain
+		if terr := toolchainFailure(stdout + stderr); terr != nil {
+			return terr
+		}
 		return fmt.Errorf("installation failed: %w, stdout: %s, stderr: %s", err, stdout, stderr)
 	}
 
@@ -446,6 +578,30 @@ func (n *NPMInstaller) installPackage(ctx context.Context, options NPMInstallOpt
 	return nil
 }
 
+// installEnv returns the NAME=value arguments to env that add environment to
+// an install command's environment, with the directory of a pinned nodeExec
+// in front of PATH.
+func installEnv(environment map[string]string, nodeExec string) []string {
+	var vars []string
+	for k, v := range environment {
+		if k != "PATH" {
+			vars = append(vars, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	sort.Strings(vars)
+	path, ok := environment["PATH"]
+	if !ok {
+		path = os.Getenv("PATH")
+	}
+	if filepath.IsAbs(nodeExec) {
+		path, ok = filepath.Dir(nodeExec)+string(os.PathListSeparator)+path, true
+	}
+	if ok {
+		vars = append(vars, "PATH="+path)
+	}
+	return vars
+}
+
 // getPackageInfo retrieves information about the installed package
 func (n *NPMInstaller) getPackageInfo(ctx context.Context, packageName, runtimeDir, packageManager string) (*NPMPackageInfo, string, error) {
 	// Try to read package.json from node_modules
@@ -515,57 +671,72 @@ func (n *NPMInstaller) detectBinExecutables(runtimeDir string, packageInfo *NPMP
 }
 
 // determineEntryPoint determines the command and arguments to run the MCP server
-func (n *NPMInstaller) determineEntryPoint(options NPMInstallOptions, packageInfo *NPMPackageInfo, runtimeDir string) (command string, args []string, env map[string]string, err error) {
+func (n *NPMInstaller) determineEntryPoint(options NPMInstallOptions, candidates []registry.EntryPoint, runtimeDir, nodeExec string) (command string, args []string, env map[string]string, err error) {
 	env = make(map[string]string)
 
-	// Add node_modules/.bin to PATH
+	// Add node_modules/.bin to PATH. A pinned node goes in front of it so that
+	// package bins with a "#!/usr/bin/env node" shebang run on the pinned runtime.
 	binPath := filepath.Join(runtimeDir, "node_modules", ".bin")
+	if filepath.IsAbs(nodeExec) {
+		binPath = filepath.Dir(nodeExec) + ":" + binPath
+	}
 	env["PATH"] = fmt.Sprintf("%s:%s", binPath, os.Getenv("PATH"))
 
 	// MCP-specific configuration takes priority
-	if options.MCPConfig != nil {
+	if options.MCPConfig != nil && options.EntryPointName == "" {
 		if options.MCPConfig.EntryCommand != "" {
 			return options.MCPConfig.EntryCommand, options.MCPConfig.Args, env, nil
 		}
 		if options.MCPConfig.EntryScript != "" {
-			return "node", []string{filepath.Join(runtimeDir, options.MCPConfig.EntryScript)}, env, nil
+			return nodeExec, []string{filepath.Join(runtimeDir, options.MCPConfig.EntryScript)}, env, nil
 		}
 	}
 
-	// Check if package has a binary entry
+	ep, err := pickEntryPoint(candidates, options.EntryPointName)
+	if err != nil {
+		return "", nil, env, fmt.Errorf("no entry point found for package %s: %w", options.Package, err)
+	}
+	return ep.Command, ep.Args, env, nil
+}
+
+// entryPoints lists the ways to run the installed package: its bins, with one
+// named after the package first, then its main script and common entry files.
+func (n *NPMInstaller) entryPoints(options NPMInstallOptions, packageInfo *NPMPackageInfo, runtimeDir, nodeExec string) []registry.EntryPoint {
+	var candidates []registry.EntryPoint
+	packageDir := filepath.Join(runtimeDir, "node_modules", options.Package)
+
 	if packageInfo != nil && len(packageInfo.Bin) > 0 {
-		// Use the first binary entry
-		for name, _ := range packageInfo.Bin {
+		names := make([]string, 0, len(packageInfo.Bin))
+		for name := range packageInfo.Bin {
+			names = append(names, name)
+		}
+		base := path.Base(options.Package)
+		sort.Slice(names, func(i, j int) bool {
+			if (names[i] == base) != (names[j] == base) {
+				return names[i] == base
+			}
+			return names[i] < names[j]
+		})
+		for _, name := range names {
 			binPath := filepath.Join(runtimeDir, "node_modules", ".bin", name)
 			if _, err := os.Stat(binPath); err == nil {
-				return binPath, []string{}, env, nil
+				candidates = addEntryPoint(candidates, registry.EntryPoint{Name: name, Kind: "bin", Command: binPath})
 			}
 		}
 	}
 
-	// Check package.json main field
+	// package.json main field, then common entry files
+	scripts := []string{"index.js", "main.js", "server.js", filepath.Join("src", "index.js")}
 	if packageInfo != nil && packageInfo.Main != "" {
-		mainPath := filepath.Join(runtimeDir, "node_modules", options.Package, packageInfo.Main)
-		if _, err := os.Stat(mainPath); err == nil {
-			return "node", []string{mainPath}, env, nil
-		}
+		scripts = append([]string{packageInfo.Main}, scripts...)
 	}
-
-	// Check for common entry points
-	possiblePaths := []string{
-		filepath.Join(runtimeDir, "node_modules", options.Package, "index.js"),
-		filepath.Join(runtimeDir, "node_modules", options.Package, "main.js"),
-		filepath.Join(runtimeDir, "node_modules", options.Package, "server.js"),
-		filepath.Join(runtimeDir, "node_modules", options.Package, "src", "index.js"),
-	}
-
-	for _, path := range possiblePaths {
-		if _, err := os.Stat(path); err == nil {
-			return "node", []string{path}, env, nil
+	for _, script := range scripts {
+		scriptPath := filepath.Join(packageDir, script)
+		if _, err := os.Stat(scriptPath); err == nil {
+			candidates = addEntryPoint(candidates, registry.EntryPoint{Name: filepath.ToSlash(filepath.Clean(script)), Kind: "script", Command: nodeExec, Args: []string{scriptPath}})
 		}
 	}
-
-	return "", nil, env, fmt.Errorf("no entry point found for package %s", options.Package)
+	return candidates
 }
 
 // runPostInstallCommands executes user-defined post-install commands
@@ -629,7 +800,7 @@ func (n *NPMInstaller) createBinScript(binDir, slug, command string, args []stri
 		script.WriteString("exit 1\n")
 	}
 
-	if err := os.WriteFile(scriptPath, []byte(script.String()), 0o755); err != nil {
+	if err := paths.WriteFile(scriptPath, []byte(script.String()), paths.ExecMode()); err != nil {
 		return fmt.Errorf("failed to write script: %w", err)
 	}
 
@@ -664,5 +835,5 @@ func copyFile(src, dst string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(dst, sourceData, 0o644)
+	return paths.WriteFile(dst, sourceData, paths.FileMode())
 }
@@ -3,6 +3,7 @@ package install
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -102,3 +103,30 @@ func TestNPMInstaller_ErrorHandling(t *testing.T) {
 	// We'll skip it for now as it requires more advanced mocking or setup.
 	t.Skip("Skipping test that requires filesystem error injection")
 }
+
+// TestNPMInstaller_PinnedNodeOnPath checks that the install command finds a pinned node first on PATH.
+func TestNPMInstaller_PinnedNodeOnPath(t *testing.T) {
+	var name string
+	var args []string
+	installer := NewNPMInstaller(mockRunner{f: func(ctx context.Context, n string, a ...string) (string, string, error) {
+		name, args = n, a
+		return "", "", nil
+	}}, testLogger{t})
+	runtimeDir := t.TempDir()
+
+	options := NPMInstallOptions{Package: "left-pad", Environment: map[string]string{"NPM_CONFIG_FUND": "false"}}
+	if err := installer.installPackage(context.Background(), options, "/opt/node-20/bin/node", runtimeDir, "npm"); err != nil {
+		t.Fatal(err)
+	}
+	if name != "env" || len(args) < 4 || args[0] != "NPM_CONFIG_FUND=false" || !strings.HasPrefix(args[1], "PATH=/opt/node-20/bin:") || args[2] != "npm" || args[3] != "install" {
+		t.Fatalf("ran %s %v", name, args)
+	}
+
+	// An unpinned node with no extra variables runs the package manager directly
+	if err := installer.installPackage(context.Background(), NPMInstallOptions{Package: "left-pad"}, "node", runtimeDir, "npm"); err != nil {
+		t.Fatal(err)
+	}
+	if name == "env" {
+		t.Fatalf("ran %s %v", name, args)
+	}
+}
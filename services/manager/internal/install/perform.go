@@ -4,7 +4,6 @@ import (
     "context"
     "encoding/json"
     "fmt"
-    "os"
     "path/filepath"
     "time"
 
@@ -48,13 +47,13 @@ func PerformStream(ctx context.Context, in PerformInput, r Runner, lg Logger) (P
     baseServers, err := paths.ServersDir()
     if err != nil { return PerformResult{OK: false}, err }
     dest := filepath.Join(baseServers, in.Slug)
-    if err := os.MkdirAll(dest, 0o755); err != nil { return PerformResult{OK: false}, err }
+    if err := paths.MkdirAll(dest); err != nil { return PerformResult{OK: false}, err }
     installDir := filepath.Join(dest, "install")
     runtimeDir := filepath.Join(dest, "runtime")
     binDir := filepath.Join(dest, "bin")
-    if err := os.MkdirAll(installDir, 0o755); err != nil { return PerformResult{OK: false}, err }
-    if err := os.MkdirAll(runtimeDir, 0o755); err != nil { return PerformResult{OK: false}, err }
-    if err := os.MkdirAll(binDir, 0o755); err != nil { return PerformResult{OK: false}, err }
+    if err := paths.MkdirAll(installDir); err != nil { return PerformResult{OK: false}, err }
+    if err := paths.MkdirAll(runtimeDir); err != nil { return PerformResult{OK: false}, err }
+    if err := paths.MkdirAll(binDir); err != nil { return PerformResult{OK: false}, err }
 
     if r == nil { r = ExecRunner{} }
     switch in.Type {
@@ -101,13 +100,13 @@ func PerformStream(ctx context.Context, in PerformInput, r Runner, lg Logger) (P
         "manager": in.Manager,
     }
     b, _ := json.MarshalIndent(manifest, "", "  ")
-    if err := os.WriteFile(filepath.Join(dest, "manifest.json"), b, 0o644); err != nil {
+    if err := paths.WriteFile(filepath.Join(dest, "manifest.json"), b, paths.FileMode()); err != nil {
         return PerformResult{OK: false, Message: err.Error()}, nil
     }
 
     // Create a bin entrypoint placeholder; real command will be set via registry entry
     entry := filepath.Join(binDir, in.Slug)
-    _ = os.WriteFile(entry, []byte("#!/bin/sh\necho 'MCP server placeholder; configure entry.command in registry.'\n"), 0o755)
+    _ = paths.WriteFile(entry, []byte("#!/bin/sh\necho 'MCP server placeholder; configure entry.command in registry.'\n"), paths.ExecMode())
 
     logf(lg, "installed %s", in.Slug)
     return PerformResult{OK: true, Message: "installed"}, nil
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
 )
 
 // PipInstaller handles pip-based MCP server installations
@@ -43,11 +44,15 @@ type PipInstallOptions struct {
 	UseVenv          bool              `json:"useVenv,omitempty"`          // create and use virtual environment (default: true)
 	UsePipx          bool              `json:"usePipx,omitempty"`          // use pipx for isolated installation
 	PythonVersion    string            `json:"pythonVersion,omitempty"`    // required Python version
+	PythonPath       string            `json:"pythonPath,omitempty"`       // explicit interpreter to pin the install to
 	RequirementsFile string            `json:"requirementsFile,omitempty"` // path to requirements.txt file
 	Extras           []string          `json:"extras,omitempty"`           // package extras to install (e.g., ["dev", "test"])
 	Environment      map[string]string `json:"environment,omitempty"`      // environment variables
 	PostInstall      []string          `json:"postInstall,omitempty"`      // commands to run after install
 	MCPConfig        *PipMCPConfig     `json:"mcpConfig,omitempty"`        // MCP-specific configuration
+	EntryPointName   string            `json:"entryPointName,omitempty"`   // console script or module to run; defaults to the first one found
+	SharedRuntime    bool              `json:"sharedRuntime,omitempty"`    // install into the venv shared by servers on the same Python when compatible
+	Checksum         *Checksum         `json:"checksum,omitempty"`         // expected digest of the downloaded wheel or sdist
 }
 
 // PipMCPConfig contains MCP-specific pip configuration
@@ -63,22 +68,25 @@ type PipMCPConfig struct {
 
 // PipInstallResult contains the result of a pip installation
 type PipInstallResult struct {
-	Success           bool              `json:"success"`
-	InstallPath       string            `json:"installPath"`
-	RuntimePath       string            `json:"runtimePath"`
-	BinPath           string            `json:"binPath"`
-	VenvPath          string            `json:"venvPath,omitempty"`
-	PythonPath        string            `json:"pythonPath"`
-	PipPath           string            `json:"pipPath"`
-	InstalledVersion  string            `json:"installedVersion"`
-	EntryCommand      string            `json:"entryCommand"`
-	EntryArgs         []string          `json:"entryArgs"`
-	Environment       map[string]string `json:"environment"`
-	ConsoleScripts    []string          `json:"consoleScripts"`
-	PackageInfo       *PipPackageInfo   `json:"packageInfo,omitempty"`
-	InstalledPackages []string          `json:"installedPackages"`
-	Logs              []string          `json:"logs"`
-	Error             string            `json:"error,omitempty"`
+	Success           bool                  `json:"success"`
+	InstallPath       string                `json:"installPath"`
+	RuntimePath       string                `json:"runtimePath"`
+	BinPath           string                `json:"binPath"`
+	VenvPath          string                `json:"venvPath,omitempty"`
+	RuntimeMode       string                `json:"runtimeMode,omitempty"` // RuntimeShared or RuntimeIsolated for venv and pipx installs
+	PythonPath        string                `json:"pythonPath"`
+	Interpreter       string                `json:"interpreter,omitempty"` // base interpreter the venv was created from
+	PipPath           string                `json:"pipPath"`
+	InstalledVersion  string                `json:"installedVersion"`
+	EntryCommand      string                `json:"entryCommand"`
+	EntryArgs         []string              `json:"entryArgs"`
+	Environment       map[string]string     `json:"environment"`
+	ConsoleScripts    []string              `json:"consoleScripts"`
+	EntryPoints       []registry.EntryPoint `json:"entryPoints,omitempty"`
+	PackageInfo       *PipPackageInfo       `json:"packageInfo,omitempty"`
+	InstalledPackages []string              `json:"installedPackages"`
+	Logs              []string              `json:"logs"`
+	Error             string                `json:"error,omitempty"`
 }
 
 // PipPackageInfo contains information about the installed pip package
@@ -120,7 +128,7 @@ func (p *PipInstaller) Install(ctx context.Context, slug string, options PipInst
 
 	// Ensure directories exist
 	for _, dir := range []string{serverDir, installDir, runtimeDir, binDir} {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
+		if err := paths.MkdirAll(dir); err != nil {
 			return result, fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
@@ -129,13 +137,14 @@ func (p *PipInstaller) Install(ctx context.Context, slug string, options PipInst
 	logf(p.logger, "Package: %s", options.Package)
 
 	// Validate Python installation
-	pythonExec, err := p.detectPythonExecutable(ctx, options.PythonVersion)
+	pythonExec, err := p.resolvePython(ctx, options)
 	if err != nil {
 		result.Error = fmt.Sprintf("Python validation failed: %v", err)
 		logf(p.logger, result.Error)
 		return result, nil
 	}
 	result.PythonPath = pythonExec
+	result.Interpreter = pythonExec
 	logf(p.logger, "Using Python: %s", pythonExec)
 
 	// Handle pipx installation if requested
@@ -143,22 +152,34 @@ func (p *PipInstaller) Install(ctx context.Context, slug string, options PipInst
 		return p.installWithPipx(ctx, slug, options, result)
 	}
 
-	// Create virtual environment if requested (default behavior)
-	if options.UseVenv {
-		venvPath, err := p.createVirtualEnvironment(ctx, runtimeDir, pythonExec)
-		if err != nil {
-			result.Error = fmt.Sprintf("Virtual environment creation failed: %v", err)
-			logf(p.logger, result.Error)
-			return result, nil
-		}
+	// useVenv points the install at venvPath's Python and pip
+	basePython := pythonExec
+	useVenv := func(venvPath string) {
 		result.VenvPath = venvPath
-
-		// Update Python and pip paths for virtual environment
 		if pythonPath, pipPath, err := p.getVenvExecutables(venvPath); err == nil {
 			result.PythonPath = pythonPath
 			result.PipPath = pipPath
 			pythonExec = pythonPath
 		}
+	}
+
+	// Create virtual environment if requested (default behavior)
+	if options.UseVenv {
+		venvPath, unlock := p.sharedVenv(ctx, options, pythonExec)
+		if venvPath != "" {
+			defer unlock()
+			result.RuntimeMode = RuntimeShared
+			result.RuntimePath = filepath.Dir(venvPath)
+		} else {
+			venvPath, err = p.createVirtualEnvironment(ctx, runtimeDir, pythonExec)
+			if err != nil {
+				result.Error = fmt.Sprintf("Virtual environment creation failed: %v", err)
+				logf(p.logger, result.Error)
+				return result, nil
+			}
+			result.RuntimeMode = RuntimeIsolated
+		}
+		useVenv(venvPath)
 	} else {
 		// Use system pip
 		if pipPath, err := p.detectPipExecutable(ctx, pythonExec); err == nil {
@@ -173,8 +194,35 @@ func (p *PipInstaller) Install(ctx context.Context, slug string, options PipInst
 		return result, nil
 	}
 
+	// Install the file that was verified rather than downloading it again
+	installOptions := options
+	if options.Checksum != nil {
+		file, cleanup, err := p.downloadVerified(ctx, options, pipCommand(result.PipPath))
+		if err != nil {
+			result.Error = fmt.Sprintf("Checksum verification failed: %v", err)
+			logf(p.logger, result.Error)
+			return result, nil
+		}
+		defer cleanup()
+		installOptions.Package, installOptions.Version = file, ""
+	}
+
 	// Install package
-	if err := p.installPackage(ctx, options, result.PipPath, pythonExec); err != nil {
+	err = p.installPackage(ctx, installOptions, result.PipPath, pythonExec)
+	if err != nil && result.RuntimeMode == RuntimeShared {
+		// The dry run can't catch everything, e.g. a failing build
+		logf(p.logger, "Shared install failed, using an isolated venv: %v", err)
+		venvPath, verr := p.createVirtualEnvironment(ctx, runtimeDir, basePython)
+		if verr != nil {
+			result.Error = fmt.Sprintf("Virtual environment creation failed: %v", verr)
+			logf(p.logger, result.Error)
+			return result, nil
+		}
+		result.RuntimeMode, result.RuntimePath = RuntimeIsolated, runtimeDir
+		useVenv(venvPath)
+		err = p.installPackage(ctx, installOptions, result.PipPath, pythonExec)
+	}
+	if err != nil {
 		result.Error = fmt.Sprintf("Package installation failed: %v", err)
 		logf(p.logger, result.Error)
 		return result, nil
@@ -197,8 +245,18 @@ func (p *PipInstaller) Install(ctx context.Context, slug string, options PipInst
 		result.ConsoleScripts = consoleScripts
 	}
 
+	// Record every way to run the package so the entry can be switched later
+	result.EntryPoints = p.entryPoints(ctx, options, packageInfo, result.VenvPath, pythonExec)
+	if options.EntryPointName != "" {
+		if _, err := pickEntryPoint(result.EntryPoints, options.EntryPointName); err != nil {
+			result.Error = err.Error()
+			logf(p.logger, result.Error)
+			return result, nil
+		}
+	}
+
 	// Determine entry point
-	entryCmd, entryArgs, env, err := p.determineEntryPoint(ctx, options, packageInfo, result.VenvPath, pythonExec)
+	entryCmd, entryArgs, env, err := p.determineEntryPoint(options, result.EntryPoints, pythonExec)
 	if err != nil {
 		logf(p.logger, "Warning: Failed to determine entry point: %v", err)
 	} else {
@@ -240,6 +298,20 @@ func (p *PipInstaller) Install(ctx context.Context, slug string, options PipInst
 	return result, nil
 }
 
+// resolvePython picks the interpreter for the install. An explicit PythonPath or an
+// exact PythonVersion is a pin and must be satisfied; otherwise the first suitable
+// python on PATH is used.
+func (p *PipInstaller) resolvePython(ctx context.Context, options PipInstallOptions) (string, error) {
+	if options.PythonPath != "" || isExactVersion(options.PythonVersion) {
+		return resolveRuntimePin(ctx, p.runner, p.logger, RuntimePin{
+			Runtime: "python",
+			Path:    options.PythonPath,
+			Version: options.PythonVersion,
+		})
+	}
+	return p.detectPythonExecutable(ctx, options.PythonVersion)
+}
+
 // detectPythonExecutable finds and validates the Python executable
 func (p *PipInstaller) detectPythonExecutable(ctx context.Context, requiredVersion string) (string, error) {
 	pythonCandidates := []string{"python3", "python", "python3.12", "python3.11", "python3.10", "python3.9"}
@@ -334,6 +406,7 @@ func (p *PipInstaller) getVenvExecutables(venvPath string) (pythonPath, pipPath
 // installWithPipx performs installation using pipx for isolation
 func (p *PipInstaller) installWithPipx(ctx context.Context, slug string, options PipInstallOptions, result *PipInstallResult) (*PipInstallResult, error) {
 	logf(p.logger, "Installing with pipx...")
+	result.RuntimeMode = RuntimeIsolated
 
 	// Check if pipx is available
 	if _, _, err := p.runner.Run(ctx, "pipx", "--version"); err != nil {
@@ -346,8 +419,22 @@ func (p *PipInstaller) installWithPipx(ctx context.Context, slug string, options
 		packageSpec = fmt.Sprintf("%s==%s", options.Package, options.Version)
 	}
 
+	if options.Checksum != nil {
+		file, cleanup, err := p.downloadVerified(ctx, options, []string{result.Interpreter, "-m", "pip"})
+		if err != nil {
+			result.Error = fmt.Sprintf("Checksum verification failed: %v", err)
+			return result, nil
+		}
+		defer cleanup()
+		packageSpec = file
+	}
+
 	args := []string{"install", packageSpec}
 
+	if options.PythonPath != "" || isExactVersion(options.PythonVersion) {
+		args = append(args, "--python", result.Interpreter)
+	}
+
 	if options.ForceReinstall {
 		args = append(args, "--force")
 	}
@@ -425,10 +512,102 @@ func (p *PipInstaller) validatePackage(ctx context.Context, options PipInstallOp
 	return nil
 }
 
+// downloadVerified fetches the package alone with pip download --no-deps into
+// a temporary directory and checks it against options.Checksum. It returns
+// the verified file, which installs in place of the package name, and a
+// function that removes it. pip is the command that runs pip, with its
+// leading arguments, e.g. the interpreter, "-m" and "pip".
+func (p *PipInstaller) downloadVerified(ctx context.Context, options PipInstallOptions, pip []string) (string, func(), error) {
+	if options.RequirementsFile != "" {
+		return "", nil, fmt.Errorf("checksums apply to a single package, not a requirements file")
+	}
+	dir, err := os.MkdirTemp("", "mcp-pip-verify-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	packageSpec := options.Package
+	if options.Version != "" {
+		packageSpec = fmt.Sprintf("%s==%s", options.Package, options.Version)
+	}
+	args := []string{"download", "--no-deps", "--dest", dir, packageSpec}
+	if options.PreRelease {
+		args = append(args, "--pre")
+	}
+	if options.IndexURL != "" {
+		args = append(args, "--index-url", options.IndexURL)
+	}
+	if options.ExtraIndexURL != "" {
+		args = append(args, "--extra-index-url", options.ExtraIndexURL)
+	}
+	if options.TrustedHost != "" {
+		args = append(args, "--trusted-host", options.TrustedHost)
+	}
+	if _, stderr, err := p.runner.Run(ctx, pip[0], append(pip[1:len(pip):len(pip)], args...)...); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("pip download failed: %w: %s", err, lastLines(stderr, 3))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		cleanup()
+		return "", nil, fmt.Errorf("expected pip download to fetch one file, got %d", len(entries))
+	}
+	file := filepath.Join(dir, entries[0].Name())
+	if err := VerifyChecksum(file, options.Checksum.Algorithm, options.Checksum.Value); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	logf(p.logger, "Verified %s", entries[0].Name())
+	return file, cleanup, nil
+}
+
+// pipCommand splits a pip path from detectPipExecutable into the command and
+// its leading arguments
+func pipCommand(pipPath string) []string {
+	if strings.Contains(pipPath, "-m pip") {
+		return strings.Fields(pipPath)
+	}
+	return []string{pipPath}
+}
+
 // installPackage performs the actual package installation
 func (p *PipInstaller) installPackage(ctx context.Context, options PipInstallOptions, pipPath, pythonExec string) error {
 	logf(p.logger, "Installing package with pip...")
 
+	args := pipInstallArgs(options)
+
+	var cmd *exec.Cmd
+	if strings.Contains(pipPath, "-m pip") {
+		parts := strings.Fields(pipPath)
+		allArgs := append(parts[1:], args...)
+		cmd = exec.CommandContext(ctx, parts[0], allArgs...)
+	} else {
+		cmd = exec.CommandContext(ctx, pipPath, args...)
+	}
+
+	env := os.Environ()
+	for k, v := range options.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	cmd.Env = env
+
+	stdout, stderr, err := runWithProgress(ctx, p.runner, p.logger, pipInstallProgress(), cmd.Path, cmd.Args[1:]...)
+	if err != nil {
+		// Report a missing compiler or headers rather than the build log
+		if terr := toolchainFailure(stdout + stderr); terr != nil {
+			return terr
+		}
+		return fmt.Errorf("installation failed: %w, stdout: %s, stderr: %s", err, stdout, stderr)
+	}
+
+	logf(p.logger, "Package installed successfully")
+	return nil
+}
+
+// pipInstallArgs returns the pip arguments that install options' package
+func pipInstallArgs(options PipInstallOptions) []string {
 	var args []string
 
 	if options.RequirementsFile != "" {
@@ -464,29 +643,7 @@ func (p *PipInstaller) installPackage(ctx context.Context, options PipInstallOpt
 	if options.TrustedHost != "" {
 		args = append(args, "--trusted-host", options.TrustedHost)
 	}
-
-	var cmd *exec.Cmd
-	if strings.Contains(pipPath, "-m pip") {
-		parts := strings.Fields(pipPath)
-		allArgs := append(parts[1:], args...)
-		cmd = exec.CommandContext(ctx, parts[0], allArgs...)
-	} else {
-		cmd = exec.CommandContext(ctx, pipPath, args...)
-	}
-
-	env := os.Environ()
-	for k, v := range options.Environment {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
-	}
-	cmd.Env = env
-
-	stdout, stderr, err := p.runner.Run(ctx, cmd.Path, cmd.Args[1:]...)
-	if err != nil {
-		return fmt.Errorf("installation failed: %w, stdout: %s, stderr: %s", err, stdout, stderr)
-	}
-
-	logf(p.logger, "Package installed successfully")
-	return nil
+	return args
 }
 
 // getPackageInfo retrieves information about the installed package
@@ -651,11 +808,11 @@ func (p *PipInstaller) isPythonScript(path string) bool {
 }
 
 // determineEntryPoint determines the command and arguments to run the MCP server
-func (p *PipInstaller) determineEntryPoint(ctx context.Context, options PipInstallOptions, packageInfo *PipPackageInfo, venvPath, pythonExec string) (command string, args []string, env map[string]string, err error) {
+func (p *PipInstaller) determineEntryPoint(options PipInstallOptions, candidates []registry.EntryPoint, pythonExec string) (command string, args []string, env map[string]string, err error) {
 	env = make(map[string]string)
 
 	// MCP-specific configuration takes priority
-	if options.MCPConfig != nil {
+	if options.MCPConfig != nil && options.EntryPointName == "" {
 		if options.MCPConfig.EntryCommand != "" {
 			return options.MCPConfig.EntryCommand, options.MCPConfig.Args, env, nil
 		}
@@ -663,40 +820,55 @@ func (p *PipInstaller) determineEntryPoint(ctx context.Context, options PipInsta
 			return pythonExec, []string{"-m", options.MCPConfig.EntryModule}, env, nil
 		}
 		if options.MCPConfig.EntryScript != "" {
-			scriptPath := filepath.Join(venvPath, options.MCPConfig.EntryScript)
-			if _, err := os.Stat(scriptPath); err == nil {
-				return pythonExec, []string{scriptPath}, env, nil
+			for _, ep := range candidates {
+				if ep.Kind == "script" {
+					return ep.Command, ep.Args, env, nil
+				}
 			}
 		}
 	}
 
-	// Check for console scripts
+	ep, err := pickEntryPoint(candidates, options.EntryPointName)
+	if err != nil {
+		return "", nil, env, fmt.Errorf("no entry point found for package %s: %w", options.Package, err)
+	}
+	return ep.Command, ep.Args, env, nil
+}
+
+// entryPoints lists the ways to run the installed package: its console
+// scripts, then the modules under its name that import.
+func (p *PipInstaller) entryPoints(ctx context.Context, options PipInstallOptions, packageInfo *PipPackageInfo, venvPath, pythonExec string) []registry.EntryPoint {
+	var candidates []registry.EntryPoint
+
+	if options.MCPConfig != nil && options.MCPConfig.EntryScript != "" {
+		scriptPath := filepath.Join(venvPath, options.MCPConfig.EntryScript)
+		if _, err := os.Stat(scriptPath); err == nil {
+			candidates = addEntryPoint(candidates, registry.EntryPoint{Name: options.MCPConfig.EntryScript, Kind: "script", Command: pythonExec, Args: []string{scriptPath}})
+		}
+	}
+
 	if packageInfo != nil && packageInfo.EntryPoints != nil {
-		if consoleScripts, exists := packageInfo.EntryPoints["console_scripts"]; exists && len(consoleScripts) > 0 {
-			// Use the first console script
-			scriptSpec := consoleScripts[0]
-			scriptName := strings.Split(scriptSpec, " = ")[0]
+		for _, scriptSpec := range packageInfo.EntryPoints["console_scripts"] {
+			parts := strings.SplitN(scriptSpec, " = ", 2)
+			scriptName := strings.TrimSpace(parts[0])
 
-			// Check if the script exists in the venv
+			// Prefer the generated script, then run its module directly
 			if venvPath != "" {
 				scriptPath := filepath.Join(venvPath, "bin", scriptName)
 				if _, err := os.Stat(scriptPath); err == nil {
-					return scriptPath, []string{}, env, nil
+					candidates = addEntryPoint(candidates, registry.EntryPoint{Name: scriptName, Kind: "console_script", Command: scriptPath})
+					continue
 				}
 				// Try Windows Scripts directory
 				scriptPath = filepath.Join(venvPath, "Scripts", scriptName+".exe")
 				if _, err := os.Stat(scriptPath); err == nil {
-					return scriptPath, []string{}, env, nil
+					candidates = addEntryPoint(candidates, registry.EntryPoint{Name: scriptName, Kind: "console_script", Command: scriptPath})
+					continue
 				}
 			}
-
-			// Try running as module
-			parts := strings.Split(scriptSpec, " = ")
 			if len(parts) > 1 {
-				moduleParts := strings.Split(parts[1], ":")
-				if len(moduleParts) > 0 {
-					return pythonExec, []string{"-m", moduleParts[0]}, env, nil
-				}
+				module := strings.Split(parts[1], ":")[0]
+				candidates = addEntryPoint(candidates, registry.EntryPoint{Name: scriptName, Kind: "module", Command: pythonExec, Args: []string{"-m", module}})
 			}
 		}
 	}
@@ -709,13 +881,17 @@ func (p *PipInstaller) determineEntryPoint(ctx context.Context, options PipInsta
 		strings.ReplaceAll(options.Package, "-", "_") + ".server",
 	}
 
+	seen := make(map[string]bool)
 	for _, module := range commonModules {
+		if seen[module] {
+			continue
+		}
+		seen[module] = true
 		if p.canImportModule(ctx, pythonExec, module) {
-			return pythonExec, []string{"-m", module}, env, nil
+			candidates = addEntryPoint(candidates, registry.EntryPoint{Name: module, Kind: "module", Command: pythonExec, Args: []string{"-m", module}})
 		}
 	}
-
-	return "", nil, env, fmt.Errorf("no entry point found for package %s", options.Package)
+	return candidates
 }
 
 // canImportModule checks if a Python module can be imported
@@ -793,7 +969,7 @@ func (p *PipInstaller) createBinScript(binDir, slug, command string, args []stri
 		script.WriteString("exit 1\n")
 	}
 
-	if err := os.WriteFile(scriptPath, []byte(script.String()), 0o755); err != nil {
+	if err := paths.WriteFile(scriptPath, []byte(script.String()), paths.ExecMode()); err != nil {
 		return fmt.Errorf("failed to write script: %w", err)
 	}
 
@@ -0,0 +1,211 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StreamRunner is a Runner that can also hand each line of a command's
+// output to onLine while the command is still running.
+type StreamRunner interface {
+	Runner
+	RunStream(ctx context.Context, onLine func(line string), name string, args ...string) (stdout string, stderr string, err error)
+}
+
+// progressReporter is implemented by loggers that can move a job's progress bar
+type progressReporter interface {
+	Progress(pct float64)
+}
+
+// stageReporter is implemented by loggers that follow a job's stages, for
+// installers that go through several of them in one call
+type stageReporter interface {
+	EnterStage(stage JobStage)
+}
+
+// enterStage moves logger's job on to stage, when logger follows a job
+func enterStage(logger Logger, stage JobStage) {
+	if sr, ok := logger.(stageReporter); ok {
+		sr.EnterStage(stage)
+	}
+}
+
+// progressParser turns one line of tool output into a stage percentage
+type progressParser func(line string) (pct float64, ok bool)
+
+var (
+	gitProgressRE  = regexp.MustCompile(`^(?:remote: )?(Receiving objects|Resolving deltas):\s+(\d{1,3})%`)
+	pipRawRE       = regexp.MustCompile(`^Progress (\d+) of (\d+)`)
+	yarnStepRE     = regexp.MustCompile(`^\[(\d+)/(\d+)\] `)
+	pnpmProgressRE = regexp.MustCompile(`^Progress: resolved (\d+), reused (\d+), downloaded (\d+), added (\d+)`)
+	dockerStepRE   = regexp.MustCompile(`^(?:#\d+ \[(?:[\w.-]+ )?|Step )(\d+)/(\d+)[\] ]`)
+)
+
+// gitCloneProgress maps "Receiving objects" onto 0-90% and "Resolving deltas"
+// onto the last 10%, the two phases that take time on a large clone.
+func gitCloneProgress(line string) (float64, bool) {
+	m := gitProgressRE.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	pct, _ := strconv.ParseFloat(m[2], 64)
+	if m[1] == "Resolving deltas" {
+		return 90 + pct/10, true
+	}
+	return pct * 0.9, true
+}
+
+// pipInstallProgress follows pip's default output: each "Collecting" line
+// creeps towards 60%, installing the resolved set is 80% and success is 100%.
+// With PIP_PROGRESS_BAR=raw, pip's "Progress N of M" download lines are used too.
+func pipInstallProgress() progressParser {
+	collected := 0
+	return func(line string) (float64, bool) {
+		switch {
+		case strings.HasPrefix(line, "Collecting "):
+			collected++
+			return 60 - 60/float64(collected+1), true
+		case strings.HasPrefix(line, "Installing collected packages"):
+			return 80, true
+		case strings.HasPrefix(line, "Successfully installed"):
+			return 100, true
+		}
+		if m := pipRawRE.FindStringSubmatch(line); m != nil {
+			done, _ := strconv.ParseFloat(m[1], 64)
+			total, _ := strconv.ParseFloat(m[2], 64)
+			if total > 0 {
+				// A single download stays inside the collecting share
+				return 60 * done / total, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// npmInstallProgress reads yarn's "[N/M]" step lines and pnpm's append-only
+// "Progress: resolved ..." lines. npm itself prints nothing until it is done.
+func npmInstallProgress(line string) (float64, bool) {
+	if m := yarnStepRE.FindStringSubmatch(line); m != nil {
+		step, _ := strconv.ParseFloat(m[1], 64)
+		total, _ := strconv.ParseFloat(m[2], 64)
+		if total > 0 {
+			return 100 * (step - 1) / total, true
+		}
+	}
+	if m := pnpmProgressRE.FindStringSubmatch(line); m != nil {
+		resolved, _ := strconv.ParseFloat(m[1], 64)
+		added, _ := strconv.ParseFloat(m[4], 64)
+		if resolved > 0 {
+			return 100 * added / resolved, true
+		}
+	}
+	return 0, false
+}
+
+// dockerBuildProgress reads the build steps from BuildKit's plain output
+// ("#7 [3/5] RUN npm ci") and the classic builder's ("Step 3/5 : RUN npm ci").
+// Steps of a multi-stage build count within their stage.
+func dockerBuildProgress(line string) (float64, bool) {
+	m := dockerStepRE.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	step, _ := strconv.ParseFloat(m[1], 64)
+	total, _ := strconv.ParseFloat(m[2], 64)
+	if total == 0 {
+		return 0, false
+	}
+	return 100 * (step - 1) / total, true
+}
+
+// progressTracker feeds parsed percentages to a reporter, never moving
+// backwards: submodule clones and later phases restart their own counters.
+// line is called from the stdout and stderr writers at once, and parsers such
+// as pipInstallProgress keep state, so parsing happens under mu too.
+type progressTracker struct {
+	mu       sync.Mutex
+	parse    progressParser
+	reporter progressReporter
+	last     float64
+}
+
+func (t *progressTracker) line(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pct, ok := t.parse(strings.TrimSpace(line))
+	if !ok {
+		return
+	}
+	if pct > 100 {
+		pct = 100
+	}
+	// Whole percents are enough for a progress bar
+	if pct <= t.last || (pct < t.last+1 && pct < 100) {
+		return
+	}
+	t.last = pct
+	t.reporter.Progress(pct)
+}
+
+// runWithProgress runs name through r, reporting progress parsed from its
+// output to logger when logger can take it. Runners that cannot stream are
+// parsed once the command exits.
+func runWithProgress(ctx context.Context, r Runner, logger Logger, parse progressParser, name string, args ...string) (string, string, error) {
+	reporter, ok := logger.(progressReporter)
+	if !ok {
+		return r.Run(ctx, name, args...)
+	}
+	tracker := &progressTracker{parse: parse, reporter: reporter}
+	if sr, ok := r.(StreamRunner); ok {
+		return sr.RunStream(ctx, tracker.line, name, args...)
+	}
+	stdout, stderr, err := r.Run(ctx, name, args...)
+	for _, out := range []string{stdout, stderr} {
+		for _, line := range splitProgressLines(out) {
+			tracker.line(line)
+		}
+	}
+	return stdout, stderr, err
+}
+
+// splitProgressLines splits on both newlines and the carriage returns
+// that git and pip use to redraw a progress line in place.
+func splitProgressLines(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' })
+}
+
+// lineWriter collects written output and calls onLine for every
+// complete line, treating '\r' as a line end.
+type lineWriter struct {
+	buf     bytes.Buffer
+	partial []byte
+	onLine  func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexAny(w.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			w.onLine(string(w.partial[:i]))
+		}
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush reports a trailing line that had no terminator
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.onLine(string(w.partial))
+		w.partial = nil
+	}
+}
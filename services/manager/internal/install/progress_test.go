@@ -0,0 +1,157 @@
+package install
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type progressLogger struct {
+	testLogger
+	reports []float64
+}
+
+func (l *progressLogger) Progress(pct float64) { l.reports = append(l.reports, pct) }
+
+type stageLogger struct {
+	testLogger
+	stages []JobStage
+}
+
+func (l *stageLogger) EnterStage(stage JobStage) { l.stages = append(l.stages, stage) }
+
+func TestGitInstallStages(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	logger := &stageLogger{testLogger: testLogger{t}}
+	git := NewGitInstaller(mockRunner{}, logger)
+	if _, err := git.Install(context.Background(), "demo", GitInstallOptions{URI: "https://example.com/demo.git"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.stages) != 2 || logger.stages[0] != StageInstalling || logger.stages[1] != StageConfiguring {
+		t.Fatalf("stages = %v", logger.stages)
+	}
+}
+
+func TestGitCloneProgress(t *testing.T) {
+	cases := map[string]float64{
+		"Receiving objects:  50% (512/1024), 1.20 MiB | 2.40 MiB/s": 45,
+		"Receiving objects: 100% (1024/1024), done.":                90,
+		"Resolving deltas:  50% (10/20)":                            95,
+	}
+	for line, want := range cases {
+		if got, ok := gitCloneProgress(line); !ok || got != want {
+			t.Errorf("gitCloneProgress(%q) = %v, %v; want %v", line, got, ok, want)
+		}
+	}
+	if _, ok := gitCloneProgress("remote: Counting objects: 40% (4/10)"); ok {
+		t.Error("counting phase reported as progress")
+	}
+}
+
+func TestRunWithProgress(t *testing.T) {
+	stderr := "Cloning into 'demo'...\n" +
+		"Receiving objects:  10% (1/10)\rReceiving objects:  10% (1/10)\rReceiving objects:  60% (6/10)\r" +
+		"Receiving objects: 100% (10/10), done.\n" +
+		"Resolving deltas:   0% (0/4)\rResolving deltas: 100% (4/4), done.\n"
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		return "", stderr, nil
+	}}
+	logger := &progressLogger{testLogger: testLogger{t}}
+	if _, _, err := runWithProgress(context.Background(), runner, logger, gitCloneProgress, "git", "clone"); err != nil {
+		t.Fatal(err)
+	}
+	// Repeats are dropped and the start of delta resolution does not move backwards
+	want := []float64{9, 54, 90, 100}
+	if len(logger.reports) != len(want) {
+		t.Fatalf("reports = %v, want %v", logger.reports, want)
+	}
+	for i := range want {
+		if logger.reports[i] != want[i] {
+			t.Fatalf("reports = %v, want %v", logger.reports, want)
+		}
+	}
+}
+
+func TestProgressTrackerSerializesParser(t *testing.T) {
+	// Parsers keep state, like pipInstallProgress's count of collected packages
+	lines := 0
+	parse := func(line string) (float64, bool) {
+		lines++
+		return 0, false
+	}
+	tracker := &progressTracker{parse: parse, reporter: &progressLogger{testLogger: testLogger{t}}}
+	var wg sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func() { // the stdout and stderr writers
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				tracker.line("Collecting demo")
+			}
+		}()
+	}
+	wg.Wait()
+	if lines != 1000 {
+		t.Fatalf("parser saw %d lines, want 1000", lines)
+	}
+}
+
+func TestInstallProgressParsers(t *testing.T) {
+	pip := pipInstallProgress()
+	var last float64
+	for _, line := range []string{"Collecting demo", "Collecting dep-a", "Downloading dep_a-1.0.whl (12 kB)", "Installing collected packages: dep-a, demo", "Successfully installed demo-1.0 dep-a-1.0"} {
+		if pct, ok := pip(line); ok {
+			if pct <= last {
+				t.Fatalf("pip progress went from %v to %v at %q", last, pct, line)
+			}
+			last = pct
+		}
+	}
+	if last != 100 {
+		t.Fatalf("pip finished at %v", last)
+	}
+
+	if pct, ok := npmInstallProgress("[2/4] Fetching packages..."); !ok || pct != 25 {
+		t.Errorf("yarn step = %v, %v", pct, ok)
+	}
+	if pct, ok := npmInstallProgress("Progress: resolved 40, reused 10, downloaded 20, added 30"); !ok || pct != 75 {
+		t.Errorf("pnpm progress = %v, %v", pct, ok)
+	}
+	if _, ok := npmInstallProgress("added 12 packages in 2s"); ok {
+		t.Error("npm summary reported as progress")
+	}
+
+	for line, want := range map[string]float64{
+		"#7 [3/5] RUN npm ci":              40,
+		"#9 [builder 2/4] COPY . .":        25,
+		"Step 5/5 : CMD [\"node\", \"x\"]": 80,
+	} {
+		if pct, ok := dockerBuildProgress(line); !ok || pct != want {
+			t.Errorf("dockerBuildProgress(%q) = %v, %v; want %v", line, pct, ok, want)
+		}
+	}
+	if _, ok := dockerBuildProgress("#7 0.412 added 12 packages"); ok {
+		t.Error("build output reported as progress")
+	}
+}
+
+func TestLineWriterSplitsCarriageReturns(t *testing.T) {
+	var lines []string
+	w := &lineWriter{onLine: func(line string) { lines = append(lines, line) }}
+	w.Write([]byte("Receiving objects:  1%\rReceiving"))
+	w.Write([]byte(" objects:  2%\r\ndone"))
+	w.flush()
+	want := []string{"Receiving objects:  1%", "Receiving objects:  2%", "done"}
+	if len(lines) != len(want) {
+		t.Fatalf("lines = %q", lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("lines = %q", lines)
+		}
+	}
+	if w.buf.String() != "Receiving objects:  1%\rReceiving objects:  2%\r\ndone" {
+		t.Fatalf("buffered output = %q", w.buf.String())
+	}
+}
@@ -4,33 +4,41 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"mcp/manager/internal/paths"
 	"mcp/manager/internal/registry"
+	"mcp/manager/internal/yaml"
 )
 
 // RegistryIntegrator handles the integration between installation results and the server registry
 type RegistryIntegrator struct {
 	registryPath string
+	secrets      registry.SecretStore // receives secret required env values
 }
 
 // NewRegistryIntegrator creates a new registry integrator
 func NewRegistryIntegrator() (*RegistryIntegrator, error) {
-	baseDir, err := paths.HomeMCP()
-	if err != nil {
+	if _, err := paths.HomeMCP(); err != nil {
 		return nil, fmt.Errorf("failed to get MCP home directory: %w", err)
 	}
 	
-	registryPath := filepath.Join(baseDir, "registry.json")
+	registryPath, err := registry.DefaultPath()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry path: %w", err)
+	}
 	return &RegistryIntegrator{
 		registryPath: registryPath,
 	}, nil
 }
 
-// RegisterServer adds a server to the registry based on installation results
-func (ri *RegistryIntegrator) RegisterServer(ctx context.Context, slug string, installResult *InstallationResult, sourceType SourceType, sourceURI string) (*registry.Server, error) {
+// RegisterServer adds a server to the registry based on installation results.
+// env supplies values for the server's required env; secret ones are stored
+// through the integrator's secret store.
+func (ri *RegistryIntegrator) RegisterServer(ctx context.Context, slug string, installResult *InstallationResult, sourceType SourceType, sourceURI string, env map[string]string) (*registry.Server, error) {
 	// Load existing registry
 	reg, err := ri.loadRegistry()
 	if err != nil {
@@ -40,8 +48,19 @@ func (ri *RegistryIntegrator) RegisterServer(ctx context.Context, slug string, i
 	// Check if server already exists
 	for i, server := range reg.Servers {
 		if server.Slug == slug {
-			// Update existing server
+			// Update existing server, keeping required env the user already provided
 			updatedServer := ri.createServerEntry(slug, installResult, sourceType, sourceURI)
+			for _, req := range updatedServer.Entry.RequiredEnv {
+				if v := server.Entry.Env[req.Key]; v != "" && updatedServer.Entry.Env[req.Key] == "" {
+					if updatedServer.Entry.Env == nil {
+						updatedServer.Entry.Env = make(map[string]string)
+					}
+					updatedServer.Entry.Env[req.Key] = v
+				}
+			}
+			if err := registry.ApplyEnv(updatedServer, env, ri.secrets); err != nil {
+				return nil, err
+			}
 			reg.Servers[i] = *updatedServer
 			
 			if err := ri.saveRegistry(reg); err != nil {
@@ -54,6 +73,9 @@ func (ri *RegistryIntegrator) RegisterServer(ctx context.Context, slug string, i
 	
 	// Add new server
 	newServer := ri.createServerEntry(slug, installResult, sourceType, sourceURI)
+	if err := registry.ApplyEnv(newServer, env, ri.secrets); err != nil {
+		return nil, err
+	}
 	reg.Servers = append(reg.Servers, *newServer)
 	
 	if err := ri.saveRegistry(reg); err != nil {
@@ -174,7 +196,7 @@ func (ri *RegistryIntegrator) CreateManifest(slug string, installResult *Install
 		return fmt.Errorf("failed to marshal manifest: %w", err)
 	}
 	
-	if err := os.WriteFile(manifestPath, manifestData, 0o644); err != nil {
+	if err := paths.WriteFile(manifestPath, manifestData, paths.FileMode()); err != nil {
 		return fmt.Errorf("failed to write manifest: %w", err)
 	}
 	
@@ -197,7 +219,12 @@ func (ri *RegistryIntegrator) loadRegistry() (*registry.Registry, error) {
 	}
 	
 	var reg registry.Registry
-	if err := json.Unmarshal(data, &reg); err != nil {
+	if yaml.IsYAML(ri.registryPath) {
+		err = yaml.Unmarshal(data, &reg)
+	} else {
+		err = json.Unmarshal(data, &reg)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal registry: %w", err)
 	}
 	
@@ -206,19 +233,25 @@ func (ri *RegistryIntegrator) loadRegistry() (*registry.Registry, error) {
 
 // saveRegistry saves the server registry to disk
 func (ri *RegistryIntegrator) saveRegistry(reg *registry.Registry) error {
-	data, err := json.MarshalIndent(reg, "", "  ")
+	var data []byte
+	var err error
+	if yaml.IsYAML(ri.registryPath) {
+		data, err = yaml.Marshal(reg)
+	} else {
+		data, err = json.MarshalIndent(reg, "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal registry: %w", err)
 	}
 	
 	// Ensure the directory exists
-	if err := os.MkdirAll(filepath.Dir(ri.registryPath), 0o755); err != nil {
+	if err := paths.MkdirAll(filepath.Dir(ri.registryPath)); err != nil {
 		return fmt.Errorf("failed to create registry directory: %w", err)
 	}
 	
 	// Write to a temporary file first, then rename for atomic operation
 	tempPath := ri.registryPath + ".tmp"
-	if err := os.WriteFile(tempPath, data, 0o644); err != nil {
+	if err := paths.WriteFile(tempPath, data, paths.FileMode()); err != nil {
 		return fmt.Errorf("failed to write temporary registry file: %w", err)
 	}
 	
@@ -229,21 +262,26 @@ func (ri *RegistryIntegrator) saveRegistry(reg *registry.Registry) error {
 	return nil
 }
 
-// createServerEntry creates a registry server entry from installation results
-func (ri *RegistryIntegrator) createServerEntry(slug string, installResult *InstallationResult, sourceType SourceType, sourceURI string) *registry.Server {
-	server := &registry.Server{
+// NewServerEntry builds the registry entry for a finished installation from
+// what the installer actually set up. The concrete installers attach it to
+// their result, so finalizing registers it without re-deriving anything.
+func NewServerEntry(slug string, installResult *InstallationResult, sourceType SourceType, sourceURI string) *registry.Server {
+	return &registry.Server{
 		Name: slug,
 		Slug: slug,
 		Source: registry.Source{
 			Type: string(sourceType),
 			URI:  sourceURI,
 		},
-		Runtime: ri.createRuntimeEntry(installResult),
+		Runtime: runtimeEntry(installResult),
 		Entry: registry.Entry{
-			Transport: "stdio", // Default transport
-			Command:   installResult.EntryCommand,
-			Args:      installResult.EntryArgs,
-			Env:       installResult.Environment,
+			Transport:   "stdio", // every installer sets up a stdio launcher
+			Command:     installResult.EntryCommand,
+			Args:        slices.Clone(installResult.EntryArgs),
+			Env:         maps.Clone(installResult.Environment),
+			RequiredEnv: slices.Clone(installResult.RequiredEnv),
+			EntryPoints: slices.Clone(installResult.EntryPoints),
+			EntryPoint:  entryPointName(installResult.EntryPoints, installResult.EntryCommand, installResult.EntryArgs),
 		},
 		Health: registry.Health{
 			Probe:         "command",
@@ -258,17 +296,52 @@ func (ri *RegistryIntegrator) createServerEntry(slug string, installResult *Inst
 			ClaudeDesktop: &registry.ClientFlag{Enabled: false},
 			CursorGlobal:  &registry.ClientFlag{Enabled: false},
 			Continue:      &registry.ClientFlag{Enabled: false},
+			VSCode:        &registry.ClientFlag{Enabled: false},
+			Windsurf:      &registry.ClientFlag{Enabled: false},
 		},
 	}
+}
+
+// createServerEntry returns a copy of the installer's entry, or builds one for
+// results that don't carry it. Required env is taken from the result, which
+// holds the env declared with the request by now.
+func (ri *RegistryIntegrator) createServerEntry(slug string, installResult *InstallationResult, sourceType SourceType, sourceURI string) *registry.Server {
+	if installResult.ServerEntry == nil {
+		return NewServerEntry(slug, installResult, sourceType, sourceURI)
+	}
 	
-	return server
+	server := *installResult.ServerEntry
+	server.Entry.Args = slices.Clone(server.Entry.Args)
+	server.Entry.Env = maps.Clone(server.Entry.Env)
+	server.Entry.RequiredEnv = slices.Clone(installResult.RequiredEnv)
+	return &server
+}
+
+// mergeRequiredEnv combines the env an installer reported with the env declared
+// in the install request, which wins for keys present in both.
+func mergeRequiredEnv(reported, declared []registry.RequiredEnv) []registry.RequiredEnv {
+	merged := append([]registry.RequiredEnv(nil), declared...)
+	seen := make(map[string]bool, len(declared))
+	for _, req := range declared {
+		seen[req.Key] = true
+	}
+	for _, req := range reported {
+		if !seen[req.Key] {
+			merged = append(merged, req)
+		}
+	}
+	return merged
 }
 
-// createRuntimeEntry creates a runtime entry based on the detected runtime
-func (ri *RegistryIntegrator) createRuntimeEntry(installResult *InstallationResult) registry.Runtime {
+// runtimeEntry creates a runtime entry based on the detected runtime
+func runtimeEntry(installResult *InstallationResult) registry.Runtime {
 	runtime := registry.Runtime{
 		Kind: installResult.Runtime,
 	}
+	if runtime.Kind == "" {
+		// Nothing recognisable was detected; the launcher is run as is
+		runtime.Kind = "binary"
+	}
 	
 	switch installResult.Runtime {
 	case "node":
@@ -0,0 +1,103 @@
+package install
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"mcp/manager/internal/registry"
+)
+
+type secretMap map[string]map[string]string
+
+func (m secretMap) Retrieve(ref string) (map[string]string, error) { return m[ref], nil }
+func (m secretMap) Store(ref string, v map[string]string) error    { m[ref] = v; return nil }
+
+func TestRegisterServerRecordsRequiredEnv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	ri, err := NewRegistryIntegrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := secretMap{}
+	ri.secrets = secrets
+
+	result := &InstallationResult{
+		Success:      true,
+		EntryCommand: "node",
+		Environment:  map[string]string{"NODE_ENV": "production"},
+		RequiredEnv: mergeRequiredEnv(
+			[]registry.RequiredEnv{{Key: "API_URL", Description: "from package metadata"}},
+			[]registry.RequiredEnv{{Key: "API_URL", Description: "Base URL"}, {Key: "API_TOKEN", Secret: true}},
+		),
+	}
+	sv, err := ri.RegisterServer(context.Background(), "demo", result, SrcNpm, "demo", map[string]string{"API_TOKEN": "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sv.Entry.RequiredEnv) != 2 || sv.Entry.RequiredEnv[0].Description != "Base URL" {
+		t.Fatalf("required env = %+v", sv.Entry.RequiredEnv)
+	}
+	if got := sv.Entry.Env["API_TOKEN"]; got != registry.VaultRef(registry.ServerEnvRef("demo"), "API_TOKEN") {
+		t.Fatalf("API_TOKEN = %q", got)
+	}
+	if secrets["env:demo"]["API_TOKEN"] != "tok" {
+		t.Fatalf("secret not stored: %v", secrets)
+	}
+	if got := sv.Entry.MissingEnv(); !reflect.DeepEqual(got, []string{"API_URL"}) {
+		t.Fatalf("missing = %v", got)
+	}
+	if _, ok := result.Environment["API_TOKEN"]; ok {
+		t.Fatal("install result environment modified")
+	}
+
+	// Re-registering keeps values the user already supplied
+	sv, err = ri.RegisterServer(context.Background(), "demo", result, SrcNpm, "demo", map[string]string{"API_URL": "https://api.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sv.Entry.MissingEnv(); len(got) != 0 {
+		t.Fatalf("missing after update = %v (env %v)", got, sv.Entry.Env)
+	}
+	stored, err := ri.GetServerEntry("demo")
+	if err != nil || stored.Entry.Env["API_TOKEN"] == "" || stored.Entry.Env["API_URL"] == "" {
+		t.Fatalf("registry entry = %+v, %v", stored, err)
+	}
+}
+
+func TestRegisterServerUsesInstallerEntry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	ri, err := NewRegistryIntegrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ri.secrets = secretMap{}
+
+	result := &InstallationResult{
+		Success:      true,
+		EntryCommand: "/srv/demo/bin/demo",
+		Environment:  map[string]string{"NODE_ENV": "production"},
+	}
+	result.ServerEntry = NewServerEntry("demo", result, SrcURL, "https://example.com/demo.tar.gz")
+	if got := result.ServerEntry.Runtime.Kind; got != "binary" {
+		t.Fatalf("runtime kind = %q", got)
+	}
+	result.ServerEntry.Entry.Transport = "http"
+	result.RequiredEnv = []registry.RequiredEnv{{Key: "API_TOKEN", Secret: true}}
+
+	sv, err := ri.RegisterServer(context.Background(), "demo", result, SrcURL, "https://example.com/demo.tar.gz", map[string]string{"API_TOKEN": "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sv.Entry.Transport != "http" || sv.Entry.Command != "/srv/demo/bin/demo" || sv.Source.Type != "url" {
+		t.Fatalf("registered entry = %+v", sv)
+	}
+	if len(sv.Entry.RequiredEnv) != 1 || sv.Entry.Env["API_TOKEN"] == "" {
+		t.Fatalf("required env not applied: %+v", sv.Entry)
+	}
+	if _, ok := result.ServerEntry.Entry.Env["API_TOKEN"]; ok {
+		t.Fatal("installer entry modified")
+	}
+}
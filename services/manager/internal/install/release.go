@@ -0,0 +1,245 @@
+package install
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"mcp/manager/internal/paths"
+)
+
+// defaultGitHubAPI is where GitHub release references are resolved.
+const defaultGitHubAPI = "https://api.github.com"
+
+// releaseRef names a GitHub release, written as github:owner/repo@tag or
+// owner/repo@tag. A missing tag or "latest" means the latest release.
+type releaseRef struct {
+	Owner, Repo, Tag string
+}
+
+// parseReleaseRef reports whether uri is a GitHub release reference rather
+// than a URL.
+func parseReleaseRef(uri string) (releaseRef, bool) {
+	rest, prefixed := strings.CutPrefix(uri, "github:")
+	if !prefixed && (strings.Contains(uri, "://") || !strings.Contains(uri, "@")) {
+		return releaseRef{}, false
+	}
+	repo, tag, _ := strings.Cut(rest, "@")
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return releaseRef{}, false
+	}
+	if tag == "" {
+		tag = "latest"
+	}
+	return releaseRef{Owner: owner, Repo: name, Tag: tag}, true
+}
+
+func (r releaseRef) String() string {
+	return fmt.Sprintf("%s/%s@%s", r.Owner, r.Repo, r.Tag)
+}
+
+// validateArchiveSource accepts what URLInstaller can install from: an http
+// or https URL, or a GitHub release reference.
+func validateArchiveSource(uri string) error {
+	if _, ok := parseReleaseRef(uri); ok {
+		return nil
+	}
+	return validateArchiveURL(uri)
+}
+
+// releaseAsset is one downloadable file of a GitHub release.
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// resolveRelease looks up ref's release and returns the download URL of the
+// asset built for this OS and architecture.
+func (u *URLInstaller) resolveRelease(ctx context.Context, ref releaseRef, authHeader string) (string, error) {
+	api := u.githubAPI
+	if api == "" {
+		api = defaultGitHubAPI
+	}
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", api, ref.Owner, ref.Repo, ref.Tag)
+	if ref.Tag == "latest" {
+		endpoint = fmt.Sprintf("%s/repos/%s/%s/releases/latest", api, ref.Owner, ref.Repo)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("release %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("release %s: unexpected status %s", ref, resp.Status)
+	}
+	var release struct {
+		TagName string         `json:"tag_name"`
+		Assets  []releaseAsset `json:"assets"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&release); err != nil {
+		return "", fmt.Errorf("release %s: %w", ref, err)
+	}
+
+	asset, err := pickAsset(release.Assets, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", fmt.Errorf("release %s: %w", ref, err)
+	}
+	logf(u.logger, "Release %s/%s %s: using asset %s", ref.Owner, ref.Repo, release.TagName, asset.Name)
+	return asset.URL, nil
+}
+
+// Names by which release assets commonly spell each OS and architecture.
+var (
+	osAliases = map[string][]string{
+		"linux":   {"linux"},
+		"darwin":  {"darwin", "macos", "apple", "osx"},
+		"windows": {"windows", "win64", "win32"},
+	}
+	archAliases = map[string][]string{
+		"amd64": {"amd64", "x86_64", "x64"},
+		"arm64": {"arm64", "aarch64"},
+		"386":   {"386", "i686"},
+	}
+)
+
+// skippedAssetSuffixes mark checksums, signatures, packages and archives the
+// installer can't unpack, which are never taken for the server itself.
+var skippedAssetSuffixes = []string{
+	".sha256", ".sha256sum", ".sha512", ".md5", ".sig", ".asc", ".pem", ".sbom",
+	".json", ".txt", ".deb", ".rpm", ".apk", ".msi", ".pkg", ".dmg",
+	".gz", ".xz", ".bz2", ".zst", ".7z", ".rar",
+}
+
+// pickAsset chooses the asset built for goos and goarch, preferring a
+// tar.gz, then a zip, then a raw binary: any other asset without a skipped
+// suffix, dots in a version number and all. A darwin "universal" build
+// matches any architecture.
+func pickAsset(assets []releaseAsset, goos, goarch string) (releaseAsset, error) {
+	containsAny := func(name string, words []string) bool {
+		for _, w := range words {
+			if strings.Contains(name, w) {
+				return true
+			}
+		}
+		return false
+	}
+	rank := func(name string) int {
+		switch {
+		case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+			return 0
+		case strings.HasSuffix(name, ".zip"):
+			return 1
+		case slices.ContainsFunc(skippedAssetSuffixes, func(suffix string) bool { return strings.HasSuffix(name, suffix) }):
+			return -1
+		}
+		return 2
+	}
+
+	best, bestRank := releaseAsset{}, -1
+	var names []string
+	for _, a := range assets {
+		names = append(names, a.Name)
+		name := strings.ToLower(a.Name)
+		if !containsAny(name, osAliases[goos]) {
+			continue
+		}
+		universal := goos == "darwin" && strings.Contains(name, "universal")
+		if !universal && !containsAny(name, archAliases[goarch]) {
+			continue
+		}
+		if r := rank(name); r >= 0 && (bestRank < 0 || r < bestRank) {
+			best, bestRank = a, r
+		}
+	}
+	if bestRank < 0 {
+		return releaseAsset{}, fmt.Errorf("no asset for %s/%s among %s", goos, goarch, strings.Join(names, ", "))
+	}
+	return best, nil
+}
+
+// isNativeExecutable reports whether header, the first bytes of a file,
+// starts an ELF, Mach-O or PE executable or a script with a #! line.
+func isNativeExecutable(header []byte) bool {
+	for _, magic := range [][]byte{
+		[]byte("\x7fELF"),
+		{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf}, // Mach-O
+		{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+		{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+		[]byte("MZ"),
+		[]byte("#!"),
+	} {
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileHeader returns up to the first 4 bytes of the file at path.
+func fileHeader(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(f, header)
+	return header[:n]
+}
+
+// installBinary moves a downloaded raw binary into dest as name, executable.
+func installBinary(download, dest, name string) error {
+	target := filepath.Join(dest, name)
+	if err := os.Rename(download, target); err != nil {
+		return err
+	}
+	return os.Chmod(target, paths.Mask(0o755))
+}
+
+// binaryName is the file name a raw binary for slug is installed under.
+func binaryName(slug string) string {
+	if runtime.GOOS == "windows" {
+		return slug + ".exe"
+	}
+	return slug
+}
+
+// markExecutables makes the native executables at the top of dir
+// executable, as zip archives made on Windows carry no permission bits.
+func markExecutables(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		full := filepath.Join(dir, entry.Name())
+		if isExecutable(full) || !isNativeExecutable(fileHeader(full)) {
+			continue
+		}
+		if err := os.Chmod(full, paths.Mask(0o755)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,9 +3,36 @@ package install
 import (
     "bytes"
     "context"
+    "os"
     "os/exec"
+    "sync"
 )
 
+var (
+    caBundleMu sync.RWMutex
+    caBundle   string
+)
+
+// SetCABundle makes every installer subprocess trust the PEM certificates in
+// path, for registries and git hosts behind a private CA. An empty path clears it.
+func SetCABundle(path string) {
+    caBundleMu.Lock()
+    defer caBundleMu.Unlock()
+    caBundle = path
+}
+
+// caEnv returns the environment that points npm, pip and git at the CA bundle.
+func caEnv() []string {
+    caBundleMu.RLock()
+    defer caBundleMu.RUnlock()
+    if caBundle == "" { return nil }
+    return []string{
+        "NODE_EXTRA_CA_CERTS=" + caBundle,
+        "PIP_CERT=" + caBundle,
+        "GIT_SSL_CAINFO=" + caBundle,
+    }
+}
+
 type Runner interface {
     Run(ctx context.Context, name string, args ...string) (stdout string, stderr string, err error)
 }
@@ -14,6 +41,9 @@ type ExecRunner struct{}
 
 func (ExecRunner) Run(ctx context.Context, name string, args ...string) (string, string, error) {
     cmd := exec.CommandContext(ctx, name, args...)
+    if env := caEnv(); env != nil {
+        cmd.Env = append(os.Environ(), env...)
+    }
     var out, errb bytes.Buffer
     cmd.Stdout = &out
     cmd.Stderr = &errb
@@ -21,3 +51,18 @@ func (ExecRunner) Run(ctx context.Context, name string, args ...string) (string,
     return out.String(), errb.String(), err
 }
 
+// RunStream is Run with every line of stdout and stderr also passed to onLine as it arrives.
+func (ExecRunner) RunStream(ctx context.Context, onLine func(line string), name string, args ...string) (string, string, error) {
+    cmd := exec.CommandContext(ctx, name, args...)
+    if env := caEnv(); env != nil {
+        cmd.Env = append(os.Environ(), env...)
+    }
+    out := &lineWriter{onLine: onLine}
+    errw := &lineWriter{onLine: onLine}
+    cmd.Stdout = out
+    cmd.Stderr = errw
+    err := cmd.Run()
+    out.flush()
+    errw.flush()
+    return out.buf.String(), errw.buf.String(), err
+}
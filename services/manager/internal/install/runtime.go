@@ -0,0 +1,184 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exactVersionPattern matches plain version pins such as "3.11", "v20" or "18.19.0".
+// Range expressions (">=18", "^20") are not pins and are left to the caller.
+var exactVersionPattern = regexp.MustCompile(`^v?\d+(\.\d+){0,2}$`)
+
+// RuntimePin describes how an installer should pick its language interpreter
+type RuntimePin struct {
+	Runtime string // "python" or "node"
+	Path    string // explicit interpreter path, used as-is when set
+	Version string // version to resolve through pyenv, nvm, asdf or PATH
+}
+
+// isExactVersion reports whether v is a plain version that can be resolved to an interpreter
+func isExactVersion(v string) bool {
+	return exactVersionPattern.MatchString(strings.TrimSpace(v))
+}
+
+// versionMatches reports whether the output of `<interpreter> --version` satisfies want.
+// "3.11" matches "Python 3.11.4" but not "Python 3.1.2".
+func versionMatches(output, want string) bool {
+	want = strings.TrimPrefix(strings.TrimSpace(want), "v")
+	for _, field := range strings.Fields(output) {
+		got := strings.TrimPrefix(field, "v")
+		if got == want || strings.HasPrefix(got, want+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRuntimePin returns the interpreter that satisfies pin. An explicit path
+// wins; otherwise version managers are consulted before falling back to PATH.
+// The returned error explains what was tried so the install log is actionable.
+func resolveRuntimePin(ctx context.Context, runner Runner, logger Logger, pin RuntimePin) (string, error) {
+	if pin.Path != "" {
+		stdout, stderr, err := runner.Run(ctx, pin.Path, "--version")
+		if err != nil {
+			return "", fmt.Errorf("pinned %s interpreter %s is not usable: %w", pin.Runtime, pin.Path, err)
+		}
+		// python2 reports its version on stderr
+		output := strings.TrimSpace(stdout + " " + stderr)
+		if pin.Version != "" && !versionMatches(output, pin.Version) {
+			return "", fmt.Errorf("pinned %s interpreter %s reports %q, want version %s", pin.Runtime, pin.Path, output, pin.Version)
+		}
+		logf(logger, "Using pinned %s interpreter %s (%s)", pin.Runtime, pin.Path, output)
+		return pin.Path, nil
+	}
+
+	var tried []string
+	for _, candidate := range managedInterpreters(ctx, runner, pin) {
+		tried = append(tried, candidate.source)
+		stdout, stderr, err := runner.Run(ctx, candidate.path, "--version")
+		if err != nil {
+			continue
+		}
+		output := strings.TrimSpace(stdout + " " + stderr)
+		if !versionMatches(output, pin.Version) {
+			logf(logger, "%s interpreter %s reports %q, want %s", candidate.source, candidate.path, output, pin.Version)
+			continue
+		}
+		logf(logger, "Resolved %s %s via %s: %s", pin.Runtime, pin.Version, candidate.source, candidate.path)
+		return candidate.path, nil
+	}
+
+	return "", fmt.Errorf("%s version %s could not be satisfied (tried: %s)", pin.Runtime, pin.Version, strings.Join(tried, ", "))
+}
+
+type interpreterCandidate struct {
+	source string
+	path   string
+}
+
+// managedInterpreters lists interpreter candidates for pin.Version in lookup order:
+// version managers first, then the plain executables on PATH.
+func managedInterpreters(ctx context.Context, runner Runner, pin RuntimePin) []interpreterCandidate {
+	version := strings.TrimPrefix(strings.TrimSpace(pin.Version), "v")
+	var candidates []interpreterCandidate
+
+	switch pin.Runtime {
+	case "python":
+		if stdout, _, err := runner.Run(ctx, "pyenv", "prefix", version); err == nil {
+			if prefix := firstLine(stdout); prefix != "" {
+				candidates = append(candidates, interpreterCandidate{"pyenv", filepath.Join(prefix, "bin", "python")})
+			}
+		}
+		if stdout, _, err := runner.Run(ctx, "asdf", "where", "python", version); err == nil {
+			if dir := firstLine(stdout); dir != "" {
+				candidates = append(candidates, interpreterCandidate{"asdf", filepath.Join(dir, "bin", "python")})
+			}
+		}
+		candidates = append(candidates, interpreterCandidate{"PATH", "python" + version})
+		candidates = append(candidates, interpreterCandidate{"PATH", "python3"})
+	case "node":
+		if node := nvmNode(version); node != "" {
+			candidates = append(candidates, interpreterCandidate{"nvm", node})
+		}
+		if stdout, _, err := runner.Run(ctx, "asdf", "where", "nodejs", version); err == nil {
+			if dir := firstLine(stdout); dir != "" {
+				candidates = append(candidates, interpreterCandidate{"asdf", filepath.Join(dir, "bin", "node")})
+			}
+		}
+		candidates = append(candidates, interpreterCandidate{"PATH", "node"})
+	}
+
+	return candidates
+}
+
+// nvmNode returns the newest node binary under NVM_DIR (or ~/.nvm) matching version
+func nvmNode(version string) string {
+	nvmDir := os.Getenv("NVM_DIR")
+	if nvmDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		nvmDir = filepath.Join(home, ".nvm")
+	}
+
+	entries, err := os.ReadDir(filepath.Join(nvmDir, "versions", "node"))
+	if err != nil {
+		return ""
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := strings.TrimPrefix(entry.Name(), "v")
+		if name == version || strings.HasPrefix(name, version+".") {
+			matches = append(matches, entry.Name())
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return compareVersions(matches[i], matches[j]) < 0
+	})
+	node := filepath.Join(nvmDir, "versions", "node", matches[len(matches)-1], "bin", "node")
+	if _, err := os.Stat(node); err != nil {
+		return ""
+	}
+	return node
+}
+
+// compareVersions compares dotted numeric versions component by component
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,83 @@
+package install
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveRuntimePin(t *testing.T) {
+	nvmDir := t.TempDir()
+	for _, v := range []string{"v18.19.0", "v20.9.0", "v20.11.1"} {
+		bin := filepath.Join(nvmDir, "versions", "node", v, "bin")
+		if err := os.MkdirAll(bin, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(bin, "node"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("NVM_DIR", nvmDir)
+
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		switch {
+		case name == "pyenv" && len(args) == 2 && args[1] == "3.11":
+			return "/opt/pyenv/versions/3.11.7\n", "", nil
+		case name == "/opt/pyenv/versions/3.11.7/bin/python":
+			return "Python 3.11.7", "", nil
+		case name == "/usr/bin/python3.10":
+			return "Python 3.10.2", "", nil
+		case name == "python3":
+			return "Python 3.12.1", "", nil
+		case strings.HasPrefix(name, nvmDir):
+			return "v" + filepath.Base(filepath.Dir(filepath.Dir(name)))[1:], "", nil
+		case name == "node":
+			return "v21.0.0", "", nil
+		}
+		return "", "", fmt.Errorf("command not found: %s", name)
+	}}
+
+	tests := []struct {
+		name    string
+		pin     RuntimePin
+		want    string
+		wantErr string
+	}{
+		{"pyenv version", RuntimePin{Runtime: "python", Version: "3.11"}, "/opt/pyenv/versions/3.11.7/bin/python", ""},
+		{"explicit path", RuntimePin{Runtime: "python", Path: "/usr/bin/python3.10"}, "/usr/bin/python3.10", ""},
+		{"explicit path wrong version", RuntimePin{Runtime: "python", Path: "/usr/bin/python3.10", Version: "3.11"}, "", "want version 3.11"},
+		{"missing explicit path", RuntimePin{Runtime: "python", Path: "/nope/python"}, "", "is not usable"},
+		{"unsatisfiable version", RuntimePin{Runtime: "python", Version: "3.8"}, "", "python version 3.8 could not be satisfied"},
+		{"nvm newest match", RuntimePin{Runtime: "node", Version: "20"}, filepath.Join(nvmDir, "versions", "node", "v20.11.1", "bin", "node"), ""},
+		{"node from PATH", RuntimePin{Runtime: "node", Version: "v21"}, "node", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRuntimePin(context.Background(), runner, testLogger{t}, tt.pin)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsExactVersion(t *testing.T) {
+	for v, want := range map[string]bool{"3.11": true, "v20": true, "18.19.0": true, ">=18": false, "^20.1": false, "": false} {
+		if got := isExactVersion(v); got != want {
+			t.Errorf("isExactVersion(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
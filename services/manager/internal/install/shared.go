@@ -0,0 +1,232 @@
+package install
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"mcp/manager/internal/paths"
+)
+
+// Runtime modes reported in install results
+const (
+	RuntimeIsolated = "isolated"
+	RuntimeShared   = "shared"
+)
+
+// sharedLocks serialises installs into each shared runtime directory
+var sharedLocks sync.Map
+
+// lockSharedRuntime locks dir for one install and returns the unlock function.
+func lockSharedRuntime(dir string) func() {
+	mu, _ := sharedLocks.LoadOrStore(dir, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// sharedRuntimeDir returns the shared runtime directory for name, e.g.
+// "python-3.11" or "node-npm", creating it if needed.
+func sharedRuntimeDir(name string) (string, error) {
+	base, err := paths.RuntimesDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, name)
+	if err := paths.MkdirAll(dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+var pythonVersionRe = regexp.MustCompile(`Python (\d+)\.(\d+)`)
+
+// pythonRuntimeName names the shared venv for an interpreter by its minor
+// version, as a venv only works with the Python it was created from.
+func pythonRuntimeName(versionOutput string) (string, bool) {
+	m := pythonVersionRe.FindStringSubmatch(versionOutput)
+	if m == nil {
+		return "", false
+	}
+	return fmt.Sprintf("python-%s.%s", m[1], m[2]), true
+}
+
+// normalizePipName folds a distribution name the way pip compares them
+func normalizePipName(name string) string {
+	return strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name))
+}
+
+// parseFreeze maps the packages in "pip list --format=freeze" output to
+// their versions.
+func parseFreeze(out string) map[string]string {
+	versions := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		if name, version, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=="); ok {
+			versions[normalizePipName(name)] = version
+		}
+	}
+	return versions
+}
+
+// pipVersionChanges returns the packages a "pip install --dry-run" would
+// move to another version than the one in installed.
+func pipVersionChanges(dryRun string, installed map[string]string) []string {
+	var changes []string
+	scanner := bufio.NewScanner(strings.NewReader(dryRun))
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "Would install ")
+		if !ok {
+			continue
+		}
+		for _, dist := range strings.Fields(line) {
+			i := strings.LastIndex(dist, "-")
+			if i <= 0 {
+				continue
+			}
+			name, version := normalizePipName(dist[:i]), dist[i+1:]
+			if have, ok := installed[name]; ok && have != version {
+				changes = append(changes, fmt.Sprintf("%s %s -> %s", name, have, version))
+			}
+		}
+	}
+	return changes
+}
+
+// sharedPipConflicts reports why options can't be installed into the shared
+// venv behind pipPath without changing what the servers already there use.
+func (p *PipInstaller) sharedPipConflicts(ctx context.Context, options PipInstallOptions, pipPath string) []string {
+	if options.ForceReinstall || len(options.PostInstall) > 0 {
+		return []string{"forceReinstall and postInstall would change the shared environment"}
+	}
+	freeze, _, err := p.runner.Run(ctx, pipPath, "list", "--format=freeze")
+	if err != nil {
+		return []string{fmt.Sprintf("could not list shared packages: %v", err)}
+	}
+	dryRun, stderr, err := p.runner.Run(ctx, pipPath, append(pipInstallArgs(options), "--dry-run")...)
+	if err != nil {
+		return []string{fmt.Sprintf("dependency resolution failed: %v %s", err, strings.TrimSpace(stderr))}
+	}
+	return pipVersionChanges(dryRun, parseFreeze(freeze))
+}
+
+// sharedVenv returns the shared venv to install options into, locked until
+// unlock is called, or "" when the server needs a venv of its own.
+func (p *PipInstaller) sharedVenv(ctx context.Context, options PipInstallOptions, pythonExec string) (venvPath string, unlock func()) {
+	if !options.SharedRuntime {
+		return "", nil
+	}
+	version, _, err := p.runner.Run(ctx, pythonExec, "--version")
+	name, ok := pythonRuntimeName(version)
+	if err != nil || !ok {
+		logf(p.logger, "Using an isolated venv: cannot tell the version of %s", pythonExec)
+		return "", nil
+	}
+	dir, err := sharedRuntimeDir(name)
+	if err != nil {
+		logf(p.logger, "Using an isolated venv: %v", err)
+		return "", nil
+	}
+
+	unlock = lockSharedRuntime(dir)
+	venvPath = filepath.Join(dir, "venv")
+	if _, err := os.Stat(venvPath); os.IsNotExist(err) {
+		if _, err := p.createVirtualEnvironment(ctx, dir, pythonExec); err != nil {
+			unlock()
+			logf(p.logger, "Using an isolated venv: %v", err)
+			return "", nil
+		}
+	}
+	_, pipPath, err := p.getVenvExecutables(venvPath)
+	if err == nil {
+		if conflicts := p.sharedPipConflicts(ctx, options, pipPath); len(conflicts) > 0 {
+			err = fmt.Errorf("sharing would conflict: %s", strings.Join(conflicts, "; "))
+		}
+	}
+	if err != nil {
+		unlock()
+		logf(p.logger, "Using an isolated venv: %v", err)
+		return "", nil
+	}
+	logf(p.logger, "Using shared venv %s", venvPath)
+	return venvPath, unlock
+}
+
+// sharedNPMConflicts reports why options can't be added to the shared
+// node_modules in dir: another server there depends on a different version
+// of the same package, or the install runs commands of its own.
+func sharedNPMConflicts(options NPMInstallOptions, dir string) []string {
+	if options.Global || len(options.PostInstall) > 0 {
+		return []string{"global installs and postInstall would change the shared environment"}
+	}
+	if options.Token != "" || options.Username != "" {
+		return []string{"registry credentials stay with the server's own runtime"}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("could not read shared package.json: %v", err)}
+	}
+	var manifest struct {
+		Dependencies map[string]string `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return []string{fmt.Sprintf("invalid shared package.json: %v", err)}
+	}
+	have, ok := manifest.Dependencies[options.Package]
+	if !ok {
+		return nil
+	}
+	want := options.Version
+	if want == "" || want == "latest" {
+		// Whatever "latest" is now may not be what the other servers got
+		return []string{fmt.Sprintf("%s is already shared at %s; give that version to share it", options.Package, have)}
+	}
+	if strings.TrimLeft(have, "^~=") != strings.TrimLeft(want, "^~=") {
+		return []string{fmt.Sprintf("%s is shared at %s, not %s", options.Package, have, want)}
+	}
+	return nil
+}
+
+// sharedModules returns the shared directory to add options' package to,
+// locked until unlock is called, or "" when the server needs its own.
+func (n *NPMInstaller) sharedModules(options NPMInstallOptions, packageManager string) (dir string, unlock func()) {
+	if !options.SharedRuntime {
+		return "", nil
+	}
+	dir, err := sharedRuntimeDir("node-" + packageManager)
+	if err != nil {
+		logf(n.logger, "Using an isolated runtime: %v", err)
+		return "", nil
+	}
+
+	unlock = lockSharedRuntime(dir)
+	if conflicts := sharedNPMConflicts(options, dir); len(conflicts) > 0 {
+		unlock()
+		logf(n.logger, "Using an isolated runtime, sharing would conflict: %s", strings.Join(conflicts, "; "))
+		return "", nil
+	}
+	manifest := filepath.Join(dir, "package.json")
+	if _, err := os.Stat(manifest); os.IsNotExist(err) {
+		data, _ := json.MarshalIndent(map[string]interface{}{
+			"name":        "mcp-shared-runtime",
+			"version":     "1.0.0",
+			"private":     true,
+			"description": "Dependencies shared by MCP servers",
+		}, "", "  ")
+		if err := paths.WriteFile(manifest, data, paths.FileMode()); err != nil {
+			unlock()
+			logf(n.logger, "Using an isolated runtime: %v", err)
+			return "", nil
+		}
+	}
+	logf(n.logger, "Using shared runtime %s", dir)
+	return dir, unlock
+}
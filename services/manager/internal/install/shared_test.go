@@ -0,0 +1,130 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPipVersionChanges(t *testing.T) {
+	installed := parseFreeze("mcp==1.2.0\nPydantic_Core==2.14.1\nhttpx==0.27.0\n")
+	dryRun := "Collecting weather-mcp\nWould install anyio-4.2.0 httpx-0.27.0 pydantic-core-2.16.0 weather_mcp-0.3.1\n"
+	changes := pipVersionChanges(dryRun, installed)
+	if len(changes) != 1 || changes[0] != "pydantic-core 2.14.1 -> 2.16.0" {
+		t.Fatalf("changes = %v", changes)
+	}
+}
+
+func TestSharedNPMConflicts(t *testing.T) {
+	dir := t.TempDir()
+	if c := sharedNPMConflicts(NPMInstallOptions{Package: "mcp-a"}, dir); c != nil {
+		t.Fatalf("empty shared runtime: %v", c)
+	}
+	manifest := `{"dependencies": {"mcp-a": "^1.2.0"}}`
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		options  NPMInstallOptions
+		conflict bool
+	}{
+		{NPMInstallOptions{Package: "mcp-b"}, false},
+		{NPMInstallOptions{Package: "mcp-a", Version: "1.2.0"}, false},
+		{NPMInstallOptions{Package: "mcp-a", Version: "2.0.0"}, true},
+		{NPMInstallOptions{Package: "mcp-a"}, true},
+		{NPMInstallOptions{Package: "mcp-b", Token: "t"}, true},
+		{NPMInstallOptions{Package: "mcp-b", PostInstall: []string{"make"}}, true},
+	}
+	for _, c := range cases {
+		if got := sharedNPMConflicts(c.options, dir); (len(got) > 0) != c.conflict {
+			t.Errorf("%+v: conflicts %v, want conflict %v", c.options, got, c.conflict)
+		}
+	}
+}
+
+func TestSharedVenv(t *testing.T) {
+	t.Setenv("MCP_HOME", t.TempDir())
+
+	dryRun := "Would install mcp-1.2.0 weather-mcp-0.1.0"
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		switch {
+		case len(args) == 1 && args[0] == "--version":
+			return "Python 3.11.4\n", "", nil
+		case len(args) == 3 && args[1] == "venv":
+			// Stand in for python -m venv
+			bin := filepath.Join(args[2], "bin")
+			if err := os.MkdirAll(bin, 0o755); err != nil {
+				return "", "", err
+			}
+			return "", "", os.WriteFile(filepath.Join(bin, "python"), nil, 0o755)
+		case len(args) > 0 && args[0] == "list":
+			return "mcp==1.2.0\n", "", nil
+		case strings.Contains(strings.Join(args, " "), "--dry-run"):
+			return dryRun, "", nil
+		}
+		return "", "", nil
+	}}
+	p := NewPipInstaller(runner, testLogger{t})
+	options := PipInstallOptions{Package: "weather-mcp", UseVenv: true, SharedRuntime: true}
+
+	venv, unlock := p.sharedVenv(context.Background(), options, "python3")
+	if filepath.Base(filepath.Dir(venv)) != "python-3.11" {
+		t.Fatalf("shared venv = %q", venv)
+	}
+	unlock()
+
+	// A different mcp would break the servers already sharing the venv
+	dryRun = "Would install mcp-1.3.0 other-mcp-0.1.0"
+	if venv, _ := p.sharedVenv(context.Background(), options, "python3"); venv != "" {
+		t.Fatalf("conflicting install shared %q", venv)
+	}
+	options.SharedRuntime = false
+	if venv, _ := p.sharedVenv(context.Background(), options, "python3"); venv != "" {
+		t.Fatalf("sharing not requested but got %q", venv)
+	}
+}
+
+func TestSharedVenvInstallFallsBack(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("MCP_HOME", root)
+
+	var installs []string
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		joined := strings.Join(args, " ")
+		switch {
+		case len(args) == 1 && args[0] == "--version":
+			return "Python 3.11.4\n", "", nil
+		case len(args) == 3 && args[1] == "venv":
+			bin := filepath.Join(args[2], "bin")
+			if err := os.MkdirAll(bin, 0o755); err != nil {
+				return "", "", err
+			}
+			return "", "", os.WriteFile(filepath.Join(bin, "python"), nil, 0o755)
+		case len(args) > 0 && args[0] == "list":
+			return "mcp==1.2.0\n", "", nil
+		case strings.Contains(joined, "--dry-run"):
+			return "Would install weather-mcp-0.1.0", "", nil
+		case len(args) > 0 && args[0] == "install" && !strings.Contains(joined, "--upgrade pip"):
+			installs = append(installs, name)
+			if !strings.HasPrefix(name, filepath.Join(root, "servers")) {
+				return "", "error: failed building wheel for weather-mcp", errors.New("exit status 1")
+			}
+		}
+		return "", "", nil
+	}}
+	p := NewPipInstaller(runner, testLogger{t})
+	result, err := p.Install(context.Background(), "weather", PipInstallOptions{Package: "weather-mcp", UseVenv: true, SharedRuntime: true})
+	if err != nil || result.Error != "" {
+		t.Fatalf("install: %v %s", err, result.Error)
+	}
+	if len(installs) != 2 {
+		t.Fatalf("pip install ran with %q, want the shared venv then the server's own", installs)
+	}
+	runtimeDir := filepath.Join(root, "servers", "weather", "runtime")
+	if result.RuntimeMode != RuntimeIsolated || result.RuntimePath != runtimeDir || result.VenvPath != filepath.Join(runtimeDir, "venv") {
+		t.Fatalf("result %s %s %s", result.RuntimeMode, result.RuntimePath, result.VenvPath)
+	}
+}
@@ -0,0 +1,175 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Build prerequisites for native npm addons and Python C extensions, as
+// reported in ToolStatus.Name and ToolchainError.Missing.
+const (
+	ToolCCompiler     = "C compiler"
+	ToolMake          = "make"
+	ToolPythonHeaders = "Python development headers"
+	ToolPython        = "Python 3 (for node-gyp)"
+)
+
+// pythonHeadersScript exits non-zero when Python.h is not installed.
+const pythonHeadersScript = "import os, sys, sysconfig; sys.exit(not os.path.exists(os.path.join(sysconfig.get_paths()['include'], 'Python.h')))"
+
+// ToolchainError reports that an install needs a native build and the tools
+// for it are missing. Its message says what to install instead of carrying
+// the compiler output.
+type ToolchainError struct {
+	Reason  string   // why a native build is needed
+	Missing []string // the ToolX prerequisites that are absent
+}
+
+func (e *ToolchainError) Error() string {
+	return fmt.Sprintf("%s needs build tools, missing %s; %s", e.Reason, strings.Join(e.Missing, ", "), BuildToolsHint())
+}
+
+// BuildToolsHint tells how to install the build prerequisites on this platform.
+func BuildToolsHint() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "install the Xcode command line tools with `xcode-select --install`"
+	case "windows":
+		return "install the Visual Studio Build Tools with the \"Desktop development with C++\" workload"
+	default:
+		return "install build-essential and python3-dev (Debian/Ubuntu) or gcc, gcc-c++, make and python3-devel (Fedora/RHEL)"
+	}
+}
+
+// DetectBuildTools probes the prerequisites of native builds. None of them are
+// required: most servers install without compiling anything.
+func DetectBuildTools(ctx context.Context, runner Runner) []ToolStatus {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+
+	compiler := ToolStatus{Name: ToolCCompiler}
+	for _, cc := range []string{"cc", "gcc", "clang"} {
+		if stdout, _, err := runner.Run(ctx, cc, "--version"); err == nil {
+			compiler.Found = true
+			compiler.Command = cc
+			compiler.Version = firstLine(stdout)
+			break
+		}
+	}
+
+	mk := probeTool(ctx, runner, ToolMake, "make", "--version")
+
+	headers := ToolStatus{Name: ToolPythonHeaders}
+	if python, ok := detectPython(ctx, runner); ok {
+		if _, _, err := runner.Run(ctx, python, "-c", pythonHeadersScript); err == nil {
+			headers.Found = true
+			headers.Command = python
+		}
+	}
+	return []ToolStatus{compiler, mk, headers}
+}
+
+// preflightToolchain fails early when the tree in dir will be compiled and the
+// tools for that are missing. python selects the Python header check.
+func preflightToolchain(ctx context.Context, runner Runner, dir string, python bool) error {
+	reason := nativeBuildReason(dir)
+	if reason == "" {
+		return nil
+	}
+
+	var missing []string
+	for _, tool := range DetectBuildTools(ctx, runner) {
+		if tool.Found || (tool.Name == ToolPythonHeaders && !python) {
+			continue
+		}
+		missing = append(missing, tool.Name)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return &ToolchainError{Reason: reason, Missing: missing}
+}
+
+// errStopWalk ends nativeBuildReason's walk at the first hit.
+var errStopWalk = errors.New("stop")
+
+// nativeBuildReason returns why the source tree in dir needs a compiler, or ""
+// when it looks like plain JavaScript or Python. Dependency and VCS
+// directories are not searched.
+func nativeBuildReason(dir string) string {
+	if _, err := os.Stat(filepath.Join(dir, "binding.gyp")); err == nil {
+		return "native addon (binding.gyp)"
+	}
+
+	var reason string
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			switch d.Name() {
+			case "node_modules", ".git", "venv", ".venv", "__pycache__":
+				return filepath.SkipDir
+			}
+			if rel, _ := filepath.Rel(dir, path); strings.Count(rel, string(filepath.Separator)) >= 3 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(d.Name()) {
+		case ".c", ".cc", ".cpp", ".pyx":
+			reason = "C extension sources (" + d.Name() + ")"
+			return errStopWalk
+		}
+		return nil
+	})
+	return reason
+}
+
+// toolchainSignatures map compiler and node-gyp error output to the missing
+// prerequisite. A compile that merely failed also prints "command 'gcc'
+// failed", so only the not-found variants count.
+var toolchainSignatures = []struct {
+	match   string
+	missing string
+}{
+	{"Python.h: No such file or directory", ToolPythonHeaders},
+	{"gyp ERR! find Python", ToolPython},
+	{"Can't find Python executable", ToolPython},
+	{"make: not found", ToolMake},
+	{"not found: make", ToolMake},
+	{"gyp ERR! find VS", ToolCCompiler},
+	{"Microsoft Visual C++ 14.0", ToolCCompiler},
+	{"xcrun: error: invalid active developer path", ToolCCompiler},
+	{"unable to execute 'gcc'", ToolCCompiler},
+	{"command 'gcc' failed: No such file or directory", ToolCCompiler},
+	{"command 'cc' failed: No such file or directory", ToolCCompiler},
+	{"gcc: not found", ToolCCompiler},
+	{"cc: not found", ToolCCompiler},
+	{"g++: not found", ToolCCompiler},
+	{"not found: g++", ToolCCompiler},
+	{"linux-gnu-gcc' failed: No such file or directory", ToolCCompiler},
+}
+
+// toolchainFailure recognises a failed install that was caused by missing
+// build tools and returns a ToolchainError for it, or nil.
+func toolchainFailure(output string) error {
+	var missing []string
+	for _, sig := range toolchainSignatures {
+		if strings.Contains(output, sig.match) && !slices.Contains(missing, sig.missing) {
+			missing = append(missing, sig.missing)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return &ToolchainError{Reason: "native build failed", Missing: missing}
+}
@@ -0,0 +1,90 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNativeBuildReason(t *testing.T) {
+	dir := t.TempDir()
+	if got := nativeBuildReason(dir); got != "" {
+		t.Fatalf("empty tree: %q", got)
+	}
+
+	// Sources inside dependencies don't count
+	os.MkdirAll(filepath.Join(dir, "node_modules", "dep"), 0o755)
+	os.WriteFile(filepath.Join(dir, "node_modules", "dep", "addon.c"), nil, 0o644)
+	if got := nativeBuildReason(dir); got != "" {
+		t.Fatalf("node_modules searched: %q", got)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "src"), 0o755)
+	os.WriteFile(filepath.Join(dir, "src", "speedups.pyx"), nil, 0o644)
+	if got := nativeBuildReason(dir); !strings.Contains(got, "speedups.pyx") {
+		t.Fatalf("got %q", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "binding.gyp"), nil, 0o644)
+	if got := nativeBuildReason(dir); !strings.Contains(got, "binding.gyp") {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestPreflightToolchain(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ext.c"), nil, 0o644)
+
+	// make is present, no compiler, Python without headers
+	r := fakeRunner{f: func(name string, args ...string) error {
+		if name == "make" || (name == "python3" && len(args) == 1) {
+			return nil
+		}
+		return errors.New("not found")
+	}}
+	err := preflightToolchain(context.Background(), r, dir, true)
+	var terr *ToolchainError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected ToolchainError, got %v", err)
+	}
+	if strings.Join(terr.Missing, ",") != ToolCCompiler+","+ToolPythonHeaders {
+		t.Fatalf("missing = %v", terr.Missing)
+	}
+	if !strings.Contains(err.Error(), BuildToolsHint()) {
+		t.Fatalf("no remediation in %q", err)
+	}
+
+	// Node builds don't need the Python headers
+	r = fakeRunner{f: func(name string, args ...string) error {
+		if name == "gcc" || name == "make" {
+			return nil
+		}
+		return errors.New("not found")
+	}}
+	if err := preflightToolchain(context.Background(), r, dir, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestToolchainFailure(t *testing.T) {
+	out := `src/ext.c:1:10: fatal error: Python.h: No such file or directory
+error: command '/usr/bin/gcc' failed with exit code 1`
+	err := toolchainFailure(out)
+	var terr *ToolchainError
+	if !errors.As(err, &terr) || len(terr.Missing) != 1 || terr.Missing[0] != ToolPythonHeaders {
+		t.Fatalf("got %v", err)
+	}
+	if strings.Contains(err.Error(), "ext.c") {
+		t.Fatalf("compiler output leaked into %q", err)
+	}
+
+	if err := toolchainFailure("gyp ERR! find Python\ngyp ERR! stack Error: not found: make"); err == nil || len(err.(*ToolchainError).Missing) != 2 {
+		t.Fatalf("got %v", err)
+	}
+	if err := toolchainFailure("npm ERR! 404 Not Found"); err != nil {
+		t.Fatalf("unrelated failure classified: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package install
+
+import (
+	"context"
+	"syscall"
+)
+
+// ToolStatus reports whether an external tool the installers shell out to is available.
+type ToolStatus struct {
+	Name     string `json:"name"`
+	Found    bool   `json:"found"`
+	Command  string `json:"command,omitempty"` // executable that answered, e.g. python3
+	Version  string `json:"version,omitempty"`
+	Required bool   `json:"required"` // needed by the default git/npm/pip installers
+}
+
+// installerTools are probed with --version in this order. Python is found with
+// the pip installer's own candidate search, so it is listed separately.
+var installerTools = []struct {
+	name     string
+	required bool
+}{
+	{"git", true},
+	{"node", true},
+	{"npm", true},
+	{"yarn", false},
+	{"pnpm", false},
+	{"docker", false},
+}
+
+// DetectTools probes every tool the installers depend on.
+func DetectTools(ctx context.Context, runner Runner) []ToolStatus {
+	if runner == nil {
+		runner = ExecRunner{}
+	}
+	out := make([]ToolStatus, 0, len(installerTools)+1)
+	for _, tool := range installerTools {
+		status := probeTool(ctx, runner, tool.name, tool.name, "--version")
+		status.Required = tool.required
+		out = append(out, status)
+	}
+
+	python := ToolStatus{Name: "python"}
+	if cmd, ok := detectPython(ctx, runner); ok {
+		python = probeTool(ctx, runner, "python", cmd, "--version")
+	}
+	python.Required = true
+	return append(out, python)
+}
+
+// probeTool runs command with args and reports it as tool name, found when
+// the command succeeds, with the first line of its output as the version.
+func probeTool(ctx context.Context, runner Runner, name, command string, args ...string) ToolStatus {
+	status := ToolStatus{Name: name}
+	if stdout, _, err := runner.Run(ctx, command, args...); err == nil {
+		status.Found = true
+		status.Command = command
+		status.Version = firstLine(stdout)
+	}
+	return status
+}
+
+// detectPython finds the interpreter the pip installer would use.
+func detectPython(ctx context.Context, runner Runner) (string, bool) {
+	var discard []string
+	cmd, err := NewPipInstaller(runner, sliceLogger{lines: &discard}).detectPythonExecutable(ctx, "")
+	return cmd, err == nil
+}
+
+// FreeDiskSpace returns the bytes available to the manager on the filesystem holding path.
+func FreeDiskSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,89 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetectTools(t *testing.T) {
+	installed := map[string]bool{"git": true, "npm": true, "python": true}
+	r := fakeRunner{f: func(name string, args ...string) error {
+		if installed[name] {
+			return nil
+		}
+		return errors.New("not found")
+	}}
+
+	got := map[string]ToolStatus{}
+	for _, tool := range DetectTools(context.Background(), r) {
+		got[tool.Name] = tool
+	}
+	if len(got) != len(installerTools)+1 {
+		t.Fatalf("expected %d tools, got %+v", len(installerTools)+1, got)
+	}
+	if g := got["git"]; !g.Found || !g.Required || g.Version != "ok" {
+		t.Fatalf("git: %+v", g)
+	}
+	if n := got["node"]; n.Found || !n.Required {
+		t.Fatalf("node: %+v", n)
+	}
+	if d := got["docker"]; d.Found || d.Required {
+		t.Fatalf("docker: %+v", d)
+	}
+	// python3 is missing, so the fallback candidate answers
+	if p := got["python"]; !p.Found || p.Command != "python" {
+		t.Fatalf("python: %+v", p)
+	}
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	installed := map[string]bool{"git": true, "node": true, "pnpm": true, "python3": true, "go": true}
+	r := fakeRunner{f: func(name string, args ...string) error {
+		// git has no lfs and python3 no venv module, so only pipx could
+		// make pip installs work
+		if name == "git" && args[0] == "lfs" || name == "python3" && len(args) > 1 && args[1] == "venv" {
+			return errors.New("unknown command")
+		}
+		if installed[name] {
+			return nil
+		}
+		return errors.New("not found")
+	}}
+
+	caps := DetectCapabilities(context.Background(), r)
+	sources := map[SourceType]SourceCapability{}
+	for _, src := range caps.Sources {
+		sources[src.Type] = src
+	}
+	if g := sources[SrcGit]; !g.Usable || strings.Join(g.Builds, ",") != "node,go" {
+		t.Fatalf("git: %+v", g)
+	}
+	if n := sources[SrcNpm]; !n.Usable {
+		t.Fatalf("npm via pnpm: %+v", n)
+	}
+	if p := sources[SrcPip]; p.Usable || strings.Join(p.Missing, ",") != "python-venv" {
+		t.Fatalf("pip: %+v", p)
+	}
+	if d := sources[SrcCompose]; d.Usable || d.Missing[0] != "docker-compose" {
+		t.Fatalf("compose: %+v", d)
+	}
+	if u := sources[SrcURL]; !u.Usable {
+		t.Fatalf("url: %+v", u)
+	}
+
+	tools := map[string]ToolStatus{}
+	for _, tool := range caps.Tools {
+		tools[tool.Name] = tool
+	}
+	if l := tools["git-lfs"]; l.Found {
+		t.Fatalf("git-lfs: %+v", l)
+	}
+	if p := tools["pip"]; !p.Found || p.Command != "python3 -m pip" {
+		t.Fatalf("pip: %+v", p)
+	}
+	if g := tools["go"]; !g.Found || g.Version != "ok" {
+		t.Fatalf("go: %+v", g)
+	}
+}
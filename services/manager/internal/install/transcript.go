@@ -0,0 +1,102 @@
+package install
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"mcp/manager/internal/paths"
+)
+
+// TranscriptDir is the directory under a server's directory holding the
+// transcripts of its finished installation jobs. It sits next to install/
+// because reinstalls replace that directory.
+const TranscriptDir = "install-logs"
+
+// ErrTranscriptNotFound is returned by ReadTranscript for a job that left no
+// transcript, or whose transcript was pruned.
+var ErrTranscriptNotFound = errors.New("install transcript not found")
+
+var validJobID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// TranscriptPath returns where the transcript of job jobID for slug is kept.
+func TranscriptPath(slug, jobID string) (string, error) {
+	if !validJobID.MatchString(jobID) {
+		return "", fmt.Errorf("invalid job id %q", jobID)
+	}
+	serversDir, err := paths.ServersDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(serversDir, slug, TranscriptDir, jobID+".json"), nil
+}
+
+// writeTranscript saves the finished job, logs and result included, so it can
+// still be read once the job manager has forgotten it. Failures are only
+// logged: the installation itself is over either way.
+func (job *InstallationJob) writeTranscript() {
+	path, err := TranscriptPath(job.Slug, job.ID)
+	if err != nil {
+		log.Printf("Not saving install transcript of %s: %v", job.ID, err)
+		return
+	}
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err == nil {
+		err = paths.MkdirAll(filepath.Dir(path))
+	}
+	if err == nil {
+		err = paths.WriteFile(path, data, paths.FileMode())
+	}
+	if err != nil {
+		log.Printf("Failed to save install transcript of %s: %v", job.ID, err)
+	}
+}
+
+// ReadTranscript returns the saved JSON of the finished job jobID, in the
+// same form as a live job's status.
+func ReadTranscript(jobID string) ([]byte, error) {
+	if !validJobID.MatchString(jobID) {
+		return nil, ErrTranscriptNotFound
+	}
+	serversDir, err := paths.ServersDir()
+	if err != nil {
+		return nil, err
+	}
+	matches, _ := filepath.Glob(filepath.Join(serversDir, "*", TranscriptDir, jobID+".json"))
+	if len(matches) == 0 {
+		return nil, ErrTranscriptNotFound
+	}
+	return os.ReadFile(matches[0])
+}
+
+// PruneTranscripts deletes transcripts last written more than maxAge ago and
+// returns how many it removed.
+func PruneTranscripts(maxAge time.Duration) (int, error) {
+	serversDir, err := paths.ServersDir()
+	if err != nil {
+		return 0, err
+	}
+	files, err := filepath.Glob(filepath.Join(serversDir, "*", TranscriptDir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(f); err == nil {
+			removed++
+		}
+		// Drop the directory with its last transcript
+		_ = os.Remove(filepath.Dir(f))
+	}
+	return removed, nil
+}
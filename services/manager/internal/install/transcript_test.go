@@ -0,0 +1,70 @@
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJobTranscript(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	jm := NewJobManager(1)
+	job := jm.CreateJob("demo", SrcNpm, "demo", funcInstaller(func(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+		job.Logf(LogLevelInfo, StageInstalling, "resolving demo")
+		return nil, errors.New("npm exited with status 1")
+	}))
+	if err := jm.StartJob(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, ch, _ := job.Subscribe()
+	for range ch {
+	}
+
+	// The transcript is written once the log collector has drained
+	path, _ := TranscriptPath("demo", job.ID)
+	deadline := time.Now().Add(2 * time.Second)
+	var data []byte
+	for {
+		var err error
+		if data, err = ReadTranscript(job.ID); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no transcript at %s: %v", path, err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	var saved struct {
+		Status JobStatus  `json:"status"`
+		Error  string     `json:"error"`
+		Logs   []LogEntry `json:"logs"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Status != JobStatusFailed || saved.Error != "npm exited with status 1" || len(saved.Logs) != 3 || saved.Logs[1].Message != "resolving demo" {
+		t.Fatalf("transcript = %s", data)
+	}
+
+	if _, err := ReadTranscript("../demo"); !errors.Is(err, ErrTranscriptNotFound) {
+		t.Fatalf("path in job id: %v", err)
+	}
+
+	// Only transcripts older than the retention are pruned
+	if n, err := PruneTranscripts(time.Hour); err != nil || n != 0 {
+		t.Fatalf("fresh prune = %d, %v", n, err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(path, old, old)
+	if n, err := PruneTranscripts(time.Hour); err != nil || n != 1 {
+		t.Fatalf("prune = %d, %v", n, err)
+	}
+	if _, err := ReadTranscript(job.ID); !errors.Is(err, ErrTranscriptNotFound) {
+		t.Fatalf("pruned transcript still read: %v", err)
+	}
+}
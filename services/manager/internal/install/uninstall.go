@@ -0,0 +1,103 @@
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
+)
+
+// UninstallResult reports what Uninstall removed, for a summary in the UI.
+type UninstallResult struct {
+	Slug     string   `json:"slug"`
+	Removed  []string `json:"removed"`            // paths deleted
+	Packages []string `json:"packages,omitempty"` // pipx packages and docker images removed
+	Warnings []string `json:"warnings,omitempty"` // cleanup that failed but didn't stop the rest
+}
+
+// Uninstall removes what the installers placed for slug: the install, runtime
+// and bin directories and the manifest under ~/.mcp/servers/<slug>/, plus the
+// package of a pipx install and the mcp-<slug> image of a docker install.
+// Shared runtimes and install transcripts are kept. Anything already gone is
+// skipped, so uninstalling twice succeeds. The registry entry and a running
+// process are the caller's to handle first.
+func Uninstall(ctx context.Context, slug string) (*UninstallResult, error) {
+	return uninstall(ctx, slug, ExecRunner{})
+}
+
+func uninstall(ctx context.Context, slug string, r Runner) (*UninstallResult, error) {
+	result := &UninstallResult{Slug: slug, Removed: []string{}}
+	if !registry.ValidSlug(slug) {
+		return result, fmt.Errorf("invalid slug: %q", slug)
+	}
+
+	baseServers, err := paths.ServersDir()
+	if err != nil {
+		return result, fmt.Errorf("failed to get servers directory: %w", err)
+	}
+	serverDir := filepath.Join(baseServers, slug)
+	manifestPath := filepath.Join(serverDir, "manifest.json")
+
+	// Packages living outside the server directory go first, while the
+	// manifest still says where they came from
+	var manifest ServerManifest
+	if data, err := os.ReadFile(manifestPath); err == nil {
+		_ = json.Unmarshal(data, &manifest)
+	}
+	if pkg := pipxPackage(manifest); pkg != "" {
+		if _, stderr, err := r.Run(ctx, "pipx", "uninstall", pkg); err == nil {
+			result.Packages = append(result.Packages, "pipx:"+pkg)
+		} else if !strings.Contains(stderr, "Nothing to uninstall") {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("pipx uninstall %s: %v: %s", pkg, err, strings.TrimSpace(stderr)))
+		}
+	}
+	if manifest.Installation.Runtime == "docker" {
+		tag := DockerTag(slug)
+		if _, stderr, err := r.Run(ctx, "docker", "image", "rm", tag); err == nil {
+			result.Packages = append(result.Packages, "docker:"+tag)
+		} else if !strings.Contains(stderr, "No such image") {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("docker image rm %s: %v: %s", tag, err, strings.TrimSpace(stderr)))
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join(serverDir, "install"),
+		filepath.Join(serverDir, "runtime"),
+		filepath.Join(serverDir, "bin"),
+		manifestPath,
+	} {
+		if _, err := os.Lstat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return result, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		result.Removed = append(result.Removed, path)
+	}
+
+	// The directory itself goes once nothing else, such as transcripts, is left
+	if err := os.Remove(serverDir); err == nil {
+		result.Removed = append(result.Removed, serverDir)
+	}
+	return result, nil
+}
+
+// pipxPackage returns the package a pipx install put in pipx's own venv, or
+// "" for any other install.
+func pipxPackage(manifest ServerManifest) string {
+	pkg, _ := manifest.Metadata["pipPackage"].(string)
+	if pkg == "" {
+		return ""
+	}
+	venv, _ := manifest.Metadata["venvPath"].(string)
+	if manifest.Metadata["usePipx"] == true || strings.Contains(filepath.ToSlash(venv), "/pipx/venvs/") {
+		return pkg
+	}
+	return ""
+}
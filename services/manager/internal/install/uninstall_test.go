@@ -0,0 +1,90 @@
+package install
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp/manager/internal/paths"
+)
+
+func TestUninstall(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	base, err := paths.ServersDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverDir := filepath.Join(base, "tool")
+	for _, dir := range []string{"install", "runtime", "bin", "install-logs"} {
+		if err := paths.MkdirAll(filepath.Join(serverDir, dir)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := `{"installation":{"runtime":"python"},"metadata":{"pipPackage":"mcp-tool","usePipx":true}}`
+	if err := os.WriteFile(filepath.Join(serverDir, "manifest.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var commands []string
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return "", "", nil
+	}}
+	result, err := uninstall(context.Background(), "tool", runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 1 || commands[0] != "pipx uninstall mcp-tool" || len(result.Packages) != 1 {
+		t.Fatalf("commands %q, packages %q", commands, result.Packages)
+	}
+	// Transcripts keep the server directory itself
+	if len(result.Removed) != 4 {
+		t.Fatalf("removed %q", result.Removed)
+	}
+	if _, err := os.Stat(filepath.Join(serverDir, "install-logs")); err != nil {
+		t.Fatalf("transcripts removed: %v", err)
+	}
+
+	// Nothing left to remove is not an error, and pipx isn't asked again
+	commands = nil
+	result, err = uninstall(context.Background(), "tool", runner)
+	if err != nil || len(result.Removed) != 0 || len(commands) != 0 {
+		t.Fatalf("repeat: %v, removed %q, commands %q", err, result.Removed, commands)
+	}
+
+	if _, err := uninstall(context.Background(), "../tool", runner); err == nil {
+		t.Fatal("slug with a path accepted")
+	}
+}
+
+func TestUninstallDockerImage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	base, _ := paths.ServersDir()
+	if err := paths.MkdirAll(filepath.Join(base, "box")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "box", "manifest.json"), []byte(`{"installation":{"runtime":"docker"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		if name+" "+strings.Join(args, " ") != "docker image rm mcp-box" {
+			t.Errorf("unexpected command %s %q", name, args)
+		}
+		return "", "Error response from daemon: No such image: mcp-box", errors.New("exit status 1")
+	}}
+	result, err := uninstall(context.Background(), "box", runner)
+	if err != nil || len(result.Warnings) != 0 || len(result.Packages) != 0 {
+		t.Fatalf("already removed image: %v %+v", err, result)
+	}
+	if _, err := os.Stat(filepath.Join(base, "box")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("server dir left behind: %v", err)
+	}
+}
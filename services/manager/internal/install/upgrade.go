@@ -0,0 +1,384 @@
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
+)
+
+// ErrUpgradeUnsupported is returned for a server Upgrade can't upgrade in
+// place: anything but an npm or pip install into its own runtime directory.
+var ErrUpgradeUnsupported = errors.New("server can't be upgraded in place")
+
+// upgradeVersionRE accepts exact versions and npm dist-tags, not ranges
+var upgradeVersionRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_-]*$`)
+
+// UpgradeResult reports an in-place upgrade. When the new version fails its
+// smoke test the runtime is restored, RolledBack is set and Error says why;
+// NewVersion is then the version still installed.
+type UpgradeResult struct {
+	Slug         string                `json:"slug"`
+	OldVersion   string                `json:"oldVersion"`
+	NewVersion   string                `json:"newVersion"`
+	RolledBack   bool                  `json:"rolledBack"`
+	EntryCommand string                `json:"entryCommand,omitempty"`
+	EntryArgs    []string              `json:"entryArgs,omitempty"`
+	EntryPoint   string                `json:"entryPoint,omitempty"`
+	EntryPoints  []registry.EntryPoint `json:"entryPoints,omitempty"`
+	Logs         []string              `json:"logs"`
+	Error        string                `json:"error,omitempty"`
+}
+
+// Upgrade moves an npm or pip server to version, or to the latest release
+// when version is empty, without reinstalling it. The runtime directory is
+// copied to runtime.bak first and put back if the upgrade fails or the
+// re-detected entry point doesn't pass a smoke test. On success the manifest
+// records the new version and entry; updating the registry entry and bin
+// script from the result is the caller's job, as is stopping the server.
+func Upgrade(ctx context.Context, slug, version string) (*UpgradeResult, error) {
+	return upgrade(ctx, slug, version, ExecRunner{}, nil)
+}
+
+// CheckUpgrade reports whether Upgrade can upgrade slug to version, without
+// changing anything, so callers can refuse before stopping the server.
+func CheckUpgrade(slug, version string) error {
+	_, err := upgradeTarget(slug, version)
+	return err
+}
+
+// upgradeTarget reads the manifest of the server Upgrade is to move to
+// version and checks that it is an npm or pip install Upgrade can handle.
+func upgradeTarget(slug, version string) (*ServerManifest, error) {
+	if !registry.ValidSlug(slug) {
+		return nil, fmt.Errorf("invalid slug: %q", slug)
+	}
+	if version != "" && !upgradeVersionRE.MatchString(version) {
+		return nil, fmt.Errorf("invalid version: %q", version)
+	}
+
+	baseServers, err := paths.ServersDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get servers directory: %w", err)
+	}
+	serverDir := filepath.Join(baseServers, slug)
+	runtimeDir := filepath.Join(serverDir, "runtime")
+	data, err := os.ReadFile(filepath.Join(serverDir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: no install manifest: %v", ErrUpgradeUnsupported, err)
+	}
+	var manifest ServerManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if filepath.Clean(manifest.Installation.RuntimePath) != runtimeDir {
+		return &manifest, fmt.Errorf("%w: its runtime %s is shared with other servers", ErrUpgradeUnsupported, manifest.Installation.RuntimePath)
+	}
+
+	switch manifest.Installation.Runtime {
+	case "node":
+		if pkg, _ := manifest.Metadata["npmPackage"].(string); pkg == "" {
+			return &manifest, fmt.Errorf("%w: the manifest names no npm package", ErrUpgradeUnsupported)
+		}
+	case "python":
+		pkg, _ := manifest.Metadata["pipPackage"].(string)
+		venvPath, _ := manifest.Metadata["venvPath"].(string)
+		if pkg == "" || pipxPackage(manifest) != "" {
+			return &manifest, fmt.Errorf("%w: only packages installed with pip into a venv are supported", ErrUpgradeUnsupported)
+		}
+		if rel, err := filepath.Rel(runtimeDir, venvPath); err != nil || !filepath.IsLocal(rel) {
+			return &manifest, fmt.Errorf("%w: the package is not installed into the server's own venv", ErrUpgradeUnsupported)
+		}
+	default:
+		return &manifest, fmt.Errorf("%w: runtime %q", ErrUpgradeUnsupported, manifest.Installation.Runtime)
+	}
+	return &manifest, nil
+}
+
+// upgrade runs Upgrade with r, logging to logger, or to the result's Logs
+// when logger is nil.
+func upgrade(ctx context.Context, slug, version string, r Runner, logger Logger) (*UpgradeResult, error) {
+	result := &UpgradeResult{Slug: slug, Logs: []string{}}
+	if logger == nil {
+		logger = sliceLogger{lines: &result.Logs}
+	}
+	manifest, err := upgradeTarget(slug, version)
+	if manifest != nil {
+		result.OldVersion = manifest.Installation.InstalledVersion
+	}
+	if err != nil {
+		return result, err
+	}
+	runtimeDir := filepath.Clean(manifest.Installation.RuntimePath)
+	manifestPath := filepath.Join(filepath.Dir(runtimeDir), "manifest.json")
+
+	// install runs the package manager; detect reads back the installed
+	// version and the entry points it provides
+	var install func() error
+	var detect func() (string, []registry.EntryPoint)
+	switch manifest.Installation.Runtime {
+	case "node":
+		pkg, _ := manifest.Metadata["npmPackage"].(string)
+		nodeExec, _ := manifest.Metadata["nodePath"].(string)
+		if nodeExec == "" {
+			nodeExec = "node"
+		}
+		packageManager := manifest.Installation.PackageManager
+		n := NewNPMInstaller(r, logger)
+		options := NPMInstallOptions{Package: pkg, Version: version}
+		if options.Version == "" {
+			options.Version = "latest"
+		}
+		install = func() error {
+			args, err := npmUpgradeArgs(packageManager, pkg+"@"+options.Version, runtimeDir)
+			if err != nil {
+				return err
+			}
+			logf(logger, "Upgrading %s with %s...", options.Package, packageManager)
+			if stdout, stderr, err := runWithProgress(ctx, r, logger, npmInstallProgress, packageManager, args...); err != nil {
+				return fmt.Errorf("upgrade failed: %w, stdout: %s, stderr: %s", err, stdout, stderr)
+			}
+			return nil
+		}
+		detect = func() (string, []registry.EntryPoint) {
+			info, installed, err := n.getPackageInfo(ctx, pkg, runtimeDir, packageManager)
+			if err != nil {
+				logf(logger, "Warning: Failed to get package info: %v", err)
+			}
+			return installed, n.entryPoints(options, info, runtimeDir, nodeExec)
+		}
+
+	case "python":
+		pkg, _ := manifest.Metadata["pipPackage"].(string)
+		venvPath, _ := manifest.Metadata["venvPath"].(string)
+		p := NewPipInstaller(r, logger)
+		pythonExec, pipPath, err := p.getVenvExecutables(venvPath)
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", ErrUpgradeUnsupported, err)
+		}
+		options := PipInstallOptions{Package: pkg, Version: version}
+		install = func() error {
+			logf(logger, "Upgrading %s with pip...", pkg)
+			args := append(pipInstallArgs(options), "--upgrade")
+			if stdout, stderr, err := runWithProgress(ctx, r, logger, pipInstallProgress(), pipPath, args...); err != nil {
+				if terr := toolchainFailure(stdout + stderr); terr != nil {
+					return terr
+				}
+				return fmt.Errorf("upgrade failed: %w, stdout: %s, stderr: %s", err, stdout, stderr)
+			}
+			return nil
+		}
+		detect = func() (string, []registry.EntryPoint) {
+			info, installed, err := p.getPackageInfo(ctx, pkg, pipPath, pythonExec)
+			if err != nil {
+				logf(logger, "Warning: Failed to get package info: %v", err)
+			}
+			return installed, p.entryPoints(ctx, options, info, venvPath, pythonExec)
+		}
+
+	}
+
+	// Upgrades change files in place, so the snapshot is a copy and the
+	// venv's absolute paths still hold once it is renamed back
+	backupDir := runtimeDir + ".bak"
+	if err := os.RemoveAll(backupDir); err != nil {
+		return result, fmt.Errorf("failed to remove stale backup: %w", err)
+	}
+	if err := copyTree(runtimeDir, backupDir); err != nil {
+		os.RemoveAll(backupDir)
+		return result, fmt.Errorf("failed to snapshot runtime: %w", err)
+	}
+
+	var ep registry.EntryPoint
+	err = install()
+	if err == nil {
+		result.NewVersion, result.EntryPoints = detect()
+		ep, err = upgradeEntry(result.EntryPoints, manifest.Entry)
+	}
+	if err == nil {
+		err = smokeTest(ctx, r, ep)
+	}
+	if err != nil {
+		logf(logger, "Upgrade failed, restoring %s: %v", runtimeDir, err)
+		result.RolledBack = true
+		result.Error = err.Error()
+		result.NewVersion = result.OldVersion
+		result.EntryPoints = nil
+		if rerr := os.RemoveAll(runtimeDir); rerr != nil {
+			return result, fmt.Errorf("failed to restore runtime from %s: %w", backupDir, rerr)
+		}
+		if rerr := os.Rename(backupDir, runtimeDir); rerr != nil {
+			return result, fmt.Errorf("failed to restore runtime from %s: %w", backupDir, rerr)
+		}
+		return result, nil
+	}
+	if err := os.RemoveAll(backupDir); err != nil {
+		logf(logger, "Warning: Failed to remove %s: %v", backupDir, err)
+	}
+
+	result.EntryCommand = ep.Command
+	result.EntryArgs = ep.Args
+	result.EntryPoint = ep.Name
+	manifest.Installation.InstalledVersion = result.NewVersion
+	manifest.Entry.Command = ep.Command
+	manifest.Entry.Args = ep.Args
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return result, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := paths.WriteFile(manifestPath, manifestData, paths.FileMode()); err != nil {
+		return result, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	logf(logger, "Upgraded %s from %s to %s", slug, result.OldVersion, result.NewVersion)
+	return result, nil
+}
+
+// UpgradeHooks let an upgrade job take the server down and record its new
+// entry, which the install package leaves to the caller.
+type UpgradeHooks struct {
+	// Stop runs before the upgrade; the restart it returns runs once the
+	// job is done, whether or not the upgrade succeeded
+	Stop func() (restart func(), err error)
+	// Apply is called with the result of an upgrade that ran, also when it
+	// was rolled back
+	Apply func(*UpgradeResult) error
+}
+
+// upgradeInstaller runs Upgrade as an installation job
+type upgradeInstaller struct {
+	version string
+	hooks   UpgradeHooks
+	runner  Runner
+}
+
+// Install implements Installer. A rolled-back upgrade fails the job.
+func (u *upgradeInstaller) Install(ctx context.Context, job *InstallationJob) (*InstallationResult, error) {
+	logger := NewInstallationJobLogger(job, LogLevelInfo, StageValidation)
+	if u.hooks.Stop != nil {
+		restart, err := u.hooks.Stop()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stop server: %w", err)
+		}
+		if restart != nil {
+			defer restart()
+		}
+	}
+
+	logger.EnterStage(StageInstalling)
+	result, err := upgrade(ctx, job.Slug, u.version, u.runner, logger)
+	if err != nil {
+		return nil, err
+	}
+	logger.EnterStage(StageRegistering)
+	if u.hooks.Apply != nil {
+		if err := u.hooks.Apply(result); err != nil {
+			return nil, err
+		}
+	}
+	if result.RolledBack {
+		return nil, fmt.Errorf("upgrade rolled back to %s: %s", result.OldVersion, result.Error)
+	}
+	return &InstallationResult{
+		Success:          true,
+		EntryCommand:     result.EntryCommand,
+		EntryArgs:        result.EntryArgs,
+		InstalledVersion: result.NewVersion,
+		EntryPoints:      result.EntryPoints,
+		Metadata: map[string]interface{}{
+			"upgrade": result,
+		},
+	}, nil
+}
+
+// npmUpgradeArgs returns the arguments that install spec into runtimeDir
+// with packageManager.
+func npmUpgradeArgs(packageManager, spec, runtimeDir string) ([]string, error) {
+	switch packageManager {
+	case "npm":
+		return []string{"install", "--prefix", runtimeDir, spec}, nil
+	case "yarn":
+		return []string{"--cwd", runtimeDir, "add", spec}, nil
+	case "pnpm":
+		return []string{"--dir", runtimeDir, "add", spec}, nil
+	}
+	return nil, fmt.Errorf("unsupported package manager: %s", packageManager)
+}
+
+// upgradeEntry re-detects the entry point after an upgrade: the candidate
+// that runs the old command, else the old command itself when it still
+// exists, as for one set in an MCP config, else the first candidate.
+func upgradeEntry(candidates []registry.EntryPoint, old ManifestEntry) (registry.EntryPoint, error) {
+	if name := entryPointName(candidates, old.Command, old.Args); name != "" {
+		return pickEntryPoint(candidates, name)
+	}
+	if _, err := exec.LookPath(old.Command); err == nil && old.Command != "" {
+		return registry.EntryPoint{Command: old.Command, Args: old.Args}, nil
+	}
+	return pickEntryPoint(candidates, "")
+}
+
+// smokeTest checks that ep can still start: its command is executable, a
+// module still imports and a script is still there.
+func smokeTest(ctx context.Context, r Runner, ep registry.EntryPoint) error {
+	if _, err := exec.LookPath(ep.Command); err != nil {
+		return fmt.Errorf("entry point command: %w", err)
+	}
+	switch ep.Kind {
+	case "module":
+		if len(ep.Args) == 2 && ep.Args[0] == "-m" {
+			if _, stderr, err := r.Run(ctx, ep.Command, "-c", "import "+ep.Args[1]); err != nil {
+				return fmt.Errorf("module %s does not import: %v: %s", ep.Args[1], err, lastLines(stderr, 3))
+			}
+		}
+	case "script":
+		if len(ep.Args) > 0 {
+			if _, err := os.Stat(ep.Args[0]); err != nil {
+				return fmt.Errorf("entry point script: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// copyTree copies the directory src to dst, keeping file modes and
+// symlinks, which venvs use for their interpreter.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		default:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		}
+	})
+}
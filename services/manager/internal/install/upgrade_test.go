@@ -0,0 +1,209 @@
+package install
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp/manager/internal/paths"
+)
+
+// setupNPMServer lays out an npm install of demo-mcp 1.0.0 for slug and
+// returns its runtime directory.
+func setupNPMServer(t *testing.T, slug string) string {
+	t.Helper()
+	base, err := paths.ServersDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtimeDir := filepath.Join(base, slug, "runtime")
+	bin := filepath.Join(runtimeDir, "node_modules", ".bin", "demo-mcp")
+	if err := paths.MkdirAll(filepath.Dir(bin)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writePackageJSON(t, runtimeDir, `{"name":"demo-mcp","version":"1.0.0","bin":{"demo-mcp":"cli.js"}}`)
+
+	manifest, _ := json.Marshal(ServerManifest{
+		Slug: slug,
+		Installation: ManifestInstallation{
+			Runtime:          "node",
+			PackageManager:   "npm",
+			InstalledVersion: "1.0.0",
+			RuntimePath:      runtimeDir,
+		},
+		Entry:    ManifestEntry{Command: bin},
+		Metadata: map[string]interface{}{"npmPackage": "demo-mcp"},
+	})
+	if err := os.WriteFile(filepath.Join(base, slug, "manifest.json"), manifest, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return runtimeDir
+}
+
+func writePackageJSON(t *testing.T, runtimeDir, data string) {
+	t.Helper()
+	dir := filepath.Join(runtimeDir, "node_modules", "demo-mcp")
+	if err := paths.MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpgradeNPM(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	runtimeDir := setupNPMServer(t, "demo")
+
+	var commands []string
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		writePackageJSON(t, runtimeDir, `{"name":"demo-mcp","version":"2.0.0","bin":{"demo-mcp":"cli.js"}}`)
+		return "", "", nil
+	}}
+	result, err := upgrade(context.Background(), "demo", "2.0.0", runner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "npm install --prefix " + runtimeDir + " demo-mcp@2.0.0"; len(commands) != 1 || commands[0] != want {
+		t.Fatalf("commands %q, want %q", commands, want)
+	}
+	if result.RolledBack || result.OldVersion != "1.0.0" || result.NewVersion != "2.0.0" || result.EntryPoint != "demo-mcp" {
+		t.Fatalf("result %+v", result)
+	}
+	if _, err := os.Stat(runtimeDir + ".bak"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("backup left behind: %v", err)
+	}
+
+	var manifest ServerManifest
+	data, _ := os.ReadFile(filepath.Join(filepath.Dir(runtimeDir), "manifest.json"))
+	if err := json.Unmarshal(data, &manifest); err != nil || manifest.Installation.InstalledVersion != "2.0.0" {
+		t.Fatalf("manifest version %q: %v", manifest.Installation.InstalledVersion, err)
+	}
+}
+
+func TestUpgradeRollsBack(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	runtimeDir := setupNPMServer(t, "demo")
+	bin := filepath.Join(runtimeDir, "node_modules", ".bin", "demo-mcp")
+
+	// The new version drops its bin, leaving nothing to run
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		writePackageJSON(t, runtimeDir, `{"name":"demo-mcp","version":"3.0.0"}`)
+		os.Remove(bin)
+		return "", "", nil
+	}}
+	result, err := upgrade(context.Background(), "demo", "", runner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.RolledBack || result.NewVersion != "1.0.0" || result.Error == "" {
+		t.Fatalf("result %+v", result)
+	}
+	data, _ := os.ReadFile(filepath.Join(runtimeDir, "node_modules", "demo-mcp", "package.json"))
+	if !strings.Contains(string(data), `"1.0.0"`) {
+		t.Fatalf("package.json not restored: %s", data)
+	}
+	if info, err := os.Stat(bin); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("bin not restored: %v", err)
+	}
+	if _, err := os.Stat(runtimeDir + ".bak"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("backup left behind: %v", err)
+	}
+}
+
+func TestUpgradeUnsupported(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	base, _ := paths.ServersDir()
+	if err := paths.MkdirAll(filepath.Join(base, "box")); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"installation":{"runtime":"docker","runtimePath":"` + filepath.Join(base, "box", "runtime") + `"}}`
+	if err := os.WriteFile(filepath.Join(base, "box", "manifest.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		t.Errorf("unexpected command %s %q", name, args)
+		return "", "", nil
+	}}
+	for _, slug := range []string{"box", "missing"} {
+		if _, err := upgrade(context.Background(), slug, "", runner, nil); !errors.Is(err, ErrUpgradeUnsupported) {
+			t.Errorf("%s: err = %v", slug, err)
+		}
+	}
+	setupNPMServer(t, "demo")
+	if _, err := upgrade(context.Background(), "demo", ">=2", runner, nil); err == nil {
+		t.Error("version range accepted")
+	}
+}
+
+func TestUpgradeJob(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	runtimeDir := setupNPMServer(t, "demo")
+	bin := filepath.Join(runtimeDir, "node_modules", ".bin", "demo-mcp")
+
+	var calls []string
+	hooks := UpgradeHooks{
+		Stop: func() (func(), error) {
+			calls = append(calls, "stop")
+			return func() { calls = append(calls, "restart") }, nil
+		},
+		Apply: func(result *UpgradeResult) error {
+			calls = append(calls, "apply "+result.NewVersion)
+			return nil
+		},
+	}
+	run := func(runner Runner) *InstallationJob {
+		t.Helper()
+		calls = nil
+		jm := NewJobManager(1)
+		job := jm.CreateJob("demo", SrcNpm, "demo-mcp", &upgradeInstaller{hooks: hooks, runner: runner})
+		if err := jm.StartJob(job.ID); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for !job.IsCompleted() {
+			if time.Now().After(deadline) {
+				t.Fatal("upgrade job never finished")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return job.GetSnapshot()
+	}
+
+	job := run(mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		writePackageJSON(t, runtimeDir, `{"name":"demo-mcp","version":"2.0.0","bin":{"demo-mcp":"cli.js"}}`)
+		return "", "", nil
+	}})
+	if job.Status != JobStatusCompleted || job.Result == nil || job.Result.InstalledVersion != "2.0.0" {
+		t.Fatalf("upgrade job %s: %+v %s", job.Status, job.Result, job.Error)
+	}
+	if got := strings.Join(calls, ", "); got != "stop, apply 2.0.0, restart" {
+		t.Fatalf("hooks ran as %q", got)
+	}
+
+	// A rolled-back upgrade fails the job but still restarts the server
+	job = run(mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		os.Remove(bin)
+		return "", "", nil
+	}})
+	if job.Status != JobStatusFailed || !strings.Contains(job.Error, "rolled back") {
+		t.Fatalf("rolled-back job %s: %s", job.Status, job.Error)
+	}
+	if got := strings.Join(calls, ", "); got != "stop, apply 2.0.0, restart" {
+		t.Fatalf("hooks ran as %q", got)
+	}
+}
@@ -0,0 +1,523 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"mcp/manager/internal/paths"
+)
+
+const (
+	// maxArchiveBytes caps the size of a downloaded archive
+	maxArchiveBytes = 512 << 20
+	// maxExtractedBytes caps the total size of the extracted files, so a
+	// small archive can't expand to fill the disk
+	maxExtractedBytes = 2 << 30
+)
+
+// Archive formats understood by URLInstaller
+const (
+	FormatTarGz  = "tar.gz"
+	FormatZip    = "zip"
+	FormatBinary = "binary" // a raw executable, installed as is
+)
+
+// URLInstaller handles installations from an archive or a raw binary at an
+// HTTP(S) URL, or from the asset of a GitHub release built for this OS and
+// architecture. The extracted tree goes through the same runtime and entry
+// point detection as a cloned git repository.
+type URLInstaller struct {
+	client    *http.Client
+	git       *GitInstaller
+	logger    Logger
+	githubAPI string // empty uses defaultGitHubAPI
+}
+
+// NewURLInstaller creates a new URL installer instance
+func NewURLInstaller(runner Runner, logger Logger) *URLInstaller {
+	return &URLInstaller{
+		client: http.DefaultClient,
+		git:    NewGitInstaller(runner, logger),
+		logger: logger,
+	}
+}
+
+// URLInstallOptions contains configuration for URL-based installations
+type URLInstallOptions struct {
+	URI           string            `json:"uri"`                     // http(s) URL, or github:owner/repo@tag
+	SHA256        string            `json:"sha256,omitempty"`        // expected hex digest of the archive
+	AuthHeader    string            `json:"authHeader,omitempty"`    // sent as the Authorization header, e.g. "Bearer <token>"
+	Format        string            `json:"format,omitempty"`        // tar.gz, zip or binary, detected when empty
+	PostInstall   []string          `json:"postInstall,omitempty"`   // commands to run after extraction
+	Environment   map[string]string `json:"environment,omitempty"`   // environment variables for commands
+	SkipDepsCheck bool              `json:"skipDepsCheck,omitempty"` // skip dependency detection and installation
+}
+
+// URLInstallResult contains the result of a URL installation
+type URLInstallResult struct {
+	GitInstallResult
+	SourceURL string `json:"sourceUrl"`
+	SHA256    string `json:"sha256"` // digest of the downloaded archive
+	Format    string `json:"format"`
+}
+
+// Install downloads and extracts the archive, then installs it like a git
+// checkout. Any existing install directory for slug is replaced.
+func (u *URLInstaller) Install(ctx context.Context, slug string, options URLInstallOptions) (*URLInstallResult, error) {
+	result := &URLInstallResult{SourceURL: options.URI}
+	result.Environment = make(map[string]string)
+
+	if ref, ok := parseReleaseRef(options.URI); ok {
+		assetURL, err := u.resolveRelease(ctx, ref, options.AuthHeader)
+		if err != nil {
+			return result, err
+		}
+		options.URI = assetURL
+		result.SourceURL = assetURL
+	}
+	if err := validateArchiveURL(options.URI); err != nil {
+		return result, err
+	}
+
+	baseServers, err := paths.ServersDir()
+	if err != nil {
+		return result, fmt.Errorf("failed to get servers directory: %w", err)
+	}
+
+	serverDir := filepath.Join(baseServers, slug)
+	installDir := filepath.Join(serverDir, "install")
+	runtimeDir := filepath.Join(serverDir, "runtime")
+	binDir := filepath.Join(serverDir, "bin")
+
+	result.InstallPath = installDir
+	result.RuntimePath = runtimeDir
+	result.BinPath = binDir
+
+	for _, dir := range []string{serverDir, runtimeDir, binDir} {
+		if err := paths.MkdirAll(dir); err != nil {
+			return result, fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	logf(u.logger, "Starting URL installation for %s", slug)
+	logf(u.logger, "Archive: %s", options.URI)
+
+	archive, digest, err := u.download(ctx, options, serverDir)
+	if err != nil {
+		return result, fmt.Errorf("download failed: %w", err)
+	}
+	defer os.Remove(archive)
+	result.SHA256 = digest
+
+	if options.SHA256 != "" && !strings.EqualFold(options.SHA256, digest) {
+		return result, fmt.Errorf("checksum mismatch: expected sha256 %s, got %s", options.SHA256, digest)
+	}
+
+	format := options.Format
+	if format == "" {
+		if format, err = detectArchiveFormat(options.URI, archive); err != nil {
+			return result, err
+		}
+	}
+	result.Format = format
+
+	// Extract next to the install directory and swap it in, so a failed
+	// extraction leaves the previous install untouched
+	staging, err := os.MkdirTemp(serverDir, ".extract-")
+	if err != nil {
+		return result, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	logf(u.logger, "Extracting %s archive...", format)
+	switch format {
+	case FormatTarGz:
+		err = extractTarGz(archive, staging)
+	case FormatZip:
+		err = extractZip(archive, staging)
+	case FormatBinary:
+		err = installBinary(archive, staging, binaryName(slug))
+	default:
+		err = fmt.Errorf("unsupported archive format: %s", format)
+	}
+	if err != nil {
+		return result, fmt.Errorf("extraction failed: %w", err)
+	}
+
+	root, err := archiveRoot(staging)
+	if err != nil {
+		return result, err
+	}
+	if err := os.RemoveAll(installDir); err != nil {
+		return result, fmt.Errorf("failed to remove previous install: %w", err)
+	}
+	if err := os.Rename(root, installDir); err != nil {
+		return result, fmt.Errorf("failed to move extracted files: %w", err)
+	}
+	if err := markExecutables(installDir); err != nil {
+		return result, fmt.Errorf("failed to mark binaries executable: %w", err)
+	}
+
+	gitOptions := GitInstallOptions{
+		URI:         options.URI,
+		PostInstall: options.PostInstall,
+		Environment: options.Environment,
+	}
+
+	if !options.SkipDepsCheck {
+		runtime, manager, err := u.git.detectRuntime(installDir)
+		if err != nil {
+			logf(u.logger, "Warning: Runtime detection failed: %v", err)
+		} else {
+			result.DetectedRuntime = runtime
+			result.DetectedManager = manager
+			logf(u.logger, "Detected runtime: %s with manager: %s", runtime, manager)
+		}
+
+		if err := u.git.installDependencies(ctx, installDir, runtimeDir, runtime, manager, gitOptions); err != nil {
+			return result, fmt.Errorf("dependency installation failed: %w", err)
+		}
+	}
+
+	if len(options.PostInstall) > 0 {
+		if err := u.git.runPostInstallCommands(ctx, installDir, gitOptions); err != nil {
+			return result, fmt.Errorf("post-install commands failed: %w", err)
+		}
+	}
+
+	entryCmd, entryArgs, env, err := u.git.detectEntryPoint(installDir, result.DetectedRuntime)
+	if err != nil {
+		logf(u.logger, "Warning: Entry point detection failed: %v", err)
+	} else {
+		result.EntryCommand = entryCmd
+		result.EntryArgs = entryArgs
+		for k, v := range env {
+			result.Environment[k] = v
+		}
+	}
+
+	if err := u.git.createBinScript(binDir, slug, result.EntryCommand, result.EntryArgs, result.Environment); err != nil {
+		return result, fmt.Errorf("failed to create bin script: %w", err)
+	}
+
+	result.Success = true
+	logf(u.logger, "URL installation completed successfully for %s", slug)
+	return result, nil
+}
+
+// validateArchiveURL accepts absolute http and https URLs only.
+func validateArchiveURL(uri string) error {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid archive URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("archive URL must use http or https, got %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return errors.New("archive URL has no host")
+	}
+	return nil
+}
+
+// download fetches the archive into a temporary file in dir and returns its
+// path and sha256 digest.
+func (u *URLInstaller) download(ctx context.Context, options URLInstallOptions, dir string) (string, string, error) {
+	logf(u.logger, "Downloading archive...")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, options.URI, nil)
+	if err != nil {
+		return "", "", err
+	}
+	if options.AuthHeader != "" {
+		req.Header.Set("Authorization", options.AuthHeader)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > maxArchiveBytes {
+		return "", "", fmt.Errorf("archive is larger than %d MB", maxArchiveBytes>>20)
+	}
+
+	f, err := os.CreateTemp(dir, ".archive-")
+	if err != nil {
+		return "", "", err
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, hash), io.LimitReader(resp.Body, maxArchiveBytes+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > maxArchiveBytes {
+		err = fmt.Errorf("archive is larger than %d MB", maxArchiveBytes>>20)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", "", err
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+	logf(u.logger, "Downloaded %d bytes (sha256 %s)", n, digest)
+	return f.Name(), digest, nil
+}
+
+// detectArchiveFormat reads the leading bytes of the archive, falling back to
+// the URL's file extension. A download that starts like an executable is a
+// raw binary.
+func detectArchiveFormat(uri, archive string) (string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(f, magic)
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case isNativeExecutable(magic):
+		return FormatBinary, nil
+	}
+
+	name := uri
+	if parsed, err := url.Parse(uri); err == nil {
+		name = parsed.Path
+	}
+	name = strings.ToLower(path.Base(name))
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, nil
+	}
+	return "", errors.New("cannot detect archive format; expected a .tar.gz, a .zip or an executable")
+}
+
+// safeJoin resolves an archive entry name below dest, rejecting absolute
+// names, names that climb out of dest and names below an extracted symlink.
+func safeJoin(dest, name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("archive entry %q has an absolute path", name)
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("archive entry %q escapes the extraction directory", name)
+	}
+	target := filepath.Join(dest, filepath.FromSlash(clean))
+
+	// An earlier symlink entry must not redirect later entries, or a link to
+	// "." followed by "link/../x" style names could still escape
+	dir := dest
+	for _, part := range strings.Split(path.Dir(clean), "/") {
+		if part == "." {
+			break
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if err != nil {
+			break
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry %q is written through a symlink", name)
+		}
+	}
+	return target, nil
+}
+
+// checkLink rejects a link at target whose destination resolves outside dest.
+func checkLink(dest, target, linkname string) error {
+	linkname = strings.ReplaceAll(linkname, "\\", "/")
+	if path.IsAbs(linkname) || filepath.IsAbs(linkname) {
+		return fmt.Errorf("link %q points to absolute path %q", target, linkname)
+	}
+	resolved := filepath.Join(filepath.Dir(target), filepath.FromSlash(linkname))
+	rel, err := filepath.Rel(dest, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("link %q points outside the extraction directory", target)
+	}
+	return nil
+}
+
+// writeEntry copies at most budget bytes of r into a new file at target and
+// returns the bytes written.
+func writeEntry(target string, r io.Reader, mode os.FileMode, budget int64) (int64, error) {
+	if err := paths.MkdirAll(filepath.Dir(target)); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, paths.Mask(mode.Perm()|0o600))
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, budget+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > budget {
+		err = fmt.Errorf("extracted files exceed %d MB", maxExtractedBytes>>20)
+	}
+	return n, err
+}
+
+// extractTarGz extracts a gzip-compressed tarball into dest. Device files and
+// other special entries are skipped.
+func extractTarGz(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := safeJoin(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := paths.MkdirAll(target); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			n, err := writeEntry(target, tr, hdr.FileInfo().Mode(), maxExtractedBytes-total)
+			if err != nil {
+				return err
+			}
+			total += n
+		case tar.TypeSymlink:
+			if err := checkLink(dest, target, hdr.Linkname); err != nil {
+				return err
+			}
+			if err := paths.MkdirAll(filepath.Dir(target)); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			source, err := safeJoin(dest, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := paths.MkdirAll(filepath.Dir(target)); err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractZip extracts a zip archive into dest.
+func extractZip(archive, dest string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	var total int64
+	for _, zf := range zr.File {
+		target, err := safeJoin(dest, zf.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := zf.Mode()
+		switch {
+		case mode.IsDir():
+			if err := paths.MkdirAll(target); err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			linkname, err := io.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			if err := checkLink(dest, target, string(linkname)); err != nil {
+				return err
+			}
+			if err := paths.MkdirAll(filepath.Dir(target)); err != nil {
+				return err
+			}
+			if err := os.Symlink(string(linkname), target); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+			n, err := writeEntry(target, rc, mode, maxExtractedBytes-total)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+	}
+	return nil
+}
+
+// archiveRoot returns the directory to install from: the single top-level
+// directory most release archives wrap their files in, or dir itself.
+func archiveRoot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", errors.New("archive is empty")
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	return dir, nil
+}
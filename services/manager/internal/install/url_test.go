@@ -0,0 +1,305 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type archiveFile struct {
+	name, body, link string
+	mode             int64
+}
+
+func makeTarGz(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: f.mode, Size: int64(len(f.body)), Typeflag: tar.TypeReg}
+		if f.link != "" {
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, f.link, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if f.link == "" {
+			if _, err := tw.Write([]byte(f.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeZip(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		fh := &zip.FileHeader{Name: f.name, Method: zip.Deflate}
+		fh.SetMode(os.FileMode(f.mode))
+		w, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func serveArchive(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/" + name
+}
+
+func TestURLInstaller_Install(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	files := []archiveFile{
+		{name: "server-1.0/run.sh", body: "#!/bin/sh\necho ok\n", mode: 0o755},
+		{name: "server-1.0/README", body: "docs", mode: 0o644},
+	}
+	for _, tc := range []struct {
+		name, format string
+		data         []byte
+	}{
+		{"server-1.0.tar.gz", FormatTarGz, makeTarGz(t, files)},
+		// No extension: the format comes from the content
+		{"download", FormatZip, makeZip(t, files)},
+	} {
+		sum := sha256.Sum256(tc.data)
+		digest := hex.EncodeToString(sum[:])
+		uri := serveArchive(t, tc.name, tc.data)
+
+		installer := NewURLInstaller(mockRunner{}, testLogger{t})
+		result, err := installer.Install(context.Background(), "archived", URLInstallOptions{
+			URI:        uri,
+			SHA256:     strings.ToUpper(digest),
+			AuthHeader: "Bearer secret",
+		})
+		if err != nil {
+			t.Fatalf("%s: Install: %v", tc.name, err)
+		}
+		if !result.Success || result.SHA256 != digest || result.SourceURL != uri || result.Format != tc.format {
+			t.Fatalf("%s: unexpected result %+v", tc.name, result)
+		}
+		// The single top-level directory is stripped
+		if result.EntryCommand != filepath.Join(result.InstallPath, "run.sh") {
+			t.Fatalf("%s: entry command %q", tc.name, result.EntryCommand)
+		}
+		if result.DetectedRuntime != "binary" {
+			t.Fatalf("%s: runtime %q", tc.name, result.DetectedRuntime)
+		}
+	}
+}
+
+func TestURLInstaller_ChecksumMismatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	uri := serveArchive(t, "server.tar.gz", makeTarGz(t, []archiveFile{{name: "run.sh", body: "x", mode: 0o755}}))
+	installer := NewURLInstaller(mockRunner{}, testLogger{t})
+	_, err := installer.Install(context.Background(), "archived", URLInstallOptions{
+		URI:        uri,
+		SHA256:     strings.Repeat("0", 64),
+		AuthHeader: "Bearer secret",
+	})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestExtractRejectsEscapes(t *testing.T) {
+	for name, files := range map[string][]archiveFile{
+		"parent":       {{name: "../evil", body: "x", mode: 0o644}},
+		"nested":       {{name: "a/../../evil", body: "x", mode: 0o644}},
+		"absolute":     {{name: "/tmp/evil", body: "x", mode: 0o644}},
+		"symlink":      {{name: "link", link: "../outside"}},
+		"through link": {{name: "dir", link: "."}, {name: "dir/evil", body: "x", mode: 0o644}},
+	} {
+		for _, format := range []string{FormatTarGz, FormatZip} {
+			if format == FormatZip && files[0].link != "" {
+				continue
+			}
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "archive")
+			data := makeTarGz(t, files)
+			extract := extractTarGz
+			if format == FormatZip {
+				data, extract = makeZip(t, files), extractZip
+			}
+			if err := os.WriteFile(archive, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			dest := filepath.Join(dir, "out")
+			if err := os.Mkdir(dest, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := extract(archive, dest); err == nil {
+				t.Errorf("%s/%s: extraction succeeded", name, format)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "evil")); err == nil {
+				t.Errorf("%s/%s: file written outside the destination", name, format)
+			}
+		}
+	}
+}
+
+func TestURLInstaller_RawBinary(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	// Served without an extension, so only the ELF header says what it is
+	uri := serveArchive(t, "download", []byte("\x7fELF\x02\x01\x01 not really a binary"))
+	installer := NewURLInstaller(mockRunner{}, testLogger{t})
+	result, err := installer.Install(context.Background(), "raw", URLInstallOptions{URI: uri, AuthHeader: "Bearer secret"})
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if result.Format != FormatBinary {
+		t.Fatalf("format %q", result.Format)
+	}
+	entry := filepath.Join(result.InstallPath, binaryName("raw"))
+	if result.EntryCommand != entry || !isExecutable(entry) {
+		t.Fatalf("entry command %q, executable %v", result.EntryCommand, isExecutable(entry))
+	}
+	if _, err := os.Stat(filepath.Join(result.BinPath, "raw")); err != nil {
+		t.Fatalf("bin script: %v", err)
+	}
+}
+
+func TestURLInstaller_MarksZippedBinariesExecutable(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	uri := serveArchive(t, "server.zip", makeZip(t, []archiveFile{
+		{name: "server", body: "\x7fELF\x02\x01\x01", mode: 0o644},
+		{name: "LICENSE", body: "MIT", mode: 0o644},
+	}))
+	installer := NewURLInstaller(mockRunner{}, testLogger{t})
+	result, err := installer.Install(context.Background(), "zipped", URLInstallOptions{URI: uri, AuthHeader: "Bearer secret"})
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if result.EntryCommand != filepath.Join(result.InstallPath, "server") {
+		t.Fatalf("entry command %q", result.EntryCommand)
+	}
+	if isExecutable(filepath.Join(result.InstallPath, "LICENSE")) {
+		t.Fatal("LICENSE was marked executable")
+	}
+}
+
+func TestURLInstaller_GitHubRelease(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+
+	data := makeTarGz(t, []archiveFile{{name: "run.sh", body: "#!/bin/sh\necho ok\n", mode: 0o755}})
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/server/releases/tags/v1.2.0":
+			asset := "server_" + runtime.GOOS + "_" + runtime.GOARCH
+			fmt.Fprintf(w, `{"tag_name":"v1.2.0","assets":[
+				{"name":"checksums.txt","browser_download_url":"%[1]s/checksums.txt"},
+				{"name":"server_plan9_mips.tar.gz","browser_download_url":"%[1]s/wrong"},
+				{"name":"%[2]s.tar.gz.sha256","browser_download_url":"%[1]s/wrong"},
+				{"name":"%[2]s.tar.gz","browser_download_url":"%[1]s/download/%[2]s.tar.gz"}
+			]}`, srv.URL, asset)
+		case "/download/server_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz":
+			w.Write(data)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	installer := NewURLInstaller(mockRunner{}, testLogger{t})
+	installer.githubAPI = srv.URL
+	result, err := installer.Install(context.Background(), "released", URLInstallOptions{URI: "github:acme/server@v1.2.0"})
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if !strings.HasSuffix(result.SourceURL, "/download/server_"+runtime.GOOS+"_"+runtime.GOARCH+".tar.gz") || result.Format != FormatTarGz {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	if _, err := installer.Install(context.Background(), "released", URLInstallOptions{URI: "acme/server@v9"}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected a missing release to fail, got %v", err)
+	}
+}
+
+func TestPickAsset(t *testing.T) {
+	assets := []releaseAsset{
+		{Name: "tool-Linux-x86_64"},
+		{Name: "tool-linux-amd64.zip"},
+		{Name: "tool_linux_amd64.tar.gz.sig"},
+		{Name: "tool-linux-arm64.tar.gz"},
+		{Name: "tool-macOS-universal.tar.gz"},
+		{Name: "tool-windows-x64.exe"},
+		{Name: "tool_1.0_amd64.deb"},
+	}
+	for _, c := range []struct{ goos, goarch, want string }{
+		{"linux", "amd64", "tool-linux-amd64.zip"},
+		{"linux", "arm64", "tool-linux-arm64.tar.gz"},
+		{"darwin", "arm64", "tool-macOS-universal.tar.gz"},
+		{"windows", "amd64", "tool-windows-x64.exe"},
+		{"linux", "386", ""},
+	} {
+		got, err := pickAsset(assets, c.goos, c.goarch)
+		if got.Name != c.want || (err == nil) != (c.want != "") {
+			t.Errorf("%s/%s: got %q, %v; want %q", c.goos, c.goarch, got.Name, err, c.want)
+		}
+	}
+
+	// A raw binary whose name carries a version is still a binary
+	versioned := []releaseAsset{{Name: "tool_1.2.0_linux_amd64.tar.xz"}, {Name: "tool_1.2.0_linux_amd64"}, {Name: "tool_1.2.0_checksums.txt"}}
+	if got, err := pickAsset(versioned, "linux", "amd64"); err != nil || got.Name != "tool_1.2.0_linux_amd64" {
+		t.Errorf("versioned binary: got %q, %v", got.Name, err)
+	}
+
+	for uri, want := range map[string]bool{
+		"github:acme/server":        true,
+		"acme/server@v1":            true,
+		"acme/server":               false,
+		"https://example.com/a@b":   false,
+		"github:acme/server/sub@v1": false,
+	} {
+		if _, ok := parseReleaseRef(uri); ok != want {
+			t.Errorf("parseReleaseRef(%q) = %v", uri, ok)
+		}
+	}
+}
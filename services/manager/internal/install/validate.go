@@ -8,7 +8,6 @@ import (
     "path"
     "regexp"
     "strings"
-    "syscall"
 )
 
 type SourceType string
@@ -19,6 +18,7 @@ const (
     SrcPip    SourceType = "pip"
     SrcDocker SourceType = "docker-image"
     SrcCompose SourceType = "docker-compose"
+    SrcURL    SourceType = "url"
 )
 
 type Input struct {
@@ -40,6 +40,8 @@ func slugify(s string) string {
     s = strings.ToLower(s)
     s = strings.TrimSuffix(s, ".git")
     s = strings.TrimSuffix(s, ".tar.gz")
+    s = strings.TrimSuffix(s, ".tgz")
+    s = strings.TrimSuffix(s, ".zip")
     s = strings.Trim(s, "/ ")
     s = strings.ReplaceAll(s, "_", "-")
     s = slugRE.ReplaceAllString(s, "-")
@@ -83,6 +85,10 @@ func Validate(ctx context.Context, in Input, r Runner) (Result, error) {
         if _, _, err := r.Run(ctx, "docker", "compose", "config", "-q"); err != nil {
             res.OK = false; res.Problems = append(res.Problems, fmt.Sprintf("docker compose not available: %v", err))
         } else { res.Runtime = "docker" }
+    case SrcURL:
+        if err := validateArchiveSource(in.URI); err != nil {
+            res.OK = false; res.Problems = append(res.Problems, err.Error())
+        }
     default:
         return res, errors.New("unsupported source type")
     }
@@ -94,9 +100,8 @@ func Validate(ctx context.Context, in Input, r Runner) (Result, error) {
 }
 
 func hasDiskSpace(minBytes uint64) (bool, error) {
-    var st syscall.Statfs_t
-    if err := syscall.Statfs("/", &st); err != nil { return false, err }
-    free := st.Bfree * uint64(st.Bsize)
+    free, err := FreeDiskSpace("/")
+    if err != nil { return false, err }
     return free >= minBytes, nil
 }
 
@@ -0,0 +1,148 @@
+package install
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
+)
+
+// DriftReport lists the ways a server's registry entry disagrees with what is on disk.
+type DriftReport struct {
+	Slug        string   `json:"slug"`
+	NeedsRepair bool     `json:"needsRepair"`
+	Issues      []string `json:"issues"`
+}
+
+func (d *DriftReport) addIssue(format string, args ...interface{}) {
+	d.Issues = append(d.Issues, fmt.Sprintf(format, args...))
+	d.NeedsRepair = true
+}
+
+// VerifyServer checks that the files an installed server needs are still in
+// place: its entry command, the bin directory and the runtime environment
+// (a venv for python, node_modules for node). External servers have nothing
+// on disk and always verify clean.
+func VerifyServer(sv registry.Server) DriftReport {
+	report := DriftReport{Slug: sv.Slug, Issues: []string{}}
+	if sv.IsExternal() {
+		return report
+	}
+
+	baseServers, err := paths.ServersDir()
+	if err != nil {
+		report.addIssue("failed to get servers directory: %v", err)
+		return report
+	}
+	serverDir := filepath.Join(baseServers, sv.Slug)
+
+	// preStart hooks may produce the command, as the supervisor also allows
+	if len(sv.Entry.PreStart) == 0 {
+		if err := registry.CheckCommand(sv.Entry.Command, serverDir); err != nil {
+			report.addIssue("%v", err)
+		}
+	}
+
+	// Layout checks only apply to servers the installers placed under serverDir
+	if _, err := os.Stat(serverDir); err != nil {
+		return report
+	}
+
+	var manifest ServerManifest
+	if data, err := os.ReadFile(filepath.Join(serverDir, "manifest.json")); err == nil {
+		_ = json.Unmarshal(data, &manifest)
+	}
+	binPath := manifest.Installation.BinPath
+	if binPath == "" {
+		binPath = filepath.Join(serverDir, "bin")
+	}
+	runtimePath := manifest.Installation.RuntimePath
+	if runtimePath == "" {
+		runtimePath = filepath.Join(serverDir, "runtime")
+	}
+
+	switch sv.Runtime.Kind {
+	case "node":
+		requireDir(&report, "bin directory", binPath)
+		requireDir(&report, "node_modules", filepath.Join(runtimePath, "node_modules"))
+	case "python":
+		requireDir(&report, "bin directory", binPath)
+		if sv.Runtime.Python != nil && sv.Runtime.Python.Venv {
+			venv := filepath.Join(runtimePath, "venv")
+			if requireDir(&report, "virtual environment", venv) {
+				if _, _, err := (&PipInstaller{}).getVenvExecutables(venv); err != nil {
+					report.addIssue("virtual environment %s has no python interpreter", venv)
+				}
+			}
+		}
+	}
+
+	return report
+}
+
+
+// requireDir reports path as missing unless it is a directory.
+func requireDir(report *DriftReport, what, path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.IsDir() {
+		report.addIssue("%s %s is missing", what, path)
+		return false
+	}
+	return true
+}
+
+// ErrChecksumMismatch is returned when an installed artifact doesn't match
+// the checksum the install was pinned to.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Checksum pins an install to a known artifact: the wheel pip downloads, the
+// tarball npm packs, or for git the commit that is checked out. Value is the
+// expected digest in hex, or for git a commit id, which defaults to the
+// install's commit. Algorithm is sha256 (the default), sha512 or sha1 and is
+// not used for git.
+type Checksum struct {
+	Algorithm string `json:"algorithm,omitempty"`
+	Value     string `json:"value"`
+}
+
+// VerifyChecksum checks that the file at path hashes to expected, a hex
+// digest, with algorithm.
+func VerifyChecksum(path, algorithm, expected string) error {
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "", "sha256":
+		algorithm, h = "sha256", sha256.New()
+	case "sha512":
+		h = sha512.New()
+	case "sha1":
+		h = sha1.New()
+	default:
+		return fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	digest := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(digest, strings.TrimSpace(expected)) {
+		return fmt.Errorf("%w: expected %s %s for %s, got %s", ErrChecksumMismatch, strings.ToLower(algorithm), expected, filepath.Base(path), digest)
+	}
+	return nil
+}
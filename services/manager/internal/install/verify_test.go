@@ -0,0 +1,175 @@
+package install
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"mcp/manager/internal/registry"
+)
+
+func TestVerifyServerDetectsDrift(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", root)
+	serversDir := filepath.Join(root, "servers")
+	serverDir := filepath.Join(serversDir, "fs")
+	binScript := filepath.Join(serverDir, "bin", "fs")
+	for _, d := range []string{filepath.Dir(binScript), filepath.Join(serverDir, "runtime", "node_modules")} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(binScript, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	sv := registry.Server{
+		Slug:    "fs",
+		Runtime: registry.Runtime{Kind: "node"},
+		Entry:   registry.Entry{Transport: "stdio", Command: binScript},
+	}
+	if report := VerifyServer(sv); report.NeedsRepair {
+		t.Fatalf("expected clean install, got %v", report.Issues)
+	}
+
+	if err := os.RemoveAll(filepath.Join(serverDir, "runtime", "node_modules")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(binScript, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report := VerifyServer(sv)
+	if !report.NeedsRepair || len(report.Issues) != 2 {
+		t.Fatalf("expected missing node_modules and non-executable command, got %v", report.Issues)
+	}
+
+	py := registry.Server{
+		Slug:    "py",
+		Runtime: registry.Runtime{Kind: "python", Python: &registry.PyRuntime{Venv: true}},
+		Entry:   registry.Entry{Transport: "stdio", Command: "bin/py"},
+	}
+	if err := os.MkdirAll(filepath.Join(serversDir, "py", "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if report := VerifyServer(py); len(report.Issues) != 2 {
+		t.Fatalf("expected missing command and venv, got %v", report.Issues)
+	}
+
+	ext := registry.Server{Slug: "gh", External: &registry.ExternalInfo{Provider: "github"}}
+	if report := VerifyServer(ext); report.NeedsRepair {
+		t.Fatalf("external server should not need repair: %v", report.Issues)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pkg.whl")
+	if err := os.WriteFile(path, []byte("wheel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("wheel"))
+	digest := hex.EncodeToString(sum[:])
+
+	if err := VerifyChecksum(path, "", strings.ToUpper(digest)); err != nil {
+		t.Fatalf("matching digest: %v", err)
+	}
+	if err := VerifyChecksum(path, "sha512", digest); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("wrong algorithm: %v", err)
+	}
+	if err := VerifyChecksum(path, "md5", digest); err == nil || errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("unsupported algorithm: %v", err)
+	}
+}
+
+func TestInstallersVerifyChecksums(t *testing.T) {
+	ctx := context.Background()
+	wheelSum := sha256.Sum256([]byte("wheel"))
+	tarball := []byte("tarball")
+	tarSum := sha512.Sum512(tarball)
+	tarSHA := sha256.Sum256(tarball)
+
+	// pip download puts the wheel in the directory after --dest
+	pip := NewPipInstaller(mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		dest := args[slices.Index(args, "--dest")+1]
+		return "", "", os.WriteFile(filepath.Join(dest, "demo-1.0-py3-none-any.whl"), []byte("wheel"), 0o644)
+	}}, testLogger{t})
+	options := PipInstallOptions{Package: "demo", Version: "1.0", Checksum: &Checksum{Value: hex.EncodeToString(wheelSum[:])}}
+	file, cleanup, err := pip.downloadVerified(ctx, options, []string{"/venv/bin/pip"})
+	if err != nil || filepath.Base(file) != "demo-1.0-py3-none-any.whl" {
+		t.Fatalf("pip: %q %v", file, err)
+	}
+	cleanup()
+	options.Checksum.Value = strings.Repeat("0", 64)
+	if _, _, err := pip.downloadVerified(ctx, options, []string{"/venv/bin/pip"}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("pip mismatch: %v", err)
+	}
+
+	// An interpreter path with a space stays one argument
+	var ran string
+	spaced := NewPipInstaller(mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		ran = name
+		return "", "", errors.New("offline")
+	}}, testLogger{t})
+	_, _, _ = spaced.downloadVerified(ctx, options, []string{"/opt/my python/bin/python3", "-m", "pip"})
+	if ran != "/opt/my python/bin/python3" {
+		t.Fatalf("pip ran %q", ran)
+	}
+
+	// npm pack reports the tarball's integrity along with its name
+	integrity := "sha512-" + base64.StdEncoding.EncodeToString(tarSum[:])
+	packRunner := mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		dest := args[slices.Index(args, "--pack-destination")+1]
+		if err := os.WriteFile(filepath.Join(dest, "demo-1.0.0.tgz"), tarball, 0o644); err != nil {
+			return "", "", err
+		}
+		return `[{"filename":"demo-1.0.0.tgz","integrity":"` + integrity + `"}]`, "", nil
+	}}
+	npm := NewNPMInstaller(packRunner, testLogger{t})
+	npmOptions := NPMInstallOptions{Package: "demo", Checksum: &Checksum{Algorithm: "sha256", Value: hex.EncodeToString(tarSHA[:])}}
+	file, cleanup, err = npm.packVerified(ctx, npmOptions)
+	if err != nil || filepath.Base(file) != "demo-1.0.0.tgz" {
+		t.Fatalf("npm: %q %v", file, err)
+	}
+	// The installed tarball is kept in the runtime directory it was installed into
+	runtimeDir := t.TempDir()
+	var installed []string
+	npm.runner = mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		installed = args
+		return "", "", nil
+	}}
+	if err := npm.installInto(ctx, npmOptions, file, "node", runtimeDir, "npm"); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(runtimeDir, "demo-1.0.0.tgz")
+	if len(installed) < 2 || installed[1] != kept {
+		t.Fatalf("npm installed %v, want %s", installed, kept)
+	}
+	cleanup()
+	if _, err := os.Stat(kept); err != nil {
+		t.Fatalf("tarball removed with the temporary copy: %v", err)
+	}
+	npm.runner = packRunner
+	integrity = "sha512-" + base64.StdEncoding.EncodeToString(make([]byte, 64))
+	if _, _, err := npm.packVerified(ctx, npmOptions); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("npm integrity mismatch: %v", err)
+	}
+
+	// git compares HEAD with the commit, abbreviated or not
+	git := NewGitInstaller(mockRunner{f: func(ctx context.Context, name string, args ...string) (string, string, error) {
+		return "0123456789abcdef0123456789abcdef01234567\n", "", nil
+	}}, testLogger{t})
+	for commit, ok := range map[string]bool{"0123456": true, "0123456789abcdef0123456789abcdef01234567": true, "abcdef0": false, "012": false} {
+		err := git.verifyCommit(ctx, GitInstallOptions{Commit: commit, Checksum: &Checksum{}}, t.TempDir())
+		if (err == nil) != ok {
+			t.Errorf("commit %s: %v", commit, err)
+		}
+	}
+}
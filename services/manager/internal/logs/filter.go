@@ -0,0 +1,106 @@
+package logs
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "regexp"
+    "strings"
+    "time"
+)
+
+// levelRank orders the levels ParseLine reports, least severe first.
+var levelRank = map[string]int{"debug": 1, "info": 2, "warning": 3, "error": 4}
+
+// Filter selects the log entries a client wants. Every condition that is
+// set must hold. Control entries such as EventDropped always pass, so a
+// filtered stream still learns about gaps and closes.
+type Filter struct {
+    Level    string         // minimum level: debug, info, warning or error; entries without a level fail it
+    Contains string         // substring of the message
+    Regex    *regexp.Regexp // matched against the message
+    Since    time.Time      // entries dated before it fail
+}
+
+// NewFilter builds a filter from query-style values, any of which may be
+// empty. level accepts "warn" for "warning", and since is RFC 3339. It
+// returns nil when nothing is set, which matches every entry.
+func NewFilter(level, contains, pattern, since string) (*Filter, error) {
+    if level == "" && contains == "" && pattern == "" && since == "" {
+        return nil, nil
+    }
+    f := &Filter{Contains: contains}
+    if level != "" {
+        level = strings.ToLower(level)
+        if level == "warn" {
+            level = "warning"
+        }
+        if levelRank[level] == 0 {
+            return nil, fmt.Errorf("invalid level %q: want debug, info, warning or error", level)
+        }
+        f.Level = level
+    }
+    if pattern != "" {
+        re, err := regexp.Compile(pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid regex: %w", err)
+        }
+        f.Regex = re
+    }
+    if since != "" {
+        t, err := time.Parse(time.RFC3339Nano, since)
+        if err != nil {
+            return nil, fmt.Errorf("invalid since %q: want an RFC 3339 time", since)
+        }
+        f.Since = t
+    }
+    return f, nil
+}
+
+// Match reports whether entry passes f. A nil filter passes everything.
+func (f *Filter) Match(entry LogEntry) bool {
+    if f == nil || entry.Event != "" {
+        return true
+    }
+    if f.Level != "" && levelRank[entry.Level] < levelRank[f.Level] {
+        return false
+    }
+    if f.Contains != "" && !strings.Contains(entry.Message, f.Contains) {
+        return false
+    }
+    if f.Regex != nil && !f.Regex.MatchString(entry.Message) {
+        return false
+    }
+    return f.Since.IsZero() || !entry.Timestamp.Before(f.Since)
+}
+
+// FilterTail returns the last n lines of the log at path that pass f. Lines
+// are matched one by one, without record grouping.
+func FilterTail(path string, n int, f *Filter) ([]string, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    if n <= 0 {
+        return nil, nil
+    }
+
+    grouper := recordGrouper{datePast: true}
+    var lines []string
+    scanner := bufio.NewScanner(file)
+    scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+    lineNum := int64(0)
+    for scanner.Scan() {
+        lineNum++
+        entry, _ := grouper.add(lineNum, scanner.Text())
+        if !f.Match(entry) {
+            continue
+        }
+        if len(lines) == n {
+            lines = lines[1:]
+        }
+        lines = append(lines, entry.Message)
+    }
+    return lines, scanner.Err()
+}
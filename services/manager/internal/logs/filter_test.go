@@ -0,0 +1,100 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewFilter(t *testing.T) {
+	if f, err := NewFilter("", "", "", ""); f != nil || err != nil {
+		t.Fatalf("empty filter: %v, %v", f, err)
+	}
+	for _, bad := range [][4]string{
+		{"fatal", "", "", ""},
+		{"", "", "(", ""},
+		{"", "", "", "yesterday"},
+	} {
+		if _, err := NewFilter(bad[0], bad[1], bad[2], bad[3]); err == nil {
+			t.Errorf("NewFilter%q: expected error", bad)
+		}
+	}
+
+	f, err := NewFilter("WARN", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for level, want := range map[string]bool{"error": true, "warning": true, "info": false, "": false} {
+		if got := f.Match(LogEntry{Level: level}); got != want {
+			t.Errorf("level %q: got %v, want %v", level, got, want)
+		}
+	}
+	if !f.Match(LogEntry{Event: EventDropped}) {
+		t.Error("control entry filtered out")
+	}
+}
+
+func TestFilterTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc.log")
+	log := "[2024-05-01T10:00:00Z] started\n" +
+		"GET /a 200\n" +
+		"[2024-05-01T12:00:00Z] retrying\n" +
+		"GET /b 500\n" +
+		"GET /c 200\n"
+	if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Undated lines take the date of the line before them
+	f, _ := NewFilter("", "GET", "", "2024-05-01T11:00:00Z")
+	lines, err := FilterTail(path, 10, f)
+	if want := []string{"GET /b 500", "GET /c 200"}; err != nil || !reflect.DeepEqual(lines, want) {
+		t.Fatalf("got %q, %v; want %q", lines, err, want)
+	}
+	f, _ = NewFilter("", "", ` 200$`, "")
+	if lines, _ := FilterTail(path, 1, f); !reflect.DeepEqual(lines, []string{"GET /c 200"}) {
+		t.Fatalf("tail 1: %q", lines)
+	}
+}
+
+func TestStreamLogsFilter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "svc.log")
+	if err := os.WriteFile(path, []byte("info: up\nerror: disk full\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ls := NewLogStreamer(dir)
+	ls.Start()
+	defer ls.Stop()
+
+	f, _ := NewFilter("error", "", "", "")
+	client, err := ls.StreamLogs("c1", "svc", 0, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() LogEntry {
+		t.Helper()
+		select {
+		case e := <-client.Ch:
+			return e
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for log entry")
+		}
+		return LogEntry{}
+	}
+	if e := next(); e.Message != "error: disk full" {
+		t.Fatalf("historical entry %+v", e)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = file.WriteString("info: retrying\nerror: still full\n")
+	file.Close()
+	if e := next(); e.Message != "error: still full" || e.Line != 4 {
+		t.Fatalf("live entry %+v", e)
+	}
+}
@@ -20,7 +20,7 @@ func ApplyRotation(paths []string, trim []int64) error {
         defer f.Close()
         if _, err := f.Seek(fi.Size()-keep, io.SeekStart); err != nil { continue }
         tmp := p + ".tmp"
-        tf, err := os.Create(tmp)
+        tf, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fi.Mode().Perm()) // the trimmed log keeps its mode
         if err != nil { continue }
         if _, err := io.Copy(tf, f); err != nil { tf.Close(); _ = os.Remove(tmp); continue }
         tf.Close()
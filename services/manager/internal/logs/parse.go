@@ -0,0 +1,199 @@
+package logs
+
+import (
+    "encoding/json"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ParseLine reads the time, level and message of a raw log line. It knows
+//   - a leading RFC 3339 timestamp, bare or in square brackets as the
+//     supervisor writes it,
+//   - logfmt pairs such as level=info, LEVEL=WARN, time=... and msg="...",
+//   - JSON lines with level/lvl/severity, time/ts/timestamp and msg/message,
+//     including pino's numeric levels and millisecond times.
+//
+// ts is zero when the line carries no time. level is one of debug, info,
+// warning and error, or empty; without a level field it is guessed from
+// keywords in the message. msg is the line without the parts read into ts
+// and level, or the message field when there is one.
+func ParseLine(raw string) (ts time.Time, level string, msg string) {
+    if strings.HasPrefix(strings.TrimSpace(raw), "{") {
+        if ts, level, msg, ok := parseJSONLine(raw); ok {
+            return ts, level, msg
+        }
+    }
+
+    msg = raw
+    if t, rest, ok := cutLogTime(raw); ok {
+        ts, msg = t, rest
+    }
+    fields := logfmtFields(msg)
+    if ts.IsZero() {
+        for _, key := range []string{"time", "ts", "timestamp"} {
+            if t, err := time.Parse(time.RFC3339Nano, fields[key]); err == nil {
+                ts = t
+                break
+            }
+        }
+    }
+    if m, ok := fields["msg"]; ok {
+        msg = m
+    }
+    for _, key := range []string{"level", "lvl"} {
+        if v, ok := fields[key]; ok {
+            return ts, normalizeLevel(v), msg
+        }
+    }
+    return ts, parseLogLevel(msg), msg
+}
+
+// parseJSONLine handles a line holding one JSON object.
+func parseJSONLine(raw string) (ts time.Time, level string, msg string, ok bool) {
+    var obj map[string]any
+    if json.Unmarshal([]byte(raw), &obj) != nil {
+        return time.Time{}, "", "", false
+    }
+    msg = raw
+    for _, key := range []string{"msg", "message"} {
+        if s, isString := obj[key].(string); isString {
+            msg = s
+            break
+        }
+    }
+    for _, key := range []string{"time", "ts", "timestamp", "@timestamp"} {
+        switch v := obj[key].(type) {
+        case string:
+            if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+                ts = t
+            }
+        case float64:
+            // Unix seconds, as zap and others write them, or milliseconds
+            // as pino and Date.now() do; seconds stay below 1e12 until the
+            // year 33658
+            if v > 1e12 {
+                ts = time.UnixMilli(int64(v))
+                break
+            }
+            sec := int64(v)
+            ts = time.Unix(sec, int64((v-float64(sec))*1e9))
+        }
+        if !ts.IsZero() {
+            break
+        }
+    }
+    for _, key := range []string{"level", "lvl", "severity"} {
+        switch v := obj[key].(type) {
+        case string:
+            return ts, normalizeLevel(v), msg, true
+        case float64:
+            return ts, numericLevel(v), msg, true
+        }
+    }
+    return ts, parseLogLevel(msg), msg, true
+}
+
+// numericLevel maps pino and bunyan's numeric levels (10 trace, 20 debug,
+// 30 info, 40 warn, 50 error, 60 fatal) onto the LogEntry levels.
+func numericLevel(level float64) string {
+    switch {
+    case level >= 50:
+        return "error"
+    case level >= 40:
+        return "warning"
+    case level >= 30:
+        return "info"
+    case level > 0:
+        return "debug"
+    }
+    return ""
+}
+
+// cutLogTime splits off the timestamp a line starts with.
+func cutLogTime(line string) (time.Time, string, bool) {
+    rest := strings.TrimPrefix(line, "[")
+    bracketed := len(rest) < len(line)
+    end := strings.IndexAny(rest, "] ")
+    if end < 0 {
+        end = len(rest)
+    }
+    // Anything shorter can't be an RFC 3339 time
+    if end < len("2006-01-02T15:04:05Z") {
+        return time.Time{}, line, false
+    }
+    t, err := time.Parse(time.RFC3339Nano, rest[:end])
+    if err != nil {
+        return time.Time{}, line, false
+    }
+    rest = rest[end:]
+    if bracketed {
+        rest = strings.TrimPrefix(rest, "]")
+    }
+    return t, strings.TrimPrefix(rest, " "), true
+}
+
+// logfmtFields collects the key=value pairs of a line, keys lowercased.
+// Quoted values are unquoted; words without '=' are skipped.
+func logfmtFields(line string) map[string]string {
+    fields := map[string]string{}
+    for line != "" {
+        line = strings.TrimLeft(line, " ")
+        end := strings.IndexAny(line, "= ")
+        if end < 0 {
+            break
+        }
+        if line[end] == ' ' || end == 0 {
+            line = line[end+1:]
+            continue
+        }
+        key := strings.ToLower(line[:end])
+        line = line[end+1:]
+        if quoted, err := strconv.QuotedPrefix(line); err == nil {
+            fields[key], _ = strconv.Unquote(quoted)
+            line = line[len(quoted):]
+            continue
+        }
+        value, rest, _ := strings.Cut(line, " ")
+        fields[key] = value
+        line = rest
+    }
+    return fields
+}
+
+// normalizeLevel maps the level names loggers use onto the four LogEntry
+// levels, or "" for one it doesn't know.
+func normalizeLevel(level string) string {
+    switch strings.ToLower(level) {
+    case "error", "err", "fatal", "panic", "crit", "critical", "alert", "emerg":
+        return "error"
+    case "warning", "warn":
+        return "warning"
+    case "info", "notice":
+        return "info"
+    case "debug", "trace":
+        return "debug"
+    }
+    return ""
+}
+
+// parseLogLevel guesses the level of a line without a level field from the
+// words in it
+func parseLogLevel(line string) string {
+    line = strings.ToLower(line)
+
+    if strings.Contains(line, "error") || strings.Contains(line, "err") {
+        return "error"
+    }
+    if strings.Contains(line, "warn") {
+        return "warning"
+    }
+    if strings.Contains(line, "info") {
+        return "info"
+    }
+    if strings.Contains(line, "debug") {
+        return "debug"
+    }
+
+    return ""
+}
@@ -0,0 +1,33 @@
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	stamp := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		raw, level, msg string
+		ts              time.Time
+	}{
+		{"[2024-05-01T10:00:00Z] Starting process: npx []", "", "Starting process: npx []", stamp},
+		{"2024-05-01T10:00:00Z disk error", "error", "disk error", stamp},
+		{`time=2024-05-01T10:00:00Z LEVEL=WARN msg="slow \"tool\" call" took=3s`, "warning", `slow "tool" call`, stamp},
+		{"level=info listening on :8080", "info", "level=info listening on :8080", time.Time{}},
+		{`{"level":"fatal","msg":"boom","time":"2024-05-01T10:00:00Z"}`, "error", "boom", stamp},
+		{`{"severity":"DEBUG","message":"tick","ts":1714557600}`, "debug", "tick", stamp},
+		{`{"level":30,"time":1714557600000,"msg":"listening"}`, "info", "listening", stamp},
+		{`{"level":50,"time":1714557600123,"msg":"request failed"}`, "error", "request failed", stamp.Add(123 * time.Millisecond)},
+		{`{"level":40,"msg":"slow"}`, "warning", "slow", time.Time{}},
+		{`{"level":10,"msg":"an error in the trace"}`, "debug", "an error in the trace", time.Time{}},
+		{"level=verbose no warnings here", "", "level=verbose no warnings here", time.Time{}},
+		{"{not json, but an error}", "error", "{not json, but an error}", time.Time{}},
+		{"plain line", "", "plain line", time.Time{}},
+	} {
+		ts, level, msg := ParseLine(tc.raw)
+		if !ts.Equal(tc.ts) || level != tc.level || msg != tc.msg {
+			t.Errorf("ParseLine(%q) = %v, %q, %q; want %v, %q, %q", tc.raw, ts, level, msg, tc.ts, tc.level, tc.msg)
+		}
+	}
+}
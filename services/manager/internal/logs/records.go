@@ -0,0 +1,70 @@
+package logs
+
+import (
+    "regexp"
+    "time"
+)
+
+// recordGrouper folds physical log lines into records. With no start pattern
+// every line is its own record. Otherwise a line matching start opens a record
+// and the lines after it are appended until the next match. A record keeps the
+// line number of its first line, so fromLine/LastSeen stay stable no matter
+// how many continuation lines follow.
+//
+// A record is dated and leveled by ParseLine on its first line. Undated, it
+// is dated now, which suits lines read as they are written; with datePast
+// set, for reading back old lines, it takes the date of the last dated
+// record before it instead.
+type recordGrouper struct {
+    process  string
+    start    *regexp.Regexp
+    pending  *LogEntry
+    datePast bool
+    lastTime time.Time
+}
+
+// add feeds one line and returns the record it completes, if any.
+func (g *recordGrouper) add(lineNum int64, text string) (LogEntry, bool) {
+    if g.start == nil {
+        return g.newEntry(lineNum, text), true
+    }
+    if g.pending != nil && !g.start.MatchString(text) {
+        g.pending.Message += "\n" + text
+        return LogEntry{}, false
+    }
+    done, ok := g.flush()
+    entry := g.newEntry(lineNum, text)
+    g.pending = &entry
+    return done, ok
+}
+
+// flush returns the buffered record, if any, and clears it.
+func (g *recordGrouper) flush() (LogEntry, bool) {
+    if g.pending == nil {
+        return LogEntry{}, false
+    }
+    entry := *g.pending
+    g.pending = nil
+    return entry, true
+}
+
+func (g *recordGrouper) newEntry(lineNum int64, text string) LogEntry {
+    stamp, level, _ := ParseLine(text)
+    switch {
+    case !stamp.IsZero():
+        g.lastTime = stamp
+    case g.datePast && !g.lastTime.IsZero():
+        stamp = g.lastTime
+    default:
+        stamp = time.Now()
+    }
+    // Message stays the raw line, with the fields ParseLine read from it
+    return LogEntry{
+        Timestamp: stamp,
+        Process:   g.process,
+        Message:   text,
+        Line:      lineNum,
+        Level:     level,
+    }
+}
+
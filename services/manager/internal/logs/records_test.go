@@ -0,0 +1,100 @@
+package logs
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRecordGrouper(t *testing.T) {
+	lines := []string{
+		"2024-01-01 INFO starting",
+		"2024-01-01 ERROR boom",
+		"    at foo (a.js:1)",
+		"    at bar (b.js:2)",
+		"2024-01-01 INFO recovered",
+	}
+
+	g := recordGrouper{process: "p"}
+	var got []LogEntry
+	for i, l := range lines {
+		if e, ok := g.add(int64(i+1), l); ok {
+			got = append(got, e)
+		}
+	}
+	if len(got) != len(lines) {
+		t.Fatalf("newline mode: want %d entries, got %d", len(lines), len(got))
+	}
+
+	g = recordGrouper{process: "p", start: regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)}
+	got = nil
+	for i, l := range lines {
+		if e, ok := g.add(int64(i+1), l); ok {
+			got = append(got, e)
+		}
+	}
+	if e, ok := g.flush(); ok {
+		got = append(got, e)
+	}
+	if len(got) != 3 {
+		t.Fatalf("pattern mode: want 3 records, got %d: %+v", len(got), got)
+	}
+	want := "2024-01-01 ERROR boom\n    at foo (a.js:1)\n    at bar (b.js:2)"
+	if got[1].Message != want || got[1].Line != 2 || got[1].Level != "error" {
+		t.Fatalf("unexpected multi-line record: %+v", got[1])
+	}
+	if got[2].Line != 5 {
+		t.Fatalf("record after continuation should keep its own line number, got %d", got[2].Line)
+	}
+}
+
+func TestStreamGroupsMultilineRecords(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "svc.log")
+	if err := os.WriteFile(path, []byte("[1] first\n{\n  \"a\": 1\n}\n[2] second\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ls := NewLogStreamer(dir)
+	defer ls.Stop()
+	if err := ls.SetRecordStart("svc", `^\[\d+\]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.SetRecordStart("svc", `(`); err == nil {
+		t.Fatal("expected error for invalid pattern")
+	}
+
+	client, err := ls.StreamLogs("c1", "svc", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := func() LogEntry {
+		t.Helper()
+		select {
+		case e := <-client.Ch:
+			return e
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for log entry")
+		}
+		return LogEntry{}
+	}
+
+	// fromLine=1 skips the first record, which starts on line 1
+	if e := next(); e.Line != 5 || e.Message != "[2] second" {
+		t.Fatalf("unexpected historical record: %+v", e)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("[3] panic\ngoroutine 1\n  main.go:10\n"); err != nil {
+		t.Fatal(err)
+	}
+	if e := next(); e.Line != 6 || e.Message != "[3] panic\ngoroutine 1\n  main.go:10" {
+		t.Fatalf("unexpected tailed record: %+v", e)
+	}
+}
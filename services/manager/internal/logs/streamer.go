@@ -2,13 +2,18 @@ package logs
 
 import (
     "bufio"
+    "bytes"
     "context"
     "fmt"
     "io"
+    "log"
     "os"
-    "strings"
+    "regexp"
     "sync"
+    "sync/atomic"
     "time"
+
+    "mcp/manager/internal/paths"
 )
 
 // LogEntry represents a single log entry
@@ -18,16 +23,57 @@ type LogEntry struct {
     Level      string    `json:"level,omitempty"`
     Message    string    `json:"message"`
     Line       int64     `json:"line"`
+    Event      string    `json:"event,omitempty"` // set only on control entries such as EventClosed
+    Count      int64     `json:"count,omitempty"` // entries an EventDropped entry stands in for
 }
 
+// EventClosed marks the last entry a client receives when its process's
+// stream is closed by CloseProcess.
+const EventClosed = "closed"
+
+// EventDropped stands in for Count entries a slow client missed under
+// OverflowMarker; its Line is the last of them.
+const EventDropped = "dropped"
+
+// EventDisconnected is the last entry of a client that stayed full past the
+// block timeout under OverflowBlock.
+const EventDisconnected = "disconnected"
+
+// OverflowPolicy decides what a stream does once its client has a full
+// buffer of entries it has not read yet.
+type OverflowPolicy string
+
+const (
+    // OverflowMarker drops entries and, as soon as there is room, sends one
+    // EventDropped entry counting them, so the client can tell where the gap is.
+    OverflowMarker OverflowPolicy = "marker"
+    // OverflowBlock waits up to the block timeout for room and then
+    // disconnects the client instead of losing entries.
+    OverflowBlock OverflowPolicy = "block"
+)
+
+// Defaults for the per-client stream settings of SetOverflow.
+const (
+    DefaultStreamBuffer = 100
+    DefaultBlockTimeout = 2 * time.Second
+)
+
 // StreamClient represents a client listening to log streams
 type StreamClient struct {
     ID       string
     Process  string
     Ch       chan LogEntry
     Cancel   context.CancelFunc
-    LastSeen int64 // Last line number seen
+    LastSeen int64 // Last line number seen; use atomic loads/stores
+    Filter   *Filter // entries that fail it are skipped; nil sends everything
     ctx      context.Context
+    
+    streamer     *LogStreamer
+    policy       OverflowPolicy
+    blockTimeout time.Duration
+    dropMu       sync.Mutex
+    dropped      int64 // entries not yet reported by an EventDropped entry; guarded by dropMu
+    lastDropped  int64 // guarded by dropMu
 }
 
 // LogStreamer provides real-time log streaming capabilities
@@ -36,6 +82,10 @@ type LogStreamer struct {
     clients      map[string]*StreamClient
     watchers     map[string]*LogWatcher // process -> watcher
     logsDir      string
+    recordStarts map[string]*regexp.Regexp // process -> start-of-record pattern
+    bufferSize   int
+    overflow     OverflowPolicy
+    blockTimeout time.Duration
     
     ctx          context.Context
     cancel       context.CancelFunc
@@ -49,10 +99,14 @@ type LogWatcher struct {
     file        *os.File
     scanner     *bufio.Scanner
     position    int64
-    lineCount   int64
+    lineCount   int64 // written by the watch goroutine; use atomic loads elsewhere
     lastCheck   time.Time
+    grouper     recordGrouper // only touched by the watch goroutine
+    counted     bool          // the existing lines are counted and lineCount is absolute
+    held        []LogEntry    // tailed before counted, numbered from the end of the file
     
     clients     map[string]*StreamClient
+    recordStart *regexp.Regexp // guarded by mu, picked up by grouper on the next check
     mu          sync.RWMutex
     
     ctx         context.Context
@@ -64,14 +118,70 @@ func NewLogStreamer(logsDir string) *LogStreamer {
     ctx, cancel := context.WithCancel(context.Background())
     
     return &LogStreamer{
-        clients:  make(map[string]*StreamClient),
-        watchers: make(map[string]*LogWatcher),
-        logsDir:  logsDir,
-        ctx:      ctx,
-        cancel:   cancel,
+        clients:      make(map[string]*StreamClient),
+        watchers:     make(map[string]*LogWatcher),
+        logsDir:      logsDir,
+        recordStarts: make(map[string]*regexp.Regexp),
+        bufferSize:   DefaultStreamBuffer,
+        overflow:     OverflowMarker,
+        blockTimeout: DefaultBlockTimeout,
+        ctx:          ctx,
+        cancel:       cancel,
     }
 }
 
+// SetRecordStart makes the streamer group a process's log lines into records
+// that begin at lines matching pattern, so multi-line output such as stack
+// traces arrives as one LogEntry. An empty pattern restores one entry per line.
+func (ls *LogStreamer) SetRecordStart(process, pattern string) error {
+    var re *regexp.Regexp
+    if pattern != "" {
+        var err error
+        if re, err = regexp.Compile(pattern); err != nil {
+            return fmt.Errorf("invalid record start pattern for %s: %w", process, err)
+        }
+    }
+    
+    ls.mu.Lock()
+    defer ls.mu.Unlock()
+    if re == nil {
+        delete(ls.recordStarts, process)
+    } else {
+        ls.recordStarts[process] = re
+    }
+    if watcher, exists := ls.watchers[process]; exists {
+        watcher.mu.Lock()
+        watcher.recordStart = re
+        watcher.mu.Unlock()
+    }
+    return nil
+}
+
+// SetOverflow sets how many entries each stream buffers for its client and
+// what happens when a client falls that far behind. Zero values and an empty
+// policy keep the defaults. Streams opened earlier keep their settings.
+func (ls *LogStreamer) SetOverflow(bufferSize int, policy OverflowPolicy, blockTimeout time.Duration) error {
+    switch policy {
+    case "":
+        policy = OverflowMarker
+    case OverflowMarker, OverflowBlock:
+    default:
+        return fmt.Errorf("unknown log stream overflow policy %q", policy)
+    }
+    if bufferSize <= 0 {
+        bufferSize = DefaultStreamBuffer
+    }
+    bufferSize = max(bufferSize, 2) // one entry plus the slot kept for the drop marker
+    if blockTimeout <= 0 {
+        blockTimeout = DefaultBlockTimeout
+    }
+    
+    ls.mu.Lock()
+    defer ls.mu.Unlock()
+    ls.bufferSize, ls.overflow, ls.blockTimeout = bufferSize, policy, blockTimeout
+    return nil
+}
+
 // Start begins the log streaming service
 func (ls *LogStreamer) Start() {
     // Start cleanup goroutine for disconnected clients
@@ -102,8 +212,9 @@ func (ls *LogStreamer) Stop() {
     ls.wg.Wait()
 }
 
-// StreamLogs creates a new streaming client for a process
-func (ls *LogStreamer) StreamLogs(clientID, process string, fromLine int64) (*StreamClient, error) {
+// StreamLogs creates a new streaming client for a process. Only entries
+// that pass filter are sent to it; filter may be nil.
+func (ls *LogStreamer) StreamLogs(clientID, process string, fromLine int64, filter *Filter) (*StreamClient, error) {
     ls.mu.Lock()
     defer ls.mu.Unlock()
     
@@ -121,10 +232,15 @@ func (ls *LogStreamer) StreamLogs(clientID, process string, fromLine int64) (*St
     client := &StreamClient{
         ID:       clientID,
         Process:  process,
-        Ch:       make(chan LogEntry, 100), // Buffered channel
+        Ch:       make(chan LogEntry, ls.bufferSize),
         Cancel:   cancel,
         LastSeen: fromLine,
+        Filter:   filter,
         ctx:      ctx,
+        
+        streamer:     ls,
+        policy:       ls.overflow,
+        blockTimeout: ls.blockTimeout,
     }
     
     ls.clients[clientID] = client
@@ -171,6 +287,140 @@ func (ls *LogStreamer) StopStream(clientID string) {
     }
 }
 
+// CloseProcess ends every stream of process, e.g. because the server was
+// stopped or deleted. Each client receives a final entry with Event set to
+// EventClosed before its channel is closed, and the watcher is torn down.
+func (ls *LogStreamer) CloseProcess(process string) {
+    ls.mu.Lock()
+    defer ls.mu.Unlock()
+    
+    if watcher, exists := ls.watchers[process]; exists {
+        watcher.Stop()
+        delete(ls.watchers, process)
+    }
+    
+    closed := LogEntry{Timestamp: time.Now(), Process: process, Event: EventClosed}
+    for clientID, client := range ls.clients {
+        if client.Process != process {
+            continue
+        }
+        client.Cancel()
+        sendFinal(client.Ch, closed)
+        close(client.Ch)
+        delete(ls.clients, clientID)
+    }
+}
+
+// disconnect ends the stream of a client that stayed full past its block
+// timeout. The client is told with a final EventDisconnected entry.
+func (ls *LogStreamer) disconnect(client *StreamClient) {
+    ls.mu.Lock()
+    defer ls.mu.Unlock()
+    
+    if ls.clients[client.ID] != client {
+        return // already stopped or replaced
+    }
+    if watcher, exists := ls.watchers[client.Process]; exists {
+        watcher.RemoveClient(client.ID)
+    }
+    client.Cancel()
+    sendFinal(client.Ch, LogEntry{Timestamp: time.Now(), Process: client.Process, Event: EventDisconnected})
+    close(client.Ch)
+    delete(ls.clients, client.ID)
+}
+
+// sendFinal queues the last entry of a stream that is about to be closed,
+// discarding the oldest queued entry if that is what it takes to fit.
+func sendFinal(ch chan LogEntry, entry LogEntry) {
+    select {
+    case ch <- entry:
+        return
+    default:
+    }
+    // Make room so a slow reader still sees the end of the stream
+    select {
+    case <-ch:
+    default:
+    }
+    select {
+    case ch <- entry:
+    default:
+    }
+}
+
+// deliver queues entry for the client under its overflow policy. It returns
+// false once the client is gone and nothing more should be sent to it.
+func (c *StreamClient) deliver(entry LogEntry) bool {
+    if c.policy == OverflowBlock {
+        select {
+        case c.Ch <- entry:
+            atomic.StoreInt64(&c.LastSeen, entry.Line)
+            return true
+        case <-c.ctx.Done():
+            return false
+        default:
+        }
+        timer := time.NewTimer(c.blockTimeout)
+        defer timer.Stop()
+        select {
+        case c.Ch <- entry:
+            atomic.StoreInt64(&c.LastSeen, entry.Line)
+            return true
+        case <-c.ctx.Done():
+            return false
+        case <-timer.C:
+            c.streamer.disconnect(c)
+            return false
+        }
+    }
+    
+    if c.ctx.Err() != nil {
+        return false
+    }
+    c.dropMu.Lock()
+    defer c.dropMu.Unlock()
+    // The last slot is kept free for the drop marker, which goes ahead of
+    // the first entry that fits again
+    if len(c.Ch) < cap(c.Ch)-1 && c.flushDroppedLocked() {
+        select {
+        case c.Ch <- entry:
+            atomic.StoreInt64(&c.LastSeen, entry.Line)
+            return true
+        default:
+        }
+    }
+    c.dropped++
+    c.lastDropped = entry.Line
+    atomic.StoreInt64(&c.LastSeen, entry.Line)
+    return true
+}
+
+// flushDropped sends the drop marker for entries dropped since the last one,
+// if there are any and it fits.
+func (c *StreamClient) flushDropped() {
+    if c.policy == OverflowBlock || c.ctx.Err() != nil {
+        return
+    }
+    c.dropMu.Lock()
+    defer c.dropMu.Unlock()
+    c.flushDroppedLocked()
+}
+
+// flushDroppedLocked reports whether no drops are left unreported.
+func (c *StreamClient) flushDroppedLocked() bool {
+    if c.dropped == 0 {
+        return true
+    }
+    marker := LogEntry{Timestamp: time.Now(), Process: c.Process, Line: c.lastDropped, Event: EventDropped, Count: c.dropped}
+    select {
+    case c.Ch <- marker:
+        c.dropped = 0
+        return true
+    default:
+        return false
+    }
+}
+
 // createWatcher creates a new log watcher for a process
 func (ls *LogStreamer) createWatcher(process string) (*LogWatcher, error) {
     filePath := fmt.Sprintf("%s/%s.log", ls.logsDir, process)
@@ -178,11 +428,13 @@ func (ls *LogStreamer) createWatcher(process string) (*LogWatcher, error) {
     ctx, cancel := context.WithCancel(ls.ctx)
     
     watcher := &LogWatcher{
-        process:  process,
-        filePath: filePath,
-        clients:  make(map[string]*StreamClient),
-        ctx:      ctx,
-        cancel:   cancel,
+        process:     process,
+        filePath:    filePath,
+        clients:     make(map[string]*StreamClient),
+        ctx:         ctx,
+        cancel:      cancel,
+        grouper:     recordGrouper{process: process, start: ls.recordStarts[process]},
+        recordStart: ls.recordStarts[process],
     }
     
     // Start the watcher
@@ -205,32 +457,32 @@ func (ls *LogStreamer) sendHistoricalLogs(client *StreamClient, fromLine int64)
     }
     defer file.Close()
     
+    ls.mu.RLock()
+    grouper := recordGrouper{process: client.Process, start: ls.recordStarts[client.Process], datePast: true}
+    ls.mu.RUnlock()
+    
+    send := func(entry LogEntry) bool {
+        // Records are numbered by their first line, so a record that began
+        // at or before fromLine has already been seen
+        if entry.Line <= fromLine || !client.Filter.Match(entry) {
+            return true
+        }
+        return client.deliver(entry)
+    }
+    defer client.flushDropped()
+    
     scanner := bufio.NewScanner(file)
     lineNum := int64(0)
     
     for scanner.Scan() {
         lineNum++
-        
-        if lineNum <= fromLine {
-            continue
-        }
-        
-        entry := LogEntry{
-            Timestamp: time.Now(), // TODO: Parse timestamp from log line
-            Process:   client.Process,
-            Message:   scanner.Text(),
-            Line:      lineNum,
-        }
-        
-        select {
-        case client.Ch <- entry:
-            client.LastSeen = lineNum
-        case <-client.ctx.Done():
+        if entry, ok := grouper.add(lineNum, scanner.Text()); ok && !send(entry) {
             return
-        default:
-            // Channel is full, skip this entry
         }
     }
+    if entry, ok := grouper.flush(); ok {
+        send(entry)
+    }
 }
 
 // cleanupLoop periodically cleans up disconnected clients
@@ -282,7 +534,7 @@ func (ls *LogStreamer) cleanupDisconnectedClients() {
 func (lw *LogWatcher) Start() error {
     // Try to open the file, create if it doesn't exist
     var err error
-    lw.file, err = os.OpenFile(lw.filePath, os.O_RDONLY|os.O_CREATE, 0644)
+    lw.file, err = paths.OpenFile(lw.filePath, os.O_RDONLY|os.O_CREATE, paths.FileMode())
     if err != nil {
         return fmt.Errorf("failed to open log file %s: %w", lw.filePath, err)
     }
@@ -298,21 +550,48 @@ func (lw *LogWatcher) Start() error {
     lw.file.Seek(lw.position, io.SeekStart)
     lw.scanner = bufio.NewScanner(lw.file)
     
-    // Count existing lines
-    if tempFile, err := os.Open(lw.filePath); err == nil {
-        tempScanner := bufio.NewScanner(tempFile)
-        for tempScanner.Scan() {
-            lw.lineCount++
-        }
-        tempFile.Close()
-    }
-    
     // Start watching goroutine
-    go lw.watch()
+    go lw.watch(lw.position)
     
     return nil
 }
 
+// countLines counts the lines in the first size bytes of path the way
+// bufio.Scanner would split them, reading in fixed chunks so memory stays
+// flat however large the file is. It gives up when ctx is cancelled.
+func countLines(ctx context.Context, path string, size int64) (int64, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return 0, err
+    }
+    defer f.Close()
+    
+    r := io.LimitReader(f, size)
+    buf := make([]byte, 1<<20)
+    var lines int64
+    last := byte('\n')
+    for {
+        if err := ctx.Err(); err != nil {
+            return lines, err
+        }
+        n, err := r.Read(buf)
+        if n > 0 {
+            lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+            last = buf[n-1]
+        }
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return lines, err
+        }
+    }
+    if last != '\n' {
+        lines++ // unterminated final line
+    }
+    return lines, nil
+}
+
 // Stop stops the log watcher
 func (lw *LogWatcher) Stop() {
     lw.cancel()
@@ -343,7 +622,20 @@ func (lw *LogWatcher) ClientCount() int {
 }
 
 // watch continuously monitors the log file for changes
-func (lw *LogWatcher) watch() {
+func (lw *LogWatcher) watch(startSize int64) {
+    // Live line numbers continue from the lines already in the file. Counting
+    // them can take a while on a huge log, so tailing starts right away and
+    // the count is added once it is known; until then tailed entries are held
+    // back, since clients filter on absolute line numbers.
+    counted := make(chan int64, 1)
+    go func() {
+        count, err := countLines(lw.ctx, lw.filePath, startSize)
+        if err != nil && lw.ctx.Err() == nil {
+            log.Printf("logs: failed to count lines in %s, numbering from %d: %v", lw.filePath, count, err)
+        }
+        counted <- count
+    }()
+    
     ticker := time.NewTicker(500 * time.Millisecond) // Check every 500ms
     defer ticker.Stop()
     
@@ -351,12 +643,41 @@ func (lw *LogWatcher) watch() {
         select {
         case <-lw.ctx.Done():
             return
+        case base := <-counted:
+            counted = nil
+            lw.applyBase(base)
         case <-ticker.C:
             lw.checkForNewLines()
         }
     }
 }
 
+// applyBase shifts everything numbered so far by the base existing lines and
+// releases the entries held back while they were being counted.
+func (lw *LogWatcher) applyBase(base int64) {
+    atomic.AddInt64(&lw.lineCount, base)
+    if lw.grouper.pending != nil {
+        lw.grouper.pending.Line += base
+    }
+    held := lw.held
+    for i := range held {
+        held[i].Line += base
+    }
+    lw.counted, lw.held = true, nil
+    if len(held) > 0 {
+        lw.broadcastEntries(held)
+    }
+}
+
+// publish broadcasts entries, or holds them until the existing lines are counted
+func (lw *LogWatcher) publish(entries []LogEntry) {
+    if !lw.counted {
+        lw.held = append(lw.held, entries...)
+        return
+    }
+    lw.broadcastEntries(entries)
+}
+
 // checkForNewLines checks for new lines in the log file
 func (lw *LogWatcher) checkForNewLines() {
     // Check if file has grown
@@ -365,7 +686,26 @@ func (lw *LogWatcher) checkForNewLines() {
         return
     }
     
+    var newEntries []LogEntry
+    
+    lw.mu.RLock()
+    recordStart := lw.recordStart
+    lw.mu.RUnlock()
+    if recordStart != lw.grouper.start {
+        if entry, ok := lw.grouper.flush(); ok {
+            newEntries = append(newEntries, entry)
+        }
+        lw.grouper.start = recordStart
+    }
+    
     if stat.Size() <= lw.position {
+        // A quiet tick ends any record still collecting continuation lines
+        if entry, ok := lw.grouper.flush(); ok {
+            newEntries = append(newEntries, entry)
+        }
+        if len(newEntries) > 0 {
+            lw.publish(newEntries)
+        }
         return // No new content
     }
     
@@ -373,21 +713,11 @@ func (lw *LogWatcher) checkForNewLines() {
     lw.file.Seek(lw.position, io.SeekStart)
     scanner := bufio.NewScanner(lw.file)
     
-    var newEntries []LogEntry
-    
     for scanner.Scan() {
-        lw.lineCount++
-        line := scanner.Text()
-        
-        entry := LogEntry{
-            Timestamp: time.Now(), // TODO: Parse timestamp from log line
-            Process:   lw.process,
-            Message:   line,
-            Line:      lw.lineCount,
-            Level:     parseLogLevel(line), // TODO: Implement log level parsing
+        lineNum := atomic.AddInt64(&lw.lineCount, 1)
+        if entry, ok := lw.grouper.add(lineNum, scanner.Text()); ok {
+            newEntries = append(newEntries, entry)
         }
-        
-        newEntries = append(newEntries, entry)
     }
     
     // Update position
@@ -396,7 +726,7 @@ func (lw *LogWatcher) checkForNewLines() {
     
     // Send entries to all clients
     if len(newEntries) > 0 {
-        lw.broadcastEntries(newEntries)
+        lw.publish(newEntries)
     }
 }
 
@@ -412,43 +742,17 @@ func (lw *LogWatcher) broadcastEntries(entries []LogEntry) {
     for _, client := range clients {
         for _, entry := range entries {
             // Only send entries newer than what client has seen
-            if entry.Line > client.LastSeen {
-                select {
-                case client.Ch <- entry:
-                    client.LastSeen = entry.Line
-                case <-client.ctx.Done():
-                    // Client disconnected, will be cleaned up later
-                    continue
-                default:
-                    // Channel is full, skip this entry
-                    // TODO: Could implement overflow handling
-                }
+            if entry.Line <= atomic.LoadInt64(&client.LastSeen) || !client.Filter.Match(entry) {
+                continue
+            }
+            if !client.deliver(entry) {
+                break // disconnected, cleaned up elsewhere
             }
         }
+        client.flushDropped()
     }
 }
 
-// parseLogLevel attempts to parse log level from log line
-func parseLogLevel(line string) string {
-    // Simple log level detection
-    line = strings.ToLower(line)
-    
-    if strings.Contains(line, "error") || strings.Contains(line, "err") {
-        return "error"
-    }
-    if strings.Contains(line, "warn") {
-        return "warning"
-    }
-    if strings.Contains(line, "info") {
-        return "info"
-    }
-    if strings.Contains(line, "debug") {
-        return "debug"
-    }
-    
-    return ""
-}
-
 // GetActiveStreams returns information about active streams
 func (ls *LogStreamer) GetActiveStreams() map[string]interface{} {
     ls.mu.RLock()
@@ -465,7 +769,7 @@ func (ls *LogStreamer) GetActiveStreams() map[string]interface{} {
         clientInfo := map[string]interface{}{
             "id":       client.ID,
             "process":  client.Process,
-            "lastSeen": client.LastSeen,
+            "lastSeen": atomic.LoadInt64(&client.LastSeen),
         }
         result["clients"] = append(result["clients"].([]map[string]interface{}), clientInfo)
     }
@@ -474,7 +778,7 @@ func (ls *LogStreamer) GetActiveStreams() map[string]interface{} {
         watcherInfo := map[string]interface{}{
             "process":     watcher.process,
             "filePath":    watcher.filePath,
-            "lineCount":   watcher.lineCount,
+            "lineCount":   atomic.LoadInt64(&watcher.lineCount),
             "position":    watcher.position,
             "clientCount": watcher.ClientCount(),
             "lastCheck":   watcher.lastCheck,
@@ -0,0 +1,235 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.log")
+	for content, want := range map[string]int64{
+		"":               0,
+		"one\n":          1,
+		"one\ntwo":       2,
+		"one\n\nthree\n": 3,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := countLines(context.Background(), path, int64(len(content)))
+		if err != nil || got != want {
+			t.Fatalf("countLines(%q) = %d, %v; want %d", content, got, err, want)
+		}
+	}
+}
+
+func TestStreamOpensLargeLogQuickly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.log")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// A sparse 4GB file: reading it all, as a full line count does, takes seconds
+	if err := f.Truncate(4 << 30); err != nil {
+		t.Skipf("sparse files not supported: %v", err)
+	}
+
+	ls := NewLogStreamer(dir)
+	defer ls.Stop()
+
+	start := time.Now()
+	if _, err := ls.StreamLogs("c1", "big", -1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("opening a stream on a 4GB log took %v", elapsed)
+	}
+}
+
+func TestTailNumbersLinesAfterExistingContent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "svc.log")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ls := NewLogStreamer(dir)
+	defer ls.Stop()
+	client, err := ls.StreamLogs("c1", "svc", -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("four\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-client.Ch:
+		if e.Line != 4 || e.Message != "four" {
+			t.Fatalf("unexpected entry: %+v", e)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for tailed line")
+	}
+}
+
+func TestTailHeldUntilLinesCounted(t *testing.T) {
+	client := &StreamClient{ID: "c1", Ch: make(chan LogEntry, 4), LastSeen: 3, ctx: context.Background()}
+	lw := &LogWatcher{clients: map[string]*StreamClient{"c1": client}}
+
+	// Tailed before the count: numbered from the end of the file and held
+	lw.publish([]LogEntry{{Line: 1, Message: "four"}, {Line: 2, Message: "five"}})
+	if len(client.Ch) != 0 {
+		t.Fatal("entries sent before the existing lines were counted")
+	}
+
+	lw.applyBase(3)
+	for _, want := range []LogEntry{{Line: 4, Message: "four"}, {Line: 5, Message: "five"}} {
+		if e := <-client.Ch; e.Line != want.Line || e.Message != want.Message {
+			t.Fatalf("got %+v, want %+v", e, want)
+		}
+	}
+
+	lw.publish([]LogEntry{{Line: 6, Message: "six"}})
+	if e := <-client.Ch; e.Line != 6 {
+		t.Fatalf("entry after the count: %+v", e)
+	}
+}
+
+func TestCloseProcessEndsStreams(t *testing.T) {
+	dir := t.TempDir()
+	ls := NewLogStreamer(dir)
+	defer ls.Stop()
+
+	a, err := ls.StreamLogs("a", "svc", -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ls.StreamLogs("b", "other", -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ls.CloseProcess("svc")
+
+	select {
+	case e, ok := <-a.Ch:
+		if !ok || e.Event != EventClosed || e.Process != "svc" {
+			t.Fatalf("first entry after close = %+v, %v; want a closed event", e, ok)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no closed event")
+	}
+	if _, ok := <-a.Ch; ok {
+		t.Fatal("channel still open after the closed event")
+	}
+
+	streams := ls.GetActiveStreams()
+	if streams["totalClients"] != 1 || streams["totalWatchers"] != 1 {
+		t.Fatalf("streams after close: %v", streams)
+	}
+	select {
+	case e := <-other.Ch:
+		t.Fatalf("other process's stream got %+v", e)
+	default:
+	}
+
+	// The client's own cleanup after the close is a no-op
+	ls.StopStream("a")
+}
+
+func writeLines(t *testing.T, path string, n int) {
+	t.Helper()
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlowClientGetsDropMarker(t *testing.T) {
+	dir := t.TempDir()
+	writeLines(t, filepath.Join(dir, "svc.log"), 300)
+
+	ls := NewLogStreamer(dir)
+	defer ls.Stop()
+	client, err := ls.StreamLogs("slow", "svc", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Don't read until the history has filled the buffer
+	deadline := time.Now().Add(3 * time.Second)
+	for len(client.Ch) < cap(client.Ch) {
+		if time.Now().After(deadline) {
+			t.Fatalf("buffer never filled: %d of %d", len(client.Ch), cap(client.Ch))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for want := int64(1); want < DefaultStreamBuffer; want++ {
+		if e := <-client.Ch; e.Line != want || e.Event != "" {
+			t.Fatalf("entry %d = %+v", want, e)
+		}
+	}
+	e := <-client.Ch
+	if e.Event != EventDropped || e.Count != 300-(DefaultStreamBuffer-1) || e.Line != 300 {
+		t.Fatalf("expected a drop marker for the rest, got %+v", e)
+	}
+}
+
+func TestBlockingOverflowDisconnectsSlowClient(t *testing.T) {
+	dir := t.TempDir()
+	writeLines(t, filepath.Join(dir, "svc.log"), 10)
+
+	ls := NewLogStreamer(dir)
+	defer ls.Stop()
+	if err := ls.SetOverflow(2, OverflowBlock, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := ls.SetOverflow(2, "sometimes", 0); err == nil {
+		t.Fatal("unknown policy accepted")
+	}
+	client, err := ls.StreamLogs("slow", "svc", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last LogEntry
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case e, ok := <-client.Ch:
+			if !ok {
+				if last.Event != EventDisconnected {
+					t.Fatalf("stream closed after %+v, want a disconnected event", last)
+				}
+				if n := ls.GetActiveStreams()["totalClients"]; n != 0 {
+					t.Fatalf("%v clients left", n)
+				}
+				return
+			}
+			last = e
+			if e.Event == "" {
+				time.Sleep(50 * time.Millisecond) // slower than the block timeout
+			}
+		case <-timeout:
+			t.Fatal("slow client never disconnected")
+		}
+	}
+}
@@ -1,16 +1,64 @@
 package paths
 
 import (
+    "log"
     "os"
     "path/filepath"
+    "sync"
 )
 
-// HomeMCP returns the base ~/.mcp directory and ensures it exists.
-func HomeMCP() (string, error) {
+// EnvHome names the environment variable that relocates the ~/.mcp directory.
+// The daemon's --config-dir flag sets it so every package resolves the same root.
+const EnvHome = "MCP_HOME"
+
+// Root returns the base directory, $MCP_HOME or ~/.mcp, without creating it.
+func Root() (string, error) {
+    if dir := os.Getenv(EnvHome); dir != "" {
+        return filepath.Abs(dir)
+    }
     home, err := os.UserHomeDir()
     if err != nil { return "", err }
-    base := filepath.Join(home, ".mcp")
-    if err := os.MkdirAll(base, 0o755); err != nil { return "", err }
+    return filepath.Join(home, ".mcp"), nil
+}
+
+// SetRoot points Root at dir for this process and any children it spawns.
+func SetRoot(dir string) error {
+    abs, err := filepath.Abs(dir)
+    if err != nil { return err }
+    return os.Setenv(EnvHome, abs)
+}
+
+// configExts are the formats ConfigFile looks for, in order of preference.
+var configExts = []string{".yaml", ".yml", ".json"}
+
+var warnedConfigs sync.Map
+
+// ConfigFile returns the file under Root that holds the config called name,
+// e.g. "registry": name.yaml or name.yml if one exists, else name.json.
+// When more than one exists the first of that order wins and the others are
+// reported once as ignored.
+func ConfigFile(name string) (string, error) {
+    base, err := Root()
+    if err != nil { return "", err }
+    var found []string
+    for _, ext := range configExts {
+        p := filepath.Join(base, name+ext)
+        if _, err := os.Stat(p); err == nil { found = append(found, p) }
+    }
+    if len(found) == 0 { return filepath.Join(base, name+".json"), nil }
+    if len(found) > 1 {
+        if _, seen := warnedConfigs.LoadOrStore(found[0], true); !seen {
+            log.Printf("warning: using %s; ignoring %v", found[0], found[1:])
+        }
+    }
+    return found[0], nil
+}
+
+// HomeMCP returns the base directory (see Root) and ensures it exists.
+func HomeMCP() (string, error) {
+    base, err := Root()
+    if err != nil { return "", err }
+    if err := MkdirAll(base); err != nil { return "", err }
     return base, nil
 }
 
@@ -18,7 +66,7 @@ func LogsDir() (string, error) {
     base, err := HomeMCP()
     if err != nil { return "", err }
     p := filepath.Join(base, "logs")
-    if err := os.MkdirAll(p, 0o755); err != nil { return "", err }
+    if err := MkdirAll(p); err != nil { return "", err }
     return p, nil
 }
 
@@ -26,7 +74,17 @@ func ServersDir() (string, error) {
     base, err := HomeMCP()
     if err != nil { return "", err }
     p := filepath.Join(base, "servers")
-    if err := os.MkdirAll(p, 0o755); err != nil { return "", err }
+    if err := MkdirAll(p); err != nil { return "", err }
+    return p, nil
+}
+
+// RuntimesDir returns the directory of runtimes shared between servers
+// (~/.mcp/runtimes) and ensures it exists.
+func RuntimesDir() (string, error) {
+    base, err := HomeMCP()
+    if err != nil { return "", err }
+    p := filepath.Join(base, "runtimes")
+    if err := MkdirAll(p); err != nil { return "", err }
     return p, nil
 }
 
@@ -35,7 +93,7 @@ func CacheDir() (string, error) {
     base, err := HomeMCP()
     if err != nil { return "", err }
     p := filepath.Join(base, "cache")
-    if err := os.MkdirAll(p, 0o755); err != nil { return "", err }
+    if err := MkdirAll(p); err != nil { return "", err }
     return p, nil
 }
 
@@ -0,0 +1,100 @@
+package paths
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+)
+
+// EnvUmask names the environment variable holding the permission policy for
+// everything the manager creates, as an octal umask such as 027 or 007.
+// The daemon's --umask flag sets it so installers and children agree.
+const EnvUmask = "MCP_UMASK"
+
+// DefaultUmask keeps server directories, scripts, logs and config out of
+// reach of other users while leaving them readable by the owner's group.
+const DefaultUmask os.FileMode = 0o027
+
+// SecretMode is the mode of files holding credentials (.npmrc, vault files).
+// No umask can widen it.
+const SecretMode os.FileMode = 0o600
+
+// ParseUmask parses an octal umask such as "027" or "0o007".
+func ParseUmask(s string) (os.FileMode, error) {
+    if len(s) > 2 && (s[:2] == "0o" || s[:2] == "0O") { s = s[2:] }
+    v, err := strconv.ParseUint(s, 8, 32)
+    if err != nil || v > 0o777 {
+        return 0, fmt.Errorf("invalid umask %q: want an octal value up to 777", s)
+    }
+    return os.FileMode(v), nil
+}
+
+// SetUmask sets the permission policy for this process and any children it spawns.
+func SetUmask(mask os.FileMode) error {
+    if mask&^0o777 != 0 { return errors.New("umask may only hold permission bits") }
+    return os.Setenv(EnvUmask, fmt.Sprintf("%03o", mask))
+}
+
+// Umask returns the permission policy from $MCP_UMASK, or DefaultUmask when unset or invalid.
+func Umask() os.FileMode {
+    if s := os.Getenv(EnvUmask); s != "" {
+        if mask, err := ParseUmask(s); err == nil { return mask }
+    }
+    return DefaultUmask
+}
+
+// Mask applies the policy to perm. The owner's bits are never masked, so the
+// manager can always read back what it wrote.
+func Mask(perm os.FileMode) os.FileMode {
+    return perm.Perm()&^Umask() | perm.Perm()&0o700
+}
+
+// DirMode is the mode for directories the manager creates.
+func DirMode() os.FileMode { return Mask(0o777) }
+
+// FileMode is the mode for regular files: the registry, settings, manifests and logs.
+func FileMode() os.FileMode { return Mask(0o666) }
+
+// ExecMode is the mode for generated launcher scripts.
+func ExecMode() os.FileMode { return Mask(0o777) }
+
+// MkdirAll creates dir and any missing parents with DirMode. Only directories
+// it creates are chmodded, so existing ones such as $HOME keep their modes.
+func MkdirAll(dir string) error {
+    var created []string
+    for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+        if _, err := os.Stat(p); err == nil { break }
+        created = append(created, p)
+        if filepath.Dir(p) == p { break }
+    }
+    mode := DirMode()
+    if err := os.MkdirAll(dir, mode); err != nil { return err }
+    // The process umask may have narrowed the mode on the way in
+    for _, p := range created {
+        if err := os.Chmod(p, mode); err != nil { return err }
+    }
+    return nil
+}
+
+// WriteFile writes data to name and sets its mode to perm even if the file
+// already existed or the process umask would have narrowed it.
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+    if err := os.WriteFile(name, data, perm); err != nil { return err }
+    return os.Chmod(name, perm)
+}
+
+// OpenFile is os.OpenFile that, when flag creates the file, also sets its
+// mode to perm so files opened for appending follow the policy too.
+func OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+    f, err := os.OpenFile(name, flag, perm)
+    if err != nil { return nil, err }
+    if flag&os.O_CREATE != 0 {
+        if err := f.Chmod(perm); err != nil {
+            f.Close()
+            return nil, err
+        }
+    }
+    return f, nil
+}
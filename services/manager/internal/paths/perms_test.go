@@ -0,0 +1,96 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func modeOf(t *testing.T, path string) os.FileMode {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Mode().Perm()
+}
+
+func TestPermissionPolicy(t *testing.T) {
+	t.Setenv("MCP_HOME", t.TempDir())
+	t.Setenv(EnvUmask, "")
+
+	servers, err := ServersDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := modeOf(t, servers); got != 0o750 {
+		t.Fatalf("servers dir mode %o, want 750", got)
+	}
+
+	if err := SetUmask(0o007); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(servers, "demo", "bin")
+	if err := MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{dir, filepath.Dir(dir)} {
+		if got := modeOf(t, p); got != 0o770 {
+			t.Fatalf("%s mode %o, want 770", p, got)
+		}
+	}
+	// Existing parents keep their mode
+	if got := modeOf(t, servers); got != 0o750 {
+		t.Fatalf("servers dir changed to %o", got)
+	}
+
+	cases := map[string]struct {
+		perm os.FileMode
+		want os.FileMode
+	}{
+		"run.sh":        {ExecMode(), 0o770},
+		"manifest.json": {FileMode(), 0o660},
+		".npmrc":        {SecretMode, 0o600},
+	}
+	for name, c := range cases {
+		p := filepath.Join(dir, name)
+		if err := WriteFile(p, []byte("x"), c.perm); err != nil {
+			t.Fatal(err)
+		}
+		if got := modeOf(t, p); got != c.want {
+			t.Errorf("%s mode %o, want %o", name, got, c.want)
+		}
+	}
+
+	// A wide-open policy never reaches secrets or masks the owner
+	if err := SetUmask(0); err != nil {
+		t.Fatal(err)
+	}
+	if SecretMode != 0o600 || FileMode() != 0o666 {
+		t.Fatalf("umask 0: file %o, secret %o", FileMode(), SecretMode)
+	}
+	if err := SetUmask(0o777); err != nil {
+		t.Fatal(err)
+	}
+	if DirMode() != 0o700 || FileMode() != 0o600 {
+		t.Fatalf("umask 777: dir %o, file %o", DirMode(), FileMode())
+	}
+}
+
+func TestParseUmask(t *testing.T) {
+	for in, want := range map[string]os.FileMode{"027": 0o027, "0o007": 0o007, "0": 0} {
+		if got, err := ParseUmask(in); err != nil || got != want {
+			t.Errorf("ParseUmask(%q) = %o, %v", in, got, err)
+		}
+	}
+	for _, in := range []string{"", "8", "1000", "rw"} {
+		if _, err := ParseUmask(in); err == nil {
+			t.Errorf("ParseUmask(%q) accepted", in)
+		}
+	}
+
+	t.Setenv(EnvUmask, "bogus")
+	if Umask() != DefaultUmask {
+		t.Fatalf("invalid $%s gave %o", EnvUmask, Umask())
+	}
+}
@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"mcp/manager/internal/paths"
+)
+
+// customFileName is the file under the config dir that keeps user-defined
+// providers. It is always JSON.
+const customFileName = "providers.json"
+
+// customNameRE limits custom provider names to what fits in a credential ref
+var customNameRE = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ErrCustomSave is returned by AddCustomProvider when the file can't be written.
+var ErrCustomSave = errors.New("failed to save custom providers")
+
+// customMu serializes adding a custom provider with saving the file
+var customMu sync.Mutex
+
+// CustomProvidersFile returns the path of the custom providers file,
+// creating the config dir if needed.
+func CustomProvidersFile() (string, error) {
+	root, err := paths.HomeMCP()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, customFileName), nil
+}
+
+// checkCustom applies the rules a user-defined provider must meet on top of
+// AddProvider's.
+func checkCustom(provider Provider) error {
+	if !customNameRE.MatchString(provider.Name) {
+		return errors.New("provider name must be lowercase letters, digits, '-' or '_'")
+	}
+	switch provider.AuthType {
+	case AuthAPIKey, AuthOAuth2, AuthBasic:
+	default:
+		return fmt.Errorf("invalid auth type %q: want %s, %s or %s", provider.AuthType, AuthAPIKey, AuthOAuth2, AuthBasic)
+	}
+	for _, endpoint := range []string{provider.HealthEndpoint, provider.BaseURL, provider.TokenEndpoint} {
+		if endpoint == "" {
+			continue
+		}
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid endpoint %q: want an http or https URL", endpoint)
+		}
+	}
+	return nil
+}
+
+// AddCustomProvider adds a user-defined provider and saves it, with the
+// other custom providers, to path. Nothing is added if saving fails.
+func AddCustomProvider(path string, provider Provider) error {
+	if err := checkCustom(provider); err != nil {
+		return err
+	}
+	provider.Custom = true
+
+	customMu.Lock()
+	defer customMu.Unlock()
+
+	if err := AddProvider(provider); err != nil {
+		return err
+	}
+	if err := saveCustom(path); err != nil {
+		registryMu.Lock()
+		delete(providerRegistry, provider.Name)
+		registryMu.Unlock()
+		return fmt.Errorf("%w: %w", ErrCustomSave, err)
+	}
+	return nil
+}
+
+// LoadCustomProviders adds the providers saved in path. A missing file is
+// not an error. A provider that is invalid or clashes with an existing one
+// is skipped and reported, so one bad entry doesn't hide the rest.
+func LoadCustomProviders(path string) []error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return []error{err}
+	}
+	var saved []Provider
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return []error{fmt.Errorf("invalid %s: %w", path, err)}
+	}
+
+	var errs []error
+	for _, provider := range saved {
+		if err := checkCustom(provider); err != nil {
+			errs = append(errs, fmt.Errorf("provider '%s': %w", provider.Name, err))
+			continue
+		}
+		provider.Custom = true
+		if err := AddProvider(provider); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// saveCustom writes every custom provider to path, sorted by name.
+func saveCustom(path string) error {
+	registryMu.RLock()
+	var custom []Provider
+	for _, provider := range providerRegistry {
+		if provider.Custom {
+			custom = append(custom, provider)
+		}
+	}
+	registryMu.RUnlock()
+	sort.Slice(custom, func(i, j int) bool { return custom[i].Name < custom[j].Name })
+
+	data, err := json.MarshalIndent(custom, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := paths.WriteFile(tmp, data, paths.FileMode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,90 @@
+package providers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// removeProvider drops name from the registry when the test ends.
+func removeProvider(t *testing.T, name string) {
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(providerRegistry, name)
+		registryMu.Unlock()
+	})
+}
+
+func TestCustomProviders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+	provider := Provider{
+		Name:           "acme",
+		DisplayName:    "Acme",
+		AuthType:       AuthAPIKey,
+		HealthEndpoint: "https://api.acme.test/health",
+		Credentials: []Credential{
+			{Key: "api_key", DisplayName: "API Key", Required: true, Secret: true, Validation: `^acme_[a-z0-9]+$`, Example: "acme_123"},
+		},
+	}
+	removeProvider(t, "acme")
+
+	for _, tc := range []struct {
+		edit func(*Provider)
+		want string
+	}{
+		{func(p *Provider) { p.Name = "Acme:Prod" }, "provider name"},
+		{func(p *Provider) { p.AuthType = "token" }, "invalid auth type"},
+		{func(p *Provider) { p.HealthEndpoint = "ftp://acme.test" }, "invalid endpoint"},
+		{func(p *Provider) { p.Credentials = []Credential{{Key: "k", DisplayName: "K", Validation: "(["}} }, "invalid validation pattern"},
+		{func(p *Provider) { p.Name = "github" }, "already exists"},
+	} {
+		bad := provider
+		tc.edit(&bad)
+		if err := AddCustomProvider(path, bad); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("AddCustomProvider(%+v) = %v, want %q", bad, err, tc.want)
+		}
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("rejected providers were saved: %v", err)
+	}
+
+	if err := AddCustomProvider(path, provider); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddCustomProvider(path, provider); !errors.Is(err, ErrProviderExists) {
+		t.Fatalf("second add: %v", err)
+	}
+	if err := ValidateProviderConfig("acme", map[string]string{"api_key": "acme_42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart loads the file again
+	registryMu.Lock()
+	delete(providerRegistry, "acme")
+	registryMu.Unlock()
+	if errs := LoadCustomProviders(path); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if got, err := GetProvider("acme"); err != nil || !got.Custom || got.HealthEndpoint != provider.HealthEndpoint {
+		t.Fatalf("loaded %+v, %v", got, err)
+	}
+	if errs := LoadCustomProviders(filepath.Join(t.TempDir(), "missing.json")); len(errs) != 0 {
+		t.Fatalf("missing file: %v", errs)
+	}
+}
+
+func TestAddCustomProviderSaveFailure(t *testing.T) {
+	removeProvider(t, "unsaved")
+	err := AddCustomProvider(filepath.Join(t.TempDir(), "no-such-dir", "providers.json"), Provider{
+		Name:           "unsaved",
+		DisplayName:    "Unsaved",
+		AuthType:       AuthBasic,
+		HealthEndpoint: "https://unsaved.test",
+		Credentials:    []Credential{{Key: "username", DisplayName: "Username"}},
+	})
+	if !errors.Is(err, ErrCustomSave) || IsProviderSupported("unsaved") {
+		t.Fatalf("got %v, supported %v", err, IsProviderSupported("unsaved"))
+	}
+}
@@ -0,0 +1,185 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys kept with OAuth2 credentials besides the provider's own. ExpiresAtKey
+// holds the RFC 3339 time the access token expires; expires_in, in seconds,
+// may be given instead when credentials are stored and is turned into it.
+const (
+	ExpiresAtKey = "expires_at"
+	expiresInKey = "expires_in"
+)
+
+// ErrNoRefreshToken is returned by RefreshToken for credentials without a
+// refresh token.
+var ErrNoRefreshToken = errors.New("no refresh token stored")
+
+// oauthClient sends token refresh requests
+var oauthClient = &http.Client{Timeout: 30 * time.Second}
+
+// tokenResponse covers the fields Google, Microsoft and Slack answer a
+// refresh with. Slack reports errors with ok=false and a 200 status.
+type tokenResponse struct {
+	OK               *bool  `json:"ok"`
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// RefreshToken exchanges the refresh token in creds for a new access token at
+// the provider's token endpoint. It returns a copy of creds with the access
+// token replaced, and the refresh token too when the provider rotated it.
+func RefreshToken(ctx context.Context, providerName string, creds map[string]string) (map[string]string, error) {
+	provider, err := GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if provider.TokenEndpoint == "" {
+		return nil, fmt.Errorf("provider '%s' does not support token refresh", provider.Name)
+	}
+	if creds["refresh_token"] == "" {
+		return nil, ErrNoRefreshToken
+	}
+	if creds["client_id"] == "" || creds["client_secret"] == "" {
+		return nil, fmt.Errorf("refreshing a %s token needs client_id and client_secret", provider.DisplayName)
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {creds["refresh_token"]},
+		"client_id":     {creds["client_id"]},
+		"client_secret": {creds["client_secret"]},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token refresh failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err == nil {
+		err = json.Unmarshal(body, &token)
+	}
+	switch {
+	case token.Error != "" || (token.OK != nil && !*token.OK):
+		reason := token.Error
+		if token.ErrorDescription != "" {
+			reason += ": " + token.ErrorDescription
+		}
+		return nil, fmt.Errorf("token refresh rejected by %s: %s", provider.DisplayName, reason)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("token refresh failed: %s returned %d", provider.DisplayName, resp.StatusCode)
+	case err != nil:
+		return nil, fmt.Errorf("token refresh failed: invalid response: %w", err)
+	case token.AccessToken == "":
+		return nil, fmt.Errorf("token refresh failed: %s returned no access token", provider.DisplayName)
+	}
+
+	refreshed := make(map[string]string, len(creds))
+	for k, v := range creds {
+		refreshed[k] = v
+	}
+	refreshed[accessTokenKey(provider)] = token.AccessToken
+	if token.RefreshToken != "" {
+		refreshed["refresh_token"] = token.RefreshToken
+	}
+	delete(refreshed, ExpiresAtKey)
+	if token.ExpiresIn > 0 {
+		refreshed[expiresInKey] = strconv.FormatInt(token.ExpiresIn, 10)
+	}
+	RecordExpiry(provider.Name, refreshed)
+	return refreshed, nil
+}
+
+// accessTokenKey is the credential an OAuth2 provider's access token is
+// stored under. Slack's rotating tokens stand in for the bot token.
+func accessTokenKey(provider Provider) string {
+	if provider.Name == "slack" {
+		return "bot_token"
+	}
+	return "access_token"
+}
+
+// RecordExpiry sets creds[ExpiresAtKey] for an OAuth2 provider from an
+// expires_in in creds, which it removes, or else from the exp claim of a JWT
+// access token. A new access token whose expiry can't be told clears the
+// expiry it replaces, so that a merge into stored credentials drops it too.
+// Credentials of other providers are left alone.
+func RecordExpiry(providerName string, creds map[string]string) {
+	provider, err := GetProvider(providerName)
+	if err != nil || provider.AuthType != AuthOAuth2 {
+		return
+	}
+	if v, ok := creds[expiresInKey]; ok {
+		delete(creds, expiresInKey)
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil && seconds > 0 {
+			creds[ExpiresAtKey] = time.Now().Add(time.Duration(seconds) * time.Second).UTC().Format(time.RFC3339)
+			return
+		}
+	}
+	token, hasToken := creds[accessTokenKey(provider)]
+	if _, set := creds[ExpiresAtKey]; set || !hasToken {
+		return
+	}
+	creds[ExpiresAtKey] = ""
+	if exp, ok := jwtExpiry(token); ok {
+		creds[ExpiresAtKey] = exp.UTC().Format(time.RFC3339)
+	}
+}
+
+// CredentialExpiry returns when the access token in creds expires: the
+// recorded ExpiresAtKey, or the exp claim of a JWT access token. It is nil
+// when that is unknown and for providers that don't use OAuth2.
+func CredentialExpiry(providerName string, creds map[string]string) *time.Time {
+	provider, err := GetProvider(providerName)
+	if err != nil || provider.AuthType != AuthOAuth2 {
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339, creds[ExpiresAtKey]); err == nil {
+		return &t
+	}
+	if t, ok := jwtExpiry(creds[accessTokenKey(provider)]); ok {
+		return &t
+	}
+	return nil
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it.
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(claims.Exp), 0), true
+}
@@ -0,0 +1,125 @@
+package providers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withTokenEndpoint points provider's token refresh at url for one test.
+func withTokenEndpoint(t *testing.T, name, url string) {
+	t.Helper()
+	provider := providerRegistry[name]
+	saved := provider.TokenEndpoint
+	provider.TokenEndpoint = url
+	providerRegistry[name] = provider
+	t.Cleanup(func() {
+		provider.TokenEndpoint = saved
+		providerRegistry[name] = provider
+	})
+}
+
+func TestRefreshToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "refresh_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.Form.Get("refresh_token") {
+		case "1//good":
+			w.Write([]byte(`{"access_token":"ya29.new","expires_in":3599,"token_type":"Bearer"}`))
+		case "xoxe-1-good":
+			w.Write([]byte(`{"ok":true,"access_token":"xoxe.xoxb-1-new","refresh_token":"xoxe-1-next"}`))
+		case "xoxe-1-revoked":
+			w.Write([]byte(`{"ok":false,"error":"invalid_refresh_token"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+		}
+	}))
+	defer srv.Close()
+	withTokenEndpoint(t, "google", srv.URL)
+	withTokenEndpoint(t, "slack", srv.URL)
+	ctx := context.Background()
+
+	creds := map[string]string{"access_token": "ya29.old", "refresh_token": "1//good", "client_id": "id", "client_secret": "secret"}
+	got, err := RefreshToken(ctx, "google", creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["access_token"] != "ya29.new" || got["refresh_token"] != "1//good" || creds["access_token"] != "ya29.old" {
+		t.Fatalf("refreshed %v from %v", got, creds)
+	}
+	if exp := CredentialExpiry("google", got); exp == nil || time.Until(*exp) < 59*time.Minute || time.Until(*exp) > time.Hour {
+		t.Fatalf("expiry after refresh: %v", exp)
+	}
+
+	got, err = RefreshToken(ctx, "slack", map[string]string{"bot_token": "xoxe.xoxb-1-old", "refresh_token": "xoxe-1-good", "client_id": "1.2", "client_secret": "0123456789abcdef0123456789abcdef"})
+	if err != nil || got["bot_token"] != "xoxe.xoxb-1-new" || got["refresh_token"] != "xoxe-1-next" {
+		t.Fatalf("slack refresh: %v, %v", got, err)
+	}
+	if err := ValidateProviderConfig("slack", got); err != nil {
+		t.Fatalf("rotated slack credentials invalid: %v", err)
+	}
+
+	for _, tc := range []struct {
+		provider, refresh, want string
+	}{
+		{"slack", "xoxe-1-revoked", "invalid_refresh_token"},
+		{"google", "1//expired", "invalid_grant: Token has been expired or revoked."},
+		{"github", "x", "does not support token refresh"},
+	} {
+		_, err := RefreshToken(ctx, tc.provider, map[string]string{"refresh_token": tc.refresh, "client_id": "id", "client_secret": "secret"})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s %s: got %v, want %q", tc.provider, tc.refresh, err, tc.want)
+		}
+	}
+	if _, err := RefreshToken(ctx, "microsoft", map[string]string{"access_token": "a.b.c"}); !errors.Is(err, ErrNoRefreshToken) {
+		t.Errorf("without refresh token: %v", err)
+	}
+}
+
+func TestCredentialExpiry(t *testing.T) {
+	exp := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	jwt := "eyJ0eXAiOiJKV1QifQ." + base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix()))) + ".c2ln"
+
+	// Microsoft access tokens are JWTs and carry their own expiry
+	creds := map[string]string{"access_token": jwt, "client_id": "12345678-1234-1234-1234-123456789012", "client_secret": "s"}
+	RecordExpiry("microsoft", creds)
+	if got := CredentialExpiry("microsoft", creds); got == nil || !got.Equal(exp) || creds[ExpiresAtKey] == "" {
+		t.Fatalf("JWT expiry: %v, recorded %q", got, creds[ExpiresAtKey])
+	}
+	if err := ValidateProviderConfig("microsoft", creds); err != nil {
+		t.Fatalf("recorded expiry rejected: %v", err)
+	}
+
+	// expires_in is turned into a time
+	creds = map[string]string{"access_token": "ya29.x", "expires_in": "3600"}
+	RecordExpiry("google", creds)
+	if _, left := creds["expires_in"]; left || CredentialExpiry("google", creds) == nil {
+		t.Fatalf("expires_in not recorded: %v", creds)
+	}
+
+	// A new opaque token clears the expiry of the one it replaces
+	update := map[string]string{"access_token": "ya29.y"}
+	RecordExpiry("google", update)
+	if v, ok := update[ExpiresAtKey]; !ok || v != "" || CredentialExpiry("google", update) != nil {
+		t.Fatalf("stale expiry kept: %v", update)
+	}
+
+	// API keys don't expire
+	creds = map[string]string{"api_key": jwt, "expires_in": "60"}
+	RecordExpiry("openai", creds)
+	if CredentialExpiry("openai", creds) != nil || creds["expires_in"] != "60" {
+		t.Fatalf("api key credentials changed: %v", creds)
+	}
+	if err := ValidateProviderConfig("openai", creds); err == nil {
+		t.Fatal("expected expires_in to be unexpected for an API key provider")
+	}
+}
@@ -277,4 +277,25 @@ func TestValidationError(t *testing.T) {
 	if err.Error() != expected {
 		t.Errorf("ValidationError.Error() = %v, want %v", err.Error(), expected)
 	}
-}
\ No newline at end of file
+}
+
+func TestSelfCheck(t *testing.T) {
+	for _, err := range SelfCheck() {
+		t.Error(err)
+	}
+}
+
+func TestAddProviderRejectsMismatchedExample(t *testing.T) {
+	err := AddProvider(Provider{
+		Name:           "bad-example",
+		DisplayName:    "Bad Example",
+		HealthEndpoint: "https://api.test.com/health",
+		Credentials: []Credential{
+			{Key: "token", DisplayName: "Token", Validation: "^tok_[0-9]+$", Example: "tok_abc"},
+		},
+	})
+	if err == nil {
+		delete(providerRegistry, "bad-example")
+		t.Fatal("AddProvider() should reject an example that fails its own validation")
+	}
+}
@@ -2,15 +2,20 @@ package registry
 
 import (
     "fmt"
+    "regexp"
     "strings"
     "mcp/manager/internal/clients"
 )
 
+// slugUnsafeRE matches runs of characters other than letters and digits
+var slugUnsafeRE = regexp.MustCompile(`[^a-z0-9]+`)
+
 // AdoptExistingMCP converts a detected MCP server into a registry Server entry
 func AdoptExistingMCP(mcp clients.MCPServer) Server {
     // Generate a slug from the name
     slug := strings.ToLower(strings.ReplaceAll(mcp.Name, " ", "-"))
     slug = strings.ReplaceAll(slug, "_", "-")
+    slug = strings.Trim(slugUnsafeRE.ReplaceAllString(slug, "-"), "-")
     
     // Determine runtime based on command
     runtime := determineRuntime(mcp.Command, mcp.Args)
@@ -50,6 +55,8 @@ func AdoptExistingMCP(mcp clients.MCPServer) Server {
             ClaudeDesktop: &ClientFlag{Enabled: strings.Contains(mcp.Source, "Claude")},
             CursorGlobal:  &ClientFlag{Enabled: strings.Contains(mcp.Source, "Cursor")},
             Continue:      &ClientFlag{Enabled: strings.Contains(mcp.Source, "Continue")},
+            VSCode:        &ClientFlag{Enabled: mcp.Source == "VS Code"},
+            Windsurf:      &ClientFlag{Enabled: mcp.Source == "Windsurf"},
         },
     }
     
@@ -133,6 +140,7 @@ func (r *Registry) AdoptMCPs(mcps []clients.MCPServer) []Server {
         
         if !exists {
             server := AdoptExistingMCP(mcp)
+            server.Slug = r.uniqueSlug(server.Slug)
             r.Servers = append(r.Servers, server)
             adoptedServers = append(adoptedServers, server)
         }
@@ -157,5 +165,23 @@ func (r *Registry) DetectAndAdoptMCPs(paths clients.Paths) ([]Server, error) {
     }
     
     adopted := r.AdoptMCPs(allMCPs)
+    if err := r.Validate(); err != nil {
+        r.Servers = r.Servers[:len(r.Servers)-len(adopted)]
+        return nil, fmt.Errorf("adopted servers are invalid: %w", err)
+    }
     return adopted, nil
+}
+
+// uniqueSlug returns slug, or slug with the lowest numeric suffix from 2 on
+// that no server in r uses yet.
+func (r *Registry) uniqueSlug(slug string) string {
+    taken := map[string]bool{}
+    for _, s := range r.Servers {
+        taken[s.Slug] = true
+    }
+    candidate := slug
+    for n := 2; taken[candidate]; n++ {
+        candidate = fmt.Sprintf("%s-%d", slug, n)
+    }
+    return candidate
 }
\ No newline at end of file
@@ -0,0 +1,37 @@
+package registry
+
+import (
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+)
+
+// CheckCommand reports why command would fail to exec for a server whose
+// directory is dir, resolving it the way the supervisor does: a bare name is
+// looked up on PATH and a relative path is taken from dir, the server's
+// working directory.
+func CheckCommand(command, dir string) error {
+    if strings.TrimSpace(command) == "" {
+        return fmt.Errorf("no command configured")
+    }
+    if !strings.ContainsRune(command, filepath.Separator) {
+        if _, err := exec.LookPath(command); err != nil {
+            return fmt.Errorf("command %q not found on PATH", command)
+        }
+        return nil
+    }
+    path := command
+    if !filepath.IsAbs(path) {
+        path = filepath.Join(dir, path)
+    }
+    fi, err := os.Stat(path)
+    if err != nil {
+        return fmt.Errorf("command %s: %w", path, err)
+    }
+    if fi.IsDir() || fi.Mode()&0o111 == 0 {
+        return fmt.Errorf("command %s is not an executable file", path)
+    }
+    return nil
+}
@@ -0,0 +1,29 @@
+package registry
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestCheckCommand(t *testing.T) {
+    dir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil { t.Fatal(err) }
+    if err := os.WriteFile(filepath.Join(dir, "data.txt"), nil, 0o644); err != nil { t.Fatal(err) }
+
+    for _, tc := range []struct{ command, want string }{
+        {"./run.sh", ""},
+        {filepath.Join(dir, "run.sh"), ""},
+        {"sh", ""},
+        {"", "no command configured"},
+        {"no-such-command-xyz", "not found on PATH"},
+        {"./missing.sh", "no such file"},
+        {"./data.txt", "not an executable file"},
+    } {
+        err := CheckCommand(tc.command, dir)
+        if tc.want == "" && err != nil || tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+            t.Errorf("CheckCommand(%q) = %v, want %q", tc.command, err, tc.want)
+        }
+    }
+}
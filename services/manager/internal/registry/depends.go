@@ -0,0 +1,143 @@
+package registry
+
+import (
+    "fmt"
+    "slices"
+    "strings"
+)
+
+// checkDependencies rejects a dependsOn entry that names no server, the
+// server itself or an external server, which the supervisor doesn't run, and
+// any cycle among dependencies, which could never be started.
+func (r *Registry) checkDependencies() error {
+    bySlug := r.serversBySlug()
+    for _, s := range r.Servers {
+        for _, dep := range s.DependsOn {
+            d, ok := bySlug[dep]
+            switch {
+            case dep == s.Slug:
+                return fmt.Errorf("%s: dependsOn lists itself", s.Slug)
+            case !ok:
+                return fmt.Errorf("%s: dependsOn unknown server %q", s.Slug, dep)
+            case d.IsExternal():
+                return fmt.Errorf("%s: dependsOn external server %q, which is not started locally", s.Slug, dep)
+            }
+        }
+    }
+    _, err := dependencyOrder(bySlug, r.slugs())
+    return err
+}
+
+// DependencyOrder returns the slugs of the servers slug depends on, directly
+// or through others, each after all of its own dependencies, which is the
+// order to start them in. slug itself is not included.
+func (r *Registry) DependencyOrder(slug string) ([]string, error) {
+    bySlug := r.serversBySlug()
+    if _, ok := bySlug[slug]; !ok {
+        return nil, fmt.Errorf("unknown slug: %s", slug)
+    }
+    order, err := dependencyOrder(bySlug, []string{slug})
+    if err != nil {
+        return nil, err
+    }
+    return order[:len(order)-1], nil
+}
+
+// Dependents returns the slugs of the servers that list slug in dependsOn.
+func (r *Registry) Dependents(slug string) []string {
+    var dependents []string
+    for _, s := range r.Servers {
+        if slices.Contains(s.DependsOn, slug) {
+            dependents = append(dependents, s.Slug)
+        }
+    }
+    return dependents
+}
+
+// RemoveDependency drops slug from the dependsOn of every server.
+func (r *Registry) RemoveDependency(slug string) {
+    for i := range r.Servers {
+        r.Servers[i].DependsOn = slices.DeleteFunc(r.Servers[i].DependsOn, func(dep string) bool { return dep == slug })
+    }
+}
+
+// SortByDependencies orders servers so that each comes after those of its
+// dependencies that are also in servers, keeping the given order otherwise.
+func SortByDependencies(servers []Server) ([]Server, error) {
+    bySlug := make(map[string]*Server, len(servers))
+    slugs := make([]string, len(servers))
+    for i := range servers {
+        bySlug[servers[i].Slug] = &servers[i]
+        slugs[i] = servers[i].Slug
+    }
+    order, err := dependencyOrder(bySlug, slugs)
+    if err != nil {
+        return nil, err
+    }
+    sorted := make([]Server, len(order))
+    for i, slug := range order {
+        sorted[i] = *bySlug[slug]
+    }
+    return sorted, nil
+}
+
+func (r *Registry) serversBySlug() map[string]*Server {
+    bySlug := make(map[string]*Server, len(r.Servers))
+    for i := range r.Servers {
+        bySlug[r.Servers[i].Slug] = &r.Servers[i]
+    }
+    return bySlug
+}
+
+func (r *Registry) slugs() []string {
+    slugs := make([]string, len(r.Servers))
+    for i, s := range r.Servers {
+        slugs[i] = s.Slug
+    }
+    return slugs
+}
+
+// dependencyOrder returns roots and everything they depend on in bySlug,
+// dependencies first, by depth-first search. Dependencies missing from
+// bySlug are skipped. A cycle is reported with its path, e.g. "a -> b -> a".
+func dependencyOrder(bySlug map[string]*Server, roots []string) ([]string, error) {
+    const (
+        visiting = 1
+        done     = 2
+    )
+    mark := make(map[string]int, len(bySlug))
+    var order, path []string
+    var visit func(slug string) error
+    visit = func(slug string) error {
+        switch mark[slug] {
+        case done:
+            return nil
+        case visiting:
+            start := 0
+            for path[start] != slug {
+                start++
+            }
+            return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path[start:], " -> "), slug)
+        }
+        mark[slug] = visiting
+        path = append(path, slug)
+        for _, dep := range bySlug[slug].DependsOn {
+            if _, ok := bySlug[dep]; !ok {
+                continue
+            }
+            if err := visit(dep); err != nil {
+                return err
+            }
+        }
+        path = path[:len(path)-1]
+        mark[slug] = done
+        order = append(order, slug)
+        return nil
+    }
+    for _, slug := range roots {
+        if err := visit(slug); err != nil {
+            return nil, err
+        }
+    }
+    return order, nil
+}
@@ -0,0 +1,56 @@
+package registry
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestDependencies(t *testing.T) {
+    server := func(slug string, deps ...string) Server {
+        return Server{Name: slug, Slug: slug, DependsOn: deps,
+            Entry:  Entry{Transport: "stdio", Command: "node"},
+            Health: Health{IntervalSec: 20, TimeoutSec: 5}}
+    }
+    reg := func(servers ...Server) *Registry { return &Registry{Version: "1.0", Servers: servers} }
+
+    r := reg(server("proxy", "backend", "auth"), server("backend", "db"), server("auth", "db"), server("db"))
+    if err := r.Validate(); err != nil { t.Fatal(err) }
+    order, err := r.DependencyOrder("proxy")
+    if err != nil { t.Fatal(err) }
+    if got := strings.Join(order, ","); got != "db,backend,auth" {
+        t.Fatalf("DependencyOrder(proxy) = %s", got)
+    }
+    sorted, err := SortByDependencies(r.Servers)
+    if err != nil { t.Fatal(err) }
+    var slugs []string
+    for _, s := range sorted {
+        slugs = append(slugs, s.Slug)
+    }
+    if got := strings.Join(slugs, ","); got != "db,backend,auth,proxy" {
+        t.Fatalf("SortByDependencies = %s", got)
+    }
+
+    if got := strings.Join(r.Dependents("db"), ","); got != "backend,auth" {
+        t.Fatalf("Dependents(db) = %s", got)
+    }
+    r.RemoveDependency("db")
+    if deps := r.Dependents("db"); len(deps) != 0 || len(r.Servers[0].DependsOn) != 2 {
+        t.Fatalf("after RemoveDependency(db): dependents %v, proxy dependsOn %v", deps, r.Servers[0].DependsOn)
+    }
+
+    external := server("cloud")
+    external.External = &ExternalInfo{Provider: "notion"}
+    for _, tc := range []struct {
+        r    *Registry
+        want string
+    }{
+        {reg(server("a", "a")), "a: dependsOn lists itself"},
+        {reg(server("a", "missing")), `a: dependsOn unknown server "missing"`},
+        {reg(server("a", "cloud"), external), `a: dependsOn external server "cloud"`},
+        {reg(server("a", "b"), server("b", "c"), server("c", "b")), "dependency cycle: b -> c -> b"},
+    } {
+        if err := tc.r.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+            t.Errorf("Validate() = %v, want %q", err, tc.want)
+        }
+    }
+}
@@ -0,0 +1,153 @@
+package registry
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+)
+
+// vaultScheme prefixes env values that live in the credential vault:
+// vault://<ref>/<key> names the key entry of the vault item stored under ref.
+const vaultScheme = "vault://"
+
+// RequiredEnv declares an environment variable a server needs at runtime that
+// its installer cannot supply, such as an API base URL or a token.
+type RequiredEnv struct {
+    Key         string `json:"key"`
+    Description string `json:"description,omitempty"`
+    Secret      bool   `json:"secret,omitempty"` // stored in the vault, referenced from env
+}
+
+// SecretStore is the part of the credential vault that holds secret env values.
+type SecretStore interface {
+    Retrieve(ref string) (map[string]string, error)
+    Store(ref string, values map[string]string) error
+}
+
+// Entry.InheritEnv policies, which decide how much of the manager's own
+// environment a server's processes start with. Env is added on top either way.
+const (
+    InheritAll       = "all"       // everything; the default
+    InheritNone      = "none"      // only PATH and HOME
+    InheritAllowlist = "allowlist" // PATH, HOME and the InheritVars
+)
+
+// ValidateInheritEnv checks an InheritEnv policy and its variable list.
+func ValidateInheritEnv(policy string, vars []string) error {
+    switch policy {
+    case "", InheritAll, InheritNone:
+        if len(vars) > 0 {
+            return fmt.Errorf("inheritVars needs inheritEnv %q", InheritAllowlist)
+        }
+    case InheritAllowlist:
+        for _, name := range vars {
+            if name == "" || strings.ContainsAny(name, "= \t\n") {
+                return fmt.Errorf("invalid inheritVars name %q", name)
+            }
+        }
+    default:
+        return fmt.Errorf("invalid inheritEnv %q: use %q, %q or %q", policy, InheritAll, InheritNone, InheritAllowlist)
+    }
+    return nil
+}
+
+// ServerEnvRef is the vault key for a server's secret env values.
+func ServerEnvRef(slug string) string {
+    return "env:" + slug
+}
+
+// VaultRef returns the env value that refers to key in the vault item ref.
+func VaultRef(ref, key string) string {
+    return vaultScheme + ref + "/" + key
+}
+
+// ParseVaultRef splits a vault:// env value into its vault ref and key.
+func ParseVaultRef(value string) (ref, key string, ok bool) {
+    rest, found := strings.CutPrefix(value, vaultScheme)
+    if !found {
+        return "", "", false
+    }
+    i := strings.LastIndex(rest, "/")
+    if i <= 0 || i == len(rest)-1 {
+        return "", "", false
+    }
+    return rest[:i], rest[i+1:], true
+}
+
+// MissingEnv returns the required env keys that have no value, sorted.
+func (e Entry) MissingEnv() []string {
+    var missing []string
+    for _, req := range e.RequiredEnv {
+        if e.Env[req.Key] == "" {
+            missing = append(missing, req.Key)
+        }
+    }
+    sort.Strings(missing)
+    return missing
+}
+
+// ApplyEnv sets env values on sv. Values for keys declared secret in
+// RequiredEnv are written to store under ServerEnvRef and replaced by a
+// vault:// reference; other values are kept inline. An empty value removes
+// the key. Values that are already vault:// references are kept as given.
+func ApplyEnv(sv *Server, values map[string]string, store SecretStore) error {
+    secret := map[string]bool{}
+    for _, req := range sv.Entry.RequiredEnv {
+        secret[req.Key] = req.Secret
+    }
+    
+    ref := ServerEnvRef(sv.Slug)
+    secrets := map[string]string{}
+    for key, value := range values {
+        if secret[key] && value != "" && !strings.HasPrefix(value, vaultScheme) {
+            secrets[key] = value
+        }
+    }
+    if len(secrets) > 0 {
+        if store == nil {
+            return fmt.Errorf("no vault available to store secret env for %s", sv.Slug)
+        }
+        // Merge with secrets stored earlier; a missing item just starts empty
+        stored, _ := store.Retrieve(ref)
+        if stored == nil {
+            stored = map[string]string{}
+        }
+        for key, value := range secrets {
+            stored[key] = value
+        }
+        if err := store.Store(ref, stored); err != nil {
+            return fmt.Errorf("failed to store secret env for %s: %w", sv.Slug, err)
+        }
+    }
+    
+    if sv.Entry.Env == nil {
+        sv.Entry.Env = make(map[string]string)
+    }
+    for key, value := range values {
+        switch {
+        case value == "":
+            delete(sv.Entry.Env, key)
+        case secrets[key] != "":
+            sv.Entry.Env[key] = VaultRef(ref, key)
+        default:
+            sv.Entry.Env[key] = value
+        }
+    }
+    return nil
+}
+
+// ValidateRequiredEnv checks that every declared key is a usable, unique
+// variable name.
+func ValidateRequiredEnv(reqs []RequiredEnv) error {
+    seen := map[string]bool{}
+    for _, req := range reqs {
+        if req.Key == "" || strings.ContainsAny(req.Key, "= \t\n") {
+            return fmt.Errorf("invalid requiredEnv key %q", req.Key)
+        }
+        if seen[req.Key] {
+            return fmt.Errorf("duplicate requiredEnv key %q", req.Key)
+        }
+        seen[req.Key] = true
+    }
+    return nil
+}
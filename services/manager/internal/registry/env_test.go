@@ -0,0 +1,60 @@
+package registry
+
+import (
+    "reflect"
+    "testing"
+)
+
+type secretMap map[string]map[string]string
+
+func (m secretMap) Retrieve(ref string) (map[string]string, error) { return m[ref], nil }
+func (m secretMap) Store(ref string, v map[string]string) error   { m[ref] = v; return nil }
+
+func TestParseVaultRef(t *testing.T) {
+    ref, key, ok := ParseVaultRef(VaultRef(ServerEnvRef("fs"), "TOKEN"))
+    if !ok || ref != "env:fs" || key != "TOKEN" { t.Fatalf("got %q %q %v", ref, key, ok) }
+    ref, key, ok = ParseVaultRef("vault://fs/db")
+    if !ok || ref != "fs" || key != "db" { t.Fatalf("got %q %q %v", ref, key, ok) }
+    for _, bad := range []string{"plain", "vault://", "vault://noslash", "vault://ref/", "vault:///key"} {
+        if _, _, ok := ParseVaultRef(bad); ok { t.Errorf("%q parsed as a vault ref", bad) }
+    }
+}
+
+func TestApplyEnv(t *testing.T) {
+    sv := &Server{Slug: "fs", Entry: Entry{
+        Env:         map[string]string{"LOG_LEVEL": "info"},
+        RequiredEnv: []RequiredEnv{{Key: "BASE_URL"}, {Key: "TOKEN", Secret: true}, {Key: "PASSWORD", Secret: true}},
+    }}
+    if got := sv.Entry.MissingEnv(); !reflect.DeepEqual(got, []string{"BASE_URL", "PASSWORD", "TOKEN"}) { t.Fatalf("missing = %v", got) }
+    
+    store := secretMap{"env:fs": {"PASSWORD": "old"}}
+    if err := ApplyEnv(sv, map[string]string{"TOKEN": "t0k", "LOG_LEVEL": ""}, store); err != nil { t.Fatal(err) }
+    if sv.Entry.Env["TOKEN"] != "vault://env:fs/TOKEN" { t.Fatalf("secret kept inline: %v", sv.Entry.Env) }
+    if _, ok := sv.Entry.Env["LOG_LEVEL"]; ok { t.Fatal("empty value should remove the key") }
+    if store["env:fs"]["TOKEN"] != "t0k" || store["env:fs"]["PASSWORD"] != "old" { t.Fatalf("vault = %v", store) }
+    
+    if err := ApplyEnv(sv, map[string]string{"BASE_URL": "https://api.example.com"}, nil); err != nil { t.Fatal(err) }
+    if got := sv.Entry.MissingEnv(); !reflect.DeepEqual(got, []string{"PASSWORD"}) { t.Fatalf("missing = %v", got) }
+    
+    if err := ApplyEnv(sv, map[string]string{"PASSWORD": "p"}, nil); err == nil { t.Fatal("secret accepted without a vault") }
+}
+
+func TestValidateRequiredEnv(t *testing.T) {
+    if err := ValidateRequiredEnv([]RequiredEnv{{Key: "A"}, {Key: "B", Secret: true}}); err != nil { t.Fatal(err) }
+    for _, bad := range [][]RequiredEnv{{{Key: ""}}, {{Key: "A=B"}}, {{Key: "A"}, {Key: "A"}}} {
+        if err := ValidateRequiredEnv(bad); err == nil { t.Errorf("%v accepted", bad) }
+    }
+}
+
+func TestValidateInheritEnv(t *testing.T) {
+    for _, ok := range []struct{ policy string; vars []string }{
+        {"", nil}, {InheritAll, nil}, {InheritNone, nil}, {InheritAllowlist, []string{"LANG", "TZ"}},
+    } {
+        if err := ValidateInheritEnv(ok.policy, ok.vars); err != nil { t.Errorf("%q %v: %v", ok.policy, ok.vars, err) }
+    }
+    for _, bad := range []struct{ policy string; vars []string }{
+        {"some", nil}, {InheritNone, []string{"LANG"}}, {InheritAllowlist, []string{"A=B"}},
+    } {
+        if err := ValidateInheritEnv(bad.policy, bad.vars); err == nil { t.Errorf("%q %v accepted", bad.policy, bad.vars) }
+    }
+}
@@ -0,0 +1,68 @@
+package registry
+
+import (
+    "net"
+    "strings"
+)
+
+// DeriveHTTPURL returns the URL an http server listens on, for health checks
+// and introspection, or "" when args and env name no port. HEALTH_HTTP_URL in
+// env is used as is. Otherwise the port comes from --port, --http-port or -p,
+// then the PORT or MCP_PORT env; the host from --host or --bind, defaulting
+// to loopback; and the path from --base-path.
+func DeriveHTTPURL(args []string, env map[string]string) string {
+    if u := env["HEALTH_HTTP_URL"]; u != "" {
+        return u
+    }
+
+    host, _ := flagValue(args, "--host", "--bind")
+    port, ok := flagValue(args, "--port", "--http-port", "-p")
+    // --bind may carry the port as well
+    if h, p, err := net.SplitHostPort(host); err == nil {
+        host = h
+        if !ok {
+            port, ok = p, true
+        }
+    }
+    if !ok {
+        for _, key := range []string{"PORT", "MCP_PORT"} {
+            if v := env[key]; v != "" {
+                port, ok = v, true
+                break
+            }
+        }
+    }
+    if !ok || port == "" {
+        return ""
+    }
+
+    // A wildcard bind is reachable on loopback
+    switch host {
+    case "", "0.0.0.0", "::", "[::]", "*":
+        host = "127.0.0.1"
+    }
+    host = strings.Trim(host, "[]")
+
+    path, _ := flagValue(args, "--base-path")
+    path = strings.TrimSuffix(path, "/")
+    if path != "" && !strings.HasPrefix(path, "/") {
+        path = "/" + path
+    }
+    return "http://" + net.JoinHostPort(host, port) + path
+}
+
+// flagValue returns the value of the first of names found in args, given as
+// "name=value" or "name value".
+func flagValue(args []string, names ...string) (string, bool) {
+    for i, a := range args {
+        for _, name := range names {
+            if v, found := strings.CutPrefix(a, name+"="); found {
+                return v, true
+            }
+            if a == name && i+1 < len(args) {
+                return args[i+1], true
+            }
+        }
+    }
+    return "", false
+}
@@ -0,0 +1,36 @@
+package registry
+
+import "testing"
+
+func TestDeriveHTTPURL(t *testing.T) {
+    cases := []struct {
+        args []string
+        env  map[string]string
+        want string
+    }{
+        {[]string{"--port=8080"}, nil, "http://127.0.0.1:8080"},
+        {[]string{"-p", "3000"}, nil, "http://127.0.0.1:3000"},
+        {nil, map[string]string{"HEALTH_HTTP_URL": "http://127.0.0.1:9090/health"}, "http://127.0.0.1:9090/health"},
+        {[]string{"--http-port", "4000"}, nil, "http://127.0.0.1:4000"},
+        {[]string{"--http-port=4001"}, nil, "http://127.0.0.1:4001"},
+        {nil, map[string]string{"PORT": "5000"}, "http://127.0.0.1:5000"},
+        {nil, map[string]string{"MCP_PORT": "5001"}, "http://127.0.0.1:5001"},
+        // Flags win over env
+        {[]string{"--port", "6000"}, map[string]string{"PORT": "5000"}, "http://127.0.0.1:6000"},
+        {[]string{"--host", "10.0.0.5", "--port", "7000"}, nil, "http://10.0.0.5:7000"},
+        {[]string{"--bind=0.0.0.0", "--port=7001"}, nil, "http://127.0.0.1:7001"},
+        {[]string{"--bind", "192.168.1.2:7002"}, nil, "http://192.168.1.2:7002"},
+        {[]string{"--host", "::1", "-p", "7003"}, nil, "http://[::1]:7003"},
+        {[]string{"--port", "8000", "--base-path", "mcp/"}, nil, "http://127.0.0.1:8000/mcp"},
+        {[]string{"--base-path=/api/v1"}, map[string]string{"MCP_PORT": "8001"}, "http://127.0.0.1:8001/api/v1"},
+        // No port, no URL
+        {[]string{"--host", "10.0.0.5"}, nil, ""},
+        {[]string{"--port"}, nil, ""},
+        {nil, nil, ""},
+    }
+    for _, c := range cases {
+        if got := DeriveHTTPURL(c.args, c.env); got != c.want {
+            t.Errorf("DeriveHTTPURL(%q, %v) = %q, want %q", c.args, c.env, got, c.want)
+        }
+    }
+}
@@ -5,12 +5,21 @@ import (
     "errors"
     "fmt"
     "os"
-    "path/filepath"
     "regexp"
+    "strings"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/yaml"
 )
 
 var slugRE = regexp.MustCompile(`^[a-z0-9-]+$`)
 
+// ValidSlug reports whether slug is usable as a server slug: lowercase
+// letters, digits and dashes, which is also safe as a directory name.
+func ValidSlug(slug string) bool {
+    return slugRE.MatchString(slug)
+}
+
 // Load reads and parses a registry from the specified path.
 // Returns an error if the file doesn't exist or contains invalid data.
 func Load(path string) (*Registry, error) {
@@ -19,8 +28,13 @@ func Load(path string) (*Registry, error) {
         return nil, fmt.Errorf("failed to read registry file: %w", err)
     }
     var r Registry
-    if err := json.Unmarshal(b, &r); err != nil {
-        return nil, fmt.Errorf("failed to parse registry JSON: %w", err)
+    if yaml.IsYAML(path) {
+        err = yaml.Unmarshal(b, &r)
+    } else {
+        err = json.Unmarshal(b, &r)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse registry %s: %w", formatName(path), err)
     }
     if err := validate(&r); err != nil {
         return nil, fmt.Errorf("registry validation failed: %w", err)
@@ -28,26 +42,88 @@ func Load(path string) (*Registry, error) {
     return &r, nil
 }
 
+// DefaultPath returns the registry file under the MCP root (see paths.Root):
+// registry.yaml or registry.yml when one exists, otherwise registry.json.
 func DefaultPath() (string, error) {
-    home, err := os.UserHomeDir()
-    if err != nil { return "", err }
-    return filepath.Join(home, ".mcp", "registry.json"), nil
+    return paths.ConfigFile("registry")
+}
+
+// formatName names the serialization used for path in error messages.
+func formatName(path string) string {
+    if yaml.IsYAML(path) { return "YAML" }
+    return "JSON"
+}
+
+// Validate checks r the way Load and Save do, so entries added in memory,
+// e.g. by adopting client configs, can be checked before they are used.
+func (r *Registry) Validate() error {
+    return validate(r)
+}
+
+// SlugCollision is a slug shared by several servers.
+type SlugCollision struct {
+    Slug    string
+    Indexes []int    // positions in Registry.Servers
+    Names   []string // names of those servers
+}
+
+// DuplicateSlugError reports servers that share a slug. The slug names a
+// server's directory and log file, so such servers would fight over them.
+type DuplicateSlugError struct {
+    Collisions []SlugCollision // in order of first appearance
+}
+
+func (e *DuplicateSlugError) Error() string {
+    parts := make([]string, len(e.Collisions))
+    for i, c := range e.Collisions {
+        servers := make([]string, len(c.Indexes))
+        for j, idx := range c.Indexes {
+            servers[j] = fmt.Sprintf("servers[%d] %q", idx, c.Names[j])
+        }
+        parts[i] = fmt.Sprintf("duplicate slug %q: %s", c.Slug, strings.Join(servers, ", "))
+    }
+    return strings.Join(parts, "; ")
+}
+
+// duplicateSlugs returns a *DuplicateSlugError listing every shared slug,
+// or nil if all slugs are unique.
+func (r *Registry) duplicateSlugs() error {
+    bySlug := map[string][]int{}
+    var order []string
+    for i, s := range r.Servers {
+        if bySlug[s.Slug] == nil {
+            order = append(order, s.Slug)
+        }
+        bySlug[s.Slug] = append(bySlug[s.Slug], i)
+    }
+    var dup DuplicateSlugError
+    for _, slug := range order {
+        if idx := bySlug[slug]; len(idx) > 1 {
+            c := SlugCollision{Slug: slug, Indexes: idx}
+            for _, i := range idx {
+                c.Names = append(c.Names, r.Servers[i].Name)
+            }
+            dup.Collisions = append(dup.Collisions, c)
+        }
+    }
+    if dup.Collisions == nil {
+        return nil
+    }
+    return &dup
 }
 
 func validate(r *Registry) error {
     if r.Version == "" {
         return errors.New("version required")
     }
-    seen := map[string]bool{}
+    if err := r.duplicateSlugs(); err != nil {
+        return err
+    }
     for i := range r.Servers {
         s := &r.Servers[i]
-        if s.Slug == "" || !slugRE.MatchString(s.Slug) {
+        if !ValidSlug(s.Slug) {
             return fmt.Errorf("invalid slug: %q", s.Slug)
         }
-        if seen[s.Slug] {
-            return fmt.Errorf("duplicate slug: %q", s.Slug)
-        }
-        seen[s.Slug] = true
         if s.Entry.Transport != "stdio" && s.Entry.Transport != "http" {
             return fmt.Errorf("invalid transport for %s", s.Slug)
         }
@@ -58,11 +134,48 @@ func validate(r *Registry) error {
         if s.Health.IntervalSec <= 0 || s.Health.TimeoutSec <= 0 {
             return fmt.Errorf("invalid health timing for %s", s.Slug)
         }
+        if err := s.Health.ValidateProbe(); err != nil {
+            return fmt.Errorf("%s: %w", s.Slug, err)
+        }
+        if err := s.Health.ValidateStop(); err != nil {
+            return fmt.Errorf("%s: %w", s.Slug, err)
+        }
+        if err := ValidateRequiredEnv(s.Entry.RequiredEnv); err != nil {
+            return fmt.Errorf("%s: %w", s.Slug, err)
+        }
+        if err := ValidateInheritEnv(s.Entry.InheritEnv, s.Entry.InheritVars); err != nil {
+            return fmt.Errorf("%s: %w", s.Slug, err)
+        }
+        if err := s.Limits.Validate(); err != nil {
+            return fmt.Errorf("%s: %w", s.Slug, err)
+        }
+        if err := s.Priority.Validate(); err != nil {
+            return fmt.Errorf("%s: %w", s.Slug, err)
+        }
+        if f := s.Logs; f != nil {
+            switch f.Mode {
+            case "", "newline":
+            case "pattern":
+                if f.RecordStart == "" {
+                    return fmt.Errorf("logFormat.recordStart required for %s", s.Slug)
+                }
+                if _, err := regexp.Compile(f.RecordStart); err != nil {
+                    return fmt.Errorf("invalid logFormat.recordStart for %s: %w", s.Slug, err)
+                }
+            default:
+                return fmt.Errorf("invalid logFormat.mode for %s: %q", s.Slug, f.Mode)
+            }
+        }
+        if s.External != nil {
+            if err := s.External.ValidateHealthCheck(); err != nil {
+                return fmt.Errorf("%s: %w", s.Slug, err)
+            }
+        }
     }
-    return nil
+    return r.checkDependencies()
 }
 
-// LoadDefault loads the registry from the default location (~/.mcp/registry.json).
+// LoadDefault loads the registry from the default location (see DefaultPath).
 // If the file doesn't exist, returns a new empty registry with default version.
 func LoadDefault() (*Registry, error) {
     path, err := DefaultPath()
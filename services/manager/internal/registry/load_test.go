@@ -1,9 +1,14 @@
 package registry
 
 import (
+    "errors"
     "os"
     "path/filepath"
+    "strings"
     "testing"
+
+    "mcp/manager/internal/clients"
+    "mcp/manager/internal/paths"
 )
 
 func writeTemp(t *testing.T, data string) string {
@@ -36,3 +41,112 @@ func TestLoad_InvalidSlug(t *testing.T) {
     if _, err := Load(p); err == nil { t.Fatal("expected error") }
 }
 
+
+func TestConfigDirRedirectsRegistryAndServers(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    dir := t.TempDir()
+    t.Setenv(paths.EnvHome, dir)
+
+    reg := &Registry{Version: "1.0", Servers: []Server{{Slug: "fs", Entry: Entry{Transport: "stdio", Command: "node"}, Health: Health{IntervalSec: 30, TimeoutSec: 5}}}}
+    if err := SaveDefault(reg); err != nil { t.Fatal(err) }
+    if _, err := os.Stat(filepath.Join(dir, "registry.json")); err != nil { t.Fatalf("registry not written under config dir: %v", err) }
+
+    loaded, err := LoadDefault()
+    if err != nil { t.Fatal(err) }
+    if len(loaded.Servers) != 1 || loaded.Servers[0].Slug != "fs" { t.Fatalf("loaded %+v", loaded.Servers) }
+
+    servers, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if servers != filepath.Join(dir, "servers") { t.Fatalf("servers dir %s not under %s", servers, dir) }
+    if entries, err := os.ReadDir(home); err != nil || len(entries) != 0 { t.Fatalf("home should be untouched, got %v, err=%v", entries, err) }
+}
+
+func TestLoad_DuplicateSlugs(t *testing.T) {
+    server := func(name, slug string) string {
+        return `{"name":"` + name + `","slug":"` + slug + `","source":{"type":"git","uri":"u"},"runtime":{"kind":"node"},"entry":{"transport":"stdio","command":"node"},"health":{"probe":"mcp","method":"ping","intervalSec":20,"timeoutSec":5},"clients":{}}`
+    }
+    p := writeTemp(t, `{"version":"1.0","servers":[`+
+        server("Files", "files")+","+server("Git", "git")+","+server("Files copy", "files")+","+
+        server("Git 2", "git")+","+server("Other", "other")+`]}`)
+
+    _, err := Load(p)
+    var dup *DuplicateSlugError
+    if !errors.As(err, &dup) {
+        t.Fatalf("expected DuplicateSlugError, got %v", err)
+    }
+    if len(dup.Collisions) != 2 || dup.Collisions[0].Slug != "files" || dup.Collisions[1].Slug != "git" {
+        t.Fatalf("collisions = %+v", dup.Collisions)
+    }
+    want := `duplicate slug "files": servers[0] "Files", servers[2] "Files copy"; duplicate slug "git": servers[1] "Git", servers[3] "Git 2"`
+    if !strings.Contains(err.Error(), want) {
+        t.Fatalf("error = %q, want it to contain %q", err, want)
+    }
+}
+
+func TestAdoptKeepsSlugsUnique(t *testing.T) {
+    r := &Registry{Version: "1.0", Servers: []Server{{Name: "Files", Slug: "my-files"}}}
+    adopted := r.AdoptMCPs([]clients.MCPServer{
+        {Name: "My Files", Command: "files-a"},
+        {Name: "my_files", Command: "files-b"},
+        {Name: "GitHub (official)", Command: "gh"},
+    })
+    var slugs []string
+    for _, s := range adopted {
+        slugs = append(slugs, s.Slug)
+    }
+    if got := strings.Join(slugs, ","); got != "my-files-2,my-files-3,github-official" {
+        t.Fatalf("slugs = %s", got)
+    }
+    if err := r.duplicateSlugs(); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestYAMLRegistry(t *testing.T) {
+    dir := t.TempDir()
+    t.Setenv(paths.EnvHome, dir)
+    src := `# Hand-maintained registry
+version: 1.0
+x-health: &health {probe: mcp, method: ping, intervalSec: 20, timeoutSec: 5, restartPolicy: always, maxRestarts: 5}
+servers:
+  - name: fs
+    slug: filesystem   # used in URLs
+    source: {type: git, uri: "https://x"}
+    runtime: {kind: node}
+    entry:
+      transport: stdio
+      command: node
+      args: [server.js]
+    health: *health
+`
+    yamlPath := filepath.Join(dir, "registry.yaml")
+    if err := os.WriteFile(yamlPath, []byte(src), 0o644); err != nil { t.Fatal(err) }
+    // A leftover JSON registry loses to the YAML one
+    if err := os.WriteFile(filepath.Join(dir, "registry.json"), []byte(`{"version":"1.0","servers":[]}`), 0o644); err != nil { t.Fatal(err) }
+
+    if p, err := DefaultPath(); err != nil || p != yamlPath { t.Fatalf("DefaultPath = %s, %v", p, err) }
+    r, err := LoadDefault()
+    if err != nil { t.Fatal(err) }
+    if r.Version != "1.0" || len(r.Servers) != 1 || r.Servers[0].Health.IntervalSec != 20 || r.Servers[0].Entry.Args[0] != "server.js" {
+        t.Fatalf("loaded %+v", r)
+    }
+
+    // Saving what was loaded leaves the file, comments and anchors, alone
+    if err := SaveDefault(r); err != nil { t.Fatal(err) }
+    if b, err := os.ReadFile(yamlPath); err != nil || string(b) != src { t.Fatalf("unchanged save rewrote the file:\n%s", b) }
+
+    // Saving keeps the format it was loaded from
+    r.Servers[0].Name = "Files"
+    if err := SaveDefault(r); err != nil { t.Fatal(err) }
+    b, err := os.ReadFile(yamlPath)
+    if err != nil { t.Fatal(err) }
+    if strings.HasPrefix(strings.TrimSpace(string(b)), "{") { t.Fatalf("saved as JSON:\n%s", b) }
+    again, err := Load(yamlPath)
+    if err != nil { t.Fatalf("%v in\n%s", err, b) }
+    if again.Servers[0].Name != "Files" || again.Servers[0].Slug != "filesystem" { t.Fatalf("reloaded %+v", again.Servers[0]) }
+
+    bad := filepath.Join(t.TempDir(), "registry.yml")
+    if err := os.WriteFile(bad, []byte("servers:\n  - name: x\n   slug: y\n"), 0o644); err != nil { t.Fatal(err) }
+    if _, err := Load(bad); err == nil || !strings.Contains(err.Error(), "YAML") { t.Fatalf("bad YAML: %v", err) }
+}
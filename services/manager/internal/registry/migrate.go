@@ -0,0 +1,68 @@
+package registry
+
+import (
+    "fmt"
+)
+
+// CredentialStore is the part of the credential vault needed to move legacy
+// inline credentials out of the registry.
+type CredentialStore interface {
+    Store(ref string, credentials map[string]string) error
+    HasCredentials(ref string) bool
+}
+
+// MigrateLegacyCredentials moves inline External.Credentials/APIKey secrets into
+// store, sets CredentialRef and clears the inline fields. It returns the slugs
+// that were changed; servers without inline secrets are left alone, so running
+// it on every startup is safe.
+func MigrateLegacyCredentials(r *Registry, store CredentialStore) ([]string, error) {
+    var migrated []string
+    for i := range r.Servers {
+        ext := r.Servers[i].External
+        if ext == nil || (ext.APIKey == "" && len(ext.Credentials) == 0) {
+            continue
+        }
+        
+        ref := ext.CredentialRef
+        if ref == "" {
+            ref = ServerCredentialRef(ext.Provider, r.Servers[i].Slug)
+        }
+        
+        // An existing vault entry wins over stale inline copies
+        if ext.CredentialRef == "" || !store.HasCredentials(ref) {
+            creds := make(map[string]string, len(ext.Credentials)+1)
+            for k, v := range ext.Credentials {
+                creds[k] = v
+            }
+            if _, ok := creds["api_key"]; !ok && ext.APIKey != "" {
+                creds["api_key"] = ext.APIKey
+            }
+            if err := store.Store(ref, creds); err != nil {
+                return migrated, fmt.Errorf("migrate credentials for %s: %w", r.Servers[i].Slug, err)
+            }
+        }
+        
+        ext.CredentialRef = ref
+        ext.Credentials = nil
+        ext.APIKey = ""
+        migrated = append(migrated, r.Servers[i].Slug)
+    }
+    return migrated, nil
+}
+
+// MigrateProviderCredentialRefs points servers whose CredentialRef is the bare
+// provider name at their own server-scoped ref instead. The provider entry
+// stays in place and is picked up as the default, so behaviour is unchanged,
+// but deleting the server no longer deletes credentials other servers share.
+func MigrateProviderCredentialRefs(r *Registry) []string {
+    var migrated []string
+    for i := range r.Servers {
+        ext := r.Servers[i].External
+        if ext == nil || ext.Provider == "" || ext.CredentialRef != ext.Provider {
+            continue
+        }
+        ext.CredentialRef = ServerCredentialRef(ext.Provider, r.Servers[i].Slug)
+        migrated = append(migrated, r.Servers[i].Slug)
+    }
+    return migrated
+}
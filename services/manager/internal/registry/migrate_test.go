@@ -0,0 +1,48 @@
+package registry
+
+import "testing"
+
+type memStore map[string]map[string]string
+
+func (m memStore) Store(ref string, c map[string]string) error { m[ref] = c; return nil }
+func (m memStore) HasCredentials(ref string) bool { _, ok := m[ref]; return ok }
+
+func TestMigrateLegacyCredentials(t *testing.T) {
+    r := &Registry{Version: "1.0", Servers: []Server{
+        {Slug: "local", Entry: Entry{Command: "node"}},
+        {Slug: "gh", External: &ExternalInfo{Provider: "github", Credentials: map[string]string{"personal_access_token": "ghp_x"}}},
+        {Slug: "oa", External: &ExternalInfo{Provider: "openai", APIKey: "sk-x"}},
+    }}
+    store := memStore{}
+    migrated, err := MigrateLegacyCredentials(r, store)
+    if err != nil { t.Fatal(err) }
+    if len(migrated) != 2 { t.Fatalf("want 2 migrated, got %v", migrated) }
+    if store["ext:github:gh"]["personal_access_token"] != "ghp_x" { t.Fatalf("github creds not stored: %v", store) }
+    if store["ext:openai:oa"]["api_key"] != "sk-x" { t.Fatalf("api key not stored: %v", store) }
+    for _, s := range r.Servers[1:] {
+        if s.External.APIKey != "" || s.External.Credentials != nil { t.Fatalf("%s not scrubbed", s.Slug) }
+        if s.External.CredentialRef == "" { t.Fatalf("%s missing credential ref", s.Slug) }
+    }
+    
+    // Second run is a no-op
+    migrated, err = MigrateLegacyCredentials(r, store)
+    if err != nil || len(migrated) != 0 { t.Fatalf("second run migrated %v, err %v", migrated, err) }
+    
+    // Stale inline copies never overwrite an existing vault entry
+    r.Servers[1].External.Credentials = map[string]string{"personal_access_token": "stale"}
+    if _, err := MigrateLegacyCredentials(r, store); err != nil { t.Fatal(err) }
+    if store["ext:github:gh"]["personal_access_token"] != "ghp_x" { t.Fatal("vault entry overwritten") }
+}
+
+func TestMigrateProviderCredentialRefs(t *testing.T) {
+    r := &Registry{Version: "1.0", Servers: []Server{
+        {Slug: "local", Entry: Entry{Command: "node"}},
+        {Slug: "gh", External: &ExternalInfo{Provider: "github", CredentialRef: "github"}},
+        {Slug: "oa", External: &ExternalInfo{Provider: "openai", CredentialRef: "ext:openai:oa"}},
+    }}
+    migrated := MigrateProviderCredentialRefs(r)
+    if len(migrated) != 1 || migrated[0] != "gh" { t.Fatalf("want [gh], got %v", migrated) }
+    if ref := r.Servers[1].External.CredentialRef; ref != "ext:github:gh" { t.Fatalf("gh ref = %q", ref) }
+    if ref := r.Servers[2].External.CredentialRef; ref != "ext:openai:oa" { t.Fatalf("oa ref changed to %q", ref) }
+    if again := MigrateProviderCredentialRefs(r); len(again) != 0 { t.Fatalf("second run migrated %v", again) }
+}
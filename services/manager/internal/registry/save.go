@@ -6,6 +6,9 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/yaml"
 )
 
 var saveMutex sync.Mutex
@@ -27,8 +30,16 @@ func Save(r *Registry, path string) error {
 	saveMutex.Lock()
 	defer saveMutex.Unlock()
 
+	// A YAML registry may be hand-written; saving it unchanged would only
+	// drop its comments and anchors
+	if yaml.IsYAML(path) {
+		if current, err := os.ReadFile(path); err == nil && yaml.Holds(current, r) {
+			return nil
+		}
+	}
+
 	// Ensure parent directory exists
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+	if err := paths.MkdirAll(filepath.Dir(path)); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
@@ -38,6 +49,12 @@ func Save(r *Registry, path string) error {
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tempPath := tempFile.Name()
+	// CreateTemp makes the file 0600; the rename would carry that over
+	if err := tempFile.Chmod(paths.FileMode()); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to set registry permissions: %w", err)
+	}
 
 	// Clean up temp file on any error
 	defer func() {
@@ -45,8 +62,8 @@ func Save(r *Registry, path string) error {
 		os.Remove(tempPath)
 	}()
 
-	// Marshal registry to pretty-printed JSON
-	data, err := json.MarshalIndent(r, "", "  ")
+	// Marshal registry in the format its extension names
+	data, err := marshal(r, path)
 	if err != nil {
 		return fmt.Errorf("failed to marshal registry: %w", err)
 	}
@@ -76,7 +93,7 @@ func Save(r *Registry, path string) error {
 	return nil
 }
 
-// SaveDefault saves the registry to the default location (~/.mcp/registry.json).
+// SaveDefault saves the registry to the default location, in the format it was found in.
 func SaveDefault(r *Registry) error {
 	path, err := DefaultPath()
 	if err != nil {
@@ -85,6 +102,14 @@ func SaveDefault(r *Registry) error {
 	return Save(r, path)
 }
 
+// marshal encodes v as YAML for a .yaml/.yml path and as indented JSON otherwise.
+func marshal(v any, path string) ([]byte, error) {
+	if yaml.IsYAML(path) {
+		return yaml.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", "  ")
+}
+
 // Save method for Registry to save itself
 func (r *Registry) Save(path string) error {
 	return Save(r, path)
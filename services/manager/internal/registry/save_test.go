@@ -11,6 +11,7 @@ func TestSave(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir := t.TempDir()
 	registryPath := filepath.Join(tmpDir, "registry.json")
+	t.Setenv("MCP_UMASK", "")
 
 	// Create a test registry
 	reg := &Registry{
@@ -49,6 +50,11 @@ func TestSave(t *testing.T) {
 		t.Fatalf("failed to save registry: %v", err)
 	}
 
+	// The registry follows the permission policy, not CreateTemp's 0600
+	if fi, err := os.Stat(registryPath); err != nil || fi.Mode().Perm() != 0o640 {
+		t.Fatalf("registry mode: %v, %v", fi.Mode(), err)
+	}
+
 	// Verify file exists
 	if _, err := os.Stat(registryPath); os.IsNotExist(err) {
 		t.Fatal("registry file was not created")
@@ -1,7 +1,12 @@
 package registry
 
 import (
+    "errors"
     "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+    "syscall"
     "time"
 )
 
@@ -21,6 +26,13 @@ type Server struct {
     Health   Health        `json:"health"`
     Clients  Clients       `json:"clients"`
     External *ExternalInfo `json:"external,omitempty"`
+    RPC      *RPCPolicy    `json:"rpcPolicy,omitempty"`
+    Logs     *LogFormat    `json:"logFormat,omitempty"`
+    Limits   *Limits       `json:"limits,omitempty"`
+    Priority *Priority     `json:"priority,omitempty"`
+
+    // Slugs of the local servers that must be ready before this one starts
+    DependsOn []string `json:"dependsOn,omitempty"`
 }
 
 type Source struct {
@@ -41,10 +53,146 @@ type PyRuntime struct {
 }
 
 type Entry struct {
-    Transport string            `json:"transport"`
-    Command   string            `json:"command"`
-    Args      []string          `json:"args,omitempty"`
-    Env       map[string]string `json:"env,omitempty"`
+    Transport   string            `json:"transport"`
+    Command     string            `json:"command"`
+    Args        []string          `json:"args,omitempty"`
+    Env         map[string]string `json:"env,omitempty"`
+    InheritEnv  string            `json:"inheritEnv,omitempty"`     // manager env the process gets: InheritAll (default), InheritNone or InheritAllowlist
+    InheritVars []string          `json:"inheritVars,omitempty"`    // with InheritAllowlist, the variables passed through
+    PreStart    []string          `json:"preStart,omitempty"`    // shell commands run before each launch
+    PostStop    []string          `json:"postStop,omitempty"`    // shell commands run after the process exits
+    RequiredEnv []RequiredEnv     `json:"requiredEnv,omitempty"` // env the user must provide before the first start
+    EntryPoints []EntryPoint      `json:"entryPoints,omitempty"` // ways to run the installed package, recorded by the installer
+    EntryPoint  string            `json:"entryPoint,omitempty"`  // name of the entry point Command and Args run
+}
+
+// EntryPoint is one executable an installed package provides, such as a
+// package bin, a console script or a Python module.
+type EntryPoint struct {
+    Name    string   `json:"name"`
+    Kind    string   `json:"kind"` // "bin", "console_script", "module" or "script"
+    Command string   `json:"command"`
+    Args    []string `json:"args,omitempty"`
+}
+
+// SelectEntryPoint makes the recorded entry point name the one the server
+// runs. It fails if the installer did not record an entry point by that name.
+func (e *Entry) SelectEntryPoint(name string) error {
+    if len(e.EntryPoints) == 0 {
+        return errors.New("no entry points were recorded for this server")
+    }
+    names := make([]string, 0, len(e.EntryPoints))
+    for _, ep := range e.EntryPoints {
+        if ep.Name == name {
+            e.Command = ep.Command
+            e.Args = append([]string(nil), ep.Args...)
+            e.EntryPoint = ep.Name
+            return nil
+        }
+        names = append(names, ep.Name)
+    }
+    return fmt.Errorf("unknown entry point %q, available: %s", name, strings.Join(names, ", "))
+}
+
+// LogFormat describes how a server's log lines group into records. In
+// "pattern" mode a line matching RecordStart opens a new record and every
+// other line continues the previous one (stack traces, pretty-printed JSON).
+type LogFormat struct {
+    Mode        string `json:"mode"`                  // "newline" (default) or "pattern"
+    RecordStart string `json:"recordStart,omitempty"` // regexp for the first line of a record
+}
+
+// RecordPattern returns the record-start regexp, or "" for one record per line.
+func (f *LogFormat) RecordPattern() string {
+    if f == nil || f.Mode != "pattern" {
+        return ""
+    }
+    return f.RecordStart
+}
+
+// RPCPolicy restricts which JSON-RPC methods the manager forwards to a server
+// through its /rpc proxy. Patterns are exact method names or a prefix ending
+// in "*", e.g. "tools/*".
+type RPCPolicy struct {
+    Allow []string `json:"allow,omitempty"` // when non-empty, only matching methods pass
+    Deny  []string `json:"deny,omitempty"`  // always rejected, checked before Allow
+}
+
+// Permits reports whether method may be forwarded. A nil policy permits everything.
+func (p *RPCPolicy) Permits(method string) bool {
+    if p == nil {
+        return true
+    }
+    if matchMethod(p.Deny, method) {
+        return false
+    }
+    return len(p.Allow) == 0 || matchMethod(p.Allow, method)
+}
+
+func matchMethod(patterns []string, method string) bool {
+    for _, pat := range patterns {
+        if prefix, ok := strings.CutSuffix(pat, "*"); ok {
+            if strings.HasPrefix(method, prefix) {
+                return true
+            }
+        } else if pat == method {
+            return true
+        }
+    }
+    return false
+}
+
+// Limits are hard resource limits the kernel enforces on a local server's
+// process, as opposed to the supervisor's sampled memory caps. Zero fields
+// are unlimited; limits the platform can't enforce are skipped with a note in
+// the server log.
+type Limits struct {
+    MaxMemoryMB   int `json:"maxMemoryMB,omitempty"`
+    MaxCPUPercent int `json:"maxCPUPercent,omitempty"` // 100 is one full core
+    MaxOpenFiles  int `json:"maxOpenFiles,omitempty"`
+}
+
+// Validate rejects negative limits.
+func (l *Limits) Validate() error {
+    if l == nil {
+        return nil
+    }
+    if l.MaxMemoryMB < 0 || l.MaxCPUPercent < 0 || l.MaxOpenFiles < 0 {
+        return errors.New("limits must not be negative")
+    }
+    return nil
+}
+
+// Priority is the scheduling priority a local server's process is launched
+// with, e.g. to keep background servers out of the way of foreground work.
+// Without it the process inherits the manager's.
+type Priority struct {
+    Nice    int    `json:"nice"`              // -20 (most favoured) to 19; below the manager's own needs privilege
+    IOClass string `json:"ioClass,omitempty"` // Linux only: "realtime", "best-effort" or "idle"
+    IOLevel *int   `json:"ioLevel,omitempty"` // 0 (highest) to 7 within realtime and best-effort
+}
+
+// Validate checks the ranges of p.
+func (p *Priority) Validate() error {
+    if p == nil {
+        return nil
+    }
+    if p.Nice < -20 || p.Nice > 19 {
+        return fmt.Errorf("priority.nice %d is outside -20..19", p.Nice)
+    }
+    switch p.IOClass {
+    case "", "idle":
+        if p.IOLevel != nil {
+            return errors.New("priority.ioLevel needs an ioClass of realtime or best-effort")
+        }
+    case "realtime", "best-effort":
+        if p.IOLevel != nil && (*p.IOLevel < 0 || *p.IOLevel > 7) {
+            return fmt.Errorf("priority.ioLevel %d is outside 0..7", *p.IOLevel)
+        }
+    default:
+        return fmt.Errorf("invalid priority.ioClass %q", p.IOClass)
+    }
+    return nil
 }
 
 type Perms struct {
@@ -58,18 +206,148 @@ type Autostart struct {
 }
 
 type Health struct {
-    Probe         string `json:"probe"`
-    Method        string `json:"method"`
-    IntervalSec   int    `json:"intervalSec"`
-    TimeoutSec    int    `json:"timeoutSec"`
-    RestartPolicy string `json:"restartPolicy"`
-    MaxRestarts   int    `json:"maxRestarts"`
+    Probe          string `json:"probe"`
+    Method         string `json:"method"`
+    IntervalSec    int    `json:"intervalSec"`
+    TimeoutSec     int    `json:"timeoutSec"`
+    RestartPolicy  string `json:"restartPolicy"`
+    MaxRestarts    int    `json:"maxRestarts"`
+    StopSignal     string `json:"stopSignal,omitempty"`     // asks the process to exit, SIGTERM when unset
+    StopTimeoutSec int    `json:"stopTimeoutSec,omitempty"` // how long it gets to exit before SIGKILL
+    
+    // Parameters of the tcp, exec and http probes; other probes ignore them.
+    // The http probe sends Method, GET when unset, to Path on Host:Port, or
+    // on the server's own URL when Port is unset.
+    Host           string   `json:"host,omitempty"`           // tcp and http, defaults to 127.0.0.1
+    Port           int      `json:"port,omitempty"`           // tcp and http
+    Path           string   `json:"path,omitempty"`           // http
+    ExpectedStatus int      `json:"expectedStatus,omitempty"` // http, 0 accepts any 2xx
+    Command        string   `json:"command,omitempty"`        // exec
+    Args           []string `json:"args,omitempty"`           // exec
+    ExpectedExit   int      `json:"expectedExit,omitempty"`   // exec, the exit code that counts as healthy
+
+    // Sent with the http check of an http server. Header values may be
+    // vault://<ref>/<key> references; BearerToken must be one and is sent as
+    // "Authorization: Bearer <token>".
+    Headers     map[string]string `json:"headers,omitempty"`
+    BearerToken string            `json:"bearerToken,omitempty"`
+}
+
+// Probe types that replace the transport's default health check.
+const (
+    ProbeTCP  = "tcp"  // dial Host:Port
+    ProbeExec = "exec" // run Command and compare its exit code
+    ProbeHTTP = "http" // send Method to Path and compare the status
+)
+
+// probeMethods are the HTTP methods an http probe may send.
+var probeMethods = map[string]bool{
+    http.MethodGet:     true,
+    http.MethodHead:    true,
+    http.MethodPost:    true,
+    http.MethodPut:     true,
+    http.MethodPatch:   true,
+    http.MethodDelete:  true,
+    http.MethodOptions: true,
+}
+
+// ProbeMethod returns the HTTP method of an http probe, GET when unset.
+func (h Health) ProbeMethod() string {
+    if h.Method == "" {
+        return http.MethodGet
+    }
+    return strings.ToUpper(h.Method)
+}
+
+// ValidateProbe checks the parameters of a tcp, exec or http probe and the
+// headers of the http check. Credentials in headers must come from the vault.
+func (h Health) ValidateProbe() error {
+    for name, value := range h.Headers {
+        if !validHeader(name, value) {
+            return fmt.Errorf("invalid health header %q", name)
+        }
+        if _, _, ok := ParseVaultRef(value); secretHeaders[http.CanonicalHeaderKey(name)] && !ok {
+            return fmt.Errorf("health header %q carries credentials and must be a vault:// reference", name)
+        }
+    }
+    if _, _, ok := ParseVaultRef(h.BearerToken); h.BearerToken != "" && !ok {
+        return fmt.Errorf("health bearerToken must be a vault://<ref>/<key> reference")
+    }
+
+    switch h.Probe {
+    case ProbeTCP:
+        if h.Port < 1 || h.Port > 65535 {
+            return fmt.Errorf("tcp probe needs a port between 1 and 65535, got %d", h.Port)
+        }
+    case ProbeExec:
+        if h.Command == "" {
+            return fmt.Errorf("exec probe needs a command")
+        }
+        if h.ExpectedExit < 0 || h.ExpectedExit > 255 {
+            return fmt.Errorf("invalid expected exit code %d", h.ExpectedExit)
+        }
+    case ProbeHTTP:
+        if !probeMethods[h.ProbeMethod()] {
+            return fmt.Errorf("http probe method %q is not supported", h.Method)
+        }
+        if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+            return fmt.Errorf("http probe path %q must start with /", h.Path)
+        }
+        if h.Port < 0 || h.Port > 65535 {
+            return fmt.Errorf("http probe port must be between 1 and 65535, got %d", h.Port)
+        }
+        if h.ExpectedStatus != 0 && (h.ExpectedStatus < 100 || h.ExpectedStatus > 599) {
+            return fmt.Errorf("invalid expected status %d", h.ExpectedStatus)
+        }
+    }
+    return nil
+}
+
+// stopSignals are the signals a server may be stopped with, by name.
+var stopSignals = map[string]syscall.Signal{
+    "SIGTERM": syscall.SIGTERM,
+    "SIGINT":  syscall.SIGINT,
+    "SIGQUIT": syscall.SIGQUIT,
+    "SIGHUP":  syscall.SIGHUP,
+    "SIGUSR1": syscall.SIGUSR1,
+    "SIGUSR2": syscall.SIGUSR2,
+    "SIGKILL": syscall.SIGKILL,
+}
+
+// ParseStopSignal returns the signal StopSignal names, SIGTERM when it is
+// empty. Names are case-insensitive and the SIG prefix is optional.
+func (h Health) ParseStopSignal() (syscall.Signal, error) {
+    if h.StopSignal == "" {
+        return syscall.SIGTERM, nil
+    }
+    name := strings.ToUpper(h.StopSignal)
+    if !strings.HasPrefix(name, "SIG") {
+        name = "SIG" + name
+    }
+    sig, ok := stopSignals[name]
+    if !ok {
+        return 0, fmt.Errorf("unknown stopSignal %q: want SIGTERM, SIGINT, SIGQUIT, SIGHUP, SIGUSR1, SIGUSR2 or SIGKILL", h.StopSignal)
+    }
+    return sig, nil
+}
+
+// ValidateStop checks the stop signal and timeout.
+func (h Health) ValidateStop() error {
+    if _, err := h.ParseStopSignal(); err != nil {
+        return err
+    }
+    if h.StopTimeoutSec < 0 {
+        return fmt.Errorf("invalid stopTimeoutSec %d", h.StopTimeoutSec)
+    }
+    return nil
 }
 
 type Clients struct {
     ClaudeDesktop *ClientFlag `json:"claudeDesktop,omitempty"`
     CursorGlobal  *ClientFlag `json:"cursorGlobal,omitempty"`
     Continue      *ClientFlag `json:"continue,omitempty"`
+    VSCode        *ClientFlag `json:"vscode,omitempty"`
+    Windsurf      *ClientFlag `json:"windsurf,omitempty"`
 }
 
 type ClientFlag struct { Enabled bool `json:"enabled"` }
@@ -81,6 +359,8 @@ type ExternalInfo struct {
     APIEndpoint   string                 `json:"apiEndpoint"`   // API URL for health checks
     AuthType      string                 `json:"authType"`      // "api_key", "oauth2", "basic"
     CredentialRef string                 `json:"credentialRef"` // Reference to stored credentials
+    Profiles      []string               `json:"profiles,omitempty"`      // Named credential profiles, see ProfileCredentialRef
+    ActiveProfile string                 `json:"activeProfile,omitempty"` // Profile CredentialRef points at; "" is the server's own credentials
     Config        map[string]interface{} `json:"config,omitempty"`        // Provider-specific configuration
     LastSync      *time.Time             `json:"lastSync,omitempty"`      // Last successful synchronization
     Status        ExternalStatus         `json:"status"`        // Detailed status information
@@ -89,6 +369,20 @@ type ExternalInfo struct {
     APIKey      string            `json:"apiKey,omitempty"`      // Deprecated: use CredentialRef
     Credentials map[string]string `json:"credentials,omitempty"` // Deprecated: use CredentialRef
     WebhookURL  string            `json:"webhookUrl,omitempty"`  // For services that use webhooks
+
+    // InsecureSkipVerify disables TLS verification for health checks. Development only.
+    InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+
+    // Health check override, e.g. for a self-hosted gateway compatible with the
+    // provider's API. An empty HealthEndpoint uses the provider template's.
+    HealthEndpoint string `json:"healthEndpoint,omitempty"`
+    ExpectedStatus int    `json:"expectedStatus,omitempty"` // 0 accepts any 2xx
+    ExpectedBody   string `json:"expectedBody,omitempty"`   // substring the response body must contain
+
+    // Extra headers and query parameters sent with health checks. Headers
+    // override the provider template's defaults; credentials never go here.
+    HealthHeaders map[string]string `json:"healthHeaders,omitempty"`
+    HealthQuery   map[string]string `json:"healthQuery,omitempty"`
 }
 
 // ExternalStatus provides detailed status tracking for external servers
@@ -99,6 +393,36 @@ type ExternalStatus struct {
     ResponseTime *int64     `json:"responseTime"` // Response time in milliseconds
 }
 
+// ServerCredentialRef is the vault key for one external server's credentials,
+// which override the provider default stored under the provider name.
+func ServerCredentialRef(provider, slug string) string {
+    return fmt.Sprintf("ext:%s:%s", provider, slug)
+}
+
+// ProfileCredentialRef is the vault key for a named credential profile of one
+// external server, e.g. ext:github:gh:staging. The empty profile is the
+// server's own ServerCredentialRef.
+func ProfileCredentialRef(provider, slug, profile string) string {
+    if profile == "" {
+        return ServerCredentialRef(provider, slug)
+    }
+    return ServerCredentialRef(provider, slug) + ":" + profile
+}
+
+// ValidateProfileName checks that name can key a credential profile: letters,
+// digits, '-' and '_' only.
+func ValidateProfileName(name string) error {
+    if name == "" {
+        return errors.New("profile name is required")
+    }
+    for _, c := range name {
+        if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+            return fmt.Errorf("invalid profile name %q: use letters, digits, '-' and '_'", name)
+        }
+    }
+    return nil
+}
+
 // CredentialRequirement defines what credentials a provider needs
 type CredentialRequirement struct {
     Key         string `json:"key"`         // Credential key name
@@ -162,9 +486,75 @@ func (s *Server) ValidateExternalSetup() error {
         return fmt.Errorf("external server missing credentials")
     }
 
+    return ext.ValidateHealthCheck()
+}
+
+// ValidateHealthCheck checks the health check override, if any.
+func (e *ExternalInfo) ValidateHealthCheck() error {
+    if e.HealthEndpoint != "" {
+        u, err := url.Parse(e.HealthEndpoint)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return fmt.Errorf("invalid health endpoint %q: must be an absolute http(s) URL", e.HealthEndpoint)
+        }
+    }
+    if e.ExpectedStatus != 0 && (e.ExpectedStatus < 100 || e.ExpectedStatus > 599) {
+        return fmt.Errorf("invalid expected status %d", e.ExpectedStatus)
+    }
+    if e.HealthEndpoint == "" && (e.ExpectedStatus != 0 || e.ExpectedBody != "") {
+        return fmt.Errorf("expected status and body require a health endpoint")
+    }
+    for name, value := range e.HealthHeaders {
+        if !validHeader(name, value) {
+            return fmt.Errorf("invalid health header %q", name)
+        }
+        if secretHeaders[http.CanonicalHeaderKey(name)] {
+            return fmt.Errorf("health header %q carries credentials; store them in the vault instead", name)
+        }
+    }
+    if _, ok := e.HealthQuery[""]; ok {
+        return fmt.Errorf("health query parameters need a name")
+    }
     return nil
 }
 
+// secretHeaders are built from vault credentials and may not be configured
+var secretHeaders = map[string]bool{
+    "Authorization":       true,
+    "Proxy-Authorization": true,
+    "Cookie":              true,
+    "X-Api-Key":           true,
+}
+
+// validHeader reports whether name and value can be sent as an HTTP header.
+func validHeader(name, value string) bool {
+    return name != "" && !strings.ContainsAny(name, " \t\r\n:") && !strings.ContainsAny(value, "\r\n")
+}
+
+// HealthRequestHeaders returns the headers health checks send: providerDefaults
+// overlaid with the server's own HealthHeaders.
+func (e *ExternalInfo) HealthRequestHeaders(providerDefaults map[string]string) map[string]string {
+    if len(providerDefaults) == 0 && len(e.HealthHeaders) == 0 {
+        return nil
+    }
+    headers := make(map[string]string, len(providerDefaults)+len(e.HealthHeaders))
+    for k, v := range providerDefaults {
+        headers[http.CanonicalHeaderKey(k)] = v
+    }
+    for k, v := range e.HealthHeaders {
+        headers[http.CanonicalHeaderKey(k)] = v
+    }
+    return headers
+}
+
+// HealthCheckURL returns the endpoint health checks should hit: the override
+// when set, otherwise providerDefault.
+func (e *ExternalInfo) HealthCheckURL(providerDefault string) string {
+    if e.HealthEndpoint != "" {
+        return e.HealthEndpoint
+    }
+    return providerDefault
+}
+
 // Methods for ExternalInfo struct
 
 // IsActive returns true if the external server is in an active state
@@ -199,6 +589,20 @@ func (e *ExternalInfo) GetDisplayName() string {
     return e.Provider
 }
 
+// HasProfile reports whether name is one of the server's credential profiles.
+// The unnamed profile, the server's own credentials, always exists.
+func (e *ExternalInfo) HasProfile(name string) bool {
+    if name == "" {
+        return true
+    }
+    for _, p := range e.Profiles {
+        if p == name {
+            return true
+        }
+    }
+    return false
+}
+
 // UpdateStatus updates the external status with new information
 func (e *ExternalInfo) UpdateStatus(state, message string, responseTime *int64) {
     now := time.Now()
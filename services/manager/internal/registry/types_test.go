@@ -0,0 +1,155 @@
+package registry
+
+import (
+    "syscall"
+    "testing"
+)
+
+func TestRPCPolicyPermits(t *testing.T) {
+    policy := &RPCPolicy{Allow: []string{"tools/*", "ping"}, Deny: []string{"tools/call"}}
+    cases := map[string]bool{
+        "tools/list":     true,
+        "tools/call":     false,
+        "ping":           true,
+        "resources/read": false,
+    }
+    for method, want := range cases {
+        if got := policy.Permits(method); got != want {
+            t.Errorf("Permits(%q) = %v, want %v", method, got, want)
+        }
+    }
+
+    denyOnly := &RPCPolicy{Deny: []string{"resources/*"}}
+    if !denyOnly.Permits("tools/call") || denyOnly.Permits("resources/read") {
+        t.Errorf("deny-only policy should allow everything except its patterns")
+    }
+
+    var none *RPCPolicy
+    if !none.Permits("anything") {
+        t.Errorf("nil policy should permit every method")
+    }
+}
+
+func TestSelectEntryPoint(t *testing.T) {
+    e := Entry{Command: "/venv/bin/demo-cli", EntryPoints: []EntryPoint{
+        {Name: "demo-cli", Kind: "console_script", Command: "/venv/bin/demo-cli"},
+        {Name: "demo.server", Kind: "module", Command: "/venv/bin/python", Args: []string{"-m", "demo.server"}},
+    }}
+    if err := e.SelectEntryPoint("demo.server"); err != nil {
+        t.Fatal(err)
+    }
+    if e.Command != "/venv/bin/python" || len(e.Args) != 2 || e.EntryPoint != "demo.server" {
+        t.Fatalf("entry = %+v", e)
+    }
+    // The selected args are a copy
+    e.Args[1] = "changed"
+    if e.EntryPoints[1].Args[1] != "demo.server" {
+        t.Fatal("entry point args aliased")
+    }
+
+    if err := e.SelectEntryPoint("missing"); err == nil || e.EntryPoint != "demo.server" {
+        t.Fatalf("unknown entry point: err %v, entry %+v", err, e)
+    }
+    if err := (&Entry{}).SelectEntryPoint("demo"); err == nil {
+        t.Fatal("selected an entry point with none recorded")
+    }
+}
+
+func TestHealthValidateProbe(t *testing.T) {
+    cases := []struct {
+        health Health
+        valid  bool
+    }{
+        {Health{Probe: "http"}, true},
+        {Health{Probe: ProbeTCP, Port: 8080}, true},
+        {Health{Probe: ProbeTCP}, false},
+        {Health{Probe: ProbeTCP, Port: 70000}, false},
+        {Health{Probe: ProbeExec, Command: "check.sh", ExpectedExit: 1}, true},
+        {Health{Probe: ProbeExec}, false},
+        {Health{Probe: ProbeExec, Command: "check.sh", ExpectedExit: -1}, false},
+        {Health{Probe: ProbeHTTP, Method: "head", Path: "/healthz", Port: 9000, ExpectedStatus: 204}, true},
+        {Health{Probe: ProbeHTTP, Method: "ping"}, false},
+        {Health{Probe: ProbeHTTP, Path: "healthz"}, false},
+        {Health{Probe: ProbeHTTP, ExpectedStatus: 42}, false},
+        {Health{Headers: map[string]string{"X-Tenant": "acme", "Authorization": "vault://env:srv/AUTH"}}, true},
+        {Health{Headers: map[string]string{"Authorization": "Bearer inline"}}, false},
+        {Health{Headers: map[string]string{"Bad Name": "x"}}, false},
+        {Health{BearerToken: "vault://env:srv/TOKEN"}, true},
+        {Health{BearerToken: "inline-token"}, false},
+    }
+    for _, c := range cases {
+        if err := c.health.ValidateProbe(); (err == nil) != c.valid {
+            t.Errorf("ValidateProbe(%+v) = %v, want valid %v", c.health, err, c.valid)
+        }
+    }
+}
+
+func TestHealthStopSignal(t *testing.T) {
+    cases := []struct {
+        health Health
+        want   syscall.Signal
+        valid  bool
+    }{
+        {Health{}, syscall.SIGTERM, true},
+        {Health{StopSignal: "SIGINT"}, syscall.SIGINT, true},
+        {Health{StopSignal: "quit", StopTimeoutSec: 30}, syscall.SIGQUIT, true},
+        {Health{StopSignal: "SIGSTOP"}, 0, false},
+        {Health{StopSignal: "15"}, 0, false},
+        {Health{StopTimeoutSec: -1}, syscall.SIGTERM, false},
+    }
+    for _, c := range cases {
+        if err := c.health.ValidateStop(); (err == nil) != c.valid {
+            t.Errorf("ValidateStop(%+v) = %v, want valid %v", c.health, err, c.valid)
+        }
+        if sig, _ := c.health.ParseStopSignal(); sig != c.want {
+            t.Errorf("ParseStopSignal(%+v) = %v, want %v", c.health, sig, c.want)
+        }
+    }
+}
+
+func TestPriorityValidate(t *testing.T) {
+    level := func(n int) *int { return &n }
+    cases := []struct {
+        priority *Priority
+        valid    bool
+    }{
+        {nil, true},
+        {&Priority{Nice: 10}, true},
+        {&Priority{Nice: -20, IOClass: "realtime", IOLevel: level(0)}, true},
+        {&Priority{Nice: 20}, false},
+        {&Priority{Nice: -21}, false},
+        {&Priority{IOClass: "idle"}, true},
+        {&Priority{IOClass: "idle", IOLevel: level(3)}, false},
+        {&Priority{IOLevel: level(3)}, false},
+        {&Priority{IOClass: "best-effort", IOLevel: level(8)}, false},
+        {&Priority{IOClass: "background"}, false},
+    }
+    for _, c := range cases {
+        if err := c.priority.Validate(); (err == nil) != c.valid {
+            t.Errorf("Validate(%+v) = %v, want valid %v", c.priority, err, c.valid)
+        }
+    }
+}
+
+func TestExternalHealthHeaders(t *testing.T) {
+    for name, valid := range map[string]bool{
+        "Notion-Version": true,
+        "authorization":  false,
+        "X-API-Key":      false,
+        "Bad Name":       false,
+    } {
+        ext := ExternalInfo{HealthHeaders: map[string]string{name: "v"}}
+        if err := ext.ValidateHealthCheck(); (err == nil) != valid {
+            t.Errorf("header %q: %v, want valid %v", name, err, valid)
+        }
+    }
+
+    ext := ExternalInfo{HealthHeaders: map[string]string{"notion-version": "2024-01-01", "X-Trace": "1"}}
+    got := ext.HealthRequestHeaders(map[string]string{"Notion-Version": "2022-06-28"})
+    if len(got) != 2 || got["Notion-Version"] != "2024-01-01" || got["X-Trace"] != "1" {
+        t.Fatalf("headers = %v", got)
+    }
+    if got := (&ExternalInfo{}).HealthRequestHeaders(nil); got != nil {
+        t.Fatalf("no headers = %v", got)
+    }
+}
@@ -0,0 +1,174 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldError describes one invalid setting. Field is the dotted JSON path,
+// e.g. "logs.retentionDays".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every setting that was rejected.
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (v *ValidationError) add(field, format string, args ...interface{}) {
+	v.Fields = append(v.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *ValidationError) Error() string {
+	parts := make([]string, len(v.Fields))
+	for i, f := range v.Fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return "invalid settings: " + strings.Join(parts, "; ")
+}
+
+// Patch returns current with a partial update applied. Objects are merged key
+// by key, so {"logs":{"level":"debug"}} leaves the other log settings alone;
+// any other value replaces the current one. Unknown keys, values of the wrong
+// type and values that fail validation are reported together as a
+// *ValidationError. current is not modified.
+func Patch(current *Settings, patch []byte) (*Settings, error) {
+	var changes map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	base, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(base, &doc); err != nil {
+		return nil, err
+	}
+
+	var v ValidationError
+	mergeObject(doc, changes, reflect.TypeOf(Settings{}), "", &v)
+	if len(v.Fields) > 0 {
+		return nil, &v
+	}
+
+	merged, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	updated := &Settings{}
+	if err := json.Unmarshal(merged, updated); err != nil {
+		return nil, err
+	}
+	if err := validate(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Changed returns the dotted paths of the settings that differ between a and
+// b, e.g. "health.restartGraceSec", sorted. A list counts as one setting.
+func Changed(a, b *Settings) []string {
+	var da, db map[string]interface{}
+	for _, c := range []struct {
+		s   *Settings
+		doc *map[string]interface{}
+	}{{a, &da}, {b, &db}} {
+		data, _ := json.Marshal(c.s)
+		_ = json.Unmarshal(data, c.doc)
+	}
+	var changed []string
+	diffObjects(da, db, "", &changed)
+	sort.Strings(changed)
+	return changed
+}
+
+// diffObjects adds the paths of the leaves that differ between a and b.
+func diffObjects(a, b map[string]interface{}, prefix string, changed *[]string) {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	for k := range keys {
+		sa, aIsObject := a[k].(map[string]interface{})
+		sb, bIsObject := b[k].(map[string]interface{})
+		switch {
+		case aIsObject && bIsObject:
+			diffObjects(sa, sb, prefix+k+".", changed)
+		case !reflect.DeepEqual(a[k], b[k]):
+			*changed = append(*changed, prefix+k)
+		}
+	}
+}
+
+// mergeObject applies changes to doc, the JSON form of a value of struct type t.
+func mergeObject(doc, changes map[string]json.RawMessage, t reflect.Type, prefix string, v *ValidationError) {
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		raw, path := changes[key], prefix+key
+		field, ok := jsonField(t, key)
+		if !ok {
+			v.add(path, "is not a known setting")
+			continue
+		}
+
+		var nested map[string]json.RawMessage
+		if field.Type.Kind() == reflect.Struct && json.Unmarshal(raw, &nested) == nil && nested != nil {
+			var sub map[string]json.RawMessage
+			if existing, ok := doc[key]; ok {
+				_ = json.Unmarshal(existing, &sub)
+			}
+			if sub == nil {
+				sub = map[string]json.RawMessage{}
+			}
+			mergeObject(sub, nested, field.Type, path+".", v)
+			doc[key], _ = json.Marshal(sub)
+			continue
+		}
+
+		if err := json.Unmarshal(raw, reflect.New(field.Type).Interface()); err != nil {
+			v.add(path, "must be %s", kindName(field.Type))
+			continue
+		}
+		doc[key] = raw
+	}
+}
+
+// jsonField finds the field of struct type t serialized under name.
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == name && f.IsExported() {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int64:
+		return "an integer"
+	case reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.Kind().String()
+}
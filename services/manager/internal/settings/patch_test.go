@@ -0,0 +1,118 @@
+package settings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatchDeepMerges(t *testing.T) {
+	current := NewDefault()
+	updated, err := Patch(current, []byte(`{"logs":{"level":"debug"},"theme":{"mode":"dark"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Logs.Level != "debug" || updated.Theme.Mode != "dark" {
+		t.Fatalf("patch not applied: %+v %+v", updated.Logs, updated.Theme)
+	}
+	if updated.Logs.MaxSizePerFile != current.Logs.MaxSizePerFile || updated.Theme.Accent != "blue" {
+		t.Fatalf("untouched fields lost: %+v %+v", updated.Logs, updated.Theme)
+	}
+	if current.Logs.Level != "info" {
+		t.Fatal("Patch modified current")
+	}
+}
+
+func TestPatchReportsAllOffendingFields(t *testing.T) {
+	_, err := Patch(NewDefault(), []byte(`{"logs":{"levle":"debug","retentionDays":"30"},"bogus":1}`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	got := map[string]bool{}
+	for _, f := range verr.Fields {
+		got[f.Field] = true
+	}
+	for _, want := range []string{"bogus", "logs.levle", "logs.retentionDays"} {
+		if !got[want] {
+			t.Errorf("missing %s in %+v", want, verr.Fields)
+		}
+	}
+
+	_, err = Patch(NewDefault(), []byte(`{"manager":{"port":70000,"healthCheckSec":0,"maxConcurrentInstalls":-1},"health":{"recoverAfterChecks":101},"theme":{"mode":"neon"}}`))
+	if !errors.As(err, &verr) || len(verr.Fields) != 5 {
+		t.Fatalf("expected five range/enum errors, got %v", err)
+	}
+
+	_, err = Patch(NewDefault(), []byte(`{"manager":{"memoryCapMB":-1,"globalMemoryCapMB":-1}}`))
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 || verr.Fields[0].Field != "manager.memoryCapMB" {
+		t.Fatalf("expected negative memory caps to be rejected, got %v", err)
+	}
+
+	_, err = Patch(NewDefault(), []byte(`{"notifications":{"webhooks":[{"url":"https://hooks.example.com/x","secret":"vault://settings:hook/KEY"},{"url":"hooks.example.com","secret":"plain"}]}}`))
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 || verr.Fields[0].Field != "notifications.webhooks[1].url" || verr.Fields[1].Field != "notifications.webhooks[1].secret" {
+		t.Fatalf("expected the second webhook's url and secret to be rejected, got %v", err)
+	}
+
+	if _, err := Patch(NewDefault(), []byte(`not json`)); err == nil || errors.As(err, &verr) {
+		t.Fatalf("expected a plain JSON error, got %v", err)
+	}
+}
+
+func TestLoadFillsMissingKeysWithDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(path, []byte(`{"theme":{"mode":"dark","accent":"red"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Theme.Mode != "dark" || s.Manager.Port != 38018 || s.Control.Port != 7100 || s.Logs.Level != "info" {
+		t.Fatalf("defaults not applied: %+v", s)
+	}
+}
+
+func TestReloadReportsChangedSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	cachedSettings = nil
+	if _, err := GetCached(); err != nil {
+		t.Fatal(err)
+	}
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	edited := `{"health":{"restartGraceSec":30},"manager":{"port":38018,"memoryLimitMB":128,"globalMemoryMB":1024,"healthCheckSec":30,"saveIntervalSec":300,"commandAllowlist":["node"]}}`
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	previous, current, err := Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Changed(previous, current)
+	if len(got) != 2 || got[0] != "health.restartGraceSec" || got[1] != "manager.commandAllowlist" {
+		t.Fatalf("changed = %v", got)
+	}
+	if cached, _ := GetCached(); cached != current {
+		t.Fatal("reload did not replace the cache")
+	}
+
+	// A broken file keeps what was loaded
+	if err := os.WriteFile(path, []byte(`{"manager":{"port":0}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Reload(); err == nil {
+		t.Fatal("invalid settings reloaded")
+	}
+	if cached, _ := GetCached(); cached != current {
+		t.Fatal("failed reload replaced the cache")
+	}
+}
@@ -4,9 +4,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
+
+	"mcp/manager/internal/paths"
+	"mcp/manager/internal/registry"
+	"mcp/manager/internal/yaml"
 )
 
 // Settings represents the application settings that persist across sessions.
@@ -29,6 +36,21 @@ type Settings struct {
 	// Storage information
 	Storage StorageInfo `json:"storage"`
 	
+	// TLS trust for health checks and installers
+	TLS TLSSettings `json:"tls"`
+	
+	// Health evaluation thresholds
+	Health HealthSettings `json:"health"`
+	
+	// gRPC control interface
+	Control ControlSettings `json:"control"`
+	
+	// Credential validation rate limit
+	Credentials CredentialSettings `json:"credentials"`
+	
+	// Where health status changes are sent
+	Notifications NotificationSettings `json:"notifications"`
+	
 	// Logs cap in MB
 	LogsCap int `json:"logsCap"`
 }
@@ -47,20 +69,101 @@ type ThemeSettings struct {
 
 // LogSettings controls logging behavior
 type LogSettings struct {
-	Level           string `json:"level"`           // "debug", "info", "warn", "error"
-	MaxSizePerFile  int64  `json:"maxSizePerFile"`  // bytes
-	MaxTotalSize    int64  `json:"maxTotalSize"`    // bytes
-	RetentionDays   int    `json:"retentionDays"`   // days to keep logs
-	RotationEnabled bool   `json:"rotationEnabled"` // enable automatic log rotation
+	Level                 string `json:"level"`                           // "debug", "info", "warn", "error"
+	MaxSizePerFile        int64  `json:"maxSizePerFile"`                  // bytes
+	MaxTotalSize          int64  `json:"maxTotalSize"`                    // bytes
+	RetentionDays         int    `json:"retentionDays"`                   // days to keep logs
+	RotationEnabled       bool   `json:"rotationEnabled"`                 // enable automatic log rotation
+	JanitorIntervalSec    int    `json:"janitorIntervalSec,omitempty"`    // seconds between rotation passes; 0 means the default
+	InstallTranscriptDays int    `json:"installTranscriptDays,omitempty"` // days to keep install transcripts; 0 means the default
+	StreamBuffer          int    `json:"streamBuffer,omitempty"`          // entries queued per log stream client; 0 means 100
+	StreamOverflow        string `json:"streamOverflow,omitempty"`        // "marker" (default) or "block", for a client that falls a buffer behind
+	StreamBlockMs         int    `json:"streamBlockMs,omitempty"`         // with "block", ms to wait for room before disconnecting; 0 means 2000
+}
+
+// DefaultJanitorIntervalSec is used when LogSettings.JanitorIntervalSec is unset.
+const DefaultJanitorIntervalSec = 120
+
+// JanitorInterval returns how often the log rotation janitor should run.
+func (l LogSettings) JanitorInterval() time.Duration {
+	if l.JanitorIntervalSec <= 0 {
+		return DefaultJanitorIntervalSec * time.Second
+	}
+	return time.Duration(l.JanitorIntervalSec) * time.Second
+}
+
+// DefaultInstallTranscriptDays is used when LogSettings.InstallTranscriptDays is unset.
+const DefaultInstallTranscriptDays = 30
+
+// InstallTranscriptRetention returns how long install transcripts are kept.
+func (l LogSettings) InstallTranscriptRetention() time.Duration {
+	days := l.InstallTranscriptDays
+	if days <= 0 {
+		days = DefaultInstallTranscriptDays
+	}
+	return time.Duration(days) * 24 * time.Hour
 }
 
 // ManagerSettings controls daemon behavior
 type ManagerSettings struct {
-	Port            int    `json:"port"`            // HTTP API port
-	MemoryLimitMB   int    `json:"memoryLimitMB"`   // per-server memory limit
-	GlobalMemoryMB  int    `json:"globalMemoryMB"`  // global memory limit
-	HealthCheckSec  int    `json:"healthCheckSec"`  // health check interval
-	SaveIntervalSec int    `json:"saveIntervalSec"` // registry save interval
+	Port                      int      `json:"port"`                       // HTTP API port
+	MemoryLimitMB             int      `json:"memoryLimitMB"`              // per-server memory limit
+	GlobalMemoryMB            int      `json:"globalMemoryMB"`             // global memory limit
+	HealthCheckSec            int      `json:"healthCheckSec"`             // health check interval
+	SaveIntervalSec           int      `json:"saveIntervalSec"`            // registry save interval
+	AutoRestartOnConfigChange bool     `json:"autoRestartOnConfigChange"`  // restart running servers whose command, args, env or limits were edited
+	CommandAllowlist          []string `json:"commandAllowlist,omitempty"` // absolute paths or PATH basenames servers and exec probes may run, refusing hooks; empty allows any; reapplied on SIGHUP
+	MaxConcurrentInstalls     int      `json:"maxConcurrentInstalls,omitempty"` // installs run at once, the rest queue; 0 means the default
+	MemoryCapMB               int      `json:"memoryCapMB,omitempty"`           // RSS a server may use before it is killed, unless its limits.maxMemoryMB is set; 0 means no cap
+	GlobalMemoryCapMB         int      `json:"globalMemoryCapMB,omitempty"`     // RSS all servers may use before starts are refused; 0 means no cap
+}
+
+// TLSSettings controls certificate trust for outbound connections.
+// Changes take effect on the next daemon start.
+type TLSSettings struct {
+	CABundle string `json:"caBundle,omitempty"` // PEM file with extra CA certificates (e.g. a private CA)
+}
+
+// HealthSettings tunes when a supervised process is reported degraded or down.
+// Zero values fall back to the built-in defaults.
+type HealthSettings struct {
+	DegradedMissedPings int `json:"degradedMissedPings,omitempty"` // consecutive missed pings before degraded
+	DownMissedPings     int `json:"downMissedPings,omitempty"`     // consecutive missed pings before down
+	MaxPingMs           int `json:"maxPingMs,omitempty"`           // ping latency ceiling in milliseconds
+	MaxRestarts10m      int `json:"maxRestarts10m,omitempty"`      // restarts within 10 minutes before degraded
+	CheckConcurrency    int `json:"checkConcurrency,omitempty"`    // health checks run at once; applied on the next daemon start
+	RestartGraceSec     int `json:"restartGraceSec,omitempty"`     // seconds a failing server gets to recover before a health restart; 0 means the default
+	DegradeAfterChecks  int `json:"degradeAfterChecks,omitempty"`  // consecutive worse checks before a status drops
+	RecoverAfterChecks  int `json:"recoverAfterChecks,omitempty"`  // consecutive better checks before a status recovers
+}
+
+// ControlSettings controls the optional gRPC control listener.
+// Changes take effect on the next daemon start.
+type ControlSettings struct {
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"` // loopback TCP port, separate from the HTTP API
+}
+
+// CredentialSettings limits how often credentials may be validated against a
+// provider. Changes take effect on the next daemon start.
+type CredentialSettings struct {
+	ValidationMaxAttempts int  `json:"validationMaxAttempts,omitempty"` // attempts per window; 0 means the default
+	ValidationWindowSec   int  `json:"validationWindowSec,omitempty"`   // window length in seconds; 0 means the default
+	ValidationPerClient   bool `json:"validationPerClient,omitempty"`   // count attempts per client address as well as per provider
+}
+
+// NotificationSettings lists the webhooks every health status change is
+// POSTed to.
+type NotificationSettings struct {
+	Webhooks []WebhookSettings `json:"webhooks,omitempty"`
+}
+
+// WebhookSettings is one webhook. With a Secret the body is signed with
+// HMAC-SHA256; the secret must be a vault://<ref>/<key> reference, so it
+// never appears in the settings file or the API.
+type WebhookSettings struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
 }
 
 // PerformanceSettings contains performance-related settings
@@ -80,13 +183,14 @@ var (
 	cachedSettings *Settings
 )
 
-// DefaultPath returns the default settings file path (~/.mcp/settings.json).
+// DefaultPath returns the default settings file path under the MCP root:
+// settings.yaml or settings.yml when one exists, otherwise settings.json.
 func DefaultPath() (string, error) {
-	home, err := os.UserHomeDir()
+	path, err := paths.ConfigFile("settings")
 	if err != nil {
-		return "", fmt.Errorf("failed to get user home directory: %w", err)
+		return "", fmt.Errorf("failed to resolve MCP directory: %w", err)
 	}
-	return filepath.Join(home, ".mcp", "settings.json"), nil
+	return path, nil
 }
 
 // NewDefault creates a new Settings instance with default values.
@@ -101,11 +205,13 @@ func NewDefault() *Settings {
 			Accent: "blue",
 		},
 		Logs: LogSettings{
-			Level:           "info",
-			MaxSizePerFile:  10 * 1024 * 1024,  // 10MB per file
-			MaxTotalSize:    100 * 1024 * 1024, // 100MB total
-			RetentionDays:   30,                 // 30 days
-			RotationEnabled: true,
+			Level:                 "info",
+			MaxSizePerFile:        128 * 1024 * 1024,  // 128MB per file
+			MaxTotalSize:          1024 * 1024 * 1024, // 1GB total
+			RetentionDays:         30,                 // 30 days
+			RotationEnabled:       true,
+			JanitorIntervalSec:    DefaultJanitorIntervalSec,
+			InstallTranscriptDays: DefaultInstallTranscriptDays,
 		},
 		Manager: ManagerSettings{
 			Port:            38018,
@@ -114,6 +220,24 @@ func NewDefault() *Settings {
 			HealthCheckSec:  30,   // 30 second health checks
 			SaveIntervalSec: 300,  // save registry every 5 minutes
 		},
+		Health: HealthSettings{
+			DegradedMissedPings: 1,
+			DownMissedPings:     3,
+			MaxPingMs:           1000,
+			MaxRestarts10m:      1,
+			CheckConcurrency:    16,
+			RestartGraceSec:     10,
+			DegradeAfterChecks:  1,
+			RecoverAfterChecks:  2,
+		},
+		Control: ControlSettings{
+			Enabled: false,
+			Port:    7100,
+		},
+		Credentials: CredentialSettings{
+			ValidationMaxAttempts: 5,
+			ValidationWindowSec:   60,
+		},
 		Performance: PerformanceSettings{
 			RefreshInterval: 5000, // 5 seconds
 			MaxLogLines:     1000,
@@ -133,19 +257,47 @@ func Load(path string) (*Settings, error) {
 		return nil, fmt.Errorf("failed to read settings file: %w", err)
 	}
 
-	var settings Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return nil, fmt.Errorf("failed to parse settings JSON: %w", err)
+	// Keys missing from older files keep their defaults
+	settings := NewDefault()
+	if yaml.IsYAML(path) {
+		err = yaml.Unmarshal(data, settings)
+	} else {
+		err = json.Unmarshal(data, settings)
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse settings file: %w", err)
+	}
+	migrateLogCaps(&settings.Logs)
 
-	if err := validate(&settings); err != nil {
+	if err := validate(settings); err != nil {
 		return nil, fmt.Errorf("settings validation failed: %w", err)
 	}
 
-	return &settings, nil
+	return settings, nil
+}
+
+// The log caps NewDefault used before they were raised. Every saved settings
+// file spells them out, so they are read as "the default" rather than as a
+// choice.
+const (
+	oldDefaultMaxSizePerFile = 10 * 1024 * 1024
+	oldDefaultMaxTotalSize   = 100 * 1024 * 1024
+)
+
+// migrateLogCaps moves log caps still at their old defaults to the current
+// ones. A per-file cap is raised no further than a total the user set, so
+// the file still validates. The next save writes them back.
+func migrateLogCaps(l *LogSettings) {
+	defaults := NewDefault().Logs
+	if l.MaxTotalSize == oldDefaultMaxTotalSize {
+		l.MaxTotalSize = defaults.MaxTotalSize
+	}
+	if l.MaxSizePerFile == oldDefaultMaxSizePerFile {
+		l.MaxSizePerFile = min(defaults.MaxSizePerFile, max(l.MaxTotalSize, oldDefaultMaxSizePerFile))
+	}
 }
 
-// LoadDefault loads settings from the default path (~/.mcp/settings.json).
+// LoadDefault loads settings from the default path (see DefaultPath).
 // If the file doesn't exist, returns default settings.
 func LoadDefault() (*Settings, error) {
 	path, err := DefaultPath()
@@ -184,7 +336,7 @@ func Save(settings *Settings, path string) error {
 	defer settingsMutex.Unlock()
 
 	// Ensure parent directory exists
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+	if err := paths.MkdirAll(filepath.Dir(path)); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
@@ -194,6 +346,11 @@ func Save(settings *Settings, path string) error {
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tempPath := tempFile.Name()
+	if err := tempFile.Chmod(paths.FileMode()); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to set settings permissions: %w", err)
+	}
 
 	// Clean up temp file on any error
 	defer func() {
@@ -201,8 +358,13 @@ func Save(settings *Settings, path string) error {
 		os.Remove(tempPath)
 	}()
 
-	// Marshal settings to pretty-printed JSON
-	data, err := json.MarshalIndent(settings, "", "  ")
+	// Marshal settings in the format the file's extension names
+	var data []byte
+	if yaml.IsYAML(path) {
+		data, err = yaml.Marshal(settings)
+	} else {
+		data, err = json.MarshalIndent(settings, "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal settings: %w", err)
 	}
@@ -279,52 +441,198 @@ func UpdateCached(settings *Settings) error {
 	return nil
 }
 
-// validate checks if the settings are valid.
+// Reload rereads the settings file into the cache, for edits made to it
+// outside the API, and returns the settings it replaced. An unreadable or
+// invalid file leaves the cache alone.
+func Reload() (previous, current *Settings, err error) {
+	current, err = LoadDefault()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settingsMutex.Lock()
+	previous = cachedSettings
+	cachedSettings = current
+	settingsMutex.Unlock()
+
+	if previous == nil {
+		previous = NewDefault()
+	}
+	return previous, current, nil
+}
+
+// Validate reports whether s would be accepted by Save.
+func Validate(s *Settings) error {
+	return validate(s)
+}
+
+// validate checks every setting and reports all invalid fields at once as a
+// *ValidationError.
 func validate(s *Settings) error {
+	var v ValidationError
+
 	if s.Autostart.Scope != "user" && s.Autostart.Scope != "system" {
-		return fmt.Errorf("invalid autostart scope: %s", s.Autostart.Scope)
+		v.add("autostart.scope", "must be user or system, got %q", s.Autostart.Scope)
 	}
 
 	if s.Theme.Mode != "light" && s.Theme.Mode != "dark" && s.Theme.Mode != "system" {
-		return fmt.Errorf("invalid theme mode: %s", s.Theme.Mode)
+		v.add("theme.mode", "must be light, dark or system, got %q", s.Theme.Mode)
 	}
 
 	logLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !logLevels[s.Logs.Level] {
-		return fmt.Errorf("invalid log level: %s", s.Logs.Level)
+		v.add("logs.level", "must be debug, info, warn or error, got %q", s.Logs.Level)
 	}
 
 	if s.Logs.MaxSizePerFile <= 0 {
-		return fmt.Errorf("maxSizePerFile must be positive")
+		v.add("logs.maxSizePerFile", "must be positive")
 	}
 
 	if s.Logs.MaxTotalSize <= 0 {
-		return fmt.Errorf("maxTotalSize must be positive")
+		v.add("logs.maxTotalSize", "must be positive")
+	}
+
+	if s.Logs.MaxSizePerFile > s.Logs.MaxTotalSize {
+		v.add("logs.maxSizePerFile", "(%d) must not exceed maxTotalSize (%d)", s.Logs.MaxSizePerFile, s.Logs.MaxTotalSize)
+	}
+
+	if s.Logs.JanitorIntervalSec < 0 || s.Logs.JanitorIntervalSec > 86400 {
+		v.add("logs.janitorIntervalSec", "must be between 0 (default) and 86400")
+	}
+
+	if s.Logs.RetentionDays <= 0 || s.Logs.RetentionDays > 3650 {
+		v.add("logs.retentionDays", "must be between 1 and 3650")
+	}
+
+	if s.Logs.InstallTranscriptDays < 0 || s.Logs.InstallTranscriptDays > 3650 {
+		v.add("logs.installTranscriptDays", "must be between 0 (default) and 3650, got %d", s.Logs.InstallTranscriptDays)
+	}
+
+	if s.Logs.StreamBuffer != 0 && (s.Logs.StreamBuffer < 2 || s.Logs.StreamBuffer > 100000) {
+		v.add("logs.streamBuffer", "must be 0 (default) or between 2 and 100000, got %d", s.Logs.StreamBuffer)
+	}
+
+	switch s.Logs.StreamOverflow {
+	case "", "marker", "block":
+	default:
+		v.add("logs.streamOverflow", "must be \"marker\" or \"block\", got %q", s.Logs.StreamOverflow)
+	}
+
+	if s.Logs.StreamBlockMs < 0 || s.Logs.StreamBlockMs > 60000 {
+		v.add("logs.streamBlockMs", "must be between 0 (default) and 60000, got %d", s.Logs.StreamBlockMs)
+	}
+
+	h := s.Health
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"health.degradedMissedPings", h.DegradedMissedPings},
+		{"health.downMissedPings", h.DownMissedPings},
+		{"health.maxPingMs", h.MaxPingMs},
+		{"health.maxRestarts10m", h.MaxRestarts10m},
+	} {
+		if f.value < 0 {
+			v.add(f.name, "must not be negative")
+		}
+	}
+
+	if h.CheckConcurrency < 0 || h.CheckConcurrency > 256 {
+		v.add("health.checkConcurrency", "must be between 0 (default) and 256, got %d", h.CheckConcurrency)
+	}
+
+	if h.RestartGraceSec < 0 || h.RestartGraceSec > 3600 {
+		v.add("health.restartGraceSec", "must be between 0 (default) and 3600, got %d", h.RestartGraceSec)
+	}
+
+	if h.DegradeAfterChecks < 0 || h.DegradeAfterChecks > 100 {
+		v.add("health.degradeAfterChecks", "must be between 0 (default) and 100, got %d", h.DegradeAfterChecks)
+	}
+	if h.RecoverAfterChecks < 0 || h.RecoverAfterChecks > 100 {
+		v.add("health.recoverAfterChecks", "must be between 0 (default) and 100, got %d", h.RecoverAfterChecks)
+	}
+
+	if h.DegradedMissedPings > 0 && h.DownMissedPings > 0 && h.DownMissedPings < h.DegradedMissedPings {
+		v.add("health.downMissedPings", "(%d) must not be below degradedMissedPings (%d)", h.DownMissedPings, h.DegradedMissedPings)
+	}
+
+	if s.Control.Enabled && (s.Control.Port <= 0 || s.Control.Port > 65535) {
+		v.add("control.port", "must be between 1 and 65535, got %d", s.Control.Port)
+	}
+
+	if c := s.Credentials.ValidationMaxAttempts; c < 0 || c > 10000 {
+		v.add("credentials.validationMaxAttempts", "must be between 0 (default) and 10000, got %d", c)
+	}
+
+	if c := s.Credentials.ValidationWindowSec; c < 0 || c > 86400 {
+		v.add("credentials.validationWindowSec", "must be between 0 (default) and 86400, got %d", c)
 	}
 
-	if s.Logs.RetentionDays <= 0 {
-		return fmt.Errorf("retentionDays must be positive")
+	for i, hook := range s.Notifications.Webhooks {
+		field := fmt.Sprintf("notifications.webhooks[%d]", i)
+		if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add(field+".url", "must be an http or https URL, got %q", hook.URL)
+		}
+		if _, _, ok := registry.ParseVaultRef(hook.Secret); hook.Secret != "" && !ok {
+			v.add(field+".secret", "must be a vault://<ref>/<key> reference")
+		}
 	}
 
 	if s.Manager.Port <= 0 || s.Manager.Port > 65535 {
-		return fmt.Errorf("invalid port: %d", s.Manager.Port)
+		v.add("manager.port", "must be between 1 and 65535, got %d", s.Manager.Port)
 	}
 
 	if s.Manager.MemoryLimitMB <= 0 {
-		return fmt.Errorf("memoryLimitMB must be positive")
+		v.add("manager.memoryLimitMB", "must be positive")
 	}
 
 	if s.Manager.GlobalMemoryMB <= 0 {
-		return fmt.Errorf("globalMemoryMB must be positive")
+		v.add("manager.globalMemoryMB", "must be positive")
+	}
+
+	if s.Manager.HealthCheckSec <= 0 || s.Manager.HealthCheckSec > 3600 {
+		v.add("manager.healthCheckSec", "must be between 1 and 3600")
 	}
 
-	if s.Manager.HealthCheckSec <= 0 {
-		return fmt.Errorf("healthCheckSec must be positive")
+	if s.Manager.SaveIntervalSec <= 0 || s.Manager.SaveIntervalSec > 86400 {
+		v.add("manager.saveIntervalSec", "must be between 1 and 86400")
 	}
 
-	if s.Manager.SaveIntervalSec <= 0 {
-		return fmt.Errorf("saveIntervalSec must be positive")
+	if n := s.Manager.MaxConcurrentInstalls; n < 0 || n > 64 {
+		v.add("manager.maxConcurrentInstalls", "must be between 0 (default) and 64, got %d", n)
+	}
+
+	if s.Manager.MemoryCapMB < 0 {
+		v.add("manager.memoryCapMB", "must not be negative")
+	}
+	if s.Manager.GlobalMemoryCapMB < 0 {
+		v.add("manager.globalMemoryCapMB", "must not be negative")
 	}
 
+	for i, c := range s.Manager.CommandAllowlist {
+		if c == "" || (!filepath.IsAbs(c) && strings.ContainsRune(c, filepath.Separator)) {
+			v.add(fmt.Sprintf("manager.commandAllowlist[%d]", i), "must be an absolute path or a bare command name, got %q", c)
+		}
+	}
+
+	if s.Performance.RefreshInterval < 100 || s.Performance.RefreshInterval > 3600000 {
+		v.add("performance.refreshInterval", "must be between 100 and 3600000 milliseconds")
+	}
+
+	if s.Performance.MaxLogLines <= 0 || s.Performance.MaxLogLines > 1000000 {
+		v.add("performance.maxLogLines", "must be between 1 and 1000000")
+	}
+
+	if s.LogsCap <= 0 {
+		v.add("logsCap", "must be positive")
+	}
+
+	if s.TLS.CABundle != "" && !filepath.IsAbs(s.TLS.CABundle) {
+		v.add("tls.caBundle", "must be an absolute path, got %s", s.TLS.CABundle)
+	}
+
+	if len(v.Fields) > 0 {
+		return &v
+	}
 	return nil
-}
\ No newline at end of file
+}
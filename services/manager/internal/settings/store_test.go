@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestSettingsLifecycle(t *testing.T) {
@@ -107,4 +108,88 @@ func TestAtomicSave(t *testing.T) {
 	if loaded.Theme.Mode != "dark" {
 		t.Errorf("expected theme mode 'dark', got %s", loaded.Theme.Mode)
 	}
-}
\ No newline at end of file
+}
+
+func TestLogRotationSettings(t *testing.T) {
+	defaults := NewDefault()
+	if got := defaults.Logs.JanitorInterval(); got != 2*time.Minute {
+		t.Errorf("expected default janitor interval 2m, got %s", got)
+	}
+
+	// Settings files written before the interval existed still load
+	legacy := NewDefault()
+	legacy.Logs.JanitorIntervalSec = 0
+	if err := validate(legacy); err != nil {
+		t.Errorf("unset janitor interval should be valid: %v", err)
+	}
+	if got := legacy.Logs.JanitorInterval(); got != 2*time.Minute {
+		t.Errorf("unset janitor interval should default to 2m, got %s", got)
+	}
+
+	tooBig := NewDefault()
+	tooBig.Logs.MaxSizePerFile = tooBig.Logs.MaxTotalSize + 1
+	if err := validate(tooBig); err == nil {
+		t.Error("validation should fail when maxSizePerFile exceeds maxTotalSize")
+	}
+
+	negative := NewDefault()
+	negative.Logs.JanitorIntervalSec = -5
+	if err := validate(negative); err == nil {
+		t.Error("validation should fail for a negative janitor interval")
+	}
+
+	// Caps saved at the old defaults move to the new ones; others are kept
+	path := filepath.Join(t.TempDir(), "settings.json")
+	src := `{"logs":{"level":"info","maxSizePerFile":10485760,"maxTotalSize":52428800000,"retentionDays":30,"rotationEnabled":true}}`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.Logs.MaxSizePerFile != defaults.Logs.MaxSizePerFile || migrated.Logs.MaxTotalSize != 52428800000 {
+		t.Errorf("migrated caps %d/%d", migrated.Logs.MaxSizePerFile, migrated.Logs.MaxTotalSize)
+	}
+
+	// A total set below the new per-file default holds the per-file cap at it
+	src = `{"logs":{"level":"info","maxSizePerFile":10485760,"maxTotalSize":52428800,"retentionDays":30,"rotationEnabled":true}}`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	migrated, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.Logs.MaxSizePerFile != 52428800 || migrated.Logs.MaxTotalSize != 52428800 {
+		t.Errorf("migrated caps %d/%d", migrated.Logs.MaxSizePerFile, migrated.Logs.MaxTotalSize)
+	}
+}
+
+func TestYAMLSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yml")
+	src := "# Only what differs from the defaults\nmanager:\n  port: 39000\nhealth:\n  checkConcurrency: 4\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys the file leaves out keep their defaults
+	if s.Manager.Port != 39000 || s.Health.CheckConcurrency != 4 || s.Manager.SaveIntervalSec != 300 {
+		t.Fatalf("loaded %+v", s)
+	}
+
+	s.Theme.Mode = "dark"
+	if err := Save(s, path); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Theme.Mode != "dark" || again.Manager.Port != 39000 {
+		t.Fatalf("reloaded %+v", again)
+	}
+}
@@ -0,0 +1,149 @@
+package supervisor
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+// ErrCommandNotAllowed is returned when a server's command is not covered by
+// the command allowlist. The server is marked failed and not retried.
+var ErrCommandNotAllowed = errors.New("command not in allowlist")
+
+// binScriptHeader marks the launchers the installers write to bin/<slug>.
+const binScriptHeader = "# Generated MCP server launcher"
+
+// SetCommandAllowlist restricts the commands servers may launch to list:
+// absolute paths, matched after cleaning, and bare names, matched against a
+// command given as a bare name and looked up on PATH. An empty list lifts
+// the restriction. Commands inside the server's own directory or the shared
+// runtimes directory, and the target of its generated bin script, are always
+// allowed since the manager put them there.
+func (s *Supervisor) SetCommandAllowlist(list []string) {
+    s.allowMu.Lock()
+    defer s.allowMu.Unlock()
+    s.allowlist = nil
+    for _, c := range list {
+        if c = strings.TrimSpace(c); c != "" {
+            s.allowlist = append(s.allowlist, c)
+        }
+    }
+}
+
+// checkCommandAllowed returns an ErrCommandNotAllowed error when an
+// allowlist is set and does not cover sv's command.
+func (s *Supervisor) checkCommandAllowed(sv *registry.Server) error {
+    return s.CommandAllowed(sv.Slug, sv.Entry.Command)
+}
+
+// CommandAllowed returns an ErrCommandNotAllowed error when an allowlist is
+// set and does not cover command run on behalf of the server slug, such as
+// its exec health probe. A relative path is taken inside the server's
+// directory.
+func (s *Supervisor) CommandAllowed(slug, name string) error {
+    allow := s.commandAllowlist()
+    if len(allow) == 0 {
+        return nil
+    }
+
+    command := name
+    if !strings.ContainsRune(command, filepath.Separator) {
+        for _, a := range allow {
+            if a == command {
+                return nil
+            }
+        }
+        // The name may still resolve to an allowed path
+        if resolved, err := exec.LookPath(command); err == nil {
+            command = resolved
+        }
+    } else if !filepath.IsAbs(command) {
+        command = filepath.Join(serverDir(slug), command)
+    }
+    command = filepath.Clean(command)
+
+    if filepath.IsAbs(command) {
+        for _, a := range allow {
+            if filepath.IsAbs(a) && filepath.Clean(a) == command {
+                return nil
+            }
+        }
+        if dir := serverDir(slug); dir != "" && within(dir, command) {
+            return nil
+        }
+        if dir, err := paths.RuntimesDir(); err == nil && within(dir, command) {
+            return nil
+        }
+    }
+    if target := binScriptTarget(slug); target != "" && target == name {
+        return nil
+    }
+    return fmt.Errorf("%w: %s", ErrCommandNotAllowed, name)
+}
+
+// commandAllowlist returns the allowlist in effect, nil when there is none.
+func (s *Supervisor) commandAllowlist() []string {
+    s.allowMu.RLock()
+    defer s.allowMu.RUnlock()
+    return s.allowlist
+}
+
+// within reports whether path lies inside dir.
+func within(dir, path string) bool {
+    rel, err := filepath.Rel(dir, path)
+    return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// binScriptTarget returns the command the generated bin script of slug
+// execs, or "" when there is no such script. The script is read from disk
+// rather than trusted from the registry.
+func binScriptTarget(slug string) string {
+    dir := serverDir(slug)
+    if dir == "" {
+        return ""
+    }
+    f, err := os.Open(filepath.Join(dir, "bin", slug))
+    if err != nil {
+        return ""
+    }
+    defer f.Close()
+
+    sc := bufio.NewScanner(f)
+    generated := false
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line == binScriptHeader {
+            generated = true
+            continue
+        }
+        if rest, ok := strings.CutPrefix(line, "exec "); ok && generated {
+            return execTarget(rest)
+        }
+    }
+    return ""
+}
+
+// execTarget returns the command an exec line runs. The installers quote it,
+// as in exec "node" "index.js" "$@", and the path may hold spaces.
+func execTarget(rest string) string {
+    rest = strings.TrimSpace(rest)
+    if quoted, err := strconv.QuotedPrefix(rest); err == nil {
+        command, err := strconv.Unquote(quoted)
+        if err != nil {
+            return ""
+        }
+        return command
+    }
+    if fields := strings.Fields(rest); len(fields) > 0 {
+        return fields[0]
+    }
+    return ""
+}
@@ -0,0 +1,111 @@
+package supervisor
+
+import (
+    "errors"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/install"
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+func TestCheckCommandAllowed(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    binDir := filepath.Join(srvDir, "demo", "bin")
+    if err := install.WriteBinScript("demo", registry.Entry{Command: "node", Args: []string{"/srv/index.js"}, Env: map[string]string{"PATH": "/x"}}); err != nil { t.Fatal(err) }
+    if err := install.WriteBinScript("spaced", registry.Entry{Command: "/opt/my tools/node", Args: []string{"index.js"}}); err != nil { t.Fatal(err) }
+    sleepPath, err := exec.LookPath("sleep")
+    if err != nil { t.Skip("sleep not on PATH") }
+
+    sup := New(&registry.Registry{Version: "1.0"}, 0, 0)
+    check := func(slug, command string) error {
+        return sup.checkCommandAllowed(&registry.Server{Slug: slug, Entry: registry.Entry{Command: command}})
+    }
+    if err := check("demo", "/bin/bash"); err != nil { t.Fatalf("no allowlist: %v", err) }
+
+    sup.SetCommandAllowlist([]string{"uvx", sleepPath})
+    for _, c := range []struct{ slug, command string }{
+        {"demo", "uvx"},                       // bare name listed
+        {"demo", "sleep"},                     // resolves to a listed path
+        {"demo", "bin/demo"},                  // generated bin script
+        {"demo", filepath.Join(binDir, "demo")},
+        {"demo", "node"},                      // the bin script's target
+        {"spaced", "/opt/my tools/node"},
+    } {
+        if err := check(c.slug, c.command); err != nil { t.Errorf("%s %s: %v", c.slug, c.command, err) }
+    }
+    for _, c := range []struct{ slug, command string }{
+        {"demo", "/bin/bash"},
+        {"demo", "/tmp/evil/uvx"},             // basenames only match PATH lookups
+        {"demo", "bin/../../other/bin/other"}, // escapes the server directory
+        {"other", "node"},                     // no bin script names it
+    } {
+        if err := check(c.slug, c.command); !errors.Is(err, ErrCommandNotAllowed) { t.Errorf("%s %s allowed: %v", c.slug, c.command, err) }
+    }
+}
+
+func TestRefusedCommandFailsWithoutRetry(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "sleeper"), 0o755); err != nil { t.Fatal(err) }
+
+    sup := New(&registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Slug:   "sleeper",
+        Entry:  registry.Entry{Transport: "stdio", Command: "sleep", Args: []string{"30"}},
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+    }}}, 0, 0)
+    defer sup.Shutdown(time.Second)
+    sup.SetCommandAllowlist([]string{"node"})
+
+    if err := sup.Start("sleeper"); err != nil { t.Fatal(err) }
+    deadline := time.Now().Add(5 * time.Second)
+    for sup.GetProcessInfo("sleeper")["state"] != ProcessFailed.String() {
+        if time.Now().After(deadline) { t.Fatalf("not failed: %v", sup.GetProcessInfo("sleeper")) }
+        time.Sleep(20 * time.Millisecond)
+    }
+    info := sup.GetProcessInfo("sleeper")
+    if msg, _ := info["launchError"].(string); !strings.Contains(msg, "command not in allowlist") || info["restarts"] != 0 {
+        t.Fatalf("info = %v", info)
+    }
+}
+
+func TestHooksRefusedUnderAllowlist(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "hooked"), 0o755); err != nil { t.Fatal(err) }
+    sleepPath, err := exec.LookPath("sleep")
+    if err != nil { t.Skip("sleep not on PATH") }
+
+    sup := New(&registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Slug:   "hooked",
+        Entry:  registry.Entry{Transport: "stdio", Command: sleepPath, Args: []string{"30"}, PreStart: []string{"true"}},
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+    }}}, 0, 0)
+    defer sup.Shutdown(time.Second)
+    sup.SetCommandAllowlist([]string{sleepPath})
+
+    if err := sup.Start("hooked"); err != nil { t.Fatal(err) }
+    deadline := time.Now().Add(5 * time.Second)
+    for sup.GetProcessInfo("hooked")["state"] != ProcessFailed.String() {
+        if time.Now().After(deadline) { t.Fatalf("not failed: %v", sup.GetProcessInfo("hooked")) }
+        time.Sleep(20 * time.Millisecond)
+    }
+    if msg, _ := sup.GetProcessInfo("hooked")["launchError"].(string); !strings.Contains(msg, "pre-start hooks run through sh") {
+        t.Fatalf("launchError = %q", msg)
+    }
+    if err := sup.CommandAllowed("hooked", "/bin/sh"); !errors.Is(err, ErrCommandNotAllowed) {
+        t.Fatalf("probe command allowed: %v", err)
+    }
+}
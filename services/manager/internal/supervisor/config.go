@@ -0,0 +1,77 @@
+package supervisor
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "sort"
+    "sync"
+
+    "mcp/manager/internal/registry"
+)
+
+// launchHash digests the parts of sv that shape the launched process:
+// command, args, env, working directory, transport, limits and priority.
+// Health settings, names and client flags are left out, so editing them
+// never calls for a restart. Env is hashed as configured, so a secret
+// rotated in the vault behind a vault:// reference goes unnoticed.
+func launchHash(sv *registry.Server) string {
+    data, _ := json.Marshal(struct {
+        Command   string
+        Args      []string
+        Env       map[string]string
+        Inherit   string
+        Vars      []string
+        Dir       string
+        Transport string
+        Limits    *registry.Limits
+        Priority  *registry.Priority
+    }{sv.Entry.Command, sv.Entry.Args, sv.Entry.Env, sv.Entry.InheritEnv, sv.Entry.InheritVars, serverDir(sv.Slug), sv.Entry.Transport, sv.Limits, sv.Priority})
+    sum := sha256.Sum256(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// configChanged reports whether ps was launched with a different
+// configuration than sv now has. Callers hold ps.mu.
+func (ps *ProcState) configChanged(sv *registry.Server) bool {
+    return sv != nil && ps.launchHash != "" && ps.State == ProcessRunning && ps.launchHash != launchHash(sv)
+}
+
+// RestartChanged restarts every running process whose launch configuration
+// in the registry differs from the one it was started with, and returns
+// their slugs. The restarts run concurrently and are recorded with reason
+// "config".
+func (s *Supervisor) RestartChanged() []string {
+    s.mu.RLock()
+    var changed []string
+    for slug, ps := range s.procs {
+        sv := s.findServer(slug)
+        ps.mu.RLock()
+        if ps.configChanged(sv) {
+            changed = append(changed, slug)
+        }
+        ps.mu.RUnlock()
+    }
+    s.mu.RUnlock()
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    restarted := []string{}
+    for _, slug := range changed {
+        wg.Add(1)
+        go func(slug string) {
+            defer wg.Done()
+            if err := s.collapse(slug, func() error { return s.restart(slug) }); err != nil {
+                if ps := s.proc(slug); ps != nil { ps.logf("Restart after config change failed: %v", err) }
+                return
+            }
+            s.recordRestart(slug, RestartConfig, "launch configuration changed")
+            mu.Lock()
+            restarted = append(restarted, slug)
+            mu.Unlock()
+        }(slug)
+    }
+    wg.Wait()
+    sort.Strings(restarted)
+    return restarted
+}
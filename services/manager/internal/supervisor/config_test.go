@@ -0,0 +1,60 @@
+package supervisor
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+func TestRestartChanged(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "sleeper"), 0o755); err != nil { t.Fatal(err) }
+
+    server := registry.Server{
+        Name:   "Sleeper",
+        Slug:   "sleeper",
+        Entry:  registry.Entry{Transport: "stdio", Command: "sleep", Args: []string{"30"}},
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+    }
+    sup := New(&registry.Registry{Version: "1.0", Servers: []registry.Server{server}}, 0, 0)
+    defer sup.Shutdown(time.Second)
+
+    waitRunning := func() int {
+        t.Helper()
+        deadline := time.Now().Add(5 * time.Second)
+        for time.Now().Before(deadline) {
+            info := sup.GetProcessInfo("sleeper")
+            if info["state"] == ProcessRunning.String() { return info["pid"].(int) }
+            time.Sleep(20 * time.Millisecond)
+        }
+        t.Fatal("process never reached running")
+        return 0
+    }
+    if err := sup.Start("sleeper"); err != nil { t.Fatal(err) }
+    pid := waitRunning()
+
+    // Health settings and the name don't reach the process
+    cosmetic := server
+    cosmetic.Name = "Renamed"
+    cosmetic.Health.IntervalSec = 5
+    sup.UpdateRegistry(&registry.Registry{Version: "1.0", Servers: []registry.Server{cosmetic}})
+    if sup.GetProcessInfo("sleeper")["configChanged"] != false { t.Fatal("cosmetic edit reported as a config change") }
+    if got := sup.RestartChanged(); len(got) != 0 { t.Fatalf("cosmetic edit restarted %v", got) }
+
+    launch := cosmetic
+    launch.Entry.Env = map[string]string{"MODE": "debug"}
+    sup.UpdateRegistry(&registry.Registry{Version: "1.0", Servers: []registry.Server{launch}})
+    if sup.GetProcessInfo("sleeper")["configChanged"] != true { t.Fatal("env edit not reported as a config change") }
+    if got := sup.RestartChanged(); len(got) != 1 || got[0] != "sleeper" { t.Fatalf("restarted %v", got) }
+    if newPID := waitRunning(); newPID == pid { t.Fatal("process was not relaunched") }
+
+    info := sup.GetProcessInfo("sleeper")
+    if info["configChanged"] != false || info["restartsByReason"].(map[string]int)["config"] != 1 { t.Fatalf("after restart: %v", info) }
+}
@@ -0,0 +1,160 @@
+package supervisor
+
+import (
+    "errors"
+    "fmt"
+    "time"
+
+    "mcp/manager/internal/health"
+    "mcp/manager/internal/registry"
+)
+
+// defaultDependencyTimeout is how long Start waits for each dependency of a
+// server to become ready. The health monitor checks every 30s, so this
+// allows a few checks.
+const defaultDependencyTimeout = 2 * time.Minute
+
+// dependencyPollInterval is how often a dependency's readiness is looked at.
+const dependencyPollInterval = 250 * time.Millisecond
+
+// ErrDependencyNotReady is returned by Start when a server the started one
+// depends on didn't start or didn't become ready in time.
+var ErrDependencyNotReady = errors.New("dependency not ready")
+
+// HealthWatcher is a HealthSource that can be told to check a process. The
+// supervisor registers the dependencies it starts on a dependent's behalf,
+// whose readiness nothing else would check. health.HealthMonitor implements
+// it.
+type HealthWatcher interface {
+    AddProcess(name, transport, httpURL, logPath string)
+    SetProbe(name string, hc registry.Health)
+}
+
+// startDependencies starts what slug depends on, dependencies first, and
+// waits for each to be ready before the next. If one isn't, slug is marked
+// failed with the reason. A server already running is left alone.
+func (s *Supervisor) startDependencies(slug string) error {
+    s.mu.RLock()
+    reg := s.reg
+    sv := s.findServer(slug)
+    var state ProcessState
+    if ps := s.procs[slug]; ps != nil {
+        ps.mu.RLock()
+        state = ps.State
+        ps.mu.RUnlock()
+    }
+    s.mu.RUnlock()
+    if sv == nil || len(sv.DependsOn) == 0 {
+        return nil // start reports an unknown slug
+    }
+    if state == ProcessRunning || state == ProcessStarting || state == ProcessRestarting {
+        return nil
+    }
+
+    order, err := reg.DependencyOrder(slug)
+    if err != nil {
+        return err
+    }
+    for _, dep := range order {
+        if err := s.collapse(dep, func() error { return s.start(dep) }); err != nil {
+            return s.dependencyFailed(slug, fmt.Errorf("%w: %s failed to start: %v", ErrDependencyNotReady, dep, err))
+        }
+        s.watchDependency(dep)
+        if err := s.waitReady(dep); err != nil {
+            return s.dependencyFailed(slug, err)
+        }
+    }
+    return nil
+}
+
+// watchDependency has the health source check dep, unless it already does.
+func (s *Supervisor) watchDependency(dep string) {
+    s.healthMu.RLock()
+    watcher, ok := s.healthSrc.(HealthWatcher)
+    s.healthMu.RUnlock()
+    if !ok {
+        return
+    }
+    if _, known := s.processHealth(dep); known {
+        return
+    }
+    s.mu.RLock()
+    sv := s.findServer(dep)
+    ps := s.procs[dep]
+    s.mu.RUnlock()
+    if sv == nil || ps == nil {
+        return
+    }
+    ps.mu.RLock()
+    logPath, httpURL := ps.LogPath, ps.HTTPURL
+    ps.mu.RUnlock()
+    watcher.AddProcess(dep, sv.Entry.Transport, httpURL, logPath)
+    watcher.SetProbe(dep, sv.Health)
+}
+
+// waitReady waits up to s.depTimeout for dep to be running and, when there
+// is a health source, reported Ready by it.
+func (s *Supervisor) waitReady(dep string) error {
+    deadline := time.Now().Add(s.depTimeout)
+    for {
+        state, _ := s.GetProcessState(dep)
+        status := health.Ready
+        if ph, ok := s.processHealth(dep); ok {
+            status = ph.Status
+        } else if s.hasHealthSource() {
+            status = health.Down
+        }
+        if state == ProcessRunning && status == health.Ready {
+            return nil
+        }
+        if time.Now().After(deadline) {
+            return fmt.Errorf("%w: %s is %s (%s) after %s", ErrDependencyNotReady, dep, state, status, s.depTimeout)
+        }
+        select {
+        case <-time.After(dependencyPollInterval):
+        case <-s.shutdownCh:
+            return fmt.Errorf("supervisor is shutting down")
+        }
+    }
+}
+
+func (s *Supervisor) hasHealthSource() bool {
+    s.healthMu.RLock()
+    defer s.healthMu.RUnlock()
+    return s.healthSrc != nil
+}
+
+// dependencyFailed marks slug failed for cause, which it returns. A server
+// that was never started gets its process state here so the failure shows.
+func (s *Supervisor) dependencyFailed(slug string, cause error) error {
+    s.mu.Lock()
+    ps := s.procs[slug]
+    if ps == nil {
+        sv := s.findServer(slug)
+        if sv == nil {
+            s.mu.Unlock()
+            return cause
+        }
+        created, err := s.newProcState(slug, sv)
+        if err != nil {
+            s.mu.Unlock()
+            return cause
+        }
+        ps = created
+        s.procs[slug] = ps
+    }
+    s.mu.Unlock()
+
+    ps.mu.Lock()
+    if ps.State != ProcessStopped && ps.State != ProcessFailed {
+        ps.mu.Unlock()
+        return cause // started meanwhile by another caller
+    }
+    ps.State = ProcessFailed
+    ps.Status = health.Down
+    ps.LaunchError = cause.Error()
+    ps.mu.Unlock()
+    s.recordState(ps)
+    ps.logf("Not starting: %v", cause)
+    return cause
+}
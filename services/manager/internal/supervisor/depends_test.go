@@ -0,0 +1,90 @@
+package supervisor
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/health"
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+// watchedHealth reports a fixed status for the processes it was told to watch.
+type watchedHealth struct {
+    fixedHealth
+    mu      sync.Mutex
+    watched []string
+}
+
+func (w *watchedHealth) GetProcessHealth(name string) (*health.ProcessHealth, bool) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    for _, n := range w.watched {
+        if n == name { return w.fixedHealth.GetProcessHealth(name) }
+    }
+    return nil, false
+}
+
+func (w *watchedHealth) AddProcess(name, transport, httpURL, logPath string) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.watched = append(w.watched, name)
+}
+
+func (w *watchedHealth) SetProbe(string, registry.Health) {}
+
+func TestStartWaitsForDependencies(t *testing.T) {
+    var _ HealthWatcher = (*health.HealthMonitor)(nil)
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    reg := &registry.Registry{Version: "1.0"}
+    for _, sv := range []registry.Server{
+        {Name: "DB", Slug: "db"},
+        {Name: "Backend", Slug: "backend", DependsOn: []string{"db"}},
+        {Name: "Proxy", Slug: "proxy", DependsOn: []string{"backend"}},
+    } {
+        sv.Entry = registry.Entry{Transport: "stdio", Command: "sleep", Args: []string{"30"}}
+        sv.Health = registry.Health{IntervalSec: 60, TimeoutSec: 5}
+        if err := os.MkdirAll(filepath.Join(srvDir, sv.Slug), 0o755); err != nil { t.Fatal(err) }
+        reg.Servers = append(reg.Servers, sv)
+    }
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    sup.depTimeout = 500 * time.Millisecond
+    src := &watchedHealth{}
+    src.status.Store(health.Degraded)
+    sup.SetHealthSource(src)
+
+    // The database runs but never reports ready
+    err = sup.Start("proxy")
+    if !errors.Is(err, ErrDependencyNotReady) || !strings.Contains(err.Error(), "db is running (degraded)") {
+        t.Fatalf("Start(proxy) = %v", err)
+    }
+    for slug, want := range map[string]ProcessState{"db": ProcessRunning, "backend": ProcessStopped, "proxy": ProcessFailed} {
+        if state, _ := sup.GetProcessState(slug); state != want {
+            t.Errorf("%s is %s, want %s", slug, state, want)
+        }
+    }
+    if info := sup.GetProcessInfo("proxy"); !strings.Contains(info["launchError"].(string), "db is running") {
+        t.Errorf("proxy launchError = %v", info["launchError"])
+    }
+    if len(src.watched) != 1 || src.watched[0] != "db" {
+        t.Fatalf("watched %v, want [db]", src.watched)
+    }
+
+    // Once it is ready the whole chain comes up
+    src.status.Store(health.Ready)
+    if err := sup.Start("proxy"); err != nil { t.Fatal(err) }
+    for _, slug := range []string{"db", "backend", "proxy"} {
+        if state, _ := sup.GetProcessState(slug); state != ProcessRunning && state != ProcessStarting {
+            t.Errorf("%s is %s", slug, state)
+        }
+    }
+}
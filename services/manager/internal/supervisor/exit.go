@@ -0,0 +1,83 @@
+package supervisor
+
+import (
+    "errors"
+    "fmt"
+    "os/exec"
+    "syscall"
+)
+
+// signalNames covers the signals a server is commonly terminated by.
+var signalNames = map[syscall.Signal]string{
+    syscall.SIGABRT: "SIGABRT",
+    syscall.SIGBUS:  "SIGBUS",
+    syscall.SIGHUP:  "SIGHUP",
+    syscall.SIGILL:  "SIGILL",
+    syscall.SIGINT:  "SIGINT",
+    syscall.SIGKILL: "SIGKILL",
+    syscall.SIGPIPE: "SIGPIPE",
+    syscall.SIGQUIT: "SIGQUIT",
+    syscall.SIGSEGV: "SIGSEGV",
+    syscall.SIGTERM: "SIGTERM",
+}
+
+func signalName(sig syscall.Signal) string {
+    if name, ok := signalNames[sig]; ok {
+        return name
+    }
+    return fmt.Sprintf("signal %d", int(sig))
+}
+
+// exitDetails extracts the exit code and terminating signal from the error
+// returned by exec.Cmd.Wait. The code is -1 when the process was killed by a
+// signal or its status could not be read.
+func exitDetails(err error) (code int, signal string) {
+    if err == nil {
+        return 0, ""
+    }
+    var ee *exec.ExitError
+    if !errors.As(err, &ee) {
+        return -1, ""
+    }
+    if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+        return -1, signalName(ws.Signal())
+    }
+    return ee.ExitCode(), ""
+}
+
+// recordExit stores how the process exited. Callers hold ps.mu.
+func (ps *ProcState) recordExit(err error) {
+    ps.LastExitCode, ps.LastSignal = exitDetails(err)
+    ps.LastExitAt = ps.StoppedAt
+}
+
+// exitReason describes the last exit for logs and failure reports, or returns
+// "" if the process has not exited yet. Callers hold ps.mu.
+func (ps *ProcState) exitReason() string {
+    switch {
+    case ps.LastExitAt.IsZero():
+        return ""
+    case ps.LastOOMKill:
+        return "killed by the OOM killer for exceeding its memory limit"
+    case ps.LastSignal == "SIGKILL":
+        return "killed by SIGKILL (possibly out of memory)"
+    case ps.LastSignal != "":
+        return "killed by " + ps.LastSignal
+    default:
+        return fmt.Sprintf("exited with code %d", ps.LastExitCode)
+    }
+}
+
+// LastExit describes how the process for slug last exited, e.g. "exited with
+// code 1", or returns "" if it has not exited since the manager started.
+func (s *Supervisor) LastExit(slug string) string {
+    s.mu.RLock()
+    ps := s.procs[slug]
+    s.mu.RUnlock()
+    if ps == nil {
+        return ""
+    }
+    ps.mu.RLock()
+    defer ps.mu.RUnlock()
+    return ps.exitReason()
+}
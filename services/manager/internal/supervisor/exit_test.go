@@ -0,0 +1,62 @@
+package supervisor
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+func TestExitDetailsRecorded(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    
+    scripts := map[string]string{
+        "clean":   "exit 0",
+        "crashed": "exit 3",
+        "killed":  "kill -9 $$",
+    }
+    var servers []registry.Server
+    for slug, script := range scripts {
+        if err := os.MkdirAll(filepath.Join(srvDir, slug), 0o755); err != nil { t.Fatal(err) }
+        servers = append(servers, registry.Server{
+            Slug:   slug,
+            Entry:  registry.Entry{Transport: "stdio", Command: "sh", Args: []string{"-c", script}},
+            Health: registry.Health{IntervalSec: 60, TimeoutSec: 5, RestartPolicy: "never"},
+        })
+    }
+    sup := New(&registry.Registry{Version: "1.0", Servers: servers}, 0, 0)
+    defer sup.Shutdown(time.Second)
+    
+    for slug := range scripts {
+        if err := sup.Start(slug); err != nil { t.Fatal(err) }
+    }
+    
+    tests := []struct {
+        slug, signal, reason string
+        code                 int
+    }{
+        {"clean", "", "exited with code 0", 0},
+        {"crashed", "", "exited with code 3", 3},
+        {"killed", "SIGKILL", "killed by SIGKILL (possibly out of memory)", -1},
+    }
+    for _, tt := range tests {
+        deadline := time.Now().Add(5 * time.Second)
+        for sup.LastExit(tt.slug) == "" {
+            if time.Now().After(deadline) { t.Fatalf("%s never exited", tt.slug) }
+            time.Sleep(20 * time.Millisecond)
+        }
+        info := sup.GetProcessInfo(tt.slug)
+        if info["lastExitCode"] != tt.code || info["lastSignal"] != tt.signal || info["lastExitReason"] != tt.reason {
+            t.Errorf("%s: code=%v signal=%v reason=%v", tt.slug, info["lastExitCode"], info["lastSignal"], info["lastExitReason"])
+        }
+        if at, _ := info["lastExitAt"].(time.Time); at.IsZero() {
+            t.Errorf("%s: lastExitAt not set", tt.slug)
+        }
+    }
+}
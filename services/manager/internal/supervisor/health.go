@@ -0,0 +1,62 @@
+package supervisor
+
+import (
+    "time"
+
+    "mcp/manager/internal/health"
+)
+
+// HealthSource runs the health checks of supervised processes. The supervisor
+// does not probe processes itself; it reports the source's status for a
+// running process, so both always agree. health.HealthMonitor implements it.
+type HealthSource interface {
+    GetProcessHealth(name string) (*health.ProcessHealth, bool)
+}
+
+// SetHealthSource makes the supervisor report health from src. The source
+// should read process state back through ProbeInput.
+func (s *Supervisor) SetHealthSource(src HealthSource) {
+    s.healthMu.Lock()
+    defer s.healthMu.Unlock()
+
+    s.healthSrc = src
+}
+
+// ProbeInput reports whether slug is running and how often it restarted in
+// the last 10 minutes, for the health source to weigh into its checks. The
+// ping fields are left for the caller. It implements health.ProcessSource.
+func (s *Supervisor) ProbeInput(slug string) (health.ProbeInput, bool) {
+    s.mu.RLock()
+    ps := s.procs[slug]
+    s.mu.RUnlock()
+    if ps == nil {
+        return health.ProbeInput{}, false
+    }
+
+    ps.mu.Lock()
+    defer ps.mu.Unlock()
+    return health.ProbeInput{
+        ProcessRunning:  ps.State == ProcessRunning,
+        RestartsLast10m: countRestarts(ps, 10*time.Minute),
+    }, true
+}
+
+// processHealth returns the health source's view of a running process. It
+// calls back into the supervisor, so callers must not hold s.mu or ps.mu.
+func (s *Supervisor) processHealth(slug string) (*health.ProcessHealth, bool) {
+    s.healthMu.RLock()
+    src := s.healthSrc
+    s.healthMu.RUnlock()
+    if src == nil {
+        return nil, false
+    }
+    return src.GetProcessHealth(slug)
+}
+
+// lastResponseMs is the duration of the latest check in milliseconds.
+func lastResponseMs(ph *health.ProcessHealth) int {
+    if n := len(ph.CheckHistory); n > 0 {
+        return int(ph.CheckHistory[n-1].ResponseTime.Milliseconds())
+    }
+    return 0
+}
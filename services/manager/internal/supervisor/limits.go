@@ -0,0 +1,75 @@
+package supervisor
+
+import (
+    "fmt"
+    "os/exec"
+    "strings"
+
+    "mcp/manager/internal/registry"
+)
+
+// cgroup is the cgroup v2 group a server's process runs in so the kernel
+// enforces its memory and CPU limits. Only Linux creates one; the methods are
+// safe to call on a nil *cgroup.
+type cgroup struct {
+    path    string
+    oomBase int64 // oom_kill count when the process was placed in the group
+}
+
+// wrapLaunch makes cmd run the shell steps before exec'ing the server. The
+// steps run in a /bin/sh wrapper that exec's the original command, so the
+// server keeps the wrapper's PID and nothing applies to the manager itself.
+// A step that fails should report it on stderr, which is the server log, so
+// the server starts anyway.
+func wrapLaunch(cmd *exec.Cmd, steps ...string) {
+    if len(steps) == 0 || cmd.Err != nil {
+        return
+    }
+    script := append(append([]string(nil), steps...), `exec "$0" "$@"`)
+
+    cmd.Args = append([]string{"sh", "-c", strings.Join(script, "; "), cmd.Path}, cmd.Args[1:]...)
+    cmd.Path = "/bin/sh"
+}
+
+// rlimitSteps returns the wrapper steps setting the file descriptor and
+// address space limits in l. The address space limit is skipped when
+// memoryEnforced says a cgroup already caps memory, since it is much blunter
+// than a cgroup limit.
+func rlimitSteps(l *registry.Limits, memoryEnforced bool) []string {
+    if l == nil {
+        return nil
+    }
+    var steps []string
+    if l.MaxOpenFiles > 0 {
+        steps = append(steps, ulimitStep("-n", l.MaxOpenFiles, fmt.Sprintf("open files to %d", l.MaxOpenFiles)))
+    }
+    if l.MaxMemoryMB > 0 && !memoryEnforced {
+        steps = append(steps, ulimitStep("-v", l.MaxMemoryMB*1024, fmt.Sprintf("address space to %d MB", l.MaxMemoryMB)))
+    }
+    return steps
+}
+
+// cgroupFallback says what becomes of l's memory and CPU limits without a
+// cgroup, for the server log.
+func cgroupFallback(l *registry.Limits) string {
+    var parts []string
+    if l.MaxMemoryMB > 0 {
+        parts = append(parts, "memory capped as address space instead")
+    }
+    if l.MaxCPUPercent > 0 {
+        parts = append(parts, "CPU limit not enforced")
+    }
+    return strings.Join(parts, ", ")
+}
+
+func ulimitStep(flag string, value int, what string) string {
+    return fmt.Sprintf("ulimit %s %d 2>/dev/null || echo 'mcp-manager: could not limit %s' >&2", flag, value, what)
+}
+
+// recordOOMKill notes whether the kernel killed the process for exceeding its
+// cgroup memory limit, then removes the cgroup. Callers hold ps.mu.
+func (ps *ProcState) recordOOMKill() {
+    ps.LastOOMKill = ps.cgroup.oomKilled()
+    ps.cgroup.remove()
+    ps.cgroup = nil
+}
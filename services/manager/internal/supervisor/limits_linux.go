@@ -0,0 +1,273 @@
+//go:build linux
+
+package supervisor
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+
+    "mcp/manager/internal/registry"
+)
+
+const cgroupMount = "/sys/fs/cgroup"
+
+// newCgroup creates a cgroup for slug below the manager's own cgroup and
+// writes the memory and CPU limits from l into it. It needs a cgroup v2
+// hierarchy the manager may write to, e.g. a systemd unit with Delegate=yes,
+// and Linux 5.7 or later to start processes inside the group; otherwise it
+// returns an error and the caller falls back to rlimits.
+func newCgroup(slug string, l *registry.Limits) (*cgroup, error) {
+    if l == nil || (l.MaxMemoryMB <= 0 && l.MaxCPUPercent <= 0) {
+        return nil, nil
+    }
+
+    parent, err := serversCgroup()
+    if err != nil {
+        return nil, err
+    }
+
+    var want []string
+    if l.MaxMemoryMB > 0 {
+        want = append(want, "memory")
+    }
+    if l.MaxCPUPercent > 0 {
+        want = append(want, "cpu")
+    }
+    // Enabling a controller fails if the parent already has it or may not
+    // delegate it; the child's cgroup.controllers tells which case it was
+    for _, c := range want {
+        _ = os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+c), 0)
+    }
+
+    path := filepath.Join(parent, "mcp-"+slug)
+    // A group left behind by an earlier run is reused once it is empty
+    _ = os.Remove(path)
+    if err := os.Mkdir(path, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+        return nil, fmt.Errorf("cannot create cgroup: %w", err)
+    }
+    cg := &cgroup{path: path}
+
+    data, err := os.ReadFile(filepath.Join(path, "cgroup.controllers"))
+    if err != nil {
+        cg.remove()
+        return nil, fmt.Errorf("cannot read cgroup controllers: %w", err)
+    }
+    have := strings.Fields(string(data))
+    for _, c := range want {
+        if !contains(have, c) {
+            cg.remove()
+            return nil, fmt.Errorf("cgroup controller %q is not delegated to %s", c, parent)
+        }
+    }
+
+    if l.MaxMemoryMB > 0 {
+        limit := strconv.FormatInt(int64(l.MaxMemoryMB)<<20, 10)
+        if err := os.WriteFile(filepath.Join(path, "memory.max"), []byte(limit), 0); err != nil {
+            cg.remove()
+            return nil, fmt.Errorf("cannot set memory.max: %w", err)
+        }
+        // Without this the limit only pushes the server into swap
+        _ = os.WriteFile(filepath.Join(path, "memory.swap.max"), []byte("0"), 0)
+    }
+    if l.MaxCPUPercent > 0 {
+        const period = 100000
+        quota := fmt.Sprintf("%d %d", l.MaxCPUPercent*period/100, period)
+        if err := os.WriteFile(filepath.Join(path, "cpu.max"), []byte(quota), 0); err != nil {
+            cg.remove()
+            return nil, fmt.Errorf("cannot set cpu.max: %w", err)
+        }
+    }
+    if err := cg.probe(); err != nil {
+        cg.remove()
+        return nil, err
+    }
+    return cg, nil
+}
+
+var (
+    serversCgroupMu   sync.Mutex
+    serversCgroupPath string
+)
+
+// serversCgroup returns the cgroup the servers' groups are created in: the
+// one the manager started in. cgroup v2 only lets a group hand controllers to
+// its children while no process lives in it, so the first call moves the
+// manager, and servers it already started, into a "manager" leaf beside the
+// servers' groups. A group shared with other processes, e.g. a login
+// session's, is not the manager's to rearrange: it is refused and servers
+// fall back to rlimits. The root group is exempt and is used as is.
+func serversCgroup() (string, error) {
+    serversCgroupMu.Lock()
+    defer serversCgroupMu.Unlock()
+    if serversCgroupPath != "" {
+        return serversCgroupPath, nil
+    }
+
+    self, err := ownCgroup()
+    if err != nil {
+        return "", err
+    }
+    parent := filepath.Join(cgroupMount, self)
+    if self != "/" {
+        procs, err := os.ReadFile(filepath.Join(parent, "cgroup.procs"))
+        if err != nil {
+            return "", fmt.Errorf("cannot read cgroup processes: %w", err)
+        }
+        pids := strings.Fields(string(procs))
+        if others := foreignProcs(pids); len(others) > 0 {
+            return "", fmt.Errorf("cgroup %s is shared with processes the manager did not start (%s)", self, strings.Join(others, ", "))
+        }
+        if len(pids) > 0 {
+            leaf := filepath.Join(parent, "manager")
+            if err := os.Mkdir(leaf, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+                return "", fmt.Errorf("cannot create cgroup for the manager: %w", err)
+            }
+            for _, pid := range pids {
+                err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(pid), 0)
+                if err != nil && pid == strconv.Itoa(os.Getpid()) {
+                    return "", fmt.Errorf("cannot move the manager into %s: %w", leaf, err)
+                }
+            }
+        }
+    }
+    serversCgroupPath = parent
+    return parent, nil
+}
+
+// foreignProcs returns the pids that are neither the manager nor one of its
+// children.
+func foreignProcs(pids []string) []string {
+    self := os.Getpid()
+    var others []string
+    for _, pid := range pids {
+        if pid == strconv.Itoa(self) || parentPid(pid) == self {
+            continue
+        }
+        others = append(others, pid)
+    }
+    return others
+}
+
+// parentPid returns the parent of process pid, or 0 when it is gone.
+func parentPid(pid string) int {
+    data, err := os.ReadFile(filepath.Join("/proc", pid, "stat"))
+    if err != nil {
+        return 0
+    }
+    // The command name in parentheses may hold spaces; the state and the
+    // parent follow the last ')'
+    i := strings.LastIndexByte(string(data), ')')
+    if i < 0 {
+        return 0
+    }
+    fields := strings.Fields(string(data[i+1:]))
+    if len(fields) < 2 {
+        return 0
+    }
+    ppid, _ := strconv.Atoi(fields[1])
+    return ppid
+}
+
+// ownCgroup returns the manager's cgroup v2 path relative to the mount.
+func ownCgroup() (string, error) {
+    if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+        return "", errors.New("cgroup v2 is not mounted")
+    }
+    f, err := os.Open("/proc/self/cgroup")
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        if path, ok := strings.CutPrefix(scanner.Text(), "0::"); ok {
+            return path, nil
+        }
+    }
+    return "", errors.New("manager is not in a cgroup v2 hierarchy")
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+// probe starts a process straight into the group, as attach will have the
+// server started, to find out whether the kernel and the group's permissions
+// allow it.
+func (cg *cgroup) probe() error {
+    dir, err := os.Open(cg.path)
+    if err != nil {
+        return err
+    }
+    defer dir.Close()
+    p, err := os.StartProcess("/bin/sh", []string{"sh", "-c", ":"}, &os.ProcAttr{
+        Sys: &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: int(dir.Fd())},
+    })
+    if err != nil {
+        return fmt.Errorf("cannot start processes in a cgroup (needs Linux 5.7): %w", err)
+    }
+    _, _ = p.Wait()
+    return nil
+}
+
+// attach makes cmd start inside the group, so its limits hold from the first
+// instruction, and remembers the current OOM kill count. Call it after
+// newProcessGroup, which replaces cmd.SysProcAttr. The returned func releases
+// the group once cmd has started, or failed to.
+func (cg *cgroup) attach(cmd *exec.Cmd) (func(), error) {
+    if cg == nil {
+        return func() {}, nil
+    }
+    dir, err := os.Open(cg.path)
+    if err != nil {
+        return nil, err
+    }
+    if cmd.SysProcAttr == nil {
+        cmd.SysProcAttr = &syscall.SysProcAttr{}
+    }
+    cmd.SysProcAttr.UseCgroupFD = true
+    cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+    cg.oomBase = cg.oomKills()
+    return func() { dir.Close() }, nil
+}
+
+// oomKilled reports whether the OOM killer has fired in the group since add.
+func (cg *cgroup) oomKilled() bool {
+    return cg != nil && cg.oomKills() > cg.oomBase
+}
+
+func (cg *cgroup) oomKills() int64 {
+    data, err := os.ReadFile(filepath.Join(cg.path, "memory.events"))
+    if err != nil {
+        return 0
+    }
+    for _, line := range strings.Split(string(data), "\n") {
+        if v, ok := strings.CutPrefix(line, "oom_kill "); ok {
+            n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+            return n
+        }
+    }
+    return 0
+}
+
+// remove deletes the group. It fails harmlessly while a process the server
+// left behind is still in it.
+func (cg *cgroup) remove() {
+    if cg != nil {
+        _ = os.Remove(cg.path)
+    }
+}
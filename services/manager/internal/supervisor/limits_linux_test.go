@@ -0,0 +1,26 @@
+//go:build linux
+
+package supervisor
+
+import (
+    "os"
+    "os/exec"
+    "strconv"
+    "testing"
+)
+
+func TestForeignProcs(t *testing.T) {
+    child := exec.Command("sleep", "5")
+    if err := child.Start(); err != nil { t.Fatal(err) }
+    defer func() { _ = child.Process.Kill(); _ = child.Wait() }()
+
+    self, own := strconv.Itoa(os.Getpid()), strconv.Itoa(child.Process.Pid)
+    if others := foreignProcs([]string{self, own}); len(others) != 0 {
+        t.Fatalf("the manager and its child counted as foreign: %v", others)
+    }
+    // The manager's parent, e.g. a shell in the same session, is not its to move
+    parent := strconv.Itoa(os.Getppid())
+    if others := foreignProcs([]string{self, parent}); len(others) != 1 || others[0] != parent {
+        t.Fatalf("foreign = %v, want [%s]", others, parent)
+    }
+}
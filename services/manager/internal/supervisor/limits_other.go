@@ -0,0 +1,25 @@
+//go:build !linux
+
+package supervisor
+
+import (
+    "errors"
+    "os/exec"
+
+    "mcp/manager/internal/registry"
+)
+
+// newCgroup reports that memory and CPU limits can't be enforced: cgroups
+// only exist on Linux.
+func newCgroup(slug string, l *registry.Limits) (*cgroup, error) {
+    if l == nil || (l.MaxMemoryMB <= 0 && l.MaxCPUPercent <= 0) {
+        return nil, nil
+    }
+    return nil, errors.New("cgroups are not available on this platform")
+}
+
+func (cg *cgroup) attach(cmd *exec.Cmd) (func(), error) { return func() {}, nil }
+
+func (cg *cgroup) oomKilled() bool { return false }
+
+func (cg *cgroup) remove() {}
@@ -0,0 +1,42 @@
+package supervisor
+
+import (
+    "os/exec"
+    "strings"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/registry"
+)
+
+func TestRlimitSteps(t *testing.T) {
+    cmd := exec.Command("sh", "-c", `ulimit -n; ulimit -v; echo "$@"`, "sh", "a b", "c")
+    wrapLaunch(cmd, rlimitSteps(&registry.Limits{MaxOpenFiles: 64, MaxMemoryMB: 512}, false)...)
+    out, err := cmd.CombinedOutput()
+    if err != nil { t.Fatalf("%v: %s", err, out) }
+    // Arguments survive the wrapper unchanged
+    if got := strings.Fields(string(out)); len(got) != 5 || got[0] != "64" || got[1] != "524288" || got[2] != "a" || got[4] != "c" {
+        t.Fatalf("output %q", out)
+    }
+
+    // A cgroup memory limit replaces the address space limit
+    cmd = exec.Command("sh", "-c", "ulimit -v")
+    wrapLaunch(cmd, rlimitSteps(&registry.Limits{MaxMemoryMB: 512}, true)...)
+    if cmd.Path == "/bin/sh" && len(cmd.Args) > 3 { t.Fatalf("wrapped without rlimits to set: %v", cmd.Args) }
+
+    cmd = exec.Command("sh", "-c", "true")
+    wrapLaunch(cmd, rlimitSteps(nil, false)...)
+    if len(cmd.Args) != 3 { t.Fatalf("nil limits changed the command: %v", cmd.Args) }
+}
+
+func TestOOMKillExitReason(t *testing.T) {
+    ps := &ProcState{}
+    ps.StoppedAt = time.Now()
+    ps.recordExit(nil)
+    ps.LastOOMKill = true
+    if got := ps.exitReason(); !strings.Contains(got, "OOM killer") { t.Fatalf("exitReason() = %q", got) }
+
+    // Without a cgroup nothing is reported
+    ps.recordOOMKill()
+    if ps.LastOOMKill { t.Fatal("OOM kill reported without a cgroup") }
+}
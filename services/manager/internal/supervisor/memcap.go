@@ -0,0 +1,105 @@
+package supervisor
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "syscall"
+)
+
+// ErrGlobalMemoryCap is returned by Start while the processes together use
+// more memory than the global cap.
+var ErrGlobalMemoryCap = errors.New("global memory cap exceeded")
+
+// memoryCapDetail is logged, and recorded as the restart detail, when a
+// process is killed for going over the per-process cap.
+const memoryCapDetail = "memory cap exceeded"
+
+// SetMemoryCaps sets the RSS a process may use, unless its limits.maxMemoryMB
+// says otherwise, and the RSS all processes may use together before starts
+// are refused. 0 means no cap.
+func (s *Supervisor) SetMemoryCaps(perProcess, global int64) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.perCap, s.globCap = perProcess, global
+}
+
+// processCap returns the RSS cap of slug: its limits.maxMemoryMB, or the
+// per-process cap. Callers must hold s.mu.
+func (s *Supervisor) processCap(slug string) int64 {
+    if sv := s.findServer(slug); sv != nil && sv.Limits != nil && sv.Limits.MaxMemoryMB > 0 {
+        return int64(sv.Limits.MaxMemoryMB) << 20
+    }
+    return s.perCap
+}
+
+// enforceMemoryCap kills ps once its sampled RSS is over its cap. The run
+// loop sees an unexpected exit, so the restart policy decides whether it
+// comes back, and records RestartMemory as the reason.
+func (s *Supervisor) enforceMemoryCap(ps *ProcState) {
+    s.mu.RLock()
+    limit := s.processCap(ps.Slug)
+    s.mu.RUnlock()
+    if limit <= 0 {
+        return
+    }
+    ps.mu.Lock()
+    rss, process := ps.RSSBytes, ps.Process
+    if rss <= limit || process == nil || ps.memoryCapHit {
+        ps.mu.Unlock()
+        return
+    }
+    ps.memoryCapHit = true
+    ps.mu.Unlock()
+
+    ps.logf("%s: RSS %d MB is over the %d MB cap", memoryCapDetail, rss>>20, limit>>20)
+    log.Printf("Process %s: %s (RSS %d MB, cap %d MB)", ps.Slug, memoryCapDetail, rss>>20, limit>>20)
+    _ = signalGroup(process, syscall.SIGKILL)
+}
+
+// takeMemoryCapHit reports whether the exit just seen was enforceMemoryCap's
+// doing, and clears it for the next run. Callers hold ps.mu.
+func (ps *ProcState) takeMemoryCapHit() bool {
+    hit := ps.memoryCapHit
+    ps.memoryCapHit = false
+    return hit
+}
+
+// totalRSS sums the last sampled RSS of the processes that are running.
+// Callers must hold s.mu.
+func (s *Supervisor) totalRSS() int64 {
+    var total int64
+    for _, ps := range s.procs {
+        ps.mu.RLock()
+        if ps.Process != nil {
+            total += ps.RSSBytes
+        }
+        ps.mu.RUnlock()
+    }
+    return total
+}
+
+// checkGlobalCap refuses to start slug while the running processes are over
+// the global memory cap. A process already running is not refused, as Start
+// leaves it alone.
+func (s *Supervisor) checkGlobalCap(slug string) error {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    if s.globCap <= 0 {
+        return nil
+    }
+    if ps := s.procs[slug]; ps != nil {
+        ps.mu.RLock()
+        state := ps.State
+        ps.mu.RUnlock()
+        if state == ProcessRunning || state == ProcessStarting || state == ProcessRestarting {
+            return nil
+        }
+    }
+    total := s.totalRSS()
+    if total <= s.globCap {
+        return nil
+    }
+    log.Printf("Warning: %v: processes use %d MB of the %d MB cap, not starting %s", ErrGlobalMemoryCap, total>>20, s.globCap>>20, slug)
+    return fmt.Errorf("%w: processes use %d MB of the %d MB cap", ErrGlobalMemoryCap, total>>20, s.globCap>>20)
+}
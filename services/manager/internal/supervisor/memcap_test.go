@@ -0,0 +1,110 @@
+package supervisor
+
+import (
+    "errors"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+func TestMemoryCapRestartsProcess(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "hog"), 0o755); err != nil { t.Fatal(err) }
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Name:   "Hog",
+        Slug:   "hog",
+        Entry:  registry.Entry{Transport: "stdio", Command: "sleep", Args: []string{"30"}},
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5, RestartPolicy: "always"},
+    }}}
+    sup := New(reg, 64<<20, 0)
+    defer sup.Shutdown(time.Second)
+    if err := sup.Start("hog"); err != nil { t.Fatal(err) }
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        if state, _ := sup.GetProcessState("hog"); state == ProcessRunning { break }
+        if time.Now().After(deadline) { t.Fatal("hog never reached running") }
+        time.Sleep(20 * time.Millisecond)
+    }
+
+    // Under the cap nothing happens
+    ps := sup.proc("hog")
+    ps.mu.Lock()
+    ps.RSSBytes = 32 << 20
+    ps.mu.Unlock()
+    sup.enforceMemoryCap(ps)
+    ps.mu.Lock()
+    ps.RSSBytes = 100 << 20
+    ps.mu.Unlock()
+    sup.enforceMemoryCap(ps)
+
+    for sup.GetProcessInfo("hog")["restartsByReason"].(map[string]int)["memory"] == 0 {
+        if time.Now().After(deadline) { t.Fatalf("no memory restart: %v", sup.GetProcessInfo("hog")) }
+        time.Sleep(20 * time.Millisecond)
+    }
+    info := sup.GetProcessInfo("hog")
+    if counts := info["restartsByReason"].(map[string]int); counts["memory"] != 1 || counts["crash"] != 0 {
+        t.Fatalf("restartsByReason = %v", counts)
+    }
+    data, err := os.ReadFile(ps.LogPath)
+    if err != nil { t.Fatal(err) }
+    if !strings.Contains(string(data), "memory cap exceeded: RSS 100 MB is over the 64 MB cap") {
+        t.Fatalf("log = %q", data)
+    }
+}
+
+func TestGlobalMemoryCapRefusesStart(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    cmd := exec.Command("sleep", "30")
+    if err := cmd.Start(); err != nil { t.Fatal(err) }
+    defer func() { _ = cmd.Process.Kill(); _ = cmd.Wait() }()
+
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+        {Name: "Big", Slug: "big", Entry: registry.Entry{Transport: "stdio", Command: "sleep"}},
+        {Name: "Next", Slug: "next", Entry: registry.Entry{Transport: "stdio", Command: "sleep"}},
+    }}
+    sup := New(reg, 0, 1<<30)
+    defer sup.cancel() // ps isn't one Shutdown could stop
+    sup.procs["big"] = &ProcState{Slug: "big", Process: cmd.Process, PID: cmd.Process.Pid, State: ProcessRunning, RSSBytes: 1<<30 + 1<<20}
+
+    if err := sup.Start("next"); !errors.Is(err, ErrGlobalMemoryCap) {
+        t.Fatalf("Start = %v, want ErrGlobalMemoryCap", err)
+    }
+    if _, exists := sup.GetProcessState("next"); exists { t.Fatal("next was started") }
+    if err := sup.Start("big"); err != nil { t.Fatalf("Start of a running process = %v", err) }
+
+    stats := sup.Stats()
+    if stats["rssBytes"] != int64(1<<30+1<<20) || stats["globalMemoryCapBytes"] != int64(1<<30) || stats["overGlobalMemoryCap"] != true {
+        t.Fatalf("stats = %v", stats)
+    }
+}
+
+func TestMemoryCapFollowsServerLimit(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+        {Name: "Capped", Slug: "capped", Entry: registry.Entry{Transport: "stdio", Command: "sleep"}, Limits: &registry.Limits{MaxMemoryMB: 256}},
+        {Name: "Plain", Slug: "plain", Entry: registry.Entry{Transport: "stdio", Command: "sleep"}},
+    }}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+
+    // No cap unless configured, but a server's own limit always applies
+    if c, p := sup.processCap("capped"), sup.processCap("plain"); c != 256<<20 || p != 0 {
+        t.Fatalf("caps = %d, %d", c, p)
+    }
+    sup.SetMemoryCaps(64<<20, 1<<30)
+    if c, p := sup.processCap("capped"), sup.processCap("plain"); c != 256<<20 || p != 64<<20 {
+        t.Fatalf("caps = %d, %d", c, p)
+    }
+    if stats := sup.Stats(); stats["globalMemoryCapBytes"] != int64(1<<30) { t.Fatalf("stats = %v", stats) }
+}
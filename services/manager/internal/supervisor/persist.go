@@ -0,0 +1,423 @@
+package supervisor
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "syscall"
+    "time"
+
+    "mcp/manager/internal/health"
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+// stateFileName is the file under the config dir that records the processes
+// the supervisor runs, so the next daemon can adopt the ones still alive.
+const stateFileName = "state.json"
+
+// adoptPollInterval is how often an adopted process is checked for having
+// exited. The daemon isn't its parent, so it can't wait for it.
+const adoptPollInterval = 250 * time.Millisecond
+
+// startSlack is how far the start time ps reports for a PID may be from the
+// recorded one for it to count as the same process rather than a reused PID.
+const startSlack = 5 * time.Second
+
+// procRecord is a process as the state file keeps it.
+type procRecord struct {
+    Slug       string    `json:"slug"`
+    PID        int       `json:"pid"`
+    State      string    `json:"state"`
+    StartedAt  time.Time `json:"startedAt"`
+    Restarts   int       `json:"restarts"`
+    LaunchHash string    `json:"launchHash,omitempty"`
+}
+
+// processRecords holds what the state file records. It is kept apart from
+// the process table so saving never waits on s.mu, which start holds while
+// it waits for a run loop to finish.
+type processRecords struct {
+    mu      sync.Mutex
+    records map[string]procRecord
+    adopted []string
+}
+
+// recordState saves the current state of ps to the state file. Callers must
+// not hold ps.mu.
+func (s *Supervisor) recordState(ps *ProcState) {
+    ps.mu.RLock()
+    rec := ps.record()
+    ps.mu.RUnlock()
+
+    s.state.mu.Lock()
+    defer s.state.mu.Unlock()
+    if s.state.records == nil {
+        s.state.records = make(map[string]procRecord)
+    }
+    s.state.records[rec.Slug] = rec
+    if err := s.saveRecords(); err != nil {
+        log.Printf("Failed to save process state: %v", err)
+    }
+}
+
+// record is ps as the state file keeps it. Callers must hold ps.mu.
+func (ps *ProcState) record() procRecord {
+    return procRecord{
+        Slug:       ps.Slug,
+        PID:        ps.PID,
+        State:      ps.State.String(),
+        StartedAt:  ps.StartedAt,
+        Restarts:   ps.Restarts,
+        LaunchHash: ps.launchHash,
+    }
+}
+
+// saveRecords writes the records to the state file, sorted by slug. Callers
+// must hold s.state.mu.
+func (s *Supervisor) saveRecords() error {
+    root, err := paths.HomeMCP()
+    if err != nil {
+        return err
+    }
+    records := make([]procRecord, 0, len(s.state.records))
+    for _, rec := range s.state.records {
+        records = append(records, rec)
+    }
+    sort.Slice(records, func(i, j int) bool { return records[i].Slug < records[j].Slug })
+
+    data, err := json.MarshalIndent(records, "", "  ")
+    if err != nil {
+        return err
+    }
+    path := filepath.Join(root, stateFileName)
+    tmp := path + ".tmp"
+    if err := paths.WriteFile(tmp, data, paths.FileMode()); err != nil {
+        return err
+    }
+    return os.Rename(tmp, path)
+}
+
+// loadRecords reads the state file. A missing file has no records.
+func loadRecords() ([]procRecord, error) {
+    root, err := paths.Root()
+    if err != nil {
+        return nil, err
+    }
+    path := filepath.Join(root, stateFileName)
+    data, err := os.ReadFile(path)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    var records []procRecord
+    if err := json.Unmarshal(data, &records); err != nil {
+        return nil, fmt.Errorf("invalid %s: %w", path, err)
+    }
+    return records, nil
+}
+
+// adoptRecorded takes over the processes a previous daemon left running, as
+// the state file records them, without restarting them. Only HTTP servers
+// are adopted: a stdio server spoke to the old daemon over pipes that died
+// with it. Records of processes that are gone are dropped.
+func (s *Supervisor) adoptRecorded() {
+    records, err := loadRecords()
+    if err != nil {
+        log.Printf("Ignoring saved process state: %v", err)
+        return
+    }
+    if len(records) == 0 {
+        return
+    }
+
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.state.mu.Lock()
+    defer s.state.mu.Unlock()
+
+    s.state.records = make(map[string]procRecord)
+    for _, rec := range records {
+        if rec.PID <= 0 {
+            continue
+        }
+        sv := s.findServer(rec.Slug)
+        if sv == nil || sv.Entry.Transport != "http" {
+            continue
+        }
+        process, err := findRecordedProcess(rec)
+        if err != nil {
+            log.Printf("Not adopting %s (pid %d): %v", rec.Slug, rec.PID, err)
+            continue
+        }
+        ps, err := s.adoptProcess(sv, rec, process)
+        if err != nil {
+            log.Printf("Failed to adopt %s (pid %d): %v", rec.Slug, rec.PID, err)
+            continue
+        }
+        s.state.records[rec.Slug] = ps.record()
+        s.state.adopted = append(s.state.adopted, rec.Slug)
+        log.Printf("Adopted running process %s (pid %d)", rec.Slug, rec.PID)
+    }
+    if err := s.saveRecords(); err != nil {
+        log.Printf("Failed to save process state: %v", err)
+    }
+}
+
+// findRecordedProcess returns the process rec names if it is still running
+// and started when rec says it did.
+func findRecordedProcess(rec procRecord) (*os.Process, error) {
+    process, err := os.FindProcess(rec.PID)
+    if err != nil {
+        return nil, err
+    }
+    if err := process.Signal(syscall.Signal(0)); err != nil {
+        return nil, fmt.Errorf("not running: %w", err)
+    }
+    started, err := processStartedAt(rec.PID)
+    if err != nil {
+        return nil, fmt.Errorf("cannot tell when it started: %w", err)
+    }
+    if d := started.Sub(rec.StartedAt); d > startSlack || d < -startSlack {
+        return nil, fmt.Errorf("pid was reused: started %s, recorded %s",
+            started.Format(time.RFC3339), rec.StartedAt.Format(time.RFC3339))
+    }
+    return process, nil
+}
+
+// processStartedAt reports when pid started, using the start time in
+// /proc/<pid>/stat where there is one and the elapsed time ps gives
+// elsewhere.
+func processStartedAt(pid int) (time.Time, error) {
+    if started, err := procStartedAt(pid); err == nil {
+        return started, nil
+    }
+    out, err := exec.Command("ps", "-o", "etime=", "-p", strconv.Itoa(pid)).Output()
+    if err != nil {
+        return time.Time{}, err
+    }
+    elapsed, err := parseElapsed(strings.TrimSpace(string(out)))
+    if err != nil {
+        return time.Time{}, err
+    }
+    return time.Now().Add(-elapsed), nil
+}
+
+// clockTicks is the USER_HZ that /proc times are counted in. It is 100 on
+// every Linux architecture Go supports.
+const clockTicks = 100
+
+// procStartedAt reads pid's start time from /proc: field 22 of its stat is
+// the start in clock ticks after boot, and /proc/uptime says how long ago
+// boot was.
+func procStartedAt(pid int) (time.Time, error) {
+    stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+    if err != nil {
+        return time.Time{}, err
+    }
+    // The command name in field 2 may contain spaces; fields resume after its ')'
+    i := strings.LastIndexByte(string(stat), ')')
+    if i < 0 {
+        return time.Time{}, fmt.Errorf("unexpected /proc/%d/stat", pid)
+    }
+    fields := strings.Fields(string(stat[i+1:]))
+    if len(fields) < 20 {
+        return time.Time{}, fmt.Errorf("unexpected /proc/%d/stat", pid)
+    }
+    ticks, err := strconv.ParseInt(fields[19], 10, 64)
+    if err != nil {
+        return time.Time{}, err
+    }
+    uptime, err := os.ReadFile("/proc/uptime")
+    if err != nil {
+        return time.Time{}, err
+    }
+    up, err := strconv.ParseFloat(strings.Fields(string(uptime) + " ")[0], 64)
+    if err != nil {
+        return time.Time{}, err
+    }
+    boot := time.Now().Add(-time.Duration(up * float64(time.Second)))
+    return boot.Add(time.Duration(ticks) * time.Second / clockTicks), nil
+}
+
+// parseElapsed parses the [[dd-]hh:]mm:ss elapsed time printed by ps.
+func parseElapsed(s string) (time.Duration, error) {
+    days := 0
+    if d, rest, ok := strings.Cut(s, "-"); ok {
+        n, err := strconv.Atoi(d)
+        if err != nil {
+            return 0, fmt.Errorf("unexpected elapsed time %q", s)
+        }
+        days, s = n, rest
+    }
+    parts := strings.Split(s, ":")
+    if len(parts) < 2 || len(parts) > 3 {
+        return 0, fmt.Errorf("unexpected elapsed time %q", s)
+    }
+    secs := 0
+    for _, part := range parts {
+        n, err := strconv.Atoi(part)
+        if err != nil {
+            return 0, fmt.Errorf("unexpected elapsed time %q", s)
+        }
+        secs = secs*60 + n
+    }
+    return time.Duration(days*86400+secs) * time.Second, nil
+}
+
+// adoptProcess adds a running process for sv to the process table and starts
+// watching it. Callers must hold s.mu.
+func (s *Supervisor) adoptProcess(sv *registry.Server, rec procRecord, process *os.Process) (*ProcState, error) {
+    ps, err := s.newProcState(sv.Slug, sv)
+    if err != nil {
+        return nil, err
+    }
+    ps.State = ProcessRunning
+    ps.Status = health.Down
+    ps.Process = process
+    ps.PID = rec.PID
+    ps.StartedAt = rec.StartedAt
+    ps.Restarts = rec.Restarts
+    ps.launchHash = rec.LaunchHash
+    ps.runDone = make(chan struct{})
+    s.procs[sv.Slug] = ps
+
+    ps.logf("Adopted running process %d after a daemon restart", rec.PID)
+    s.wg.Add(1)
+    go s.runAdopted(ps, sv, process, ps.runDone)
+    return ps, nil
+}
+
+// Adopted returns the slugs of the processes taken over from a previous
+// daemon when the supervisor was created.
+func (s *Supervisor) Adopted() []string {
+    s.state.mu.Lock()
+    defer s.state.mu.Unlock()
+    return append([]string(nil), s.state.adopted...)
+}
+
+// runAdopted watches an adopted process until it exits. Its exit status
+// can't be known, so an unasked-for exit counts as a failure and the restart
+// policy then takes over as it would in runProcess.
+func (s *Supervisor) runAdopted(ps *ProcState, sv *registry.Server, process *os.Process, done chan struct{}) {
+    finish := func() {
+        ps.mu.Lock()
+        if ps.LogFile != nil {
+            ps.LogFile.Close()
+            ps.LogFile = nil
+        }
+        ps.mu.Unlock()
+        close(done)
+        s.wg.Done()
+    }
+
+    s.startMonitoring(ps)
+    ticker := time.NewTicker(adoptPollInterval)
+    for process.Signal(syscall.Signal(0)) == nil {
+        select {
+        case <-ticker.C:
+        case <-ps.ctx.Done():
+            ticker.Stop()
+            finish()
+            return
+        }
+    }
+    ticker.Stop()
+    s.stopMonitoring(ps)
+
+    ps.mu.Lock()
+    ps.StoppedAt = time.Now()
+    ps.Process = nil
+    ps.PID = 0
+    ps.Status = health.Down
+    stopping := atomic.LoadInt32(&ps.Stopping) == 1
+    restart := false
+    switch {
+    case stopping:
+        ps.State = ProcessStopped
+    case ps.RestartPolicy.Policy == "never":
+        ps.State = ProcessStopped
+    default:
+        ps.State = ProcessFailed
+        ps.Restarts++
+        ps.RestartsAt = append(ps.RestartsAt, time.Now())
+        if ps.takeMemoryCapHit() {
+            ps.noteRestart(RestartMemory, memoryCapDetail)
+        } else {
+            ps.noteRestart(RestartCrash, "adopted process exited")
+        }
+        restart = true
+    }
+    ps.mu.Unlock()
+    s.recordState(ps)
+    ps.logf("Adopted process %d exited", process.Pid)
+
+    if stopping {
+        select {
+        case <-ps.stoppedCh:
+        default:
+            close(ps.stoppedCh)
+        }
+    }
+    if restart {
+        // runProcess takes over the WaitGroup slot and closes done
+        s.runProcess(ps, sv, done)
+        return
+    }
+    finish()
+}
+
+// Detach lets go of the running HTTP servers without stopping them, for a
+// daemon upgrade: the state file keeps them for the next daemon to adopt.
+// Stdio servers can't outlive their pipes to this daemon and are stopped,
+// each given up to timeout. No process can be started afterwards.
+func (s *Supervisor) Detach(timeout time.Duration) error {
+    s.mu.Lock()
+    select {
+    case <-s.shutdownCh:
+        s.mu.Unlock()
+        return nil
+    default:
+        close(s.shutdownCh)
+    }
+    var stdio []string
+    procs := make([]*ProcState, 0, len(s.procs))
+    for slug, ps := range s.procs {
+        procs = append(procs, ps)
+        if ps.stdio != nil {
+            stdio = append(stdio, slug)
+        }
+    }
+    s.mu.Unlock()
+
+    var wg sync.WaitGroup
+    for _, slug := range stdio {
+        wg.Add(1)
+        go func(slug string) {
+            defer wg.Done()
+            _ = s.stopProcess(slug, timeout)
+        }(slug)
+    }
+    wg.Wait()
+
+    s.state.mu.Lock()
+    defer s.state.mu.Unlock()
+    s.state.records = make(map[string]procRecord, len(procs))
+    for _, ps := range procs {
+        ps.mu.RLock()
+        s.state.records[ps.Slug] = ps.record()
+        ps.mu.RUnlock()
+    }
+    return s.saveRecords()
+}
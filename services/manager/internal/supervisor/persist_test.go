@@ -0,0 +1,154 @@
+package supervisor
+
+import (
+    "encoding/json"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "syscall"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+// writeState writes records as the state file of the current config dir.
+func writeState(t *testing.T, records ...procRecord) {
+    t.Helper()
+    root, err := paths.HomeMCP()
+    if err != nil { t.Fatal(err) }
+    data, err := json.Marshal(records)
+    if err != nil { t.Fatal(err) }
+    if err := os.WriteFile(filepath.Join(root, stateFileName), data, 0o600); err != nil { t.Fatal(err) }
+}
+
+func readState(t *testing.T) map[string]procRecord {
+    t.Helper()
+    records, err := loadRecords()
+    if err != nil { t.Fatal(err) }
+    out := make(map[string]procRecord)
+    for _, rec := range records {
+        out[rec.Slug] = rec
+    }
+    return out
+}
+
+func TestAdoptRecordedProcess(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+
+    // Stands in for a server the previous daemon started
+    cmd := exec.Command("sleep", "30")
+    if err := cmd.Start(); err != nil { t.Fatal(err) }
+    exited := make(chan struct{})
+    go func() { _ = cmd.Wait(); close(exited) }()
+    defer cmd.Process.Kill()
+    started := time.Now()
+
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+        {Name: "Web", Slug: "web", Entry: registry.Entry{Transport: "http", Command: "sleep", Args: []string{"30"}}},
+        {Name: "Reused", Slug: "reused", Entry: registry.Entry{Transport: "http", Command: "sleep"}},
+        {Name: "Pipe", Slug: "pipe", Entry: registry.Entry{Transport: "stdio", Command: "sleep"}},
+    }}
+    writeState(t,
+        procRecord{Slug: "web", PID: cmd.Process.Pid, State: "running", StartedAt: started, Restarts: 2},
+        procRecord{Slug: "reused", PID: cmd.Process.Pid, State: "running", StartedAt: started.Add(-time.Hour)},
+        procRecord{Slug: "pipe", PID: cmd.Process.Pid, State: "running", StartedAt: started},
+        procRecord{Slug: "gone", PID: cmd.Process.Pid, State: "running", StartedAt: started},
+    )
+
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    if got := sup.Adopted(); len(got) != 1 || got[0] != "web" {
+        t.Fatalf("adopted %v, want [web]", got)
+    }
+    if state, _ := sup.GetProcessState("web"); state != ProcessRunning {
+        t.Fatalf("web is %s", state)
+    }
+    for _, slug := range []string{"reused", "pipe"} {
+        if _, exists := sup.GetProcessState(slug); exists { t.Errorf("%s was adopted", slug) }
+    }
+    if saved := readState(t); len(saved) != 1 || saved["web"].PID != cmd.Process.Pid || saved["web"].Restarts != 2 {
+        t.Fatalf("state file = %+v", saved)
+    }
+
+    // Start leaves the adopted process alone, Stop ends it
+    if err := sup.Start("web"); err != nil { t.Fatal(err) }
+    sup.mu.RLock()
+    ps := sup.procs["web"]
+    sup.mu.RUnlock()
+    if ps.PID != cmd.Process.Pid { t.Fatalf("pid %d, want %d", ps.PID, cmd.Process.Pid) }
+    if err := sup.Stop("web", 2*time.Second); err != nil { t.Fatal(err) }
+    select {
+    case <-exited:
+    case <-time.After(5 * time.Second):
+        t.Fatal("adopted process was not stopped")
+    }
+    if state, _ := sup.GetProcessState("web"); state != ProcessStopped {
+        t.Fatalf("web is %s after stop", state)
+    }
+    if rec := readState(t)["web"]; rec.PID != 0 || rec.State != "stopped" {
+        t.Fatalf("state file has %+v after stop", rec)
+    }
+}
+
+func TestDetachLeavesHTTPServersRunning(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    reg := &registry.Registry{Version: "1.0"}
+    for _, sv := range []registry.Server{
+        {Name: "Web", Slug: "web", Entry: registry.Entry{Transport: "http", Command: "sleep", Args: []string{"30"}}},
+        {Name: "Pipe", Slug: "pipe", Entry: registry.Entry{Transport: "stdio", Command: "sleep", Args: []string{"30"}}},
+    } {
+        if err := os.MkdirAll(filepath.Join(srvDir, sv.Slug), 0o755); err != nil { t.Fatal(err) }
+        reg.Servers = append(reg.Servers, sv)
+    }
+
+    sup := New(reg, 0, 0)
+    defer func() { sup.cancel(); sup.wg.Wait() }() // Shutdown does nothing once detached
+    for _, sv := range reg.Servers {
+        if err := sup.Start(sv.Slug); err != nil { t.Fatal(err) }
+    }
+    deadline := time.Now().Add(5 * time.Second)
+    for _, sv := range reg.Servers {
+        for {
+            if state, _ := sup.GetProcessState(sv.Slug); state == ProcessRunning { break }
+            if time.Now().After(deadline) { t.Fatalf("%s never reached running", sv.Slug) }
+            time.Sleep(20 * time.Millisecond)
+        }
+    }
+
+    if err := sup.Detach(time.Second); err != nil { t.Fatal(err) }
+    saved := readState(t)
+    if rec := saved["web"]; rec.PID == 0 || rec.State != "running" || syscall.Kill(rec.PID, 0) != nil {
+        t.Fatalf("web was not left running: %+v", rec)
+    }
+    if rec := saved["pipe"]; rec.PID != 0 || rec.State != "stopped" {
+        t.Fatalf("pipe was not stopped: %+v", rec)
+    }
+    if err := sup.Start("web"); err == nil { t.Fatal("Start succeeded after Detach") }
+}
+
+func TestParseElapsed(t *testing.T) {
+    tests := []struct {
+        in   string
+        want time.Duration
+    }{
+        {"00:07", 7 * time.Second},
+        {"12:34", 12*time.Minute + 34*time.Second},
+        {"01:02:03", time.Hour + 2*time.Minute + 3*time.Second},
+        {"2-03:04:05", 51*time.Hour + 4*time.Minute + 5*time.Second},
+    }
+    for _, tt := range tests {
+        if got, err := parseElapsed(tt.in); err != nil || got != tt.want {
+            t.Errorf("parseElapsed(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+        }
+    }
+    for _, bad := range []string{"", "7", "a:b", "x-01:02"} {
+        if _, err := parseElapsed(bad); err == nil { t.Errorf("parseElapsed(%q) succeeded", bad) }
+    }
+}
@@ -0,0 +1,35 @@
+package supervisor
+
+import (
+    "fmt"
+
+    "mcp/manager/internal/registry"
+)
+
+// ioClasses maps priority.ioClass to ionice's class numbers.
+var ioClasses = map[string]int{"realtime": 1, "best-effort": 2, "idle": 3}
+
+// prioritySteps returns the wrapper steps giving the server p's niceness and
+// I/O priority. They renice the wrapper shell, which the server then
+// replaces, so every thread the server starts inherits the priority. When
+// the kernel refuses, e.g. a niceness below the manager's without
+// CAP_SYS_NICE, the server log says so and the server keeps the manager's.
+func prioritySteps(p *registry.Priority) []string {
+    if p == nil {
+        return nil
+    }
+    steps := []string{fmt.Sprintf("renice %d -p $$ >/dev/null 2>&1 || echo 'mcp-manager: could not set niceness to %d' >&2", p.Nice, p.Nice)}
+    if p.IOClass == "" {
+        return steps
+    }
+    if !ioPrioritySupported {
+        return append(steps, "echo 'mcp-manager: I/O priority is not supported on this platform' >&2")
+    }
+    flags := fmt.Sprintf("-c %d", ioClasses[p.IOClass])
+    what := p.IOClass
+    if p.IOLevel != nil {
+        flags += fmt.Sprintf(" -n %d", *p.IOLevel)
+        what += fmt.Sprintf(" level %d", *p.IOLevel)
+    }
+    return append(steps, fmt.Sprintf("ionice %s -p $$ >/dev/null 2>&1 || echo 'mcp-manager: could not set I/O priority to %s' >&2", flags, what))
+}
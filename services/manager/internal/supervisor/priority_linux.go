@@ -0,0 +1,33 @@
+//go:build linux
+
+package supervisor
+
+import (
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+)
+
+// ioPrioritySupported says whether ionice can set a server's I/O priority.
+const ioPrioritySupported = true
+
+// processNice returns the niceness pid runs at, from /proc/<pid>/stat.
+func processNice(pid int) (int, bool) {
+    data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+    if err != nil {
+        return 0, false
+    }
+    // The command name in parentheses may contain spaces; nice is the 17th
+    // field after it
+    i := strings.LastIndexByte(string(data), ')')
+    if i < 0 {
+        return 0, false
+    }
+    fields := strings.Fields(string(data[i+1:]))
+    if len(fields) < 17 {
+        return 0, false
+    }
+    n, err := strconv.Atoi(fields[16])
+    return n, err == nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package supervisor
+
+// ioPrioritySupported says whether ionice can set a server's I/O priority;
+// I/O scheduling classes only exist on Linux.
+const ioPrioritySupported = false
+
+// processNice reads /proc on Linux; elsewhere the niceness is not reported.
+func processNice(pid int) (int, bool) { return 0, false }
@@ -0,0 +1,46 @@
+package supervisor
+
+import (
+    "os/exec"
+    "runtime"
+    "strings"
+    "testing"
+
+    "mcp/manager/internal/registry"
+)
+
+func TestPrioritySteps(t *testing.T) {
+    if _, err := exec.LookPath("renice"); err != nil { t.Skip("renice is not installed") }
+
+    // Raising niceness is always allowed
+    cmd := exec.Command("sh", "-c", `nice; echo "$@"`, "sh", "a b")
+    wrapLaunch(cmd, prioritySteps(&registry.Priority{Nice: 19})...)
+    out, err := cmd.CombinedOutput()
+    if err != nil { t.Fatalf("%v: %s", err, out) }
+    if got := strings.Fields(string(out)); len(got) != 3 || got[0] != "19" || got[1] != "a" { t.Fatalf("output %q", out) }
+
+    // Lowering it without privilege is noted and the server starts anyway
+    cmd = exec.Command("sh", "-c", "nice")
+    wrapLaunch(cmd, prioritySteps(&registry.Priority{Nice: -20})...)
+    out, err = cmd.CombinedOutput()
+    if err != nil { t.Fatalf("%v: %s", err, out) }
+    if s := string(out); !strings.Contains(s, "-20") && !strings.Contains(s, "could not set niceness to -20") { t.Fatalf("output %q", out) }
+
+    cmd = exec.Command("sh", "-c", "true")
+    wrapLaunch(cmd, prioritySteps(nil)...)
+    if len(cmd.Args) != 3 { t.Fatalf("nil priority changed the command: %v", cmd.Args) }
+}
+
+func TestProcessNice(t *testing.T) {
+    if runtime.GOOS != "linux" { t.Skip("niceness is only read on Linux") }
+    if _, err := exec.LookPath("renice"); err != nil { t.Skip("renice is not installed") }
+
+    cmd := exec.Command("sh", "-c", "echo ready; exec sleep 5")
+    wrapLaunch(cmd, prioritySteps(&registry.Priority{Nice: 12})...)
+    stdout, _ := cmd.StdoutPipe()
+    if err := cmd.Start(); err != nil { t.Fatal(err) }
+    defer func() { cmd.Process.Kill(); cmd.Wait() }()
+    buf := make([]byte, 6)
+    stdout.Read(buf)
+    if n, ok := processNice(cmd.Process.Pid); !ok || n != 12 { t.Fatalf("processNice = %d, %v", n, ok) }
+}
@@ -0,0 +1,25 @@
+package supervisor
+
+import (
+    "os"
+    "os/exec"
+    "syscall"
+)
+
+// newProcessGroup makes cmd's process lead a process group of its own, so
+// that signalGroup reaches whatever it forks as well.
+func newProcessGroup(cmd *exec.Cmd) {
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    // A cancelled context kills the whole group too, not just the leader
+    cmd.Cancel = func() error { return signalGroup(cmd.Process, syscall.SIGKILL) }
+}
+
+// signalGroup sends sig to the process group process leads, so children a
+// launcher forked don't outlive it. A process that leads no group, like one
+// adopted from a daemon that didn't create groups, gets sig by itself.
+func signalGroup(process *os.Process, sig syscall.Signal) error {
+    if pgid, err := syscall.Getpgid(process.Pid); err == nil && pgid == process.Pid {
+        return syscall.Kill(-process.Pid, sig)
+    }
+    return process.Signal(sig)
+}
@@ -0,0 +1,141 @@
+package supervisor
+
+import (
+    "fmt"
+    "time"
+
+    "mcp/manager/internal/health"
+)
+
+// RestartReason says what made the supervisor restart a process.
+type RestartReason string
+
+const (
+    // RestartCrash is the restart policy relaunching a process that exited or failed to start.
+    RestartCrash RestartReason = "crash"
+    // RestartHealth is the health monitor giving up on a running process.
+    RestartHealth RestartReason = "health"
+    // RestartManual is a restart asked for through the API or control interface.
+    RestartManual RestartReason = "manual"
+    // RestartConfig is a running process relaunched because its launch
+    // configuration changed, see RestartChanged.
+    RestartConfig RestartReason = "config"
+    // RestartMemory is a process killed for going over the per-process
+    // memory cap, see enforceMemoryCap.
+    RestartMemory RestartReason = "memory"
+)
+
+// DefaultHealthRestartGrace is how long a health-triggered restart waits
+// for the process to recover on its own before bouncing it.
+const DefaultHealthRestartGrace = 10 * time.Second
+
+// SetHealthRestartGrace sets the cool-down of RestartForHealth. Zero or
+// less restores DefaultHealthRestartGrace.
+func (s *Supervisor) SetHealthRestartGrace(d time.Duration) {
+    if d <= 0 { d = DefaultHealthRestartGrace }
+    s.graceMu.Lock()
+    defer s.graceMu.Unlock()
+    s.healthGrace = d
+}
+
+func (s *Supervisor) healthRestartGrace() time.Duration {
+    s.graceMu.RLock()
+    defer s.graceMu.RUnlock()
+    if s.healthGrace <= 0 { return DefaultHealthRestartGrace }
+    return s.healthGrace
+}
+
+// RestartForHealth restarts slug because its health checks failed, recording
+// detail as the reason. It first waits out the grace period and gives up if
+// the process recovered or was stopped meanwhile, so a brief network blip
+// does not bounce it. While one call is waiting, others for slug are dropped.
+// It reports whether the process was restarted.
+func (s *Supervisor) RestartForHealth(slug, detail string) (bool, error) {
+    ps := s.proc(slug)
+    if ps == nil { return false, fmt.Errorf("unknown process: %s", slug) }
+
+    ps.mu.Lock()
+    if ps.healthRestartPending {
+        ps.mu.Unlock()
+        return false, nil
+    }
+    ps.healthRestartPending = true
+    ps.mu.Unlock()
+    defer func() {
+        ps.mu.Lock()
+        ps.healthRestartPending = false
+        ps.mu.Unlock()
+    }()
+
+    select {
+    case <-time.After(s.healthRestartGrace()):
+    case <-s.ctx.Done():
+        return false, s.ctx.Err()
+    }
+
+    ps.mu.RLock()
+    stopped := ps.State == ProcessStopped
+    ps.mu.RUnlock()
+    if stopped { return false, nil }
+    if ph, ok := s.processHealth(slug); ok && ph.Status == health.Ready {
+        ps.logf("Health restart skipped, recovered within %s: %s", s.healthRestartGrace(), detail)
+        return false, nil
+    }
+
+    if err := s.collapse(slug, func() error { return s.restart(slug) }); err != nil {
+        return false, err
+    }
+    s.recordRestart(slug, RestartHealth, detail)
+    return true, nil
+}
+
+// proc returns the state of slug, or nil if it was never started.
+func (s *Supervisor) proc(slug string) *ProcState {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return s.procs[slug]
+}
+
+// recordRestart counts a restart of slug that went through Restart or RestartForHealth.
+func (s *Supervisor) recordRestart(slug string, reason RestartReason, detail string) {
+    ps := s.proc(slug)
+    if ps == nil { return }
+    ps.mu.Lock()
+    ps.noteRestart(reason, detail)
+    ps.mu.Unlock()
+    ps.logf("Restarted (%s): %s", reason, detail)
+}
+
+// noteRestart records a restart for reason. Callers hold ps.mu.
+func (ps *ProcState) noteRestart(reason RestartReason, detail string) {
+    if ps.RestartsByReason == nil { ps.RestartsByReason = make(map[RestartReason]int) }
+    ps.RestartsByReason[reason]++
+    ps.LastRestartReason = reason
+    ps.LastRestartDetail = detail
+    ps.LastRestartAt = time.Now()
+}
+
+// addRestartInfo adds the restart breakdown to a GetProcessInfo map. Callers hold ps.mu.
+func (ps *ProcState) addRestartInfo(info map[string]interface{}) {
+    counts := map[string]int{}
+    for _, r := range []RestartReason{RestartCrash, RestartHealth, RestartManual, RestartConfig, RestartMemory} {
+        counts[string(r)] = ps.RestartsByReason[r]
+    }
+    info["restartsByReason"] = counts
+    if ps.LastRestartReason != "" {
+        info["lastRestart"] = map[string]interface{}{
+            "reason": ps.LastRestartReason,
+            "detail": ps.LastRestartDetail,
+            "at":     ps.LastRestartAt,
+        }
+    }
+}
+
+// logf appends a timestamped line to the process log, if it is open.
+func (ps *ProcState) logf(format string, args ...interface{}) {
+    ps.mu.RLock()
+    defer ps.mu.RUnlock()
+    if ps.LogFile != nil {
+        fmt.Fprintf(ps.LogFile, "[%s] %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+    }
+}
@@ -0,0 +1,98 @@
+package supervisor
+
+import (
+    "os"
+    "path/filepath"
+    "sync/atomic"
+    "syscall"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/health"
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+type fixedHealth struct{ status atomic.Value }
+
+func (f *fixedHealth) GetProcessHealth(string) (*health.ProcessHealth, bool) {
+    return &health.ProcessHealth{Status: f.status.Load().(health.Status)}, true
+}
+
+func TestRestartReasons(t *testing.T) {
+    t.Setenv("HOME", t.TempDir())
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "sleeper"), 0o755); err != nil { t.Fatal(err) }
+
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Name:   "Sleeper",
+        Slug:   "sleeper",
+        Entry:  registry.Entry{Transport: "stdio", Command: "sleep", Args: []string{"30"}},
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+    }}}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    src := &fixedHealth{}
+    src.status.Store(health.Ready)
+    sup.SetHealthSource(src)
+    sup.SetHealthRestartGrace(50 * time.Millisecond)
+
+    waitRunning := func() int {
+        t.Helper()
+        deadline := time.Now().Add(5 * time.Second)
+        for time.Now().Before(deadline) {
+            info := sup.GetProcessInfo("sleeper")
+            if info["state"] == ProcessRunning.String() { return info["pid"].(int) }
+            time.Sleep(20 * time.Millisecond)
+        }
+        t.Fatal("process never reached running")
+        return 0
+    }
+    counts := func() map[string]int {
+        return sup.GetProcessInfo("sleeper")["restartsByReason"].(map[string]int)
+    }
+
+    if err := sup.Start("sleeper"); err != nil { t.Fatal(err) }
+    pid := waitRunning()
+
+    // A process that is healthy again by the end of the grace period is left alone
+    if restarted, err := sup.RestartForHealth("sleeper", "consecutive failures: 3"); err != nil || restarted {
+        t.Fatalf("recovered process: restarted %v, err %v", restarted, err)
+    }
+    if got := waitRunning(); got != pid { t.Fatalf("pid changed from %d to %d", pid, got) }
+
+    // Only one health restart waits at a time
+    src.status.Store(health.Down)
+    first := make(chan bool)
+    go func() {
+        restarted, _ := sup.RestartForHealth("sleeper", "consecutive failures: 3")
+        first <- restarted
+    }()
+    time.Sleep(10 * time.Millisecond)
+    if restarted, _ := sup.RestartForHealth("sleeper", "again"); restarted {
+        t.Fatal("second health restart was not dropped")
+    }
+    if !<-first { t.Fatal("unhealthy process was not restarted") }
+    pid = waitRunning()
+
+    if err := sup.Restart("sleeper"); err != nil { t.Fatal(err) }
+    pid = waitRunning()
+
+    // A crash is relaunched by the restart policy
+    if err := syscall.Kill(pid, syscall.SIGKILL); err != nil { t.Fatal(err) }
+    deadline := time.Now().Add(5 * time.Second)
+    for counts()["crash"] == 0 {
+        if time.Now().After(deadline) { t.Fatal("crash restart never counted") }
+        time.Sleep(20 * time.Millisecond)
+    }
+
+    want := map[string]int{"crash": 1, "health": 1, "manual": 1}
+    got := counts()
+    for reason, n := range want {
+        if got[reason] != n { t.Fatalf("restartsByReason = %v, want %v", got, want) }
+    }
+    last := sup.GetProcessInfo("sleeper")["lastRestart"].(map[string]interface{})
+    if last["reason"] != RestartCrash { t.Fatalf("lastRestart = %v", last) }
+}
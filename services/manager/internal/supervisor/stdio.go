@@ -0,0 +1,455 @@
+package supervisor
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+
+    "mcp/manager/internal/registry"
+)
+
+const (
+    // stdioClientBuffer is how many messages may queue for an attached client
+    // before it is treated as stalled and disconnected.
+    stdioClientBuffer = 256
+    // maxStdioMessage bounds a single message written by a client.
+    maxStdioMessage = 4 << 20
+    // stdioDrainTimeout bounds how long an exited server's stdout is drained,
+    // in case a child process inherited the pipe and keeps it open.
+    stdioDrainTimeout = 2 * time.Second
+)
+
+// JSON-RPC error codes answered by the broker itself.
+const (
+    rpcParseError     = -32700
+    rpcInvalidRequest = -32600
+    rpcMethodNotFound = -32601
+)
+
+var errStdioClosed = errors.New("server process is not running")
+
+// stdioBroker shares the stdin and stdout of one stdio server among attached
+// clients. Client requests are given broker-assigned ids before they reach the
+// server, so clients that pick the same ids don't collide, and each response
+// is routed back to its sender with the original id restored. Notifications
+// and server-initiated requests go to every client; the first client response
+// to a server request is forwarded and the rest are dropped.
+//
+// A server accepts initialize once per process, so the broker forwards only
+// the first one and answers later clients from the cached result. Likewise
+// only the first notifications/initialized is forwarded. When the process
+// exits every client is disconnected and must attach again.
+type stdioBroker struct {
+    slug string
+
+    writeMu sync.Mutex // serializes writes to stdin
+
+    mu           sync.Mutex
+    stdin        io.WriteCloser
+    clients      map[*stdioClient]struct{}
+    pending      map[int64]pendingCall
+    serverCalls  map[string]bool // ids of server requests awaiting a client response
+    nextID       int64
+    initResult   json.RawMessage
+    initInFlight bool
+    initWaiters  []pendingCall
+    initialized  bool
+}
+
+// pendingCall is a client request forwarded to the server.
+type pendingCall struct {
+    client *stdioClient
+    id     json.RawMessage
+    method string
+}
+
+// stdioMessage holds the fields of a JSON-RPC message the broker routes on.
+type stdioMessage struct {
+    ID     json.RawMessage `json:"id"`
+    Method string          `json:"method"`
+    Params json.RawMessage `json:"params"`
+    Result json.RawMessage `json:"result"`
+}
+
+func (m *stdioMessage) hasID() bool {
+    return len(m.ID) > 0 && string(m.ID) != "null"
+}
+
+func newStdioBroker(slug string) *stdioBroker {
+    b := &stdioBroker{slug: slug}
+    b.reset()
+    return b
+}
+
+// reset clears per-process session state. Callers hold b.mu.
+func (b *stdioBroker) reset() {
+    b.clients = make(map[*stdioClient]struct{})
+    b.pending = make(map[int64]pendingCall)
+    b.serverCalls = make(map[string]bool)
+    b.initResult = nil
+    b.initInFlight = false
+    b.initWaiters = nil
+    b.initialized = false
+}
+
+// bind connects the broker to the stdin of a freshly started process.
+func (b *stdioBroker) bind(stdin io.WriteCloser) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.stdin = stdin
+}
+
+// unbind disconnects every client after the process has exited.
+func (b *stdioBroker) unbind() {
+    b.mu.Lock()
+    clients := b.clients
+    b.stdin = nil
+    b.reset()
+    b.mu.Unlock()
+
+    for c := range clients {
+        c.closeQueue()
+    }
+}
+
+// attach registers a new client whose requests are checked against policy.
+func (b *stdioBroker) attach(policy *registry.RPCPolicy) (*stdioClient, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if b.stdin == nil {
+        return nil, errStdioClosed
+    }
+    c := &stdioClient{
+        broker: b,
+        policy: policy,
+        out:    make(chan []byte, stdioClientBuffer),
+        done:   make(chan struct{}),
+    }
+    b.clients[c] = struct{}{}
+    return c, nil
+}
+
+// detach removes c. Its outstanding requests still complete on the server but
+// their responses are discarded.
+func (b *stdioBroker) detach(c *stdioClient) {
+    b.mu.Lock()
+    delete(b.clients, c)
+    b.mu.Unlock()
+    c.closeQueue()
+}
+
+// pump copies the server's stdout into logw and routes each line that holds a
+// JSON-RPC message to the attached clients. It closes r and done at EOF.
+func (b *stdioBroker) pump(r *os.File, logw io.Writer, done chan struct{}) {
+    defer close(done)
+    defer r.Close()
+
+    br := bufio.NewReader(r)
+    for {
+        line, err := br.ReadBytes('\n')
+        if len(line) > 0 {
+            _, _ = logw.Write(line)
+            b.fromServer(bytes.TrimSpace(line))
+        }
+        if err != nil {
+            return
+        }
+    }
+}
+
+// fromServer routes one line of server output.
+func (b *stdioBroker) fromServer(line []byte) {
+    if len(line) == 0 || line[0] != '{' {
+        return
+    }
+    var msg stdioMessage
+    if err := json.Unmarshal(line, &msg); err != nil {
+        return
+    }
+
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    if msg.Method != "" {
+        if msg.hasID() {
+            b.serverCalls[string(msg.ID)] = true
+        }
+        for c := range b.clients {
+            b.deliver(c, line)
+        }
+        return
+    }
+
+    id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+    if err != nil {
+        return
+    }
+    call, ok := b.pending[id]
+    if !ok {
+        return
+    }
+    delete(b.pending, id)
+
+    if call.method == "initialize" {
+        b.initInFlight = false
+        if len(msg.Result) > 0 {
+            b.initResult = msg.Result
+        }
+        for _, w := range b.initWaiters {
+            if _, ok := b.clients[w.client]; ok {
+                b.deliver(w.client, withID(line, w.id))
+            }
+        }
+        b.initWaiters = nil
+    }
+    if _, ok := b.clients[call.client]; ok {
+        b.deliver(call.client, withID(line, call.id))
+    }
+}
+
+// fromClient handles one line written by c.
+func (b *stdioBroker) fromClient(c *stdioClient, line []byte) error {
+    line = bytes.TrimSpace(line)
+    if len(line) == 0 {
+        return nil
+    }
+
+    b.mu.Lock()
+    if _, ok := b.clients[c]; !ok || b.stdin == nil {
+        b.mu.Unlock()
+        return errStdioClosed
+    }
+    forward := b.route(c, line)
+    b.mu.Unlock()
+
+    if forward == nil {
+        return nil
+    }
+    return b.write(forward)
+}
+
+// route decides what, if anything, to send to the server for a client
+// message, answering the client directly where the broker can. Callers hold
+// b.mu.
+func (b *stdioBroker) route(c *stdioClient, line []byte) []byte {
+    if line[0] == '[' {
+        b.deliver(c, rpcErrorLine(nil, rpcInvalidRequest, "batch requests are not supported"))
+        return nil
+    }
+    var msg stdioMessage
+    if err := json.Unmarshal(line, &msg); err != nil {
+        b.deliver(c, rpcErrorLine(nil, rpcParseError, "parse error"))
+        return nil
+    }
+
+    // Responses to server requests
+    if msg.Method == "" {
+        if msg.hasID() && b.serverCalls[string(msg.ID)] {
+            delete(b.serverCalls, string(msg.ID))
+            return line
+        }
+        return nil
+    }
+
+    // Notifications
+    if !msg.hasID() {
+        switch msg.Method {
+        case "notifications/initialized":
+            if b.initialized {
+                return nil
+            }
+            b.initialized = true
+            return line
+        case "notifications/cancelled":
+            return b.remapCancel(c, line, msg.Params)
+        }
+        if !c.policy.Permits(msg.Method) {
+            log.Printf("[AUDIT] Dropped notification %s from client attached to %s", msg.Method, b.slug)
+            return nil
+        }
+        return line
+    }
+
+    // Requests
+    if msg.Method == "initialize" {
+        if b.initResult != nil {
+            b.deliver(c, rpcResultLine(msg.ID, b.initResult))
+            return nil
+        }
+        if b.initInFlight {
+            b.initWaiters = append(b.initWaiters, pendingCall{client: c, id: msg.ID, method: msg.Method})
+            return nil
+        }
+        b.initInFlight = true
+    } else if !c.policy.Permits(msg.Method) {
+        log.Printf("[AUDIT] Denied RPC method %s for client attached to %s", msg.Method, b.slug)
+        b.deliver(c, rpcErrorLine(msg.ID, rpcMethodNotFound, fmt.Sprintf("method %s is not permitted for server %s", msg.Method, b.slug)))
+        return nil
+    }
+
+    b.nextID++
+    b.pending[b.nextID] = pendingCall{client: c, id: msg.ID, method: msg.Method}
+    return withID(line, json.RawMessage(strconv.FormatInt(b.nextID, 10)))
+}
+
+// remapCancel rewrites the requestId of a client's cancellation to the id the
+// server knows the request by. Cancellations for unknown requests are dropped.
+// Callers hold b.mu.
+func (b *stdioBroker) remapCancel(c *stdioClient, line []byte, params json.RawMessage) []byte {
+    var p map[string]json.RawMessage
+    if err := json.Unmarshal(params, &p); err != nil {
+        return nil
+    }
+    for id, call := range b.pending {
+        if call.client == c && bytes.Equal(call.id, p["requestId"]) {
+            p["requestId"] = json.RawMessage(strconv.FormatInt(id, 10))
+            rewritten, err := json.Marshal(p)
+            if err != nil {
+                return nil
+            }
+            return withField(line, "params", rewritten)
+        }
+    }
+    return nil
+}
+
+// deliver queues line for c, disconnecting c if its queue is full. Callers
+// hold b.mu.
+func (b *stdioBroker) deliver(c *stdioClient, line []byte) {
+    msg := make([]byte, len(line)+1)
+    copy(msg, line)
+    msg[len(line)] = '\n'
+    select {
+    case c.out <- msg:
+    default:
+        log.Printf("stdio: disconnecting stalled client of %s", b.slug)
+        delete(b.clients, c)
+        c.closeQueue()
+    }
+}
+
+// write sends one message to the server's stdin.
+func (b *stdioBroker) write(line []byte) error {
+    b.writeMu.Lock()
+    defer b.writeMu.Unlock()
+
+    b.mu.Lock()
+    stdin := b.stdin
+    b.mu.Unlock()
+    if stdin == nil {
+        return errStdioClosed
+    }
+    msg := make([]byte, len(line)+1)
+    copy(msg, line)
+    msg[len(line)] = '\n'
+    _, err := stdin.Write(msg)
+    return err
+}
+
+// withID returns line with its id replaced.
+func withID(line []byte, id json.RawMessage) []byte {
+    return withField(line, "id", id)
+}
+
+// withField returns the JSON object line with key set to value, or line
+// unchanged if it cannot be decoded.
+func withField(line []byte, key string, value json.RawMessage) []byte {
+    var obj map[string]json.RawMessage
+    if err := json.Unmarshal(line, &obj); err != nil {
+        return line
+    }
+    obj[key] = value
+    out, err := json.Marshal(obj)
+    if err != nil {
+        return line
+    }
+    return out
+}
+
+func rpcResultLine(id, result json.RawMessage) []byte {
+    out, _ := json.Marshal(map[string]json.RawMessage{"jsonrpc": json.RawMessage(`"2.0"`), "id": id, "result": result})
+    return out
+}
+
+func rpcErrorLine(id json.RawMessage, code int, message string) []byte {
+    if len(id) == 0 {
+        id = json.RawMessage("null")
+    }
+    out, _ := json.Marshal(map[string]any{
+        "jsonrpc": "2.0",
+        "id":      id,
+        "error":   map[string]any{"code": code, "message": message},
+    })
+    return out
+}
+
+// stdioClient is one attached client. It reads and writes newline-delimited
+// JSON-RPC messages.
+type stdioClient struct {
+    broker *stdioBroker
+    policy *registry.RPCPolicy
+    out    chan []byte
+    done   chan struct{}
+    once   sync.Once
+    rbuf   []byte
+    wbuf   []byte
+}
+
+// Read returns messages destined for the client. It reports io.EOF once the
+// client is detached and its queue is drained.
+func (c *stdioClient) Read(p []byte) (int, error) {
+    if len(c.rbuf) == 0 {
+        select {
+        case msg := <-c.out:
+            c.rbuf = msg
+        case <-c.done:
+            select {
+            case msg := <-c.out:
+                c.rbuf = msg
+            default:
+                return 0, io.EOF
+            }
+        }
+    }
+    n := copy(p, c.rbuf)
+    c.rbuf = c.rbuf[n:]
+    return n, nil
+}
+
+// Write passes each complete line in p to the broker.
+func (c *stdioClient) Write(p []byte) (int, error) {
+    c.wbuf = append(c.wbuf, p...)
+    for {
+        i := bytes.IndexByte(c.wbuf, '\n')
+        if i < 0 {
+            break
+        }
+        line := c.wbuf[:i]
+        c.wbuf = c.wbuf[i+1:]
+        if err := c.broker.fromClient(c, line); err != nil {
+            return 0, err
+        }
+    }
+    if len(c.wbuf) > maxStdioMessage {
+        return 0, fmt.Errorf("message exceeds %d bytes", maxStdioMessage)
+    }
+    return len(p), nil
+}
+
+// Close detaches the client. The server keeps running.
+func (c *stdioClient) Close() error {
+    c.broker.detach(c)
+    return nil
+}
+
+func (c *stdioClient) closeQueue() {
+    c.once.Do(func() { close(c.done) })
+}
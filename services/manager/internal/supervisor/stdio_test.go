@@ -0,0 +1,226 @@
+package supervisor
+
+import (
+    "bufio"
+    "encoding/json"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
+)
+
+// lineRecorder captures what the broker writes to a server's stdin.
+type lineRecorder struct {
+    mu    sync.Mutex
+    lines []string
+}
+
+func (l *lineRecorder) Write(p []byte) (int, error) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.lines = append(l.lines, strings.TrimSuffix(string(p), "\n"))
+    return len(p), nil
+}
+
+func (l *lineRecorder) Close() error { return nil }
+
+func (l *lineRecorder) take() []map[string]any {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    out := make([]map[string]any, len(l.lines))
+    for i, line := range l.lines {
+        _ = json.Unmarshal([]byte(line), &out[i])
+    }
+    l.lines = nil
+    return out
+}
+
+// next returns the next queued message for c without blocking.
+func next(t *testing.T, c *stdioClient) map[string]any {
+    t.Helper()
+    select {
+    case msg := <-c.out:
+        var out map[string]any
+        if err := json.Unmarshal(msg, &out); err != nil { t.Fatalf("bad message %q: %v", msg, err) }
+        return out
+    default:
+        t.Fatal("no message queued")
+    }
+    return nil
+}
+
+func TestStdioBrokerRouting(t *testing.T) {
+    stdin := &lineRecorder{}
+    b := newStdioBroker("shared")
+    b.bind(stdin)
+    a, _ := b.attach(nil)
+    c, _ := b.attach(&registry.RPCPolicy{Deny: []string{"tools/call"}})
+
+    send := func(cl *stdioClient, msg string) {
+        t.Helper()
+        if _, err := cl.Write([]byte(msg + "\n")); err != nil { t.Fatal(err) }
+    }
+
+    // Both clients use id 1; the server sees distinct ids
+    send(a, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+    send(c, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`)
+    sent := stdin.take()
+    if len(sent) != 2 || sent[0]["id"] == sent[1]["id"] { t.Fatalf("ids not remapped: %v", sent) }
+
+    b.fromServer([]byte(`{"jsonrpc":"2.0","id":2,"result":{"who":"c"}}`))
+    b.fromServer([]byte(`{"jsonrpc":"2.0","id":1,"result":{"who":"a"}}`))
+    if got := next(t, a); got["id"] != float64(1) || got["result"].(map[string]any)["who"] != "a" { t.Fatalf("a got %v", got) }
+    if got := next(t, c); got["id"] != float64(1) || got["result"].(map[string]any)["who"] != "c" { t.Fatalf("c got %v", got) }
+
+    // Notifications reach everyone
+    b.fromServer([]byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`))
+    if next(t, a)["method"] != "notifications/tools/list_changed" || next(t, c)["method"] != "notifications/tools/list_changed" {
+        t.Fatal("notification not broadcast")
+    }
+
+    // Only the first answer to a server request is forwarded
+    b.fromServer([]byte(`{"jsonrpc":"2.0","id":"s1","method":"roots/list"}`))
+    next(t, a)
+    next(t, c)
+    send(c, `{"jsonrpc":"2.0","id":"s1","result":{"roots":[]}}`)
+    send(a, `{"jsonrpc":"2.0","id":"s1","result":{"roots":[]}}`)
+    if sent := stdin.take(); len(sent) != 1 || sent[0]["id"] != "s1" { t.Fatalf("server request answers: %v", sent) }
+
+    // Cancellations refer to the server-side id
+    send(a, `{"jsonrpc":"2.0","id":"x","method":"tools/list"}`)
+    send(a, `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"x"}}`)
+    sent = stdin.take()
+    if len(sent) != 2 || sent[1]["params"].(map[string]any)["requestId"] != sent[0]["id"] { t.Fatalf("cancel not remapped: %v", sent) }
+
+    // Policy is enforced per client
+    send(c, `{"jsonrpc":"2.0","id":5,"method":"tools/call"}`)
+    if got := next(t, c); got["error"].(map[string]any)["code"] != float64(rpcMethodNotFound) || got["id"] != float64(5) { t.Fatalf("denied call: %v", got) }
+    if sent := stdin.take(); len(sent) != 0 { t.Fatalf("denied call forwarded: %v", sent) }
+
+    // Unbinding disconnects clients
+    b.unbind()
+    if _, err := a.Read(make([]byte, 1)); err != io.EOF { t.Fatalf("read after unbind: %v", err) }
+    if _, err := a.Write([]byte("{}\n")); err == nil { t.Fatal("write after unbind succeeded") }
+}
+
+func TestStdioBrokerSharesInitialize(t *testing.T) {
+    stdin := &lineRecorder{}
+    b := newStdioBroker("shared")
+    b.bind(stdin)
+    a, _ := b.attach(nil)
+    c, _ := b.attach(nil)
+    late, _ := b.attach(nil)
+
+    _, _ = a.Write([]byte(`{"jsonrpc":"2.0","id":"init-a","method":"initialize","params":{}}` + "\n"))
+    _, _ = c.Write([]byte(`{"jsonrpc":"2.0","id":"init-c","method":"initialize","params":{}}` + "\n"))
+    sent := stdin.take()
+    if len(sent) != 1 { t.Fatalf("want one initialize forwarded, got %v", sent) }
+
+    b.fromServer([]byte(`{"jsonrpc":"2.0","id":` + string(mustJSON(sent[0]["id"])) + `,"result":{"protocolVersion":"2025-06-18"}}`))
+    if got := next(t, a); got["id"] != "init-a" { t.Fatalf("a got %v", got) }
+    if got := next(t, c); got["id"] != "init-c" { t.Fatalf("waiting client got %v", got) }
+
+    _, _ = late.Write([]byte(`{"jsonrpc":"2.0","id":3,"method":"initialize","params":{}}` + "\n"))
+    got := next(t, late)
+    if got["id"] != float64(3) || got["result"].(map[string]any)["protocolVersion"] != "2025-06-18" { t.Fatalf("late client got %v", got) }
+
+    for _, cl := range []*stdioClient{a, c, late} {
+        _, _ = cl.Write([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n"))
+    }
+    if sent := stdin.take(); len(sent) != 1 { t.Fatalf("want one initialized notification, got %v", sent) }
+}
+
+func mustJSON(v any) []byte {
+    data, _ := json.Marshal(v)
+    return data
+}
+
+func TestAttachToStdioServer(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "echo"), 0o755); err != nil { t.Fatal(err) }
+
+    // Answers every message with a result naming its method
+    script := `while IFS= read -r l; do printf '%s\n' "$l" | sed 's/"method":"\([^"]*\)"/"result":{"method":"\1"}/'; done`
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Slug:   "echo",
+        Entry:  registry.Entry{Transport: "stdio", Command: "sh", Args: []string{"-c", script}},
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+        RPC:    &registry.RPCPolicy{Deny: []string{"tools/call"}},
+    }}}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+
+    if err := sup.Start("echo"); err != nil { t.Fatal(err) }
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        if state, _ := sup.GetProcessState("echo"); state == ProcessRunning { break }
+        if time.Now().After(deadline) { t.Fatal("process never reached running") }
+        time.Sleep(20 * time.Millisecond)
+    }
+
+    readLine := func(r *bufio.Reader) (string, error) {
+        t.Helper()
+        type result struct { line string; err error }
+        ch := make(chan result, 1)
+        go func() { line, err := r.ReadString('\n'); ch <- result{line, err} }()
+        select {
+        case res := <-ch:
+            return res.line, res.err
+        case <-time.After(5 * time.Second):
+            t.Fatal("timed out waiting for a message")
+        }
+        return "", nil
+    }
+    call := func(c io.Writer, r *bufio.Reader, msg string) map[string]any {
+        t.Helper()
+        if _, err := io.WriteString(c, msg+"\n"); err != nil { t.Fatal(err) }
+        line, err := readLine(r)
+        if err != nil { t.Fatalf("reply to %s: %v", msg, err) }
+        var out map[string]any
+        if err := json.Unmarshal([]byte(line), &out); err != nil { t.Fatalf("bad reply %q: %v", line, err) }
+        return out
+    }
+
+    a, err := sup.Attach("echo")
+    if err != nil { t.Fatal(err) }
+    b, err := sup.Attach("echo")
+    if err != nil { t.Fatal(err) }
+    ra, rb := bufio.NewReader(a), bufio.NewReader(b)
+
+    for _, got := range []map[string]any{
+        call(a, ra, `{"jsonrpc":"2.0","id":1,"method":"initialize"}`),
+        call(b, rb, `{"jsonrpc":"2.0","id":1,"method":"initialize"}`),
+    } {
+        if got["id"] != float64(1) || got["result"].(map[string]any)["method"] != "initialize" { t.Fatalf("initialize: %v", got) }
+    }
+    for _, got := range []map[string]any{
+        call(a, ra, `{"jsonrpc":"2.0","id":7,"method":"tools/list"}`),
+        call(b, rb, `{"jsonrpc":"2.0","id":7,"method":"tools/list"}`),
+    } {
+        if got["id"] != float64(7) || got["result"].(map[string]any)["method"] != "tools/list" { t.Fatalf("tools/list: %v", got) }
+    }
+    if got := call(a, ra, `{"jsonrpc":"2.0","id":8,"method":"tools/call"}`); got["error"] == nil { t.Fatalf("denied method reached the server: %v", got) }
+
+    // Server output still reaches the log
+    logsDir, _ := paths.LogsDir()
+    data, _ := os.ReadFile(filepath.Join(logsDir, "echo.log"))
+    if n := strings.Count(string(data), `{"method":"initialize"}`); n != 1 { t.Fatalf("initialize reached the server %d times:\n%s", n, data) }
+    if !strings.Contains(string(data), `{"method":"tools/list"}`) { t.Fatalf("server output missing from log:\n%s", data) }
+
+    // Detaching one client leaves the other attached
+    a.Close()
+    if got := call(b, rb, `{"jsonrpc":"2.0","id":9,"method":"ping"}`); got["id"] != float64(9) { t.Fatalf("ping after detach: %v", got) }
+
+    if err := sup.Stop("echo", time.Second); err != nil { t.Fatal(err) }
+    if _, err := readLine(rb); err != io.EOF { t.Fatalf("want EOF after stop, got %v", err) }
+    if _, err := sup.Attach("echo"); err == nil { t.Fatal("attach to stopped server succeeded") }
+}
@@ -2,9 +2,10 @@ package supervisor
 
 import (
     "context"
+    "errors"
     "fmt"
     "io"
-    "net/http"
+    "log"
     "os"
     "os/exec"
     "os/signal"
@@ -52,6 +53,10 @@ func (s ProcessState) String() string {
     }
 }
 
+// hookTimeout bounds each pre-start/post-stop hook so a hung script cannot
+// block a launch forever.
+const hookTimeout = 60 * time.Second
+
 // RestartPolicy defines how processes should be restarted
 type RestartPolicy struct {
     Policy      string        // "always", "on-failure", "never"
@@ -82,28 +87,49 @@ type ProcState struct {
     State          ProcessState
     Status         health.Status
     Restarts       int
+    LastExitCode   int    // -1 when killed by a signal
+    LastSignal     string // e.g. "SIGKILL", empty for a normal exit
+    LastExitAt     time.Time
+    LastOOMKill    bool // killed for exceeding its cgroup memory limit
+    memoryCapHit   bool // killed by enforceMemoryCap, not yet seen exiting
     StartedAt      time.Time
     StoppedAt      time.Time
-    LastPingMs     int
-    MissedPings    int
     Uptime         time.Duration
     Stopping       int32 // atomic flag
     CPUPercent     float64
     RSSBytes       int64
+    StatsUnsupported bool // CPU and memory can't be sampled here; the zeros mean nothing
     LogPath        string
     LogFile        *os.File
-    LastLogAt      time.Time
     Transport      string
     HTTPURL        string
     RestartsAt     []time.Time
-    HandshakeReady bool
     RestartPolicy  RestartPolicy
+    StopSignal     syscall.Signal // asks the process to exit, before SIGKILL
+    StopTimeout    time.Duration  // how long it gets after StopSignal; zero uses the caller's
+    
+    // Restarts broken down by what triggered them, and the latest one
+    RestartsByReason  map[RestartReason]int
+    LastRestartReason RestartReason
+    LastRestartDetail string
+    LastRestartAt     time.Time
+    healthRestartPending bool // a RestartForHealth call is waiting out its grace period
+    launchHash        string // launchHash of the configuration the process was started with
+    LaunchError       string // why it couldn't launch: a refused command (ErrCommandNotAllowed) or a dependency (ErrDependencyNotReady)
+    
+    // Stdio servers keep their pipes so clients can attach
+    stdio      *stdioBroker
+    stdoutPipe *os.File
+    pumpDone   chan struct{}
+    
+    // Enforces the server's memory and CPU limits while it runs
+    cgroup *cgroup
     
     // Control channels
     stopCh      chan struct{}
     stoppedCh   chan struct{}
-    healthStopCh chan struct{}
     metricsStopCh chan struct{}
+    runDone     chan struct{} // closed when runProcess returns
     
     // Context for process lifetime
     ctx    context.Context
@@ -129,6 +155,53 @@ type Supervisor struct {
     
     // Background tasks
     wg sync.WaitGroup
+    
+    // In-flight Start/Restart calls, keyed by slug
+    flightMu sync.Mutex
+    inflight map[string]*startCall
+    
+    // Probe results for running processes
+    healthMu  sync.RWMutex
+    healthSrc HealthSource
+    
+    // Vault lookups for vault:// env values
+    secretsMu sync.RWMutex
+    secrets   SecretSource
+    
+    // Cool-down before a health-triggered restart
+    graceMu     sync.RWMutex
+    healthGrace time.Duration
+    
+    // Called on SIGHUP
+    reloadMu sync.RWMutex
+    reload   func()
+    
+    // Commands servers may launch; empty allows any
+    allowMu   sync.RWMutex
+    allowlist []string
+    
+    // CPU/RAM sampling; it is given up for good after the first failed sample
+    statsCmd         string
+    statsUnsupported atomic.Bool
+    statsOnce        sync.Once
+    
+    // What state.json records, for a restarted daemon to adopt
+    state processRecords
+    
+    // How long Start waits for each dependency to become ready
+    depTimeout time.Duration
+}
+
+// SecretSource looks up the vault items that vault:// env values refer to.
+type SecretSource interface {
+    Retrieve(ref string) (map[string]string, error)
+}
+
+// startCall tracks a Start or Restart in progress so concurrent callers
+// for the same slug share its result instead of spawning again.
+type startCall struct {
+    done chan struct{}
+    err  error
 }
 
 func New(reg *registry.Registry, perFileCap, globalCap int64) *Supervisor {
@@ -136,55 +209,92 @@ func New(reg *registry.Registry, perFileCap, globalCap int64) *Supervisor {
     s := &Supervisor{
         reg:        reg,
         procs:      make(map[string]*ProcState),
+        inflight:   make(map[string]*startCall),
         perCap:     perFileCap,
         globCap:    globalCap,
         ctx:        ctx,
         cancel:     cancel,
         shutdownCh: make(chan struct{}),
+        statsCmd:   "ps",
+        depTimeout: defaultDependencyTimeout,
     }
     
-    // Start the global supervisor goroutines
+    // Take over the servers a previous daemon left running
+    s.adoptRecorded()
+    
+    // Start the global supervisor goroutines. Signals are registered first,
+    // so a SIGHUP right after New can't take the default action of exiting.
+    sigCh := make(chan os.Signal, 1)
+    signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
     s.wg.Add(1)
-    go s.signalHandler()
+    go s.signalHandler(sigCh)
     
     return s
 }
 
-// signalHandler handles system signals for graceful shutdown
-func (s *Supervisor) signalHandler() {
+// signalHandler shuts down gracefully on SIGINT or SIGTERM and runs the
+// reload handler on SIGHUP.
+func (s *Supervisor) signalHandler(sigCh chan os.Signal) {
     defer s.wg.Done()
+    defer signal.Stop(sigCh)
     
-    sigCh := make(chan os.Signal, 1)
-    signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-    
-    select {
-    case <-sigCh:
-        s.Shutdown(30 * time.Second)
-    case <-s.ctx.Done():
-        return
+    for {
+        select {
+        case sig := <-sigCh:
+            if sig != syscall.SIGHUP {
+                s.Shutdown(30 * time.Second)
+                return
+            }
+            s.reloadMu.RLock()
+            reload := s.reload
+            s.reloadMu.RUnlock()
+            if reload != nil {
+                reload()
+            }
+        case <-s.ctx.Done():
+            return
+        }
     }
 }
 
-// Shutdown gracefully stops all processes and shuts down the supervisor
+// SetReloadHandler makes SIGHUP call fn, which should reread the
+// configuration. Without one SIGHUP is ignored rather than ending the process.
+func (s *Supervisor) SetReloadHandler(fn func()) {
+    s.reloadMu.Lock()
+    defer s.reloadMu.Unlock()
+    s.reload = fn
+}
+
+// Shutdown stops all processes in parallel, giving each up to timeout to exit
+// gracefully, force-kills any still running once it elapses, and shuts down
+// the supervisor.
 func (s *Supervisor) Shutdown(timeout time.Duration) error {
     s.mu.Lock()
-    defer s.mu.Unlock()
     
     // Signal shutdown
     select {
     case <-s.shutdownCh:
+        s.mu.Unlock()
         return nil // already shutting down
     default:
         close(s.shutdownCh)
     }
+    slugs := make([]string, 0, len(s.procs))
+    for slug := range s.procs {
+        slugs = append(slugs, slug)
+    }
+    // stopProcess takes the read lock, so it must not be held while stopping
+    s.mu.Unlock()
     
-    // Stop all processes
+    // Processes stop in parallel, so each gets the whole budget to exit
+    // gracefully rather than a share of it
+    deadline := time.Now().Add(timeout)
     var wg sync.WaitGroup
-    for slug := range s.procs {
+    for _, slug := range slugs {
         wg.Add(1)
         go func(slug string) {
             defer wg.Done()
-            _ = s.stopProcess(slug, timeout/time.Duration(len(s.procs)))
+            _ = s.stopProcess(slug, time.Until(deadline))
         }(slug)
     }
     
@@ -198,13 +308,17 @@ func (s *Supervisor) Shutdown(timeout time.Duration) error {
     select {
     case <-done:
         // All processes stopped gracefully
-    case <-time.After(timeout):
-        // Force kill remaining processes
+    case <-time.After(time.Until(deadline)):
+        // Force kill the stragglers once the budget is spent
+        s.mu.RLock()
         for _, ps := range s.procs {
-            if ps.Process != nil {
-                _ = ps.Process.Kill()
+            ps.mu.RLock()
+            if ps.Process != nil && ps.State != ProcessStopped {
+                _ = signalGroup(ps.Process, syscall.SIGKILL)
             }
+            ps.mu.RUnlock()
         }
+        s.mu.RUnlock()
     }
     
     // Cancel context and wait for background tasks
@@ -215,17 +329,28 @@ func (s *Supervisor) Shutdown(timeout time.Duration) error {
 }
 
 func (s *Supervisor) Summary() []map[string]any {
+    out, _ := s.SummaryPage(0, 0)
+    return out
+}
+
+// SummaryPage returns the Summary rows of at most limit servers, 0 meaning no
+// limit, starting at offset in registry order, and the number of servers in
+// all. Only the rows on the page are built.
+func (s *Supervisor) SummaryPage(offset, limit int) ([]map[string]any, int) {
     s.mu.RLock()
-    defer s.mu.RUnlock()
-    
-    out := make([]map[string]any, 0, len(s.reg.Servers))
-    for _, sv := range s.reg.Servers {
+    total := len(s.reg.Servers)
+    from := min(max(offset, 0), total)
+    to := total
+    if limit > 0 && limit < total-from {
+        to = from + limit
+    }
+    out := make([]map[string]any, 0, to-from)
+    for _, sv := range s.reg.Servers[from:to] {
         ps := s.procs[sv.Slug]
         status := health.Down
         state := ProcessStopped
         uptime := time.Duration(0)
         restarts := 0
-        lastPing := 0
         pid := 0
         
         if ps != nil {
@@ -240,24 +365,29 @@ func (s *Supervisor) Summary() []map[string]any {
                 }
             }
             restarts = ps.Restarts
-            lastPing = ps.LastPingMs
             pid = ps.PID
             cpuPercent := ps.CPUPercent
             rssBytes := ps.RSSBytes
+            unsupported := ps.StatsUnsupported
             ps.mu.RUnlock()
             
-            out = append(out, map[string]any{
+            row := map[string]any{
                 "name":       sv.Name,
                 "slug":       sv.Slug,
                 "status":     string(status),
                 "state":      state.String(),
                 "uptime":     int(uptime.Seconds()),
                 "restarts":   restarts,
-                "lastPingMs": lastPing,
+                "lastPingMs": 0,
                 "pid":        pid,
                 "cpu":        cpuPercent,
                 "ramMB":      rssBytes / 1024 / 1024,
-            })
+            }
+            // null rather than 0, which would read as idle
+            if unsupported {
+                row["cpu"], row["ramMB"], row["metrics"] = nil, nil, "unsupported"
+            }
+            out = append(out, row)
         } else {
             out = append(out, map[string]any{
                 "name":       sv.Name,
@@ -273,7 +403,19 @@ func (s *Supervisor) Summary() []map[string]any {
             })
         }
     }
-    return out
+    s.mu.RUnlock()
+    
+    // The health source reads process state back, so ask it without holding locks
+    for _, row := range out {
+        if row["state"] != ProcessRunning.String() {
+            continue
+        }
+        if ph, ok := s.processHealth(row["slug"].(string)); ok {
+            row["status"] = string(ph.Status)
+            row["lastPingMs"] = lastResponseMs(ph)
+        }
+    }
+    return out, total
 }
 
 // GetProcessState returns the current state of a process
@@ -297,6 +439,7 @@ func (s *Supervisor) GetProcessState(slug string) (ProcessState, bool) {
 func (s *Supervisor) GetProcessInfo(slug string) map[string]interface{} {
     s.mu.RLock()
     ps := s.procs[slug]
+    sv := s.findServer(slug)
     s.mu.RUnlock()
     
     if ps == nil {
@@ -306,8 +449,6 @@ func (s *Supervisor) GetProcessInfo(slug string) map[string]interface{} {
     }
     
     ps.mu.RLock()
-    defer ps.mu.RUnlock()
-    
     info := map[string]interface{}{
         "exists":         true,
         "slug":           ps.Slug,
@@ -318,16 +459,35 @@ func (s *Supervisor) GetProcessInfo(slug string) map[string]interface{} {
         "restarts":       ps.Restarts,
         "startedAt":      ps.StartedAt,
         "stoppedAt":      ps.StoppedAt,
-        "lastPingMs":     ps.LastPingMs,
-        "missedPings":    ps.MissedPings,
+        "lastPingMs":     0,
+        "missedPings":    0,
         "cpuPercent":     ps.CPUPercent,
         "rssBytes":       ps.RSSBytes,
         "logPath":        ps.LogPath,
         "transport":      ps.Transport,
         "httpURL":        ps.HTTPURL,
-        "handshakeReady": ps.HandshakeReady,
+        "handshakeReady": false,
         "restartPolicy":  ps.RestartPolicy,
     }
+    ps.addRestartInfo(info)
+    info["configChanged"] = ps.configChanged(sv)
+    if ps.LaunchError != "" {
+        info["launchError"] = ps.LaunchError
+    }
+    if ps.StatsUnsupported {
+        info["cpuPercent"], info["rssBytes"], info["metrics"] = nil, nil, "unsupported"
+    }
+    
+    if !ps.LastExitAt.IsZero() {
+        info["lastExitCode"] = ps.LastExitCode
+        info["lastSignal"] = ps.LastSignal
+        info["lastExitAt"] = ps.LastExitAt
+        info["lastExitReason"] = ps.exitReason()
+    }
+    
+    if ps.cgroup != nil {
+        info["cgroup"] = ps.cgroup.path
+    }
     
     if !ps.StartedAt.IsZero() {
         if ps.State == ProcessRunning {
@@ -337,6 +497,25 @@ func (s *Supervisor) GetProcessInfo(slug string) map[string]interface{} {
         }
     }
     
+    running := ps.State == ProcessRunning
+    pid := ps.PID
+    ps.mu.RUnlock()
+    
+    if running && pid > 0 {
+        if nice, ok := processNice(pid); ok {
+            info["nice"] = nice
+        }
+    }
+    
+    if running {
+        if ph, ok := s.processHealth(slug); ok {
+            info["status"] = string(ph.Status)
+            info["lastPingMs"] = lastResponseMs(ph)
+            info["missedPings"] = ph.ConsecutiveFails
+            info["handshakeReady"] = ph.MCPHandshakeComplete
+        }
+    }
+    
     return info
 }
 
@@ -348,6 +527,7 @@ func (s *Supervisor) Stats() map[string]interface{} {
     running := 0
     stopped := 0
     failed := 0
+    rss := s.totalRSS()
     
     for _, ps := range s.procs {
         ps.mu.RLock()
@@ -363,17 +543,61 @@ func (s *Supervisor) Stats() map[string]interface{} {
     }
     
     return map[string]interface{}{
-        "totalProcesses": len(s.procs),
-        "running":        running,
-        "stopped":        stopped,
-        "failed":         failed,
-        "totalStarts":    atomic.LoadInt64(&s.totalStarts),
-        "totalStops":     atomic.LoadInt64(&s.totalStops),
-        "totalRestarts":  atomic.LoadInt64(&s.totalRestarts),
+        "totalProcesses":           len(s.procs),
+        "running":                  running,
+        "stopped":                  stopped,
+        "failed":                   failed,
+        "totalStarts":              atomic.LoadInt64(&s.totalStarts),
+        "totalStops":               atomic.LoadInt64(&s.totalStops),
+        "totalRestarts":            atomic.LoadInt64(&s.totalRestarts),
+        "rssBytes":                 rss,
+        "perProcessMemoryCapBytes": s.perCap,
+        "globalMemoryCapBytes":     s.globCap,
+        "overGlobalMemoryCap":      s.globCap > 0 && rss > s.globCap,
     }
 }
 
+// Start launches the process for slug once the servers it depends on are
+// started and ready. It is refused with ErrGlobalMemoryCap while the running
+// processes are over the global memory cap. Concurrent Start/Restart calls for the same slug
+// collapse into the one already in flight.
 func (s *Supervisor) Start(slug string) error {
+    if err := s.checkGlobalCap(slug); err != nil {
+        return err
+    }
+    if err := s.startDependencies(slug); err != nil {
+        return err
+    }
+    return s.collapse(slug, func() error { return s.start(slug) })
+}
+
+// collapse runs fn unless another Start/Restart for slug is in flight, in
+// which case it waits for that call and returns its result.
+func (s *Supervisor) collapse(slug string, fn func() error) error {
+    s.flightMu.Lock()
+    if s.inflight == nil {
+        s.inflight = make(map[string]*startCall)
+    }
+    if call, ok := s.inflight[slug]; ok {
+        s.flightMu.Unlock()
+        <-call.done
+        return call.err
+    }
+    call := &startCall{done: make(chan struct{})}
+    s.inflight[slug] = call
+    s.flightMu.Unlock()
+    
+    call.err = fn()
+    
+    s.flightMu.Lock()
+    delete(s.inflight, slug)
+    s.flightMu.Unlock()
+    close(call.done)
+    
+    return call.err
+}
+
+func (s *Supervisor) start(slug string) error {
     s.mu.Lock()
     defer s.mu.Unlock()
     
@@ -396,16 +620,39 @@ func (s *Supervisor) Start(slug string) error {
         return fmt.Errorf("unknown slug: %s", slug)
     }
     
+    // Refuse to launch anything that would only fail and loop in backoff
+    if err := validateLaunch(sv); err != nil {
+        return err
+    }
+    
     // Check if process already exists and is running
     if ps, exists := s.procs[slug]; exists {
         ps.mu.RLock()
         state := ps.State
+        runDone := ps.runDone
         ps.mu.RUnlock()
         
-        if state == ProcessRunning || state == ProcessStarting {
+        if state == ProcessRunning || state == ProcessStarting || state == ProcessRestarting {
             return nil // Already running or starting
         }
         
+        // A previous run loop may still be alive, either backing off after a
+        // failure or finishing a stop. Never start a second loop beside it.
+        if runDone != nil {
+            select {
+            case <-runDone:
+            default:
+                if atomic.LoadInt32(&ps.Stopping) == 0 {
+                    return nil // The existing loop will restart it
+                }
+                select {
+                case <-runDone:
+                case <-time.After(10 * time.Second):
+                    return fmt.Errorf("previous instance of %s has not exited", slug)
+                }
+            }
+        }
+        
         // If process exists but is stopped, we can restart it
         if state == ProcessStopped || state == ProcessFailed {
             return s.startProcess(ps, sv)
@@ -418,17 +665,34 @@ func (s *Supervisor) Start(slug string) error {
 
 // createAndStartProcess creates a new process state and starts the process
 func (s *Supervisor) createAndStartProcess(slug string, sv *registry.Server) error {
+    ps, err := s.newProcState(slug, sv)
+    if err != nil {
+        return err
+    }
+    s.procs[slug] = ps
+    
+    return s.startProcess(ps, sv)
+}
+
+// newProcState returns the stopped state of a new process for sv, with its
+// log file open.
+func (s *Supervisor) newProcState(slug string, sv *registry.Server) (*ProcState, error) {
+    stopSignal, err := sv.Health.ParseStopSignal()
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", slug, err)
+    }
+    
     logsDir, err := paths.LogsDir()
     if err != nil {
-        return fmt.Errorf("failed to get logs directory: %w", err)
+        return nil, fmt.Errorf("failed to get logs directory: %w", err)
     }
     
     logPath := filepath.Join(logsDir, slug+".log")
     
     // Create or open log file
-    logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+    logFile, err := paths.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, paths.FileMode())
     if err != nil {
-        return fmt.Errorf("failed to open log file: %w", err)
+        return nil, fmt.Errorf("failed to open log file: %w", err)
     }
     
     // Create process context
@@ -453,21 +717,22 @@ func (s *Supervisor) createAndStartProcess(slug string, sv *registry.Server) err
         LogFile:       logFile,
         Transport:     sv.Entry.Transport,
         RestartPolicy: restartPolicy,
+        StopSignal:    stopSignal,
+        StopTimeout:   time.Duration(sv.Health.StopTimeoutSec) * time.Second,
         ctx:           ctx,
         cancel:        cancel,
         stopCh:        make(chan struct{}),
         stoppedCh:     make(chan struct{}),
-        healthStopCh:  make(chan struct{}),
         metricsStopCh: make(chan struct{}),
     }
     
     if ps.Transport == "http" {
-        ps.HTTPURL = deriveHTTPURL(sv.Entry.Args, sv.Entry.Env)
+        ps.HTTPURL = registry.DeriveHTTPURL(sv.Entry.Args, sv.Entry.Env)
+    } else {
+        ps.stdio = newStdioBroker(slug)
     }
     
-    s.procs[slug] = ps
-    
-    return s.startProcess(ps, sv)
+    return ps, nil
 }
 
 // startProcess starts or restarts a process
@@ -480,6 +745,22 @@ func (s *Supervisor) startProcess(ps *ProcState, sv *registry.Server) error {
         return nil
     }
     
+    // Reset control channels left closed by a previous run
+    if ps.runDone != nil {
+        atomic.StoreInt32(&ps.Stopping, 0)
+        ps.stopCh = make(chan struct{})
+        ps.stoppedCh = make(chan struct{})
+        ps.metricsStopCh = make(chan struct{})
+    }
+    if ps.LogFile == nil && ps.LogPath != "" {
+        logFile, err := paths.OpenFile(ps.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, paths.FileMode())
+        if err != nil {
+            return fmt.Errorf("failed to open log file: %w", err)
+        }
+        ps.LogFile = logFile
+    }
+    ps.runDone = make(chan struct{})
+    
     // Set state to starting
     ps.State = ProcessStarting
     ps.Status = health.Down
@@ -487,13 +768,13 @@ func (s *Supervisor) startProcess(ps *ProcState, sv *registry.Server) error {
     
     // Start the process management goroutine
     s.wg.Add(1)
-    go s.runProcess(ps, sv)
+    go s.runProcess(ps, sv, ps.runDone)
     
     return nil
 }
 
 // runProcess manages the lifecycle of a single process with proper state management
-func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
+func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server, done chan struct{}) {
     defer s.wg.Done()
     defer func() {
         ps.mu.Lock()
@@ -502,6 +783,7 @@ func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
             ps.LogFile = nil
         }
         ps.mu.Unlock()
+        close(done)
     }()
     
     for {
@@ -519,6 +801,7 @@ func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
             ps.State = ProcessFailed
             ps.Status = health.Down
             ps.mu.Unlock()
+            s.recordState(ps)
             return
         }
         ps.mu.Unlock()
@@ -529,6 +812,7 @@ func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
             ps.mu.Lock()
             ps.State = ProcessRestarting
             ps.mu.Unlock()
+            s.recordState(ps)
             
             select {
             case <-time.After(delay):
@@ -541,14 +825,31 @@ func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
             atomic.AddInt64(&s.totalRestarts, 1)
         }
         
-        // Attempt to start the process
-        if err := s.attemptProcessStart(ps, sv); err != nil {
+        // Run pre-start hooks, then attempt to start the process
+        err := s.runHooks(ps, sv, "pre-start", sv.Entry.PreStart)
+        if err == nil {
+            err = s.attemptProcessStart(ps, sv)
+        }
+        if errors.Is(err, ErrCommandNotAllowed) {
+            // Retrying can't help until the allowlist or the command changes
+            ps.mu.Lock()
+            ps.LaunchError = err.Error()
+            ps.State = ProcessFailed
+            ps.Status = health.Down
+            ps.mu.Unlock()
+            s.recordState(ps)
+            ps.logf("Refusing to start process: %v", err)
+            return
+        }
+        if err != nil {
             ps.mu.Lock()
             ps.State = ProcessFailed
             ps.Status = health.Down
             ps.Restarts++
             ps.RestartsAt = append(ps.RestartsAt, time.Now())
+            ps.noteRestart(RestartCrash, fmt.Sprintf("failed to start: %v", err))
             ps.mu.Unlock()
+            s.recordState(ps)
             
             // Log the error
             if ps.LogFile != nil {
@@ -564,28 +865,47 @@ func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
         ps.State = ProcessRunning
         ps.StartedAt = time.Now()
         ps.PID = ps.Process.Pid
+        process := ps.Process
         ps.mu.Unlock()
+        s.recordState(ps)
+        
+        // A stop that raced with the spawn saw no process to signal
+        if atomic.LoadInt32(&ps.Stopping) == 1 {
+            _ = signalGroup(process, ps.stopSignal())
+        }
         
         // Start monitoring goroutines
         s.startMonitoring(ps)
         
         // Wait for process to exit
-        err := ps.Cmd.Wait()
+        err = ps.Cmd.Wait()
+        s.finishStdio(ps)
         
         // Stop monitoring
         s.stopMonitoring(ps)
         
+        if hookErr := s.runHooks(ps, sv, "post-stop", sv.Entry.PostStop); hookErr != nil {
+            ps.mu.RLock()
+            if ps.LogFile != nil {
+                fmt.Fprintf(ps.LogFile, "[%s] %v\n", time.Now().Format(time.RFC3339), hookErr)
+            }
+            ps.mu.RUnlock()
+        }
+        
         // Update state based on exit
         ps.mu.Lock()
         ps.StoppedAt = time.Now()
         ps.Process = nil
         ps.PID = 0
+        ps.recordExit(err)
+        ps.recordOOMKill()
         
         if atomic.LoadInt32(&ps.Stopping) == 1 {
             // Process was intentionally stopped
             ps.State = ProcessStopped
             ps.Status = health.Down
             ps.mu.Unlock()
+            s.recordState(ps)
             
             // Signal that we've stopped
             select {
@@ -601,17 +921,25 @@ func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
         ps.Status = health.Down
         ps.Restarts++
         ps.RestartsAt = append(ps.RestartsAt, time.Now())
+        if ps.takeMemoryCapHit() {
+            ps.noteRestart(RestartMemory, memoryCapDetail)
+        } else if err != nil {
+            ps.noteRestart(RestartCrash, ps.exitReason())
+        } else {
+            ps.noteRestart(RestartCrash, "exited normally")
+        }
         
         if ps.LogFile != nil {
             if err != nil {
-                fmt.Fprintf(ps.LogFile, "[%s] Process exited with error: %v\n", 
-                    time.Now().Format(time.RFC3339), err)
+                fmt.Fprintf(ps.LogFile, "[%s] Process exited with error: %v (%s)\n", 
+                    time.Now().Format(time.RFC3339), err, ps.exitReason())
             } else {
                 fmt.Fprintf(ps.LogFile, "[%s] Process exited normally\n", 
                     time.Now().Format(time.RFC3339))
             }
         }
         ps.mu.Unlock()
+        s.recordState(ps)
         
         // Check if we should restart based on policy
         if ps.RestartPolicy.Policy == "never" || 
@@ -619,6 +947,7 @@ func (s *Supervisor) runProcess(ps *ProcState, sv *registry.Server) {
             ps.mu.Lock()
             ps.State = ProcessStopped
             ps.mu.Unlock()
+            s.recordState(ps)
             return
         }
         
@@ -631,22 +960,22 @@ func (s *Supervisor) attemptProcessStart(ps *ProcState, sv *registry.Server) err
     ps.mu.Lock()
     defer ps.mu.Unlock()
     
+    ps.LaunchError = ""
+    if err := s.checkCommandAllowed(sv); err != nil {
+        ps.LaunchError = err.Error()
+        return err
+    }
+    
     // Create command
+    ps.launchHash = launchHash(sv)
     cmd := exec.CommandContext(ps.ctx, sv.Entry.Command, sv.Entry.Args...)
     
-    // Set working directory if specified
-    if srvDir, _ := paths.ServersDir(); srvDir != "" {
-        cmd.Dir = filepath.Join(srvDir, ps.Slug)
-    }
-    
-    // Set environment variables
-    if len(sv.Entry.Env) > 0 {
-        env := os.Environ()
-        for key, value := range sv.Entry.Env {
-            env = append(env, fmt.Sprintf("%s=%s", key, value))
-        }
-        cmd.Env = env
+    cmd.Dir = serverDir(ps.Slug)
+    env, err := s.serverEnv(sv)
+    if err != nil {
+        return err
     }
+    cmd.Env = env
     
     // Set up logging
     if ps.LogFile != nil {
@@ -658,17 +987,265 @@ func (s *Supervisor) attemptProcessStart(ps *ProcState, sv *registry.Server) err
             time.Now().Format(time.RFC3339), sv.Entry.Command, sv.Entry.Args)
     }
     
+    // Memory and CPU limits go in a cgroup where one can be created; file
+    // descriptors, and memory without a cgroup, are capped by rlimits
+    cg, cgErr := newCgroup(ps.Slug, sv.Limits)
+    if cgErr != nil && ps.LogFile != nil {
+        fmt.Fprintf(ps.LogFile, "[%s] cgroup limits unavailable, %s: %v\n",
+            time.Now().Format(time.RFC3339), cgroupFallback(sv.Limits), cgErr)
+    }
+    // The rlimits and the scheduling priority are set by a shell wrapper
+    steps := rlimitSteps(sv.Limits, cg != nil && sv.Limits.MaxMemoryMB > 0)
+    wrapLaunch(cmd, append(steps, prioritySteps(sv.Priority)...)...)
+    newProcessGroup(cmd)
+    releaseCgroup, err := cg.attach(cmd)
+    if err != nil {
+        cg.remove()
+        return fmt.Errorf("failed to open cgroup: %w", err)
+    }
+    
+    // Stdio servers speak MCP over their pipes; stdout reaches the log
+    // through the broker instead of directly
+    var stdin io.WriteCloser
+    var stdoutR, stdoutW *os.File
+    if ps.stdio != nil {
+        if stdin, err = cmd.StdinPipe(); err != nil {
+            return fmt.Errorf("failed to open stdin: %w", err)
+        }
+        if stdoutR, stdoutW, err = os.Pipe(); err != nil {
+            return fmt.Errorf("failed to open stdout: %w", err)
+        }
+        cmd.Stdout = stdoutW
+    }
+    
     // Start the process
-    if err := cmd.Start(); err != nil {
+    err = cmd.Start()
+    releaseCgroup()
+    if err != nil {
+        cg.remove()
+        if stdoutR != nil {
+            stdoutR.Close()
+            stdoutW.Close()
+        }
         return fmt.Errorf("failed to start command: %w", err)
     }
     
     ps.Cmd = cmd
     ps.Process = cmd.Process
+    ps.cgroup = cg
+    
+    if ps.stdio != nil {
+        stdoutW.Close()
+        var logw io.Writer = io.Discard
+        if ps.LogFile != nil {
+            logw = ps.LogFile
+        }
+        ps.stdoutPipe = stdoutR
+        ps.pumpDone = make(chan struct{})
+        ps.stdio.bind(stdin)
+        go ps.stdio.pump(stdoutR, logw, ps.pumpDone)
+    }
     
     return nil
 }
 
+// finishStdio waits for an exited stdio server's output to be drained into
+// its log, then disconnects any attached clients.
+func (s *Supervisor) finishStdio(ps *ProcState) {
+    ps.mu.RLock()
+    broker, pipe, done := ps.stdio, ps.stdoutPipe, ps.pumpDone
+    ps.mu.RUnlock()
+    if broker == nil || done == nil {
+        return
+    }
+    
+    select {
+    case <-done:
+    case <-time.After(stdioDrainTimeout):
+        // A child that inherited stdout is holding the pipe open
+        pipe.Close()
+        <-done
+    }
+    broker.unbind()
+}
+
+// Attach connects a client to the stdin and stdout of the running stdio
+// server slug. The returned stream carries newline-delimited JSON-RPC
+// messages both ways and is shared with other attached clients as described
+// on stdioBroker. Requests are subject to the server's rpcPolicy. Closing
+// the stream detaches the client and leaves the server running. It implements
+// health.StdioAttacher for the health monitor's handshake probe.
+func (s *Supervisor) Attach(slug string) (io.ReadWriteCloser, error) {
+    s.mu.RLock()
+    ps := s.procs[slug]
+    var policy *registry.RPCPolicy
+    if sv := s.findServer(slug); sv != nil {
+        policy = sv.RPC
+    }
+    s.mu.RUnlock()
+    if ps == nil {
+        return nil, fmt.Errorf("server %s is not running", slug)
+    }
+    
+    ps.mu.RLock()
+    broker, state := ps.stdio, ps.State
+    ps.mu.RUnlock()
+    if broker == nil {
+        return nil, fmt.Errorf("server %s does not use stdio transport", slug)
+    }
+    if state != ProcessRunning {
+        return nil, fmt.Errorf("server %s is not running", slug)
+    }
+    
+    c, err := broker.attach(policy)
+    if err != nil {
+        return nil, fmt.Errorf("server %s: %w", slug, err)
+    }
+    return c, nil
+}
+
+// serverDir returns the working directory for a server's processes and hooks.
+func serverDir(slug string) string {
+    if srvDir, _ := paths.ServersDir(); srvDir != "" {
+        return filepath.Join(srvDir, slug)
+    }
+    return ""
+}
+
+// validateLaunch checks that sv is a local server with a command that can be
+// executed. A missing command is only tolerated when pre-start hooks exist,
+// since a hook may build or fetch it.
+func validateLaunch(sv *registry.Server) error {
+    if sv.IsExternal() {
+        return fmt.Errorf("%s is an external server and is not started by the supervisor", sv.Slug)
+    }
+    if missing := sv.Entry.MissingEnv(); len(missing) > 0 {
+        return fmt.Errorf("server %s is missing required env: %s", sv.Slug, strings.Join(missing, ", "))
+    }
+    if strings.TrimSpace(sv.Entry.Command) == "" {
+        return fmt.Errorf("server %s has no command configured", sv.Slug)
+    }
+    if len(sv.Entry.PreStart) > 0 {
+        return nil
+    }
+    if err := registry.CheckCommand(sv.Entry.Command, serverDir(sv.Slug)); err != nil {
+        return fmt.Errorf("server %s: %w", sv.Slug, err)
+    }
+    return nil
+}
+
+// SetSecretSource makes vault:// env values resolve against src.
+func (s *Supervisor) SetSecretSource(src SecretSource) {
+    s.secretsMu.Lock()
+    defer s.secretsMu.Unlock()
+    s.secrets = src
+}
+
+// serverEnv returns the environment for a server's processes and hooks, or
+// nil to inherit the manager's environment unchanged. It starts from the part
+// of the manager's environment the InheritEnv policy lets through, and
+// vault:// values are replaced by the secrets they refer to.
+func (s *Supervisor) serverEnv(sv *registry.Server) ([]string, error) {
+    inherited := inheritedEnv(sv.Entry.InheritEnv, sv.Entry.InheritVars)
+    if len(sv.Entry.Env) == 0 {
+        return inherited, nil
+    }
+    s.secretsMu.RLock()
+    secrets := s.secrets
+    s.secretsMu.RUnlock()
+    
+    items := map[string]map[string]string{}
+    env := inherited
+    if env == nil {
+        env = os.Environ()
+    }
+    for key, value := range sv.Entry.Env {
+        if ref, item, ok := registry.ParseVaultRef(value); ok {
+            if secrets == nil {
+                return nil, fmt.Errorf("env %s refers to the vault, which is not available", key)
+            }
+            if items[ref] == nil {
+                stored, err := secrets.Retrieve(ref)
+                if err != nil {
+                    return nil, fmt.Errorf("env %s: %w", key, err)
+                }
+                items[ref] = stored
+            }
+            resolved, found := items[ref][item]
+            if !found {
+                return nil, fmt.Errorf("env %s: vault item %s has no key %s", key, ref, item)
+            }
+            value = resolved
+        }
+        env = append(env, fmt.Sprintf("%s=%s", key, value))
+    }
+    return env, nil
+}
+
+// inheritedEnv returns the manager's environment filtered by an InheritEnv
+// policy, or nil when the policy passes everything. PATH and HOME always get
+// through, so commands still resolve and tools find their config.
+func inheritedEnv(policy string, vars []string) []string {
+    if policy == "" || policy == registry.InheritAll {
+        return nil
+    }
+    names := []string{"PATH", "HOME"}
+    if policy == registry.InheritAllowlist {
+        names = append(names, vars...)
+    }
+    env := []string{}
+    seen := map[string]bool{}
+    for _, name := range names {
+        if value, ok := os.LookupEnv(name); ok && !seen[name] {
+            env = append(env, name+"="+value)
+        }
+        seen[name] = true
+    }
+    return env
+}
+
+// runHooks runs each hook command through sh with the server's env and working
+// directory, copying its output into the server log under a [stage] prefix.
+// It stops at the first hook that fails or exceeds hookTimeout. While a
+// command allowlist is set hooks are refused, since what a shell line runs
+// can't be checked against it.
+func (s *Supervisor) runHooks(ps *ProcState, sv *registry.Server, stage string, hooks []string) error {
+    if len(hooks) > 0 && len(s.commandAllowlist()) > 0 {
+        return fmt.Errorf("%w: %s hooks run through sh", ErrCommandNotAllowed, stage)
+    }
+    for _, hook := range hooks {
+        env, err := s.serverEnv(sv)
+        if err != nil {
+            return fmt.Errorf("%s hook %q: %w", stage, hook, err)
+        }
+        ctx, cancel := context.WithTimeout(ps.ctx, hookTimeout)
+        cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+        cmd.Dir = serverDir(ps.Slug)
+        cmd.Env = env
+        out, err := cmd.CombinedOutput()
+        if ctx.Err() == context.DeadlineExceeded {
+            err = fmt.Errorf("timed out after %s", hookTimeout)
+        }
+        cancel()
+        
+        ps.mu.RLock()
+        if ps.LogFile != nil {
+            fmt.Fprintf(ps.LogFile, "[%s] [%s] %s\n", time.Now().Format(time.RFC3339), stage, hook)
+            for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+                if line != "" {
+                    fmt.Fprintf(ps.LogFile, "[%s] %s\n", stage, line)
+                }
+            }
+        }
+        ps.mu.RUnlock()
+        
+        if err != nil {
+            return fmt.Errorf("%s hook %q failed: %w", stage, hook, err)
+        }
+    }
+    return nil
+}
+
 // calculateBackoff calculates the backoff delay for restarts
 func (s *Supervisor) calculateBackoff(restarts int, policy RestartPolicy) time.Duration {
     if restarts <= 0 {
@@ -688,26 +1265,15 @@ func (s *Supervisor) calculateBackoff(restarts int, policy RestartPolicy) time.D
     return delay
 }
 
-// startMonitoring starts health and metrics monitoring for a process
+// startMonitoring starts metrics monitoring for a process. Health checks are
+// run by the HealthSource, not here.
 func (s *Supervisor) startMonitoring(ps *ProcState) {
-    // Start health monitoring
     s.wg.Add(1)
-    go s.healthMonitor(ps)
-    
-    // Start metrics monitoring
-    s.wg.Add(1)
-    go s.metricsMonitor(ps)
+    go s.metricsMonitor(ps, ps.metricsStopCh)
 }
 
 // stopMonitoring stops monitoring for a process
 func (s *Supervisor) stopMonitoring(ps *ProcState) {
-    // Signal health monitor to stop
-    select {
-    case <-ps.healthStopCh:
-    default:
-        close(ps.healthStopCh)
-    }
-    
     // Signal metrics monitor to stop
     select {
     case <-ps.metricsStopCh:
@@ -716,136 +1282,48 @@ func (s *Supervisor) stopMonitoring(ps *ProcState) {
     }
 }
 
-// healthMonitor continuously monitors the health of a process
-func (s *Supervisor) healthMonitor(ps *ProcState) {
+// metricsMonitor continuously collects metrics for a process
+func (s *Supervisor) metricsMonitor(ps *ProcState, stopCh chan struct{}) {
     defer s.wg.Done()
     
-    // Determine health check interval (default 20 seconds)
-    interval := 20 * time.Second
-    if sv := s.findServer(ps.Slug); sv != nil && sv.Health.IntervalSec > 0 {
-        interval = time.Duration(sv.Health.IntervalSec) * time.Second
+    if s.statsUnsupported.Load() {
+        s.markStatsUnsupported(ps)
+        return
     }
     
-    ticker := time.NewTicker(interval)
+    ticker := time.NewTicker(5 * time.Second)
     defer ticker.Stop()
     
     for {
         select {
-        case <-ps.healthStopCh:
+        case <-stopCh:
             return
         case <-ps.ctx.Done():
             return
         case <-ticker.C:
-            s.performHealthCheck(ps)
+            if !s.sampleProcessStats(ps) {
+                s.markStatsUnsupported(ps)
+                return
+            }
+            s.enforceMemoryCap(ps)
         }
     }
 }
 
-// performHealthCheck performs a single health check on a process
-func (s *Supervisor) performHealthCheck(ps *ProcState) {
-    ps.mu.Lock()
-    if ps.State != ProcessRunning {
-        ps.mu.Unlock()
-        return
-    }
-    
-    transport := ps.Transport
-    httpURL := ps.HTTPURL
-    logPath := ps.LogPath
-    handshakeReady := ps.HandshakeReady
-    ps.mu.Unlock()
-    
-    // Check for handshake readiness if not already ready
-    if !handshakeReady {
-        if ok, _ := logContains(logPath, []string{"notifications/initialized", "initialized"}); ok {
-            ps.mu.Lock()
-            ps.HandshakeReady = true
-            handshakeReady = true
-            ps.mu.Unlock()
-        }
-    }
+// markStatsUnsupported stops reporting CPU and memory for ps, and for every
+// process started later, as this environment can't measure them.
+func (s *Supervisor) markStatsUnsupported(ps *ProcState) {
+    s.statsUnsupported.Store(true)
+    s.statsOnce.Do(func() {
+        log.Printf("Process CPU/memory sampling is unavailable (%s failed); reporting these metrics as unsupported", s.statsCmd)
+    })
     
-    var pingError error
-    var pingTime time.Duration
-    
-    if transport == "http" && httpURL != "" {
-        // HTTP health check
-        start := time.Now()
-        client := &http.Client{Timeout: 3 * time.Second}
-        resp, err := client.Get(httpURL)
-        pingTime = time.Since(start)
-        pingError = err
-        
-        if resp != nil {
-            resp.Body.Close()
-        }
-    } else {
-        // For stdio transport, use log activity as health indicator
-        if info, err := os.Stat(logPath); err == nil {
-            ps.mu.Lock()
-            if info.ModTime().After(ps.LastLogAt) {
-                ps.LastLogAt = info.ModTime()
-                ps.MissedPings = 0
-                ps.LastPingMs = 0
-            } else {
-                ps.MissedPings++
-                if ps.LastLogAt.IsZero() {
-                    ps.LastLogAt = time.Now()
-                }
-                ps.LastPingMs = int(time.Since(ps.LastLogAt).Milliseconds())
-            }
-            ps.mu.Unlock()
-        }
-        return // Skip the rest for stdio transport
-    }
-    
-    // Update ping results
     ps.mu.Lock()
-    if pingError == nil {
-        ps.LastPingMs = int(pingTime.Milliseconds())
-        ps.MissedPings = 0
-    } else {
-        ps.MissedPings++
-    }
-    
-    // Evaluate health status
-    in := health.ProbeInput{
-        ProcessRunning:  ps.State == ProcessRunning,
-        MissedPings:     ps.MissedPings,
-        LastPingMs:      ps.LastPingMs,
-        RestartsLast10m: len(ps.RestartsAt), // Simplified for now
-    }
-    
-    status := health.Evaluate(in)
-    
-    // Gate ready status until handshake is complete
-    if !ps.HandshakeReady && status == health.Ready {
-        status = health.Degraded
-    }
-    
-    ps.Status = status
+    ps.StatsUnsupported = true
+    ps.CPUPercent, ps.RSSBytes = 0, 0
     ps.mu.Unlock()
 }
 
-// metricsMonitor continuously collects metrics for a process
-func (s *Supervisor) metricsMonitor(ps *ProcState) {
-    defer s.wg.Done()
-    
-    ticker := time.NewTicker(5 * time.Second)
-    defer ticker.Stop()
-    
-    for {
-        select {
-        case <-ps.metricsStopCh:
-            return
-        case <-ps.ctx.Done():
-            return
-        case <-ticker.C:
-            s.sampleProcessStats(ps)
-        }
-    }
-}
-
 // findServer finds a server configuration by slug
 func (s *Supervisor) findServer(slug string) *registry.Server {
     for i := range s.reg.Servers {
@@ -856,29 +1334,31 @@ func (s *Supervisor) findServer(slug string) *registry.Server {
     return nil
 }
 
-// sampleProcessStats updates CPUPercent and RSSBytes using `ps` if available.
-func (s *Supervisor) sampleProcessStats(ps *ProcState) {
+// sampleProcessStats updates CPUPercent and RSSBytes using `ps`. It returns
+// false when the stats can't be collected here: `ps` is missing, or fails or
+// prints something unexpected for a process that is still running.
+func (s *Supervisor) sampleProcessStats(ps *ProcState) bool {
     ps.mu.RLock()
     process := ps.Process
     ps.mu.RUnlock()
     
     if process == nil {
-        return
+        return true
     }
     
     pid := process.Pid
     
     // ps -o pid=,pcpu=,rss= -p <pid>
-    out, err := exec.Command("ps", "-o", "pid=,pcpu=,rss=", "-p", fmt.Sprint(pid)).Output()
+    out, err := exec.Command(s.statsCmd, "-o", "pid=,pcpu=,rss=", "-p", fmt.Sprint(pid)).Output()
     if err != nil {
-        // Process might have exited
-        return
+        // ps also fails for a pid that has just exited, which says nothing about support
+        return !errors.Is(err, exec.ErrNotFound) && process.Signal(syscall.Signal(0)) != nil
     }
     
     // Expected: " 12345  1.2  54321\n"
     fields := strings.Fields(string(out))
     if len(fields) < 3 {
-        return
+        return false
     }
     
     cpu, _ := strconv.ParseFloat(fields[1], 64)
@@ -888,12 +1368,17 @@ func (s *Supervisor) sampleProcessStats(ps *ProcState) {
     ps.CPUPercent = cpu
     ps.RSSBytes = rssKB * 1024
     ps.mu.Unlock()
+    return true
 }
 
 func (s *Supervisor) restartsInLast(ps *ProcState, win time.Duration) int {
     ps.mu.Lock()
     defer ps.mu.Unlock()
-    
+    return countRestarts(ps, win)
+}
+
+// countRestarts is restartsInLast for callers that already hold ps.mu.
+func countRestarts(ps *ProcState, win time.Duration) int {
     cutoff := time.Now().Add(-win)
     n := 0
     // also trim old entries to avoid growth
@@ -908,49 +1393,21 @@ func (s *Supervisor) restartsInLast(ps *ProcState, win time.Duration) int {
     return n
 }
 
-// deriveHTTPURL tries to construct a local HTTP URL from args or env.
-// Priority: env[HEALTH_HTTP_URL], then --port=NNNN or -p NNNN in args → http://127.0.0.1:NNNN
-func deriveHTTPURL(args []string, env map[string]string) string {
-    if env != nil {
-        if u, ok := env["HEALTH_HTTP_URL"]; ok && u != "" { return u }
-    }
-    var port string
-    for i := 0; i < len(args); i++ {
-        a := args[i]
-        if strings.HasPrefix(a, "--port=") {
-            port = strings.TrimPrefix(a, "--port=")
-            break
-        }
-        if a == "-p" && i+1 < len(args) { port = args[i+1]; break }
-    }
-    if port != "" { return "http://127.0.0.1:" + port }
-    return ""
-}
-
-// logContains returns true if the end of the file contains any of the substrings.
-func logContains(path string, subs []string) (bool, error) {
-    f, err := os.Open(path)
-    if err != nil { return false, err }
-    defer f.Close()
-    const maxRead = 64 * 1024
-    fi, err := f.Stat(); if err != nil { return false, err }
-    size := fi.Size()
-    off := size - maxRead
-    if off < 0 { off = 0 }
-    buf := make([]byte, size-off)
-    if _, err := f.ReadAt(buf, off); err != nil && err != io.EOF { return false, err }
-    s := string(buf)
-    for _, sub := range subs {
-        if strings.Contains(s, sub) { return true, nil }
-    }
-    return false, nil
-}
-
 func (s *Supervisor) Stop(slug string, graceful time.Duration) error {
     return s.stopProcess(slug, graceful)
 }
 
-// stopProcess stops a process with the given timeout for graceful shutdown
+// stopSignal is the signal that asks ps to exit. StopSignal is fixed when ps
+// is created, so no lock is needed.
+func (ps *ProcState) stopSignal() syscall.Signal {
+    if ps.StopSignal == 0 {
+        return syscall.SIGTERM
+    }
+    return ps.StopSignal
+}
+
+// stopProcess stops a process with its stop signal, then SIGKILL once it has
+// had its stop timeout, or graceful for a server that sets none, to exit.
 func (s *Supervisor) stopProcess(slug string, graceful time.Duration) error {
     s.mu.RLock()
     ps := s.procs[slug]
@@ -972,9 +1429,11 @@ func (s *Supervisor) stopProcess(slug string, graceful time.Duration) error {
     ps.Status = health.Down
     
     process := ps.Process
-    ps.mu.Unlock()
-    
-    atomic.AddInt64(&s.totalStops, 1)
+    stoppedCh := ps.stoppedCh
+    stopSig := ps.stopSignal()
+    if ps.StopTimeout > 0 {
+        graceful = ps.StopTimeout
+    }
     
     // Signal the process to stop
     select {
@@ -983,50 +1442,68 @@ func (s *Supervisor) stopProcess(slug string, graceful time.Duration) error {
     default:
         close(ps.stopCh)
     }
+    ps.mu.Unlock()
+    s.recordState(ps)
+    
+    atomic.AddInt64(&s.totalStops, 1)
     
     if process == nil {
         // No actual process to stop
         ps.mu.Lock()
         ps.State = ProcessStopped
         ps.mu.Unlock()
+        s.recordState(ps)
         return nil
     }
     
-    // Send SIGTERM for graceful shutdown
-    if err := process.Signal(syscall.SIGTERM); err != nil {
+    // Ask the process to exit
+    if err := signalGroup(process, stopSig); err != nil {
         // Process might have already exited
         ps.mu.Lock()
         ps.State = ProcessStopped
         ps.mu.Unlock()
+        s.recordState(ps)
         return nil
     }
     
     // Wait for graceful shutdown with timeout
     select {
-    case <-ps.stoppedCh:
+    case <-stoppedCh:
         // Process stopped gracefully
         return nil
     case <-time.After(graceful):
         // Timeout exceeded, force kill
-        if err := process.Kill(); err != nil {
+        if err := signalGroup(process, syscall.SIGKILL); err != nil {
             // Process might have already exited
         }
         
         // Wait a bit more for the kill to take effect
         select {
-        case <-ps.stoppedCh:
+        case <-stoppedCh:
         case <-time.After(5 * time.Second):
         }
         
         ps.mu.Lock()
         ps.State = ProcessStopped
         ps.mu.Unlock()
+        s.recordState(ps)
         
         return nil
     }
 }
 
+// Restart stops and starts the process for slug and counts it as a manual
+// restart. It shares the in-flight guard with Start, so a concurrent Start
+// and Restart spawn only once.
 func (s *Supervisor) Restart(slug string) error {
+    if err := s.collapse(slug, func() error { return s.restart(slug) }); err != nil {
+        return err
+    }
+    s.recordRestart(slug, RestartManual, "requested")
+    return nil
+}
+
+func (s *Supervisor) restart(slug string) error {
     // Stop the process with a reasonable timeout
     if err := s.Stop(slug, 10*time.Second); err != nil {
         return fmt.Errorf("failed to stop process %s: %w", slug, err)
@@ -1036,7 +1513,7 @@ func (s *Supervisor) Restart(slug string) error {
     time.Sleep(100 * time.Millisecond)
     
     // Start the process again
-    if err := s.Start(slug); err != nil {
+    if err := s.start(slug); err != nil {
         return fmt.Errorf("failed to start process %s after restart: %w", slug, err)
     }
     
@@ -1,8 +1,19 @@
 package supervisor
 
 import (
+    "context"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
     "testing"
     "time"
+
+    "mcp/manager/internal/paths"
+    "mcp/manager/internal/registry"
 )
 
 func TestRestartsInLast(t *testing.T) {
@@ -17,11 +28,429 @@ func TestRestartsInLast(t *testing.T) {
     if len(ps.RestartsAt) != 2 { t.Fatalf("kept %d", len(ps.RestartsAt)) }
 }
 
-func TestDeriveHTTPURL(t *testing.T) {
-    u := deriveHTTPURL([]string{"--port=8080"}, nil)
-    if u != "http://127.0.0.1:8080" { t.Fatalf("got %s", u) }
-    u = deriveHTTPURL([]string{"-p", "3000"}, nil)
-    if u != "http://127.0.0.1:3000" { t.Fatalf("got %s", u) }
-    u = deriveHTTPURL(nil, map[string]string{"HEALTH_HTTP_URL": "http://127.0.0.1:9090/health"})
-    if u != "http://127.0.0.1:9090/health" { t.Fatalf("got %s", u) }
+func TestConcurrentStartRestartSpawnsOnce(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "sleeper"), 0o755); err != nil { t.Fatal(err) }
+    pidFile := filepath.Join(home, "pids")
+    
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Name: "Sleeper",
+        Slug: "sleeper",
+        Entry: registry.Entry{
+            Transport: "stdio",
+            Command:   "sh",
+            Args:      []string{"-c", "echo $$ >> " + pidFile + "; exec sleep 30"},
+        },
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+    }}}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(2)
+        go func() { defer wg.Done(); _ = sup.Start("sleeper") }()
+        go func() { defer wg.Done(); _ = sup.Restart("sleeper") }()
+    }
+    wg.Wait()
+    
+    // Wait for the surviving process to come up
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        if state, _ := sup.GetProcessState("sleeper"); state == ProcessRunning { break }
+        if time.Now().After(deadline) { t.Fatal("process never reached running") }
+        time.Sleep(20 * time.Millisecond)
+    }
+    time.Sleep(200 * time.Millisecond)
+    
+    data, err := os.ReadFile(pidFile)
+    if err != nil { t.Fatal(err) }
+    alive := 0
+    for _, line := range strings.Fields(string(data)) {
+        pid, err := strconv.Atoi(line)
+        if err != nil { t.Fatal(err) }
+        if syscall.Kill(pid, 0) == nil { alive++ }
+    }
+    if alive != 1 { t.Fatalf("want exactly 1 child alive, got %d (spawned: %q)", alive, data) }
+}
+
+func TestPreStartHooks(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    for _, slug := range []string{"hooked", "broken"} {
+        if err := os.MkdirAll(filepath.Join(srvDir, slug), 0o755); err != nil { t.Fatal(err) }
+    }
+    
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+        {
+            Slug: "hooked",
+            Entry: registry.Entry{
+                Transport: "stdio",
+                Command:   "sh",
+                Args:      []string{"-c", "test -f migrated && exec sleep 30"},
+                Env:       map[string]string{"HOOK_MSG": "migrating"},
+                PreStart:  []string{"echo $HOOK_MSG; touch migrated"},
+            },
+            Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+        },
+        {
+            Slug: "broken",
+            Entry: registry.Entry{
+                Transport: "stdio",
+                Command:   "sleep",
+                Args:      []string{"30"},
+                PreStart:  []string{"echo refresh failed; exit 3"},
+            },
+            Health: registry.Health{IntervalSec: 60, TimeoutSec: 5, MaxRestarts: 1},
+        },
+    }}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    
+    for _, slug := range []string{"hooked", "broken"} {
+        if err := sup.Start(slug); err != nil { t.Fatal(err) }
+    }
+    
+    waitFor := func(slug string, want ProcessState) {
+        t.Helper()
+        deadline := time.Now().Add(5 * time.Second)
+        for {
+            if state, _ := sup.GetProcessState(slug); state == want { return }
+            if time.Now().After(deadline) { t.Fatalf("%s never reached %s", slug, want) }
+            time.Sleep(20 * time.Millisecond)
+        }
+    }
+    waitFor("hooked", ProcessRunning)
+    waitFor("broken", ProcessFailed)
+    
+    logsDir, _ := paths.LogsDir()
+    data, _ := os.ReadFile(filepath.Join(logsDir, "hooked.log"))
+    if !strings.Contains(string(data), "[pre-start] migrating") { t.Fatalf("hook output missing from log:\n%s", data) }
+    data, _ = os.ReadFile(filepath.Join(logsDir, "broken.log"))
+    if !strings.Contains(string(data), "[pre-start] refresh failed") || strings.Contains(string(data), "Starting process") {
+        t.Fatalf("failed hook should block the start:\n%s", data)
+    }
+}
+
+func TestStartRejectsUnlaunchableServers(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "local", "bin"), 0o755); err != nil { t.Fatal(err) }
+    if err := os.WriteFile(filepath.Join(srvDir, "local", "bin", "notes.txt"), []byte("x"), 0o644); err != nil { t.Fatal(err) }
+    
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{
+        {Slug: "cloud", External: &registry.ExternalInfo{Provider: "notion"}},
+        {Slug: "empty", Entry: registry.Entry{Transport: "stdio"}},
+        {Slug: "missing", Entry: registry.Entry{Transport: "stdio", Command: "definitely-not-a-real-mcp-binary"}},
+        {Slug: "absent", Entry: registry.Entry{Transport: "stdio", Command: "/nonexistent/server"}},
+        {Slug: "local", Entry: registry.Entry{Transport: "stdio", Command: "bin/notes.txt"}},
+        {Slug: "unset", Entry: registry.Entry{Transport: "stdio", Command: "sh", RequiredEnv: []registry.RequiredEnv{{Key: "API_URL"}}}},
+    }}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    
+    tests := []struct{ slug, want string }{
+        {"cloud", "external server"},
+        {"empty", "no command configured"},
+        {"missing", "not found on PATH"},
+        {"absent", "no such file"},
+        {"local", "not an executable file"},
+        {"unset", "missing required env: API_URL"},
+    }
+    for _, tt := range tests {
+        err := sup.Start(tt.slug)
+        if err == nil || !strings.Contains(err.Error(), tt.want) {
+            t.Errorf("Start(%s) = %v, want error containing %q", tt.slug, err, tt.want)
+        }
+        if _, exists := sup.GetProcessState(tt.slug); exists {
+            t.Errorf("Start(%s) created process state despite failing validation", tt.slug)
+        }
+    }
+}
+
+type secretMap map[string]map[string]string
+
+func (m secretMap) Retrieve(ref string) (map[string]string, error) {
+    if v, ok := m[ref]; ok { return v, nil }
+    return nil, os.ErrNotExist
+}
+
+func TestVaultEnvResolvedAtStart(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "vaulted"), 0o755); err != nil { t.Fatal(err) }
+    
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Slug: "vaulted",
+        Entry: registry.Entry{
+            Transport:   "stdio",
+            Command:     "sh",
+            Args:        []string{"-c", "echo token=$TOKEN; exec sleep 30"},
+            Env:         map[string]string{"TOKEN": registry.VaultRef("env:vaulted", "TOKEN")},
+            RequiredEnv: []registry.RequiredEnv{{Key: "TOKEN", Secret: true}},
+        },
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+    }}}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    sup.SetSecretSource(secretMap{"env:vaulted": {"TOKEN": "s3cret"}})
+    
+    if err := sup.Start("vaulted"); err != nil { t.Fatal(err) }
+    logsDir, _ := paths.LogsDir()
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        data, _ := os.ReadFile(filepath.Join(logsDir, "vaulted.log"))
+        if strings.Contains(string(data), "token=s3cret") { break }
+        if time.Now().After(deadline) { t.Fatalf("secret not injected:\n%s", data) }
+        time.Sleep(20 * time.Millisecond)
+    }
+}
+
+func TestShutdownGivesEachProcessFullGrace(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    
+    // Each server takes a second to clean up after SIGTERM and records that it finished
+    const n = 4
+    reg := &registry.Registry{Version: "1.0"}
+    for i := 0; i < n; i++ {
+        slug := "slow-" + strconv.Itoa(i)
+        if err := os.MkdirAll(filepath.Join(srvDir, slug), 0o755); err != nil { t.Fatal(err) }
+        marker := filepath.Join(home, slug+".done")
+        reg.Servers = append(reg.Servers, registry.Server{
+            Name: slug,
+            Slug: slug,
+            Entry: registry.Entry{
+                Transport: "stdio",
+                Command:   "sh",
+                Args:      []string{"-c", "trap 'sleep 1; touch " + marker + "; exit 0' TERM; while :; do sleep 0.1; done"},
+            },
+            Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+        })
+    }
+    sup := New(reg, 0, 0)
+    for _, sv := range reg.Servers {
+        if err := sup.Start(sv.Slug); err != nil { t.Fatal(err) }
+    }
+    deadline := time.Now().Add(5 * time.Second)
+    for _, sv := range reg.Servers {
+        for {
+            if state, _ := sup.GetProcessState(sv.Slug); state == ProcessRunning { break }
+            if time.Now().After(deadline) { t.Fatalf("%s never reached running", sv.Slug) }
+            time.Sleep(20 * time.Millisecond)
+        }
+    }
+    
+    // A quarter of the budget each would be too short for the cleanup
+    start := time.Now()
+    if err := sup.Shutdown(3 * time.Second); err != nil { t.Fatal(err) }
+    if elapsed := time.Since(start); elapsed > 2500*time.Millisecond {
+        t.Fatalf("shutdown took %s", elapsed)
+    }
+    for _, sv := range reg.Servers {
+        if _, err := os.Stat(filepath.Join(home, sv.Slug+".done")); err != nil {
+            t.Errorf("%s was killed before finishing its cleanup", sv.Slug)
+        }
+    }
+}
+
+func TestStopUsesConfiguredSignal(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "interrupt"), 0o755); err != nil { t.Fatal(err) }
+    
+    // Only SIGINT makes this server clean up; it takes longer than the caller's grace
+    marker := filepath.Join(home, "interrupted")
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Name: "Interrupt",
+        Slug: "interrupt",
+        Entry: registry.Entry{
+            Transport: "stdio",
+            Command:   "sh",
+            Args:      []string{"-c", "trap '' TERM; trap 'sleep 0.5; touch " + marker + "; exit 0' INT; while :; do sleep 0.1; done"},
+        },
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5, StopSignal: "SIGINT", StopTimeoutSec: 5},
+    }}}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    if err := sup.Start("interrupt"); err != nil { t.Fatal(err) }
+    deadline := time.Now().Add(5 * time.Second)
+    for {
+        if state, _ := sup.GetProcessState("interrupt"); state == ProcessRunning { break }
+        if time.Now().After(deadline) { t.Fatal("process never reached running") }
+        time.Sleep(20 * time.Millisecond)
+    }
+    
+    if err := sup.Stop("interrupt", 100*time.Millisecond); err != nil { t.Fatal(err) }
+    if _, err := os.Stat(marker); err != nil { t.Fatalf("server was not stopped with SIGINT: %v", err) }
+    if state, _ := sup.GetProcessState("interrupt"); state != ProcessStopped { t.Fatalf("state %s", state) }
+}
+
+// processAlive reports whether pid is running. A zombie counts as gone: an
+// orphan's new parent may be slow to reap it.
+func processAlive(pid int) bool {
+    if stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat")); err == nil {
+        fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+        return len(fields) > 0 && fields[0] != "Z"
+    }
+    return syscall.Kill(pid, 0) == nil
+}
+
+func TestStopKillsProcessGroup(t *testing.T) {
+    home := t.TempDir()
+    t.Setenv("HOME", home)
+    t.Setenv("MCP_HOME", "")
+    srvDir, err := paths.ServersDir()
+    if err != nil { t.Fatal(err) }
+    if err := os.MkdirAll(filepath.Join(srvDir, "forker"), 0o755); err != nil { t.Fatal(err) }
+    
+    // A launcher that forks the real server and waits on it, as venv shims may
+    childFile := filepath.Join(home, "child")
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{
+        Name: "Forker",
+        Slug: "forker",
+        Entry: registry.Entry{
+            Transport: "stdio",
+            Command:   "sh",
+            Args:      []string{"-c", "sleep 30 & echo $! > " + childFile + "; wait"},
+        },
+        Health: registry.Health{IntervalSec: 60, TimeoutSec: 5},
+    }}}
+    sup := New(reg, 0, 0)
+    defer sup.Shutdown(time.Second)
+    if err := sup.Start("forker"); err != nil { t.Fatal(err) }
+    
+    var parent, child int
+    deadline := time.Now().Add(5 * time.Second)
+    for child == 0 {
+        if time.Now().After(deadline) { t.Fatal("child never started") }
+        time.Sleep(20 * time.Millisecond)
+        data, err := os.ReadFile(childFile)
+        if err != nil || !strings.HasSuffix(string(data), "\n") { continue }
+        child, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+    }
+    sup.mu.RLock()
+    ps := sup.procs["forker"]
+    sup.mu.RUnlock()
+    ps.mu.RLock()
+    parent = ps.PID
+    ps.mu.RUnlock()
+    
+    if err := sup.Stop("forker", 2*time.Second); err != nil { t.Fatal(err) }
+    for _, pid := range []int{parent, child} {
+        for processAlive(pid) {
+            if time.Now().After(deadline) { t.Fatalf("pid %d survived Stop (parent %d, child %d)", pid, parent, child) }
+            time.Sleep(20 * time.Millisecond)
+        }
+    }
+}
+
+func TestStatsUnsupported(t *testing.T) {
+    cmd := exec.Command("sleep", "30")
+    if err := cmd.Start(); err != nil { t.Fatal(err) }
+    defer func() { _ = cmd.Process.Kill(); _ = cmd.Wait() }()
+    
+    reg := &registry.Registry{Version: "1.0", Servers: []registry.Server{{Name: "Sleeper", Slug: "sleeper"}}}
+    sup := New(reg, 0, 0)
+    defer sup.cancel() // ps isn't one Shutdown could stop
+    ps := &ProcState{Slug: "sleeper", Process: cmd.Process, PID: cmd.Process.Pid, State: ProcessRunning}
+    sup.procs["sleeper"] = ps
+    
+    if _, err := exec.LookPath("ps"); err == nil {
+        if !sup.sampleProcessStats(ps) { t.Fatal("sampling with ps failed") }
+    }
+    
+    sup.statsCmd = filepath.Join(t.TempDir(), "ps")
+    if sup.sampleProcessStats(ps) { t.Fatal("sampling without ps reported success") }
+    sup.markStatsUnsupported(ps)
+    
+    info := sup.GetProcessInfo("sleeper")
+    if info["cpuPercent"] != nil || info["rssBytes"] != nil || info["metrics"] != "unsupported" {
+        t.Fatalf("info = %v", info)
+    }
+    row := sup.Summary()[0]
+    if row["cpu"] != nil || row["ramMB"] != nil || row["metrics"] != "unsupported" {
+        t.Fatalf("summary = %v", row)
+    }
+    
+    // Processes started later skip sampling straight away
+    later := &ProcState{Slug: "later", ctx: context.Background()}
+    sup.wg.Add(1)
+    sup.metricsMonitor(later, make(chan struct{}))
+    if !later.StatsUnsupported { t.Fatal("later process still sampled") }
+}
+
+func TestSIGHUPRunsReloadHandler(t *testing.T) {
+    sup := New(&registry.Registry{Version: "1.0"}, 0, 0)
+    defer sup.Shutdown(time.Second)
+    reloaded := make(chan struct{}, 1)
+    sup.SetReloadHandler(func() { reloaded <- struct{}{} })
+
+    if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil { t.Fatal(err) }
+    select {
+    case <-reloaded:
+    case <-time.After(5 * time.Second):
+        t.Fatal("reload handler never ran")
+    }
+    select {
+    case <-sup.shutdownCh:
+        t.Fatal("SIGHUP shut the supervisor down")
+    default:
+    }
+}
+
+func TestInheritEnvPolicies(t *testing.T) {
+    t.Setenv("HOME", "/home/mcp")
+    t.Setenv("PORT", "8080")
+    t.Setenv("OTHER_SECRET", "leak")
+    t.Setenv("LANG", "C.UTF-8")
+    
+    env := func(policy string, vars ...string) map[string]string {
+        t.Helper()
+        sv := &registry.Server{Slug: "s", Entry: registry.Entry{
+            Env:         map[string]string{"MODE": "debug", "PORT": "9000"},
+            InheritEnv:  policy,
+            InheritVars: vars,
+        }}
+        list, err := (&Supervisor{}).serverEnv(sv)
+        if err != nil { t.Fatal(err) }
+        got := map[string]string{}
+        for _, kv := range list {
+            k, v, _ := strings.Cut(kv, "=")
+            got[k] = v // later entries win, as in exec
+        }
+        return got
+    }
+    
+    all := env("")
+    if all["OTHER_SECRET"] != "leak" || all["PORT"] != "9000" || all["MODE"] != "debug" { t.Fatalf("all: %v", all) }
+    
+    none := env(registry.InheritNone)
+    if len(none) != 4 || none["HOME"] != "/home/mcp" || none["PATH"] == "" || none["PORT"] != "9000" || none["MODE"] != "debug" {
+        t.Fatalf("none: %v", none)
+    }
+    
+    allow := env(registry.InheritAllowlist, "LANG", "PATH", "UNSET_VAR")
+    if len(allow) != 5 || allow["LANG"] != "C.UTF-8" || allow["OTHER_SECRET"] != "" { t.Fatalf("allowlist: %v", allow) }
+    
+    // Without any configured env a policy still applies
+    bare := &registry.Server{Slug: "s", Entry: registry.Entry{InheritEnv: registry.InheritNone}}
+    if list, _ := (&Supervisor{}).serverEnv(bare); len(list) != 2 { t.Fatalf("none without env: %v", list) }
+    bare.Entry.InheritEnv = ""
+    if list, _ := (&Supervisor{}).serverEnv(bare); list != nil { t.Fatalf("all without env should inherit, got %v", list) }
 }
@@ -6,9 +6,19 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"mcp/manager/internal/paths"
 )
 
 // KeychainVault implements secure credential storage
+//
+// Entries are keyed by scope. A provider default is stored under the bare
+// provider name (written by /v1/credentials); a per-server override is stored
+// under the server's CredentialRef, ext:<provider>:<slug>, or
+// ext:<provider>:<slug>:<profile> while a named credential profile is active
+// (written by the /v1/external/servers endpoints). Resolve applies the
+// override first.
+//
 // In production, this would integrate with OS keychain (macOS Keychain, Windows Credential Manager, etc.)
 // For now, we use encrypted file storage as a fallback
 type KeychainVault struct {
@@ -23,13 +33,8 @@ func NewKeychainVault(serviceName string) (*KeychainVault, error) {
 	}
 
 	// Create storage directory
-	home := os.Getenv("HOME")
-	if home == "" {
-		return nil, errors.New("HOME environment variable not set")
-	}
-
-	storagePath := filepath.Join(home, ".mcp", "secrets")
-	if err := os.MkdirAll(storagePath, 0700); err != nil {
+	storagePath, err := paths.SecretsDir()
+	if err != nil {
 		return nil, fmt.Errorf("failed to create secrets directory: %w", err)
 	}
 
@@ -59,7 +64,7 @@ func (k *KeychainVault) Store(provider string, credentials map[string]string) er
 	}
 
 	// Write with restrictive permissions (owner read/write only)
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
+	if err := paths.WriteFile(filePath, data, paths.SecretMode); err != nil {
 		return fmt.Errorf("failed to write credentials file: %w", err)
 	}
 
@@ -168,6 +173,23 @@ func (k *KeychainVault) HasCredentials(provider string) bool {
 	return !os.IsNotExist(err)
 }
 
+// Resolve returns the credentials an external server should use: the entry
+// stored under its ref when there is one, otherwise the provider default. The
+// key that matched is returned alongside.
+func (k *KeychainVault) Resolve(ref, provider string) (map[string]string, string, error) {
+	for _, key := range []string{ref, provider} {
+		if key == "" || !k.HasCredentials(key) {
+			continue
+		}
+		credentials, err := k.Retrieve(key)
+		if err != nil {
+			return nil, "", err
+		}
+		return credentials, key, nil
+	}
+	return nil, "", fmt.Errorf("credentials not found for %s or provider default %s", ref, provider)
+}
+
 // Clear removes all stored credentials (use with caution)
 func (k *KeychainVault) Clear() error {
 	providers, err := k.List()
@@ -179,3 +179,37 @@ func TestCredentialEntry(t *testing.T) {
 
 	_ = startTime // Use the variable to avoid compiler warnings
 }
+
+func TestResolvePrefersServerRef(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("MCP_HOME", "")
+	vault, err := NewKeychainVault("mcp-manager-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := vault.Resolve("ext:github:gh", "github"); err == nil {
+		t.Fatal("expected error with nothing stored")
+	}
+
+	if err := vault.Store("github", map[string]string{"personal_access_token": "default"}); err != nil {
+		t.Fatal(err)
+	}
+	creds, key, err := vault.Resolve("ext:github:gh", "github")
+	if err != nil || key != "github" || creds["personal_access_token"] != "default" {
+		t.Fatalf("expected provider default, got %v %q %v", creds, key, err)
+	}
+
+	if err := vault.Store("ext:github:gh", map[string]string{"personal_access_token": "override"}); err != nil {
+		t.Fatal(err)
+	}
+	creds, key, err = vault.Resolve("ext:github:gh", "github")
+	if err != nil || key != "ext:github:gh" || creds["personal_access_token"] != "override" {
+		t.Fatalf("expected server override, got %v %q %v", creds, key, err)
+	}
+
+	// Other servers of the provider still see the default
+	if creds, _, _ := vault.Resolve("ext:github:other", "github"); creds["personal_access_token"] != "default" {
+		t.Fatalf("expected provider default for other server, got %v", creds)
+	}
+}
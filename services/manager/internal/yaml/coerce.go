@@ -0,0 +1,106 @@
+package yaml
+
+import (
+	"reflect"
+	"strings"
+)
+
+func targetType(v any) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// coerce walks a parsed document alongside the Go type it will be decoded
+// into and turns typed plain scalars back into their text where that type
+// wants a string. It returns a copy, leaving anchored nodes untouched.
+func coerce(v any, t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch n := v.(type) {
+	case scalar:
+		if t != nil && t.Kind() == reflect.String {
+			return n.text
+		}
+		return n
+	case map[string]any:
+		out := make(map[string]any, len(n))
+		for k, child := range n {
+			out[k] = coerce(child, childType(t, k))
+		}
+		return out
+	case []any:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		out := make([]any, len(n))
+		for i, child := range n {
+			out[i] = coerce(child, elem)
+		}
+		return out
+	}
+	return v
+}
+
+// childType is the type of the value under key in a map or struct of type t,
+// matching keys to json names the way encoding/json does.
+func childType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		var fold reflect.Type
+		for _, f := range jsonFields(t) {
+			if f.name == key {
+				return f.typ
+			}
+			if fold == nil && strings.EqualFold(f.name, key) {
+				fold = f.typ
+			}
+		}
+		return fold
+	}
+	return nil
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// jsonFields lists the fields encoding/json would use for struct type t,
+// including those promoted from embedded structs.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			fields = append(fields, jsonFields(ft)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name, f.Type})
+	}
+	return fields
+}
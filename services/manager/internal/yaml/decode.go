@@ -0,0 +1,614 @@
+// Package yaml reads and writes the YAML subset used for hand-written
+// config: block and flow mappings and sequences, plain, quoted and block
+// scalars, comments, and anchors, aliases and "<<" merge keys. Values are
+// bridged through encoding/json, so structs keep their json tags and shapes.
+// Tags, multiple documents and complex keys are not supported.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IsYAML reports whether path names a YAML file, going by its extension.
+func IsYAML(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// Unmarshal parses YAML data into v the way json.Unmarshal would parse the
+// equivalent JSON. Unquoted scalars bound for string fields keep their text,
+// so "version: 1.0" fills a string with "1.0".
+func Unmarshal(data []byte, v any) error {
+	doc, err := parse(data)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(coerce(doc, targetType(v)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ToJSON converts a YAML document to JSON.
+func ToJSON(data []byte) ([]byte, error) {
+	doc, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// SyntaxError reports malformed YAML and the line it was found on.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string { return fmt.Sprintf("yaml: line %d: %s", e.Line, e.Msg) }
+
+// scalar is a resolved plain scalar that remembers its source text, so it
+// can still become a string when the target field is one.
+type scalar struct {
+	text  string
+	value any // bool, json.Number
+}
+
+func (s scalar) MarshalJSON() ([]byte, error) { return json.Marshal(s.value) }
+
+type parser struct {
+	lines   []string
+	pos     int
+	anchors map[string]any
+}
+
+func parse(data []byte) (any, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	p := &parser{lines: strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), anchors: map[string]any{}}
+	ind, content, ok, err := p.peek()
+	if err != nil || !ok {
+		return nil, err
+	}
+	v, err := p.block(ind, content)
+	if err != nil {
+		return nil, err
+	}
+	if _, content, ok, err := p.peek(); err != nil {
+		return nil, err
+	} else if ok {
+		return nil, p.errorf("unexpected %q after the document", content)
+	}
+	return v, nil
+}
+
+func (p *parser) errorf(format string, a ...any) error {
+	return &SyntaxError{Line: p.pos + 1, Msg: fmt.Sprintf(format, a...)}
+}
+
+// peek skips blank lines, comments and document markers and returns the
+// indentation and comment-free content of the next line.
+func (p *parser) peek() (int, string, bool, error) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		content := strings.TrimLeft(line, " ")
+		ind := len(line) - len(content)
+		if strings.HasPrefix(content, "\t") {
+			return 0, "", false, p.errorf("tabs are not allowed in indentation")
+		}
+		content = strings.TrimSpace(stripComment(content))
+		if content == "" || ind == 0 && (content == "---" || content == "...") {
+			continue
+		}
+		if ind == 0 && strings.HasPrefix(content, "%") {
+			return 0, "", false, p.errorf("directives are not supported")
+		}
+		return ind, content, true, nil
+	}
+	return 0, "", false, nil
+}
+
+// stripComment drops a "#" comment that starts a line or follows a space,
+// outside of quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					quote = 0
+				}
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t[{,:-", rune(s[i-1])) {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+// block parses the node whose first line is the current one.
+func (p *parser) block(ind int, content string) (any, error) {
+	switch {
+	case isSeqItem(content):
+		return p.sequence(ind)
+	case mappingKey(content) >= 0:
+		return p.mapping(ind)
+	}
+	p.pos++
+	return p.inline(content, ind)
+}
+
+func isSeqItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// mappingKey returns the index of the ":" ending the key in content, or -1.
+func mappingKey(content string) int {
+	if content == "" || strings.ContainsRune("[{|>", rune(content[0])) {
+		return -1
+	}
+	if content[0] == '"' || content[0] == '\'' {
+		end := closingQuote(content)
+		if end < 0 || end+1 >= len(content) || content[end+1] != ':' {
+			return -1
+		}
+		if end+2 == len(content) || content[end+2] == ' ' {
+			return end + 1
+		}
+		return -1
+	}
+	for i := 0; i < len(content); i++ {
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+// closingQuote returns the index of the quote closing the string s starts with.
+func closingQuote(s string) int {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q:
+			if q == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *parser) mapping(ind int) (any, error) {
+	m := map[string]any{}
+	var merges []any
+	for {
+		lineInd, content, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || lineInd < ind {
+			break
+		}
+		if lineInd > ind {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isSeqItem(content) {
+			return nil, p.errorf("sequence item where a mapping key was expected")
+		}
+		colon := mappingKey(content)
+		if colon < 0 {
+			return nil, p.errorf("expected \"key: value\", got %q", content)
+		}
+		key, err := p.key(content[:colon])
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		v, err := p.value(strings.TrimSpace(content[colon+1:]), ind, true)
+		if err != nil {
+			return nil, err
+		}
+		if key == "<<" {
+			merges = append(merges, v)
+			continue
+		}
+		m[key] = v
+	}
+	// Explicit keys win over merged ones, whatever their order
+	for _, merge := range merges {
+		sources := []any{merge}
+		if list, ok := merge.([]any); ok {
+			sources = list
+		}
+		for _, src := range sources {
+			sm, ok := src.(map[string]any)
+			if !ok {
+				return nil, p.errorf("\"<<\" must merge a mapping or a list of mappings")
+			}
+			for k, v := range sm {
+				if _, set := m[k]; !set {
+					m[k] = v
+				}
+			}
+		}
+	}
+	return m, nil
+}
+
+func (p *parser) key(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", p.errorf("empty key")
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		v, err := p.quoted(s)
+		if err != nil {
+			return "", err
+		}
+		return v, nil
+	}
+	if strings.ContainsRune("&*!?", rune(s[0])) {
+		return "", p.errorf("anchors, aliases, tags and complex keys are not supported as keys")
+	}
+	return s, nil
+}
+
+func (p *parser) sequence(ind int) (any, error) {
+	list := []any{}
+	for {
+		lineInd, content, ok, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if !ok || lineInd < ind {
+			break
+		}
+		if lineInd > ind {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if !isSeqItem(content) {
+			break
+		}
+		rest := strings.TrimSpace(strings.TrimPrefix(content, "-"))
+		// "- key: value" and "- - item" start a nested node on the item's own
+		// line; rewrite the line as if that node were on a line of its own.
+		if rest != "" && (isSeqItem(rest) || mappingKey(rest) >= 0 && !strings.HasPrefix(rest, "&")) {
+			line := p.lines[p.pos]
+			after := line[ind+1:]
+			childInd := ind + 1 + len(after) - len(strings.TrimLeft(after, " "))
+			p.lines[p.pos] = strings.Repeat(" ", childInd) + line[childInd:]
+			v, err := p.block(childInd, rest)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		p.pos++
+		v, err := p.value(rest, ind, false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// value parses what follows "key:" or "- " at parent indentation ind. In a
+// mapping a sequence may sit at the key's own indentation.
+func (p *parser) value(rest string, ind int, inMapping bool) (any, error) {
+	anchor := ""
+	if strings.HasPrefix(rest, "&") {
+		name, after, _ := strings.Cut(rest[1:], " ")
+		if name == "" {
+			return nil, p.errorf("empty anchor name")
+		}
+		anchor, rest = name, strings.TrimSpace(after)
+	}
+	var v any
+	var err error
+	switch {
+	case rest == "":
+		v, err = p.nested(ind, inMapping)
+	case rest[0] == '|' || rest[0] == '>':
+		v, err = p.blockScalar(rest, ind)
+	default:
+		v, err = p.inline(rest, ind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		p.anchors[anchor] = v
+	}
+	return v, nil
+}
+
+// nested parses the block below a key or item with nothing after it, or null.
+func (p *parser) nested(ind int, inMapping bool) (any, error) {
+	childInd, content, ok, err := p.peek()
+	if err != nil || !ok {
+		return nil, err
+	}
+	if childInd > ind || inMapping && childInd == ind && isSeqItem(content) {
+		return p.block(childInd, content)
+	}
+	return nil, nil
+}
+
+// inline parses a scalar, alias or flow collection that starts on the line
+// before p.pos, continuing onto following lines while brackets are open.
+func (p *parser) inline(s string, ind int) (any, error) {
+	if strings.HasPrefix(s, "*") {
+		v, ok := p.anchors[s[1:]]
+		if !ok {
+			return nil, p.errorf("unknown alias %q", s)
+		}
+		return v, nil
+	}
+	if strings.HasPrefix(s, "!") {
+		return nil, p.errorf("tags are not supported")
+	}
+	if s[0] == '[' || s[0] == '{' {
+		for !flowClosed(s) {
+			if p.pos >= len(p.lines) {
+				return nil, p.errorf("unterminated flow collection")
+			}
+			s += " " + strings.TrimSpace(stripComment(p.lines[p.pos]))
+			p.pos++
+		}
+		f := &flow{p: p, s: s}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		if f.skipSpace(); f.i != len(f.s) {
+			return nil, p.errorf("unexpected %q after flow collection", f.s[f.i:])
+		}
+		return v, nil
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		// A quoted scalar may continue on more indented lines
+		for closingQuote(s) < 0 && p.pos < len(p.lines) {
+			s += " " + strings.TrimSpace(p.lines[p.pos])
+			p.pos++
+		}
+		end := closingQuote(s)
+		if end < 0 {
+			return nil, p.errorf("unterminated quoted string")
+		}
+		if strings.TrimSpace(s[end+1:]) != "" {
+			return nil, p.errorf("unexpected %q after quoted string", s[end+1:])
+		}
+		return p.quoted(s)
+	}
+	// Plain scalars may be folded over more indented lines
+	for p.pos < len(p.lines) {
+		lineInd, content, ok, err := p.peek()
+		if err != nil || !ok || lineInd <= ind || isSeqItem(content) || mappingKey(content) >= 0 {
+			break
+		}
+		s += " " + content
+		p.pos++
+	}
+	return resolve(s), nil
+}
+
+// flowClosed reports whether every bracket opened in s is closed.
+func flowClosed(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+func (p *parser) quoted(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	// YAML's escapes are a superset of JSON's; cover the extra common ones
+	body := s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 >= len(body) {
+			return "", p.errorf("bad escape at end of string")
+		}
+		i++
+		switch e := body[i]; e {
+		case '0':
+			b.WriteByte(0)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 't', '\t':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'v':
+			b.WriteByte('\v')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte(0x1b)
+		case ' ', '"', '/', '\\':
+			b.WriteByte(e)
+		case 'x', 'u', 'U':
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+			if i+n >= len(body) {
+				return "", p.errorf("short \\%c escape", e)
+			}
+			r, err := strconv.ParseUint(body[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", p.errorf("bad \\%c escape", e)
+			}
+			b.WriteRune(rune(r))
+			i += n
+		default:
+			return "", p.errorf("unknown escape \\%c", e)
+		}
+	}
+	return b.String(), nil
+}
+
+// blockScalar parses a "|" (literal) or ">" (folded) scalar below ind.
+func (p *parser) blockScalar(header string, ind int) (any, error) {
+	style, chomp := header[0], byte(0)
+	for _, c := range []byte(strings.TrimSpace(header[1:])) {
+		switch {
+		case c == '-' || c == '+':
+			chomp = c
+		case c >= '1' && c <= '9':
+			// Explicit indentation is implied by the first line below
+		default:
+			return nil, p.errorf("bad block scalar header %q", header)
+		}
+	}
+	var lines []string
+	contentInd := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := strings.TrimRight(p.lines[p.pos], " \r")
+		trimmed := strings.TrimLeft(line, " ")
+		lineInd := len(line) - len(trimmed)
+		if trimmed == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if contentInd < 0 {
+			if lineInd <= ind {
+				break
+			}
+			contentInd = lineInd
+		}
+		if lineInd < contentInd {
+			break
+		}
+		lines = append(lines, line[contentInd:])
+	}
+	// Trailing blank lines belong to chomping, not to the next node
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	body := lines[:len(lines)-trailing]
+	var text string
+	if style == '|' {
+		text = strings.Join(body, "\n")
+	} else {
+		var b strings.Builder
+		for i, l := range body {
+			if i > 0 {
+				switch {
+				case l == "" || body[i-1] == "":
+					b.WriteString("\n")
+				case strings.HasPrefix(l, " ") || strings.HasPrefix(body[i-1], " "):
+					b.WriteString("\n")
+				default:
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(l)
+		}
+		text = b.String()
+	}
+	switch {
+	case len(body) == 0:
+		return "", nil
+	case chomp == '-':
+		return text, nil
+	case chomp == '+':
+		return text + strings.Repeat("\n", trailing+1), nil
+	}
+	return text + "\n", nil
+}
+
+var (
+	intRE   = regexp.MustCompile(`^[-+]?(0|[1-9][0-9_]*)$`)
+	hexRE   = regexp.MustCompile(`^0x[0-9a-fA-F_]+$`)
+	octRE   = regexp.MustCompile(`^0o[0-7_]+$`)
+	floatRE = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9][0-9_]*(\.[0-9_]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolve types a plain scalar following the YAML 1.2 core schema.
+func resolve(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return scalar{s, true}
+	case "false", "False", "FALSE":
+		return scalar{s, false}
+	}
+	clean := strings.ReplaceAll(s, "_", "")
+	switch {
+	case intRE.MatchString(s):
+		if n, err := strconv.ParseInt(clean, 10, 64); err == nil {
+			return scalar{s, json.Number(strconv.FormatInt(n, 10))}
+		}
+	case hexRE.MatchString(s), octRE.MatchString(s):
+		base := 16
+		if s[1] == 'o' {
+			base = 8
+		}
+		if n, err := strconv.ParseInt(clean[2:], base, 64); err == nil {
+			return scalar{s, json.Number(strconv.FormatInt(n, 10))}
+		}
+	case floatRE.MatchString(s):
+		if f, err := strconv.ParseFloat(clean, 64); err == nil && !math.IsInf(f, 0) {
+			return scalar{s, json.Number(strconv.FormatFloat(f, 'g', -1, 64))}
+		}
+	}
+	return s
+}
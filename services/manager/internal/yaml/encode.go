@@ -0,0 +1,189 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Marshal encodes v as block YAML. It goes through v's JSON encoding, so
+// json tags, omitempty and MarshalJSON methods apply and keys keep their
+// JSON order.
+func Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	n, err := readNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	var out strings.Builder
+	if n.inline() {
+		out.WriteString(n.scalar())
+		out.WriteByte('\n')
+	} else {
+		n.write(&out, 0)
+	}
+	return []byte(out.String()), nil
+}
+
+// Holds reports whether the YAML document data decodes to the same value as
+// v, compared through their JSON encodings. Marshal keeps no comments,
+// anchors or layout, so writers use it to leave a hand-written file alone
+// when a save would change nothing.
+func Holds(data []byte, v any) bool {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	current := reflect.New(t).Interface()
+	if err := Unmarshal(data, current); err != nil {
+		return false
+	}
+	want, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	got, err := json.Marshal(current)
+	return err == nil && bytes.Equal(got, want)
+}
+
+// node is a JSON value with its object keys kept in order.
+type node struct {
+	keys  []string // object keys, parallel to items
+	items []*node  // object values or array elements
+	array bool
+	value any // string, json.Number, bool or nil for a scalar
+	coll  bool
+}
+
+func readNode(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return &node{value: tok}, nil
+	}
+	n := &node{coll: true, array: delim == '['}
+	for dec.More() {
+		if !n.array {
+			kt, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := kt.(string)
+			if !ok {
+				return nil, fmt.Errorf("yaml: unexpected object key %v", kt)
+			}
+			n.keys = append(n.keys, key)
+		}
+		child, err := readNode(dec)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, child)
+	}
+	// The closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// inline reports whether n is written on its parent's line.
+func (n *node) inline() bool { return !n.coll || len(n.items) == 0 }
+
+func (n *node) scalar() string {
+	switch v := n.value.(type) {
+	case nil:
+		if n.coll {
+			if n.array {
+				return "[]"
+			}
+			return "{}"
+		}
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case json.Number:
+		return v.String()
+	case string:
+		return quote(v)
+	}
+	return fmt.Sprint(n.value)
+}
+
+// write emits a non-empty collection with its lines indented by ind.
+func (n *node) write(b *strings.Builder, ind int) {
+	pad := strings.Repeat(" ", ind)
+	for i, child := range n.items {
+		if n.array {
+			b.WriteString(pad + "-")
+			if child.inline() {
+				b.WriteString(" " + child.scalar() + "\n")
+				continue
+			}
+			// The child's first line shares the dash's line
+			var nested strings.Builder
+			child.write(&nested, ind+2)
+			b.WriteString(" " + nested.String()[ind+2:])
+			continue
+		}
+		b.WriteString(pad + quote(n.keys[i]) + ":")
+		if child.inline() {
+			b.WriteString(" " + child.scalar() + "\n")
+			continue
+		}
+		b.WriteString("\n")
+		child.write(b, ind+2)
+	}
+}
+
+// quote returns s as a plain scalar when it reads back as the same string,
+// and double-quoted otherwise.
+func quote(s string) string {
+	if plainSafe(s) {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func plainSafe(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return false
+	}
+	if _, isString := resolve(s).(string); !isString {
+		return false
+	}
+	if strings.ContainsRune("?:,[]{}#&*!|>'\"%@`", rune(s[0])) || s[0] == '-' && (len(s) == 1 || s[1] == ' ') {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
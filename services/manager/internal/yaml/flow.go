@@ -0,0 +1,143 @@
+package yaml
+
+import "strings"
+
+// flow parses a flow collection such as [a, b] or {k: v, n: 1}.
+type flow struct {
+	p *parser
+	s string
+	i int
+}
+
+func (f *flow) skipSpace() {
+	for f.i < len(f.s) && f.s[f.i] == ' ' {
+		f.i++
+	}
+}
+
+func (f *flow) value() (any, error) {
+	f.skipSpace()
+	if f.i >= len(f.s) {
+		return nil, f.p.errorf("unterminated flow collection")
+	}
+	switch c := f.s[f.i]; {
+	case c == '[':
+		return f.sequence()
+	case c == '{':
+		return f.mapping()
+	case c == '"' || c == '\'':
+		return f.quoted()
+	case c == '*':
+		name := f.plain(false)
+		v, ok := f.p.anchors[name[1:]]
+		if !ok {
+			return nil, f.p.errorf("unknown alias %q", name)
+		}
+		return v, nil
+	}
+	return resolve(f.plain(false)), nil
+}
+
+func (f *flow) quoted() (string, error) {
+	end := closingQuote(f.s[f.i:])
+	if end < 0 {
+		return "", f.p.errorf("unterminated quoted string")
+	}
+	v, err := f.p.quoted(f.s[f.i : f.i+end+1])
+	f.i += end + 1
+	return v, err
+}
+
+// plain reads an unquoted scalar up to the next indicator. Keys also stop
+// at ": ", values only at the end of the entry.
+func (f *flow) plain(key bool) string {
+	start := f.i
+	for ; f.i < len(f.s); f.i++ {
+		c := f.s[f.i]
+		if c == ',' || c == ']' || c == '}' {
+			break
+		}
+		if key && c == ':' && (f.i+1 == len(f.s) || strings.ContainsRune(" ,}", rune(f.s[f.i+1]))) {
+			break
+		}
+	}
+	return strings.TrimSpace(f.s[start:f.i])
+}
+
+func (f *flow) sequence() (any, error) {
+	f.i++
+	list := []any{}
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == ']' {
+			f.i++
+			return list, nil
+		}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		if err := f.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *flow) mapping() (any, error) {
+	f.i++
+	m := map[string]any{}
+	for {
+		f.skipSpace()
+		if f.i < len(f.s) && f.s[f.i] == '}' {
+			f.i++
+			return m, nil
+		}
+		var key string
+		var err error
+		if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+			key, err = f.quoted()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			key = f.plain(true)
+		}
+		if key == "" {
+			return nil, f.p.errorf("empty key in flow mapping")
+		}
+		if _, dup := m[key]; dup {
+			return nil, f.p.errorf("duplicate key %q", key)
+		}
+		f.skipSpace()
+		var v any
+		if f.i < len(f.s) && f.s[f.i] == ':' {
+			f.i++
+			f.skipSpace()
+			if f.i < len(f.s) && f.s[f.i] != ',' && f.s[f.i] != '}' {
+				if v, err = f.value(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		m[key] = v
+		if err := f.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the "," between entries, leaving a closing bracket
+// for the caller.
+func (f *flow) separator(closing byte) error {
+	f.skipSpace()
+	switch {
+	case f.i >= len(f.s):
+		return f.p.errorf("unterminated flow collection")
+	case f.s[f.i] == ',':
+		f.i++
+	case f.s[f.i] != closing:
+		return f.p.errorf("expected \",\" or %q in flow collection, got %q", closing, f.s[f.i:])
+	}
+	return nil
+}
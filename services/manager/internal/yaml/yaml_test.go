@@ -0,0 +1,119 @@
+package yaml
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testEntry struct {
+	Command string            `json:"command"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Port    int               `json:"port,omitempty"`
+	Enabled bool              `json:"enabled"`
+}
+
+type testDoc struct {
+	Version string      `json:"version"`
+	Servers []testEntry `json:"servers"`
+	Notes   string      `json:"notes,omitempty"`
+	Ratio   float64     `json:"ratio,omitempty"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	src := `
+# Servers I run every day
+version: 1.0   # stays a string
+defaults: &node
+  command: node
+  enabled: true
+  env: {NODE_ENV: production, "LOG_LEVEL": 'info'}
+servers:
+  - <<: *node
+    args: [server.js, --port, "8080"]
+    port: 0x1F90
+  - command: "python3"
+    args:
+    - -m
+    - demo.server
+    env:
+      GREETING: "hello\tworld # not a comment"
+      EMPTY: ""
+    enabled: false
+notes: |
+  first line
+    indented
+  last line
+ratio: 1_000.5
+`
+	var doc testDoc
+	if err := Unmarshal([]byte(src), &doc); err != nil {
+		t.Fatal(err)
+	}
+	want := testDoc{
+		Version: "1.0",
+		Servers: []testEntry{
+			{Command: "node", Args: []string{"server.js", "--port", "8080"}, Env: map[string]string{"NODE_ENV": "production", "LOG_LEVEL": "info"}, Port: 8080, Enabled: true},
+			{Command: "python3", Args: []string{"-m", "demo.server"}, Env: map[string]string{"GREETING": "hello\tworld # not a comment", "EMPTY": ""}},
+		},
+		Notes: "first line\n  indented\nlast line\n",
+		Ratio: 1000.5,
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("got  %+v\nwant %+v", doc, want)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	doc := testDoc{
+		Version: "1.0",
+		Servers: []testEntry{
+			{Command: "node", Args: []string{"-", "- x", "true", "a: b", "#tag", ""}, Env: map[string]string{"K": "multi\nline", "Q": `"quoted"`}, Port: 7},
+			{Command: "null"},
+		},
+		Notes: "x",
+	}
+	out, err := Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back testDoc
+	if err := Unmarshal(out, &back); err != nil {
+		t.Fatalf("%v in\n%s", err, out)
+	}
+	if !reflect.DeepEqual(back, doc) {
+		t.Fatalf("round trip changed the document:\n%s\ngot %+v", out, back)
+	}
+	// Empty collections and nested lists survive too
+	j, err := ToJSON([]byte("a: []\nb: {}\nc:\n- - 1\n  - 2\n- []\n"))
+	if err != nil || string(j) != `{"a":[],"b":{},"c":[[1,2],[]]}` {
+		t.Fatalf("ToJSON = %s, %v", j, err)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	for _, src := range []string{
+		"a: 1\n  b: 2\n",
+		"a: 1\na: 2\n",
+		"a: *missing\n",
+		"a: [1, 2\n",
+		"a:\n\t- 1\n",
+		"a: \"open\n",
+		"!!map\n",
+	} {
+		var v any
+		err := Unmarshal([]byte(src), &v)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("%q: got %v, want a SyntaxError", src, err)
+		}
+	}
+	// Type mismatches are reported the way encoding/json reports them
+	var doc testDoc
+	var te *json.UnmarshalTypeError
+	if err := Unmarshal([]byte("servers: {}\n"), &doc); !errors.As(err, &te) {
+		t.Fatalf("type mismatch: %v", err)
+	}
+}
@@ -0,0 +1,5 @@
+// Package proto holds the gRPC service definition of the manager's control
+// interface and the Go generated from it.
+package proto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto